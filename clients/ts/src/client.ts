@@ -0,0 +1,492 @@
+// Code generated by cmd/openapi-ts from api/openapi.json. DO NOT EDIT.
+
+export interface APIError {
+  code: string;
+  details?: Record<string, unknown>;
+  message: string;
+}
+
+export interface AcronymDictionary {
+  acronyms: Record<string, unknown>;
+}
+
+export interface AcronymEntry {
+  acronym: string;
+  expansion: string;
+}
+
+export interface AcronymExpansion {
+  expansion: string;
+}
+
+export interface AudioMetadata {
+  channels?: number;
+  container: string;
+  duration_ms?: number;
+  sample_rate?: number;
+}
+
+export interface Chapter {
+  end: number;
+  start: number;
+  title: string;
+}
+
+export interface ChaptersRequest {
+  max_chapters?: number;
+  model?: string;
+  response_format?: "json" | "vtt";
+  segments: TranscriptionSegment[];
+  timeout_ms?: number;
+}
+
+export interface ChaptersResponse {
+  chapters: Chapter[];
+  usage?: TokenUsage;
+}
+
+export interface ErrorResponse {
+  error: APIError;
+  request_id?: string;
+}
+
+export interface HealthResponse {
+  ok: boolean;
+}
+
+export interface JobResponse {
+  created_at: string;
+  error?: APIError;
+  id: string;
+  progress: number;
+  result?: PipelineProcessResponse;
+  stage?: string;
+  status: "queued" | "running" | "succeeded" | "failed";
+  updated_at: string;
+}
+
+export interface JobWebhook {
+  created_at: string;
+  delivery_id: string;
+  error?: APIError;
+  event: "job.succeeded" | "job.failed";
+  id: string;
+  progress: number;
+  result?: PipelineProcessResponse;
+  stage?: string;
+  status: "succeeded" | "failed";
+  updated_at: string;
+}
+
+export interface PipelineBatchResponse {
+  failed: number;
+  results: PipelineBatchResult[];
+  succeeded: number;
+}
+
+export interface PipelineBatchResult {
+  error?: APIError;
+  file_name: string;
+  index: number;
+  result?: PipelineProcessResponse;
+  status: number;
+}
+
+export interface PipelineChunkTiming {
+  end: number;
+  speaker?: string;
+  start: number;
+  transcription: number;
+}
+
+export interface PipelineProcessResponse {
+  audio?: AudioMetadata;
+  cached?: boolean;
+  final_transcript: string;
+  language?: string;
+  post_processing_outcome: "succeeded" | "succeeded_after_retry" | "fallback";
+  post_processing_status: string;
+  post_processing_tier?: "small" | "large";
+  post_processing_usage?: TokenUsage;
+  preprocessing?: string[];
+  raw_transcript: string;
+  spelled_words?: string[];
+  stages?: PipelineStageResult[];
+  timings_ms: PipelineTimings;
+  warnings?: string[];
+  words?: TranscriptionWord[];
+}
+
+export interface PipelineRequest {
+  collapse_spellings?: boolean;
+  context_summary?: string;
+  custom_system_prompt?: string;
+  custom_vocabulary?: string;
+  downmix?: boolean;
+  expand_acronyms?: boolean;
+  fallback_policy?: "fallback" | "error" | "retry_then_fallback";
+  file: Blob[];
+  include_debug?: boolean;
+  language?: string;
+  normalize?: boolean;
+  post_process_model?: string;
+  resample_hz?: number;
+  response_format?: "json" | "srt" | "vtt";
+  return_audio?: boolean;
+  speaker_labels?: string;
+  spellings_to_vocabulary?: boolean;
+  split_channels?: boolean;
+  stages?: string;
+  timeout_ms?: number;
+  timestamp_granularities?: string;
+  transcode?: boolean;
+  transcription_model?: string;
+  transcription_prompt?: string;
+  trim_silence?: boolean;
+}
+
+export interface PipelineStageEvent {
+  duration_ms: number;
+  outcome?: "succeeded" | "succeeded_after_retry" | "fallback";
+  stage: "transcription" | "post_processing";
+  status?: string;
+  transcript: string;
+}
+
+export interface PipelineStageResult {
+  error?: string;
+  output?: string;
+  stage: string;
+  usage?: TokenUsage;
+}
+
+export interface PipelineTimings {
+  chunks?: PipelineChunkTiming[];
+  post_processing: number;
+  stages?: Record<string, unknown>;
+  total: number;
+  transcription: number;
+}
+
+export interface PipelineURLRequest {
+  audio_url: string;
+  callback_url?: string;
+  collapse_spellings?: boolean;
+  context_summary?: string;
+  custom_system_prompt?: string;
+  custom_vocabulary?: string;
+  downmix?: boolean;
+  expand_acronyms?: boolean;
+  fallback_policy?: "fallback" | "error" | "retry_then_fallback";
+  include_debug?: boolean;
+  language?: string;
+  normalize?: boolean;
+  post_process_model?: string;
+  resample_hz?: number;
+  response_format?: "json" | "srt" | "vtt";
+  return_audio?: boolean;
+  speaker_labels?: string[];
+  spellings_to_vocabulary?: boolean;
+  split_channels?: boolean;
+  stages?: string[];
+  timeout_ms?: number;
+  timestamp_granularities?: ("segment" | "word")[];
+  transcode?: boolean;
+  transcription_model?: string;
+  transcription_prompt?: string;
+  trim_silence?: boolean;
+}
+
+export interface PostProcessDelta {
+  content: string;
+}
+
+export interface PostProcessRequest {
+  collapse_spellings?: boolean;
+  context_summary?: string;
+  custom_system_prompt?: string;
+  custom_vocabulary?: string;
+  expand_acronyms?: boolean;
+  model?: string;
+  spellings_to_vocabulary?: boolean;
+  timeout_ms?: number;
+  transcript: string;
+}
+
+export interface PostProcessResponse {
+  spelled_words?: string[];
+  status: string;
+  tier?: "small" | "large";
+  transcript: string;
+  usage?: TokenUsage;
+}
+
+export interface ReadyResponse {
+  ok: boolean;
+  service_name?: string;
+}
+
+export interface RequestQuota {
+  limit: number;
+  remaining: number;
+  resets_at: string;
+  used: number;
+}
+
+export interface TokenUsage {
+  completion_tokens: number;
+  prompt_tokens: number;
+  total_tokens: number;
+}
+
+export interface TranscriptionRequest {
+  downmix?: boolean;
+  file: Blob;
+  language?: string;
+  model?: string;
+  normalize?: boolean;
+  prompt?: string;
+  resample_hz?: number;
+  response_format?: "json" | "verbose_json" | "srt" | "vtt";
+  timeout_ms?: number;
+  timestamp_granularities?: ("segment" | "word")[];
+  transcode?: boolean;
+  trim_silence?: boolean;
+}
+
+export interface TranscriptionResponse {
+  audio?: AudioMetadata;
+  language?: string;
+  preprocessing?: string[];
+  text: string;
+  warnings?: string[];
+}
+
+export interface TranscriptionSegment {
+  confidence?: number;
+  end: number;
+  start: number;
+  text: string;
+}
+
+export interface TranscriptionVerboseResponse {
+  audio?: AudioMetadata;
+  language?: string;
+  preprocessing?: string[];
+  segments: TranscriptionSegment[];
+  text: string;
+  warnings?: string[];
+  words?: TranscriptionWord[];
+}
+
+export interface TranscriptionWord {
+  confidence?: number;
+  end: number;
+  speaker?: string;
+  start: number;
+  word: string;
+}
+
+export interface TranslationRequest {
+  downmix?: boolean;
+  file: Blob;
+  model?: string;
+  normalize?: boolean;
+  prompt?: string;
+  resample_hz?: number;
+  response_format?: "json" | "verbose_json" | "srt" | "vtt";
+  timeout_ms?: number;
+  transcode?: boolean;
+  trim_silence?: boolean;
+}
+
+export interface WhoAmIQuotas {
+  daily_requests?: RequestQuota;
+}
+
+export interface WhoAmIResponse {
+  kind: "token" | "byot" | "anonymous";
+  name: string;
+  quotas: WhoAmIQuotas;
+  scopes: string[];
+  tenant?: string;
+  tier: "standard" | "premium";
+  token_fingerprint?: string;
+}
+
+export interface ClientOptions {
+  baseUrl?: string;
+  token?: string;
+  headers?: Record<string, string>;
+  fetch?: typeof fetch;
+}
+
+export class EchoFlowError extends Error {
+  constructor(
+    readonly status: number,
+    readonly error: APIError | undefined,
+    readonly requestId: string | undefined,
+  ) {
+    super(error?.message ?? `EchoFlow request failed with status ${status}`);
+    this.name = "EchoFlowError";
+  }
+}
+
+export class EchoFlowClient {
+  private readonly baseUrl: string;
+  private readonly fetchImpl: typeof fetch;
+
+  constructor(private readonly options: ClientOptions = {}) {
+    this.baseUrl = (options.baseUrl ?? "http://localhost:8080").replace(/\/+$/, "");
+    this.fetchImpl = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  /** GET /healthz */
+  async healthz(init?: RequestInit): Promise<HealthResponse> {
+    const res = await this.send("GET", `/healthz`, undefined, undefined, init);
+    return (await res.json()) as HealthResponse;
+  }
+
+  /** GET /metrics */
+  async metrics(init?: RequestInit): Promise<string> {
+    const res = await this.send("GET", `/metrics`, undefined, undefined, init);
+    return res.text();
+  }
+
+  /** GET /readyz */
+  async readyz(init?: RequestInit): Promise<ReadyResponse> {
+    const res = await this.send("GET", `/readyz`, undefined, undefined, init);
+    return (await res.json()) as ReadyResponse;
+  }
+
+  /** GET /v1/acronyms */
+  async listAcronyms(init?: RequestInit): Promise<AcronymDictionary> {
+    const res = await this.send("GET", `/v1/acronyms`, undefined, undefined, init);
+    return (await res.json()) as AcronymDictionary;
+  }
+
+  /** PUT /v1/acronyms/{acronym} */
+  async putAcronym(acronym: string, body: AcronymExpansion, init?: RequestInit): Promise<AcronymEntry> {
+    const res = await this.send("PUT", `/v1/acronyms/${encodeURIComponent(acronym)}`, JSON.stringify(body), "application/json", init);
+    return (await res.json()) as AcronymEntry;
+  }
+
+  /** DELETE /v1/acronyms/{acronym} */
+  async deleteAcronym(acronym: string, init?: RequestInit): Promise<void> {
+    const res = await this.send("DELETE", `/v1/acronyms/${encodeURIComponent(acronym)}`, undefined, undefined, init);
+    return undefined;
+  }
+
+  /** GET /v1/auth/whoami */
+  async whoami(init?: RequestInit): Promise<WhoAmIResponse> {
+    const res = await this.send("GET", `/v1/auth/whoami`, undefined, undefined, init);
+    return (await res.json()) as WhoAmIResponse;
+  }
+
+  /** POST /v1/chapters */
+  async chapters(body: ChaptersRequest, init?: RequestInit): Promise<ChaptersResponse> {
+    const res = await this.send("POST", `/v1/chapters`, JSON.stringify(body), "application/json", init);
+    return (await res.json()) as ChaptersResponse;
+  }
+
+  /** POST /v1/jobs */
+  async createJob(body: PipelineRequest | PipelineURLRequest, init?: RequestInit): Promise<JobResponse> {
+    const res = await this.send("POST", `/v1/jobs`, hasBlob(body) ? toFormData(body) : JSON.stringify(body), hasBlob(body) ? undefined : "application/json", init);
+    return (await res.json()) as JobResponse;
+  }
+
+  /** GET /v1/jobs/{id} */
+  async getJob(id: string, init?: RequestInit): Promise<JobResponse> {
+    const res = await this.send("GET", `/v1/jobs/${encodeURIComponent(id)}`, undefined, undefined, init);
+    return (await res.json()) as JobResponse;
+  }
+
+  /** GET /v1/jobs/{id}/events */
+  async streamJobEvents(id: string, init?: RequestInit): Promise<Response> {
+    const res = await this.send("GET", `/v1/jobs/${encodeURIComponent(id)}/events`, undefined, undefined, init);
+    return res;
+  }
+
+  /** POST /v1/pipeline/batch */
+  async processPipelineBatch(body: PipelineRequest, init?: RequestInit): Promise<PipelineBatchResponse> {
+    const res = await this.send("POST", `/v1/pipeline/batch`, toFormData(body), undefined, init);
+    return (await res.json()) as PipelineBatchResponse;
+  }
+
+  /** POST /v1/pipeline/process */
+  async processPipeline(body: PipelineRequest | PipelineURLRequest, init?: RequestInit): Promise<PipelineProcessResponse> {
+    const res = await this.send("POST", `/v1/pipeline/process`, hasBlob(body) ? toFormData(body) : JSON.stringify(body), hasBlob(body) ? undefined : "application/json", init);
+    return (await res.json()) as PipelineProcessResponse;
+  }
+
+  /** POST /v1/post-process */
+  async postProcess(body: PostProcessRequest, init?: RequestInit): Promise<PostProcessResponse> {
+    const res = await this.send("POST", `/v1/post-process`, JSON.stringify(body), "application/json", init);
+    return (await res.json()) as PostProcessResponse;
+  }
+
+  /** POST /v1/transcriptions */
+  async createTranscription(body: TranscriptionRequest, init?: RequestInit): Promise<TranscriptionResponse> {
+    const res = await this.send("POST", `/v1/transcriptions`, toFormData(body), undefined, init);
+    return (await res.json()) as TranscriptionResponse;
+  }
+
+  /** POST /v1/translations */
+  async createTranslation(body: TranslationRequest, init?: RequestInit): Promise<TranscriptionResponse> {
+    const res = await this.send("POST", `/v1/translations`, toFormData(body), undefined, init);
+    return (await res.json()) as TranscriptionResponse;
+  }
+
+  private async send(
+    method: string,
+    path: string,
+    body: BodyInit | undefined,
+    contentType: string | undefined,
+    init?: RequestInit,
+  ): Promise<Response> {
+    const headers = new Headers(this.options.headers);
+    new Headers(init?.headers).forEach((value, key) => headers.set(key, value));
+    if (this.options.token) {
+      headers.set("Authorization", `Bearer ${this.options.token}`);
+    }
+    if (contentType) {
+      headers.set("Content-Type", contentType);
+    }
+
+    const res = await this.fetchImpl(this.baseUrl + path, { ...init, method, headers, body });
+    if (!res.ok) {
+      let payload: ErrorResponse | undefined;
+      try {
+        payload = (await res.json()) as ErrorResponse;
+      } catch {
+        payload = undefined;
+      }
+      throw new EchoFlowError(res.status, payload?.error, payload?.request_id);
+    }
+    return res;
+  }
+}
+
+function hasBlob(fields: object): boolean {
+  return Object.values(fields).some((value) =>
+    (Array.isArray(value) ? value : [value]).some((item) => item instanceof Blob),
+  );
+}
+
+function toFormData(fields: object): FormData {
+  const form = new FormData();
+  for (const [key, value] of Object.entries(fields)) {
+    if (value === undefined || value === null) {
+      continue;
+    }
+    for (const item of Array.isArray(value) ? value : [value]) {
+      if (item instanceof Blob) {
+        const name = typeof File !== "undefined" && item instanceof File ? item.name : "audio";
+        form.append(key, item, name);
+      } else {
+        form.append(key, String(item));
+      }
+    }
+  }
+  return form;
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"echoflow/internal/config"
+)
+
+// runConfigSchema implements `echoflow-api config-schema [-format json|markdown]`:
+// it prints every config option with its type, default and description and
+// returns the exit code. It runs before the config is loaded, so it works
+// without any environment set.
+func runConfigSchema(args []string) int {
+	fs := flag.NewFlagSet("config-schema", flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: json (JSON Schema) or markdown (table)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var err error
+	switch *format {
+	case "json":
+		err = config.WriteJSONSchema(os.Stdout)
+	case "markdown", "md":
+		err = config.WriteMarkdown(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "config-schema: unknown format %q (want json or markdown)\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config-schema: %v\n", err)
+		return 1
+	}
+	return 0
+}
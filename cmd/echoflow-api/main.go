@@ -1,34 +1,79 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
+	"echoflow/internal/acronyms"
+	"echoflow/internal/audio"
+	"echoflow/internal/auth"
 	"echoflow/internal/config"
+	"echoflow/internal/fetch"
+	"echoflow/internal/grpcapi"
 	"echoflow/internal/httpapi"
+	"echoflow/internal/jobs"
+	"echoflow/internal/jobs/redisstore"
+	"echoflow/internal/legacy"
+	"echoflow/internal/memguard"
 	"echoflow/internal/observability"
 	"echoflow/internal/pipeline"
 	"echoflow/internal/postprocess"
+	"echoflow/internal/resultcache"
+	"echoflow/internal/routing"
 	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
+	"echoflow/internal/upstream/anthropic"
+	"echoflow/internal/upstream/assemblyai"
+	"echoflow/internal/upstream/chaos"
+	"echoflow/internal/upstream/deepgram"
+	"echoflow/internal/upstream/failover"
+	"echoflow/internal/upstream/healthprobe"
+	"echoflow/internal/upstream/keepwarm"
+	"echoflow/internal/upstream/limit"
 	"echoflow/internal/upstream/openai"
+	"echoflow/internal/upstream/regional"
+	"echoflow/internal/upstream/whisper"
+	"echoflow/internal/webhook"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config-schema" {
+		os.Exit(runConfigSchema(os.Args[2:]))
+	}
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
 		os.Exit(1)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg))
+	}
 
-	logger := newLogger(cfg.LogLevel)
+	logRing := observability.NewLogRing(newLogHandler(cfg.LogLevel), 200)
+	logger := slog.New(logRing)
+	memCfg := memguard.Config{
+		Limit:      cfg.MemoryLimitBytes,
+		LimitRatio: cfg.MemoryLimitRatio,
+		GCPercent:  cfg.GCPercent,
+		Threshold:  cfg.MemoryWatchdogThreshold,
+		Interval:   cfg.MemoryWatchdogInterval,
+		Cooldown:   time.Minute,
+		ProfileDir: cfg.MemoryProfileDir,
+	}
+	memLimit := memguard.Apply(memCfg, logger)
 	metrics := observability.NewMetrics()
 
 	transport := &http.Transport{
@@ -41,32 +86,356 @@ func main() {
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
-	upstreamHTTPClient := &http.Client{Timeout: cfg.RequestTimeout, Transport: transport}
-	upstreamClient := openai.New(cfg.UpstreamBaseURL, cfg.UpstreamAPIKey, upstreamHTTPClient, openai.WithObserver(metrics.ObserveUpstream))
+	transport.TLSClientConfig, err = upstream.TLSConfig(cfg.UpstreamTLSCertFile, cfg.UpstreamTLSKeyFile, cfg.UpstreamTLSCAFile)
+	if err != nil {
+		logger.Error("upstream TLS setup failed", "error", err)
+		os.Exit(1)
+	}
+	var upstreamTransport http.RoundTripper = transport
+	if cfg.ChaosEnabled {
+		upstreamTransport = chaos.NewTransport(transport, chaos.Config{
+			Latency:           cfg.ChaosLatency,
+			LatencyRate:       cfg.ChaosLatencyRate,
+			RateLimitRate:     cfg.ChaosRateLimitRate,
+			TruncateRate:      cfg.ChaosTruncateRate,
+			MalformedJSONRate: cfg.ChaosMalformedJSONRate,
+		})
+		logger.Warn("chaos fault injection enabled for upstream calls",
+			"env", cfg.Environment,
+			"latency_rate", cfg.ChaosLatencyRate,
+			"rate_limit_rate", cfg.ChaosRateLimitRate,
+			"truncate_rate", cfg.ChaosTruncateRate,
+			"malformed_json_rate", cfg.ChaosMalformedJSONRate,
+		)
+	}
+	// Long uploads may legitimately outlive REQUEST_TIMEOUT_SECONDS, so the client
+	// backstop must never be tighter than the largest transcription budget.
+	upstreamHTTPClient := &http.Client{Timeout: max(cfg.RequestTimeout, cfg.TranscriptionMaxTimeout, cfg.MaxRequestTimeout), Transport: upstreamTransport}
+	upstreamOpts := []openai.Option{
+		openai.WithObserver(metrics.ObserveUpstream),
+		openai.WithRetries(openai.RetryPolicy{
+			MaxAttempts: cfg.UpstreamRetryMaxAttempts,
+			BaseDelay:   cfg.UpstreamRetryBaseDelay,
+			MaxDelay:    cfg.UpstreamRetryMaxDelay,
+		}, metrics.ObserveUpstreamRetry),
+	}
+	if cfg.PostProcessLogitBias {
+		upstreamOpts = append(upstreamOpts, openai.WithTokenizeURL(cfg.PostProcessTokenizeURL, cfg.PostProcessTokenizeAPIKey))
+	}
+	var upstreamSelector *regional.Selector
+	var sharedUpstreamOpts []openai.Option
+	if baseURLs := cfg.UpstreamBaseURLs(); len(baseURLs) > 1 {
+		upstreamSelector = regional.NewSelector(baseURLs, &http.Client{Transport: upstreamTransport}, regional.Config{
+			Interval: cfg.UpstreamProbeInterval,
+		}, regional.WithObserver(metrics.ObserveUpstreamProbe), regional.WithLogger(logger))
+		sharedUpstreamOpts = append(sharedUpstreamOpts, openai.WithBaseURLSelector(upstreamSelector))
+	}
+	provider, postProcessClient, err := newUpstreamProvider(cfg, upstreamHTTPClient, upstreamOpts, sharedUpstreamOpts)
+	if err != nil {
+		logger.Error("upstream provider setup failed", "error", err)
+		os.Exit(1)
+	}
+	// Tokenization is specific to the primary post-processing upstream.
+	var tokenizer postprocess.Tokenizer = postProcessClient
+	provider, err = withUpstreamFailover(cfg, provider, upstreamHTTPClient, upstreamOpts,
+		failover.WithObserver(metrics.ObserveUpstreamFailover),
+		failover.WithAttemptTimeout(cfg.UpstreamFailoverTimeout),
+	)
+	if err != nil {
+		logger.Error("upstream failover setup failed", "error", err)
+		os.Exit(1)
+	}
+	// The limits count calls per replica across every failover upstream.
+	provider.Transcriber = limit.Transcriber(provider.Transcriber,
+		limit.New("audio_transcriptions", cfg.MaxConcurrentTranscriptions, metrics.ObserveUpstreamQueueWait))
+	provider.ChatCompleter = limit.ChatCompleter(provider.ChatCompleter,
+		limit.New("chat_completions", cfg.MaxConcurrentCompletions, metrics.ObserveUpstreamQueueWait))
+	keys, _ := provider.HealthChecker.(httpapi.KeyRotator)
+
+	timeouts := transcription.TimeoutPolicy{
+		Base:  cfg.TranscriptionTimeout,
+		PerMB: cfg.TranscriptionTimeoutPerMB,
+		Max:   cfg.TranscriptionMaxTimeout,
+	}
+	var localWhisper *whisper.Client
+	if len(cfg.LocalWhisperServers) > 0 {
+		servers := make([]whisper.Server, len(cfg.LocalWhisperServers))
+		for i, s := range cfg.LocalWhisperServers {
+			servers[i] = whisper.Server{Model: s.Model, URL: s.URL}
+		}
+		localWhisper, err = whisper.New(cfg.LocalWhisperAPI, servers, upstreamHTTPClient,
+			whisper.WithObserver(metrics.ObserveUpstream), whisper.WithAPIKey(cfg.LocalWhisperAPIKey))
+		if err != nil {
+			logger.Error("local whisper setup failed", "error", err)
+			os.Exit(1)
+		}
+	}
+	primaryTranscriber := provider.Transcriber
+	if cfg.LocalWhisperPrimary {
+		primaryTranscriber = localWhisper
+	}
+	if cfg.TranscriptionHedgeDelay > 0 {
+		primaryTranscriber = transcription.NewDelayedHedge(primaryTranscriber, cfg.TranscriptionHedgeDelay, cfg.TranscriptionHedgeMaxBytes, metrics.ObserveHedge)
+	}
+	transcriptionLanguage := transcription.WithDefaultLanguage(cfg.TranscriptionLanguage)
+	var transcoder transcription.Transcoder
+	if cfg.FFmpegPath != "" {
+		if transcoder, err = audio.NewTranscoder(cfg.FFmpegPath, cfg.MaxAudioDuration); err != nil {
+			logger.Error("transcoding setup failed", "error", err)
+			os.Exit(1)
+		}
+	}
+	transcoding := transcription.WithTranscoder(transcoder)
+	var transcriptionService pipeline.Transcriber = transcription.New(primaryTranscriber, cfg.TranscriptionModel, timeouts, transcriptionLanguage, transcoding)
+	providers := map[string]transcription.Transcriber{}
+	// Chat providers share the routing names of the transcription providers
+	// that can also do chat.
+	chatProviders := map[string]upstream.ChatCompleter{"primary": provider.ChatCompleter}
+	if cfg.AnthropicAPIKey != "" {
+		anthropicClient := anthropic.New(cfg.AnthropicBaseURL, cfg.AnthropicAPIKey, upstreamHTTPClient,
+			anthropic.WithObserver(metrics.ObserveUpstream), anthropic.WithMaxTokens(cfg.AnthropicMaxTokens))
+		// The Anthropic key is the operator's, so BYOT requests for a Claude
+		// model are refused rather than billed to it.
+		chatProviders["primary"] = routing.NewModelChatCompleter(cfg.AnthropicModels, upstream.ServerKeyChatCompleter(anthropicClient, nil), provider.ChatCompleter)
+	}
+	if cfg.HedgeBaseURL != "" {
+		hedgeClient := openai.New(cfg.HedgeBaseURL, cfg.HedgeAPIKey, upstreamHTTPClient,
+			openai.WithObserver(metrics.ObserveUpstream), openai.WithoutRequestAPIKey())
+		// HEDGE_API_KEY is the operator's, so routing a BYOT request to the
+		// hedge provider is refused rather than billed to it.
+		secondary := transcription.New(upstream.ServerKeyTranscriber(hedgeClient, nil), cfg.HedgeTranscriptionModel, timeouts, transcriptionLanguage, transcoding)
+		providers[cfg.HedgeProviderName] = secondary
+		chatProviders[cfg.HedgeProviderName] = upstream.ServerKeyChatCompleter(hedgeClient, nil)
+		transcriptionService = transcription.NewHedged(
+			transcription.Provider{Name: "primary", Transcriber: transcriptionService},
+			transcription.Provider{Name: cfg.HedgeProviderName, Transcriber: secondary},
+			metrics.ObserveHedge,
+		)
+	}
+	providers["primary"] = transcriptionService
+	if cfg.DeepgramAPIKey != "" {
+		deepgramClient := deepgram.New(cfg.DeepgramBaseURL, cfg.DeepgramAPIKey, upstreamHTTPClient,
+			deepgram.WithObserver(metrics.ObserveUpstream))
+		// DEEPGRAM_API_KEY is the operator's, so a BYOT request routed to
+		// Deepgram is refused rather than billed to it.
+		providers["deepgram"] = transcription.New(upstream.ServerKeyTranscriber(deepgramClient, nil), cfg.DeepgramModel, timeouts, transcriptionLanguage, transcoding)
+	}
+	if localWhisper != nil {
+		providers["local"] = transcription.New(localWhisper, localWhisper.DefaultModel(), timeouts, transcriptionLanguage, transcoding)
+	}
+	if cfg.AssemblyAIAPIKey != "" {
+		assemblyClient := assemblyai.New(cfg.AssemblyAIBaseURL, cfg.AssemblyAIAPIKey, upstreamHTTPClient,
+			assemblyai.WithObserver(metrics.ObserveUpstream))
+		// Likewise ASSEMBLYAI_API_KEY only serves requests on the server's key.
+		providers["assemblyai"] = transcription.New(upstream.ServerKeyTranscriber(assemblyClient, nil), cfg.AssemblyAIModel, timeouts, transcriptionLanguage, transcoding)
+	}
+	var routingRules *routing.Engine
+	if cfg.RoutingRulesPath != "" {
+		var err error
+		routingRules, err = routing.NewEngine(cfg.RoutingRulesPath, slices.Collect(maps.Keys(providers)), logger)
+		if err != nil {
+			logger.Error("routing rules load failed", "path", cfg.RoutingRulesPath, "error", err)
+			os.Exit(1)
+		}
+		transcriptionService = routing.NewTranscriber(routingRules, providers, "primary", metrics.ObserveRoutingDecision)
+	}
+	if cfg.TranscriptionChunkMaxBytes > 0 || cfg.TranscriptionChunkLength > 0 {
+		transcriptionService = transcription.NewChunked(transcriptionService, transcription.ChunkPolicy{
+			MaxBytes:    cfg.TranscriptionChunkMaxBytes,
+			MaxDuration: cfg.TranscriptionChunkLength,
+			Overlap:     cfg.TranscriptionChunkOverlap,
+			Parallelism: cfg.TranscriptionChunkWorkers,
+		}, transcoder)
+	}
+	if cfg.TranscriptionDedupe {
+		transcriptionService = transcription.NewDeduplicated(transcriptionService, metrics.ObserveTranscriptionDedupe)
+	}
+	var chatCompleter postprocess.ChatClient = chatProviders["primary"]
+	if routingRules != nil {
+		chatCompleter = routing.NewChatCompleter(routingRules, chatProviders, "primary", metrics.ObserveRoutingDecision)
+	}
+	postProcessOpts := []postprocess.Option{
+		postprocess.WithStopSequences(cfg.PostProcessStopSequences),
+		postprocess.WithContextSummarizer(cfg.PostProcessSummaryModel, cfg.PostProcessContextMaxTokens),
+	}
+	if cfg.PostProcessSmallModel != "" {
+		postProcessOpts = append(postProcessOpts, postprocess.WithEscalation(cfg.PostProcessSmallModel, cfg.PostProcessMaxDrift))
+	}
+	if cfg.PostProcessOutputTag != "" {
+		postProcessOpts = append(postProcessOpts, postprocess.WithOutputTag(cfg.PostProcessOutputTag))
+	}
+	if cfg.PostProcessLogitBias {
+		postProcessOpts = append(postProcessOpts, postprocess.WithLogitBias(tokenizer, cfg.PostProcessLogitBiasValue))
+	}
+	postProcessService := postprocess.New(chatCompleter, cfg.PostProcessModel, cfg.PostProcessTimeout, postProcessOpts...)
+	analyzers := map[string]pipeline.Analyzer{
+		"summary": postprocess.NewSummarizer(chatCompleter, cmp.Or(cfg.PipelineSummaryModel, cfg.PostProcessModel), cfg.PostProcessTimeout),
+	}
+	var pipelineService httpapi.PipelineService = pipeline.New(transcriptionService, postProcessService, cfg.TranscriptionModel,
+		pipeline.WithAnalyzers(analyzers, cfg.PipelineStageConcurrency),
+		pipeline.WithFallbackPolicy(cfg.PipelineFallbackPolicy),
+		pipeline.WithVocabularyPrompt(cfg.PipelineVocabularyPrompt))
+	switch cfg.ResultCache {
+	case "memory":
+		pipelineService = resultcache.New(pipelineService, resultcache.NewMemory(cfg.ResultCacheMaxEntries), cfg.ResultCacheTTL, metrics.ObserveResultCache)
+	case "redis":
+		connectCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		cacheBackend, err := resultcache.OpenRedis(connectCtx, cfg.RedisURL)
+		cancel()
+		if err != nil {
+			logger.Error("result cache redis connect failed", "error", err)
+			os.Exit(1)
+		}
+		defer func() { _ = cacheBackend.Close() }()
+		pipelineService = resultcache.New(pipelineService, cacheBackend, cfg.ResultCacheTTL, metrics.ObserveResultCache)
+	}
 
-	transcriptionService := transcription.New(upstreamClient, cfg.TranscriptionModel, cfg.TranscriptionTimeout)
-	postProcessService := postprocess.New(upstreamClient, cfg.PostProcessModel, cfg.PostProcessTimeout)
-	pipelineService := pipeline.New(transcriptionService, postProcessService, cfg.TranscriptionModel, cfg.PostProcessModel)
+	// ctx ends on SIGINT or SIGTERM. Job workers stop taking jobs then and
+	// are waited for after the HTTP drain.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
+	jobRunner := httpapi.NewJobRunner(pipelineService, metrics)
+	jobOpts := []jobs.Option{
+		jobs.WithContext(ctx),
+		jobs.WithLogger(logger),
+		jobs.WithWorkers(cfg.JobWorkers, cfg.JobQueueSize),
+		jobs.WithPoolObserver(metrics),
+		jobs.WithResultTTL(cfg.JobResultTTL),
+	}
+	if cfg.JobJournalDir != "" {
+		journal, err := jobs.NewDirJournal(cfg.JobJournalDir)
+		if err != nil {
+			logger.Error("job journal open failed", "dir", cfg.JobJournalDir, "error", err)
+			os.Exit(1)
+		}
+		jobOpts = append(jobOpts, jobs.WithJournal(journal, jobRunner, cfg.JobJournalMaxAttempts))
+	}
+	switch cfg.JobStore {
+	case "sqlite":
+		store, closeStore, err := openSQLiteStore(cfg)
+		if err != nil {
+			logger.Error("job store open failed", "path", cfg.JobSQLitePath, "error", err)
+			os.Exit(1)
+		}
+		defer func() { _ = closeStore() }()
+		jobOpts = append(jobOpts, jobs.WithStore(store))
+	case "redis":
+		connectCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		rdb, err := redisstore.Open(connectCtx, cfg.RedisURL, redisstore.WithRecordTTL(cfg.JobResultTTL))
+		cancel()
+		if err != nil {
+			logger.Error("redis connect failed", "error", err)
+			os.Exit(1)
+		}
+		defer func() { _ = rdb.Close() }()
+		jobOpts = append(jobOpts, jobs.WithStore(rdb), jobs.WithQueue(rdb))
+	}
+	if cfg.WebhookSecret != "" {
+		sender := webhook.New(cfg.WebhookSecret,
+			webhook.WithTimeout(cfg.WebhookTimeout),
+			webhook.WithPrivateNetworks(cfg.WebhookAllowPrivate),
+			webhook.WithMaxAttempts(cfg.WebhookMaxAttempts))
+		jobOpts = append(jobOpts, jobs.WithNotifier(httpapi.NewJobNotifier(sender, logger)))
+	}
+	jobManager := jobs.NewManager(jobOpts...)
+
+	var tokens *auth.Registry
+	if cfg.AuthTokensPath != "" {
+		var err error
+		tokens, err = auth.LoadRegistry(cfg.AuthTokensPath)
+		if err != nil {
+			logger.Error("auth tokens load failed", "path", cfg.AuthTokensPath, "error", err)
+			os.Exit(1)
+		}
+	}
+	var legacyClients *legacy.Table
+	if cfg.LegacyClientsPath != "" {
+		var err error
+		legacyClients, err = legacy.Load(cfg.LegacyClientsPath)
+		if err != nil {
+			logger.Error("legacy clients load failed", "path", cfg.LegacyClientsPath, "error", err)
+			os.Exit(1)
+		}
+	}
+	quotas := auth.NewQuotas()
+	acronymStore, err := acronyms.NewStore(cfg.AcronymsPath)
+	if err != nil {
+		logger.Error("acronym dictionary load failed", "path", cfg.AcronymsPath, "error", err)
+		os.Exit(1)
+	}
+
+	fetchOpts := []fetch.Option{
+		fetch.WithMaxBytes(cfg.MaxUploadBytes),
+		fetch.WithTimeout(cfg.AudioFetchTimeout),
+		fetch.WithPrivateNetworks(cfg.AudioFetchAllowPrivate),
+	}
+	if cfg.S3AccessKeyID != "" {
+		fetchOpts = append(fetchOpts, fetch.S3(fetch.ObjectStore{
+			Endpoint:     cfg.S3Endpoint,
+			Region:       cfg.S3Region,
+			AccessKey:    cfg.S3AccessKeyID,
+			SecretKey:    cfg.S3SecretAccessKey,
+			SessionToken: cfg.S3SessionToken,
+			Buckets:      cfg.S3Buckets,
+		}))
+	}
+	if cfg.GCSHMACAccessID != "" {
+		fetchOpts = append(fetchOpts, fetch.GCS(cfg.GCSHMACAccessID, cfg.GCSHMACSecret, cfg.GCSBuckets))
+	}
+	audioFetcher := fetch.New(fetchOpts...)
+	drain := httpapi.NewDrain()
+	var healthProber *healthprobe.Prober
+	var upstreamStatus httpapi.UpstreamStatus
+	if cfg.HealthProbeInterval > 0 {
+		healthProber = healthprobe.New(provider.Name, provider.HealthChecker, healthprobe.Config{
+			Interval:  cfg.HealthProbeInterval,
+			UseKey:    cfg.UpstreamAPIKey != "",
+			ChatModel: cfg.HealthProbeModel,
+		}, healthprobe.WithChat(provider.ChatCompleter), healthprobe.WithObserver(metrics.ObserveHealthProbe), healthprobe.WithLogger(logger))
+		upstreamStatus = healthProber
+	}
 	handler := httpapi.NewServer(cfg, logger, httpapi.Dependencies{
 		Transcription:  transcriptionService,
 		PostProcess:    postProcessService,
+		Chapters:       postprocess.NewChapterer(chatCompleter, cmp.Or(cfg.ChaptersModel, cfg.PostProcessModel), cfg.PostProcessTimeout),
 		Pipeline:       pipelineService,
-		Upstream:       upstreamClient,
+		Jobs:           jobManager,
+		Upstream:       provider.HealthChecker,
+		Fetcher:        audioFetcher,
+		Keys:           keys,
+		Tokens:         tokens,
+		Quotas:         quotas,
+		Acronyms:       acronymStore,
+		Legacy:         legacyClients,
 		Metrics:        metrics,
 		MetricsHandler: metrics.Handler(),
+		MetricsText:    metrics,
+		Logs:           logRing,
+		Drain:          drain,
+		UpstreamStatus: upstreamStatus,
 	})
 
+	// Handlers push the write deadline forward once the body has been read,
+	// so this only bounds requests that never reach that point.
+	writeTimeout := cfg.UploadReadTimeout + cfg.TranscriptionMaxTimeout + cfg.PostProcessTimeout
+
+	// Requests still running when the drain period ends are cancelled through
+	// their base context, so they answer with an error instead of a dropped
+	// connection.
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	srv := &http.Server{
 		Addr:              cfg.ListenAddr,
 		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
-		ReadTimeout:       35 * time.Second,
-		WriteTimeout:      40 * time.Second,
+		ReadTimeout:       cfg.UploadReadTimeout,
+		WriteTimeout:      writeTimeout,
 		IdleTimeout:       60 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return requestsCtx },
 	}
 
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 2)
 	go func() {
 		logger.Info("server starting", "addr", cfg.ListenAddr)
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -75,8 +444,58 @@ func main() {
 		close(errCh)
 	}()
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	var grpcSrv *grpc.Server
+	if cfg.GRPCListenAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCListenAddr)
+		if err != nil {
+			logger.Error("grpc listen failed", "addr", cfg.GRPCListenAddr, "error", err)
+			os.Exit(1)
+		}
+		grpcSrv = grpcapi.NewServer(cfg, logger, grpcapi.Dependencies{
+			Transcription: transcriptionService,
+			PostProcess:   postProcessService,
+			Pipeline:      pipelineService,
+			Tokens:        tokens,
+			Quotas:        quotas,
+			Acronyms:      acronymStore,
+			Metrics:       metrics,
+		})
+		go func() {
+			logger.Info("grpc server starting", "addr", cfg.GRPCListenAddr)
+			if err := grpcSrv.Serve(lis); err != nil {
+				errCh <- err
+			}
+		}()
+	}
+
+	go memguard.NewWatchdog(memLimit, memCfg, logger).Run(ctx)
+	if cfg.SignalDumps {
+		go memguard.NewDumper(cfg.SignalDumpDir, cfg.MemoryProfileDir, logger).Run(ctx)
+	}
+	go jobManager.Recover(ctx)
+	go jobManager.Work(ctx, jobRunner)
+	go jobManager.RunOutbox(ctx)
+	go jobManager.RunSweeper(ctx, func(cutoff time.Time) {
+		if n, err := httpapi.RemoveStaleSpoolFiles(cutoff); err != nil || n > 0 {
+			logger.Info("stale job audio removed", "files", n, "error", err)
+		}
+	})
+	if upstreamSelector != nil {
+		go upstreamSelector.Run(ctx)
+	}
+	if routingRules != nil {
+		go routingRules.Watch(ctx, cfg.RoutingRulesReloadInterval)
+	}
+	if healthProber != nil {
+		go healthProber.Run(ctx)
+	}
+	if pinger, ok := provider.HealthChecker.(upstream.Pinger); ok {
+		go keepwarm.New(pinger, provider.ChatCompleter, keepwarm.Config{
+			Interval:       cfg.KeepWarmInterval,
+			WarmupModel:    cfg.KeepWarmModel,
+			WarmupInterval: cfg.KeepWarmModelInterval,
+		}, logger).Run(ctx)
+	}
 
 	select {
 	case <-ctx.Done():
@@ -89,16 +508,44 @@ func main() {
 		return
 	}
 
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownDrainTimeout)
+	defer cancelDrain()
+	grpcStopped := make(chan struct{})
+	if grpcSrv != nil {
+		go func() {
+			grpcSrv.GracefulStop()
+			close(grpcStopped)
+		}()
+	}
+	logger.Info("draining in-flight requests", "timeout", cfg.ShutdownDrainTimeout)
+	drained, aborted := drain.Wait(drainCtx)
+	logger.Info("drain finished", "drained", drained, "aborted", aborted)
+	if grpcSrv != nil {
+		select {
+		case <-grpcStopped:
+		case <-drainCtx.Done():
+			grpcSrv.Stop()
+		}
+	}
+	cancelRequests()
+
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("graceful shutdown failed", "error", err)
 		os.Exit(1)
 	}
+
+	jobsCtx, cancelJobs := context.WithTimeout(context.Background(), cfg.ShutdownDrainTimeout)
+	defer cancelJobs()
+	logger.Info("waiting for running jobs", "timeout", cfg.ShutdownDrainTimeout)
+	if err := jobManager.Wait(jobsCtx); err != nil {
+		logger.Warn("jobs still running at exit", "error", err)
+	}
 	logger.Info("server stopped")
 }
 
-func newLogger(level string) *slog.Logger {
+func newLogHandler(level string) slog.Handler {
 	var slogLevel slog.Level
 	switch level {
 	case "debug":
@@ -110,5 +557,5 @@ func newLogger(level string) *slog.Logger {
 	default:
 		slogLevel = slog.LevelInfo
 	}
-	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slogLevel}))
+	return slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slogLevel})
 }
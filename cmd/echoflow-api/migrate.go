@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"echoflow/internal/config"
+)
+
+// runMigrate implements `echoflow-api migrate`: it applies the job store's
+// pending schema migrations and returns the exit code. Deployments that set
+// JOB_SQLITE_AUTO_MIGRATE=false run it before rolling out a new version.
+func runMigrate(cfg config.Config) int {
+	if cfg.JobStore != "sqlite" {
+		fmt.Printf("JOB_STORE=%s has no schema to migrate\n", cfg.JobStore)
+		return 0
+	}
+	applied, err := migrateSQLite(context.Background(), cfg.JobSQLitePath)
+	for _, name := range applied {
+		fmt.Printf("applied %s\n", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s: %v\n", cfg.JobSQLitePath, err)
+		return 1
+	}
+	if len(applied) == 0 {
+		fmt.Printf("%s is up to date\n", cfg.JobSQLitePath)
+	}
+	return 0
+}
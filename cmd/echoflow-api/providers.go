@@ -0,0 +1,94 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"echoflow/internal/config"
+	"echoflow/internal/upstream"
+	"echoflow/internal/upstream/failover"
+	"echoflow/internal/upstream/openai"
+)
+
+// newUpstreamProvider builds the vendor selected by UPSTREAM_PROVIDER. Vendor
+// specific extras (key rotation, keep-warm pings) are found by type assertion
+// on the returned capabilities. sharedOpts only apply to clients on
+// UPSTREAM_BASE_URL; a step moved to another base URL or key gets its own
+// client that never forwards callers' keys. That client holds the operator's
+// key, so it only serves requests the server pays for; BYOT requests stay on
+// the shared client with their own key. The post-processing client is also
+// returned unwrapped, for its tokenizer.
+func newUpstreamProvider(cfg config.Config, httpClient *http.Client, openaiOpts, sharedOpts []openai.Option) (upstream.Provider, *openai.Client, error) {
+	vendorOpts, err := upstreamVendorOptions(cfg)
+	if err != nil {
+		return upstream.Provider{}, nil, err
+	}
+	newClient := func(baseURL, apiKey string) *openai.Client {
+		opts := slices.Concat(openaiOpts, vendorOpts)
+		if baseURL == cfg.UpstreamBaseURL {
+			opts = append(opts, sharedOpts...)
+		} else {
+			opts = append(opts, openai.WithoutRequestAPIKey())
+		}
+		return openai.New(baseURL, apiKey, httpClient, opts...)
+	}
+
+	client := newClient(cfg.UpstreamBaseURL, cfg.UpstreamAPIKey)
+	provider := upstream.Provider{Name: cfg.UpstreamProvider, Transcriber: client, ChatCompleter: client, HealthChecker: client}
+	postProcessClient := client
+	if baseURL := cmp.Or(cfg.TranscriptionBaseURL, cfg.UpstreamBaseURL); baseURL != cfg.UpstreamBaseURL || cfg.TranscriptionAPIKey != cfg.UpstreamAPIKey {
+		provider.Transcriber = upstream.ServerKeyTranscriber(newClient(baseURL, cfg.TranscriptionAPIKey), client)
+	}
+	if baseURL := cmp.Or(cfg.PostProcessBaseURL, cfg.UpstreamBaseURL); baseURL != cfg.UpstreamBaseURL || cfg.PostProcessAPIKey != cfg.UpstreamAPIKey {
+		postProcessClient = newClient(baseURL, cfg.PostProcessAPIKey)
+		provider.ChatCompleter = upstream.ServerKeyChatCompleter(postProcessClient, client)
+	}
+	return provider, postProcessClient, nil
+}
+
+func upstreamVendorOptions(cfg config.Config) ([]openai.Option, error) {
+	switch cfg.UpstreamProvider {
+	case "openai":
+		return nil, nil
+	case "azure":
+		return []openai.Option{openai.WithAzure(cfg.AzureOpenAIAPIVersion)}, nil
+	default:
+		return nil, fmt.Errorf("unknown UPSTREAM_PROVIDER %q", cfg.UpstreamProvider)
+	}
+}
+
+// withUpstreamFailover puts the UPSTREAM_FAILOVER_BASE_URLS entries behind
+// both steps of provider, in order. Failover upstreams always use their own
+// keys, so they only serve requests the server pays for, and speak the same
+// API as UPSTREAM_PROVIDER.
+func withUpstreamFailover(cfg config.Config, provider upstream.Provider, httpClient *http.Client, openaiOpts []openai.Option, opts ...failover.Option) (upstream.Provider, error) {
+	if len(cfg.UpstreamFailoverBaseURLs) == 0 {
+		return provider, nil
+	}
+	vendorOpts, err := upstreamVendorOptions(cfg)
+	if err != nil {
+		return upstream.Provider{}, err
+	}
+	transcribers := []failover.Upstream{{Name: upstreamName(cmp.Or(cfg.TranscriptionBaseURL, cfg.UpstreamBaseURL)), Transcriber: provider.Transcriber}}
+	completers := []failover.Upstream{{Name: upstreamName(cmp.Or(cfg.PostProcessBaseURL, cfg.UpstreamBaseURL)), ChatCompleter: provider.ChatCompleter}}
+	for i, baseURL := range cfg.UpstreamFailoverBaseURLs {
+		client := openai.New(baseURL, cfg.UpstreamFailoverAPIKeys[i], httpClient, slices.Concat(openaiOpts, vendorOpts, []openai.Option{openai.WithoutRequestAPIKey()})...)
+		transcribers = append(transcribers, failover.Upstream{Name: upstreamName(baseURL), Transcriber: client, ServerKey: true})
+		completers = append(completers, failover.Upstream{Name: upstreamName(baseURL), ChatCompleter: client, ServerKey: true})
+	}
+	provider.Transcriber = failover.New(transcribers, opts...)
+	provider.ChatCompleter = failover.New(completers, opts...)
+	return provider, nil
+}
+
+// upstreamName labels an upstream by host so metrics never carry credentials
+// or paths from its base URL.
+func upstreamName(baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return baseURL
+}
@@ -0,0 +1,33 @@
+//go:build sqlite
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"echoflow/internal/config"
+	"echoflow/internal/jobs"
+	"echoflow/internal/jobs/sqlitestore"
+)
+
+// openSQLiteStore opens the JOB_STORE=sqlite database and returns the store
+// with its Close.
+func openSQLiteStore(cfg config.Config) (jobs.Store, func() error, error) {
+	store, err := sqlitestore.Open(cfg.JobSQLitePath, sqlitestore.WithAutoMigrate(cfg.JobSQLiteAutoMigrate))
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, store.Close, nil
+}
+
+// migrateSQLite applies pending migrations to the database at path and names
+// the ones it applied, even when a later one failed.
+func migrateSQLite(ctx context.Context, path string) ([]string, error) {
+	applied, err := sqlitestore.Migrate(ctx, path)
+	names := make([]string, len(applied))
+	for i, m := range applied {
+		names[i] = fmt.Sprintf("%04d_%s", m.Version, m.Name)
+	}
+	return names, err
+}
@@ -0,0 +1,23 @@
+//go:build !sqlite
+
+package main
+
+import (
+	"context"
+	"errors"
+
+	"echoflow/internal/config"
+	"echoflow/internal/jobs"
+)
+
+// The SQLite driver needs cgo, so the job store is only compiled in with the
+// sqlite build tag; default builds stay static.
+var errNoSQLite = errors.New("JOB_STORE=sqlite needs a binary built with -tags sqlite and CGO_ENABLED=1")
+
+func openSQLiteStore(config.Config) (jobs.Store, func() error, error) {
+	return nil, nil, errNoSQLite
+}
+
+func migrateSQLite(context.Context, string) ([]string, error) {
+	return nil, errNoSQLite
+}
@@ -0,0 +1,348 @@
+// Command openapi-ts generates the TypeScript client in clients/ts from the
+// OpenAPI spec. It covers the subset of OpenAPI the EchoFlow spec uses.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+type spec struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas   map[string]*schema  `json:"schemas"`
+		Responses map[string]response `json:"responses"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string              `json:"operationId"`
+	Parameters  []parameter         `json:"parameters"`
+	RequestBody *requestBody        `json:"requestBody"`
+	Responses   map[string]response `json:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []string           `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties any                `json:"additionalProperties"`
+}
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+func main() {
+	specPath := flag.String("spec", "api/openapi.json", "OpenAPI spec to read")
+	outPath := flag.String("out", "clients/ts/src/client.ts", "TypeScript file to write")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := generate(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*outPath, out, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(data []byte) ([]byte, error) {
+	var doc spec
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by cmd/openapi-ts from api/openapi.json. DO NOT EDIT.\n")
+
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		s := doc.Components.Schemas[name]
+		if s.Type != "object" {
+			fmt.Fprintf(&b, "\nexport type %s = %s;\n", name, tsType(s))
+			continue
+		}
+		fmt.Fprintf(&b, "\nexport interface %s {\n", name)
+		writeProperties(&b, s, "  ")
+		b.WriteString("}\n")
+	}
+
+	b.WriteString(runtimeHeader)
+
+	paths := sortedKeys(doc.Paths)
+	for _, path := range paths {
+		for _, method := range methodOrder {
+			op, ok := doc.Paths[path][method]
+			if !ok {
+				continue
+			}
+			if err := writeMethod(&b, &doc, path, method, op); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	b.WriteString(runtimeFooter)
+	return b.Bytes(), nil
+}
+
+func writeProperties(b *bytes.Buffer, s *schema, indent string) {
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	for _, name := range sortedKeys(s.Properties) {
+		optional := "?"
+		if required[name] {
+			optional = ""
+		}
+		fmt.Fprintf(b, "%s%s%s: %s;\n", indent, name, optional, tsType(s.Properties[name]))
+	}
+}
+
+func tsType(s *schema) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref != "" {
+		return refName(s.Ref)
+	}
+	if len(s.Enum) > 0 {
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			values[i] = fmt.Sprintf("%q", v)
+		}
+		return strings.Join(values, " | ")
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "binary" {
+			return "Blob"
+		}
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		item := tsType(s.Items)
+		if strings.Contains(item, " | ") {
+			// Without parentheses `"a" | "b"[]` would mean "a" or an array of "b".
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case "object":
+		if len(s.Properties) == 0 {
+			return "Record<string, unknown>"
+		}
+		var b bytes.Buffer
+		b.WriteString("{ ")
+		writeProperties(&b, s, "")
+		return strings.ReplaceAll(strings.TrimSuffix(b.String(), "\n"), ";\n", "; ") + " }"
+	}
+	return "unknown"
+}
+
+func writeMethod(b *bytes.Buffer, doc *spec, path, method string, op operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("%s %s: missing operationId", strings.ToUpper(method), path)
+	}
+
+	var args []string
+	tsPath := path
+	for _, p := range op.Parameters {
+		if p.In != "path" {
+			continue
+		}
+		args = append(args, p.Name+": "+tsType(p.Schema))
+		tsPath = strings.ReplaceAll(tsPath, "{"+p.Name+"}", "${encodeURIComponent("+p.Name+")}")
+	}
+
+	body, contentType := "undefined", "undefined"
+	if op.RequestBody != nil {
+		jsonSchema := op.RequestBody.Content["application/json"].Schema
+		formSchema := op.RequestBody.Content["multipart/form-data"].Schema
+		switch {
+		case jsonSchema != nil && formSchema != nil:
+			// Uploads go as multipart, anything without a Blob as JSON.
+			args = append(args, "body: "+tsType(formSchema)+" | "+tsType(jsonSchema))
+			body = "hasBlob(body) ? toFormData(body) : JSON.stringify(body)"
+			contentType = `hasBlob(body) ? undefined : "application/json"`
+		case jsonSchema != nil:
+			args = append(args, "body: "+tsType(jsonSchema))
+			body, contentType = "JSON.stringify(body)", `"application/json"`
+		case formSchema != nil:
+			args = append(args, "body: "+tsType(formSchema))
+			body = "toFormData(body)"
+		default:
+			return fmt.Errorf("%s: unsupported request body", op.OperationID)
+		}
+	}
+	args = append(args, "init?: RequestInit")
+
+	resultType, decode, err := successResponse(doc, op)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(b, "\n  /** %s %s */\n", strings.ToUpper(method), path)
+	fmt.Fprintf(b, "  async %s(%s): Promise<%s> {\n", op.OperationID, strings.Join(args, ", "), resultType)
+	fmt.Fprintf(b, "    const res = await this.send(%q, `%s`, %s, %s, init);\n", strings.ToUpper(method), tsPath, body, contentType)
+	fmt.Fprintf(b, "    return %s;\n", decode)
+	b.WriteString("  }\n")
+	return nil
+}
+
+func successResponse(doc *spec, op operation) (string, string, error) {
+	for _, code := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		resp := op.Responses[code]
+		if resp.Ref != "" {
+			resp = doc.Components.Responses[refName(resp.Ref)]
+		}
+		switch {
+		case resp.Content["application/json"].Schema != nil:
+			t := tsType(resp.Content["application/json"].Schema)
+			return t, "(await res.json()) as " + t, nil
+		case resp.Content["text/event-stream"].Schema != nil:
+			return "Response", "res", nil
+		case resp.Content["text/plain"].Schema != nil:
+			return "string", "res.text()", nil
+		}
+		return "void", "undefined", nil
+	}
+	return "", "", fmt.Errorf("%s: no success response", op.OperationID)
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+const runtimeHeader = `
+export interface ClientOptions {
+  baseUrl?: string;
+  token?: string;
+  headers?: Record<string, string>;
+  fetch?: typeof fetch;
+}
+
+export class EchoFlowError extends Error {
+  constructor(
+    readonly status: number,
+    readonly error: APIError | undefined,
+    readonly requestId: string | undefined,
+  ) {
+    super(error?.message ?? ` + "`EchoFlow request failed with status ${status}`" + `);
+    this.name = "EchoFlowError";
+  }
+}
+
+export class EchoFlowClient {
+  private readonly baseUrl: string;
+  private readonly fetchImpl: typeof fetch;
+
+  constructor(private readonly options: ClientOptions = {}) {
+    this.baseUrl = (options.baseUrl ?? "http://localhost:8080").replace(/\/+$/, "");
+    this.fetchImpl = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+`
+
+const runtimeFooter = `
+  private async send(
+    method: string,
+    path: string,
+    body: BodyInit | undefined,
+    contentType: string | undefined,
+    init?: RequestInit,
+  ): Promise<Response> {
+    const headers = new Headers(this.options.headers);
+    new Headers(init?.headers).forEach((value, key) => headers.set(key, value));
+    if (this.options.token) {
+      headers.set("Authorization", ` + "`Bearer ${this.options.token}`" + `);
+    }
+    if (contentType) {
+      headers.set("Content-Type", contentType);
+    }
+
+    const res = await this.fetchImpl(this.baseUrl + path, { ...init, method, headers, body });
+    if (!res.ok) {
+      let payload: ErrorResponse | undefined;
+      try {
+        payload = (await res.json()) as ErrorResponse;
+      } catch {
+        payload = undefined;
+      }
+      throw new EchoFlowError(res.status, payload?.error, payload?.request_id);
+    }
+    return res;
+  }
+}
+
+function hasBlob(fields: object): boolean {
+  return Object.values(fields).some((value) =>
+    (Array.isArray(value) ? value : [value]).some((item) => item instanceof Blob),
+  );
+}
+
+function toFormData(fields: object): FormData {
+  const form = new FormData();
+  for (const [key, value] of Object.entries(fields)) {
+    if (value === undefined || value === null) {
+      continue;
+    }
+    for (const item of Array.isArray(value) ? value : [value]) {
+      if (item instanceof Blob) {
+        const name = typeof File !== "undefined" && item instanceof File ? item.name : "audio";
+        form.append(key, item, name);
+      } else {
+        form.append(key, String(item));
+      }
+    }
+  }
+  return form;
+}
+`
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	data, err := os.ReadFile("../../api/openapi.json")
+	if err != nil {
+		t.Fatalf("read spec: %v", err)
+	}
+	want, err := generate(data)
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	got, err := os.ReadFile("../../clients/ts/src/client.ts")
+	if err != nil {
+		t.Fatalf("read client: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("clients/ts/src/client.ts is stale; run `make ts-client`")
+	}
+}
+
+func TestArrayOfUnionIsParenthesized(t *testing.T) {
+	got := tsType(&schema{Type: "array", Items: &schema{Enum: []string{"segment", "word"}}})
+	if got != `("segment" | "word")[]` {
+		t.Fatalf("tsType() = %s", got)
+	}
+}
@@ -0,0 +1,217 @@
+// Package acronyms keeps per-tenant acronym expansions ("EOD" -> "end of
+// day") and applies them to finished transcripts.
+package acronyms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxEntriesPerTenant = 500
+	maxTermLength       = 32
+	maxExpansionLength  = 200
+)
+
+var (
+	ErrInvalidEntry   = errors.New("invalid acronym entry")
+	ErrTooManyEntries = fmt.Errorf("a tenant may define at most %d acronyms", MaxEntriesPerTenant)
+)
+
+// Store holds each tenant's dictionary. With a path, every change is written
+// to that JSON file and it is read back at startup; otherwise dictionaries
+// live only in memory.
+type Store struct {
+	path string
+
+	mu       sync.RWMutex
+	byTenant map[string]map[string]string
+}
+
+// NewStore loads path when it exists. An empty path keeps the store in memory.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, byTenant: map[string]map[string]string{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.byTenant); err != nil {
+		return nil, fmt.Errorf("parse acronym dictionary %s: %w", path, err)
+	}
+	if s.byTenant == nil {
+		s.byTenant = map[string]map[string]string{}
+	}
+	return s, nil
+}
+
+// Dictionary returns a copy of tenant's entries, keyed by acronym.
+func (s *Store) Dictionary(tenant string) map[string]string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.byTenant[tenant])
+}
+
+// Set adds or replaces one entry.
+func (s *Store) Set(tenant, term, expansion string) error {
+	term, expansion = strings.TrimSpace(term), strings.TrimSpace(expansion)
+	if err := validate(term, expansion); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dict := s.byTenant[tenant]
+	if _, exists := dict[term]; !exists && len(dict) >= MaxEntriesPerTenant {
+		return ErrTooManyEntries
+	}
+	next := maps.Clone(dict)
+	if next == nil {
+		next = map[string]string{}
+	}
+	next[term] = expansion
+	return s.commit(tenant, next)
+}
+
+// Delete removes term and reports whether it existed.
+func (s *Store) Delete(tenant, term string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byTenant[tenant][term]; !ok {
+		return false, nil
+	}
+	next := maps.Clone(s.byTenant[tenant])
+	delete(next, term)
+	return true, s.commit(tenant, next)
+}
+
+// commit swaps in tenant's new dictionary once it is on disk. Callers hold mu.
+func (s *Store) commit(tenant string, dict map[string]string) error {
+	previous, had := s.byTenant[tenant]
+	if len(dict) == 0 {
+		delete(s.byTenant, tenant)
+	} else {
+		s.byTenant[tenant] = dict
+	}
+	if err := s.save(); err != nil {
+		if had {
+			s.byTenant[tenant] = previous
+		} else {
+			delete(s.byTenant, tenant)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.byTenant, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".acronyms-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func validate(term, expansion string) error {
+	switch {
+	case term == "" || expansion == "":
+		return fmt.Errorf("%w: acronym and expansion are required", ErrInvalidEntry)
+	case utf8.RuneCountInString(term) > maxTermLength:
+		return fmt.Errorf("%w: acronym is longer than %d characters", ErrInvalidEntry, maxTermLength)
+	case utf8.RuneCountInString(expansion) > maxExpansionLength:
+		return fmt.Errorf("%w: expansion is longer than %d characters", ErrInvalidEntry, maxExpansionLength)
+	case strings.IndexFunc(term, unicode.IsSpace) >= 0:
+		return fmt.Errorf("%w: acronym must not contain spaces", ErrInvalidEntry)
+	case !isWordRune(firstRune(term)) || !isWordRune(lastRune(term)):
+		return fmt.Errorf("%w: acronym must start and end with a letter or digit", ErrInvalidEntry)
+	}
+	return nil
+}
+
+// Expand replaces every whole-word, case-sensitive occurrence of a term in
+// text with its expansion. Where terms overlap the longest one wins, and
+// expansions are never expanded again.
+func Expand(text string, dict map[string]string) string {
+	if len(dict) == 0 || text == "" {
+		return text
+	}
+	terms := slices.Collect(maps.Keys(dict))
+	slices.SortFunc(terms, func(a, b string) int { return len(b) - len(a) })
+
+	var out strings.Builder
+	last := 0
+	for i := 0; i < len(text); {
+		if i > 0 && isWordRune(lastRune(text[:i])) {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+			continue
+		}
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(text[i:], term) && !isWordRune(firstRune(text[i+len(term):])) {
+				matched = term
+				break
+			}
+		}
+		if matched == "" {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+			continue
+		}
+		out.WriteString(text[last:i])
+		out.WriteString(dict[matched])
+		i += len(matched)
+		last = i
+	}
+	if last == 0 {
+		return text
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
@@ -0,0 +1,57 @@
+package acronyms
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	dict := map[string]string{"EOD": "end of day", "PR": "pull request", "R&D": "research and development", "PRD": "product requirements document"}
+	tests := []struct {
+		in, want string
+	}{
+		{"Ship it by EOD.", "Ship it by end of day."},
+		{"EOD, then the PRD and the PR", "end of day, then the product requirements document and the pull request"},
+		{"PRs and PRO and eod stay", "PRs and PRO and eod stay"},
+		{"Talk to R&D (EOD)", "Talk to research and development (end of day)"},
+		{"nothing here", "nothing here"},
+		{"über EOD", "über end of day"},
+	}
+	for _, tt := range tests {
+		if got := Expand(tt.in, dict); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStorePersistsPerTenant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acronyms.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if err := store.Set("acme", "EOD", " end of day "); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set("acme", "two words", "x"); !errors.Is(err, ErrInvalidEntry) {
+		t.Fatalf("Set with a space = %v, want ErrInvalidEntry", err)
+	}
+	if got := store.Dictionary("globex"); len(got) != 0 {
+		t.Fatalf("other tenant sees %v", got)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore reload: %v", err)
+	}
+	if got := reloaded.Dictionary("acme")["EOD"]; got != "end of day" {
+		t.Fatalf("reloaded EOD = %q", got)
+	}
+	if ok, err := reloaded.Delete("acme", "EOD"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+	if ok, _ := reloaded.Delete("acme", "EOD"); ok {
+		t.Fatal("second Delete reported the entry as present")
+	}
+}
@@ -0,0 +1,49 @@
+package audio
+
+import "time"
+
+// WAVChunk is a span of a WAV file. It shares the file's samples until
+// Encode, so splitting long audio costs no copies up front.
+type WAVChunk struct {
+	Start time.Duration
+	End   time.Duration
+	wav   *WAV
+}
+
+// Encode returns the chunk as a WAV file of its own.
+func (c WAVChunk) Encode() []byte {
+	return c.wav.Encode()
+}
+
+func (w *WAV) Duration() time.Duration {
+	return samplesToDuration(int64(w.Frames()), w.SampleRate)
+}
+
+// Split cuts w into chunks of at most length that each repeat the last
+// overlap of the chunk before them, so words cut at a boundary are heard
+// whole in one of the two. Chunks keep w's format and are only encoded when
+// asked, so callers can hold one chunk's copy at a time.
+func (w *WAV) Split(length, overlap time.Duration) []WAVChunk {
+	frames := w.Frames()
+	size := max(1, int(int64(w.SampleRate)*int64(length)/int64(time.Second)))
+	step := max(1, size-int(int64(w.SampleRate)*int64(overlap)/int64(time.Second)))
+	var chunks []WAVChunk
+	for start := 0; ; start += step {
+		end := min(start+size, frames)
+		part := &WAV{
+			Format:        w.Format,
+			Channels:      w.Channels,
+			SampleRate:    w.SampleRate,
+			BitsPerSample: w.BitsPerSample,
+			Data:          w.Data[start*w.frameSize() : end*w.frameSize()],
+		}
+		chunks = append(chunks, WAVChunk{
+			Start: samplesToDuration(int64(start), w.SampleRate),
+			End:   samplesToDuration(int64(end), w.SampleRate),
+			wav:   part,
+		})
+		if end == frames {
+			return chunks
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitOverlapsChunks(t *testing.T) {
+	wav := FromSamples([][]float64{make([]float64, 8000*25)}, 8000)
+
+	chunks := wav.Split(10*time.Second, 2*time.Second)
+	want := [][2]time.Duration{{0, 10 * time.Second}, {8 * time.Second, 18 * time.Second}, {16 * time.Second, 25 * time.Second}}
+	if len(chunks) != len(want) {
+		t.Fatalf("Split() = %d chunks, want %d", len(chunks), len(want))
+	}
+	for i, chunk := range chunks {
+		if chunk.Start != want[i][0] || chunk.End != want[i][1] {
+			t.Fatalf("chunk %d spans %v-%v, want %v-%v", i, chunk.Start, chunk.End, want[i][0], want[i][1])
+		}
+		decoded, err := DecodeWAV(chunk.Encode())
+		if err != nil || decoded.Duration() != chunk.End-chunk.Start {
+			t.Fatalf("chunk %d decodes to %v, %v", i, decoded, err)
+		}
+	}
+
+	if chunks := wav.Split(time.Minute, 2*time.Second); len(chunks) != 1 || chunks[0].End != 25*time.Second {
+		t.Fatalf("Split() of short audio = %+v", chunks)
+	}
+}
@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// DefaultFileName is used for uploads with no usable name or type.
+const DefaultFileName = "audio.wav"
+
+// fileExtensions are the extensions transcription upstreams pick a decoder
+// from.
+var fileExtensions = map[string]bool{
+	".flac": true, ".m4a": true, ".mp3": true, ".mp4": true, ".mpeg": true, ".mpga": true,
+	".oga": true, ".ogg": true, ".opus": true, ".wav": true, ".webm": true,
+}
+
+var typeExtensions = map[string]string{
+	"audio/flac": ".flac", "audio/x-flac": ".flac",
+	"audio/mp4": ".m4a", "audio/m4a": ".m4a", "audio/x-m4a": ".m4a", "audio/aac": ".m4a",
+	"audio/mpeg": ".mp3", "audio/mp3": ".mp3",
+	"audio/ogg": ".ogg", "audio/opus": ".opus",
+	"audio/wav": ".wav", "audio/wave": ".wav", "audio/x-wav": ".wav", "audio/vnd.wave": ".wav",
+	"audio/webm": ".webm", "video/webm": ".webm",
+	"video/mp4": ".mp4",
+}
+
+var containerExtensions = map[string]string{
+	"wav": ".wav", "flac": ".flac", "ogg": ".ogg", "mp4": ".mp4", "webm": ".webm", "mp3": ".mp3",
+	"amr": ".amr",
+}
+
+// FileName returns an upload's name with an extension the upstream can
+// decode by. A name that already has one is kept. Otherwise the extension
+// comes from contentType, or else from the container Probe found, and is
+// appended to the name; browsers' "blob" and an empty name become
+// fallback's stem. When nothing is known, an empty or "blob" name becomes
+// fallback and any other name is kept as it is. Directories in name are
+// dropped, whichever separator the client's OS uses.
+func FileName(name, contentType, container, fallback string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if fileExtensions[strings.ToLower(path.Ext(name))] {
+		return name
+	}
+	if fallback == "" {
+		fallback = DefaultFileName
+	}
+	ext := ""
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		ext = typeExtensions[mediaType]
+	}
+	if ext == "" {
+		ext = containerExtensions[container]
+	}
+	anonymous := name == "" || strings.EqualFold(name, "blob")
+	switch {
+	case ext == "" && anonymous:
+		return fallback
+	case ext == "":
+		return name
+	case anonymous:
+		return strings.TrimSuffix(fallback, path.Ext(fallback)) + ext
+	}
+	return name + ext
+}
@@ -0,0 +1,25 @@
+package audio
+
+import "testing"
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name, contentType, container, fallback, want string
+	}{
+		{name: "call.MP3", contentType: "audio/webm", want: "call.MP3"},
+		{name: "blob", contentType: "audio/webm;codecs=opus", want: "audio.webm"},
+		{name: "", contentType: "audio/x-m4a", fallback: "upload.bin", want: "upload.m4a"},
+		{name: "recording", contentType: "application/octet-stream", container: "ogg", want: "recording.ogg"},
+		{name: "voice.dat", contentType: "audio/mpeg", want: "voice.dat.mp3"},
+		{name: "blob", contentType: "application/octet-stream", fallback: "speech.flac", want: "speech.flac"},
+		{name: "notes", want: "notes"},
+		{name: `C:\Users\dana\call.wav`, want: "call.wav"},
+		{name: "recordings/standup", contentType: "audio/ogg", want: "standup.ogg"},
+		{want: DefaultFileName},
+	}
+	for _, tt := range tests {
+		if got := FileName(tt.name, tt.contentType, tt.container, tt.fallback); got != tt.want {
+			t.Errorf("FileName(%q, %q, %q, %q) = %q, want %q", tt.name, tt.contentType, tt.container, tt.fallback, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	silenceThreshold = 0.01
+	silencePadding   = 100 * time.Millisecond
+	// silenceMaxGap is the longest pause trimSilence leaves inside a clip.
+	silenceMaxGap    = 600 * time.Millisecond
+	vadWindow        = 20 * time.Millisecond
+	normalizePeak    = 0.891 // -1 dBFS
+	maxNormalizeGain = 20.0
+)
+
+type PreprocessOptions struct {
+	TrimSilence bool
+	Normalize   bool
+	Downmix     bool
+	SampleRate  int
+	// Transcode converts the upload to 16 kHz mono with ffmpeg before
+	// anything else; see Transcoder. It is not counted by Enabled, which
+	// reports the operations done on WAV audio.
+	Transcode bool
+}
+
+func (o PreprocessOptions) Enabled() bool {
+	return o.TrimSilence || o.Normalize || o.Downmix || o.SampleRate > 0
+}
+
+// Preprocessed is the audio Preprocess produced.
+type Preprocessed struct {
+	// Audio is the re-encoded 16-bit PCM WAV.
+	Audio []byte
+	// Applied lists the operations that changed the audio.
+	Applied []string
+	// Timeline maps times in Audio back to the input. It is nil unless
+	// silence was cut out.
+	Timeline Timeline
+}
+
+// Preprocess applies the requested operations to a WAV file.
+func Preprocess(data []byte, opts PreprocessOptions) (Preprocessed, error) {
+	wav, err := DecodeWAV(data)
+	if err != nil {
+		return Preprocessed{}, err
+	}
+	channels := wav.Samples()
+	rate := wav.SampleRate
+	var out Preprocessed
+
+	if opts.Downmix && len(channels) > 1 {
+		channels = [][]float64{downmix(channels)}
+		out.Applied = append(out.Applied, "downmix")
+	}
+	if opts.SampleRate > 0 && opts.SampleRate != rate {
+		for i := range channels {
+			channels[i] = resample(channels[i], rate, opts.SampleRate)
+		}
+		out.Applied = append(out.Applied, fmt.Sprintf("resample:%d", opts.SampleRate))
+		rate = opts.SampleRate
+	}
+	if opts.TrimSilence {
+		if trimmed, kept := trimSilence(channels, rate); kept != nil {
+			channels = trimmed
+			out.Applied = append(out.Applied, "trim_silence")
+			for _, span := range kept {
+				out.Timeline = append(out.Timeline, Span{Start: framesToDuration(span[0], rate), End: framesToDuration(span[1], rate)})
+			}
+		}
+	}
+	if opts.Normalize && normalize(channels) {
+		out.Applied = append(out.Applied, "normalize")
+	}
+
+	out.Audio = FromSamples(channels, rate).Encode()
+	return out, nil
+}
+
+// Span is a stretch of the input kept by Preprocess.
+type Span struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Timeline lists the spans of the input that preprocessed audio is made of,
+// in order. A nil Timeline means nothing was cut.
+type Timeline []Span
+
+// Original maps time d in the preprocessed audio to the input. Times past
+// the last span are carried on from its end.
+func (t Timeline) Original(d time.Duration) time.Duration {
+	if len(t) == 0 {
+		return d
+	}
+	var offset time.Duration
+	for _, span := range t {
+		length := span.End - span.Start
+		if d < offset+length {
+			return span.Start + d - offset
+		}
+		offset += length
+	}
+	return t[len(t)-1].End + d - offset
+}
+
+func framesToDuration(frames, rate int) time.Duration {
+	return time.Duration(int64(frames) * int64(time.Second) / int64(rate))
+}
+
+// Samples returns each channel as floats in [-1, 1].
+func (w *WAV) Samples() [][]float64 {
+	sampleSize := w.BitsPerSample / 8
+	frames := w.Frames()
+	out := make([][]float64, w.Channels)
+	for ch := range out {
+		out[ch] = make([]float64, frames)
+	}
+	for f := 0; f < frames; f++ {
+		for ch := 0; ch < w.Channels; ch++ {
+			start := f*w.frameSize() + ch*sampleSize
+			out[ch][f] = w.decodeSample(w.Data[start : start+sampleSize])
+		}
+	}
+	return out
+}
+
+func (w *WAV) decodeSample(b []byte) float64 {
+	if w.Format == wavFormatFloat {
+		if len(b) == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	switch len(b) {
+	case 1:
+		return (float64(b[0]) - 128) / 128
+	case 2:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+	case 3:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / 8388608
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+	}
+}
+
+// FromSamples encodes float channels as 16-bit PCM.
+func FromSamples(channels [][]float64, sampleRate int) *WAV {
+	frames := 0
+	if len(channels) > 0 {
+		frames = len(channels[0])
+	}
+	data := make([]byte, 0, frames*len(channels)*2)
+	for f := 0; f < frames; f++ {
+		for _, ch := range channels {
+			v := math.Max(-1, math.Min(1, ch[f]))
+			data = binary.LittleEndian.AppendUint16(data, uint16(int16(math.Round(v*32767))))
+		}
+	}
+	return &WAV{Format: wavFormatPCM, Channels: len(channels), SampleRate: sampleRate, BitsPerSample: 16, Data: data}
+}
+
+func downmix(channels [][]float64) []float64 {
+	out := make([]float64, len(channels[0]))
+	for _, ch := range channels {
+		for i, v := range ch {
+			out[i] += v
+		}
+	}
+	for i := range out {
+		out[i] /= float64(len(channels))
+	}
+	return out
+}
+
+func resample(samples []float64, from, to int) []float64 {
+	if len(samples) == 0 {
+		return samples
+	}
+	n := int(int64(len(samples)) * int64(to) / int64(from))
+	out := make([]float64, n)
+	ratio := float64(from) / float64(to)
+	for i := range out {
+		pos := float64(i) * ratio
+		idx := int(pos)
+		if idx+1 >= len(samples) {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+		frac := pos - float64(idx)
+		out[i] = samples[idx]*(1-frac) + samples[idx+1]*frac
+	}
+	return out
+}
+
+// trimSilence is a simple voice activity detector. It marks each 20 ms
+// window as speech when its RMS in any channel reaches the silence
+// threshold, drops the silence before the first and after the last speech
+// save a short pad, and shortens longer pauses to silenceMaxGap by cutting
+// out their middle. It returns the trimmed channels and the frame spans
+// they keep, or nil spans when nothing was cut.
+func trimSilence(channels [][]float64, rate int) ([][]float64, [][2]int) {
+	frames := len(channels[0])
+	window := max(1, int(vadWindow.Seconds()*float64(rate)))
+
+	var spans [][2]int
+	for start := 0; start < frames; start += window {
+		end := min(frames, start+window)
+		if !voiced(channels, start, end) {
+			continue
+		}
+		if n := len(spans); n > 0 && spans[n-1][1] == start {
+			spans[n-1][1] = end
+		} else {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+	if len(spans) == 0 {
+		return channels, nil
+	}
+
+	pad := int(silencePadding.Seconds() * float64(rate))
+	keep := int(silenceMaxGap.Seconds()*float64(rate)) / 2
+	kept := [][2]int{{max(0, spans[0][0]-pad), spans[0][1]}}
+	for _, span := range spans[1:] {
+		last := &kept[len(kept)-1]
+		if span[0]-last[1] <= 2*keep {
+			last[1] = span[1]
+			continue
+		}
+		last[1] += keep
+		kept = append(kept, [2]int{span[0] - keep, span[1]})
+	}
+	kept[len(kept)-1][1] = min(frames, kept[len(kept)-1][1]+pad)
+
+	if len(kept) == 1 && kept[0] == [2]int{0, frames} {
+		return channels, nil
+	}
+	out := make([][]float64, len(channels))
+	for i, ch := range channels {
+		for _, span := range kept {
+			out[i] = append(out[i], ch[span[0]:span[1]]...)
+		}
+	}
+	return out, kept
+}
+
+// voiced reports whether frames [start, end) of any channel are loud
+// enough to be speech.
+func voiced(channels [][]float64, start, end int) bool {
+	for _, ch := range channels {
+		sum := 0.0
+		for _, v := range ch[start:end] {
+			sum += v * v
+		}
+		if math.Sqrt(sum/float64(end-start)) >= silenceThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(channels [][]float64) bool {
+	peak := 0.0
+	for _, ch := range channels {
+		for _, v := range ch {
+			peak = math.Max(peak, math.Abs(v))
+		}
+	}
+	if peak == 0 {
+		return false
+	}
+	gain := math.Min(normalizePeak/peak, maxNormalizeGain)
+	if math.Abs(gain-1) < 0.01 {
+		return false
+	}
+	for _, ch := range channels {
+		for i := range ch {
+			ch[i] *= gain
+		}
+	}
+	return true
+}
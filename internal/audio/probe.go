@@ -0,0 +1,337 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Metadata describes an audio file as far as its container headers allow.
+// Duration is zero when it cannot be derived without decoding.
+type Metadata struct {
+	Container  string
+	Duration   time.Duration
+	SampleRate int
+	Channels   int
+}
+
+// DurationError reports audio longer than the configured limit.
+type DurationError struct {
+	Duration time.Duration
+	Limit    time.Duration
+}
+
+func (e *DurationError) Error() string {
+	return fmt.Sprintf("audio is %s long; at most %s is allowed", e.Duration.Round(time.Second), e.Limit)
+}
+
+// CheckDuration returns a *DurationError when d is over limit. A zero limit
+// disables the check, and a zero d (unknown duration) always passes.
+func CheckDuration(d, limit time.Duration) error {
+	if limit > 0 && d > limit {
+		return &DurationError{Duration: d, Limit: limit}
+	}
+	return nil
+}
+
+const probeTailBytes = 64 << 10
+
+// Probe inspects container headers without decoding audio. It reads only the
+// regions it needs, so it is cheap even for large spooled uploads.
+func Probe(r io.ReaderAt, size int64) (Metadata, error) {
+	head := make([]byte, 64)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return Metadata{}, err
+	}
+	head = head[:n]
+
+	switch {
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return probeWAV(r, size)
+	case len(head) >= 4 && string(head[0:4]) == "fLaC":
+		return probeFLAC(r)
+	case len(head) >= 4 && string(head[0:4]) == "OggS":
+		return probeOgg(r, size)
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return probeMP4(r, size)
+	case len(head) >= 4 && bytes.Equal(head[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return Metadata{Container: "webm"}, nil
+	case len(head) >= 3 && (string(head[0:3]) == "ID3" || (head[0] == 0xFF && head[1]&0xE0 == 0xE0)):
+		return probeMP3(r, size)
+	case len(head) >= 6 && string(head[0:5]) == "#!AMR":
+		return Metadata{Container: "amr", SampleRate: amrRate(head), Channels: 1}, nil
+	}
+	return Metadata{}, ErrUnsupportedFormat
+}
+
+// amrRate is 16 kHz for AMR-WB ("#!AMR-WB\n") and 8 kHz for AMR-NB.
+func amrRate(head []byte) int {
+	if bytes.HasPrefix(head, []byte("#!AMR-WB")) {
+		return 16000
+	}
+	return 8000
+}
+
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, off)
+	if read == n {
+		return buf, nil
+	}
+	if err == nil || err == io.EOF {
+		err = fmt.Errorf("%w: unexpected end of file", ErrInvalidAudio)
+	}
+	return nil, err
+}
+
+func probeWAV(r io.ReaderAt, size int64) (Metadata, error) {
+	meta := Metadata{Container: "wav"}
+	var byteRate int64
+	offset := int64(12)
+	for offset+8 <= size {
+		hdr, err := readAt(r, offset, 8)
+		if err != nil {
+			return Metadata{}, err
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+		switch string(hdr[0:4]) {
+		case "fmt ":
+			fmtChunk, err := readAt(r, offset+8, 16)
+			if err != nil {
+				return Metadata{}, err
+			}
+			meta.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			meta.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+			byteRate = int64(binary.LittleEndian.Uint32(fmtChunk[8:12]))
+		case "data":
+			dataSize := min(chunkSize, size-offset-8)
+			if byteRate > 0 {
+				meta.Duration = time.Duration(float64(dataSize) / float64(byteRate) * float64(time.Second))
+			}
+			return meta, nil
+		}
+		offset += 8 + chunkSize + chunkSize%2
+	}
+	return Metadata{}, fmt.Errorf("%w: missing data chunk", ErrInvalidAudio)
+}
+
+func probeFLAC(r io.ReaderAt) (Metadata, error) {
+	info, err := readAt(r, 8, 18)
+	if err != nil {
+		return Metadata{}, err
+	}
+	packed := binary.BigEndian.Uint64(info[10:18])
+	sampleRate := int(packed >> 44)
+	channels := int((packed>>41)&0x7) + 1
+	totalSamples := int64(packed & 0xFFFFFFFFF)
+
+	meta := Metadata{Container: "flac", SampleRate: sampleRate, Channels: channels}
+	if sampleRate > 0 {
+		meta.Duration = samplesToDuration(totalSamples, sampleRate)
+	}
+	return meta, nil
+}
+
+func probeOgg(r io.ReaderAt, size int64) (Metadata, error) {
+	page, err := readAt(r, 0, 27)
+	if err != nil {
+		return Metadata{}, err
+	}
+	segments := int(page[26])
+	packet, err := readAt(r, int64(27+segments), 19)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	meta := Metadata{Container: "ogg"}
+	granuleRate := 0
+	preSkip := int64(0)
+	switch {
+	case string(packet[0:8]) == "OpusHead":
+		meta.Channels = int(packet[9])
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+		meta.SampleRate = int(binary.LittleEndian.Uint32(packet[12:16]))
+		granuleRate = 48000
+	case string(packet[0:7]) == "\x01vorbis":
+		meta.Channels = int(packet[11])
+		meta.SampleRate = int(binary.LittleEndian.Uint32(packet[12:16]))
+		granuleRate = meta.SampleRate
+	default:
+		return meta, nil
+	}
+
+	tailStart := max(0, size-probeTailBytes)
+	tail, err := readAt(r, tailStart, int(size-tailStart))
+	if err != nil {
+		return Metadata{}, err
+	}
+	if idx := bytes.LastIndex(tail, []byte("OggS")); idx >= 0 && idx+14 <= len(tail) && granuleRate > 0 {
+		granule := int64(binary.LittleEndian.Uint64(tail[idx+6 : idx+14]))
+		meta.Duration = samplesToDuration(max(0, granule-preSkip), granuleRate)
+	}
+	return meta, nil
+}
+
+func probeMP4(r io.ReaderAt, size int64) (Metadata, error) {
+	meta := Metadata{Container: "mp4"}
+	moovOff, moovSize, ok, err := findBox(r, 0, size, "moov")
+	if err != nil || !ok {
+		return meta, err
+	}
+
+	if off, _, ok, err := findBox(r, moovOff, moovSize, "mvhd"); err != nil {
+		return Metadata{}, err
+	} else if ok {
+		hdr, err := readAt(r, off, 32)
+		if err != nil {
+			return Metadata{}, err
+		}
+		var timescale, duration int64
+		if hdr[0] == 1 {
+			timescale = int64(binary.BigEndian.Uint32(hdr[20:24]))
+			duration = int64(binary.BigEndian.Uint64(hdr[24:32]))
+		} else {
+			timescale = int64(binary.BigEndian.Uint32(hdr[12:16]))
+			duration = int64(binary.BigEndian.Uint32(hdr[16:20]))
+		}
+		if timescale > 0 {
+			meta.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+		}
+	}
+
+	// moov/trak/mdia/minf/stbl/stsd holds the first audio sample entry.
+	off, length := moovOff, moovSize
+	for _, name := range []string{"trak", "mdia", "minf", "stbl", "stsd"} {
+		var ok bool
+		off, length, ok, err = findBox(r, off, length, name)
+		if err != nil {
+			return Metadata{}, err
+		}
+		if !ok {
+			return meta, nil
+		}
+	}
+	entry, err := readAt(r, off+8, 36)
+	if err != nil {
+		return meta, nil
+	}
+	meta.Channels = int(binary.BigEndian.Uint16(entry[24:26]))
+	meta.SampleRate = int(binary.BigEndian.Uint32(entry[32:36]) >> 16)
+	return meta, nil
+}
+
+// findBox scans sibling ISO-BMFF boxes in [off, off+length) and returns the
+// payload range of the first one with the given type.
+func findBox(r io.ReaderAt, off, length int64, boxType string) (int64, int64, bool, error) {
+	end := off + length
+	for off+8 <= end {
+		hdr, err := readAt(r, off, 8)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(hdr[0:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - off
+		case 1:
+			large, err := readAt(r, off+8, 8)
+			if err != nil {
+				return 0, 0, false, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(large))
+			headerSize = 16
+		}
+		if boxSize < headerSize {
+			return 0, 0, false, fmt.Errorf("%w: bad %q box size", ErrInvalidAudio, hdr[4:8])
+		}
+		if string(hdr[4:8]) == boxType {
+			return off + headerSize, boxSize - headerSize, true, nil
+		}
+		off += boxSize
+	}
+	return 0, 0, false, nil
+}
+
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3Rates      = map[uint32][3]int{3: {44100, 48000, 32000}, 2: {22050, 24000, 16000}, 0: {11025, 12000, 8000}}
+)
+
+func probeMP3(r io.ReaderAt, size int64) (Metadata, error) {
+	start := int64(0)
+	if id3, err := readAt(r, 0, 10); err == nil && string(id3[0:3]) == "ID3" {
+		tagSize := int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9])
+		start = 10 + tagSize
+		if id3[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+
+	if start >= size {
+		return Metadata{}, fmt.Errorf("%w: no MPEG audio frame found", ErrInvalidAudio)
+	}
+	window, err := readAt(r, start, int(min(probeTailBytes, size-start)))
+	if err != nil {
+		return Metadata{}, err
+	}
+	for i := 0; i+4 <= len(window); i++ {
+		if window[i] != 0xFF || window[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		header := binary.BigEndian.Uint32(window[i : i+4])
+		version := (header >> 19) & 0x3
+		layer := (header >> 17) & 0x3
+		bitrateIdx := (header >> 12) & 0xF
+		rateIdx := (header >> 10) & 0x3
+		if version == 1 || layer != 1 || bitrateIdx == 0 || bitrateIdx == 15 || rateIdx == 3 {
+			continue
+		}
+
+		sampleRate := mp3Rates[version][rateIdx]
+		mono := (header>>6)&0x3 == 3
+		bitrate := mp3BitratesV2[bitrateIdx]
+		samplesPerFrame := 576
+		sideInfo := 17
+		if version == 3 {
+			bitrate = mp3BitratesV1[bitrateIdx]
+			samplesPerFrame = 1152
+			sideInfo = 32
+		}
+
+		meta := Metadata{Container: "mp3", SampleRate: sampleRate, Channels: 2}
+		if mono {
+			meta.Channels = 1
+			sideInfo /= 2
+		}
+
+		// Xing/Info and VBRI headers carry an exact frame count for VBR files.
+		if xing := i + 4 + sideInfo; xing+12 <= len(window) {
+			tag := string(window[xing : xing+4])
+			flags := binary.BigEndian.Uint32(window[xing+4 : xing+8])
+			if (tag == "Xing" || tag == "Info") && flags&0x1 != 0 {
+				frames := int64(binary.BigEndian.Uint32(window[xing+8 : xing+12]))
+				meta.Duration = samplesToDuration(frames*int64(samplesPerFrame), sampleRate)
+				return meta, nil
+			}
+		}
+		if vbri := i + 36; vbri+18 <= len(window) && string(window[vbri:vbri+4]) == "VBRI" {
+			frames := int64(binary.BigEndian.Uint32(window[vbri+14 : vbri+18]))
+			meta.Duration = samplesToDuration(frames*int64(samplesPerFrame), sampleRate)
+			return meta, nil
+		}
+
+		audioBytes := size - start - int64(i)
+		meta.Duration = time.Duration(float64(audioBytes*8) / float64(bitrate*1000) * float64(time.Second))
+		return meta, nil
+	}
+	return Metadata{}, fmt.Errorf("%w: no MPEG audio frame found", ErrInvalidAudio)
+}
+
+func samplesToDuration(samples int64, rate int) time.Duration {
+	return time.Duration(float64(samples) / float64(rate) * float64(time.Second))
+}
@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestProbeWAV(t *testing.T) {
+	wav := &WAV{Format: wavFormatPCM, Channels: 2, SampleRate: 8000, BitsPerSample: 16, Data: make([]byte, 8000*4*3)}
+	data := wav.Encode()
+
+	meta, err := Probe(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if meta.Container != "wav" || meta.Channels != 2 || meta.SampleRate != 8000 || meta.Duration != 3*time.Second {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+}
+
+func TestProbeAMR(t *testing.T) {
+	for head, rate := range map[string]int{"#!AMR\n": 8000, "#!AMR-WB\n": 16000} {
+		data := append([]byte(head), make([]byte, 32)...)
+		meta, err := Probe(bytes.NewReader(data), int64(len(data)))
+		if err != nil || meta.Container != "amr" || meta.SampleRate != rate || meta.Channels != 1 {
+			t.Fatalf("Probe(%q) = %+v, %v", head, meta, err)
+		}
+	}
+}
+
+func TestProbeMP3ConstantBitrate(t *testing.T) {
+	// MPEG-1 Layer III, 128 kbps, 44.1 kHz, joint stereo.
+	frame := []byte{0xFF, 0xFB, 0x90, 0x44}
+	data := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x00"), frame...)
+	data = append(data, make([]byte, 16000*10-len(frame))...)
+
+	meta, err := Probe(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if meta.Container != "mp3" || meta.SampleRate != 44100 || meta.Channels != 2 {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if meta.Duration != 10*time.Second {
+		t.Fatalf("unexpected duration: %v", meta.Duration)
+	}
+}
+
+func TestProbeFLAC(t *testing.T) {
+	info := make([]byte, 34)
+	packed := uint64(16000)<<44 | uint64(1-1)<<41 | uint64(16-1)<<36 | uint64(16000*5)
+	binary.BigEndian.PutUint64(info[10:18], packed)
+	data := append([]byte("fLaC\x80\x00\x00\x22"), info...)
+
+	meta, err := Probe(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if meta.Container != "flac" || meta.SampleRate != 16000 || meta.Channels != 1 || meta.Duration != 5*time.Second {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+}
+
+func TestProbeUnknownFormat(t *testing.T) {
+	data := []byte("definitely not audio")
+	if _, err := Probe(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TranscodeRate is the sample rate Transcode writes, the rate Whisper-style
+// models resample to anyway.
+const TranscodeRate = 16000
+
+// ErrTranscodeUnavailable is returned when transcoding is asked for but no
+// ffmpeg is configured.
+var ErrTranscodeUnavailable = errors.New("transcoding is not enabled on this server")
+
+// Transcoder converts audio with an ffmpeg binary.
+type Transcoder struct {
+	path        string
+	maxDuration time.Duration
+}
+
+// DefaultMaxTranscodeDuration bounds transcoder output when no maximum is
+// given, since containers such as AMR and 3GP give no duration up front.
+const DefaultMaxTranscodeDuration = 4 * time.Hour
+
+// demuxers names the ffmpeg demuxer for each container Probe recognizes.
+// Only these are ever opened: ffmpeg would otherwise pick a demuxer from the
+// contents, and some (HLS, concat) open further files or URLs named inside
+// the upload.
+var demuxers = map[string]string{
+	"wav":  "wav",
+	"flac": "flac",
+	"ogg":  "ogg",
+	"mp4":  "mov",
+	"webm": "matroska",
+	"mp3":  "mp3",
+	"amr":  "amr",
+}
+
+// NewTranscoder looks up ffmpeg, which is either a name on PATH or a path
+// to the binary. Output longer than maxDuration, or DefaultMaxTranscodeDuration
+// when it is zero, fails with a *DurationError.
+func NewTranscoder(ffmpeg string, maxDuration time.Duration) (*Transcoder, error) {
+	p, err := exec.LookPath(ffmpeg)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	return &Transcoder{path: p, maxDuration: cmp.Or(maxDuration, DefaultMaxTranscodeDuration)}, nil
+}
+
+// Transcode decodes data with ffmpeg and encodes its first audio stream as
+// 16 kHz mono format, "flac" or "wav", dropping any video. The audio goes
+// through temporary files rather than pipes because MP4 and 3GP files often
+// keep their index at the end, which ffmpeg can only reach by seeking.
+//
+// The input is opened with the demuxer for its probed container and only
+// the file protocol, so ffmpeg reads nothing but the upload. Output stops
+// just past the maximum duration, which also bounds its size.
+func (t *Transcoder) Transcode(ctx context.Context, data []byte, format string) ([]byte, error) {
+	codec := map[string]string{"flac": "flac", "wav": "pcm_s16le"}[format]
+	if codec == "" {
+		return nil, fmt.Errorf("transcode: unknown format %q", format)
+	}
+	meta, err := Probe(bytes.NewReader(data), int64(len(data)))
+	demuxer := demuxers[meta.Container]
+	if err != nil || demuxer == "" {
+		return nil, ErrUnsupportedFormat
+	}
+	dir, err := os.MkdirTemp("", "echoflow-transcode-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out."+format)
+	if err := os.WriteFile(in, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	// One second past the limit tells audio over it from audio that ends
+	// exactly there. -fs is a backstop at the WAV size of that length.
+	limit := t.maxDuration + time.Second
+	maxBytes := int64(limit.Seconds())*TranscodeRate*2 + 1<<10
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.path,
+		"-nostdin", "-hide_banner", "-loglevel", "error",
+		"-protocol_whitelist", "file", "-f", demuxer,
+		"-i", in, "-map", "0:a:0", "-vn",
+		"-ac", "1", "-ar", fmt.Sprint(TranscodeRate), "-c:a", codec,
+		"-t", strconv.FormatFloat(limit.Seconds(), 'f', -1, 64), "-fs", strconv.FormatInt(maxBytes, 10),
+		"-f", format, out)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: ffmpeg: %s", ErrInvalidAudio, cmp.Or(strings.TrimSpace(stderr.String()), err.Error()))
+	}
+	converted, err := os.ReadFile(out)
+	if err != nil {
+		return nil, err
+	}
+	if meta, err := Probe(bytes.NewReader(converted), int64(len(converted))); err == nil {
+		if err := CheckDuration(meta.Duration, t.maxDuration); err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+// NeedsTranscode reports whether name has an extension transcription
+// upstreams cannot decode, such as .amr, .3gp or .mov.
+func NeedsTranscode(name string) bool {
+	return !fileExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// WithExt replaces name's extension with ext.
+func WithExt(name, ext string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + ext
+}
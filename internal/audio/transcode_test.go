@@ -0,0 +1,94 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// The test binary stands in for ffmpeg when ECHOFLOW_FAKE_FFMPEG is set: it
+// writes its arguments and the input file to the output file.
+func TestMain(m *testing.M) {
+	if os.Getenv("ECHOFLOW_FAKE_FFMPEG") == "" {
+		os.Exit(m.Run())
+	}
+	args := os.Args[1:]
+	switch os.Getenv("ECHOFLOW_FAKE_FFMPEG") {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "Invalid data found when processing input")
+		os.Exit(1)
+	case "long":
+		// Three seconds of silence, as ffmpeg would write for a long input.
+		wav := FromSamples([][]float64{make([]float64, 3*TranscodeRate)}, TranscodeRate).Encode()
+		if os.WriteFile(args[len(args)-1], wav, 0o600) != nil {
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+	in, err := os.ReadFile(args[slices.Index(args, "-i")+1])
+	if err != nil {
+		os.Exit(2)
+	}
+	out, err := os.Create(args[len(args)-1])
+	if err != nil {
+		os.Exit(2)
+	}
+	fmt.Fprintln(out, args[:len(args)-1])
+	_, _ = io.Copy(out, bytes.NewReader(in))
+	_ = out.Close()
+	os.Exit(0)
+}
+
+func TestTranscodeRunsFFmpeg(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	transcoder, err := NewTranscoder(exe, 2*time.Second)
+	if err != nil {
+		t.Fatalf("NewTranscoder: %v", err)
+	}
+
+	t.Setenv("ECHOFLOW_FAKE_FFMPEG", "ok")
+	out, err := transcoder.Transcode(context.Background(), []byte("#!AMR\n"), "flac")
+	if err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+	for _, want := range []string{"-protocol_whitelist file -f amr -i", "-map 0:a:0 -vn -ac 1 -ar 16000 -c:a flac -t 3 -fs 97024 -f flac", "#!AMR"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Fatalf("output %q does not contain %q", out, want)
+		}
+	}
+	if _, err := transcoder.Transcode(context.Background(), nil, "mp3"); err == nil {
+		t.Fatal("Transcode to mp3 succeeded")
+	}
+
+	if _, err := transcoder.Transcode(context.Background(), []byte("#EXTM3U\nhttp://169.254.169.254/\n"), "flac"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("playlist input: err = %v", err)
+	}
+
+	t.Setenv("ECHOFLOW_FAKE_FFMPEG", "fail")
+	if _, err := transcoder.Transcode(context.Background(), []byte("#!AMR\njunk"), "wav"); !errors.Is(err, ErrInvalidAudio) {
+		t.Fatalf("failed ffmpeg: err = %v", err)
+	}
+
+	t.Setenv("ECHOFLOW_FAKE_FFMPEG", "long")
+	var durationErr *DurationError
+	if _, err := transcoder.Transcode(context.Background(), []byte("#!AMR\n"), "wav"); !errors.As(err, &durationErr) {
+		t.Fatalf("long audio: err = %v", err)
+	}
+}
+
+func TestNeedsTranscode(t *testing.T) {
+	for name, want := range map[string]bool{"call.amr": true, "clip.3gp": true, "talk.MOV": true, "memo.m4a": false, "a.WAV": false} {
+		if got := NeedsTranscode(name); got != want {
+			t.Fatalf("NeedsTranscode(%q) = %v", name, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrInvalidAudio      = errors.New("invalid audio data")
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// WAV is a decoded RIFF/WAVE file holding interleaved little-endian samples.
+type WAV struct {
+	Format        uint16
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+	Data          []byte
+}
+
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+func DecodeWAV(data []byte) (*WAV, error) {
+	if !IsWAV(data) {
+		return nil, ErrUnsupportedFormat
+	}
+
+	var wav *WAV
+	var pcm []byte
+	offset := 12
+	for offset+8 <= len(data) {
+		chunkID := string(data[offset : offset+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+		end := body + chunkSize
+		if chunkSize < 0 || end > len(data) {
+			// Streaming encoders often write a bogus size for the final data chunk.
+			if chunkID != "data" {
+				return nil, fmt.Errorf("%w: truncated %q chunk", ErrInvalidAudio, chunkID)
+			}
+			end = len(data)
+		}
+
+		switch chunkID {
+		case "fmt ":
+			if chunkSize < 16 {
+				return nil, fmt.Errorf("%w: short fmt chunk", ErrInvalidAudio)
+			}
+			format := binary.LittleEndian.Uint16(data[body : body+2])
+			if format == wavFormatExtensible && chunkSize >= 26 {
+				format = binary.LittleEndian.Uint16(data[body+24 : body+26])
+			}
+			wav = &WAV{
+				Format:        format,
+				Channels:      int(binary.LittleEndian.Uint16(data[body+2 : body+4])),
+				SampleRate:    int(binary.LittleEndian.Uint32(data[body+4 : body+8])),
+				BitsPerSample: int(binary.LittleEndian.Uint16(data[body+14 : body+16])),
+			}
+		case "data":
+			pcm = data[body:end]
+		}
+
+		offset = end + chunkSize%2
+	}
+
+	if wav == nil || pcm == nil {
+		return nil, fmt.Errorf("%w: missing fmt or data chunk", ErrInvalidAudio)
+	}
+	if wav.Format != wavFormatPCM && wav.Format != wavFormatFloat {
+		return nil, fmt.Errorf("%w: WAV encoding %d", ErrUnsupportedFormat, wav.Format)
+	}
+	if wav.Channels <= 0 || wav.SampleRate <= 0 || wav.BitsPerSample <= 0 || wav.BitsPerSample%8 != 0 {
+		return nil, fmt.Errorf("%w: bad fmt chunk", ErrInvalidAudio)
+	}
+	frame := wav.frameSize()
+	wav.Data = pcm[:len(pcm)-len(pcm)%frame]
+	return wav, nil
+}
+
+func (w *WAV) frameSize() int {
+	return w.Channels * w.BitsPerSample / 8
+}
+
+func (w *WAV) Frames() int {
+	return len(w.Data) / w.frameSize()
+}
+
+// SplitChannels returns one mono WAV per channel, in channel order.
+func (w *WAV) SplitChannels() []*WAV {
+	sampleSize := w.BitsPerSample / 8
+	frames := w.Frames()
+	out := make([]*WAV, w.Channels)
+	for ch := range out {
+		data := make([]byte, 0, frames*sampleSize)
+		for f := 0; f < frames; f++ {
+			start := f*w.frameSize() + ch*sampleSize
+			data = append(data, w.Data[start:start+sampleSize]...)
+		}
+		out[ch] = &WAV{
+			Format:        w.Format,
+			Channels:      1,
+			SampleRate:    w.SampleRate,
+			BitsPerSample: w.BitsPerSample,
+			Data:          data,
+		}
+	}
+	return out
+}
+
+func (w *WAV) Encode() []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(w.Data))
+	_, _ = w.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+func (w *WAV) WriteTo(dst io.Writer) (int64, error) {
+	header := make([]byte, 44)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+len(w.Data)))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], w.Format)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(w.Channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(w.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(w.SampleRate*w.frameSize()))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(w.frameSize()))
+	binary.LittleEndian.PutUint16(header[34:36], uint16(w.BitsPerSample))
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(len(w.Data)))
+
+	n, err := dst.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := dst.Write(w.Data)
+	return int64(n + m), err
+}
@@ -0,0 +1,175 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func stereo16(frames [][2]int16) *WAV {
+	data := make([]byte, 0, len(frames)*4)
+	for _, f := range frames {
+		data = binary.LittleEndian.AppendUint16(data, uint16(f[0]))
+		data = binary.LittleEndian.AppendUint16(data, uint16(f[1]))
+	}
+	return &WAV{Format: wavFormatPCM, Channels: 2, SampleRate: 16000, BitsPerSample: 16, Data: data}
+}
+
+func TestDecodeWAVRoundTrip(t *testing.T) {
+	in := stereo16([][2]int16{{1, -1}, {2, -2}, {3, -3}})
+
+	out, err := DecodeWAV(in.Encode())
+	if err != nil {
+		t.Fatalf("DecodeWAV() error = %v", err)
+	}
+	if out.Channels != 2 || out.SampleRate != 16000 || out.BitsPerSample != 16 || out.Frames() != 3 {
+		t.Fatalf("unexpected header: %+v", out)
+	}
+}
+
+func TestSplitChannels(t *testing.T) {
+	in := stereo16([][2]int16{{1, -1}, {2, -2}, {3, -3}})
+
+	channels := in.SplitChannels()
+	if len(channels) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(channels))
+	}
+	for ch, sign := range []int16{1, -1} {
+		mono := channels[ch]
+		if mono.Channels != 1 || mono.Frames() != 3 {
+			t.Fatalf("channel %d: unexpected shape %+v", ch, mono)
+		}
+		for i := 0; i < 3; i++ {
+			got := int16(binary.LittleEndian.Uint16(mono.Data[i*2:]))
+			if want := sign * int16(i+1); got != want {
+				t.Fatalf("channel %d sample %d: got %d want %d", ch, i, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeWAVRejectsOtherContainers(t *testing.T) {
+	_, err := DecodeWAV([]byte("ID3\x04\x00\x00not a wav file"))
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestPreprocessReportsAppliedOperations(t *testing.T) {
+	// 1s of silence, 0.5s of quiet tone, 1s of silence at 8kHz stereo.
+	frames := make([][2]int16, 0, 20000)
+	for i := 0; i < 8000; i++ {
+		frames = append(frames, [2]int16{0, 0})
+	}
+	for i := 0; i < 4000; i++ {
+		v := int16(1000)
+		if i%2 == 0 {
+			v = -1000
+		}
+		frames = append(frames, [2]int16{v, v})
+	}
+	for i := 0; i < 8000; i++ {
+		frames = append(frames, [2]int16{0, 0})
+	}
+
+	in := stereo16(frames)
+	in.SampleRate = 8000
+
+	res, err := Preprocess(in.Encode(), PreprocessOptions{
+		TrimSilence: true,
+		Normalize:   true,
+		Downmix:     true,
+		SampleRate:  16000,
+	})
+	if err != nil {
+		t.Fatalf("Preprocess() error = %v", err)
+	}
+	out, applied := res.Audio, res.Applied
+	want := []string{"downmix", "resample:16000", "trim_silence", "normalize"}
+	if len(applied) != len(want) {
+		t.Fatalf("unexpected operations: %v", applied)
+	}
+	for i := range want {
+		if applied[i] != want[i] {
+			t.Fatalf("unexpected operations: %v", applied)
+		}
+	}
+
+	wav, err := DecodeWAV(out)
+	if err != nil {
+		t.Fatalf("DecodeWAV() error = %v", err)
+	}
+	if wav.Channels != 1 || wav.SampleRate != 16000 {
+		t.Fatalf("unexpected output format: %+v", wav)
+	}
+	// 0.5s of tone plus 100ms padding on either side.
+	if got := wav.Frames(); got < 11000 || got > 11400 {
+		t.Fatalf("unexpected trimmed length: %d frames", got)
+	}
+}
+
+func TestPreprocessSkipsNoOps(t *testing.T) {
+	mono := &WAV{Format: wavFormatPCM, Channels: 1, SampleRate: 16000, BitsPerSample: 16, Data: make([]byte, 32)}
+
+	res, err := Preprocess(mono.Encode(), PreprocessOptions{Downmix: true, SampleRate: 16000})
+	if err != nil {
+		t.Fatalf("Preprocess() error = %v", err)
+	}
+	if len(res.Applied) != 0 || res.Timeline != nil {
+		t.Fatalf("expected no operations, got %v", res.Applied)
+	}
+}
+
+func TestPreprocessShortensLongPauses(t *testing.T) {
+	// Two 0.5s tones split by 3s of silence at 8kHz mono.
+	tone := func(n int) []float64 {
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = 0.1
+			if i%2 == 0 {
+				out[i] = -0.1
+			}
+		}
+		return out
+	}
+	samples := append(tone(4000), make([]float64, 24000)...)
+	samples = append(samples, tone(4000)...)
+	in := FromSamples([][]float64{samples}, 8000)
+
+	res, err := Preprocess(in.Encode(), PreprocessOptions{TrimSilence: true})
+	if err != nil {
+		t.Fatalf("Preprocess() error = %v", err)
+	}
+	if len(res.Applied) != 1 || res.Applied[0] != "trim_silence" {
+		t.Fatalf("unexpected operations: %v", res.Applied)
+	}
+	wav, err := DecodeWAV(res.Audio)
+	if err != nil {
+		t.Fatalf("DecodeWAV() error = %v", err)
+	}
+	// Both tones and a 600ms pause.
+	if got := wav.Frames(); got != 8000+4800 {
+		t.Fatalf("unexpected trimmed length: %d frames", got)
+	}
+	// The second tone starts at 1.1s in the trimmed audio and at 3.5s in
+	// the input.
+	want := Timeline{{Start: 0, End: 800 * time.Millisecond}, {Start: 3200 * time.Millisecond, End: 4 * time.Second}}
+	if !slices.Equal(res.Timeline, want) {
+		t.Fatalf("timeline = %v, want %v", res.Timeline, want)
+	}
+	if got := res.Timeline.Original(1100 * time.Millisecond); got != 3500*time.Millisecond {
+		t.Fatalf("Original(1.1s) = %v", got)
+	}
+	// A pause at most silenceMaxGap long is left alone.
+	short := append(tone(4000), make([]float64, 4000)...)
+	short = append(short, tone(4000)...)
+	res, err = Preprocess(FromSamples([][]float64{short}, 8000).Encode(), PreprocessOptions{TrimSilence: true})
+	if err != nil {
+		t.Fatalf("Preprocess() error = %v", err)
+	}
+	if len(res.Applied) != 0 || res.Timeline != nil {
+		t.Fatalf("expected no operations, got %v", res.Applied)
+	}
+}
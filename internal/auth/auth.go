@@ -0,0 +1,264 @@
+// Package auth resolves bearer tokens to identities. Tokens listed in the
+// tokens file are issued by EchoFlow and use the server-side upstream key;
+// any other token is a caller's own upstream key (BYOT).
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"echoflow/internal/transcription"
+)
+
+// Scopes gate which API groups a token may call.
+const (
+	ScopeTranscribe  = "transcribe"
+	ScopePostProcess = "post_process"
+	ScopePipeline    = "pipeline"
+	ScopeJobs        = "jobs"
+	// ScopeDebug adds the X-EchoFlow-Debug header to responses. It is not
+	// part of AllScopes, so tokens only get it by listing it.
+	ScopeDebug = "debug"
+	// ScopeAcronymsWrite allows changing the tenant's acronym dictionary,
+	// which every token of the tenant applies. Like ScopeDebug it must be
+	// listed.
+	ScopeAcronymsWrite = "acronyms_write"
+)
+
+var AllScopes = []string{ScopeTranscribe, ScopePostProcess, ScopePipeline, ScopeJobs}
+
+// optInScopes are valid in a token's scopes but never granted by default.
+var optInScopes = []string{ScopeDebug, ScopeAcronymsWrite}
+
+// Identity kinds.
+const (
+	KindToken     = "token"
+	KindBYOT      = "byot"
+	KindAnonymous = "anonymous"
+)
+
+type Identity struct {
+	Kind   string
+	Name   string
+	Tenant string
+	Scopes []string
+	Tier   string
+	// DailyRequestQuota limits billable requests per UTC day; 0 is unlimited.
+	DailyRequestQuota int
+	// Languages is the tenant's language allowlist, if it has one.
+	Languages transcription.LanguagePolicy
+	// UpstreamBaseURLs are the patterns a requested upstream base URL must
+	// match; see CheckUpstreamBaseURL.
+	UpstreamBaseURLs []string
+}
+
+func (id Identity) HasScope(scope string) bool {
+	return slices.Contains(id.Scopes, scope)
+}
+
+var (
+	ErrInvalidUpstreamBaseURL    = errors.New("upstream base URL must be an absolute http or https URL without credentials, query or fragment")
+	ErrUpstreamBaseURLNotAllowed = errors.New("token may not choose this upstream base URL")
+)
+
+// CheckUpstreamBaseURL validates a base URL the caller asked its upstream
+// calls to go to, and returns it without a trailing slash. Only EchoFlow
+// tokens may choose one, and it must match one of the token's
+// upstream_base_urls patterns, where * stands for any run of characters
+// other than "/".
+func (id Identity) CheckUpstreamBaseURL(raw string) (string, error) {
+	raw = strings.TrimRight(strings.TrimSpace(raw), "/")
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", ErrInvalidUpstreamBaseURL
+	}
+	if id.Kind == KindToken {
+		for _, pattern := range id.UpstreamBaseURLs {
+			if ok, _ := path.Match(pattern, raw); ok {
+				return raw, nil
+			}
+		}
+	}
+	return "", ErrUpstreamBaseURLNotAllowed
+}
+
+type tokenEntry struct {
+	Name              string   `json:"name"`
+	SHA256            string   `json:"sha256"`
+	Tenant            string   `json:"tenant,omitempty"`
+	Scopes            []string `json:"scopes,omitempty"`
+	Tier              string   `json:"tier,omitempty"`
+	DailyRequestQuota int      `json:"daily_request_quota,omitempty"`
+	UpstreamBaseURLs  []string `json:"upstream_base_urls,omitempty"`
+}
+
+type tenantEntry struct {
+	Name             string   `json:"name"`
+	AllowedLanguages []string `json:"allowed_languages,omitempty"`
+	// LanguagePolicy is "reject" (default) or "warn".
+	LanguagePolicy string `json:"language_policy,omitempty"`
+}
+
+// Registry maps token digests to identities, so raw tokens never sit on disk.
+type Registry struct {
+	byDigest map[string]Identity
+}
+
+// LoadRegistry reads a tokens file:
+//
+//	{"tokens": [{"name": "acme-web", "sha256": "<hex>", "tenant": "acme", "scopes": ["pipeline"], "tier": "premium", "daily_request_quota": 1000}],
+//	 "tenants": [{"name": "acme", "allowed_languages": ["en", "de"], "language_policy": "reject"}]}
+//
+// Omitted scopes grant all of them; the tier defaults to "standard". Names
+// must be unique since quotas are counted per name. Tenant settings apply to
+// every token naming that tenant.
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Tokens  []tokenEntry  `json:"tokens"`
+		Tenants []tenantEntry `json:"tenants"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse tokens file: %w", err)
+	}
+
+	policies, err := tenantPolicies(file.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	r := &Registry{byDigest: make(map[string]Identity, len(file.Tokens))}
+	names := map[string]bool{}
+	for i, entry := range file.Tokens {
+		digest := strings.ToLower(strings.TrimSpace(entry.SHA256))
+		if entry.Name == "" || len(digest) != sha256.Size*2 {
+			return nil, fmt.Errorf("tokens[%d]: name and a hex sha256 are required", i)
+		}
+		if _, dup := r.byDigest[digest]; dup || names[entry.Name] {
+			return nil, fmt.Errorf("tokens[%d] (%s): duplicate name or sha256", i, entry.Name)
+		}
+		names[entry.Name] = true
+		scopes := entry.Scopes
+		if len(scopes) == 0 {
+			scopes = AllScopes
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(AllScopes, scope) && !slices.Contains(optInScopes, scope) {
+				return nil, fmt.Errorf("tokens[%d] (%s): unknown scope %q", i, entry.Name, scope)
+			}
+		}
+		switch entry.Tier {
+		case "":
+			entry.Tier = transcription.TierStandard
+		case transcription.TierStandard, transcription.TierPremium:
+		default:
+			return nil, fmt.Errorf("tokens[%d] (%s): unknown tier %q", i, entry.Name, entry.Tier)
+		}
+		if entry.DailyRequestQuota < 0 {
+			return nil, fmt.Errorf("tokens[%d] (%s): daily_request_quota must be >= 0", i, entry.Name)
+		}
+		for _, pattern := range entry.UpstreamBaseURLs {
+			if !validBaseURLPattern(pattern) {
+				return nil, fmt.Errorf("tokens[%d] (%s): upstream_base_urls entry %q must be an http or https URL pattern", i, entry.Name, pattern)
+			}
+		}
+		r.byDigest[digest] = Identity{
+			Kind:              KindToken,
+			Name:              entry.Name,
+			Tenant:            entry.Tenant,
+			Scopes:            scopes,
+			Tier:              entry.Tier,
+			DailyRequestQuota: entry.DailyRequestQuota,
+			Languages:         policies[entry.Tenant],
+			UpstreamBaseURLs:  entry.UpstreamBaseURLs,
+		}
+	}
+	return r, nil
+}
+
+func validBaseURLPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil && (strings.HasPrefix(pattern, "https://") || strings.HasPrefix(pattern, "http://"))
+}
+
+func tenantPolicies(tenants []tenantEntry) (map[string]transcription.LanguagePolicy, error) {
+	policies := make(map[string]transcription.LanguagePolicy, len(tenants))
+	for i, tenant := range tenants {
+		if tenant.Name == "" {
+			return nil, fmt.Errorf("tenants[%d]: name is required", i)
+		}
+		if _, dup := policies[tenant.Name]; dup {
+			return nil, fmt.Errorf("tenants[%d] (%s): duplicate name", i, tenant.Name)
+		}
+		policy := transcription.LanguagePolicy{Reject: true}
+		switch tenant.LanguagePolicy {
+		case "", "reject":
+		case "warn":
+			policy.Reject = false
+		default:
+			return nil, fmt.Errorf("tenants[%d] (%s): language_policy must be reject or warn", i, tenant.Name)
+		}
+		for _, language := range tenant.AllowedLanguages {
+			code := transcription.NormalizeLanguage(language)
+			if code == "" {
+				return nil, fmt.Errorf("tenants[%d] (%s): empty entry in allowed_languages", i, tenant.Name)
+			}
+			policy.Allowed = append(policy.Allowed, code)
+		}
+		policies[tenant.Name] = policy
+	}
+	return policies, nil
+}
+
+func (r *Registry) Lookup(token string) (Identity, bool) {
+	if r == nil || token == "" {
+		return Identity{}, false
+	}
+	sum := sha256.Sum256([]byte(token))
+	id, ok := r.byDigest[hex.EncodeToString(sum[:])]
+	return id, ok
+}
+
+// Resolve returns the identity for a bearer token; an empty token is
+// anonymous (only allowed when a server-side key is configured).
+func (r *Registry) Resolve(token string) Identity {
+	if id, ok := r.Lookup(token); ok {
+		return id
+	}
+	if token == "" {
+		return Identity{Kind: KindAnonymous, Name: KindAnonymous, Scopes: AllScopes, Tier: transcription.TierStandard}
+	}
+	return Identity{Kind: KindBYOT, Name: KindBYOT, Scopes: AllScopes, Tier: transcription.TierStandard}
+}
+
+// Fingerprint is a short, non-secret identifier for a token, for logs and
+// support requests.
+func Fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:6])
+}
+
+type identityContextKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, id)
+}
+
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityContextKey{}).(Identity)
+	return id, ok
+}
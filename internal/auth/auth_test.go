@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func digest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeTokens(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRegistryResolve(t *testing.T) {
+	path := writeTokens(t, `{"tokens":[{"name":"acme-web","sha256":"`+strings.ToUpper(digest("ef_acme"))+`","tenant":"acme","scopes":["pipeline"],"tier":"premium","daily_request_quota":2}]}`)
+	r, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+
+	id := r.Resolve("ef_acme")
+	if id.Kind != KindToken || id.Name != "acme-web" || id.Tenant != "acme" || id.Tier != "premium" {
+		t.Fatalf("identity = %+v", id)
+	}
+	if !id.HasScope(ScopePipeline) || id.HasScope(ScopeTranscribe) {
+		t.Fatalf("scopes = %v", id.Scopes)
+	}
+	if got := r.Resolve("gsk_other"); got.Kind != KindBYOT || !got.HasScope(ScopeJobs) {
+		t.Fatalf("unknown token = %+v, want byot with all scopes", got)
+	}
+	if got := (*Registry)(nil).Resolve(""); got.Kind != KindAnonymous {
+		t.Fatalf("empty token = %+v, want anonymous", got)
+	}
+}
+
+func TestCheckUpstreamBaseURL(t *testing.T) {
+	path := writeTokens(t, `{"tokens":[{"name":"acme","sha256":"`+digest("ef_acme")+`","upstream_base_urls":["https://*.acme.example/openai/v1"]}]}`)
+	r, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+	id := r.Resolve("ef_acme")
+
+	if got, err := id.CheckUpstreamBaseURL("https://eu.acme.example/openai/v1/"); err != nil || got != "https://eu.acme.example/openai/v1" {
+		t.Fatalf("CheckUpstreamBaseURL() = %q, %v", got, err)
+	}
+	for raw, want := range map[string]error{
+		"https://evil.example/openai/v1":             ErrUpstreamBaseURLNotAllowed,
+		"https://a.b.acme.example.evil/openai/v1":    ErrUpstreamBaseURLNotAllowed,
+		"https://eu.acme.example/openai/v1/../admin": ErrUpstreamBaseURLNotAllowed,
+		"https://u:p@eu.acme.example/openai/v1":      ErrInvalidUpstreamBaseURL,
+		"https://eu.acme.example/openai/v1?x=1":      ErrInvalidUpstreamBaseURL,
+		"ftp://eu.acme.example/openai/v1":            ErrInvalidUpstreamBaseURL,
+	} {
+		if _, err := id.CheckUpstreamBaseURL(raw); err != want {
+			t.Errorf("CheckUpstreamBaseURL(%q) error = %v, want %v", raw, err, want)
+		}
+	}
+	if _, err := r.Resolve("gsk_byot").CheckUpstreamBaseURL("https://eu.acme.example/openai/v1"); err != ErrUpstreamBaseURLNotAllowed {
+		t.Fatalf("BYOT token error = %v", err)
+	}
+}
+
+func TestLoadRegistryRejectsInvalidEntries(t *testing.T) {
+	for name, entry := range map[string]string{
+		"short digest":  `{"name":"a","sha256":"abc"}`,
+		"unknown scope": `{"name":"a","sha256":"` + digest("a") + `","scopes":["admin"]}`,
+		"unknown tier":  `{"name":"a","sha256":"` + digest("a") + `","tier":"gold"}`,
+		"unknown field": `{"name":"a","sha256":"` + digest("a") + `","token":"a"}`,
+		"bad pattern":   `{"name":"a","sha256":"` + digest("a") + `","upstream_base_urls":["acme.example/["]}`,
+	} {
+		if _, err := LoadRegistry(writeTokens(t, `{"tokens":[`+entry+`]}`)); err == nil {
+			t.Errorf("%s: LoadRegistry succeeded", name)
+		}
+	}
+	dup := `{"name":"a","sha256":"` + digest("a") + `"},{"name":"a","sha256":"` + digest("b") + `"}`
+	if _, err := LoadRegistry(writeTokens(t, `{"tokens":[`+dup+`]}`)); err == nil {
+		t.Error("duplicate name: LoadRegistry succeeded")
+	}
+}
+
+func TestQuotasResetAtMidnightUTC(t *testing.T) {
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	q := NewQuotas()
+	q.now = func() time.Time { return now }
+	id := Identity{Name: "acme-web", DailyRequestQuota: 2}
+
+	if !q.Take(id) || !q.Take(id) || q.Take(id) {
+		t.Fatal("want exactly two requests admitted")
+	}
+	status, ok := q.Status(id)
+	if !ok || status.Remaining != 0 || !status.ResetsAt.Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("status = %+v, %v", status, ok)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if !q.Take(id) {
+		t.Fatal("quota did not reset on the next day")
+	}
+	if !q.Take(Identity{Name: "unlimited"}) {
+		t.Fatal("identity without quota was rejected")
+	}
+}
+
+func TestRegistryAppliesTenantLanguagePolicy(t *testing.T) {
+	path := writeTokens(t, `{"tokens":[{"name":"acme-web","sha256":"`+digest("ef_acme")+`","tenant":"acme"},{"name":"beta","sha256":"`+digest("ef_beta")+`","tenant":"beta"}],
+		"tenants":[{"name":"acme","allowed_languages":["EN","German","pt-BR"]},{"name":"beta","allowed_languages":["fr"],"language_policy":"warn"}]}`)
+	r, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+	if got := r.Resolve("ef_acme").Languages; !got.Reject || strings.Join(got.Allowed, ",") != "en,de,pt" {
+		t.Fatalf("acme policy = %+v", got)
+	}
+	if got := r.Resolve("ef_beta").Languages; got.Reject || len(got.Allowed) != 1 {
+		t.Fatalf("beta policy = %+v", got)
+	}
+
+	bad := `{"tokens":[],"tenants":[{"name":"acme","allowed_languages":["en"],"language_policy":"block"}]}`
+	if _, err := LoadRegistry(writeTokens(t, bad)); err == nil {
+		t.Fatal("unknown language_policy accepted")
+	}
+}
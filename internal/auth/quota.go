@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Quotas counts requests per token name against DailyRequestQuota. Counts
+// live in memory and reset at midnight UTC, so each replica enforces the
+// quota independently.
+type Quotas struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	day  time.Time
+	used map[string]int
+}
+
+// QuotaStatus is a snapshot of an identity's daily request quota.
+type QuotaStatus struct {
+	Limit     int
+	Used      int
+	Remaining int
+	ResetsAt  time.Time
+}
+
+func NewQuotas() *Quotas {
+	return &Quotas{now: time.Now, used: map[string]int{}}
+}
+
+// Take consumes one request from id's quota and reports whether it was
+// available. Identities without a quota always succeed.
+func (q *Quotas) Take(id Identity) bool {
+	return q.TakeN(id, 1)
+}
+
+// TakeN consumes n requests from id's quota if all n are available, and
+// otherwise none.
+func (q *Quotas) TakeN(id Identity, n int) bool {
+	if q == nil || id.DailyRequestQuota <= 0 {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	if q.used[id.Name]+n > id.DailyRequestQuota {
+		return false
+	}
+	q.used[id.Name] += n
+	return true
+}
+
+// Status returns id's quota usage, or false when id has no quota.
+func (q *Quotas) Status(id Identity) (QuotaStatus, bool) {
+	if q == nil || id.DailyRequestQuota <= 0 {
+		return QuotaStatus{}, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	used := q.used[id.Name]
+	return QuotaStatus{
+		Limit:     id.DailyRequestQuota,
+		Used:      used,
+		Remaining: max(id.DailyRequestQuota-used, 0),
+		ResetsAt:  q.day.AddDate(0, 0, 1),
+	}, true
+}
+
+func (q *Quotas) rollover() {
+	day := q.now().UTC().Truncate(24 * time.Hour)
+	if !day.Equal(q.day) {
+		q.day = day
+		clear(q.used)
+	}
+}
@@ -0,0 +1,29 @@
+// Package bufpool recycles byte buffers used to assemble request bodies and
+// encode responses.
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxRetained keeps one oversized upload from pinning its buffer in the pool
+// for the life of the process.
+const maxRetained = 4 << 20
+
+var pool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put returns buf to the pool. The caller must not use buf afterwards.
+func Put(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxRetained {
+		return
+	}
+	buf.Reset()
+	pool.Put(buf)
+}
@@ -0,0 +1,23 @@
+package bufpool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetReturnsEmptyBuffer(t *testing.T) {
+	buf := Get()
+	buf.WriteString("leftover")
+	Put(buf)
+
+	if got := Get(); got.Len() != 0 {
+		t.Fatalf("expected empty buffer, got %q", got.String())
+	}
+}
+
+func TestPutDropsOversizedBuffers(t *testing.T) {
+	Put(bytes.NewBuffer(make([]byte, 0, maxRetained+1)))
+	if got := Get(); got.Cap() > maxRetained {
+		t.Fatalf("oversized buffer was retained: cap=%d", got.Cap())
+	}
+}
@@ -1,57 +1,491 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
 	"strings"
 	"time"
-
-	cenv "github.com/caarlos0/env/v11"
 )
 
 type Config struct {
-	ListenAddr           string
-	UpstreamBaseURL      string
-	UpstreamAPIKey       string
-	TranscriptionModel   string
-	PostProcessModel     string
-	RequestTimeout       time.Duration
-	TranscriptionTimeout time.Duration
-	PostProcessTimeout   time.Duration
-	MaxUploadBytes       int64
-	LogLevel             string
+	ListenAddr               string
+	GRPCListenAddr           string
+	UpstreamProvider         string
+	AzureOpenAIAPIVersion    string
+	UpstreamBaseURL          string
+	UpstreamRegionalBaseURLs []string
+	UpstreamProbeInterval    time.Duration
+	UpstreamAPIKey           string
+	UpstreamFailoverBaseURLs []string
+	UpstreamFailoverAPIKeys  []string
+	UpstreamFailoverTimeout  time.Duration
+	UpstreamRetryMaxAttempts int
+	UpstreamRetryBaseDelay   time.Duration
+	UpstreamRetryMaxDelay    time.Duration
+	UpstreamTLSCertFile      string
+	UpstreamTLSKeyFile       string
+	UpstreamTLSCAFile        string
+	TranscriptionBaseURL     string
+	TranscriptionAPIKey      string
+	PostProcessBaseURL       string
+	PostProcessAPIKey        string
+	TranscriptionModel       string
+	// TranscriptionLanguage is sent upstream for requests without a language.
+	TranscriptionLanguage string
+	// UploadDefaultFileName names uploads whose format cannot be told from
+	// their name, content type or contents.
+	UploadDefaultFileName       string
+	PostProcessModel            string
+	PostProcessLogitBias        bool
+	PostProcessLogitBiasValue   int
+	PostProcessTokenizeURL      string
+	PostProcessTokenizeAPIKey   string
+	PostProcessOutputTag        string
+	PostProcessContextMaxTokens int
+	PostProcessSummaryModel     string
+	PostProcessSmallModel       string
+	PostProcessMaxDrift         float64
+	PostProcessStopSequences    []string
+	PostProcessSpellings        bool
+	RequestTimeout              time.Duration
+	TranscriptionTimeout        time.Duration
+	TranscriptionTimeoutPerMB   time.Duration
+	TranscriptionMaxTimeout     time.Duration
+	MaxRequestTimeout           time.Duration
+	CostPerAudioMinute          float64
+	CostPer1KTokens             float64
+	TranscriptionHedgeDelay     time.Duration
+	TranscriptionHedgeMaxBytes  int64
+	TranscriptionChunkMaxBytes  int64
+	TranscriptionChunkLength    time.Duration
+	TranscriptionChunkOverlap   time.Duration
+	TranscriptionChunkWorkers   int
+	TranscriptionDedupe         bool
+	MaxConcurrentTranscriptions int
+	MaxConcurrentCompletions    int
+	PostProcessTimeout          time.Duration
+	UploadReadTimeout           time.Duration
+	ShutdownDrainTimeout        time.Duration
+	MaxUploadBytes              int64
+	MaxAudioDuration            time.Duration
+	FFmpegPath                  string
+	StrictFormFields            bool
+	LogLevel                    string
+	Environment                 string
+	ChaosEnabled                bool
+	ChaosLatency                time.Duration
+	ChaosLatencyRate            float64
+	ChaosRateLimitRate          float64
+	ChaosTruncateRate           float64
+	ChaosMalformedJSONRate      float64
+	MemoryLimitBytes            int64
+	MemoryLimitRatio            float64
+	GCPercent                   int
+	MemoryWatchdogThreshold     float64
+	MemoryWatchdogInterval      time.Duration
+	MemoryProfileDir            string
+	SignalDumps                 bool
+	SignalDumpDir               string
+	KeepWarmInterval            time.Duration
+	KeepWarmModel               string
+	KeepWarmModelInterval       time.Duration
+	HealthProbeInterval         time.Duration
+	HealthProbeModel            string
+	JobStore                    string
+	JobSQLitePath               string
+	JobSQLiteAutoMigrate        bool
+	RedisURL                    string
+	ResultCache                 string
+	ResultCacheTTL              time.Duration
+	ResultCacheMaxEntries       int
+	HedgeBaseURL                string
+	HedgeAPIKey                 string
+	HedgeTranscriptionModel     string
+	HedgeProviderName           string
+	DeepgramAPIKey              string
+	DeepgramBaseURL             string
+	DeepgramModel               string
+	AssemblyAIAPIKey            string
+	AssemblyAIBaseURL           string
+	AssemblyAIModel             string
+	AnthropicAPIKey             string
+	AnthropicBaseURL            string
+	AnthropicModels             []string
+	// ModelAllowlist holds path.Match patterns for models clients may
+	// request; empty allows any well-formed name.
+	ModelAllowlist             []string
+	AnthropicMaxTokens         int
+	LocalWhisperAPI            string
+	LocalWhisperServers        []LocalWhisperServer
+	LocalWhisperAPIKey         string
+	LocalWhisperPrimary        bool
+	PremiumTokenSHA256         []string
+	RoutingRulesPath           string
+	RoutingRulesReloadInterval time.Duration
+	WebhookSecret              string
+	WebhookMaxAttempts         int
+	WebhookTimeout             time.Duration
+	WebhookAllowPrivate        bool
+	AdminToken                 string
+	AuthTokensPath             string
+	AcronymsPath               string
+	LegacyClientsPath          string
+	UpstreamKeyRotationGrace   time.Duration
+	JobWorkers                 int
+	JobQueueSize               int
+	JobAdmissionSLA            time.Duration
+	JobResultTTL               time.Duration
+	JobJournalDir              string
+	JobJournalMaxAttempts      int
+	BatchMaxFiles              int
+	BatchConcurrency           int
+	PipelineStageConcurrency   int
+	PipelineFallbackPolicy     string
+	// PipelineVocabularyPrompt sends custom vocabulary to the transcription
+	// upstream as its prompt when a request has no transcription_prompt.
+	PipelineVocabularyPrompt bool
+	PipelineSummaryModel     string
+	ChaptersModel            string
+	ReadyMaxQueueDepth       int
+	ReadyMaxInFlight         int
+	ShedMaxQueueDepth        int
+	ShedMaxInFlight          int
+	// MultipartMaxParsers bounds how many requests may parse (and spool to
+	// disk) a multipart body at once; 0 means no limit.
+	MultipartMaxParsers    int
+	MultipartParserWait    time.Duration
+	ReadyStoreTimeout      time.Duration
+	AudioFetchTimeout      time.Duration
+	AudioFetchAllowPrivate bool
+	S3Region               string
+	S3Endpoint             string
+	S3AccessKeyID          string
+	S3SecretAccessKey      string
+	S3SessionToken         string
+	S3Buckets              []string
+	GCSHMACAccessID        string
+	GCSHMACSecret          string
+	GCSBuckets             []string
 }
 
+// envConfig declares every environment variable Load reads; its tags make up
+// the Option registry.
 type envConfig struct {
-	ListenAddr                  string `env:"LISTEN_ADDR" envDefault:":8080"`
-	UpstreamBaseURL             string `env:"UPSTREAM_BASE_URL" envDefault:"https://api.groq.com/openai/v1"`
-	UpstreamAPIKey              string `env:"UPSTREAM_API_KEY"`
-	TranscriptionModel          string `env:"TRANSCRIPTION_MODEL" envDefault:"whisper-large-v3"`
-	PostProcessModel            string `env:"POSTPROCESS_MODEL" envDefault:"meta-llama/llama-4-scout-17b-16e-instruct"`
-	RequestTimeoutSeconds       int    `env:"REQUEST_TIMEOUT_SECONDS" envDefault:"25"`
-	TranscriptionTimeoutSeconds int    `env:"TRANSCRIPTION_TIMEOUT_SECONDS" envDefault:"20"`
-	PostProcessTimeoutSeconds   int    `env:"POSTPROCESS_TIMEOUT_SECONDS" envDefault:"20"`
-	MaxUploadBytes              int64  `env:"MAX_UPLOAD_BYTES" envDefault:"26214400"`
-	LogLevel                    string `env:"LOG_LEVEL" envDefault:"info"`
+	ListenAddr                  string        `env:"LISTEN_ADDR" envDefault:":8080" desc:"Address the HTTP API listens on."`
+	GRPCListenAddr              string        `env:"GRPC_LISTEN_ADDR" desc:"Address the gRPC API listens on; empty disables it."`
+	UpstreamProvider            string        `env:"UPSTREAM_PROVIDER" envDefault:"openai" desc:"Upstream vendor: openai for any OpenAI-compatible API, or azure for Azure OpenAI."`
+	AzureOpenAIAPIVersion       string        `env:"AZURE_OPENAI_API_VERSION" envDefault:"2024-10-21" desc:"api-version sent to Azure OpenAI."`
+	UpstreamBaseURL             string        `env:"UPSTREAM_BASE_URL" envDefault:"https://api.groq.com/openai/v1" desc:"Base URL of the upstream API, or the Azure resource endpoint."`
+	UpstreamRegionalBaseURLs    []string      `env:"UPSTREAM_REGIONAL_BASE_URLS" envSeparator:"," desc:"Equivalent upstreams, e.g. other regions; new requests go to the fastest healthy one."`
+	UpstreamProbeIntervalSecs   int           `env:"UPSTREAM_PROBE_INTERVAL_SECONDS" envDefault:"30" desc:"How often regional upstreams are probed, in seconds."`
+	UpstreamAPIKey              string        `env:"UPSTREAM_API_KEY" desc:"Server-side fallback token; empty requires callers to send their own."`
+	UpstreamFailoverBaseURLs    []string      `env:"UPSTREAM_FAILOVER_BASE_URLS" envSeparator:"," desc:"Ordered backups tried when an upstream returns 5xx, times out or is unreachable."`
+	UpstreamFailoverAPIKeys     []string      `env:"UPSTREAM_FAILOVER_API_KEYS" envSeparator:"," desc:"One key per UPSTREAM_FAILOVER_BASE_URLS entry."`
+	UpstreamFailoverTimeoutSecs int           `env:"UPSTREAM_FAILOVER_ATTEMPT_TIMEOUT_SECONDS" envDefault:"0" desc:"Timeout of every failover attempt but the last; 0 disables it."`
+	UpstreamRetryMaxAttempts    int           `env:"UPSTREAM_RETRY_MAX_ATTEMPTS" envDefault:"3" desc:"Attempts per upstream call, retrying 429, 5xx and transport errors."`
+	UpstreamRetryBaseDelayMS    int           `env:"UPSTREAM_RETRY_BASE_DELAY_MS" envDefault:"250" desc:"First retry delay, doubled on each attempt."`
+	UpstreamRetryMaxDelaySecs   int           `env:"UPSTREAM_RETRY_MAX_DELAY_SECONDS" envDefault:"5" desc:"Longest retry delay, including Retry-After."`
+	UpstreamTLSCertFile         string        `env:"UPSTREAM_TLS_CERT_FILE" desc:"PEM client certificate for upstreams that require mutual TLS."`
+	UpstreamTLSKeyFile          string        `env:"UPSTREAM_TLS_KEY_FILE" desc:"PEM key for UPSTREAM_TLS_CERT_FILE."`
+	UpstreamTLSCAFile           string        `env:"UPSTREAM_TLS_CA_FILE" desc:"PEM CA bundle added to the system roots for upstreams."`
+	TranscriptionBaseURL        string        `env:"TRANSCRIPTION_BASE_URL" desc:"Upstream for transcription; empty inherits UPSTREAM_BASE_URL."`
+	TranscriptionAPIKey         string        `env:"TRANSCRIPTION_API_KEY" desc:"Key for TRANSCRIPTION_BASE_URL; empty inherits UPSTREAM_API_KEY."`
+	PostProcessBaseURL          string        `env:"POSTPROCESS_BASE_URL" desc:"Upstream for post-processing; empty inherits UPSTREAM_BASE_URL."`
+	PostProcessAPIKey           string        `env:"POSTPROCESS_API_KEY" desc:"Key for POSTPROCESS_BASE_URL; empty inherits UPSTREAM_API_KEY."`
+	TranscriptionModel          string        `env:"TRANSCRIPTION_MODEL" envDefault:"whisper-large-v3" desc:"Default transcription model."`
+	TranscriptionLanguage       string        `env:"TRANSCRIPTION_LANGUAGE" desc:"ISO 639-1 language hint for requests without one; empty lets the upstream detect it."`
+	UploadDefaultFileName       string        `env:"UPLOAD_DEFAULT_FILENAME" envDefault:"audio.wav" desc:"Name for uploads whose format cannot be told from their content type or header."`
+	PostProcessModel            string        `env:"POSTPROCESS_MODEL" envDefault:"meta-llama/llama-4-scout-17b-16e-instruct" desc:"Default post-processing model."`
+	PostProcessLogitBias        bool          `env:"POSTPROCESS_LOGIT_BIAS" envDefault:"false" desc:"Turn custom_vocabulary into logit_bias on post-processing requests."`
+	PostProcessLogitBiasValue   int           `env:"POSTPROCESS_LOGIT_BIAS_VALUE" envDefault:"5" desc:"Bias given to each vocabulary token, 1-100."`
+	PostProcessTokenizeURL      string        `env:"POSTPROCESS_TOKENIZE_URL" desc:"/tokenize endpoint for the post-processing model, needed by POSTPROCESS_LOGIT_BIAS."`
+	PostProcessTokenizeAPIKey   string        `env:"POSTPROCESS_TOKENIZE_API_KEY" desc:"Bearer token for POSTPROCESS_TOKENIZE_URL; empty sends none."`
+	PostProcessOutputTag        string        `env:"POSTPROCESS_OUTPUT_TAG" envDefault:"transcript" desc:"Tag the post-processing model writes its answer in; none keeps the whole output."`
+	PostProcessContextMaxTokens int           `env:"POSTPROCESS_CONTEXT_MAX_TOKENS" envDefault:"1500" desc:"context_summary above this many estimated tokens is condensed first; 0 disables."`
+	PostProcessSummaryModel     string        `env:"POSTPROCESS_SUMMARY_MODEL" envDefault:"llama-3.1-8b-instant" desc:"Model that condenses long context_summary values."`
+	PostProcessSmallModel       string        `env:"POSTPROCESS_SMALL_MODEL" desc:"Cheaper model tried before POSTPROCESS_MODEL; empty disables escalation."`
+	PostProcessMaxDrift         float64       `env:"POSTPROCESS_ESCALATION_MAX_DRIFT" envDefault:"0.3" desc:"Share of unseen words above which POSTPROCESS_SMALL_MODEL output is escalated."`
+	PostProcessStopSequences    []string      `env:"POSTPROCESS_STOP_SEQUENCES" envSeparator:"|" desc:"Extra stop sequences; \\n means a newline."`
+	PostProcessSpellings        bool          `env:"POSTPROCESS_COLLAPSE_SPELLINGS" envDefault:"false" desc:"Collapse dictated spellings into words before post-processing by default."`
+	RequestTimeoutSeconds       int           `env:"REQUEST_TIMEOUT_SECONDS" envDefault:"25" desc:"Timeout of a whole request."`
+	TranscriptionTimeoutSeconds int           `env:"TRANSCRIPTION_TIMEOUT_SECONDS" envDefault:"20" desc:"Base transcription timeout."`
+	TranscriptionPerMBSeconds   int           `env:"TRANSCRIPTION_TIMEOUT_PER_MB_SECONDS" envDefault:"2" desc:"Extra transcription timeout per MiB of audio."`
+	TranscriptionMaxSeconds     int           `env:"TRANSCRIPTION_MAX_TIMEOUT_SECONDS" envDefault:"120" desc:"Cap on the transcription timeout."`
+	MaxRequestTimeoutMS         int           `env:"MAX_REQUEST_TIMEOUT_MS" envDefault:"300000" desc:"Cap on the per-request timeout_ms override."`
+	CostPerAudioMinute          float64       `env:"COST_PER_AUDIO_MINUTE" desc:"Price per audio minute for X-EchoFlow-Estimated-Cost; unset leaves the header out."`
+	CostPer1KTokens             float64       `env:"COST_PER_1K_TOKENS" desc:"Price per 1000 tokens for X-EchoFlow-Estimated-Cost."`
+	TranscriptionHedgeDelayMS   int           `env:"TRANSCRIPTION_HEDGE_DELAY_MS" envDefault:"0" desc:"Send a second transcription request when the first has not answered after this long; 0 disables."`
+	TranscriptionHedgeMaxBytes  int64         `env:"TRANSCRIPTION_HEDGE_MAX_BYTES" envDefault:"2097152" desc:"Largest upload hedged; 0 hedges any size."`
+	TranscriptionChunkMaxBytes  int64         `env:"TRANSCRIPTION_CHUNK_MAX_BYTES" envDefault:"0" desc:"Split uploads over this size into chunks; 0 disables the limit."`
+	TranscriptionChunkSeconds   int           `env:"TRANSCRIPTION_CHUNK_SECONDS" envDefault:"0" desc:"Split audio longer than this into chunks; 0 disables the limit."`
+	TranscriptionChunkOverlapMS int           `env:"TRANSCRIPTION_CHUNK_OVERLAP_MS" envDefault:"2000" desc:"Audio each chunk repeats from the one before."`
+	TranscriptionChunkWorkers   int           `env:"TRANSCRIPTION_CHUNK_PARALLELISM" envDefault:"4" desc:"Chunks of one upload transcribed at once."`
+	TranscriptionDedupe         bool          `env:"TRANSCRIPTION_DEDUPE" envDefault:"true" desc:"Collapse identical concurrent transcription requests into one upstream call."`
+	MaxConcurrentTranscriptions int           `env:"MAX_CONCURRENT_TRANSCRIPTIONS" envDefault:"0" desc:"Concurrent transcription calls per replica; 0 means no limit."`
+	MaxConcurrentCompletions    int           `env:"MAX_CONCURRENT_COMPLETIONS" envDefault:"0" desc:"Concurrent chat completion calls per replica; 0 means no limit."`
+	PostProcessTimeoutSeconds   int           `env:"POSTPROCESS_TIMEOUT_SECONDS" envDefault:"20" desc:"Post-processing timeout."`
+	UploadReadTimeoutSeconds    int           `env:"UPLOAD_READ_TIMEOUT_SECONDS" envDefault:"60" desc:"Time allowed to receive a request body."`
+	ShutdownDrainSeconds        int           `env:"SHUTDOWN_DRAIN_SECONDS" envDefault:"30" desc:"How long running requests may finish after SIGTERM."`
+	MaxUploadBytes              int64         `env:"MAX_UPLOAD_BYTES" envDefault:"26214400" desc:"Largest upload accepted."`
+	MaxAudioSeconds             int           `env:"MAX_AUDIO_SECONDS" envDefault:"0" desc:"Longest audio accepted, read from its headers; 0 disables the limit."`
+	FFmpegPath                  string        `env:"FFMPEG_PATH" desc:"ffmpeg binary for transcoding; empty disables transcoding."`
+	StrictFormFields            bool          `env:"STRICT_FORM_FIELDS" envDefault:"false" desc:"Reject multipart fields an endpoint doesn't read."`
+	LogLevel                    string        `env:"LOG_LEVEL" envDefault:"info" desc:"debug, info, warn or error."`
+	Environment                 string        `env:"APP_ENV" envDefault:"development" desc:"Deployment environment; production disables unsafe options."`
+	ChaosEnabled                bool          `env:"CHAOS_ENABLED" envDefault:"false" desc:"Inject faults into upstream calls; refused in production."`
+	ChaosLatencyMS              int           `env:"CHAOS_LATENCY_MS" envDefault:"2000" desc:"Latency added by chaos."`
+	ChaosLatencyRate            float64       `env:"CHAOS_LATENCY_RATE" envDefault:"0" desc:"Share of upstream calls delayed, 0-1."`
+	ChaosRateLimitRate          float64       `env:"CHAOS_429_RATE" envDefault:"0" desc:"Share of upstream calls answered with 429, 0-1."`
+	ChaosTruncateRate           float64       `env:"CHAOS_TRUNCATE_RATE" envDefault:"0" desc:"Share of upstream responses truncated, 0-1."`
+	ChaosMalformedJSONRate      float64       `env:"CHAOS_MALFORMED_JSON_RATE" envDefault:"0" desc:"Share of upstream responses replaced with malformed JSON, 0-1."`
+	MemoryLimitBytes            int64         `env:"MEMORY_LIMIT_BYTES" envDefault:"0" desc:"Container memory limit; 0 detects it from the cgroup."`
+	MemoryLimitRatio            float64       `env:"MEMORY_LIMIT_RATIO" envDefault:"0.9" desc:"GOMEMLIMIT as a share of the memory limit."`
+	GCPercent                   int           `env:"GC_PERCENT" envDefault:"0" desc:"Overrides GOGC when above 0."`
+	MemoryWatchdogThreshold     float64       `env:"MEMORY_WATCHDOG_THRESHOLD" envDefault:"0.85" desc:"Share of the memory limit at which a heap profile is written."`
+	MemoryWatchdogIntervalSecs  int           `env:"MEMORY_WATCHDOG_INTERVAL_SECONDS" envDefault:"5" desc:"How often the memory watchdog checks RSS."`
+	MemoryProfileDir            string        `env:"MEMORY_PROFILE_DIR" desc:"Where watchdog heap profiles go; empty uses the temp dir."`
+	SignalDumps                 bool          `env:"SIGNAL_DUMPS" envDefault:"true" desc:"Dump goroutines on SIGQUIT and the heap on SIGUSR1 without exiting."`
+	SignalDumpDir               string        `env:"SIGNAL_DUMP_DIR" desc:"Where signal dumps go; empty writes goroutines to stderr."`
+	KeepWarmIntervalSeconds     int           `env:"UPSTREAM_KEEPWARM_INTERVAL_SECONDS" envDefault:"0" desc:"Ping the upstream this often to keep connections warm; 0 disables."`
+	KeepWarmModel               string        `env:"UPSTREAM_KEEPWARM_MODEL" desc:"Model kept warm with one-token chat completions."`
+	KeepWarmModelIntervalSecs   int           `env:"UPSTREAM_KEEPWARM_MODEL_INTERVAL_SECONDS" envDefault:"300" desc:"How often UPSTREAM_KEEPWARM_MODEL is called."`
+	HealthProbeIntervalSecs     int           `env:"UPSTREAM_HEALTH_PROBE_INTERVAL_SECONDS" envDefault:"0" desc:"Check the upstream this often for /readyz; 0 checks on every call."`
+	HealthProbeModel            string        `env:"UPSTREAM_HEALTH_PROBE_MODEL" desc:"Model given a one-token chat completion per health check."`
+	JobStore                    string        `env:"JOB_STORE" envDefault:"memory" desc:"Async job persistence: memory, sqlite or redis."`
+	JobSQLitePath               string        `env:"JOB_SQLITE_PATH" envDefault:"echoflow-jobs.db" desc:"SQLite database for JOB_STORE=sqlite."`
+	JobSQLiteAutoMigrate        bool          `env:"JOB_SQLITE_AUTO_MIGRATE" envDefault:"true" desc:"Apply pending SQLite migrations at startup."`
+	RedisURL                    string        `env:"REDIS_URL" desc:"Redis for JOB_STORE=redis and RESULT_CACHE=redis."`
+	ResultCache                 string        `env:"RESULT_CACHE" envDefault:"off" desc:"Pipeline result cache: off, memory or redis."`
+	ResultCacheTTL              time.Duration `env:"RESULT_CACHE_TTL" envDefault:"1h" desc:"How long cached results are kept."`
+	ResultCacheMaxEntries       int           `env:"RESULT_CACHE_MAX_ENTRIES" envDefault:"1000" desc:"Entries kept by RESULT_CACHE=memory."`
+	HedgeBaseURL                string        `env:"HEDGE_BASE_URL" desc:"Second provider raced against the primary for premium tokens."`
+	HedgeAPIKey                 string        `env:"HEDGE_API_KEY" desc:"Key for HEDGE_BASE_URL."`
+	HedgeTranscriptionModel     string        `env:"HEDGE_TRANSCRIPTION_MODEL" envDefault:"whisper-1" desc:"Transcription model of the hedge provider."`
+	HedgeProviderName           string        `env:"HEDGE_PROVIDER_NAME" envDefault:"secondary" desc:"Name of the hedge provider in routing rules."`
+	DeepgramAPIKey              string        `env:"DEEPGRAM_API_KEY" desc:"Enables Deepgram as routing provider deepgram."`
+	DeepgramBaseURL             string        `env:"DEEPGRAM_BASE_URL" envDefault:"https://api.deepgram.com/v1" desc:"Deepgram API base URL."`
+	DeepgramModel               string        `env:"DEEPGRAM_MODEL" envDefault:"nova-2" desc:"Deepgram model."`
+	AssemblyAIAPIKey            string        `env:"ASSEMBLYAI_API_KEY" desc:"Enables AssemblyAI as routing provider assemblyai."`
+	AssemblyAIBaseURL           string        `env:"ASSEMBLYAI_BASE_URL" envDefault:"https://api.assemblyai.com/v2" desc:"AssemblyAI API base URL."`
+	AssemblyAIModel             string        `env:"ASSEMBLYAI_MODEL" envDefault:"best" desc:"AssemblyAI speech model."`
+	AnthropicAPIKey             string        `env:"ANTHROPIC_API_KEY" desc:"Enables Anthropic for post-processing models matching ANTHROPIC_MODELS."`
+	AnthropicBaseURL            string        `env:"ANTHROPIC_BASE_URL" envDefault:"https://api.anthropic.com/v1" desc:"Anthropic API base URL."`
+	AnthropicModels             []string      `env:"ANTHROPIC_MODELS" envSeparator:"," envDefault:"claude-*" desc:"path.Match patterns of models sent to Anthropic."`
+	ModelAllowlist              []string      `env:"MODEL_ALLOWLIST" envSeparator:"," desc:"path.Match patterns of models clients may request; empty allows any well-formed name."`
+	AnthropicMaxTokens          int           `env:"ANTHROPIC_MAX_TOKENS" envDefault:"4096" desc:"max_tokens sent to Anthropic."`
+	LocalWhisperAPI             string        `env:"LOCAL_WHISPER_API" envDefault:"openai" desc:"API of self-hosted Whisper servers: openai, whispercpp or asr."`
+	LocalWhisperServers         []string      `env:"LOCAL_WHISPER_SERVERS" envSeparator:"," desc:"Self-hosted Whisper servers as model=url pairs, available as routing provider local."`
+	LocalWhisperAPIKey          string        `env:"LOCAL_WHISPER_API_KEY" desc:"Key for LOCAL_WHISPER_SERVERS."`
+	LocalWhisperPrimary         bool          `env:"LOCAL_WHISPER_PRIMARY" envDefault:"false" desc:"Transcribe everything on LOCAL_WHISPER_SERVERS."`
+	PremiumTokenSHA256          []string      `env:"PREMIUM_TOKEN_SHA256" envSeparator:"," desc:"Hex SHA-256 digests of premium bearer tokens."`
+	RoutingRulesPath            string        `env:"ROUTING_RULES_PATH" desc:"JSON rules choosing provider and model per request."`
+	RoutingRulesReloadSecs      int           `env:"ROUTING_RULES_RELOAD_SECONDS" envDefault:"10" desc:"How often ROUTING_RULES_PATH is checked for changes."`
+	WebhookSecret               string        `env:"WEBHOOK_SECRET" desc:"Signs job callback_url webhooks; callbacks are rejected when empty."`
+	WebhookMaxAttempts          int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"5" desc:"Deliveries tried per webhook."`
+	WebhookTimeoutSecs          int           `env:"WEBHOOK_TIMEOUT_SECONDS" envDefault:"10" desc:"Timeout of one webhook delivery."`
+	WebhookAllowPrivate         bool          `env:"WEBHOOK_ALLOW_PRIVATE" desc:"Allow callback_url on private and loopback addresses; never in production."`
+	AdminToken                  string        `env:"ADMIN_TOKEN" desc:"Bearer token for /admin endpoints; empty disables them."`
+	AuthTokensPath              string        `env:"AUTH_TOKENS_PATH" desc:"JSON file of EchoFlow-issued tokens."`
+	AcronymsPath                string        `env:"ACRONYMS_PATH" desc:"JSON file of tenant acronym dictionaries; empty keeps them in memory."`
+	LegacyClientsPath           string        `env:"LEGACY_CLIENTS_PATH" desc:"JSON table mapping older clients onto the current API."`
+	UpstreamKeyRotationGraceSec int           `env:"UPSTREAM_KEY_ROTATION_GRACE_SECONDS" envDefault:"60" desc:"How long in-flight requests keep the old key after POST /admin/upstream-key."`
+	JobWorkers                  int           `env:"JOB_WORKERS" envDefault:"4" desc:"Async jobs run at once per replica."`
+	JobQueueSize                int           `env:"JOB_QUEUE_SIZE" envDefault:"100" desc:"Jobs that may wait for a worker before POST /v1/jobs returns 503."`
+	JobAdmissionSLASecs         int           `env:"JOB_ADMISSION_SLA_SECONDS" envDefault:"0" desc:"Refuse jobs whose estimated wait and processing exceed this; 0 disables."`
+	JobResultTTL                time.Duration `env:"JOB_RESULT_TTL" envDefault:"24h" desc:"How long finished jobs are kept; 0 keeps them forever."`
+	JobJournalDir               string        `env:"JOB_JOURNAL_DIR" desc:"Journal accepted jobs here so they resume after a restart; empty disables."`
+	JobJournalMaxAttempts       int           `env:"JOB_JOURNAL_MAX_ATTEMPTS" envDefault:"2" desc:"Interruptions after which a journaled job fails instead of resuming."`
+	BatchMaxFiles               int           `env:"BATCH_MAX_FILES" envDefault:"16" desc:"Files accepted per POST /v1/pipeline/batch."`
+	BatchConcurrency            int           `env:"BATCH_CONCURRENCY" envDefault:"4" desc:"Batch files processed at once."`
+	PipelineStageConcurrency    int           `env:"PIPELINE_STAGE_CONCURRENCY" envDefault:"4" desc:"Upstream calls per pipeline request at once, counting post-processing."`
+	PipelineFallbackPolicy      string        `env:"PIPELINE_FALLBACK_POLICY" envDefault:"fallback" desc:"When post-processing fails: fallback, error or retry_then_fallback."`
+	PipelineVocabularyPrompt    bool          `env:"PIPELINE_VOCABULARY_PROMPT" envDefault:"true" desc:"Send custom_vocabulary as the transcription prompt when none is given."`
+	PipelineSummaryModel        string        `env:"PIPELINE_SUMMARY_MODEL" desc:"Model of the summary stage; empty uses POSTPROCESS_MODEL."`
+	ChaptersModel               string        `env:"CHAPTERS_MODEL" desc:"Model for POST /v1/chapters; empty uses POSTPROCESS_MODEL."`
+	ReadyMaxQueueDepth          int           `env:"READY_MAX_QUEUE_DEPTH" desc:"/readyz fails above this many waiting jobs; 0 disables."`
+	ReadyMaxInFlight            int           `env:"READY_MAX_IN_FLIGHT" desc:"/readyz fails above this many in-flight requests; 0 disables."`
+	ShedMaxQueueDepth           int           `env:"SHED_MAX_QUEUE_DEPTH" desc:"Shed new requests above this many waiting jobs; 0 disables."`
+	ShedMaxInFlight             int           `env:"SHED_MAX_IN_FLIGHT" desc:"Shed new requests above this many in flight; 0 disables."`
+	MultipartMaxParsers         int           `env:"MULTIPART_MAX_PARSERS" desc:"Requests parsing multipart uploads at once; 0 means no limit."`
+	MultipartParserWaitMS       int           `env:"MULTIPART_PARSER_WAIT_MS" envDefault:"2000" desc:"How long a request waits for a multipart parser before 503."`
+	ReadyStoreTimeoutMS         int           `env:"READY_STORE_TIMEOUT_MS" envDefault:"1000" desc:"/readyz budget for pinging the job store."`
+	AudioFetchTimeoutSecs       int           `env:"AUDIO_FETCH_TIMEOUT_SECONDS" envDefault:"30" desc:"Time limit of audio_url downloads."`
+	AudioFetchAllowPrivate      bool          `env:"AUDIO_FETCH_ALLOW_PRIVATE" desc:"Allow audio_url downloads from private addresses; never in production."`
+	S3Region                    string        `env:"S3_REGION" envDefault:"us-east-1" desc:"Region of s3:// audio_url buckets."`
+	S3Endpoint                  string        `env:"S3_ENDPOINT" desc:"S3-compatible endpoint, addressed path-style."`
+	S3AccessKeyID               string        `env:"S3_ACCESS_KEY_ID" desc:"Access key for s3:// audio_url downloads."`
+	S3SecretAccessKey           string        `env:"S3_SECRET_ACCESS_KEY" desc:"Secret key for s3:// audio_url downloads."`
+	S3SessionToken              string        `env:"S3_SESSION_TOKEN" desc:"Session token for s3:// audio_url downloads."`
+	S3Buckets                   []string      `env:"S3_BUCKETS" envSeparator:"," desc:"Buckets s3:// audio_url may read; required with S3_ACCESS_KEY_ID."`
+	GCSHMACAccessID             string        `env:"GCS_HMAC_ACCESS_ID" desc:"HMAC access ID for gs:// audio_url downloads."`
+	GCSHMACSecret               string        `env:"GCS_HMAC_SECRET" desc:"HMAC secret for gs:// audio_url downloads."`
+	GCSBuckets                  []string      `env:"GCS_BUCKETS" envSeparator:"," desc:"Buckets gs:// audio_url may read; required with GCS_HMAC_ACCESS_ID."`
 }
 
 func Load() (Config, error) {
 	var raw envConfig
-	if err := cenv.Parse(&raw); err != nil {
+	if err := parseEnv(&raw); err != nil {
 		return Config{}, err
 	}
 
 	cfg := Config{
-		ListenAddr:           strings.TrimSpace(raw.ListenAddr),
-		UpstreamBaseURL:      strings.TrimRight(strings.TrimSpace(raw.UpstreamBaseURL), "/"),
-		UpstreamAPIKey:       strings.TrimSpace(raw.UpstreamAPIKey),
-		TranscriptionModel:   strings.TrimSpace(raw.TranscriptionModel),
-		PostProcessModel:     strings.TrimSpace(raw.PostProcessModel),
-		RequestTimeout:       time.Duration(raw.RequestTimeoutSeconds) * time.Second,
-		TranscriptionTimeout: time.Duration(raw.TranscriptionTimeoutSeconds) * time.Second,
-		PostProcessTimeout:   time.Duration(raw.PostProcessTimeoutSeconds) * time.Second,
-		MaxUploadBytes:       raw.MaxUploadBytes,
-		LogLevel:             strings.ToLower(strings.TrimSpace(raw.LogLevel)),
+		ListenAddr:                  strings.TrimSpace(raw.ListenAddr),
+		GRPCListenAddr:              strings.TrimSpace(raw.GRPCListenAddr),
+		UpstreamProvider:            strings.ToLower(strings.TrimSpace(raw.UpstreamProvider)),
+		AzureOpenAIAPIVersion:       strings.TrimSpace(raw.AzureOpenAIAPIVersion),
+		UpstreamBaseURL:             strings.TrimRight(strings.TrimSpace(raw.UpstreamBaseURL), "/"),
+		UpstreamRegionalBaseURLs:    trimBaseURLs(raw.UpstreamRegionalBaseURLs),
+		UpstreamProbeInterval:       time.Duration(raw.UpstreamProbeIntervalSecs) * time.Second,
+		UpstreamAPIKey:              strings.TrimSpace(raw.UpstreamAPIKey),
+		UpstreamFailoverBaseURLs:    trimBaseURLs(raw.UpstreamFailoverBaseURLs),
+		UpstreamFailoverAPIKeys:     trimValues(raw.UpstreamFailoverAPIKeys),
+		UpstreamFailoverTimeout:     time.Duration(raw.UpstreamFailoverTimeoutSecs) * time.Second,
+		UpstreamRetryMaxAttempts:    raw.UpstreamRetryMaxAttempts,
+		UpstreamRetryBaseDelay:      time.Duration(raw.UpstreamRetryBaseDelayMS) * time.Millisecond,
+		UpstreamRetryMaxDelay:       time.Duration(raw.UpstreamRetryMaxDelaySecs) * time.Second,
+		UpstreamTLSCertFile:         strings.TrimSpace(raw.UpstreamTLSCertFile),
+		UpstreamTLSKeyFile:          strings.TrimSpace(raw.UpstreamTLSKeyFile),
+		UpstreamTLSCAFile:           strings.TrimSpace(raw.UpstreamTLSCAFile),
+		TranscriptionBaseURL:        strings.TrimRight(strings.TrimSpace(raw.TranscriptionBaseURL), "/"),
+		TranscriptionAPIKey:         strings.TrimSpace(raw.TranscriptionAPIKey),
+		PostProcessBaseURL:          strings.TrimRight(strings.TrimSpace(raw.PostProcessBaseURL), "/"),
+		PostProcessAPIKey:           strings.TrimSpace(raw.PostProcessAPIKey),
+		TranscriptionModel:          strings.TrimSpace(raw.TranscriptionModel),
+		TranscriptionLanguage:       strings.ToLower(strings.TrimSpace(raw.TranscriptionLanguage)),
+		UploadDefaultFileName:       strings.TrimSpace(raw.UploadDefaultFileName),
+		PostProcessModel:            strings.TrimSpace(raw.PostProcessModel),
+		PostProcessLogitBias:        raw.PostProcessLogitBias,
+		PostProcessLogitBiasValue:   raw.PostProcessLogitBiasValue,
+		PostProcessTokenizeURL:      strings.TrimSpace(raw.PostProcessTokenizeURL),
+		PostProcessTokenizeAPIKey:   strings.TrimSpace(raw.PostProcessTokenizeAPIKey),
+		PostProcessOutputTag:        outputTag(raw.PostProcessOutputTag),
+		PostProcessContextMaxTokens: raw.PostProcessContextMaxTokens,
+		PostProcessSummaryModel:     strings.TrimSpace(raw.PostProcessSummaryModel),
+		PostProcessSmallModel:       strings.TrimSpace(raw.PostProcessSmallModel),
+		PostProcessMaxDrift:         raw.PostProcessMaxDrift,
+		PostProcessStopSequences:    stopSequences(raw.PostProcessStopSequences),
+		PostProcessSpellings:        raw.PostProcessSpellings,
+		RequestTimeout:              time.Duration(raw.RequestTimeoutSeconds) * time.Second,
+		TranscriptionTimeout:        time.Duration(raw.TranscriptionTimeoutSeconds) * time.Second,
+		TranscriptionTimeoutPerMB:   time.Duration(raw.TranscriptionPerMBSeconds) * time.Second,
+		TranscriptionMaxTimeout:     time.Duration(raw.TranscriptionMaxSeconds) * time.Second,
+		MaxRequestTimeout:           time.Duration(raw.MaxRequestTimeoutMS) * time.Millisecond,
+		CostPerAudioMinute:          raw.CostPerAudioMinute,
+		CostPer1KTokens:             raw.CostPer1KTokens,
+		TranscriptionHedgeDelay:     time.Duration(raw.TranscriptionHedgeDelayMS) * time.Millisecond,
+		TranscriptionHedgeMaxBytes:  raw.TranscriptionHedgeMaxBytes,
+		TranscriptionChunkMaxBytes:  raw.TranscriptionChunkMaxBytes,
+		TranscriptionChunkLength:    time.Duration(raw.TranscriptionChunkSeconds) * time.Second,
+		TranscriptionChunkOverlap:   time.Duration(raw.TranscriptionChunkOverlapMS) * time.Millisecond,
+		TranscriptionChunkWorkers:   raw.TranscriptionChunkWorkers,
+		TranscriptionDedupe:         raw.TranscriptionDedupe,
+		MaxConcurrentTranscriptions: raw.MaxConcurrentTranscriptions,
+		MaxConcurrentCompletions:    raw.MaxConcurrentCompletions,
+		PostProcessTimeout:          time.Duration(raw.PostProcessTimeoutSeconds) * time.Second,
+		UploadReadTimeout:           time.Duration(raw.UploadReadTimeoutSeconds) * time.Second,
+		ShutdownDrainTimeout:        time.Duration(raw.ShutdownDrainSeconds) * time.Second,
+		MaxUploadBytes:              raw.MaxUploadBytes,
+		MaxAudioDuration:            time.Duration(raw.MaxAudioSeconds) * time.Second,
+		FFmpegPath:                  strings.TrimSpace(raw.FFmpegPath),
+		StrictFormFields:            raw.StrictFormFields,
+		LogLevel:                    strings.ToLower(strings.TrimSpace(raw.LogLevel)),
+		Environment:                 strings.ToLower(strings.TrimSpace(raw.Environment)),
+		ChaosEnabled:                raw.ChaosEnabled,
+		ChaosLatency:                time.Duration(raw.ChaosLatencyMS) * time.Millisecond,
+		ChaosLatencyRate:            raw.ChaosLatencyRate,
+		ChaosRateLimitRate:          raw.ChaosRateLimitRate,
+		ChaosTruncateRate:           raw.ChaosTruncateRate,
+		ChaosMalformedJSONRate:      raw.ChaosMalformedJSONRate,
+		MemoryLimitBytes:            raw.MemoryLimitBytes,
+		MemoryLimitRatio:            raw.MemoryLimitRatio,
+		GCPercent:                   raw.GCPercent,
+		MemoryWatchdogThreshold:     raw.MemoryWatchdogThreshold,
+		MemoryWatchdogInterval:      time.Duration(raw.MemoryWatchdogIntervalSecs) * time.Second,
+		MemoryProfileDir:            strings.TrimSpace(raw.MemoryProfileDir),
+		SignalDumps:                 raw.SignalDumps,
+		SignalDumpDir:               strings.TrimSpace(raw.SignalDumpDir),
+		KeepWarmInterval:            time.Duration(raw.KeepWarmIntervalSeconds) * time.Second,
+		KeepWarmModel:               strings.TrimSpace(raw.KeepWarmModel),
+		KeepWarmModelInterval:       time.Duration(raw.KeepWarmModelIntervalSecs) * time.Second,
+		HealthProbeInterval:         time.Duration(raw.HealthProbeIntervalSecs) * time.Second,
+		HealthProbeModel:            strings.TrimSpace(raw.HealthProbeModel),
+		JobStore:                    strings.ToLower(strings.TrimSpace(raw.JobStore)),
+		JobSQLitePath:               strings.TrimSpace(raw.JobSQLitePath),
+		JobSQLiteAutoMigrate:        raw.JobSQLiteAutoMigrate,
+		RedisURL:                    strings.TrimSpace(raw.RedisURL),
+		ResultCache:                 strings.ToLower(strings.TrimSpace(raw.ResultCache)),
+		ResultCacheTTL:              raw.ResultCacheTTL,
+		ResultCacheMaxEntries:       raw.ResultCacheMaxEntries,
+		HedgeBaseURL:                strings.TrimRight(strings.TrimSpace(raw.HedgeBaseURL), "/"),
+		HedgeAPIKey:                 strings.TrimSpace(raw.HedgeAPIKey),
+		HedgeTranscriptionModel:     strings.TrimSpace(raw.HedgeTranscriptionModel),
+		HedgeProviderName:           strings.TrimSpace(raw.HedgeProviderName),
+		DeepgramAPIKey:              strings.TrimSpace(raw.DeepgramAPIKey),
+		DeepgramBaseURL:             strings.TrimRight(strings.TrimSpace(raw.DeepgramBaseURL), "/"),
+		DeepgramModel:               strings.TrimSpace(raw.DeepgramModel),
+		AssemblyAIAPIKey:            strings.TrimSpace(raw.AssemblyAIAPIKey),
+		AssemblyAIBaseURL:           strings.TrimRight(strings.TrimSpace(raw.AssemblyAIBaseURL), "/"),
+		AssemblyAIModel:             strings.TrimSpace(raw.AssemblyAIModel),
+		AnthropicAPIKey:             strings.TrimSpace(raw.AnthropicAPIKey),
+		AnthropicBaseURL:            strings.TrimRight(strings.TrimSpace(raw.AnthropicBaseURL), "/"),
+		AnthropicModels:             trimValues(raw.AnthropicModels),
+		ModelAllowlist:              trimValues(raw.ModelAllowlist),
+		AnthropicMaxTokens:          raw.AnthropicMaxTokens,
+		LocalWhisperAPI:             strings.ToLower(strings.TrimSpace(raw.LocalWhisperAPI)),
+		LocalWhisperServers:         localWhisperServers(raw.LocalWhisperServers),
+		LocalWhisperAPIKey:          strings.TrimSpace(raw.LocalWhisperAPIKey),
+		LocalWhisperPrimary:         raw.LocalWhisperPrimary,
+		PremiumTokenSHA256:          normalizeDigests(raw.PremiumTokenSHA256),
+		RoutingRulesPath:            strings.TrimSpace(raw.RoutingRulesPath),
+		RoutingRulesReloadInterval:  time.Duration(raw.RoutingRulesReloadSecs) * time.Second,
+		WebhookSecret:               strings.TrimSpace(raw.WebhookSecret),
+		WebhookMaxAttempts:          raw.WebhookMaxAttempts,
+		WebhookTimeout:              time.Duration(raw.WebhookTimeoutSecs) * time.Second,
+		WebhookAllowPrivate:         raw.WebhookAllowPrivate,
+		AdminToken:                  strings.TrimSpace(raw.AdminToken),
+		AuthTokensPath:              strings.TrimSpace(raw.AuthTokensPath),
+		AcronymsPath:                strings.TrimSpace(raw.AcronymsPath),
+		LegacyClientsPath:           strings.TrimSpace(raw.LegacyClientsPath),
+		UpstreamKeyRotationGrace:    time.Duration(raw.UpstreamKeyRotationGraceSec) * time.Second,
+		JobWorkers:                  raw.JobWorkers,
+		JobQueueSize:                raw.JobQueueSize,
+		JobAdmissionSLA:             time.Duration(raw.JobAdmissionSLASecs) * time.Second,
+		JobResultTTL:                raw.JobResultTTL,
+		JobJournalDir:               strings.TrimSpace(raw.JobJournalDir),
+		JobJournalMaxAttempts:       raw.JobJournalMaxAttempts,
+		BatchMaxFiles:               raw.BatchMaxFiles,
+		BatchConcurrency:            raw.BatchConcurrency,
+		PipelineStageConcurrency:    raw.PipelineStageConcurrency,
+		PipelineFallbackPolicy:      strings.ToLower(strings.TrimSpace(raw.PipelineFallbackPolicy)),
+		PipelineVocabularyPrompt:    raw.PipelineVocabularyPrompt,
+		PipelineSummaryModel:        strings.TrimSpace(raw.PipelineSummaryModel),
+		ChaptersModel:               strings.TrimSpace(raw.ChaptersModel),
+		ReadyMaxQueueDepth:          raw.ReadyMaxQueueDepth,
+		ReadyMaxInFlight:            raw.ReadyMaxInFlight,
+		ShedMaxQueueDepth:           raw.ShedMaxQueueDepth,
+		ShedMaxInFlight:             raw.ShedMaxInFlight,
+		MultipartMaxParsers:         raw.MultipartMaxParsers,
+		MultipartParserWait:         time.Duration(raw.MultipartParserWaitMS) * time.Millisecond,
+		ReadyStoreTimeout:           time.Duration(raw.ReadyStoreTimeoutMS) * time.Millisecond,
+		AudioFetchTimeout:           time.Duration(raw.AudioFetchTimeoutSecs) * time.Second,
+		AudioFetchAllowPrivate:      raw.AudioFetchAllowPrivate,
+		S3Region:                    raw.S3Region,
+		S3Endpoint:                  strings.TrimRight(raw.S3Endpoint, "/"),
+		S3AccessKeyID:               raw.S3AccessKeyID,
+		S3SecretAccessKey:           raw.S3SecretAccessKey,
+		S3SessionToken:              raw.S3SessionToken,
+		S3Buckets:                   trimValues(raw.S3Buckets),
+		GCSHMACAccessID:             raw.GCSHMACAccessID,
+		GCSHMACSecret:               raw.GCSHMACSecret,
+		GCSBuckets:                  trimValues(raw.GCSBuckets),
 	}
+	cfg.TranscriptionBaseURL, cfg.TranscriptionAPIKey = cfg.stepUpstream(cfg.TranscriptionBaseURL, cfg.TranscriptionAPIKey)
+	cfg.PostProcessBaseURL, cfg.PostProcessAPIKey = cfg.stepUpstream(cfg.PostProcessBaseURL, cfg.PostProcessAPIKey)
 
 	if err := cfg.Validate(); err != nil {
 		return Config{}, err
@@ -59,30 +493,536 @@ func Load() (Config, error) {
 	return cfg, nil
 }
 
+// LocalWhisperServer is one LOCAL_WHISPER_SERVERS entry: a self-hosted
+// Whisper server and the model it runs.
+type LocalWhisperServer struct {
+	Model string
+	URL   string
+}
+
+// LocalWhisperAPIs lists the accepted LOCAL_WHISPER_API values.
+var LocalWhisperAPIs = []string{"openai", "whispercpp", "asr"}
+
+// UpstreamProviders lists the accepted UPSTREAM_PROVIDER values. "openai"
+// covers any OpenAI-compatible API, including Groq; "azure" is Azure OpenAI.
+var UpstreamProviders = []string{"openai", "azure"}
+
 func (c Config) Validate() error {
 	if c.ListenAddr == "" {
 		return errors.New("LISTEN_ADDR must not be empty")
 	}
+	if !slices.Contains(UpstreamProviders, c.UpstreamProvider) {
+		return fmt.Errorf("UPSTREAM_PROVIDER must be one of %s", strings.Join(UpstreamProviders, ", "))
+	}
 	if c.UpstreamBaseURL == "" {
 		return errors.New("UPSTREAM_BASE_URL must not be empty")
 	}
+	if len(c.UpstreamFailoverAPIKeys) != len(c.UpstreamFailoverBaseURLs) {
+		return errors.New("UPSTREAM_FAILOVER_API_KEYS must list one key per UPSTREAM_FAILOVER_BASE_URLS entry")
+	}
+	for _, baseURL := range c.UpstreamFailoverBaseURLs {
+		if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("UPSTREAM_FAILOVER_BASE_URLS entry %q must be an http(s) URL", redactURL(baseURL))
+		}
+	}
+	if c.UpstreamFailoverTimeout < 0 {
+		return errors.New("UPSTREAM_FAILOVER_ATTEMPT_TIMEOUT_SECONDS must be >= 0")
+	}
+	if c.UpstreamRetryMaxAttempts < 1 {
+		return errors.New("UPSTREAM_RETRY_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.UpstreamRetryMaxAttempts > 1 && (c.UpstreamRetryBaseDelay <= 0 || c.UpstreamRetryMaxDelay < c.UpstreamRetryBaseDelay) {
+		return errors.New("UPSTREAM_RETRY_BASE_DELAY_MS must be > 0 and UPSTREAM_RETRY_MAX_DELAY_SECONDS must not be below it")
+	}
+	if (c.UpstreamTLSCertFile == "") != (c.UpstreamTLSKeyFile == "") {
+		return errors.New("UPSTREAM_TLS_CERT_FILE and UPSTREAM_TLS_KEY_FILE must be set together")
+	}
+	for _, step := range []struct{ name, baseURL, apiKey string }{
+		{"TRANSCRIPTION", c.TranscriptionBaseURL, c.TranscriptionAPIKey},
+		{"POSTPROCESS", c.PostProcessBaseURL, c.PostProcessAPIKey},
+	} {
+		if step.baseURL == "" || step.baseURL == c.UpstreamBaseURL {
+			continue
+		}
+		if u, err := url.Parse(step.baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s_BASE_URL must be an http(s) URL", step.name)
+		}
+		if step.apiKey == "" {
+			return fmt.Errorf("%s_API_KEY must be set when %s_BASE_URL differs from UPSTREAM_BASE_URL", step.name, step.name)
+		}
+	}
+	if c.UpstreamProvider == "azure" && c.AzureOpenAIAPIVersion == "" {
+		return errors.New("AZURE_OPENAI_API_VERSION must not be empty when UPSTREAM_PROVIDER=azure")
+	}
+	if len(c.UpstreamRegionalBaseURLs) > 0 && c.UpstreamProbeInterval <= 0 {
+		return errors.New("UPSTREAM_PROBE_INTERVAL_SECONDS must be > 0 when UPSTREAM_REGIONAL_BASE_URLS is set")
+	}
 	if c.TranscriptionModel == "" {
 		return errors.New("TRANSCRIPTION_MODEL must not be empty")
 	}
+	if n := len(c.TranscriptionLanguage); n != 0 && (n != 2 || strings.ContainsFunc(c.TranscriptionLanguage, func(r rune) bool { return r < 'a' || r > 'z' })) {
+		return errors.New("TRANSCRIPTION_LANGUAGE must be an ISO 639-1 code such as en")
+	}
+	if c.UploadDefaultFileName == "" || path.Ext(c.UploadDefaultFileName) == "" || strings.ContainsAny(c.UploadDefaultFileName, `/\`) {
+		return errors.New("UPLOAD_DEFAULT_FILENAME must be a file name with an extension, such as audio.wav")
+	}
 	if c.PostProcessModel == "" {
 		return errors.New("POSTPROCESS_MODEL must not be empty")
 	}
+	if c.PostProcessOutputTag != "" && !validOutputTag(c.PostProcessOutputTag) {
+		return errors.New("POSTPROCESS_OUTPUT_TAG must be letters, digits, '_' or '-', or none")
+	}
+	if stops := len(c.PostProcessStopSequences); stops > 4 || (stops == 4 && c.PostProcessOutputTag != "") {
+		return errors.New("at most 4 stop sequences are allowed, including the output tag's closing tag")
+	}
+	if c.PostProcessContextMaxTokens < 0 {
+		return errors.New("POSTPROCESS_CONTEXT_MAX_TOKENS must be >= 0")
+	}
+	if c.PostProcessSmallModel != "" && (c.PostProcessMaxDrift <= 0 || c.PostProcessMaxDrift > 1) {
+		return errors.New("POSTPROCESS_ESCALATION_MAX_DRIFT must be > 0 and <= 1")
+	}
+	if c.PostProcessLogitBias {
+		if c.PostProcessLogitBiasValue < 1 || c.PostProcessLogitBiasValue > 100 {
+			return errors.New("POSTPROCESS_LOGIT_BIAS_VALUE must be between 1 and 100")
+		}
+		if u, err := url.Parse(c.PostProcessTokenizeURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("POSTPROCESS_TOKENIZE_URL must be an absolute http or https URL when POSTPROCESS_LOGIT_BIAS is enabled")
+		}
+	}
 	if c.RequestTimeout <= 0 {
 		return errors.New("REQUEST_TIMEOUT_SECONDS must be > 0")
 	}
 	if c.TranscriptionTimeout <= 0 {
 		return errors.New("TRANSCRIPTION_TIMEOUT_SECONDS must be > 0")
 	}
+	if c.TranscriptionTimeoutPerMB < 0 {
+		return errors.New("TRANSCRIPTION_TIMEOUT_PER_MB_SECONDS must be >= 0")
+	}
+	if c.TranscriptionMaxTimeout < c.TranscriptionTimeout {
+		return errors.New("TRANSCRIPTION_MAX_TIMEOUT_SECONDS must be >= TRANSCRIPTION_TIMEOUT_SECONDS")
+	}
+	if c.MaxRequestTimeout <= 0 {
+		return errors.New("MAX_REQUEST_TIMEOUT_MS must be > 0")
+	}
+	if c.CostPerAudioMinute < 0 || c.CostPer1KTokens < 0 {
+		return errors.New("COST_PER_AUDIO_MINUTE and COST_PER_1K_TOKENS must be >= 0")
+	}
+	if c.TranscriptionHedgeDelay < 0 || c.TranscriptionHedgeMaxBytes < 0 {
+		return errors.New("TRANSCRIPTION_HEDGE_DELAY_MS and TRANSCRIPTION_HEDGE_MAX_BYTES must be >= 0")
+	}
+	if c.TranscriptionChunkMaxBytes < 0 || c.TranscriptionChunkLength < 0 || c.TranscriptionChunkOverlap < 0 {
+		return errors.New("TRANSCRIPTION_CHUNK_MAX_BYTES, TRANSCRIPTION_CHUNK_SECONDS and TRANSCRIPTION_CHUNK_OVERLAP_MS must be >= 0")
+	}
+	if c.TranscriptionChunkLength > 0 && c.TranscriptionChunkOverlap*2 >= c.TranscriptionChunkLength {
+		return errors.New("TRANSCRIPTION_CHUNK_OVERLAP_MS must be less than half of TRANSCRIPTION_CHUNK_SECONDS")
+	}
+	if c.TranscriptionChunkWorkers < 1 {
+		return errors.New("TRANSCRIPTION_CHUNK_PARALLELISM must be >= 1")
+	}
+	if c.MaxConcurrentTranscriptions < 0 || c.MaxConcurrentCompletions < 0 {
+		return errors.New("MAX_CONCURRENT_TRANSCRIPTIONS and MAX_CONCURRENT_COMPLETIONS must be >= 0")
+	}
 	if c.PostProcessTimeout <= 0 {
 		return errors.New("POSTPROCESS_TIMEOUT_SECONDS must be > 0")
 	}
+	if c.UploadReadTimeout <= 0 {
+		return errors.New("UPLOAD_READ_TIMEOUT_SECONDS must be > 0")
+	}
+	if c.ShutdownDrainTimeout < 0 {
+		return errors.New("SHUTDOWN_DRAIN_SECONDS must be >= 0")
+	}
 	if c.MaxUploadBytes <= 0 {
 		return errors.New("MAX_UPLOAD_BYTES must be > 0")
 	}
+	if c.MaxAudioDuration < 0 {
+		return errors.New("MAX_AUDIO_SECONDS must be >= 0")
+	}
+	if c.MemoryLimitBytes < 0 {
+		return errors.New("MEMORY_LIMIT_BYTES must be >= 0")
+	}
+	if c.MemoryLimitRatio <= 0 || c.MemoryLimitRatio > 1 {
+		return errors.New("MEMORY_LIMIT_RATIO must be > 0 and <= 1")
+	}
+	if c.GCPercent < 0 {
+		return errors.New("GC_PERCENT must be >= 0")
+	}
+	if c.MemoryWatchdogThreshold <= 0 || c.MemoryWatchdogThreshold > 1 {
+		return errors.New("MEMORY_WATCHDOG_THRESHOLD must be > 0 and <= 1")
+	}
+	if c.MemoryWatchdogInterval <= 0 {
+		return errors.New("MEMORY_WATCHDOG_INTERVAL_SECONDS must be > 0")
+	}
+	if c.KeepWarmInterval < 0 {
+		return errors.New("UPSTREAM_KEEPWARM_INTERVAL_SECONDS must be >= 0")
+	}
+	if c.KeepWarmModel != "" {
+		if c.KeepWarmInterval == 0 {
+			return errors.New("UPSTREAM_KEEPWARM_MODEL requires UPSTREAM_KEEPWARM_INTERVAL_SECONDS > 0")
+		}
+		if c.UpstreamAPIKey == "" {
+			return errors.New("UPSTREAM_KEEPWARM_MODEL requires UPSTREAM_API_KEY")
+		}
+		if c.KeepWarmModelInterval <= 0 {
+			return errors.New("UPSTREAM_KEEPWARM_MODEL_INTERVAL_SECONDS must be > 0")
+		}
+	}
+	if c.HealthProbeInterval < 0 {
+		return errors.New("UPSTREAM_HEALTH_PROBE_INTERVAL_SECONDS must be >= 0")
+	}
+	if c.HealthProbeModel != "" && (c.HealthProbeInterval == 0 || c.UpstreamAPIKey == "") {
+		return errors.New("UPSTREAM_HEALTH_PROBE_MODEL requires UPSTREAM_HEALTH_PROBE_INTERVAL_SECONDS > 0 and UPSTREAM_API_KEY")
+	}
+	switch c.JobStore {
+	case "memory":
+	case "sqlite":
+		if c.JobSQLitePath == "" {
+			return errors.New("JOB_SQLITE_PATH must not be empty when JOB_STORE=sqlite")
+		}
+	case "redis":
+		if c.RedisURL == "" {
+			return errors.New("REDIS_URL must be set when JOB_STORE=redis")
+		}
+	default:
+		return errors.New("JOB_STORE must be one of: memory, sqlite, redis")
+	}
+	switch c.ResultCache {
+	case "off":
+	case "memory":
+		if c.ResultCacheMaxEntries <= 0 {
+			return errors.New("RESULT_CACHE_MAX_ENTRIES must be > 0")
+		}
+	case "redis":
+		if c.RedisURL == "" {
+			return errors.New("REDIS_URL must be set when RESULT_CACHE=redis")
+		}
+	default:
+		return errors.New("RESULT_CACHE must be one of: off, memory, redis")
+	}
+	if c.ResultCacheTTL < 0 {
+		return errors.New("RESULT_CACHE_TTL must be >= 0")
+	}
+	if c.HedgeBaseURL != "" {
+		if c.HedgeAPIKey == "" {
+			return errors.New("HEDGE_API_KEY must be set when HEDGE_BASE_URL is set")
+		}
+		if c.HedgeTranscriptionModel == "" || c.HedgeProviderName == "" {
+			return errors.New("HEDGE_TRANSCRIPTION_MODEL and HEDGE_PROVIDER_NAME must not be empty")
+		}
+	}
+	if c.DeepgramAPIKey != "" {
+		if c.DeepgramBaseURL == "" || c.DeepgramModel == "" {
+			return errors.New("DEEPGRAM_BASE_URL and DEEPGRAM_MODEL must not be empty")
+		}
+		if c.HedgeBaseURL != "" && c.HedgeProviderName == "deepgram" {
+			return errors.New("HEDGE_PROVIDER_NAME must not be deepgram when DEEPGRAM_API_KEY is set")
+		}
+	}
+	if err := c.validateLocalWhisper(); err != nil {
+		return err
+	}
+	if c.AssemblyAIAPIKey != "" {
+		if c.AssemblyAIBaseURL == "" || c.AssemblyAIModel == "" {
+			return errors.New("ASSEMBLYAI_BASE_URL and ASSEMBLYAI_MODEL must not be empty")
+		}
+		if c.HedgeBaseURL != "" && c.HedgeProviderName == "assemblyai" {
+			return errors.New("HEDGE_PROVIDER_NAME must not be assemblyai when ASSEMBLYAI_API_KEY is set")
+		}
+	}
+	if c.AnthropicAPIKey != "" {
+		if u, err := url.Parse(c.AnthropicBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("ANTHROPIC_BASE_URL must be an http(s) URL")
+		}
+		if len(c.AnthropicModels) == 0 {
+			return errors.New("ANTHROPIC_MODELS must not be empty when ANTHROPIC_API_KEY is set")
+		}
+		for _, pattern := range c.AnthropicModels {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("ANTHROPIC_MODELS: invalid pattern %q", pattern)
+			}
+		}
+		if c.AnthropicMaxTokens <= 0 {
+			return errors.New("ANTHROPIC_MAX_TOKENS must be greater than 0")
+		}
+	}
+	for _, pattern := range c.ModelAllowlist {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("MODEL_ALLOWLIST: invalid pattern %q", pattern)
+		}
+	}
+	for _, digest := range c.PremiumTokenSHA256 {
+		if len(digest) != sha256.Size*2 {
+			return errors.New("PREMIUM_TOKEN_SHA256 entries must be hex-encoded SHA-256 digests")
+		}
+	}
+	if c.RoutingRulesReloadInterval < 0 {
+		return errors.New("ROUTING_RULES_RELOAD_SECONDS must be >= 0")
+	}
+	if c.WebhookMaxAttempts <= 0 || c.WebhookTimeout <= 0 {
+		return errors.New("WEBHOOK_MAX_ATTEMPTS and WEBHOOK_TIMEOUT_SECONDS must be > 0")
+	}
+	if c.AuthTokensPath != "" && c.UpstreamAPIKey == "" {
+		return errors.New("AUTH_TOKENS_PATH requires UPSTREAM_API_KEY")
+	}
+	if c.UpstreamKeyRotationGrace < 0 {
+		return errors.New("UPSTREAM_KEY_ROTATION_GRACE_SECONDS must be >= 0")
+	}
+	if c.JobWorkers <= 0 || c.JobQueueSize <= 0 {
+		return errors.New("JOB_WORKERS and JOB_QUEUE_SIZE must be > 0")
+	}
+	if c.JobAdmissionSLA < 0 {
+		return errors.New("JOB_ADMISSION_SLA_SECONDS must be >= 0")
+	}
+	if c.JobResultTTL != 0 && c.JobResultTTL < time.Minute {
+		return errors.New("JOB_RESULT_TTL must be 0 (keep forever) or at least 1m")
+	}
+	if c.JobJournalDir != "" && c.JobJournalMaxAttempts < 1 {
+		return errors.New("JOB_JOURNAL_MAX_ATTEMPTS must be >= 1")
+	}
+	if c.BatchMaxFiles <= 0 || c.BatchConcurrency <= 0 {
+		return errors.New("BATCH_MAX_FILES and BATCH_CONCURRENCY must be > 0")
+	}
+	if c.PipelineStageConcurrency <= 0 {
+		return errors.New("PIPELINE_STAGE_CONCURRENCY must be > 0")
+	}
+	switch c.PipelineFallbackPolicy {
+	case "fallback", "error", "retry_then_fallback":
+	default:
+		return errors.New("PIPELINE_FALLBACK_POLICY must be one of: fallback, error, retry_then_fallback")
+	}
+	if c.ReadyMaxQueueDepth < 0 || c.ReadyMaxInFlight < 0 {
+		return errors.New("READY_MAX_QUEUE_DEPTH and READY_MAX_IN_FLIGHT must be >= 0")
+	}
+	if c.ShedMaxQueueDepth < 0 || c.ShedMaxInFlight < 0 {
+		return errors.New("SHED_MAX_QUEUE_DEPTH and SHED_MAX_IN_FLIGHT must be >= 0")
+	}
+	if c.MultipartMaxParsers < 0 || c.MultipartParserWait < 0 {
+		return errors.New("MULTIPART_MAX_PARSERS and MULTIPART_PARSER_WAIT_MS must be >= 0")
+	}
+	if c.ReadyStoreTimeout <= 0 {
+		return errors.New("READY_STORE_TIMEOUT_MS must be > 0")
+	}
+	if c.AudioFetchTimeout <= 0 {
+		return errors.New("AUDIO_FETCH_TIMEOUT_SECONDS must be > 0")
+	}
+	if c.WebhookAllowPrivate && c.Environment == "production" {
+		return errors.New("WEBHOOK_ALLOW_PRIVATE must not be set when APP_ENV=production")
+	}
+	if c.AudioFetchAllowPrivate && c.Environment == "production" {
+		return errors.New("AUDIO_FETCH_ALLOW_PRIVATE must not be set when APP_ENV=production")
+	}
+	if (c.S3AccessKeyID == "") != (c.S3SecretAccessKey == "") {
+		return errors.New("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set together")
+	}
+	if c.S3Endpoint != "" {
+		if u, err := url.Parse(c.S3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("S3_ENDPOINT must be an absolute http or https URL")
+		}
+	}
+	if c.S3AccessKeyID != "" && len(c.S3Buckets) == 0 {
+		return errors.New("S3_BUCKETS must list the buckets s3:// audio_url may read")
+	}
+	if (c.GCSHMACAccessID == "") != (c.GCSHMACSecret == "") {
+		return errors.New("GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET must be set together")
+	}
+	if c.GCSHMACAccessID != "" && len(c.GCSBuckets) == 0 {
+		return errors.New("GCS_BUCKETS must list the buckets gs:// audio_url may read")
+	}
+	if c.ChaosEnabled {
+		if c.Environment == "production" {
+			return errors.New("CHAOS_ENABLED must not be set when APP_ENV=production")
+		}
+		if c.ChaosLatency < 0 {
+			return errors.New("CHAOS_LATENCY_MS must be >= 0")
+		}
+		rates := []struct {
+			name  string
+			value float64
+		}{
+			{"CHAOS_LATENCY_RATE", c.ChaosLatencyRate},
+			{"CHAOS_429_RATE", c.ChaosRateLimitRate},
+			{"CHAOS_TRUNCATE_RATE", c.ChaosTruncateRate},
+			{"CHAOS_MALFORMED_JSON_RATE", c.ChaosMalformedJSONRate},
+		}
+		for _, rate := range rates {
+			if rate.value < 0 || rate.value > 1 {
+				return fmt.Errorf("%s must be between 0 and 1", rate.name)
+			}
+		}
+	}
 	return nil
 }
+
+// stepUpstream fills a pipeline step's upstream from UPSTREAM_*. The shared
+// key is only inherited when the step stays on the shared base URL; a key for
+// one vendor is never sent to another.
+func (c Config) stepUpstream(baseURL, apiKey string) (string, string) {
+	if baseURL == "" {
+		baseURL = c.UpstreamBaseURL
+	}
+	if apiKey == "" && baseURL == c.UpstreamBaseURL {
+		apiKey = c.UpstreamAPIKey
+	}
+	return baseURL, apiKey
+}
+
+// UpstreamBaseURLs returns the primary base URL followed by any regional
+// alternatives, without duplicates.
+func (c Config) UpstreamBaseURLs() []string {
+	urls := []string{c.UpstreamBaseURL}
+	for _, u := range c.UpstreamRegionalBaseURLs {
+		if !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func trimValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func trimBaseURLs(values []string) []string {
+	var urls []string
+	for _, v := range values {
+		if v = strings.TrimRight(strings.TrimSpace(v), "/"); v != "" {
+			urls = append(urls, v)
+		}
+	}
+	return urls
+}
+
+// IsPremiumToken reports whether the SHA-256 of token is listed in
+// PREMIUM_TOKEN_SHA256, so raw tokens never need to be configured.
+func (c Config) IsPremiumToken(token string) bool {
+	if token == "" || len(c.PremiumTokenSHA256) == 0 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(token))
+	return slices.Contains(c.PremiumTokenSHA256, hex.EncodeToString(sum[:]))
+}
+
+func normalizeDigests(values []string) []string {
+	var digests []string
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			digests = append(digests, v)
+		}
+	}
+	return digests
+}
+
+// localWhisperServers parses "model=url" entries. Entries without "=" keep
+// an empty model and are rejected by Validate.
+func localWhisperServers(values []string) []LocalWhisperServer {
+	var servers []LocalWhisperServer
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		model, serverURL, _ := strings.Cut(v, "=")
+		servers = append(servers, LocalWhisperServer{Model: strings.TrimSpace(model), URL: strings.TrimRight(strings.TrimSpace(serverURL), "/")})
+	}
+	return servers
+}
+
+func (c Config) validateLocalWhisper() error {
+	if !slices.Contains(LocalWhisperAPIs, c.LocalWhisperAPI) {
+		return fmt.Errorf("LOCAL_WHISPER_API must be one of %s", strings.Join(LocalWhisperAPIs, ", "))
+	}
+	seen := map[string]bool{}
+	for _, s := range c.LocalWhisperServers {
+		if s.Model == "" || seen[s.Model] {
+			return errors.New("LOCAL_WHISPER_SERVERS entries must be model=url with unique model names")
+		}
+		seen[s.Model] = true
+		if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("LOCAL_WHISPER_SERVERS URL for %s must be an absolute http or https URL", s.Model)
+		}
+	}
+	if c.LocalWhisperPrimary && !seen[c.TranscriptionModel] {
+		return errors.New("LOCAL_WHISPER_PRIMARY requires a LOCAL_WHISPER_SERVERS entry for TRANSCRIPTION_MODEL")
+	}
+	if len(seen) > 0 && c.HedgeBaseURL != "" && c.HedgeProviderName == "local" {
+		return errors.New("HEDGE_PROVIDER_NAME must not be local when LOCAL_WHISPER_SERVERS is set")
+	}
+	return nil
+}
+
+// stopSequences drops empty entries and turns a literal \n into a newline,
+// which env files cannot hold. Spaces are kept since they can matter.
+func stopSequences(values []string) []string {
+	var stops []string
+	for _, v := range values {
+		if v = strings.ReplaceAll(v, `\n`, "\n"); v != "" {
+			stops = append(stops, v)
+		}
+	}
+	return stops
+}
+
+// outputTag maps "none" to "", which disables the output tag.
+func outputTag(raw string) string {
+	tag := strings.TrimSpace(raw)
+	if strings.EqualFold(tag, "none") {
+		return ""
+	}
+	return tag
+}
+
+func validOutputTag(tag string) bool {
+	for _, r := range tag {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+const redacted = "[redacted]"
+
+// Redacted returns a copy safe to show to operators: secrets are masked and
+// credentials are stripped from URLs.
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.UpstreamAPIKey, &c.TranscriptionAPIKey, &c.PostProcessAPIKey, &c.HedgeAPIKey, &c.DeepgramAPIKey, &c.AssemblyAIAPIKey, &c.AnthropicAPIKey, &c.LocalWhisperAPIKey, &c.WebhookSecret, &c.AdminToken, &c.S3SecretAccessKey, &c.S3SessionToken, &c.GCSHMACSecret} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	for _, u := range []*string{&c.UpstreamBaseURL, &c.TranscriptionBaseURL, &c.PostProcessBaseURL, &c.HedgeBaseURL, &c.RedisURL} {
+		*u = redactURL(*u)
+	}
+	c.UpstreamRegionalBaseURLs = slices.Clone(c.UpstreamRegionalBaseURLs)
+	for i := range c.UpstreamRegionalBaseURLs {
+		c.UpstreamRegionalBaseURLs[i] = redactURL(c.UpstreamRegionalBaseURLs[i])
+	}
+	c.UpstreamFailoverBaseURLs = slices.Clone(c.UpstreamFailoverBaseURLs)
+	for i := range c.UpstreamFailoverBaseURLs {
+		c.UpstreamFailoverBaseURLs[i] = redactURL(c.UpstreamFailoverBaseURLs[i])
+	}
+	c.UpstreamFailoverAPIKeys = slices.Clone(c.UpstreamFailoverAPIKeys)
+	for i := range c.UpstreamFailoverAPIKeys {
+		c.UpstreamFailoverAPIKeys[i] = redacted
+	}
+	c.LocalWhisperServers = slices.Clone(c.LocalWhisperServers)
+	for i := range c.LocalWhisperServers {
+		c.LocalWhisperServers[i].URL = redactURL(c.LocalWhisperServers[i].URL)
+	}
+	return c
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if raw == "" {
+			return ""
+		}
+		return redacted
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Option is one environment variable read by Load. The registry is built
+// from envConfig's struct tags: env names the variable, envDefault gives its
+// default, envSeparator splits a list and desc documents it. Load, the
+// config-schema command and the tests all read the same registry, so the
+// documentation cannot drift from what is parsed.
+type Option struct {
+	Name string
+	// Type is string, boolean, integer, number, duration or list.
+	Type string
+	// Default is the value used when the variable is unset or empty.
+	Default     string
+	Description string
+	// Separator splits a list's elements.
+	Separator string
+
+	field int
+}
+
+var options = registry()
+
+// Options returns every option in declaration order.
+func Options() []Option {
+	return slices.Clone(options)
+}
+
+func registry() []Option {
+	t := reflect.TypeFor[envConfig]()
+	opts := make([]Option, 0, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		opt := Option{
+			Name:        f.Tag.Get("env"),
+			Type:        optionType(f.Type),
+			Default:     f.Tag.Get("envDefault"),
+			Description: f.Tag.Get("desc"),
+			field:       i,
+		}
+		if opt.Type == "list" {
+			opt.Separator = cmp.Or(f.Tag.Get("envSeparator"), ",")
+		}
+		if _, ok := f.Tag.Lookup("envDefault"); !ok {
+			opt.Default = zeroDefault(opt.Type)
+		}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+func optionType(t reflect.Type) string {
+	if t == reflect.TypeFor[time.Duration]() {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list"
+	default:
+		return "string"
+	}
+}
+
+// zeroDefault spells out the zero value an option without envDefault gets.
+func zeroDefault(typ string) string {
+	switch typ {
+	case "boolean":
+		return "false"
+	case "integer", "number":
+		return "0"
+	case "duration":
+		return "0s"
+	default:
+		return ""
+	}
+}
+
+// parseEnv sets each option's field of raw from the environment.
+func parseEnv(raw *envConfig) error {
+	v := reflect.ValueOf(raw).Elem()
+	var errs []error
+	for _, opt := range options {
+		value := cmp.Or(os.Getenv(opt.Name), opt.Default)
+		if value == "" {
+			continue
+		}
+		if err := opt.set(v.Field(opt.field), value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %w", opt.Name, value, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o Option) set(field reflect.Value, value string) error {
+	switch o.Type {
+	case "duration":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case "list":
+		field.Set(reflect.ValueOf(strings.Split(value, o.Separator)))
+	default:
+		field.SetString(value)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteJSONSchema writes a JSON Schema of the environment Load reads, with
+// each option's type, default and description.
+func WriteJSONSchema(w io.Writer) error {
+	props := make(map[string]any, len(options))
+	for _, opt := range options {
+		prop := map[string]any{"description": opt.Description}
+		switch opt.Type {
+		case "list":
+			prop["type"] = "array"
+			prop["items"] = map[string]string{"type": "string"}
+			prop["x-separator"] = opt.Separator
+		case "duration":
+			prop["type"] = "string"
+			prop["format"] = "go-duration"
+		default:
+			prop["type"] = opt.Type
+		}
+		if opt.Default != "" {
+			prop["default"] = opt.typedDefault()
+		}
+		props[opt.Name] = prop
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "EchoFlow configuration",
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": true,
+	})
+}
+
+// typedDefault returns the default as the JSON type the schema gives it.
+// Defaults are checked by the tests, so a parse failure cannot happen here.
+func (o Option) typedDefault() any {
+	switch o.Type {
+	case "boolean":
+		b, _ := strconv.ParseBool(o.Default)
+		return b
+	case "integer":
+		n, _ := strconv.ParseInt(o.Default, 10, 64)
+		return n
+	case "number":
+		f, _ := strconv.ParseFloat(o.Default, 64)
+		return f
+	case "list":
+		return strings.Split(o.Default, o.Separator)
+	default:
+		return o.Default
+	}
+}
+
+// WriteMarkdown writes the options as a Markdown table in declaration
+// order.
+func WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Name | Type | Default | Description |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, opt := range options {
+		typ := opt.Type
+		if opt.Type == "list" {
+			typ = fmt.Sprintf("list (`%s`-separated)", opt.Separator)
+		}
+		def := ""
+		if opt.Default != "" {
+			def = "`" + opt.Default + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", opt.Name, markdownCell(typ), markdownCell(def), markdownCell(opt.Description))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,103 @@
+// Package deadline layers a stage timeout under the caller's context and
+// reports which of the two ended the stage. Without it a nested
+// context.WithTimeout only says "context deadline exceeded", leaving open
+// whether the stage's own timeout or the caller's deadline fired.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sources of an expired deadline.
+const (
+	// SourceStage is the stage's own configured timeout.
+	SourceStage = "stage"
+	// SourceCaller is a deadline set by the caller, such as a gRPC deadline,
+	// a job budget or an enclosing stage.
+	SourceCaller = "caller"
+)
+
+// Error is returned in place of a deadline error that ended a stage. It
+// matches context.DeadlineExceeded with errors.Is.
+type Error struct {
+	Stage  string
+	Source string
+	// Budget is the time the stage had: its timeout, or less when the
+	// caller's deadline was closer.
+	Budget  time.Duration
+	Elapsed time.Duration
+	// Caller is set when the deadline that fired belonged to an enclosing
+	// stage started with Start.
+	Caller *Error
+}
+
+func (e *Error) Error() string {
+	if e.Source == SourceCaller {
+		return fmt.Sprintf("%s deadline exceeded after %s: caller deadline left a %s budget", e.Stage, e.Elapsed.Round(time.Millisecond), e.Budget.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s deadline exceeded after %s: %s timeout", e.Stage, e.Elapsed.Round(time.Millisecond), e.Budget.Round(time.Millisecond))
+}
+
+func (e *Error) Unwrap() error { return context.DeadlineExceeded }
+
+// Budget tracks one stage's deadline.
+type Budget struct {
+	parent  context.Context
+	stage   string
+	timeout time.Duration
+	budget  time.Duration
+	started time.Time
+}
+
+type overrideKey struct{}
+
+// WithOverride makes every stage started under ctx use timeout in place of
+// its configured one, for callers that ask for their own timeout.
+func WithOverride(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, overrideKey{}, timeout)
+}
+
+// Override returns the timeout set with WithOverride, or 0.
+func Override(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(overrideKey{}).(time.Duration)
+	return timeout
+}
+
+// Start derives the stage context. The stage gets timeout, or the override
+// set on parent, or whatever is left of the caller's deadline if that is
+// sooner.
+func Start(parent context.Context, stage string, timeout time.Duration) (context.Context, *Budget, context.CancelFunc) {
+	if override := Override(parent); override > 0 {
+		timeout = override
+	}
+	b := &Budget{parent: parent, stage: stage, timeout: timeout, budget: timeout, started: time.Now()}
+	if d, ok := parent.Deadline(); ok {
+		b.budget = min(b.budget, time.Until(d))
+	}
+	// The cause lets stages started under this one name it as their Caller.
+	cause := &Error{Stage: stage, Source: SourceStage, Budget: b.budget, Elapsed: b.budget}
+	ctx, cancel := context.WithTimeoutCause(parent, timeout, cause)
+	return ctx, b, cancel
+}
+
+// Explain returns err, or an *Error in its place when err is a deadline
+// error, so callers can tell which deadline fired and how long the stage
+// had.
+func (b *Budget) Explain(err error) error {
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var already *Error
+	if errors.As(err, &already) && already.Stage == b.stage {
+		return err
+	}
+	e := &Error{Stage: b.stage, Source: SourceStage, Budget: b.budget, Elapsed: time.Since(b.started)}
+	if b.parent.Err() != nil || b.budget < b.timeout {
+		e.Source = SourceCaller
+		_ = errors.As(context.Cause(b.parent), &e.Caller)
+	}
+	return e
+}
@@ -0,0 +1,74 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExplainReportsStageTimeout(t *testing.T) {
+	ctx, budget, cancel := Start(context.Background(), "transcription", 10*time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	err := budget.Explain(ctx.Err())
+	var deadlineErr *Error
+	if !errors.As(err, &deadlineErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded to match")
+	}
+	if deadlineErr.Stage != "transcription" || deadlineErr.Source != SourceStage || deadlineErr.Budget != 10*time.Millisecond {
+		t.Fatalf("unexpected error: %+v", deadlineErr)
+	}
+}
+
+func TestExplainReportsCallerDeadline(t *testing.T) {
+	parent, outer, cancelParent := Start(context.Background(), "post_processing", 20*time.Millisecond)
+	defer cancelParent()
+	ctx, budget, cancel := Start(parent, "summary", time.Minute)
+	defer cancel()
+	<-ctx.Done()
+
+	var deadlineErr *Error
+	if !errors.As(budget.Explain(ctx.Err()), &deadlineErr) {
+		t.Fatalf("expected *Error")
+	}
+	if deadlineErr.Source != SourceCaller || deadlineErr.Budget > 20*time.Millisecond {
+		t.Fatalf("unexpected error: %+v", deadlineErr)
+	}
+	if deadlineErr.Caller == nil || deadlineErr.Caller.Stage != "post_processing" {
+		t.Fatalf("expected post_processing caller, got %+v", deadlineErr.Caller)
+	}
+
+	// The enclosing stage keeps its own attribution.
+	if err := outer.Explain(budget.Explain(ctx.Err())); !errors.As(err, &deadlineErr) || deadlineErr.Stage != "post_processing" || deadlineErr.Source != SourceStage {
+		t.Fatalf("unexpected outer error: %v", err)
+	}
+}
+
+func TestExplainPassesOtherErrorsThrough(t *testing.T) {
+	_, budget, cancel := Start(context.Background(), "transcription", time.Minute)
+	defer cancel()
+
+	other := errors.New("boom")
+	if err := budget.Explain(other); err != other {
+		t.Fatalf("expected error unchanged, got %v", err)
+	}
+	if err := budget.Explain(context.Canceled); err != context.Canceled {
+		t.Fatalf("expected cancellation unchanged, got %v", err)
+	}
+}
+
+func TestStartUsesOverride(t *testing.T) {
+	ctx, budget, cancel := Start(WithOverride(context.Background(), 10*time.Millisecond), "transcription", time.Minute)
+	defer cancel()
+	<-ctx.Done()
+
+	var deadlineErr *Error
+	if !errors.As(budget.Explain(ctx.Err()), &deadlineErr) || deadlineErr.Source != SourceStage || deadlineErr.Budget != 10*time.Millisecond {
+		t.Fatalf("unexpected error: %+v", deadlineErr)
+	}
+}
@@ -0,0 +1,281 @@
+// Package fetch downloads caller-supplied audio URLs without letting them
+// reach internal services, and reads s3:// and gs:// objects with configured
+// credentials.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxBytes = 25 << 20
+	defaultTimeout  = 30 * time.Second
+	maxRedirects    = 5
+	filePattern     = "echoflow-fetch-*"
+)
+
+var (
+	ErrInvalidURL = errors.New("audio_url must be an absolute http, https, s3 or gs URL")
+	// ErrBlockedAddress is returned when the URL (or a redirect) resolves to a
+	// loopback, private, link-local or otherwise non-public address.
+	ErrBlockedAddress = errors.New("audio_url resolves to a non-public address")
+	ErrTooLarge       = errors.New("remote audio exceeds the size limit")
+)
+
+// StatusError is returned when the remote server answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("remote server returned status %d", e.StatusCode)
+}
+
+type Fetcher struct {
+	client       *http.Client
+	storeClient  *http.Client
+	stores       map[string]ObjectStore
+	maxBytes     int64
+	allowPrivate bool
+}
+
+type Option func(*Fetcher)
+
+func WithMaxBytes(n int64) Option {
+	return func(f *Fetcher) {
+		if n > 0 {
+			f.maxBytes = n
+		}
+	}
+}
+
+func WithTimeout(d time.Duration) Option {
+	return func(f *Fetcher) {
+		if d > 0 {
+			f.client.Timeout = d
+			f.storeClient.Timeout = d
+		}
+	}
+}
+
+// WithPrivateNetworks allows fetching from non-public addresses. Only meant
+// for local development and tests.
+func WithPrivateNetworks(allow bool) Option {
+	return func(f *Fetcher) {
+		f.allowPrivate = allow
+	}
+}
+
+func New(opts ...Option) *Fetcher {
+	f := &Fetcher{maxBytes: defaultMaxBytes, stores: map[string]ObjectStore{}}
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if f.allowPrivate {
+				return nil
+			}
+			return PublicOnly(network, address, c)
+		},
+	}
+	f.client = &http.Client{
+		Timeout: defaultTimeout,
+		Transport: &http.Transport{
+			// No proxy: it would dial on our behalf and bypass the check.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 15 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return checkURL(req.URL)
+		},
+	}
+	// Object store endpoints come from configuration, not callers, so they
+	// may live on private networks (MinIO, VPC endpoints). Redirects are not
+	// followed because they would drop the signature.
+	f.storeClient = &http.Client{
+		Timeout: defaultTimeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 15 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(f)
+		}
+	}
+	return f
+}
+
+// File is downloaded audio spooled to a temp file. Close removes it.
+type File struct {
+	*os.File
+	Name string
+	Size int64
+	// ContentType is the response's Content-Type header.
+	ContentType string
+}
+
+func (f *File) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.File.Name())
+	return err
+}
+
+// Fetch downloads rawURL into a temp file of at most the configured size.
+// s3://bucket/key and gs://bucket/object are read from the configured store.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*File, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	if u.Scheme == "s3" || u.Scheme == "gs" {
+		return f.fetchObject(ctx, u)
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", "EchoFlow-Fetch/1")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, ErrBlockedAddress
+		}
+		return nil, err
+	}
+	return f.save(resp, fileName(resp.Request.URL))
+}
+
+func (f *Fetcher) fetchObject(ctx context.Context, u *url.URL) (*File, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || !validKey(key) || u.User != nil || u.RawQuery != "" {
+		return nil, ErrInvalidURL
+	}
+	store, ok := f.stores[u.Scheme]
+	if !ok {
+		return nil, ErrSourceNotConfigured
+	}
+	if !slices.Contains(store.Buckets, bucket) {
+		return nil, ErrBucketNotAllowed
+	}
+	req, err := store.newRequest(ctx, bucket, key, time.Now())
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", "EchoFlow-Fetch/1")
+	resp, err := f.storeClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return f.save(resp, fileName(u))
+}
+
+// save spools a response body to a temp file and closes the body.
+func (f *Fetcher) save(resp *http.Response, name string) (*File, error) {
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	tmp, err := os.CreateTemp("", filePattern)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{File: tmp, Name: name, ContentType: resp.Header.Get("Content-Type")}
+	file.Size, err = io.Copy(tmp, io.LimitReader(resp.Body, f.maxBytes+1))
+	if err == nil && file.Size > f.maxBytes {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// reserved holds special-purpose ranges that IsGlobalUnicast and IsPrivate
+// don't cover.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// PublicOnly is a net.Dialer Control func that refuses non-public
+// addresses. Checking the address actually dialed, after DNS resolution,
+// also covers redirects and DNS rebinding.
+func PublicOnly(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !IsPublic(addrPort.Addr()) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// IsPublic reports whether addr is a globally routable unicast address.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func fileName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return "audio"
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := IsPublic(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestFetchBlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	_, err := New().Fetch(context.Background(), srv.URL+"/a.wav")
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("err = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestFetchDownloadsWithinLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/calls/call.wav?sig=x", http.StatusFound)
+		case "/calls/call.wav":
+			_, _ = w.Write([]byte("audio-bytes"))
+		case "/big.wav":
+			w.Header().Set("Transfer-Encoding", "chunked")
+			_, _ = w.Write([]byte(strings.Repeat("x", 64)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	f := New(WithPrivateNetworks(true), WithMaxBytes(32))
+
+	file, err := f.Fetch(context.Background(), srv.URL+"/redirect")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	body, _ := io.ReadAll(file)
+	if string(body) != "audio-bytes" || file.Size != 11 || file.Name != "call.wav" {
+		t.Fatalf("file = %q size=%d name=%q", body, file.Size, file.Name)
+	}
+	_ = file.Close()
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/big.wav"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("big file err = %v, want ErrTooLarge", err)
+	}
+	var statusErr *StatusError
+	if _, err := f.Fetch(context.Background(), srv.URL+"/missing"); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing file err = %v, want 404", err)
+	}
+	if _, err := f.Fetch(context.Background(), "file:///etc/passwd"); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("file URL err = %v, want ErrInvalidURL", err)
+	}
+}
@@ -0,0 +1,171 @@
+package fetch
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSourceNotConfigured is returned for s3:// or gs:// URLs when no
+	// credentials were configured for that store.
+	ErrSourceNotConfigured = errors.New("no credentials are configured for this audio_url scheme")
+	// ErrBucketNotAllowed is returned for a bucket missing from the store's
+	// Buckets, before any request is signed.
+	ErrBucketNotAllowed = errors.New("audio_url bucket is not allowed")
+)
+
+const (
+	emptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	gcsEndpoint        = "https://storage.googleapis.com"
+)
+
+// ObjectStore reads objects over the S3 REST API with SigV4-signed GETs.
+// Google Cloud Storage speaks the same protocol with HMAC keys.
+type ObjectStore struct {
+	// Endpoint is the API base URL. Empty means AWS, addressed as
+	// https://<bucket>.s3.<region>.amazonaws.com; otherwise buckets are
+	// path-style (<endpoint>/<bucket>/<key>), as MinIO and GCS expect.
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// Buckets lists the buckets callers may read with these credentials;
+	// every other bucket is refused.
+	Buckets []string
+}
+
+// S3 returns a store for s3:// URLs.
+func S3(store ObjectStore) Option {
+	return func(f *Fetcher) {
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		f.stores["s3"] = store
+	}
+}
+
+// GCS returns a store for gs:// URLs in buckets, authenticated with an HMAC
+// key.
+func GCS(accessID, secret string, buckets []string) Option {
+	return func(f *Fetcher) {
+		f.stores["gs"] = ObjectStore{Endpoint: gcsEndpoint, Region: "auto", AccessKey: accessID, SecretKey: secret, Buckets: buckets}
+	}
+}
+
+// validKey reports whether key is safe to put in a path-style URL. Empty,
+// "." and ".." segments are refused: an endpoint or proxy that cleans the
+// path would otherwise resolve "../other-bucket/..." outside the allowed
+// bucket.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for segment := range strings.SplitSeq(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func (o ObjectStore) objectURL(bucket, key string) string {
+	escaped := escapePath(key)
+	if o.Endpoint == "" {
+		return "https://" + bucket + ".s3." + o.Region + ".amazonaws.com/" + escaped
+	}
+	return strings.TrimRight(o.Endpoint, "/") + "/" + bucket + "/" + escaped
+}
+
+func (o ObjectStore) newRequest(ctx context.Context, bucket, key string, now time.Time) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.objectURL(bucket, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	o.sign(req, now)
+	return req, nil
+}
+
+// sign adds AWS Signature Version 4 headers for an empty-bodied request.
+// Host, Range and X-Amz-* headers are signed.
+func (o ObjectStore) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadSHA256)
+	if o.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", o.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadSHA256,
+	}, "\n")
+	scope := day + "/" + o.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+o.SecretKey), day)
+	for _, part := range []string{o.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+o.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath URI-encodes each segment of an object key as SigV4 requires:
+// everything except unreserved characters is percent-encoded.
+func escapePath(key string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
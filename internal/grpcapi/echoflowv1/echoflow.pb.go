@@ -0,0 +1,915 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v6.32.0
+// source: echoflow/v1/echoflow.proto
+
+package echoflowv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PreprocessOptions struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	TrimSilence bool                   `protobuf:"varint,1,opt,name=trim_silence,json=trimSilence,proto3" json:"trim_silence,omitempty"`
+	Normalize   bool                   `protobuf:"varint,2,opt,name=normalize,proto3" json:"normalize,omitempty"`
+	Downmix     bool                   `protobuf:"varint,3,opt,name=downmix,proto3" json:"downmix,omitempty"`
+	// Target sample rate between 8000 and 48000; 0 keeps the original rate.
+	ResampleHz    int32 `protobuf:"varint,4,opt,name=resample_hz,json=resampleHz,proto3" json:"resample_hz,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PreprocessOptions) Reset() {
+	*x = PreprocessOptions{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PreprocessOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreprocessOptions) ProtoMessage() {}
+
+func (x *PreprocessOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreprocessOptions.ProtoReflect.Descriptor instead.
+func (*PreprocessOptions) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PreprocessOptions) GetTrimSilence() bool {
+	if x != nil {
+		return x.TrimSilence
+	}
+	return false
+}
+
+func (x *PreprocessOptions) GetNormalize() bool {
+	if x != nil {
+		return x.Normalize
+	}
+	return false
+}
+
+func (x *PreprocessOptions) GetDownmix() bool {
+	if x != nil {
+		return x.Downmix
+	}
+	return false
+}
+
+func (x *PreprocessOptions) GetResampleHz() int32 {
+	if x != nil {
+		return x.ResampleHz
+	}
+	return 0
+}
+
+type AudioMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Container     string                 `protobuf:"bytes,1,opt,name=container,proto3" json:"container,omitempty"`
+	DurationMs    int64                  `protobuf:"varint,2,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	SampleRate    int32                  `protobuf:"varint,3,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	Channels      int32                  `protobuf:"varint,4,opt,name=channels,proto3" json:"channels,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AudioMetadata) Reset() {
+	*x = AudioMetadata{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AudioMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AudioMetadata) ProtoMessage() {}
+
+func (x *AudioMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AudioMetadata.ProtoReflect.Descriptor instead.
+func (*AudioMetadata) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AudioMetadata) GetContainer() string {
+	if x != nil {
+		return x.Container
+	}
+	return ""
+}
+
+func (x *AudioMetadata) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *AudioMetadata) GetSampleRate() int32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *AudioMetadata) GetChannels() int32 {
+	if x != nil {
+		return x.Channels
+	}
+	return 0
+}
+
+type TokenUsage struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	PromptTokens     int32                  `protobuf:"varint,1,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	CompletionTokens int32                  `protobuf:"varint,2,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
+	TotalTokens      int32                  `protobuf:"varint,3,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *TokenUsage) Reset() {
+	*x = TokenUsage{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenUsage) ProtoMessage() {}
+
+func (x *TokenUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenUsage.ProtoReflect.Descriptor instead.
+func (*TokenUsage) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TokenUsage) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *TokenUsage) GetCompletionTokens() int32 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+func (x *TokenUsage) GetTotalTokens() int32 {
+	if x != nil {
+		return x.TotalTokens
+	}
+	return 0
+}
+
+type TranscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Audio         []byte                 `protobuf:"bytes,1,opt,name=audio,proto3" json:"audio,omitempty"`
+	Filename      string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	Preprocess    *PreprocessOptions     `protobuf:"bytes,4,opt,name=preprocess,proto3" json:"preprocess,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeRequest) Reset() {
+	*x = TranscribeRequest{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeRequest) ProtoMessage() {}
+
+func (x *TranscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeRequest.ProtoReflect.Descriptor instead.
+func (*TranscribeRequest) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TranscribeRequest) GetAudio() []byte {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+func (x *TranscribeRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetPreprocess() *PreprocessOptions {
+	if x != nil {
+		return x.Preprocess
+	}
+	return nil
+}
+
+type TranscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Audio         *AudioMetadata         `protobuf:"bytes,2,opt,name=audio,proto3" json:"audio,omitempty"`
+	Preprocessing []string               `protobuf:"bytes,3,rep,name=preprocessing,proto3" json:"preprocessing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeResponse) Reset() {
+	*x = TranscribeResponse{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeResponse) ProtoMessage() {}
+
+func (x *TranscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeResponse.ProtoReflect.Descriptor instead.
+func (*TranscribeResponse) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TranscribeResponse) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *TranscribeResponse) GetAudio() *AudioMetadata {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetPreprocessing() []string {
+	if x != nil {
+		return x.Preprocessing
+	}
+	return nil
+}
+
+type PostProcessRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Transcript         string                 `protobuf:"bytes,1,opt,name=transcript,proto3" json:"transcript,omitempty"`
+	ContextSummary     string                 `protobuf:"bytes,2,opt,name=context_summary,json=contextSummary,proto3" json:"context_summary,omitempty"`
+	CustomVocabulary   string                 `protobuf:"bytes,3,opt,name=custom_vocabulary,json=customVocabulary,proto3" json:"custom_vocabulary,omitempty"`
+	CustomSystemPrompt string                 `protobuf:"bytes,4,opt,name=custom_system_prompt,json=customSystemPrompt,proto3" json:"custom_system_prompt,omitempty"`
+	Model              string                 `protobuf:"bytes,5,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *PostProcessRequest) Reset() {
+	*x = PostProcessRequest{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostProcessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostProcessRequest) ProtoMessage() {}
+
+func (x *PostProcessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostProcessRequest.ProtoReflect.Descriptor instead.
+func (*PostProcessRequest) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PostProcessRequest) GetTranscript() string {
+	if x != nil {
+		return x.Transcript
+	}
+	return ""
+}
+
+func (x *PostProcessRequest) GetContextSummary() string {
+	if x != nil {
+		return x.ContextSummary
+	}
+	return ""
+}
+
+func (x *PostProcessRequest) GetCustomVocabulary() string {
+	if x != nil {
+		return x.CustomVocabulary
+	}
+	return ""
+}
+
+func (x *PostProcessRequest) GetCustomSystemPrompt() string {
+	if x != nil {
+		return x.CustomSystemPrompt
+	}
+	return ""
+}
+
+func (x *PostProcessRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type PostProcessResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transcript    string                 `protobuf:"bytes,1,opt,name=transcript,proto3" json:"transcript,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Usage         *TokenUsage            `protobuf:"bytes,3,opt,name=usage,proto3" json:"usage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostProcessResponse) Reset() {
+	*x = PostProcessResponse{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostProcessResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostProcessResponse) ProtoMessage() {}
+
+func (x *PostProcessResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostProcessResponse.ProtoReflect.Descriptor instead.
+func (*PostProcessResponse) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PostProcessResponse) GetTranscript() string {
+	if x != nil {
+		return x.Transcript
+	}
+	return ""
+}
+
+func (x *PostProcessResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PostProcessResponse) GetUsage() *TokenUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+type PipelineRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Audio              []byte                 `protobuf:"bytes,1,opt,name=audio,proto3" json:"audio,omitempty"`
+	Filename           string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	ContextSummary     string                 `protobuf:"bytes,3,opt,name=context_summary,json=contextSummary,proto3" json:"context_summary,omitempty"`
+	CustomVocabulary   string                 `protobuf:"bytes,4,opt,name=custom_vocabulary,json=customVocabulary,proto3" json:"custom_vocabulary,omitempty"`
+	CustomSystemPrompt string                 `protobuf:"bytes,5,opt,name=custom_system_prompt,json=customSystemPrompt,proto3" json:"custom_system_prompt,omitempty"`
+	TranscriptionModel string                 `protobuf:"bytes,6,opt,name=transcription_model,json=transcriptionModel,proto3" json:"transcription_model,omitempty"`
+	PostProcessModel   string                 `protobuf:"bytes,7,opt,name=post_process_model,json=postProcessModel,proto3" json:"post_process_model,omitempty"`
+	Preprocess         *PreprocessOptions     `protobuf:"bytes,8,opt,name=preprocess,proto3" json:"preprocess,omitempty"`
+	SplitChannels      bool                   `protobuf:"varint,9,opt,name=split_channels,json=splitChannels,proto3" json:"split_channels,omitempty"`
+	SpeakerLabels      []string               `protobuf:"bytes,10,rep,name=speaker_labels,json=speakerLabels,proto3" json:"speaker_labels,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *PipelineRequest) Reset() {
+	*x = PipelineRequest{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PipelineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipelineRequest) ProtoMessage() {}
+
+func (x *PipelineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipelineRequest.ProtoReflect.Descriptor instead.
+func (*PipelineRequest) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PipelineRequest) GetAudio() []byte {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+func (x *PipelineRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *PipelineRequest) GetContextSummary() string {
+	if x != nil {
+		return x.ContextSummary
+	}
+	return ""
+}
+
+func (x *PipelineRequest) GetCustomVocabulary() string {
+	if x != nil {
+		return x.CustomVocabulary
+	}
+	return ""
+}
+
+func (x *PipelineRequest) GetCustomSystemPrompt() string {
+	if x != nil {
+		return x.CustomSystemPrompt
+	}
+	return ""
+}
+
+func (x *PipelineRequest) GetTranscriptionModel() string {
+	if x != nil {
+		return x.TranscriptionModel
+	}
+	return ""
+}
+
+func (x *PipelineRequest) GetPostProcessModel() string {
+	if x != nil {
+		return x.PostProcessModel
+	}
+	return ""
+}
+
+func (x *PipelineRequest) GetPreprocess() *PreprocessOptions {
+	if x != nil {
+		return x.Preprocess
+	}
+	return nil
+}
+
+func (x *PipelineRequest) GetSplitChannels() bool {
+	if x != nil {
+		return x.SplitChannels
+	}
+	return false
+}
+
+func (x *PipelineRequest) GetSpeakerLabels() []string {
+	if x != nil {
+		return x.SpeakerLabels
+	}
+	return nil
+}
+
+type PipelineTimings struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	TranscriptionMs  int64                  `protobuf:"varint,1,opt,name=transcription_ms,json=transcriptionMs,proto3" json:"transcription_ms,omitempty"`
+	PostProcessingMs int64                  `protobuf:"varint,2,opt,name=post_processing_ms,json=postProcessingMs,proto3" json:"post_processing_ms,omitempty"`
+	TotalMs          int64                  `protobuf:"varint,3,opt,name=total_ms,json=totalMs,proto3" json:"total_ms,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *PipelineTimings) Reset() {
+	*x = PipelineTimings{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PipelineTimings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipelineTimings) ProtoMessage() {}
+
+func (x *PipelineTimings) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipelineTimings.ProtoReflect.Descriptor instead.
+func (*PipelineTimings) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *PipelineTimings) GetTranscriptionMs() int64 {
+	if x != nil {
+		return x.TranscriptionMs
+	}
+	return 0
+}
+
+func (x *PipelineTimings) GetPostProcessingMs() int64 {
+	if x != nil {
+		return x.PostProcessingMs
+	}
+	return 0
+}
+
+func (x *PipelineTimings) GetTotalMs() int64 {
+	if x != nil {
+		return x.TotalMs
+	}
+	return 0
+}
+
+type PipelineResponse struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	RawTranscript        string                 `protobuf:"bytes,1,opt,name=raw_transcript,json=rawTranscript,proto3" json:"raw_transcript,omitempty"`
+	FinalTranscript      string                 `protobuf:"bytes,2,opt,name=final_transcript,json=finalTranscript,proto3" json:"final_transcript,omitempty"`
+	PostProcessingStatus string                 `protobuf:"bytes,3,opt,name=post_processing_status,json=postProcessingStatus,proto3" json:"post_processing_status,omitempty"`
+	PostProcessingUsage  *TokenUsage            `protobuf:"bytes,4,opt,name=post_processing_usage,json=postProcessingUsage,proto3" json:"post_processing_usage,omitempty"`
+	Audio                *AudioMetadata         `protobuf:"bytes,5,opt,name=audio,proto3" json:"audio,omitempty"`
+	Preprocessing        []string               `protobuf:"bytes,6,rep,name=preprocessing,proto3" json:"preprocessing,omitempty"`
+	Timings              *PipelineTimings       `protobuf:"bytes,7,opt,name=timings,proto3" json:"timings,omitempty"`
+	// How the fallback policy played out: succeeded, succeeded_after_retry or
+	// fallback.
+	PostProcessingOutcome string `protobuf:"bytes,8,opt,name=post_processing_outcome,json=postProcessingOutcome,proto3" json:"post_processing_outcome,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *PipelineResponse) Reset() {
+	*x = PipelineResponse{}
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PipelineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipelineResponse) ProtoMessage() {}
+
+func (x *PipelineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_echoflow_v1_echoflow_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipelineResponse.ProtoReflect.Descriptor instead.
+func (*PipelineResponse) Descriptor() ([]byte, []int) {
+	return file_echoflow_v1_echoflow_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PipelineResponse) GetRawTranscript() string {
+	if x != nil {
+		return x.RawTranscript
+	}
+	return ""
+}
+
+func (x *PipelineResponse) GetFinalTranscript() string {
+	if x != nil {
+		return x.FinalTranscript
+	}
+	return ""
+}
+
+func (x *PipelineResponse) GetPostProcessingStatus() string {
+	if x != nil {
+		return x.PostProcessingStatus
+	}
+	return ""
+}
+
+func (x *PipelineResponse) GetPostProcessingUsage() *TokenUsage {
+	if x != nil {
+		return x.PostProcessingUsage
+	}
+	return nil
+}
+
+func (x *PipelineResponse) GetAudio() *AudioMetadata {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+func (x *PipelineResponse) GetPreprocessing() []string {
+	if x != nil {
+		return x.Preprocessing
+	}
+	return nil
+}
+
+func (x *PipelineResponse) GetTimings() *PipelineTimings {
+	if x != nil {
+		return x.Timings
+	}
+	return nil
+}
+
+func (x *PipelineResponse) GetPostProcessingOutcome() string {
+	if x != nil {
+		return x.PostProcessingOutcome
+	}
+	return ""
+}
+
+var File_echoflow_v1_echoflow_proto protoreflect.FileDescriptor
+
+const file_echoflow_v1_echoflow_proto_rawDesc = "" +
+	"\n" +
+	"\x1aechoflow/v1/echoflow.proto\x12\vechoflow.v1\"\x8f\x01\n" +
+	"\x11PreprocessOptions\x12!\n" +
+	"\ftrim_silence\x18\x01 \x01(\bR\vtrimSilence\x12\x1c\n" +
+	"\tnormalize\x18\x02 \x01(\bR\tnormalize\x12\x18\n" +
+	"\adownmix\x18\x03 \x01(\bR\adownmix\x12\x1f\n" +
+	"\vresample_hz\x18\x04 \x01(\x05R\n" +
+	"resampleHz\"\x8b\x01\n" +
+	"\rAudioMetadata\x12\x1c\n" +
+	"\tcontainer\x18\x01 \x01(\tR\tcontainer\x12\x1f\n" +
+	"\vduration_ms\x18\x02 \x01(\x03R\n" +
+	"durationMs\x12\x1f\n" +
+	"\vsample_rate\x18\x03 \x01(\x05R\n" +
+	"sampleRate\x12\x1a\n" +
+	"\bchannels\x18\x04 \x01(\x05R\bchannels\"\x81\x01\n" +
+	"\n" +
+	"TokenUsage\x12#\n" +
+	"\rprompt_tokens\x18\x01 \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x02 \x01(\x05R\x10completionTokens\x12!\n" +
+	"\ftotal_tokens\x18\x03 \x01(\x05R\vtotalTokens\"\x9b\x01\n" +
+	"\x11TranscribeRequest\x12\x14\n" +
+	"\x05audio\x18\x01 \x01(\fR\x05audio\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12>\n" +
+	"\n" +
+	"preprocess\x18\x04 \x01(\v2\x1e.echoflow.v1.PreprocessOptionsR\n" +
+	"preprocess\"\x80\x01\n" +
+	"\x12TranscribeResponse\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x120\n" +
+	"\x05audio\x18\x02 \x01(\v2\x1a.echoflow.v1.AudioMetadataR\x05audio\x12$\n" +
+	"\rpreprocessing\x18\x03 \x03(\tR\rpreprocessing\"\xd2\x01\n" +
+	"\x12PostProcessRequest\x12\x1e\n" +
+	"\n" +
+	"transcript\x18\x01 \x01(\tR\n" +
+	"transcript\x12'\n" +
+	"\x0fcontext_summary\x18\x02 \x01(\tR\x0econtextSummary\x12+\n" +
+	"\x11custom_vocabulary\x18\x03 \x01(\tR\x10customVocabulary\x120\n" +
+	"\x14custom_system_prompt\x18\x04 \x01(\tR\x12customSystemPrompt\x12\x14\n" +
+	"\x05model\x18\x05 \x01(\tR\x05model\"|\n" +
+	"\x13PostProcessResponse\x12\x1e\n" +
+	"\n" +
+	"transcript\x18\x01 \x01(\tR\n" +
+	"transcript\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12-\n" +
+	"\x05usage\x18\x03 \x01(\v2\x17.echoflow.v1.TokenUsageR\x05usage\"\xb8\x03\n" +
+	"\x0fPipelineRequest\x12\x14\n" +
+	"\x05audio\x18\x01 \x01(\fR\x05audio\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12'\n" +
+	"\x0fcontext_summary\x18\x03 \x01(\tR\x0econtextSummary\x12+\n" +
+	"\x11custom_vocabulary\x18\x04 \x01(\tR\x10customVocabulary\x120\n" +
+	"\x14custom_system_prompt\x18\x05 \x01(\tR\x12customSystemPrompt\x12/\n" +
+	"\x13transcription_model\x18\x06 \x01(\tR\x12transcriptionModel\x12,\n" +
+	"\x12post_process_model\x18\a \x01(\tR\x10postProcessModel\x12>\n" +
+	"\n" +
+	"preprocess\x18\b \x01(\v2\x1e.echoflow.v1.PreprocessOptionsR\n" +
+	"preprocess\x12%\n" +
+	"\x0esplit_channels\x18\t \x01(\bR\rsplitChannels\x12%\n" +
+	"\x0espeaker_labels\x18\n" +
+	" \x03(\tR\rspeakerLabels\"\x85\x01\n" +
+	"\x0fPipelineTimings\x12)\n" +
+	"\x10transcription_ms\x18\x01 \x01(\x03R\x0ftranscriptionMs\x12,\n" +
+	"\x12post_processing_ms\x18\x02 \x01(\x03R\x10postProcessingMs\x12\x19\n" +
+	"\btotal_ms\x18\x03 \x01(\x03R\atotalMs\"\xaf\x03\n" +
+	"\x10PipelineResponse\x12%\n" +
+	"\x0eraw_transcript\x18\x01 \x01(\tR\rrawTranscript\x12)\n" +
+	"\x10final_transcript\x18\x02 \x01(\tR\x0ffinalTranscript\x124\n" +
+	"\x16post_processing_status\x18\x03 \x01(\tR\x14postProcessingStatus\x12K\n" +
+	"\x15post_processing_usage\x18\x04 \x01(\v2\x17.echoflow.v1.TokenUsageR\x13postProcessingUsage\x120\n" +
+	"\x05audio\x18\x05 \x01(\v2\x1a.echoflow.v1.AudioMetadataR\x05audio\x12$\n" +
+	"\rpreprocessing\x18\x06 \x03(\tR\rpreprocessing\x126\n" +
+	"\atimings\x18\a \x01(\v2\x1c.echoflow.v1.PipelineTimingsR\atimings\x126\n" +
+	"\x17post_processing_outcome\x18\b \x01(\tR\x15postProcessingOutcome2\xcb\x02\n" +
+	"\bEchoFlow\x12M\n" +
+	"\n" +
+	"Transcribe\x12\x1e.echoflow.v1.TranscribeRequest\x1a\x1f.echoflow.v1.TranscribeResponse\x12U\n" +
+	"\x10TranscribeStream\x12\x1e.echoflow.v1.TranscribeRequest\x1a\x1f.echoflow.v1.TranscribeResponse(\x01\x12P\n" +
+	"\vPostProcess\x12\x1f.echoflow.v1.PostProcessRequest\x1a .echoflow.v1.PostProcessResponse\x12G\n" +
+	"\bPipeline\x12\x1c.echoflow.v1.PipelineRequest\x1a\x1d.echoflow.v1.PipelineResponseB1Z/echoflow/internal/grpcapi/echoflowv1;echoflowv1b\x06proto3"
+
+var (
+	file_echoflow_v1_echoflow_proto_rawDescOnce sync.Once
+	file_echoflow_v1_echoflow_proto_rawDescData []byte
+)
+
+func file_echoflow_v1_echoflow_proto_rawDescGZIP() []byte {
+	file_echoflow_v1_echoflow_proto_rawDescOnce.Do(func() {
+		file_echoflow_v1_echoflow_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_echoflow_v1_echoflow_proto_rawDesc), len(file_echoflow_v1_echoflow_proto_rawDesc)))
+	})
+	return file_echoflow_v1_echoflow_proto_rawDescData
+}
+
+var file_echoflow_v1_echoflow_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_echoflow_v1_echoflow_proto_goTypes = []any{
+	(*PreprocessOptions)(nil),   // 0: echoflow.v1.PreprocessOptions
+	(*AudioMetadata)(nil),       // 1: echoflow.v1.AudioMetadata
+	(*TokenUsage)(nil),          // 2: echoflow.v1.TokenUsage
+	(*TranscribeRequest)(nil),   // 3: echoflow.v1.TranscribeRequest
+	(*TranscribeResponse)(nil),  // 4: echoflow.v1.TranscribeResponse
+	(*PostProcessRequest)(nil),  // 5: echoflow.v1.PostProcessRequest
+	(*PostProcessResponse)(nil), // 6: echoflow.v1.PostProcessResponse
+	(*PipelineRequest)(nil),     // 7: echoflow.v1.PipelineRequest
+	(*PipelineTimings)(nil),     // 8: echoflow.v1.PipelineTimings
+	(*PipelineResponse)(nil),    // 9: echoflow.v1.PipelineResponse
+}
+var file_echoflow_v1_echoflow_proto_depIdxs = []int32{
+	0,  // 0: echoflow.v1.TranscribeRequest.preprocess:type_name -> echoflow.v1.PreprocessOptions
+	1,  // 1: echoflow.v1.TranscribeResponse.audio:type_name -> echoflow.v1.AudioMetadata
+	2,  // 2: echoflow.v1.PostProcessResponse.usage:type_name -> echoflow.v1.TokenUsage
+	0,  // 3: echoflow.v1.PipelineRequest.preprocess:type_name -> echoflow.v1.PreprocessOptions
+	2,  // 4: echoflow.v1.PipelineResponse.post_processing_usage:type_name -> echoflow.v1.TokenUsage
+	1,  // 5: echoflow.v1.PipelineResponse.audio:type_name -> echoflow.v1.AudioMetadata
+	8,  // 6: echoflow.v1.PipelineResponse.timings:type_name -> echoflow.v1.PipelineTimings
+	3,  // 7: echoflow.v1.EchoFlow.Transcribe:input_type -> echoflow.v1.TranscribeRequest
+	3,  // 8: echoflow.v1.EchoFlow.TranscribeStream:input_type -> echoflow.v1.TranscribeRequest
+	5,  // 9: echoflow.v1.EchoFlow.PostProcess:input_type -> echoflow.v1.PostProcessRequest
+	7,  // 10: echoflow.v1.EchoFlow.Pipeline:input_type -> echoflow.v1.PipelineRequest
+	4,  // 11: echoflow.v1.EchoFlow.Transcribe:output_type -> echoflow.v1.TranscribeResponse
+	4,  // 12: echoflow.v1.EchoFlow.TranscribeStream:output_type -> echoflow.v1.TranscribeResponse
+	6,  // 13: echoflow.v1.EchoFlow.PostProcess:output_type -> echoflow.v1.PostProcessResponse
+	9,  // 14: echoflow.v1.EchoFlow.Pipeline:output_type -> echoflow.v1.PipelineResponse
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_echoflow_v1_echoflow_proto_init() }
+func file_echoflow_v1_echoflow_proto_init() {
+	if File_echoflow_v1_echoflow_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_echoflow_v1_echoflow_proto_rawDesc), len(file_echoflow_v1_echoflow_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_echoflow_v1_echoflow_proto_goTypes,
+		DependencyIndexes: file_echoflow_v1_echoflow_proto_depIdxs,
+		MessageInfos:      file_echoflow_v1_echoflow_proto_msgTypes,
+	}.Build()
+	File_echoflow_v1_echoflow_proto = out.File
+	file_echoflow_v1_echoflow_proto_goTypes = nil
+	file_echoflow_v1_echoflow_proto_depIdxs = nil
+}
@@ -0,0 +1,241 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v6.32.0
+// source: echoflow/v1/echoflow.proto
+
+package echoflowv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EchoFlow_Transcribe_FullMethodName       = "/echoflow.v1.EchoFlow/Transcribe"
+	EchoFlow_TranscribeStream_FullMethodName = "/echoflow.v1.EchoFlow/TranscribeStream"
+	EchoFlow_PostProcess_FullMethodName      = "/echoflow.v1.EchoFlow/PostProcess"
+	EchoFlow_Pipeline_FullMethodName         = "/echoflow.v1.EchoFlow/Pipeline"
+)
+
+// EchoFlowClient is the client API for EchoFlow service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// EchoFlow mirrors the /v1 HTTP API. Callers authenticate with an
+// "authorization: Bearer <groq_cloud_token>" metadata entry unless the server
+// has UPSTREAM_API_KEY configured.
+type EchoFlowClient interface {
+	Transcribe(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (*TranscribeResponse, error)
+	// TranscribeStream accepts audio split across messages. Options are read
+	// from the first message; later messages only contribute audio bytes.
+	TranscribeStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TranscribeRequest, TranscribeResponse], error)
+	PostProcess(ctx context.Context, in *PostProcessRequest, opts ...grpc.CallOption) (*PostProcessResponse, error)
+	Pipeline(ctx context.Context, in *PipelineRequest, opts ...grpc.CallOption) (*PipelineResponse, error)
+}
+
+type echoFlowClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEchoFlowClient(cc grpc.ClientConnInterface) EchoFlowClient {
+	return &echoFlowClient{cc}
+}
+
+func (c *echoFlowClient) Transcribe(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (*TranscribeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TranscribeResponse)
+	err := c.cc.Invoke(ctx, EchoFlow_Transcribe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoFlowClient) TranscribeStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TranscribeRequest, TranscribeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EchoFlow_ServiceDesc.Streams[0], EchoFlow_TranscribeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TranscribeRequest, TranscribeResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EchoFlow_TranscribeStreamClient = grpc.ClientStreamingClient[TranscribeRequest, TranscribeResponse]
+
+func (c *echoFlowClient) PostProcess(ctx context.Context, in *PostProcessRequest, opts ...grpc.CallOption) (*PostProcessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PostProcessResponse)
+	err := c.cc.Invoke(ctx, EchoFlow_PostProcess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoFlowClient) Pipeline(ctx context.Context, in *PipelineRequest, opts ...grpc.CallOption) (*PipelineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PipelineResponse)
+	err := c.cc.Invoke(ctx, EchoFlow_Pipeline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EchoFlowServer is the server API for EchoFlow service.
+// All implementations must embed UnimplementedEchoFlowServer
+// for forward compatibility.
+//
+// EchoFlow mirrors the /v1 HTTP API. Callers authenticate with an
+// "authorization: Bearer <groq_cloud_token>" metadata entry unless the server
+// has UPSTREAM_API_KEY configured.
+type EchoFlowServer interface {
+	Transcribe(context.Context, *TranscribeRequest) (*TranscribeResponse, error)
+	// TranscribeStream accepts audio split across messages. Options are read
+	// from the first message; later messages only contribute audio bytes.
+	TranscribeStream(grpc.ClientStreamingServer[TranscribeRequest, TranscribeResponse]) error
+	PostProcess(context.Context, *PostProcessRequest) (*PostProcessResponse, error)
+	Pipeline(context.Context, *PipelineRequest) (*PipelineResponse, error)
+	mustEmbedUnimplementedEchoFlowServer()
+}
+
+// UnimplementedEchoFlowServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEchoFlowServer struct{}
+
+func (UnimplementedEchoFlowServer) Transcribe(context.Context, *TranscribeRequest) (*TranscribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Transcribe not implemented")
+}
+func (UnimplementedEchoFlowServer) TranscribeStream(grpc.ClientStreamingServer[TranscribeRequest, TranscribeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method TranscribeStream not implemented")
+}
+func (UnimplementedEchoFlowServer) PostProcess(context.Context, *PostProcessRequest) (*PostProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PostProcess not implemented")
+}
+func (UnimplementedEchoFlowServer) Pipeline(context.Context, *PipelineRequest) (*PipelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pipeline not implemented")
+}
+func (UnimplementedEchoFlowServer) mustEmbedUnimplementedEchoFlowServer() {}
+func (UnimplementedEchoFlowServer) testEmbeddedByValue()                  {}
+
+// UnsafeEchoFlowServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EchoFlowServer will
+// result in compilation errors.
+type UnsafeEchoFlowServer interface {
+	mustEmbedUnimplementedEchoFlowServer()
+}
+
+func RegisterEchoFlowServer(s grpc.ServiceRegistrar, srv EchoFlowServer) {
+	// If the following call pancis, it indicates UnimplementedEchoFlowServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EchoFlow_ServiceDesc, srv)
+}
+
+func _EchoFlow_Transcribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EchoFlowServer).Transcribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EchoFlow_Transcribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EchoFlowServer).Transcribe(ctx, req.(*TranscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EchoFlow_TranscribeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EchoFlowServer).TranscribeStream(&grpc.GenericServerStream[TranscribeRequest, TranscribeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EchoFlow_TranscribeStreamServer = grpc.ClientStreamingServer[TranscribeRequest, TranscribeResponse]
+
+func _EchoFlow_PostProcess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PostProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EchoFlowServer).PostProcess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EchoFlow_PostProcess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EchoFlowServer).PostProcess(ctx, req.(*PostProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EchoFlow_Pipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PipelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EchoFlowServer).Pipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EchoFlow_Pipeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EchoFlowServer).Pipeline(ctx, req.(*PipelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EchoFlow_ServiceDesc is the grpc.ServiceDesc for EchoFlow service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EchoFlow_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "echoflow.v1.EchoFlow",
+	HandlerType: (*EchoFlowServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Transcribe",
+			Handler:    _EchoFlow_Transcribe_Handler,
+		},
+		{
+			MethodName: "PostProcess",
+			Handler:    _EchoFlow_PostProcess_Handler,
+		},
+		{
+			MethodName: "Pipeline",
+			Handler:    _EchoFlow_Pipeline_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "TranscribeStream",
+			Handler:       _EchoFlow_TranscribeStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "echoflow/v1/echoflow.proto",
+}
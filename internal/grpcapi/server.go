@@ -0,0 +1,505 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"echoflow/internal/acronyms"
+	"echoflow/internal/audio"
+	"echoflow/internal/auth"
+	"echoflow/internal/config"
+	pb "echoflow/internal/grpcapi/echoflowv1"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type TranscriptionService interface {
+	Transcribe(ctx context.Context, in transcription.Input) (transcription.Result, error)
+}
+
+type PostProcessService interface {
+	Process(ctx context.Context, in postprocess.Input) (postprocess.Result, error)
+}
+
+type PipelineService interface {
+	Process(ctx context.Context, in pipeline.ProcessInput) (pipeline.ProcessResult, error)
+}
+
+// MetricsObserver is the part of the HTTP API's metrics the gRPC API feeds.
+type MetricsObserver interface {
+	ObserveAudioDuration(duration time.Duration)
+}
+
+type Dependencies struct {
+	Transcription TranscriptionService
+	PostProcess   PostProcessService
+	Pipeline      PipelineService
+	// Tokens and Quotas are shared with the HTTP API.
+	Tokens *auth.Registry
+	Quotas *auth.Quotas
+	// Acronyms is shared with the HTTP API. gRPC requests always apply the
+	// tenant's dictionary.
+	Acronyms *acronyms.Store
+	// Metrics is optional.
+	Metrics MetricsObserver
+}
+
+type server struct {
+	pb.UnimplementedEchoFlowServer
+
+	cfg         config.Config
+	logger      *slog.Logger
+	transcriber TranscriptionService
+	postProcess PostProcessService
+	pipeline    PipelineService
+	tokens      *auth.Registry
+	quotas      *auth.Quotas
+	acronyms    *acronyms.Store
+	metrics     MetricsObserver
+}
+
+// maxMessageOverhead leaves room for the non-audio request fields on top of
+// MAX_UPLOAD_BYTES of audio.
+const maxMessageOverhead = 64 << 10
+
+func NewServer(cfg config.Config, logger *slog.Logger, deps Dependencies) *grpc.Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if deps.Transcription == nil || deps.PostProcess == nil || deps.Pipeline == nil {
+		panic("grpcapi: all dependencies are required")
+	}
+
+	s := &server{
+		cfg:         cfg,
+		logger:      logger,
+		transcriber: deps.Transcription,
+		postProcess: deps.PostProcess,
+		pipeline:    deps.Pipeline,
+		tokens:      deps.Tokens,
+		acronyms:    deps.Acronyms,
+		quotas:      deps.Quotas,
+		metrics:     deps.Metrics,
+	}
+
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(int(cfg.MaxUploadBytes)+maxMessageOverhead),
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	pb.RegisterEchoFlowServer(srv, s)
+	return srv
+}
+
+func (s *server) Transcribe(ctx context.Context, req *pb.TranscribeRequest) (*pb.TranscribeResponse, error) {
+	return s.transcribe(ctx, req, req.GetAudio())
+}
+
+func (s *server) TranscribeStream(stream grpc.ClientStreamingServer[pb.TranscribeRequest, pb.TranscribeResponse]) error {
+	var (
+		first *pb.TranscribeRequest
+		buf   bytes.Buffer
+	)
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = msg
+		}
+		if int64(buf.Len()+len(msg.GetAudio())) > s.cfg.MaxUploadBytes {
+			return status.Errorf(codes.ResourceExhausted, "audio exceeds %d bytes", s.cfg.MaxUploadBytes)
+		}
+		buf.Write(msg.GetAudio())
+	}
+	if first == nil {
+		return status.Error(codes.InvalidArgument, "audio is required")
+	}
+
+	resp, err := s.transcribe(stream.Context(), first, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+func (s *server) transcribe(ctx context.Context, req *pb.TranscribeRequest, data []byte) (*pb.TranscribeResponse, error) {
+	if len(data) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "audio is required")
+	}
+	preprocess, err := preprocessOptions(req.GetPreprocess())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.checkModels(req.GetModel()); err != nil {
+		return nil, toStatusError(err)
+	}
+
+	file := bytes.NewReader(data)
+	audioMeta := probeAudio(file)
+	if err := s.checkDuration(audioMeta); err != nil {
+		return nil, toStatusError(err)
+	}
+	result, err := s.transcriber.Transcribe(ctx, transcription.Input{
+		File:       file,
+		FileName:   s.fileName(req.GetFilename(), audioMeta),
+		Size:       int64(len(data)),
+		Model:      strings.TrimSpace(req.GetModel()),
+		Preprocess: preprocess,
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	return &pb.TranscribeResponse{
+		Text:          result.Text,
+		Audio:         audioMeta,
+		Preprocessing: result.Preprocessing,
+	}, nil
+}
+
+func (s *server) PostProcess(ctx context.Context, req *pb.PostProcessRequest) (*pb.PostProcessResponse, error) {
+	if strings.TrimSpace(req.GetTranscript()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "transcript is required")
+	}
+	if err := s.checkModels(req.GetModel()); err != nil {
+		return nil, toStatusError(err)
+	}
+
+	result, err := s.postProcess.Process(ctx, postprocess.Input{
+		Transcript:         req.GetTranscript(),
+		ContextSummary:     req.GetContextSummary(),
+		CustomVocabulary:   req.GetCustomVocabulary(),
+		CustomSystemPrompt: req.GetCustomSystemPrompt(),
+		Model:              req.GetModel(),
+		Acronyms:           s.tenantAcronyms(ctx),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	return &pb.PostProcessResponse{
+		Transcript: result.Transcript,
+		Status:     "post-processing succeeded",
+		Usage:      toTokenUsage(result.Usage),
+	}, nil
+}
+
+func (s *server) Pipeline(ctx context.Context, req *pb.PipelineRequest) (*pb.PipelineResponse, error) {
+	data := req.GetAudio()
+	if len(data) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "audio is required")
+	}
+	preprocess, err := preprocessOptions(req.GetPreprocess())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.checkModels(req.GetTranscriptionModel(), req.GetPostProcessModel()); err != nil {
+		return nil, toStatusError(err)
+	}
+
+	file := bytes.NewReader(data)
+	audioMeta := probeAudio(file)
+	if err := s.checkDuration(audioMeta); err != nil {
+		return nil, toStatusError(err)
+	}
+	result, err := s.pipeline.Process(ctx, pipeline.ProcessInput{
+		File:               file,
+		FileName:           s.fileName(req.GetFilename(), audioMeta),
+		FileSize:           int64(len(data)),
+		SplitChannels:      req.GetSplitChannels(),
+		ChannelLabels:      req.GetSpeakerLabels(),
+		Preprocess:         preprocess,
+		ContextSummary:     req.GetContextSummary(),
+		CustomVocabulary:   req.GetCustomVocabulary(),
+		CustomSystemPrompt: req.GetCustomSystemPrompt(),
+		TranscriptionModel: req.GetTranscriptionModel(),
+		PostProcessModel:   req.GetPostProcessModel(),
+		Acronyms:           s.tenantAcronyms(ctx),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	return &pb.PipelineResponse{
+		RawTranscript:         result.RawTranscript,
+		FinalTranscript:       result.FinalTranscript,
+		PostProcessingStatus:  result.PostProcessingStatus,
+		PostProcessingOutcome: result.PostProcessingOutcome,
+		PostProcessingUsage:   toTokenUsage(result.PostProcessingUsage),
+		Audio:                 audioMeta,
+		Preprocessing:         result.Preprocessing,
+		Timings: &pb.PipelineTimings{
+			TranscriptionMs:  result.Timings.Transcription.Milliseconds(),
+			PostProcessingMs: result.Timings.PostProcessing.Milliseconds(),
+			TotalMs:          result.Timings.Total.Milliseconds(),
+		},
+	}, nil
+}
+
+func (s *server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.logger.Error("panic recovered", "method", info.FullMethod, "panic", rec, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal server error")
+		}
+		s.logCall(info.FullMethod, start, err)
+	}()
+
+	ctx, err = s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *server) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.logger.Error("panic recovered", "method", info.FullMethod, "panic", rec, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal server error")
+		}
+		s.logCall(info.FullMethod, start, err)
+	}()
+
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// methodScopes maps RPCs to the token scope they require.
+var methodScopes = map[string]string{
+	pb.EchoFlow_Transcribe_FullMethodName:       auth.ScopeTranscribe,
+	pb.EchoFlow_TranscribeStream_FullMethodName: auth.ScopeTranscribe,
+	pb.EchoFlow_PostProcess_FullMethodName:      auth.ScopePostProcess,
+	pb.EchoFlow_Pipeline_FullMethodName:         auth.ScopePipeline,
+}
+
+// authenticate applies the same rules as the HTTP API: EchoFlow-issued tokens
+// use the server-side key, any other bearer token is forwarded upstream, and
+// the token is only optional when a server-side key is configured.
+func (s *server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	var header, baseURL, upstreamKey string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = strings.TrimSpace(values[0])
+		}
+		if values := md.Get("x-upstream-base-url"); len(values) > 0 {
+			baseURL = values[0]
+		}
+		if values := md.Get("x-upstream-api-key"); len(values) > 0 {
+			upstreamKey = values[0]
+		}
+	}
+	var token string
+	if header == "" {
+		if s.cfg.UpstreamAPIKey == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing Groq Cloud bearer token")
+		}
+	} else {
+		var ok bool
+		token, ok = strings.CutPrefix(header, "Bearer ")
+		token = strings.TrimSpace(token)
+		if !ok || token == "" {
+			return nil, status.Error(codes.Unauthenticated, "authorization must be Bearer <groq_cloud_token>")
+		}
+	}
+
+	id := s.tokens.Resolve(token)
+	if s.cfg.IsPremiumToken(token) {
+		id.Tier = transcription.TierPremium
+	}
+	if scope, ok := methodScopes[method]; ok && !id.HasScope(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "token %q lacks the %q scope", id.Name, scope)
+	}
+	if baseURL != "" {
+		var err error
+		if baseURL, err = id.CheckUpstreamBaseURL(baseURL); errors.Is(err, auth.ErrUpstreamBaseURLNotAllowed) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		} else if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if !s.quotas.Take(id) {
+		return nil, status.Error(codes.ResourceExhausted, "daily request quota exhausted")
+	}
+
+	ctx = auth.WithIdentity(ctx, id)
+	ctx = reqctx.WithTenant(ctx, id.Tenant)
+	if baseURL != "" {
+		ctx = upstream.WithRequestBaseURL(ctx, baseURL)
+		ctx = upstream.WithRequestAPIKey(ctx, upstreamKey)
+		ctx = reqctx.WithAPIKeySource(ctx, reqctx.KeySourceCaller)
+	} else if id.Kind == auth.KindBYOT {
+		ctx = upstream.WithRequestAPIKey(ctx, token)
+		ctx = reqctx.WithAPIKeySource(ctx, reqctx.KeySourceCaller)
+	} else {
+		ctx = reqctx.WithAPIKeySource(ctx, reqctx.KeySourceServer)
+	}
+	if id.Tier == transcription.TierPremium {
+		ctx = reqctx.WithPriority(ctx, transcription.TierPremium)
+	}
+	if len(id.Languages.Allowed) > 0 {
+		ctx = transcription.WithLanguagePolicy(ctx, id.Languages)
+	}
+	attrs := []any{"route", method}
+	if id.Tenant != "" {
+		attrs = append(attrs, "tenant", id.Tenant)
+	}
+	ctx = reqctx.WithLogger(ctx, s.logger.With(attrs...))
+	return ctx, nil
+}
+
+func (s *server) logCall(method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+	s.logger.Log(context.Background(), level, "grpc request",
+		"method", method,
+		"code", code.String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// toStatusError mirrors the HTTP error mapping onto gRPC status codes.
+func toStatusError(err error) error {
+	var upstreamErr *upstream.Error
+	var languageErr *transcription.LanguageError
+	var modelErr *upstream.ModelError
+	var durationErr *audio.DurationError
+	switch {
+	case errors.As(err, &modelErr):
+		return status.Error(codes.InvalidArgument, modelErr.Error())
+	case errors.As(err, &durationErr):
+		return status.Error(codes.ResourceExhausted, durationErr.Error())
+	case errors.As(err, &languageErr):
+		return status.Error(codes.FailedPrecondition, languageErr.Error())
+	case errors.Is(err, upstream.ErrServerKeyOnly):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, audio.ErrTranscodeUnavailable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, audio.ErrUnsupportedFormat):
+		return status.Error(codes.InvalidArgument, "audio format is not supported for this operation")
+	case errors.Is(err, audio.ErrInvalidAudio):
+		return status.Error(codes.InvalidArgument, "audio could not be processed")
+	case errors.As(err, &upstreamErr):
+		return status.Errorf(codes.Unavailable, "upstream request failed: upstream status %d", upstreamErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	default:
+		return status.Error(codes.Internal, "request failed")
+	}
+}
+
+func preprocessOptions(opts *pb.PreprocessOptions) (audio.PreprocessOptions, error) {
+	if opts == nil {
+		return audio.PreprocessOptions{}, nil
+	}
+	rate := int(opts.GetResampleHz())
+	if rate != 0 && (rate < 8000 || rate > 48000) {
+		return audio.PreprocessOptions{}, fmt.Errorf("resample_hz must be between 8000 and 48000")
+	}
+	return audio.PreprocessOptions{
+		TrimSilence: opts.GetTrimSilence(),
+		Normalize:   opts.GetNormalize(),
+		Downmix:     opts.GetDownmix(),
+		SampleRate:  rate,
+	}, nil
+}
+
+// fileName gives the audio an extension the upstream can pick a decoder
+// from, going by its contents since gRPC requests carry no content type.
+func (s *server) fileName(name string, meta *pb.AudioMetadata) string {
+	return audio.FileName(name, "", meta.GetContainer(), s.cfg.UploadDefaultFileName)
+}
+
+// checkModels applies the same model name rules as the HTTP API.
+func (s *server) checkModels(models ...string) error {
+	for _, m := range models {
+		if err := upstream.CheckModel(strings.TrimSpace(m), s.cfg.ModelAllowlist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDuration records the probed duration and applies MAX_AUDIO_SECONDS,
+// as the HTTP API does. Audio whose headers give no duration is let through.
+func (s *server) checkDuration(meta *pb.AudioMetadata) error {
+	if meta.GetDurationMs() <= 0 {
+		return nil
+	}
+	duration := time.Duration(meta.GetDurationMs()) * time.Millisecond
+	if s.metrics != nil {
+		s.metrics.ObserveAudioDuration(duration)
+	}
+	return audio.CheckDuration(duration, s.cfg.MaxAudioDuration)
+}
+
+func probeAudio(file *bytes.Reader) *pb.AudioMetadata {
+	meta, err := audio.Probe(file, file.Size())
+	if err != nil {
+		return nil
+	}
+	return &pb.AudioMetadata{
+		Container:  meta.Container,
+		DurationMs: meta.Duration.Milliseconds(),
+		SampleRate: int32(meta.SampleRate),
+		Channels:   int32(meta.Channels),
+	}
+}
+
+func toTokenUsage(u *postprocess.TokenUsage) *pb.TokenUsage {
+	if u == nil {
+		return nil
+	}
+	return &pb.TokenUsage{
+		PromptTokens:     int32(u.PromptTokens),
+		CompletionTokens: int32(u.CompletionTokens),
+		TotalTokens:      int32(u.TotalTokens),
+	}
+}
+
+func (s *server) tenantAcronyms(ctx context.Context) map[string]string {
+	id, _ := auth.IdentityFromContext(ctx)
+	if id.Tenant == "" {
+		return nil
+	}
+	return s.acronyms.Dictionary(id.Tenant)
+}
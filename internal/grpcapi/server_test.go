@@ -0,0 +1,225 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/config"
+	pb "echoflow/internal/grpcapi/echoflowv1"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type stubTranscription struct {
+	text     string
+	err      error
+	fileBody string
+	apiKey   string
+}
+
+func (s *stubTranscription) Transcribe(ctx context.Context, in transcription.Input) (transcription.Result, error) {
+	body, _ := io.ReadAll(in.File)
+	s.fileBody = string(body)
+	s.apiKey = upstream.RequestAPIKeyFromContext(ctx)
+	return transcription.Result{Text: s.text}, s.err
+}
+
+type stubPostProcess struct {
+	result postprocess.Result
+	err    error
+	input  postprocess.Input
+}
+
+func (s *stubPostProcess) Process(_ context.Context, in postprocess.Input) (postprocess.Result, error) {
+	s.input = in
+	return s.result, s.err
+}
+
+type stubPipeline struct {
+	result   pipeline.ProcessResult
+	err      error
+	input    pipeline.ProcessInput
+	fileBody string
+}
+
+func (s *stubPipeline) Process(_ context.Context, in pipeline.ProcessInput) (pipeline.ProcessResult, error) {
+	s.input = in
+	body, _ := io.ReadAll(in.File)
+	s.fileBody = string(body)
+	return s.result, s.err
+}
+
+func newTestClient(t *testing.T, cfg config.Config, deps Dependencies) pb.EchoFlowClient {
+	t.Helper()
+	if deps.Transcription == nil {
+		deps.Transcription = &stubTranscription{}
+	}
+	if deps.PostProcess == nil {
+		deps.PostProcess = &stubPostProcess{}
+	}
+	if deps.Pipeline == nil {
+		deps.Pipeline = &stubPipeline{}
+	}
+	if cfg.MaxUploadBytes == 0 {
+		cfg.MaxUploadBytes = 1024 * 1024
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), deps)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewEchoFlowClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestTranscribeForwardsBearerToken(t *testing.T) {
+	tr := &stubTranscription{text: "hello"}
+	client := newTestClient(t, config.Config{}, Dependencies{Transcription: tr})
+
+	resp, err := client.Transcribe(withToken("user-token"), &pb.TranscribeRequest{Audio: []byte("audio-bytes"), Filename: "a.wav"})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if resp.GetText() != "hello" {
+		t.Fatalf("unexpected text: %q", resp.GetText())
+	}
+	if tr.fileBody != "audio-bytes" || tr.apiKey != "user-token" {
+		t.Fatalf("unexpected forwarded input: body=%q key=%q", tr.fileBody, tr.apiKey)
+	}
+}
+
+type durationMetrics struct {
+	observed []time.Duration
+}
+
+func (m *durationMetrics) ObserveAudioDuration(d time.Duration) {
+	m.observed = append(m.observed, d)
+}
+
+func TestTranscribeObservesAudioDuration(t *testing.T) {
+	metrics := &durationMetrics{}
+	client := newTestClient(t, config.Config{}, Dependencies{Metrics: metrics})
+
+	wav := audio.FromSamples([][]float64{make([]float64, 2*8000)}, 8000).Encode()
+	if _, err := client.Transcribe(withToken("user-token"), &pb.TranscribeRequest{Audio: wav, Filename: "a.wav"}); err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if len(metrics.observed) != 1 || metrics.observed[0] != 2*time.Second {
+		t.Fatalf("observed durations = %v", metrics.observed)
+	}
+}
+
+func TestTranscribeRequiresTokenWithoutServerKey(t *testing.T) {
+	client := newTestClient(t, config.Config{}, Dependencies{})
+
+	_, err := client.Transcribe(context.Background(), &pb.TranscribeRequest{Audio: []byte("x")})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestTranscribeStreamJoinsChunks(t *testing.T) {
+	tr := &stubTranscription{text: "joined"}
+	client := newTestClient(t, config.Config{UpstreamAPIKey: "server"}, Dependencies{Transcription: tr})
+
+	stream, err := client.TranscribeStream(context.Background())
+	if err != nil {
+		t.Fatalf("TranscribeStream: %v", err)
+	}
+	for i, chunk := range []string{"one-", "two-", "three"} {
+		msg := &pb.TranscribeRequest{Audio: []byte(chunk)}
+		if i == 0 {
+			msg.Filename = "call.wav"
+		}
+		if err := stream.Send(msg); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv: %v", err)
+	}
+	if resp.GetText() != "joined" || tr.fileBody != "one-two-three" {
+		t.Fatalf("unexpected result: text=%q body=%q", resp.GetText(), tr.fileBody)
+	}
+}
+
+func TestTranscribeStreamRejectsOversizedAudio(t *testing.T) {
+	client := newTestClient(t, config.Config{UpstreamAPIKey: "server", MaxUploadBytes: 8}, Dependencies{})
+
+	stream, err := client.TranscribeStream(context.Background())
+	if err != nil {
+		t.Fatalf("TranscribeStream: %v", err)
+	}
+	for range 3 {
+		if err := stream.Send(&pb.TranscribeRequest{Audio: []byte("12345")}); err != nil {
+			break
+		}
+	}
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestPipelineMapsUpstreamErrors(t *testing.T) {
+	pl := &stubPipeline{err: &upstream.Error{StatusCode: 500, Body: "boom"}}
+	client := newTestClient(t, config.Config{UpstreamAPIKey: "server"}, Dependencies{Pipeline: pl})
+
+	_, err := client.Pipeline(context.Background(), &pb.PipelineRequest{
+		Audio:          []byte("audio"),
+		ContextSummary: "meeting",
+		SpeakerLabels:  []string{"caller", "agent"},
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	if pl.fileBody != "audio" || pl.input.ContextSummary != "meeting" || len(pl.input.ChannelLabels) != 2 {
+		t.Fatalf("unexpected pipeline input: %+v", pl.input)
+	}
+}
+
+func TestPostProcessReturnsUsage(t *testing.T) {
+	pp := &stubPostProcess{result: postprocess.Result{
+		Transcript: "cleaned",
+		Usage:      &postprocess.TokenUsage{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6},
+	}}
+	client := newTestClient(t, config.Config{UpstreamAPIKey: "server"}, Dependencies{PostProcess: pp})
+
+	resp, err := client.PostProcess(context.Background(), &pb.PostProcessRequest{Transcript: "raw"})
+	if err != nil {
+		t.Fatalf("PostProcess: %v", err)
+	}
+	if resp.GetTranscript() != "cleaned" || resp.GetUsage().GetTotalTokens() != 6 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	if _, err := client.PostProcess(context.Background(), &pb.PostProcessRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for empty transcript, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"echoflow/internal/acronyms"
+	"echoflow/internal/auth"
+	"echoflow/internal/model"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// tenantAcronyms returns the caller's tenant dictionary, or nil when
+// expansion is off or the caller has no tenant.
+func (s *server) tenantAcronyms(ctx context.Context, enabled bool) map[string]string {
+	id, _ := auth.IdentityFromContext(ctx)
+	if !enabled || id.Tenant == "" {
+		return nil
+	}
+	return s.acronyms.Dictionary(id.Tenant)
+}
+
+// requestTenant writes 403 when the caller is not an EchoFlow token with a
+// tenant, since dictionaries are shared by all of a tenant's tokens.
+func (s *server) requestTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, _ := auth.IdentityFromContext(r.Context())
+	if id.Tenant == "" {
+		s.writeError(w, r, http.StatusForbidden, "tenant_required", "acronym dictionaries require an EchoFlow token with a tenant", nil)
+		return "", false
+	}
+	return id.Tenant, true
+}
+
+func (s *server) handleListAcronyms(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.requestTenant(w, r)
+	if !ok {
+		return
+	}
+	dict := s.acronyms.Dictionary(tenant)
+	if dict == nil {
+		dict = map[string]string{}
+	}
+	writeCacheableJSON(w, r, model.AcronymDictionary{Acronyms: dict}, time.Time{})
+}
+
+func (s *server) handlePutAcronym(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.requestTenant(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	defer func() { _ = r.Body.Close() }()
+
+	var body struct {
+		Expansion string `json:"expansion"`
+	}
+	v := &validator{}
+	if err := v.decode(r.Body, &body); err != nil {
+		s.handleJSONDecodeError(w, r, err)
+		return
+	}
+	if v.failed() {
+		s.writeValidationError(w, r, v)
+		return
+	}
+
+	term := strings.TrimSpace(chi.URLParam(r, "acronym"))
+	err := s.acronyms.Set(tenant, term, body.Expansion)
+	switch {
+	case errors.Is(err, acronyms.ErrInvalidEntry), errors.Is(err, acronyms.ErrTooManyEntries):
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	case err != nil:
+		s.writeMappedError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, model.AcronymEntry{Acronym: term, Expansion: strings.TrimSpace(body.Expansion)})
+}
+
+func (s *server) handleDeleteAcronym(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.requestTenant(w, r)
+	if !ok {
+		return
+	}
+	found, err := s.acronyms.Delete(tenant, strings.TrimSpace(chi.URLParam(r, "acronym")))
+	if err != nil {
+		s.writeMappedError(w, r, err)
+		return
+	}
+	if !found {
+		s.writeError(w, r, http.StatusNotFound, "not_found", "acronym not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
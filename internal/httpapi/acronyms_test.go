@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"echoflow/internal/model"
+)
+
+func TestAcronymDictionaryIsTenantScopedAndAppliedToPostProcess(t *testing.T) {
+	postProcess := &stubPostProcess{}
+	h := newAuthTestHandler(t, postProcess)
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPut, "/v1/acronyms/EOD", "ef_acme", `{"expansion":"end of day"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("PUT without acronyms_write status = %d body=%s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/v1/acronyms/EOD", "ef_acme_admin", `{"expansion":"end of day"}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d body=%s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/v1/acronyms/EOD", "ef_acme_admin", `{"expansion":""}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT without expansion status = %d", w.Code)
+	}
+	w := do(http.MethodGet, "/v1/acronyms", "ef_acme", "")
+	var dict model.AcronymDictionary
+	if err := json.Unmarshal(w.Body.Bytes(), &dict); err != nil || dict.Acronyms["EOD"] != "end of day" {
+		t.Fatalf("GET = %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/v1/acronyms", "gsk_caller_token", ""); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "tenant_required") {
+		t.Fatalf("BYOT GET = %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodPost, "/v1/post-process", "ef_acme", `{"transcript":"send it EOD"}`); w.Code != http.StatusOK {
+		t.Fatalf("post-process status = %d body=%s", w.Code, w.Body.String())
+	}
+	if postProcess.input.Acronyms["EOD"] != "end of day" {
+		t.Fatalf("post-process acronyms = %v", postProcess.input.Acronyms)
+	}
+
+	if w := do(http.MethodDelete, "/v1/acronyms/EOD", "ef_acme", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("DELETE without acronyms_write status = %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/v1/acronyms/EOD", "ef_acme_admin", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/v1/acronyms/EOD", "ef_acme_admin", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("second DELETE status = %d", w.Code)
+	}
+}
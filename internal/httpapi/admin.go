@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+type KeyRotator interface {
+	RotateAPIKey(ctx context.Context, key string, grace time.Duration) error
+	// PinAPIKey keeps a request on the key that was current when it arrived.
+	PinAPIKey(ctx context.Context) context.Context
+}
+
+const adminPathPrefix = "/admin/"
+
+// adminMiddleware guards /admin routes with ADMIN_TOKEN. The bearer token here
+// is never forwarded upstream.
+func (s *server) adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, ok := extractBearerToken(r.Header.Get("Authorization"))
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) != 1 {
+			s.writeError(w, r, http.StatusUnauthorized, "unauthorized", "admin token required", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) handleRotateUpstreamKey(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	defer func() { _ = r.Body.Close() }()
+
+	var req model.RotateUpstreamKeyRequest
+	v := &validator{}
+	if err := v.decode(r.Body, &req); err != nil {
+		s.handleJSONDecodeError(w, r, err)
+		return
+	}
+	v.required("api_key", req.APIKey)
+	if v.failed() {
+		s.writeValidationError(w, r, v)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	grace := s.cfg.UpstreamKeyRotationGrace
+	if err := s.keys.RotateAPIKey(ctx, req.APIKey, grace); err != nil {
+		var upstreamErr *upstream.Error
+		if errors.As(err, &upstreamErr) {
+			s.writeError(w, r, http.StatusBadRequest, "upstream_key_rejected", "upstream rejected the new key; the current key is unchanged",
+				map[string]any{"upstream_status": upstreamErr.StatusCode})
+			return
+		}
+		s.writeMappedError(w, r, err)
+		return
+	}
+
+	reqctx.Logger(r.Context()).Info("upstream key rotated", "grace_seconds", grace.Seconds())
+	writeJSON(w, http.StatusOK, model.RotateUpstreamKeyResponse{
+		OK:                    true,
+		PreviousKeyValidUntil: time.Now().Add(grace).UTC(),
+	})
+}
@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/config"
+	"echoflow/internal/model"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/upstream"
+)
+
+type stubKeys struct {
+	err     error
+	rotated string
+	grace   time.Duration
+}
+
+func (s *stubKeys) RotateAPIKey(_ context.Context, key string, grace time.Duration) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.rotated, s.grace = key, grace
+	return nil
+}
+
+func (s *stubKeys) PinAPIKey(ctx context.Context) context.Context { return ctx }
+
+func newAdminTestHandler(keys KeyRotator) http.Handler {
+	cfg := config.Config{
+		MaxUploadBytes:           1024 * 1024,
+		UpstreamAPIKey:           "x",
+		AdminToken:               "admin-secret",
+		UpstreamKeyRotationGrace: 30 * time.Second,
+	}
+	return NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Keys:          keys,
+	})
+}
+
+func TestRotateUpstreamKeyRequiresAdminToken(t *testing.T) {
+	keys := &stubKeys{}
+	h := newAdminTestHandler(keys)
+
+	for _, auth := range []string{"", "Bearer gsk_caller_token"} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/upstream-key", strings.NewReader(`{"api_key":"new"}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("auth %q: status = %d body=%s", auth, w.Code, w.Body.String())
+		}
+	}
+	if keys.rotated != "" {
+		t.Fatalf("key rotated without admin token: %q", keys.rotated)
+	}
+}
+
+func TestRotateUpstreamKey(t *testing.T) {
+	keys := &stubKeys{}
+	h := newAdminTestHandler(keys)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/upstream-key", strings.NewReader(`{"api_key":"gsk_new"}`))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	if keys.rotated != "gsk_new" || keys.grace != 30*time.Second {
+		t.Fatalf("rotated = %q grace = %v", keys.rotated, keys.grace)
+	}
+	if strings.Contains(w.Body.String(), "gsk_new") {
+		t.Fatalf("response echoes the key: %s", w.Body.String())
+	}
+}
+
+func TestRotateUpstreamKeyRejectedUpstream(t *testing.T) {
+	h := newAdminTestHandler(&stubKeys{err: &upstream.Error{StatusCode: http.StatusUnauthorized}})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/upstream-key", strings.NewReader(`{"api_key":"gsk_bad"}`))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"upstream_key_rejected"`) {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestSelftestReportsEachStage(t *testing.T) {
+	transcriber := &stubTranscription{text: ""}
+	h := NewServer(config.Config{MaxUploadBytes: 1024 * 1024, UpstreamAPIKey: "x", AdminToken: "admin-secret"},
+		slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+			Transcription: transcriber,
+			PostProcess:   &stubPostProcess{result: postprocess.Result{Transcript: "The deploy is done."}},
+			Pipeline:      &stubPipeline{},
+			Upstream:      stubUpstream{err: &upstream.Error{StatusCode: http.StatusUnauthorized}},
+		})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/selftest", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp model.SelftestResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, stage := range resp.Stages {
+		names = append(names, stage.Name)
+	}
+	if resp.OK || strings.Join(names, ",") != "upstream,transcription,post_processing,pipeline" {
+		t.Fatalf("response = %+v", resp)
+	}
+	if up := resp.Stages[0]; up.OK || up.Error == nil || up.Error.Code != "upstream_request_failed" {
+		t.Fatalf("upstream stage = %+v", up)
+	}
+	if pp := resp.Stages[2]; !pp.OK || pp.Output != "The deploy is done." {
+		t.Fatalf("post_processing stage = %+v", pp)
+	}
+	if !strings.HasPrefix(transcriber.fileBody, "RIFF") {
+		t.Fatalf("transcription got %d bytes of non-WAV audio", len(transcriber.fileBody))
+	}
+}
+
+type stubLogs []string
+
+func (s stubLogs) Recent() []string { return s }
+
+func TestDiagnosticsBundle(t *testing.T) {
+	h := NewServer(config.Config{MaxUploadBytes: 1024 * 1024, UpstreamAPIKey: "gsk_secret", AdminToken: "admin-secret"},
+		slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+			Transcription: &stubTranscription{},
+			PostProcess:   &stubPostProcess{},
+			Pipeline:      &stubPipeline{},
+			Upstream:      stubUpstream{},
+			Logs:          stubLogs{"level=ERROR msg=boom\n"},
+		})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("status = %d type=%q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+	for _, name := range []string{"config.json", "build.json", "goroutines.txt", "logs.txt"} {
+		if files[name] == "" {
+			t.Errorf("%s missing or empty", name)
+		}
+	}
+	if strings.Contains(files["config.json"], "gsk_secret") || strings.Contains(files["config.json"], "admin-secret") {
+		t.Fatal("config.json leaks secrets")
+	}
+	if !strings.Contains(files["logs.txt"], "msg=boom") || !strings.Contains(files["goroutines.txt"], "goroutine") {
+		t.Fatalf("logs=%q", files["logs.txt"])
+	}
+}
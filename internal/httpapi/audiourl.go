@@ -0,0 +1,134 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/fetch"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
+)
+
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// readPipelineURLRequest handles the JSON form of the pipeline endpoints,
+// downloading audio_url in place of a multipart upload.
+func (s *server) readPipelineURLRequest(w http.ResponseWriter, r *http.Request) (*pipelineRequest, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	defer func() { _ = r.Body.Close() }()
+
+	var body model.PipelineURLRequest
+	v := &validator{}
+	if err := v.decode(r.Body, &body); err != nil {
+		s.handleJSONDecodeError(w, r, err)
+		return nil, false
+	}
+	v.required("audio_url", body.AudioURL)
+	if body.ResampleHz != 0 && !validSampleRate(body.ResampleHz) {
+		v.check("resample_hz", "out_of_range", "8000-48000", errSampleRate)
+	}
+	timeout, err := s.requestTimeout(body.TimeoutMS)
+	v.check("timeout_ms", "out_of_range", ">= 1", err)
+	v.check("language", "invalid_value", "ISO 639-1 code", transcription.CheckLanguage(body.Language))
+	words, err := parseTimestampGranularities(body.TimestampGranularities)
+	v.check("timestamp_granularities", "invalid_value", "segment, word", err)
+	format, err := parseResponseFormat(body.ResponseFormat, pipelineFormats)
+	v.check("response_format", "invalid_value", strings.Join(pipelineFormats, ", "), err)
+	v.check("fallback_policy", "invalid_value", strings.Join([]string{pipeline.FallbackRaw, pipeline.FallbackError, pipeline.FallbackRetry}, ", "), pipeline.CheckFallbackPolicy(body.FallbackPolicy))
+	if v.failed() {
+		s.writeValidationError(w, r, v)
+		return nil, false
+	}
+	if err := s.checkModels(body.TranscriptionModel, body.PostProcessModel); err != nil {
+		s.writeMappedError(w, r, err)
+		return nil, false
+	}
+
+	file, err := s.fetcher.Fetch(r.Context(), strings.TrimSpace(body.AudioURL))
+	if err != nil {
+		s.writeFetchError(w, r, err)
+		return nil, false
+	}
+
+	meta := probeUpload(file, file.Size)
+	if err := s.checkAudioDuration(meta); err != nil {
+		_ = file.Close()
+		s.writeMappedError(w, r, err)
+		return nil, false
+	}
+	req := &pipelineRequest{
+		input: pipeline.ProcessInput{
+			File:          file,
+			FileName:      s.uploadFileName(file.Name, file.ContentType, meta),
+			FileSize:      file.Size,
+			SplitChannels: body.SplitChannels,
+			ChannelLabels: body.SpeakerLabels,
+			Preprocess: audio.PreprocessOptions{
+				TrimSilence: body.TrimSilence,
+				Normalize:   body.Normalize,
+				Downmix:     body.Downmix,
+				SampleRate:  body.ResampleHz,
+				Transcode:   body.Transcode,
+			},
+			ContextSummary:      body.ContextSummary,
+			CustomVocabulary:    body.CustomVocabulary,
+			CustomSystemPrompt:  body.CustomSystemPrompt,
+			TranscriptionModel:  body.TranscriptionModel,
+			TranscriptionPrompt: body.TranscriptionPrompt,
+			WordTimestamps:      words,
+			Segments:            isSubtitleFormat(format),
+			PostProcessModel:    body.PostProcessModel,
+			Language:            strings.TrimSpace(body.Language),
+			Stages:              body.Stages,
+			Acronyms:            s.tenantAcronyms(r.Context(), body.ExpandAcronyms == nil || *body.ExpandAcronyms),
+			EchoAudio:           body.ReturnAudio,
+			FallbackPolicy:      body.FallbackPolicy,
+			IncludeDebug:        body.IncludeDebug,
+		},
+		audio:       meta,
+		budget:      s.pipelineBudget(file.Size, timeout),
+		timeout:     timeout,
+		format:      format,
+		callbackURL: strings.TrimSpace(body.CallbackURL),
+		closers:     []func(){func() { _ = file.Close() }},
+	}
+	req.input.CollapseSpellings = s.cfg.PostProcessSpellings
+	if body.CollapseSpellings != nil {
+		req.input.CollapseSpellings = *body.CollapseSpellings
+	}
+	req.input.SpellingsToVocabulary = body.SpellingsToVocabulary
+	return req, true
+}
+
+func (s *server) writeFetchError(w http.ResponseWriter, r *http.Request, err error) {
+	var statusErr *fetch.StatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, fetch.ErrInvalidURL):
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+	case errors.Is(err, fetch.ErrSourceNotConfigured):
+		s.writeError(w, r, http.StatusBadRequest, "audio_url_unsupported", err.Error(), nil)
+	case errors.Is(err, fetch.ErrBlockedAddress), errors.Is(err, fetch.ErrBucketNotAllowed):
+		s.writeError(w, r, http.StatusBadRequest, "audio_url_blocked", err.Error(), nil)
+	case errors.Is(err, fetch.ErrTooLarge):
+		s.writeError(w, r, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("remote audio exceeds %d bytes", s.cfg.MaxUploadBytes), nil)
+	case errors.As(err, &statusErr):
+		s.writeError(w, r, http.StatusBadRequest, "audio_url_fetch_failed", "audio_url could not be downloaded",
+			map[string]any{"remote_status": statusErr.StatusCode})
+	case errors.As(err, &netErr) && netErr.Timeout():
+		s.writeError(w, r, http.StatusGatewayTimeout, "timeout", "audio_url download timed out", nil)
+	default:
+		reqctx.Logger(r.Context()).Warn("audio_url fetch failed", "error", err)
+		s.writeError(w, r, http.StatusBadRequest, "audio_url_fetch_failed", "audio_url could not be downloaded", nil)
+	}
+}
@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"echoflow/internal/fetch"
+	"echoflow/internal/pipeline"
+)
+
+type stubFetcher struct {
+	data string
+	err  error
+	url  string
+}
+
+func (s *stubFetcher) Fetch(_ context.Context, url string) (*fetch.File, error) {
+	s.url = url
+	if s.err != nil {
+		return nil, s.err
+	}
+	f, err := os.CreateTemp("", "echoflow-test-*")
+	if err != nil {
+		return nil, err
+	}
+	_, _ = f.WriteString(s.data)
+	_, _ = f.Seek(0, 0)
+	return &fetch.File{File: f, Name: "call.wav", Size: int64(len(s.data))}, nil
+}
+
+func TestPipelineProcessFetchesAudioURL(t *testing.T) {
+	pipe := &stubPipeline{result: pipeline.ProcessResult{FinalTranscript: "Hello."}}
+	fetcher := &stubFetcher{data: "remote-audio"}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      pipe,
+		Upstream:      stubUpstream{},
+		Fetcher:       fetcher,
+	})
+
+	body := `{"audio_url":"https://cdn.example.com/call.wav","context_summary":"support call","speaker_labels":["Caller"],"resample_hz":16000}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	if fetcher.url != "https://cdn.example.com/call.wav" {
+		t.Fatalf("fetched %q", fetcher.url)
+	}
+	in := pipe.input
+	if pipe.fileBody != "remote-audio" || in.FileName != "call.wav" || in.ContextSummary != "support call" ||
+		in.Preprocess.SampleRate != 16000 || len(in.ChannelLabels) != 1 {
+		t.Fatalf("pipeline input = %+v body=%q", in, pipe.fileBody)
+	}
+}
+
+func TestPipelineProcessRejectsBlockedAudioURL(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Fetcher:       &stubFetcher{err: fetch.ErrBlockedAddress},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", strings.NewReader(`{"audio_url":"http://169.254.169.254/latest"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "audio_url_blocked") {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/reqctx"
+)
+
+const (
+	defaultBatchMaxFiles    = 16
+	defaultBatchConcurrency = 4
+)
+
+// handlePipelineBatch runs every `file` part through the pipeline as its own
+// recording, BATCH_CONCURRENCY at a time. Per-file failures are reported in
+// the results rather than failing the whole batch.
+func (s *server) handlePipelineBatch(w http.ResponseWriter, r *http.Request) {
+	first, _, form, err := s.readMultipartAudio(w, r, pipelineFields)
+	if err != nil {
+		cleanupMultipartForm(form)
+		s.handleMultipartReadError(w, r, err)
+		return
+	}
+	defer cleanupMultipartForm(form)
+	_ = first.Close()
+
+	files := form.File["file"]
+	maxFiles := s.cfg.BatchMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultBatchMaxFiles
+	}
+	if len(files) > maxFiles {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", fmt.Sprintf("at most %d files are allowed per batch", maxFiles), nil)
+		return
+	}
+	opts, err := s.parsePipelineOptions(r)
+	if err != nil {
+		s.writeOptionsError(w, r, err)
+		return
+	}
+	if opts.EchoAudio {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", errReturnAudioUnsupported, nil)
+		return
+	}
+	if format := strings.TrimSpace(r.FormValue("response_format")); format != "" && format != responseFormatJSON {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", errResponseFormatUnsupported, nil)
+		return
+	}
+	if len(opts.ChannelLabels) > 0 {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", "speaker_labels is not supported for batches", nil)
+		return
+	}
+	timeout, err := s.formTimeout(r)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+	if !s.takeQuota(w, r, len(files)) {
+		return
+	}
+	r = withTimeout(r, timeout)
+
+	concurrency := s.cfg.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	var largest int64
+	for _, fh := range files {
+		largest = max(largest, fh.Size)
+	}
+	waves := (len(files) + concurrency - 1) / concurrency
+	s.startProcessingDeadline(w, time.Duration(waves)*s.pipelineBudget(largest, timeout))
+
+	results := make([]model.PipelineBatchResult, len(files))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, fh := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = s.processBatchFile(r, i, fh, opts)
+		}()
+	}
+	wg.Wait()
+
+	resp := model.PipelineBatchResponse{Results: results}
+	var usage requestUsage
+	for _, result := range results {
+		if result.Error != nil {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+			usage.add(pipelineUsage(*result.Result))
+		}
+	}
+	s.setUsageHeaders(w, r, usage)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) processBatchFile(r *http.Request, index int, fh *multipart.FileHeader, opts pipeline.ProcessInput) model.PipelineBatchResult {
+	result := model.PipelineBatchResult{Index: index, FileName: fh.Filename}
+	fail := func(err error) model.PipelineBatchResult {
+		status, apiErr := mapError(err)
+		result.Status, result.Error = status, &apiErr
+		return result
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		return fail(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	meta := probeUpload(file, fh.Size)
+	if err := s.checkAudioDuration(meta); err != nil {
+		return fail(err)
+	}
+	in := opts
+	in.File, in.FileName, in.FileSize = file, s.uploadFileName(fh.Filename, fh.Header.Get("Content-Type"), meta), fh.Size
+	processed, err := s.pipeline.Process(r.Context(), in)
+	if err != nil {
+		reqctx.Logger(r.Context()).Warn("batch file failed", "index", index, "file", fh.Filename, "error", err)
+		return fail(err)
+	}
+	s.observePipeline(processed)
+
+	resp := toPipelineResponse(processed, meta)
+	result.Status, result.Result = http.StatusOK, &resp
+	return result
+}
@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/auth"
+	"echoflow/internal/config"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+// batchPipeline echoes each file's contents and fails files named bad*.
+type batchPipeline struct {
+	running, peak atomic.Int32
+	summaries     atomic.Int32
+}
+
+func (p *batchPipeline) Process(_ context.Context, in pipeline.ProcessInput) (pipeline.ProcessResult, error) {
+	n := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if in.ContextSummary == "meeting" {
+		p.summaries.Add(1)
+	}
+	if strings.HasPrefix(in.FileName, "bad") {
+		return pipeline.ProcessResult{}, audio.ErrInvalidAudio
+	}
+	body, _ := io.ReadAll(in.File)
+	return pipeline.ProcessResult{RawTranscript: string(body), FinalTranscript: strings.ToUpper(string(body))}, nil
+}
+
+func TestPipelineBatchBoundsConcurrencyAndReportsPerFileErrors(t *testing.T) {
+	svc := &batchPipeline{}
+	cfg := config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x", BatchMaxFiles: 8, BatchConcurrency: 2}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      svc,
+		Upstream:      stubUpstream{},
+	})
+
+	names := []string{"one.wav", "bad.wav", "three.wav", "four.wav", "five.wav"}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("context_summary", "meeting")
+	for _, name := range names {
+		part, _ := mw.CreateFormFile("file", name)
+		_, _ = part.Write([]byte(strings.TrimSuffix(name, ".wav")))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/batch", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+
+	var resp model.PipelineBatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Succeeded != 4 || resp.Failed != 1 || len(resp.Results) != len(names) {
+		t.Fatalf("response = %+v", resp)
+	}
+	for i, result := range resp.Results {
+		if result.Index != i || result.FileName != names[i] {
+			t.Fatalf("result %d = %+v", i, result)
+		}
+	}
+	if bad := resp.Results[1]; bad.Error == nil || bad.Error.Code != "invalid_audio" || bad.Status != http.StatusBadRequest {
+		t.Fatalf("bad file result = %+v", bad)
+	}
+	if got := resp.Results[2].Result; got == nil || got.FinalTranscript != "THREE" {
+		t.Fatalf("three.wav result = %+v", got)
+	}
+	if peak := svc.peak.Load(); peak > 2 {
+		t.Fatalf("peak concurrency = %d, want <= 2", peak)
+	}
+	if svc.summaries.Load() != int32(len(names)) {
+		t.Fatalf("context_summary applied to %d files", svc.summaries.Load())
+	}
+}
+
+func TestPipelineBatchRejectsTooManyFiles(t *testing.T) {
+	cfg := config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x", BatchMaxFiles: 1}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &batchPipeline{},
+		Upstream:      stubUpstream{},
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, name := range []string{"a.wav", "b.wav"} {
+		part, _ := mw.CreateFormFile("file", name)
+		_, _ = part.Write([]byte("x"))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/batch", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestPipelineBatchChargesQuotaPerFile(t *testing.T) {
+	sum := sha256.Sum256([]byte("ef_batch"))
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tokens := `{"tokens":[{"name":"batch","sha256":"` + hex.EncodeToString(sum[:]) + `","scopes":["pipeline"],"daily_request_quota":3}]}`
+	if err := os.WriteFile(path, []byte(tokens), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := auth.LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x"}
+	svc := &batchPipeline{}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      svc,
+		Upstream:      stubUpstream{},
+		Tokens:        registry,
+		Quotas:        auth.NewQuotas(),
+	})
+
+	post := func(files int) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for range files {
+			part, _ := mw.CreateFormFile("file", "a.wav")
+			_, _ = part.Write([]byte("a"))
+		}
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/batch", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer ef_batch")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(2); w.Code != http.StatusOK {
+		t.Fatalf("first batch: status = %d body=%s", w.Code, w.Body.String())
+	}
+	// One request is left; a batch of two must not run, nor use it up.
+	if w := post(2); w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "quota_exceeded") {
+		t.Fatalf("second batch: status = %d body=%s", w.Code, w.Body.String())
+	}
+	if w := post(1); w.Code != http.StatusOK {
+		t.Fatalf("last file: status = %d body=%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/subtitle"
+)
+
+var chapterFormats = []string{responseFormatJSON, responseFormatVTT}
+
+// handleChapters splits a timed transcript, such as the segments of a
+// verbose_json transcription, into titled chapters.
+func (s *server) handleChapters(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	defer func() { _ = r.Body.Close() }()
+
+	var req model.ChaptersRequest
+	v := &validator{}
+	if err := v.decode(r.Body, &req); err != nil {
+		s.handleJSONDecodeError(w, r, err)
+		return
+	}
+	segments, err := chapterSegments(req.Segments)
+	v.check("segments", "invalid_value", "0 <= start <= end", err)
+	if len(segments) == 0 && !v.has("segments") {
+		v.add("segments", "required", "non-empty", "segments is required")
+	}
+	if req.MaxChapters < 0 {
+		v.add("max_chapters", "out_of_range", ">= 0", "max_chapters must be >= 0")
+	}
+	format, err := parseResponseFormat(req.ResponseFormat, chapterFormats)
+	v.check("response_format", "invalid_value", strings.Join(chapterFormats, ", "), err)
+	timeout, err := s.requestTimeout(req.TimeoutMS)
+	v.check("timeout_ms", "out_of_range", ">= 1", err)
+	if v.failed() {
+		s.writeValidationError(w, r, v)
+		return
+	}
+	if err := s.checkModels(req.Model); err != nil {
+		s.writeMappedError(w, r, err)
+		return
+	}
+	r = withTimeout(r, timeout)
+	s.startProcessingDeadline(w, cmp.Or(timeout, s.cfg.PostProcessTimeout))
+
+	result, err := s.chapters.Chapters(r.Context(), postprocess.ChaptersInput{
+		Segments:    segments,
+		Model:       req.Model,
+		MaxChapters: req.MaxChapters,
+	})
+	if err != nil {
+		s.writeMappedError(w, r, err)
+		return
+	}
+
+	var usage requestUsage
+	usage.addTokens(toModelTokenUsage(result.Usage))
+	s.setUsageHeaders(w, r, usage)
+	if format == responseFormatVTT {
+		cues := make([]subtitle.Cue, 0, len(result.Chapters))
+		for _, ch := range result.Chapters {
+			cues = append(cues, subtitle.Cue{Start: ch.Start, End: ch.End, Text: ch.Title})
+		}
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, subtitle.VTT(cues))
+		return
+	}
+	resp := model.ChaptersResponse{Chapters: make([]model.Chapter, 0, len(result.Chapters)), Usage: toModelTokenUsage(result.Usage)}
+	for _, ch := range result.Chapters {
+		resp.Chapters = append(resp.Chapters, model.Chapter{Start: ch.Start.Seconds(), End: ch.End.Seconds(), Title: ch.Title})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// chapterSegments converts request segments, skipping ones without text.
+func chapterSegments(in []model.TranscriptionSegment) ([]postprocess.ChapterSegment, error) {
+	out := make([]postprocess.ChapterSegment, 0, len(in))
+	for _, seg := range in {
+		if seg.Start < 0 || seg.End < seg.Start {
+			return nil, errors.New("segments must have 0 <= start <= end")
+		}
+		if strings.TrimSpace(seg.Text) == "" {
+			continue
+		}
+		out = append(out, postprocess.ChapterSegment{
+			Start: time.Duration(seg.Start * float64(time.Second)),
+			End:   time.Duration(seg.End * float64(time.Second)),
+			Text:  seg.Text,
+		})
+	}
+	return out, nil
+}
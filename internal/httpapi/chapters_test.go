@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/postprocess"
+)
+
+type stubChapters struct {
+	input postprocess.ChaptersInput
+}
+
+func (s *stubChapters) Chapters(_ context.Context, in postprocess.ChaptersInput) (postprocess.ChaptersResult, error) {
+	s.input = in
+	return postprocess.ChaptersResult{Chapters: []postprocess.Chapter{
+		{Start: 0, End: 90 * time.Second, Title: "Intro"},
+		{Start: 90 * time.Second, End: 200500 * time.Millisecond, Title: "Guest --> story"},
+	}}, nil
+}
+
+func TestChaptersAnswersJSONAndVTT(t *testing.T) {
+	chapters := &stubChapters{}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Chapters:      chapters,
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chapters", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	segments := `"segments": [{"start": 0, "end": 4.5, "text": "Welcome back."}, {"start": 4.5, "end": 5, "text": " "}, {"start": 90, "end": 200.5, "text": "So tell us."}]`
+
+	w := post(`{` + segments + `, "max_chapters": 4}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `{"start":90,"end":200.5,"title":"Guest --\u003e story"}`) {
+		t.Fatalf("status = %d %s", w.Code, w.Body.String())
+	}
+	if len(chapters.input.Segments) != 2 || chapters.input.Segments[1].Start != 90*time.Second || chapters.input.MaxChapters != 4 {
+		t.Fatalf("input = %+v", chapters.input)
+	}
+
+	w = post(`{` + segments + `, "response_format": "vtt"}`)
+	want := "WEBVTT\n\n00:00:00.000 --> 00:01:30.000\nIntro\n\n00:01:30.000 --> 00:03:20.500\nGuest -&gt; story\n\n"
+	if w.Code != http.StatusOK || w.Body.String() != want || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/vtt") {
+		t.Fatalf("status = %d %q", w.Code, w.Body.String())
+	}
+
+	for _, body := range []string{`{"segments": []}`, `{"segments": [{"start": 5, "end": 1, "text": "a"}]}`, `{` + segments + `, "response_format": "srt"}`} {
+		if w := post(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d %s", body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"echoflow/internal/bufpool"
+)
+
+// writeCacheableJSON writes value as a 200 like writeJSON, tagged with an
+// ETag of the body and, unless lastModified is zero, a Last-Modified date.
+// A conditional GET for the representation the client already holds gets
+// 304 Not Modified instead. Responses depend on the caller's token, so they
+// are private and vary on Authorization.
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, value any, lastModified time.Time) {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	if err := json.NewEncoder(buf).Encode(value); err != nil {
+		writeJSON(w, http.StatusOK, value)
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "private, no-cache")
+	h.Add("Vary", "Authorization")
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is
+// no If-None-Match, as RFC 9110 orders them.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
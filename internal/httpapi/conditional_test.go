@@ -0,0 +1,44 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteCacheableJSONAnswersConditionalRequests(t *testing.T) {
+	modified := time.Date(2026, 3, 4, 5, 6, 7, 800, time.UTC)
+	get := func(header, value string, body any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/acronyms", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		writeCacheableJSON(w, req, body, modified)
+		return w
+	}
+
+	first := get("", "", map[string]string{"EOD": "end of day"})
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Last-Modified") != "Wed, 04 Mar 2026 05:06:07 GMT" || first.Header().Get("Vary") != "Authorization" {
+		t.Fatalf("status %d, headers %v", first.Code, first.Header())
+	}
+
+	tests := []struct {
+		name          string
+		header, value string
+		body          any
+		want          int
+	}{
+		{"same etag", "If-None-Match", `"other", W/` + etag, map[string]string{"EOD": "end of day"}, http.StatusNotModified},
+		{"changed body", "If-None-Match", etag, map[string]string{"EOD": "end of discussion"}, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Wed, 04 Mar 2026 05:06:07 GMT", nil, http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Wed, 04 Mar 2026 05:06:06 GMT", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := get(tt.header, tt.value, tt.body); w.Code != tt.want || (tt.want == http.StatusNotModified && w.Body.Len() != 0) {
+			t.Errorf("%s: status %d, body %q", tt.name, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/reqctx"
+)
+
+// Cost attribution headers, set on processing responses so a gateway can
+// meter requests without parsing bodies.
+const (
+	tokensUsedHeader    = "X-EchoFlow-Tokens-Used"
+	audioSecondsHeader  = "X-EchoFlow-Audio-Seconds"
+	estimatedCostHeader = "X-EchoFlow-Estimated-Cost"
+)
+
+// requestUsage is what a request spent upstream.
+type requestUsage struct {
+	tokens int
+	// audio is the duration of the audio transcribed, 0 when unknown or
+	// when nothing was sent upstream.
+	audio time.Duration
+}
+
+func (u *requestUsage) add(other requestUsage) {
+	u.tokens += other.tokens
+	u.audio += other.audio
+}
+
+func (u *requestUsage) addTokens(usage *model.TokenUsage) {
+	if usage != nil {
+		u.tokens += usage.TotalTokens
+	}
+}
+
+func audioUsage(meta *model.AudioMetadata) time.Duration {
+	if meta == nil {
+		return 0
+	}
+	return time.Duration(meta.DurationMS) * time.Millisecond
+}
+
+// pipelineUsage counts post-processing and analyzer tokens. A cached result
+// cost nothing upstream.
+func pipelineUsage(resp model.PipelineProcessResponse) requestUsage {
+	if resp.Cached {
+		return requestUsage{}
+	}
+	u := requestUsage{audio: audioUsage(resp.Audio)}
+	u.addTokens(resp.PostProcessingUsage)
+	for _, stage := range resp.Stages {
+		u.addTokens(stage.Usage)
+	}
+	return u
+}
+
+// setUsageHeaders writes the cost headers and adds the usage to the caller's
+// tenant in the summary. The audio header is left out when the duration is
+// unknown, and the cost header when no price is configured.
+func (s *server) setUsageHeaders(w http.ResponseWriter, r *http.Request, u requestUsage) {
+	s.stats.observeUsage(reqctx.Tenant(r.Context()), u)
+	h := w.Header()
+	h.Set(tokensUsedHeader, strconv.Itoa(u.tokens))
+	if u.audio > 0 {
+		h.Set(audioSecondsHeader, strconv.FormatFloat(u.audio.Seconds(), 'f', 3, 64))
+	}
+	if s.cfg.CostPerAudioMinute > 0 || s.cfg.CostPer1KTokens > 0 {
+		cost := u.audio.Minutes()*s.cfg.CostPerAudioMinute + float64(u.tokens)/1000*s.cfg.CostPer1KTokens
+		h.Set(estimatedCostHeader, strconv.FormatFloat(cost, 'f', 6, 64))
+	}
+}
@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/config"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+)
+
+func TestPipelineResponseCarriesCostHeaders(t *testing.T) {
+	pipe := &stubPipeline{result: pipeline.ProcessResult{
+		FinalTranscript:      "final",
+		PostProcessingStatus: pipeline.PostProcessingSucceeded,
+		PostProcessingUsage:  &postprocess.TokenUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+	}}
+	h := NewServer(config.Config{MaxUploadBytes: 1 << 20, CostPerAudioMinute: 0.006, CostPer1KTokens: 0.5}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      pipe,
+		Upstream:      stubUpstream{},
+	})
+	process := func() http.Header {
+		wav := &audio.WAV{Format: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16, Data: make([]byte, 15*16000*2)}
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("file", "note.wav")
+		_, _ = part.Write(wav.Encode())
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", &body)
+		req.Header.Set("Authorization", "Bearer gsk_caller")
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+		}
+		return w.Header()
+	}
+
+	// 15s of audio at 0.006 a minute, plus 120 tokens at 0.5 per 1K.
+	got := process()
+	if got.Get(tokensUsedHeader) != "120" || got.Get(audioSecondsHeader) != "15.000" || got.Get(estimatedCostHeader) != "0.061500" {
+		t.Fatalf("cost headers = %v", got)
+	}
+
+	pipe.result.Cached = true
+	got = process()
+	if got.Get(tokensUsedHeader) != "0" || got.Get(audioSecondsHeader) != "" || got.Get(estimatedCostHeader) != "0.000000" {
+		t.Fatalf("cached cost headers = %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"echoflow/internal/auth"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+)
+
+// debugHeader echoes the settings a request resolved to, for tokens with the
+// debug scope.
+const debugHeader = "X-EchoFlow-Debug"
+
+// debugInfo is the debug header's "key=value" pairs, in order.
+type debugInfo []string
+
+func (d *debugInfo) add(key string, value any) {
+	*d = append(*d, fmt.Sprintf("%s=%v", key, value))
+}
+
+func postProcessDebug(params postprocess.Params, timeout time.Duration) debugInfo {
+	var info debugInfo
+	info.add("post_process_model", params.Model)
+	info.add("prompt", params.Prompt)
+	info.add("vocabulary_terms", params.VocabularyTerms)
+	info.add("vocabulary_dropped", params.VocabularyDropped)
+	info.add("logit_bias_tokens", params.LogitBiasTokens)
+	if params.MaxContextTokens > 0 {
+		info.add("max_context_tokens", params.MaxContextTokens)
+	}
+	if params.Context != "" {
+		info.add("context", params.Context)
+	}
+	info.add("timeout_ms", timeout.Milliseconds())
+	return info
+}
+
+// pipelineDebug leaves out the post-processing settings when the run fell
+// back to the raw transcript, since none were used.
+func (s *server) pipelineDebug(result pipeline.ProcessResult, req *pipelineRequest) debugInfo {
+	var info debugInfo
+	info.add("transcription_model", result.TranscriptionModel)
+	info.add("post_processing", result.PostProcessingStatus)
+	if result.PostProcessingOutcome != pipeline.OutcomeFallback {
+		info = append(info, postProcessDebug(result.PostProcessingParams, req.budget)...)
+	} else {
+		info.add("timeout_ms", req.budget.Milliseconds())
+	}
+	info.add("max_upload_bytes", s.cfg.MaxUploadBytes)
+	if result.Cached {
+		info.add("cached", true)
+	}
+	return info
+}
+
+func (s *server) setDebugHeader(w http.ResponseWriter, r *http.Request, info debugInfo) {
+	if id, _ := auth.IdentityFromContext(r.Context()); id.HasScope(auth.ScopeDebug) {
+		w.Header().Set(debugHeader, strings.Join(info, "; "))
+	}
+}
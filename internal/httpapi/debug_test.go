@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"echoflow/internal/auth"
+	"echoflow/internal/config"
+	"echoflow/internal/postprocess"
+)
+
+func TestDebugHeaderNeedsDebugScope(t *testing.T) {
+	entry := func(name, token, scopes string) string {
+		sum := sha256.Sum256([]byte(token))
+		return `{"name":"` + name + `","sha256":"` + hex.EncodeToString(sum[:]) + `","scopes":[` + scopes + `]}`
+	}
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tokens := `{"tokens":[` + entry("dev", "ef_dev", `"post_process","debug"`) + `,` + entry("web", "ef_web", "") + `]}`
+	if err := os.WriteFile(path, []byte(tokens), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := auth.LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postProcess := &stubPostProcess{result: postprocess.Result{Transcript: "hi", Params: postprocess.Params{
+		Model: "llama", Prompt: "custom", VocabularyTerms: 2, VocabularyDropped: 1, Context: postprocess.ContextTruncated, MaxContextTokens: 500,
+	}}}
+	cfg := config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x"}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   postProcess,
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Tokens:        registry,
+	})
+
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hi","timeout_ms":1500}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("ef_dev")
+	want := "post_process_model=llama; prompt=custom; vocabulary_terms=2; vocabulary_dropped=1; logit_bias_tokens=0; max_context_tokens=500; context=truncated; timeout_ms=1500"
+	if w.Code != http.StatusOK || w.Header().Get(debugHeader) != want {
+		t.Fatalf("status %d, %s = %q", w.Code, debugHeader, w.Header().Get(debugHeader))
+	}
+	if w := post("ef_web"); w.Code != http.StatusOK || w.Header().Get(debugHeader) != "" {
+		t.Fatalf("token without the debug scope: status %d, header %q", w.Code, w.Header().Get(debugHeader))
+	}
+}
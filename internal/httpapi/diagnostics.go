@@ -0,0 +1,116 @@
+package httpapi
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"echoflow/internal/reqctx"
+)
+
+// LogHistory returns recent warning and error log lines.
+type LogHistory interface {
+	Recent() []string
+}
+
+// MetricsSnapshotter writes all metrics in the Prometheus text format.
+type MetricsSnapshotter interface {
+	WriteText(w io.Writer) error
+}
+
+type buildReport struct {
+	GoVersion  string            `json:"go_version"`
+	Module     string            `json:"module,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Settings   map[string]string `json:"settings,omitempty"`
+	OS         string            `json:"os"`
+	Arch       string            `json:"arch"`
+	NumCPU     int               `json:"num_cpu"`
+	GOMAXPROCS int               `json:"gomaxprocs"`
+	Goroutines int               `json:"goroutines"`
+	HeapBytes  uint64            `json:"heap_alloc_bytes"`
+	Hostname   string            `json:"hostname,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	Uptime     string            `json:"uptime"`
+}
+
+// handleDiagnostics streams a zip for support tickets: redacted config,
+// build and runtime info, a goroutine dump, a metrics snapshot and recent
+// warning/error logs.
+func (s *server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	hostname, _ := os.Hostname()
+	name := fmt.Sprintf("echoflow-diagnostics-%s.zip", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	zw := zip.NewWriter(w)
+	add := func(file string, write func(io.Writer) error) {
+		f, err := zw.Create(file)
+		if err == nil {
+			err = write(f)
+		}
+		if err != nil {
+			reqctx.Logger(r.Context()).Warn("diagnostics section failed", "file", file, "error", err)
+		}
+	}
+	writeJSONTo := func(value any) func(io.Writer) error {
+		return func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(value)
+		}
+	}
+
+	add("config.json", writeJSONTo(s.cfg.Redacted()))
+	add("build.json", writeJSONTo(s.buildReport(hostname)))
+	add("goroutines.txt", func(w io.Writer) error {
+		return pprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	if s.metricsText != nil {
+		add("metrics.txt", s.metricsText.WriteText)
+	}
+	if s.logs != nil {
+		add("logs.txt", func(w io.Writer) error {
+			_, err := io.WriteString(w, strings.Join(s.logs.Recent(), ""))
+			return err
+		})
+	}
+	if err := zw.Close(); err != nil {
+		reqctx.Logger(r.Context()).Warn("diagnostics bundle incomplete", "error", err)
+	}
+}
+
+func (s *server) buildReport(hostname string) buildReport {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	report := buildReport{
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  mem.HeapAlloc,
+		Hostname:   hostname,
+		StartedAt:  s.startedAt.UTC(),
+		Uptime:     time.Since(s.startedAt).Round(time.Second).String(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		report.Module, report.Version = info.Main.Path, info.Main.Version
+		report.Settings = map[string]string{}
+		for _, setting := range info.Settings {
+			if strings.HasPrefix(setting.Key, "vcs.") || setting.Key == "CGO_ENABLED" || setting.Key == "-tags" {
+				report.Settings[setting.Key] = setting.Value
+			}
+		}
+	}
+	return report
+}
@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Drain tracks the /v1 requests doing work, so shutdown can stop taking new
+// ones and wait for those already running instead of cutting them off.
+type Drain struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{}
+}
+
+func NewDrain() *Drain {
+	return &Drain{idle: make(chan struct{})}
+}
+
+// enter admits a request unless the drain has started.
+func (d *Drain) enter() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.active++
+	return true
+}
+
+func (d *Drain) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active--
+	if d.draining && d.active == 0 {
+		close(d.idle)
+	}
+}
+
+func (d *Drain) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Wait refuses new requests from now on and waits until the ones in flight
+// have finished or ctx ends. It reports how many finished in time and how
+// many were still running.
+func (d *Drain) Wait(ctx context.Context) (drained, aborted int) {
+	d.mu.Lock()
+	if d.draining {
+		d.mu.Unlock()
+		return 0, 0
+	}
+	d.draining = true
+	started := d.active
+	if started == 0 {
+		close(d.idle)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.idle:
+	case <-ctx.Done():
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return started - d.active, d.active
+}
+
+func (s *server) shuttingDown(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "5")
+	s.writeError(w, r, http.StatusServiceUnavailable, "shutting_down", "replica is shutting down, retry on another", nil)
+}
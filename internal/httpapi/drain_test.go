@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/config"
+)
+
+func TestDrainWaitsForInFlightRequestsAndRefusesNewOnes(t *testing.T) {
+	postProcess := &blockingPostProcess{started: make(chan struct{}, 2), release: make(chan struct{})}
+	drain := NewDrain()
+	h := NewServer(config.Config{MaxUploadBytes: 1 << 20}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   postProcess,
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Drain:         drain,
+	})
+	postProcessReq := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hi"}`))
+		req.Header.Set("Authorization", "Bearer gsk_caller")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan int, 2)
+	for range 2 {
+		go func() { done <- postProcessReq().Code }()
+	}
+	<-postProcess.started
+	<-postProcess.started
+
+	type counts struct{ drained, aborted int }
+	waited := make(chan counts, 1)
+	go func() {
+		drained, aborted := drain.Wait(context.Background())
+		waited <- counts{drained, aborted}
+	}()
+	for !drain.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if w := postProcessReq(); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "shutting_down") {
+		t.Fatalf("request while draining = %d %s, want 503 shutting_down", w.Code, w.Body.String())
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz while draining = %d, want 503", w.Code)
+	}
+
+	close(postProcess.release)
+	for range 2 {
+		if code := <-done; code != http.StatusOK {
+			t.Fatalf("in-flight request = %d, want 200", code)
+		}
+	}
+	select {
+	case got := <-waited:
+		if got != (counts{drained: 2}) {
+			t.Fatalf("Wait = %+v, want 2 drained", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return after the requests finished")
+	}
+}
+
+func TestDrainReportsRequestsStillRunningAtDeadline(t *testing.T) {
+	drain := NewDrain()
+	if !drain.enter() || !drain.enter() {
+		t.Fatal("enter refused before draining")
+	}
+	drain.leave()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if drained, aborted := drain.Wait(ctx); drained != 0 || aborted != 1 {
+		t.Fatalf("Wait = %d drained, %d aborted; want 0, 1", drained, aborted)
+	}
+	if drain.enter() {
+		t.Fatal("enter admitted a request after draining started")
+	}
+}
@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+const errReturnAudioUnsupported = "return_audio is only supported by /v1/pipeline/process without event streaming"
+
+// writePipelineEchoResponse answers a return_audio request with a
+// multipart/mixed body: the usual JSON result first, then one part per file
+// exactly as it was sent to the transcription upstream.
+func writePipelineEchoResponse(w http.ResponseWriter, resp model.PipelineProcessResponse, echoed []pipeline.EchoedAudio) error {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/json; charset=utf-8"},
+		"Content-Disposition": {`inline; name="result"`},
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(resp); err != nil {
+		return err
+	}
+
+	for i, file := range echoed {
+		name := file.FileName
+		if name == "" {
+			name = "audio-" + strconv.Itoa(i+1)
+		}
+		header := textproto.MIMEHeader{
+			"Content-Type":        {http.DetectContentType(file.Data)},
+			"Content-Disposition": {mime.FormatMediaType("attachment", map[string]string{"name": "audio", "filename": name})},
+			"Content-Length":      {strconv.Itoa(len(file.Data))},
+		}
+		if file.Label != "" {
+			header.Set("Content-Description", file.Label)
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+func TestPipelineHandlerEchoesUpstreamAudio(t *testing.T) {
+	wav := audio.FromSamples([][]float64{{0, 0.5, -0.5, 0}}, 16000).Encode()
+	pipe := &stubPipeline{result: pipeline.ProcessResult{
+		RawTranscript:   "raw",
+		FinalTranscript: "final",
+		Audio:           []pipeline.EchoedAudio{{Label: "Caller", FileName: "call-ch1.wav", Data: wav}},
+	}}
+	h := newTestHandler(t, Dependencies{Transcription: &stubTranscription{}, PostProcess: &stubPostProcess{}, Pipeline: pipe, Upstream: stubUpstream{}})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("return_audio", "true")
+	part, _ := mw.CreateFormFile("file", "call.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !pipe.input.EchoAudio {
+		t.Fatalf("status = %d echo = %v body=%s", w.Code, pipe.input.EchoAudio, w.Body.String())
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+	mr := multipart.NewReader(w.Body, params["boundary"])
+
+	first, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("result part: %v", err)
+	}
+	var resp model.PipelineProcessResponse
+	if err := json.NewDecoder(first).Decode(&resp); err != nil || resp.FinalTranscript != "final" {
+		t.Fatalf("result = %+v err=%v", resp, err)
+	}
+
+	second, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("audio part: %v", err)
+	}
+	data, _ := io.ReadAll(second)
+	if !bytes.Equal(data, wav) || second.FileName() != "call-ch1.wav" || second.Header.Get("Content-Description") != "Caller" {
+		t.Fatalf("audio part = %d bytes, filename %q, header %v", len(data), second.FileName(), second.Header)
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Fatalf("expected two parts, got err=%v", err)
+	}
+}
+
+func TestReturnAudioRejectedForJobs(t *testing.T) {
+	h := newTestHandler(t, Dependencies{Transcription: &stubTranscription{}, PostProcess: &stubPostProcess{}, Pipeline: &stubPipeline{}, Upstream: stubUpstream{}})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("return_audio", "true")
+	part, _ := mw.CreateFormFile("file", "call.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+}
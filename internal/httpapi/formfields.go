@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"echoflow/internal/reqctx"
+)
+
+// formAliases are documented alternative names for multipart fields.
+var formAliases = map[string]string{
+	"audio":   "file",
+	"context": "context_summary",
+	// OpenAI's client libraries send the array field with brackets.
+	"timestamp_granularities[]": "timestamp_granularities",
+}
+
+// listFields take comma-separated values; repeating one appends to it.
+var listFields = map[string]bool{"speaker_labels": true, "stages": true, "timestamp_granularities": true}
+
+var preprocessFields = []string{"trim_silence", "normalize", "downmix", "resample_hz", "transcode"}
+
+// Multipart fields each endpoint reads, besides file.
+var (
+	transcriptionFields = append([]string{"model", "language", "prompt", "response_format", "timestamp_granularities", "timeout_ms"}, preprocessFields...)
+	translationFields   = append([]string{"model", "prompt", "response_format", "timeout_ms"}, preprocessFields...)
+	pipelineFields      = append([]string{
+		"split_channels", "speaker_labels", "context_summary", "custom_vocabulary", "custom_system_prompt",
+		"transcription_model", "transcription_prompt", "post_process_model", "language", "stages", "include_debug", "return_audio",
+		"expand_acronyms", "fallback_policy", "timestamp_granularities", "response_format", "timeout_ms", "callback_url",
+		"collapse_spellings", "spellings_to_vocabulary",
+	}, preprocessFields...)
+)
+
+// formFieldError is a multipart form that names its fields wrongly.
+type formFieldError struct {
+	message string
+	details map[string]any
+}
+
+func (e *formFieldError) Error() string { return e.message }
+
+// normalizeForm folds aliases into their fields, merges repeated list
+// fields, and rejects other fields sent more than once with different
+// values. With STRICT_FORM_FIELDS it also rejects fields the endpoint does
+// not read, instead of ignoring them.
+func (s *server) normalizeForm(r *http.Request, fields []string) error {
+	form := r.MultipartForm
+	for alias, field := range formAliases {
+		if _, ok := form.Value[alias]; ok && slices.Contains(fields, field) {
+			if _, dup := form.Value[field]; dup {
+				return aliasConflict(alias, field)
+			}
+			form.Value[field] = form.Value[alias]
+			delete(form.Value, alias)
+		}
+		if _, ok := form.File[alias]; ok && field == "file" {
+			if _, dup := form.File[field]; dup {
+				return aliasConflict(alias, field)
+			}
+			form.File[field] = form.File[alias]
+			delete(form.File, alias)
+		}
+	}
+	if _, ok := form.Value["file"]; ok {
+		return &formFieldError{message: "multipart field 'file' must be a file upload, not a text value", details: map[string]any{"field": "file"}}
+	}
+
+	var unknown []string
+	for name, values := range form.Value {
+		switch {
+		case !slices.Contains(fields, name):
+			unknown = append(unknown, name)
+		case listFields[name]:
+			form.Value[name] = []string{strings.Join(values, ",")}
+		case len(slices.Compact(slices.Clone(values))) > 1:
+			return &formFieldError{
+				message: fmt.Sprintf("multipart field '%s' was sent %d times with different values", name, len(values)),
+				details: map[string]any{"field": name},
+			}
+		default:
+			form.Value[name] = values[:1]
+		}
+	}
+	for name := range form.File {
+		if name != "file" {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		if s.cfg.StrictFormFields {
+			return &formFieldError{
+				message: "unknown multipart fields: " + strings.Join(unknown, ", "),
+				details: map[string]any{"unknown_fields": unknown, "known_fields": knownFields(fields)},
+			}
+		}
+		reqctx.Logger(r.Context()).Debug("unknown multipart fields ignored", "fields", unknown)
+	}
+
+	// FormValue reads r.Form, which ParseMultipartForm filled before the
+	// aliases were folded in.
+	r.PostForm = form.Value
+	r.Form = maps.Clone(r.URL.Query())
+	for name, values := range form.Value {
+		r.Form[name] = values
+	}
+	return nil
+}
+
+func aliasConflict(alias, field string) error {
+	return &formFieldError{
+		message: fmt.Sprintf("multipart fields '%s' and '%s' are the same field; send one", alias, field),
+		details: map[string]any{"field": field, "alias": alias},
+	}
+}
+
+// knownFields lists an endpoint's fields and the aliases that apply to them.
+func knownFields(fields []string) []string {
+	known := append([]string{"file"}, fields...)
+	for alias, field := range formAliases {
+		if slices.Contains(known, field) {
+			known = append(known, alias)
+		}
+	}
+	slices.Sort(known)
+	return known
+}
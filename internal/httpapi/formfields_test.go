@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"echoflow/internal/config"
+)
+
+type formField struct {
+	name, value string
+	file        bool
+}
+
+func postPipelineForm(t *testing.T, strict bool, pipe *stubPipeline, fields ...formField) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x", StrictFormFields: strict}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      pipe,
+		Upstream:      stubUpstream{},
+	})
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range fields {
+		if f.file {
+			part, _ := mw.CreateFormFile(f.name, "sample.wav")
+			_, _ = part.Write([]byte(f.value))
+		} else {
+			_ = mw.WriteField(f.name, f.value)
+		}
+	}
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestPipelineFormAcceptsAliasesAndRepeatedListFields(t *testing.T) {
+	pipe := &stubPipeline{}
+	w := postPipelineForm(t, true, pipe,
+		formField{name: "audio", value: "audio-payload", file: true},
+		formField{name: "context", value: "standup"},
+		formField{name: "language", value: "en"},
+		formField{name: "language", value: "en"},
+		formField{name: "stages", value: "summary"},
+		formField{name: "stages", value: "topics"},
+		formField{name: "transcription_prompt", value: "Dana, Grafana"},
+	)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d %s", w.Code, w.Body.String())
+	}
+	if pipe.fileBody != "audio-payload" || pipe.input.ContextSummary != "standup" || pipe.input.Language != "en" {
+		t.Fatalf("input = %+v, file %q", pipe.input, pipe.fileBody)
+	}
+	if strings.Join(pipe.input.Stages, ",") != "summary,topics" || pipe.input.TranscriptionPrompt != "Dana, Grafana" {
+		t.Fatalf("stages = %v, prompt %q", pipe.input.Stages, pipe.input.TranscriptionPrompt)
+	}
+}
+
+func TestPipelineFormRejectsAmbiguousFields(t *testing.T) {
+	file := formField{name: "file", value: "audio", file: true}
+	tests := []struct {
+		name    string
+		strict  bool
+		fields  []formField
+		message string
+	}{
+		{name: "alias and field", fields: []formField{file, {name: "context", value: "a"}, {name: "context_summary", value: "b"}}, message: "'context' and 'context_summary' are the same field"},
+		{name: "conflicting repeat", fields: []formField{file, {name: "language", value: "en"}, {name: "language", value: "de"}}, message: "'language' was sent 2 times"},
+		{name: "text file field", fields: []formField{{name: "file", value: "/tmp/call.wav"}}, message: "must be a file upload"},
+		{name: "unknown in strict mode", strict: true, fields: []formField{file, {name: "contxt_summary", value: "a"}}, message: "unknown multipart fields: contxt_summary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postPipelineForm(t, tt.strict, &stubPipeline{}, tt.fields...)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.message) {
+				t.Fatalf("status = %d %s, want 400 with %q", w.Code, w.Body.String(), tt.message)
+			}
+		})
+	}
+
+	if w := postPipelineForm(t, false, &stubPipeline{}, file, formField{name: "contxt_summary", value: "a"}); w.Code != http.StatusOK {
+		t.Fatalf("unknown field outside strict mode = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPipelineFormRejectsUnknownLanguage(t *testing.T) {
+	file := formField{name: "file", value: "audio", file: true}
+	w := postPipelineForm(t, false, &stubPipeline{}, file, formField{name: "language", value: "klingon"})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "ISO 639-1") {
+		t.Fatalf("status = %d %s", w.Code, w.Body.String())
+	}
+	pipe := &stubPipeline{}
+	if w := postPipelineForm(t, false, pipe, file, formField{name: "language", value: "pt-BR"}); w.Code != http.StatusOK || pipe.input.Language != "pt-BR" {
+		t.Fatalf("status = %d, language %q", w.Code, pipe.input.Language)
+	}
+}
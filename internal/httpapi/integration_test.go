@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/config"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream/openai"
+	"echoflow/upstreamtest"
+)
+
+func newIntegrationHandler(t *testing.T, opts ...upstreamtest.Option) (http.Handler, *upstreamtest.Server) {
+	t.Helper()
+	upstream := upstreamtest.NewServer(opts...)
+	t.Cleanup(upstream.Close)
+
+	cfg := config.Config{
+		UpstreamBaseURL:    upstream.URL,
+		TranscriptionModel: "whisper-large-v3",
+		PostProcessModel:   "llama-3.3-70b-versatile",
+		MaxUploadBytes:     1 << 20,
+		PostProcessTimeout: 2 * time.Second,
+	}
+	client := openai.New(upstream.URL, "", upstream.Client())
+	transcriber := transcription.New(client, cfg.TranscriptionModel, transcription.TimeoutPolicy{Base: 2 * time.Second})
+	postProcessor := postprocess.New(client, cfg.PostProcessModel, cfg.PostProcessTimeout)
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: transcriber,
+		PostProcess:   postProcessor,
+		Pipeline:      pipeline.New(transcriber, postProcessor, cfg.TranscriptionModel),
+		Upstream:      client,
+	})
+	return h, upstream
+}
+
+func newPipelineUploadRequest(t *testing.T) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer byot-key")
+	return req
+}
+
+func TestIntegrationPipelineAgainstFakeUpstream(t *testing.T) {
+	h, upstream := newIntegrationHandler(t,
+		upstreamtest.WithTranscript("um send the report"),
+		upstreamtest.WithCompletion("Send the report.", &upstreamtest.Usage{TotalTokens: 12}),
+		upstreamtest.WithAPIKey("byot-key"),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newPipelineUploadRequest(t))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+	var resp model.PipelineProcessResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.RawTranscript != "um send the report" || resp.FinalTranscript != "Send the report." {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if upstream.Count("/audio/transcriptions") != 1 || upstream.Count("/chat/completions") != 1 {
+		t.Fatalf("unexpected upstream calls: %+v", upstream.Requests())
+	}
+}
+
+func TestIntegrationUpstreamFailureMapsToBadGateway(t *testing.T) {
+	h, _ := newIntegrationHandler(t, upstreamtest.WithErrorRate(1, http.StatusServiceUnavailable))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newPipelineUploadRequest(t))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestIntegrationPipelineStreamsStageEvents(t *testing.T) {
+	h, _ := newIntegrationHandler(t,
+		upstreamtest.WithTranscript("um send the report"),
+		upstreamtest.WithCompletion("Send the report.", nil),
+	)
+
+	req := newPipelineUploadRequest(t)
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	var events []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, name)
+		}
+	}
+	if strings.Join(events, ",") != "transcription_done,post_processing_done,result" {
+		t.Fatalf("unexpected events %v in body:\n%s", events, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"transcript":"um send the report"`) || !strings.Contains(w.Body.String(), `"final_transcript":"Send the report."`) {
+		t.Fatalf("unexpected event payloads:\n%s", w.Body.String())
+	}
+}
+
+func TestIntegrationPipelineStreamReportsErrors(t *testing.T) {
+	h, _ := newIntegrationHandler(t, upstreamtest.WithErrorRate(1, http.StatusServiceUnavailable))
+
+	req := newPipelineUploadRequest(t)
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), "event: error\n") || !strings.Contains(w.Body.String(), `"code":"upstream_request_failed"`) {
+		t.Fatalf("expected error event, got:\n%s", w.Body.String())
+	}
+}
+
+func TestIntegrationPostProcessStreamsFromUpstream(t *testing.T) {
+	h, upstream := newIntegrationHandler(t, upstreamtest.WithCompletion("Send the report today.", nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"um send the report today"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer byot-key")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := strings.Count(w.Body.String(), "event: delta\n"); got != 4 {
+		t.Fatalf("expected 4 delta events, got %d:\n%s", got, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `event: result`+"\n"+`data: {"transcript":"Send the report today."`) {
+		t.Fatalf("missing result event:\n%s", w.Body.String())
+	}
+	if reqs := upstream.Requests(); len(reqs) != 1 || !reqs[0].Stream {
+		t.Fatalf("expected one streamed upstream call, got %+v", reqs)
+	}
+}
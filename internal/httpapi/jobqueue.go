@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/deadline"
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
+)
+
+// queuedPipelineJob is everything a worker on another replica needs to run a
+// pipeline job: the audio itself and the form options. It never carries
+// upstream credentials, so only jobs on the server's key are queued; a
+// caller's own key stays in the memory of the replica that accepted the job.
+type queuedPipelineJob struct {
+	RequestID           string
+	Audio               *model.AudioMetadata
+	FileName            string
+	Data                []byte
+	Parts               []queuedAudioPart
+	SplitChannels       bool
+	ChannelLabels       []string
+	Preprocess          audio.PreprocessOptions
+	ContextSummary      string
+	CustomVocabulary    string
+	CustomSystemPrompt  string
+	TranscriptionModel  string
+	TranscriptionPrompt string
+	WordTimestamps      bool
+	PostProcessModel    string
+	Language            string
+	Stages              []string
+	FallbackPolicy      string
+	Tier                string
+	Tenant              string
+	Languages           transcription.LanguagePolicy
+	// Acronyms is the tenant dictionary as it was when the job was submitted.
+	Acronyms map[string]string
+	// Spellings holds CollapseSpellings and SpellingsToVocabulary.
+	Spellings [2]bool
+	// Timeout is the submitter's timeout_ms override.
+	Timeout time.Duration
+}
+
+type queuedAudioPart struct {
+	FileName string
+	Label    string
+	Data     []byte
+}
+
+// errCallerKeyedJob guards against serializing a job whose upstream calls
+// need the caller's key, which the payload cannot carry.
+var errCallerKeyedJob = errors.New("jobs on the caller's upstream key cannot be serialized")
+
+func encodeQueuedJob(ctx context.Context, in pipeline.ProcessInput, audioMeta *model.AudioMetadata) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeQueuedJob(ctx, &buf, in, audioMeta); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQueuedJob is encodeQueuedJob writing to w, so a journaled job's audio
+// goes to disk without a second in-memory copy.
+func writeQueuedJob(ctx context.Context, w io.Writer, in pipeline.ProcessInput, audioMeta *model.AudioMetadata) error {
+	if !upstream.ServerPays(ctx) {
+		return errCallerKeyedJob
+	}
+	q := queuedPipelineJob{
+		RequestID:           reqctx.RequestID(ctx),
+		Audio:               audioMeta,
+		FileName:            in.FileName,
+		SplitChannels:       in.SplitChannels,
+		ChannelLabels:       in.ChannelLabels,
+		Preprocess:          in.Preprocess,
+		ContextSummary:      in.ContextSummary,
+		CustomVocabulary:    in.CustomVocabulary,
+		CustomSystemPrompt:  in.CustomSystemPrompt,
+		TranscriptionModel:  in.TranscriptionModel,
+		TranscriptionPrompt: in.TranscriptionPrompt,
+		WordTimestamps:      in.WordTimestamps,
+		PostProcessModel:    in.PostProcessModel,
+		Language:            in.Language,
+		Stages:              in.Stages,
+		FallbackPolicy:      in.FallbackPolicy,
+		Tier:                transcription.TierFromContext(ctx),
+		Tenant:              reqctx.Tenant(ctx),
+		Acronyms:            in.Acronyms,
+		Spellings:           [2]bool{in.CollapseSpellings, in.SpellingsToVocabulary},
+		Timeout:             deadline.Override(ctx),
+	}
+	q.Languages, _ = transcription.LanguagePolicyFromContext(ctx)
+	if len(in.Parts) > 0 {
+		for _, part := range in.Parts {
+			data, err := io.ReadAll(part.File)
+			if err != nil {
+				return err
+			}
+			q.Parts = append(q.Parts, queuedAudioPart{FileName: part.FileName, Label: part.Label, Data: data})
+		}
+	} else {
+		data, err := io.ReadAll(in.File)
+		if err != nil {
+			return err
+		}
+		q.Data = data
+	}
+	return gob.NewEncoder(w).Encode(q)
+}
+
+// NewJobRunner returns the worker side of queued pipeline jobs: it decodes a
+// payload from encodeQueuedJob and runs it through svc.
+func NewJobRunner(svc PipelineService, metrics MetricsObserver) jobs.Runner {
+	return func(ctx context.Context, payload []byte, onProgress func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		var q queuedPipelineJob
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&q); err != nil {
+			return model.PipelineProcessResponse{}, &jobs.Error{APIError: model.APIError{Code: "internal_error", Message: "invalid job payload"}}
+		}
+
+		ctx = reqctx.WithAPIKeySource(ctx, reqctx.KeySourceServer)
+		ctx = reqctx.WithPriority(ctx, q.Tier)
+		ctx = reqctx.WithTenant(ctx, q.Tenant)
+		attrs := []any{"request_id", q.RequestID, "route", "/v1/jobs"}
+		if q.Tenant != "" {
+			attrs = append(attrs, "tenant", q.Tenant)
+		}
+		ctx = reqctx.WithLogger(ctx, reqctx.Logger(ctx).With(attrs...))
+		if len(q.Languages.Allowed) > 0 {
+			ctx = transcription.WithLanguagePolicy(ctx, q.Languages)
+		}
+		if q.RequestID != "" {
+			ctx = reqctx.WithRequestID(ctx, q.RequestID)
+		}
+		if q.Timeout > 0 {
+			ctx = deadline.WithOverride(ctx, q.Timeout)
+		}
+		in := pipeline.ProcessInput{
+			File:                bytes.NewReader(q.Data),
+			FileName:            q.FileName,
+			FileSize:            int64(len(q.Data)),
+			SplitChannels:       q.SplitChannels,
+			ChannelLabels:       q.ChannelLabels,
+			Preprocess:          q.Preprocess,
+			ContextSummary:      q.ContextSummary,
+			CustomVocabulary:    q.CustomVocabulary,
+			CustomSystemPrompt:  q.CustomSystemPrompt,
+			TranscriptionModel:  q.TranscriptionModel,
+			TranscriptionPrompt: q.TranscriptionPrompt,
+			WordTimestamps:      q.WordTimestamps,
+			PostProcessModel:    q.PostProcessModel,
+			Language:            q.Language,
+			Stages:              q.Stages,
+			FallbackPolicy:      q.FallbackPolicy,
+			Acronyms:            q.Acronyms,
+			OnProgress:          onProgress,
+		}
+		in.CollapseSpellings, in.SpellingsToVocabulary = q.Spellings[0], q.Spellings[1]
+		if len(q.Parts) > 0 {
+			in.File = nil
+			in.FileSize = 0
+			for _, part := range q.Parts {
+				in.Parts = append(in.Parts, pipeline.AudioPart{
+					File:     bytes.NewReader(part.Data),
+					FileName: part.FileName,
+					Size:     int64(len(part.Data)),
+					Label:    part.Label,
+				})
+			}
+		}
+
+		result, err := svc.Process(ctx, in)
+		if err != nil {
+			_, apiErr := mapError(err)
+			return model.PipelineProcessResponse{}, &jobs.Error{APIError: apiErr}
+		}
+		observePipelineResult(metrics, result)
+		return toPipelineResponse(result, q.Audio), nil
+	}
+}
@@ -0,0 +1,390 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+	"echoflow/internal/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const sseHeartbeat = 1 * time.Second
+
+func (s *server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readPipelineRequest(w, r)
+	if !ok {
+		return
+	}
+	r = withTimeout(r, req.timeout)
+	if req.input.EchoAudio {
+		req.close()
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", errReturnAudioUnsupported, nil)
+		return
+	}
+	if req.format != responseFormatJSON {
+		req.close()
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", errResponseFormatUnsupported, nil)
+		return
+	}
+	opts, err := s.jobOptions(r.Context(), req.callbackURL)
+	if err != nil {
+		req.close()
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+	// A serialized job cannot carry the caller's upstream key, so jobs on
+	// it run in this replica's memory, neither queued nor journaled.
+	serializable := upstream.ServerPays(r.Context())
+	if s.jobs.HasQueue() && serializable {
+		s.enqueueJob(w, r, req, opts)
+		return
+	}
+	if !s.admitJob(w, r, req) {
+		req.close()
+		return
+	}
+	if s.jobs.HasJournal() && serializable {
+		s.submitJournaledJob(w, r, req, opts)
+		return
+	}
+	input := req.input
+	cleanup, err := spoolPipelineAudio(&input)
+	req.close()
+	if err != nil {
+		s.writeError(w, r, http.StatusInternalServerError, "internal_error", "failed to buffer upload", detailsForError(err))
+		return
+	}
+
+	audioMeta := req.audio
+	job, err := s.jobs.Submit(context.WithoutCancel(r.Context()), func(ctx context.Context, onProgress func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		input.OnProgress = onProgress
+		result, err := s.pipeline.Process(ctx, input)
+		if err != nil {
+			_, apiErr := mapError(err)
+			return model.PipelineProcessResponse{}, &jobs.Error{APIError: apiErr}
+		}
+		s.observePipeline(result)
+		return toPipelineResponse(result, audioMeta), nil
+	}, cleanup, opts...)
+	if errors.Is(err, jobs.ErrQueueFull) {
+		cleanup()
+		w.Header().Set("Retry-After", "5")
+		s.writeError(w, r, http.StatusServiceUnavailable, "queue_full", "too many jobs are queued; retry later", nil)
+		return
+	}
+	if err != nil {
+		cleanup()
+		s.writeMappedError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, toJobResponse(job))
+}
+
+// assumedBytesPerSecond converts an upload's size to a duration when probing
+// found none: 128 kbit/s, typical of compressed speech.
+const assumedBytesPerSecond = 16_000
+
+// admitJob refuses a job with 503 overloaded when the jobs already queued on
+// this replica would push its estimated completion past JOB_ADMISSION_SLA,
+// rather than accepting work that will time out in line. A job that is slow
+// on its own is still admitted: retrying it would not help.
+func (s *server) admitJob(w http.ResponseWriter, r *http.Request, req *pipelineRequest) bool {
+	sla := s.cfg.JobAdmissionSLA
+	if sla <= 0 {
+		return true
+	}
+	var audio time.Duration
+	if req.audio != nil && req.audio.DurationMS > 0 {
+		audio = time.Duration(req.audio.DurationMS) * time.Millisecond
+	} else if req.input.FileSize > 0 {
+		audio = time.Duration(req.input.FileSize) * time.Second / assumedBytesPerSecond
+	}
+	estimate := s.jobs.EstimateJob(audio)
+	if estimate.Wait <= 0 || estimate.Total() <= sla {
+		return true
+	}
+	retryAfter := max(time.Second, min(estimate.Wait, estimate.Total()-sla))
+	details := map[string]any{
+		"estimated_wait_seconds":       math.Ceil(estimate.Wait.Seconds()),
+		"estimated_processing_seconds": math.Ceil(estimate.Processing.Seconds()),
+		"sla_seconds":                  sla.Seconds(),
+	}
+	reqctx.Logger(r.Context()).Warn("job refused over admission SLA", "details", details)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	s.writeError(w, r, http.StatusServiceUnavailable, "overloaded", "estimated completion exceeds the job SLA; retry later", details)
+	return false
+}
+
+// jobOptions files the job under the caller's tenant, so only that tenant can
+// read it back.
+func (s *server) jobOptions(ctx context.Context, callbackURL string) ([]jobs.SubmitOption, error) {
+	opts := []jobs.SubmitOption{jobs.WithTenant(reqctx.Tenant(ctx))}
+	if callbackURL == "" {
+		return opts, nil
+	}
+	if s.cfg.WebhookSecret == "" {
+		return nil, errors.New("callback_url is not supported: WEBHOOK_SECRET is not configured")
+	}
+	if err := webhook.ValidateURL(callbackURL, s.cfg.WebhookAllowPrivate); err != nil {
+		return nil, err
+	}
+	return append(opts, jobs.WithCallbackURL(callbackURL)), nil
+}
+
+func jobKey(r *http.Request) jobs.Key {
+	return jobs.Key{Tenant: reqctx.Tenant(r.Context()), ID: chi.URLParam(r, "id")}
+}
+
+func (s *server) enqueueJob(w http.ResponseWriter, r *http.Request, req *pipelineRequest, opts []jobs.SubmitOption) {
+	payload, err := encodeQueuedJob(r.Context(), req.input, req.audio)
+	req.close()
+	if err != nil {
+		s.writeError(w, r, http.StatusInternalServerError, "internal_error", "failed to buffer upload", detailsForError(err))
+		return
+	}
+	job, err := s.jobs.Enqueue(r.Context(), payload, opts...)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("job enqueue failed", "error", err)
+		s.writeError(w, r, http.StatusServiceUnavailable, "queue_unavailable", "job queue is unavailable", nil)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, toJobResponse(job))
+}
+
+// submitJournaledJob runs the job on this replica, from the same payload a
+// shared queue would carry, spooled to the journal so a restart resumes it.
+func (s *server) submitJournaledJob(w http.ResponseWriter, r *http.Request, req *pipelineRequest, opts []jobs.SubmitOption) {
+	var encodeErr error
+	job, err := s.jobs.SubmitPayload(context.WithoutCancel(r.Context()), func(dst io.Writer) error {
+		encodeErr = writeQueuedJob(r.Context(), dst, req.input, req.audio)
+		return encodeErr
+	}, opts...)
+	req.close()
+	if encodeErr != nil {
+		s.writeError(w, r, http.StatusInternalServerError, "internal_error", "failed to buffer upload", detailsForError(encodeErr))
+		return
+	}
+	if errors.Is(err, jobs.ErrQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		s.writeError(w, r, http.StatusServiceUnavailable, "queue_full", "too many jobs are queued; retry later", nil)
+		return
+	}
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("job journal failed", "error", err)
+		s.writeError(w, r, http.StatusServiceUnavailable, "queue_unavailable", "job journal is unavailable", nil)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, toJobResponse(job))
+}
+
+func (s *server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(jobKey(r))
+	if !ok {
+		s.writeError(w, r, http.StatusNotFound, "not_found", "job not found", nil)
+		return
+	}
+	// Progress of a running job is interpolated over time, so only a
+	// finished job's update time says when its body last changed.
+	var lastModified time.Time
+	if job.Status.Terminal() {
+		lastModified = job.UpdatedAt
+	}
+	writeCacheableJSON(w, r, toJobResponse(job), lastModified)
+}
+
+// handleJobEvents streams job snapshots as Server-Sent Events: a "progress"
+// event on every change (and on a heartbeat, since progress is interpolated
+// over time), then a final "result" or "error" event.
+func (s *server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	key := jobKey(r)
+	job, changed, ok := s.jobs.Watch(key)
+	if !ok {
+		s.writeError(w, r, http.StatusNotFound, "not_found", "job not found", nil)
+		return
+	}
+
+	rc := startEventStream(w)
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+	for {
+		_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		resp := toJobResponse(job)
+		resp.Result = nil
+		resp.Error = nil
+		if err := writeSSE(w, "progress", resp); err != nil {
+			return
+		}
+		if job.Status.Terminal() {
+			full := toJobResponse(job)
+			if full.Error != nil {
+				_ = writeSSE(w, "error", full.Error)
+			} else {
+				_ = writeSSE(w, "result", full.Result)
+			}
+			_ = rc.Flush()
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+		}
+		job, changed, _ = s.jobs.Watch(key)
+	}
+}
+
+type WebhookSender interface {
+	Deliver(ctx context.Context, url, deliveryID string, body []byte) error
+}
+
+// NewJobNotifier posts the finished job, in the same shape as GET
+// /v1/jobs/{id} plus its delivery ID and event, to its callback URL. The
+// delivery ID depends only on the job and its outcome, so every redelivery,
+// including one after the job is resumed on restart, carries the same ID.
+// It returns an error only for failures worth another delivery later.
+func NewJobNotifier(sender WebhookSender, logger *slog.Logger) jobs.Notifier {
+	return func(ctx context.Context, job jobs.Job) error {
+		event := "job." + string(job.Status)
+		deliveryID := webhook.DeliveryID(job.ID, event)
+		body, err := json.Marshal(model.JobWebhook{DeliveryID: deliveryID, Event: event, JobResponse: toJobResponse(job)})
+		if err != nil {
+			logger.Error("job webhook encode failed", "job_id", job.ID, "error", err)
+			return nil
+		}
+		if err := sender.Deliver(ctx, job.CallbackURL, deliveryID, body); err != nil {
+			logger.Warn("job webhook delivery failed", "job_id", job.ID, "delivery_id", deliveryID, "request_id", reqctx.RequestID(ctx), "error", err)
+			if webhook.Rejected(err) {
+				return nil
+			}
+			return err
+		}
+		logger.Info("job webhook delivered", "job_id", job.ID, "delivery_id", deliveryID, "request_id", reqctx.RequestID(ctx))
+		return nil
+	}
+}
+
+func toJobResponse(job jobs.Job) model.JobResponse {
+	resp := model.JobResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Stage:     job.Stage,
+		Progress:  int(job.Progress * 100),
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		Result:    job.Result,
+	}
+	if job.Err != nil {
+		var jobErr *jobs.Error
+		if errors.As(job.Err, &jobErr) {
+			apiErr := jobErr.APIError
+			resp.Error = &apiErr
+		} else {
+			_, apiErr := mapError(job.Err)
+			resp.Error = &apiErr
+		}
+	}
+	return resp
+}
+
+const spoolPattern = "echoflow-job-*"
+
+// RemoveStaleSpoolFiles deletes job audio spooled to the temp directory that
+// has not been modified since cutoff. Files are normally removed when their
+// job finishes; this catches ones left behind by a crash. A job still reading
+// a removed file keeps its open handle; on Windows, where open files cannot
+// be removed, such files are skipped until a later sweep.
+func RemoveStaleSpoolFiles(cutoff time.Time) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(os.TempDir(), spoolPattern))
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	var errs []error
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			if !fileInUse(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// spoolPipelineAudio copies the upload readers into private temp files so the
+// job can outlive the request (whose multipart form is removed on return).
+func spoolPipelineAudio(in *pipeline.ProcessInput) (func(), error) {
+	var files []*os.File
+	cleanup := func() {
+		for _, f := range files {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}
+	spool := func(src io.Reader) (io.Reader, error) {
+		f, err := os.CreateTemp("", spoolPattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		if _, err := io.Copy(f, src); err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	var err error
+	if len(in.Parts) > 0 {
+		parts := make([]pipeline.AudioPart, len(in.Parts))
+		copy(parts, in.Parts)
+		for i := range parts {
+			if parts[i].File, err = spool(parts[i].File); err != nil {
+				cleanup()
+				return nil, err
+			}
+		}
+		in.Parts = parts
+		in.File = nil
+		return cleanup, nil
+	}
+	if in.File, err = spool(in.File); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return cleanup, nil
+}
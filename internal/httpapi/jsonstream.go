@@ -0,0 +1,171 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"unicode/utf8"
+
+	"echoflow/internal/model"
+)
+
+// streamJSONThreshold is the transcript size above which pipeline responses
+// are streamed instead of being encoded into a single buffer first.
+const streamJSONThreshold = 1 << 20
+
+func writePipelineResponse(w http.ResponseWriter, status int, resp model.PipelineProcessResponse) {
+	if len(resp.RawTranscript)+len(resp.FinalTranscript) < streamJSONThreshold {
+		writeJSON(w, status, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	obj := newJSONObjectWriter(w)
+	obj.String("raw_transcript", resp.RawTranscript)
+	obj.String("final_transcript", resp.FinalTranscript)
+	obj.String("post_processing_status", resp.PostProcessingStatus)
+	obj.String("post_processing_outcome", resp.PostProcessingOutcome)
+	if resp.PostProcessingUsage != nil {
+		obj.Value("post_processing_usage", resp.PostProcessingUsage)
+	}
+	if resp.PostProcessingTier != "" {
+		obj.String("post_processing_tier", resp.PostProcessingTier)
+	}
+	if resp.Audio != nil {
+		obj.Value("audio", resp.Audio)
+	}
+	if len(resp.Preprocessing) > 0 {
+		obj.Value("preprocessing", resp.Preprocessing)
+	}
+	if resp.Language != "" {
+		obj.String("language", resp.Language)
+	}
+	if len(resp.Warnings) > 0 {
+		obj.Value("warnings", resp.Warnings)
+	}
+	if len(resp.Stages) > 0 {
+		obj.Value("stages", resp.Stages)
+	}
+	obj.Value("timings_ms", resp.TimingsMS)
+	_ = obj.Close()
+}
+
+// jsonObjectWriter writes a JSON object field by field. Large strings are
+// escaped directly into the output rather than marshaled into a copy first.
+// Output is byte-identical to encoding/json, including the trailing newline
+// json.Encoder adds.
+type jsonObjectWriter struct {
+	w      *bufio.Writer
+	fields int
+	err    error
+}
+
+func newJSONObjectWriter(w http.ResponseWriter) *jsonObjectWriter {
+	bw := bufio.NewWriterSize(w, 32<<10)
+	_ = bw.WriteByte('{')
+	return &jsonObjectWriter{w: bw}
+}
+
+func (o *jsonObjectWriter) key(name string) {
+	if o.fields > 0 {
+		_ = o.w.WriteByte(',')
+	}
+	o.fields++
+	writeJSONString(o.w, name)
+	_ = o.w.WriteByte(':')
+}
+
+func (o *jsonObjectWriter) String(name, value string) {
+	o.key(name)
+	writeJSONString(o.w, value)
+}
+
+func (o *jsonObjectWriter) Value(name string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		o.err = err
+		data = []byte("null")
+	}
+	o.key(name)
+	_, _ = o.w.Write(data)
+}
+
+func (o *jsonObjectWriter) Close() error {
+	_, _ = o.w.WriteString("}\n")
+	if err := o.w.Flush(); err != nil {
+		return err
+	}
+	return o.err
+}
+
+const hexDigits = "0123456789abcdef"
+
+// jsonSafe marks ASCII bytes that encoding/json (with HTML escaping) copies
+// through unchanged.
+var jsonSafe = func() (safe [utf8.RuneSelf]bool) {
+	for b := 0x20; b < utf8.RuneSelf; b++ {
+		safe[b] = true
+	}
+	for _, b := range []byte{'"', '\\', '<', '>', '&'} {
+		safe[b] = false
+	}
+	return safe
+}()
+
+// writeJSONString mirrors encoding/json's string encoding with HTML escaping.
+func writeJSONString(w *bufio.Writer, s string) {
+	_ = w.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if jsonSafe[b] {
+				i++
+				continue
+			}
+			_, _ = w.WriteString(s[start:i])
+			switch b {
+			case '"', '\\':
+				_ = w.WriteByte('\\')
+				_ = w.WriteByte(b)
+			case '\b':
+				_, _ = w.WriteString(`\b`)
+			case '\f':
+				_, _ = w.WriteString(`\f`)
+			case '\n':
+				_, _ = w.WriteString(`\n`)
+			case '\r':
+				_, _ = w.WriteString(`\r`)
+			case '\t':
+				_, _ = w.WriteString(`\t`)
+			default:
+				_, _ = w.WriteString(`\u00`)
+				_ = w.WriteByte(hexDigits[b>>4])
+				_ = w.WriteByte(hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			_, _ = w.WriteString(s[start:i])
+			_, _ = w.WriteString("\uFFFD")
+		case r == '\u2028' || r == '\u2029':
+			_, _ = w.WriteString(s[start:i])
+			_, _ = w.WriteString(`\u202`)
+			_ = w.WriteByte(hexDigits[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	_, _ = w.WriteString(s[start:])
+	_ = w.WriteByte('"')
+}
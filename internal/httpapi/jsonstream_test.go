@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"echoflow/internal/model"
+)
+
+func largePipelineResponse() model.PipelineProcessResponse {
+	// Cover every escape class encoding/json handles.
+	tricky := "Alice: \"quoted\" <b>&amp;</b> back\\slash\ttab\nnew\rret\b\f\x01 café \u2028\u2029 bad:\xff end. "
+	return model.PipelineProcessResponse{
+		RawTranscript:         strings.Repeat(tricky, streamJSONThreshold/len(tricky)+1),
+		FinalTranscript:       strings.Repeat("Final words. ", 1000),
+		PostProcessingStatus:  "Post-processing succeeded",
+		PostProcessingOutcome: "succeeded",
+		PostProcessingUsage:   &model.TokenUsage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3},
+		Audio:                 &model.AudioMetadata{Container: "wav", DurationMS: 3_600_000},
+		Preprocessing:         []string{"downmix"},
+		TimingsMS:             model.PipelineTimings{Transcription: 300, PostProcessing: 200, Total: 500},
+	}
+}
+
+func TestWritePipelineResponseStreamsIdenticalJSON(t *testing.T) {
+	for name, resp := range map[string]model.PipelineProcessResponse{
+		"large":      largePipelineResponse(),
+		"large_bare": {RawTranscript: strings.Repeat("x", streamJSONThreshold), FinalTranscript: "y"},
+		"small":      {RawTranscript: "raw", FinalTranscript: "final"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var want bytes.Buffer
+			_ = json.NewEncoder(&want).Encode(resp)
+
+			w := httptest.NewRecorder()
+			writePipelineResponse(w, http.StatusOK, resp)
+			if w.Code != http.StatusOK {
+				t.Fatalf("unexpected status: %d", w.Code)
+			}
+			if !bytes.Equal(w.Body.Bytes(), want.Bytes()) {
+				t.Fatalf("streamed JSON differs from encoding/json (got %d bytes, want %d)", w.Body.Len(), want.Len())
+			}
+		})
+	}
+}
+
+type discardResponseWriter struct{ header http.Header }
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+func BenchmarkPipelineResponseEncoding(b *testing.B) {
+	line := "Speaker 1: So the plan for the next sprint is to ship the deploy fixes first. "
+	for _, size := range []int{512 << 10, 4 << 20} {
+		transcript := strings.Repeat(line, size/len(line))
+		resp := model.PipelineProcessResponse{RawTranscript: transcript, FinalTranscript: transcript}
+		b.Run(fmt.Sprintf("buffered/%dKiB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				writeJSON(&discardResponseWriter{header: http.Header{}}, http.StatusOK, resp)
+			}
+		})
+		b.Run(fmt.Sprintf("streamed/%dKiB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				writePipelineResponse(&discardResponseWriter{header: http.Header{}}, http.StatusOK, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"echoflow/internal/legacy"
+)
+
+// legacyMiddleware serves clients matched in LEGACY_CLIENTS_PATH: their
+// request is moved onto the current path and field names before routing, and
+// JSON responses are mapped back to the names and statuses they expect.
+// Streams (SSE, NDJSON) and other bodies pass through unchanged.
+func (s *server) legacyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, ok := s.legacy.Match(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := client.RewriteRequest(r); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "invalid_request", "request body could not be read", nil)
+			return
+		}
+		if !client.RewritesResponses() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		lw := &legacyResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		lw.finish(client)
+	})
+}
+
+// legacyResponseWriter holds back JSON responses so they can be rewritten
+// whole; anything else goes straight to the client.
+type legacyResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	decided   bool
+	buffering bool
+	buf       bytes.Buffer
+}
+
+func (w *legacyResponseWriter) WriteHeader(status int) {
+	if w.decided {
+		return
+	}
+	w.decided, w.status = true, status
+	mediaType, _, _ := strings.Cut(w.Header().Get("Content-Type"), ";")
+	w.buffering = strings.TrimSpace(mediaType) == "application/json"
+	if !w.buffering {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *legacyResponseWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *legacyResponseWriter) Flush() {
+	if w.buffering {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *legacyResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *legacyResponseWriter) finish(client *legacy.Client) {
+	if !w.buffering {
+		return
+	}
+	body := client.RewriteResponse(w.buf.Bytes())
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"echoflow/internal/legacy"
+)
+
+func TestLegacyClientsAreMappedOntoCurrentAPI(t *testing.T) {
+	table, err := legacy.NewTable([]*legacy.Client{{
+		Name:           "echobox-1",
+		UserAgents:     []string{"EchoBox/1.*"},
+		Paths:          map[string]string{"/api/transcribe": "/v1/transcriptions"},
+		Fields:         map[string]string{"audio": "file", "asr_model": "model"},
+		ResponseFields: map[string]string{"text": "transcript"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &stubTranscription{text: "hello"}
+	h := newTestHandler(t, Dependencies{
+		Transcription: tr,
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Legacy:        table,
+	})
+
+	upload := func(path, agent string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("asr_model", "whisper-large-v3")
+		part, _ := mw.CreateFormFile("audio", "sample.wav")
+		_, _ = part.Write([]byte("audio-bytes"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, path, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("User-Agent", agent)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("/api/transcribe", "EchoBox/1.4 (fw 2023-07)")
+	if w.Code != http.StatusOK {
+		t.Fatalf("legacy upload status %d body=%s", w.Code, w.Body.String())
+	}
+	if tr.fileBody != "audio-bytes" || tr.model != "whisper-large-v3" {
+		t.Fatalf("transcriber got file %q, model %q", tr.fileBody, tr.model)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp["transcript"] != "hello" || resp["text"] != nil {
+		t.Fatalf("legacy response = %s (%v)", w.Body.String(), err)
+	}
+
+	if w := upload("/api/transcribe", "EchoBox/2.0"); w.Code != http.StatusNotFound {
+		t.Fatalf("current client on old path = %d, want 404", w.Code)
+	}
+}
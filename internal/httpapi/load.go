@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"echoflow/internal/reqctx"
+)
+
+// inFlightMiddleware counts /v1 requests that are doing work. GETs are left
+// out since job polling and event streams are cheap but can be long-lived.
+// Once shutdown has started draining the replica, every new request is
+// refused.
+func (s *server) inFlightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.drain.enter() {
+			s.shuttingDown(w, r)
+			return
+		}
+		defer s.drain.leave()
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// shedLoad refuses a request that takes the in-flight count past
+// SHED_MAX_IN_FLIGHT, or that arrives while more than SHED_MAX_QUEUE_DEPTH
+// jobs are waiting, so the caller can retry elsewhere instead of timing out
+// in line. Routes apply it after authentication and the scope check, so a
+// request that would be refused anyway gets its 401 or 403, and before the
+// quota is charged.
+func (s *server) shedLoad(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxDepth, maxInFlight := s.cfg.ShedMaxQueueDepth, s.cfg.ShedMaxInFlight
+		if inFlight := s.inFlight.Load(); maxInFlight > 0 && inFlight > int64(maxInFlight) {
+			s.shed(w, r, map[string]any{"in_flight": inFlight - 1, "max_in_flight": maxInFlight})
+			return
+		}
+		if depth := s.jobs.QueueDepth(); maxDepth > 0 && depth > maxDepth {
+			s.shed(w, r, map[string]any{"queue_depth": depth, "max_queue_depth": maxDepth})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) shed(w http.ResponseWriter, r *http.Request, details map[string]any) {
+	reqctx.Logger(r.Context()).Warn("request shed", "details", details)
+	w.Header().Set("Retry-After", "5")
+	s.writeError(w, r, http.StatusServiceUnavailable, "overloaded", "replica is over its load thresholds, retry later", details)
+}
+
+// overloaded returns the load figures when the job queue or in-flight
+// requests exceed READY_MAX_QUEUE_DEPTH or READY_MAX_IN_FLIGHT, so /readyz
+// takes the replica out of rotation before latency collapses.
+func (s *server) overloaded() map[string]any {
+	depth, inFlight := s.jobs.QueueDepth(), s.inFlight.Load()
+	maxDepth, maxInFlight := s.cfg.ReadyMaxQueueDepth, s.cfg.ReadyMaxInFlight
+	if (maxDepth > 0 && depth > maxDepth) || (maxInFlight > 0 && inFlight > int64(maxInFlight)) {
+		return map[string]any{
+			"queue_depth":     depth,
+			"max_queue_depth": maxDepth,
+			"in_flight":       inFlight,
+			"max_in_flight":   maxInFlight,
+		}
+	}
+	return nil
+}
+
+// cachedUpstreamCheck returns the background prober's latest outcome.
+// checked is false without a prober or before its first probe, and callers
+// then check the upstream themselves.
+func (s *server) cachedUpstreamCheck() (checked bool, err error) {
+	if s.upstreamLast == nil {
+		return false, nil
+	}
+	return s.upstreamLast.LastCheck()
+}
+
+var errParsersBusy = errors.New("all multipart parsers are busy")
+
+// acquireParser takes one of the MULTIPART_MAX_PARSERS slots. Parsing spools
+// uploads to temp files, which is what saturates disk first under load, so
+// it is bounded separately from pipeline workers. A request that cannot get a
+// slot within MULTIPART_PARSER_WAIT_MS gets errParsersBusy.
+func (s *server) acquireParser(ctx context.Context) (release func(), err error) {
+	if s.parsers == nil {
+		return func() {}, nil
+	}
+	release = func() { <-s.parsers }
+	select {
+	case s.parsers <- struct{}{}:
+		return release, nil
+	default:
+	}
+	timer := time.NewTimer(s.cfg.MultipartParserWait)
+	defer timer.Stop()
+	select {
+	case s.parsers <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, errParsersBusy
+	case <-ctx.Done():
+		return nil, errParsersBusy
+	}
+}
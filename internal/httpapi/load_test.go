@@ -0,0 +1,248 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/config"
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+)
+
+type blockingPostProcess struct {
+	stubPostProcess
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingPostProcess) Process(context.Context, postprocess.Input) (postprocess.Result, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return postprocess.Result{Transcript: "ok"}, nil
+}
+
+func TestReadyzFailsWhenInFlightExceedsThreshold(t *testing.T) {
+	postProcess := &blockingPostProcess{started: make(chan struct{}, 2), release: make(chan struct{})}
+	h := NewServer(config.Config{MaxUploadBytes: 1 << 20, ReadyMaxInFlight: 1}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   postProcess,
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	readyz := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+
+	done := make(chan struct{})
+	for range 2 {
+		go func() {
+			req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hi"}`))
+			req.Header.Set("Authorization", "Bearer gsk_caller")
+			req.Header.Set("Content-Type", "application/json")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			done <- struct{}{}
+		}()
+	}
+	<-postProcess.started
+	<-postProcess.started
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz with 2 in flight = %d, want 503", code)
+	}
+
+	close(postProcess.release)
+	<-done
+	<-done
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("readyz after requests finished = %d, want 200", code)
+	}
+}
+
+func TestInFlightOverShedThresholdIsRefused(t *testing.T) {
+	postProcess := &blockingPostProcess{started: make(chan struct{}, 1), release: make(chan struct{})}
+	h := NewServer(config.Config{MaxUploadBytes: 1 << 20, ShedMaxInFlight: 1}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   postProcess,
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	postProcessReq := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hi"}`))
+		req.Header.Set("Authorization", "Bearer gsk_caller")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan struct{})
+	go func() {
+		postProcessReq()
+		close(done)
+	}()
+	<-postProcess.started
+
+	w := postProcessReq()
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" || !strings.Contains(w.Body.String(), `"overloaded"`) {
+		t.Fatalf("second request = %d %q (Retry-After %q), want 503 overloaded", w.Code, w.Body.String(), w.Header().Get("Retry-After"))
+	}
+
+	close(postProcess.release)
+	<-done
+	go func() { <-postProcess.started }()
+	if w := postProcessReq(); w.Code != http.StatusOK {
+		t.Fatalf("request after load drained = %d, want 200", w.Code)
+	}
+}
+
+func TestShedRunsAfterAuthAndScopeChecks(t *testing.T) {
+	postProcess := &blockingPostProcess{started: make(chan struct{}, 2), release: make(chan struct{})}
+	h := newAuthTestHandlerWithConfig(t, config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x", ShedMaxInFlight: 1}, postProcess)
+	send := func(path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"transcript":"hi"}`))
+		req.Header.Set("Authorization", authorization)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan struct{})
+	go func() {
+		send("/v1/post-process", "Bearer ef_acme_admin")
+		close(done)
+	}()
+	<-postProcess.started
+
+	if w := send("/v1/post-process", "Basic ZWY6YWNtZQ=="); w.Code != http.StatusUnauthorized {
+		t.Fatalf("malformed credentials while overloaded = %d %q, want 401", w.Code, w.Body.String())
+	}
+	if w := send("/v1/pipeline/process", "Bearer ef_acme"); w.Code != http.StatusForbidden {
+		t.Fatalf("out-of-scope request while overloaded = %d %q, want 403", w.Code, w.Body.String())
+	}
+	if w := send("/v1/post-process", "Bearer ef_acme"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("in-scope request while overloaded = %d %q, want 503", w.Code, w.Body.String())
+	}
+	// The shed request was not charged: acme-web's single daily request is
+	// still there once the load has gone.
+	close(postProcess.release)
+	<-done
+	if w := send("/v1/post-process", "Bearer ef_acme"); w.Code != http.StatusOK {
+		t.Fatalf("request after load drained = %d %q, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateJobRefusedWhenBacklogExceedsAdmissionSLA(t *testing.T) {
+	manager := jobs.NewManager(jobs.WithWorkers(1, 10))
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	for range 2 {
+		_, _ = manager.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+			<-release
+			return model.PipelineProcessResponse{}, nil
+		}, nil)
+	}
+	for manager.QueueDepth() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	h := NewServer(config.Config{MaxUploadBytes: 1 << 20, JobAdmissionSLA: 5 * time.Second}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Jobs:          manager,
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", &body)
+	req.Header.Set("Authorization", "Bearer gsk_caller")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	// One job waits behind one running, at the default 13s a job.
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "overloaded") {
+		t.Fatalf("status = %d body=%s, want 503 overloaded", w.Code, w.Body.String())
+	}
+	// Retrying once the estimate is back under the SLA: just over 8s.
+	if got := w.Header().Get("Retry-After"); got != "9" {
+		t.Fatalf("Retry-After = %q, want 9", got)
+	}
+}
+
+// signalReader closes started on its first Read, then blocks on the pipe.
+type signalReader struct {
+	io.Reader
+	started chan struct{}
+	once    sync.Once
+}
+
+func (r *signalReader) Read(p []byte) (int, error) {
+	r.once.Do(func() { close(r.started) })
+	return r.Reader.Read(p)
+}
+
+func TestMultipartParsersOverLimitAreRefused(t *testing.T) {
+	cfg := config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x", MultipartMaxParsers: 1, MultipartParserWait: 20 * time.Millisecond}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	body := &signalReader{Reader: pr, started: make(chan struct{})}
+	slow := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", body)
+	slow.Header.Set("Content-Type", mw.FormDataContentType())
+	slowDone := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, slow)
+		slowDone <- w.Code
+	}()
+	<-body.started
+
+	transcribe := func() *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		part, _ := mw.CreateFormFile("file", "sample.wav")
+		_, _ = part.Write([]byte("audio"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	if w := transcribe(); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" || !strings.Contains(w.Body.String(), `"max_multipart_parsers":1`) {
+		t.Fatalf("request while parser busy = %d %s", w.Code, w.Body.String())
+	}
+
+	part, _ := mw.CreateFormFile("file", "slow.wav")
+	_, _ = part.Write([]byte("audio"))
+	_ = mw.Close()
+	_ = pw.Close()
+	if code := <-slowDone; code != http.StatusOK {
+		t.Fatalf("slow upload = %d", code)
+	}
+	if w := transcribe(); w.Code != http.StatusOK {
+		t.Fatalf("request after parser freed = %d %s", w.Code, w.Body.String())
+	}
+}
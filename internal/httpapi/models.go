@@ -0,0 +1,31 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"echoflow/internal/upstream"
+)
+
+// checkModels validates client-supplied model names against their format and
+// MODEL_ALLOWLIST before any of them reaches an upstream request.
+func (s *server) checkModels(models ...string) error {
+	for _, m := range models {
+		if err := upstream.CheckModel(strings.TrimSpace(m), s.cfg.ModelAllowlist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOptionsError reports a form option error: invalid_model for model
+// names, invalid_request for everything else.
+func (s *server) writeOptionsError(w http.ResponseWriter, r *http.Request, err error) {
+	var modelErr *upstream.ModelError
+	if errors.As(err, &modelErr) {
+		s.writeMappedError(w, r, err)
+		return
+	}
+	s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+}
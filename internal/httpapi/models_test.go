@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"echoflow/internal/config"
+)
+
+func TestRejectsInvalidModelsBeforeCallingUpstream(t *testing.T) {
+	tr := &stubTranscription{text: "ok"}
+	pipe := &stubPipeline{}
+	cfg := config.Config{MaxUploadBytes: 1 << 20, UpstreamAPIKey: "x", ModelAllowlist: []string{"whisper-*", "llama-*"}}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: tr,
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      pipe,
+		Upstream:      stubUpstream{},
+	})
+	multipartRequest := func(path, field, model string) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField(field, model)
+		part, _ := mw.CreateFormFile("file", "sample.wav")
+		_, _ = part.Write([]byte("audio-bytes"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, path, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+	jsonRequest := func(path, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{"malformed transcription model", multipartRequest("/v1/transcriptions", "model", "../../v1/admin")},
+		{"transcription model outside allowlist", multipartRequest("/v1/transcriptions", "model", "whsiper-large-v3")},
+		{"pipeline post-processing model", multipartRequest("/v1/pipeline/process", "post_process_model", "llama 3")},
+		{"post-processing model", jsonRequest("/v1/post-process", `{"transcript": "hi", "model": "gpt-4o\r\nX-Injected: 1"}`)},
+		{"audio_url transcription model", jsonRequest("/v1/pipeline/process", `{"audio_url": "https://example.com/a.wav", "transcription_model": "nova-2"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, tt.req)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"invalid_model"`) {
+				t.Fatalf("status = %d %s", w.Code, w.Body.String())
+			}
+		})
+	}
+	if tr.fileBody != "" || pipe.fileBody != "" {
+		t.Fatal("upstream called with an invalid model")
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, multipartRequest("/v1/transcriptions", "model", "whisper-large-v3-turbo"))
+	if w.Code != http.StatusOK || tr.model != "whisper-large-v3-turbo" {
+		t.Fatalf("allowed model: status %d, model %q", w.Code, tr.model)
+	}
+}
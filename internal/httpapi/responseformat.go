@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/subtitle"
+	"echoflow/internal/transcription"
+)
+
+// Values of the response_format field. verbose_json is only offered by
+// /v1/transcriptions and /v1/translations; srt and vtt are caption files,
+// and vtt is also a chapters track from /v1/chapters.
+const (
+	responseFormatJSON        = "json"
+	responseFormatVerboseJSON = "verbose_json"
+	responseFormatSRT         = "srt"
+	responseFormatVTT         = "vtt"
+)
+
+const errResponseFormatUnsupported = "response_format is only supported by /v1/transcriptions, /v1/translations, /v1/pipeline/process and /v1/chapters"
+
+var (
+	transcriptionFormats = []string{responseFormatJSON, responseFormatVerboseJSON, responseFormatSRT, responseFormatVTT}
+	pipelineFormats      = []string{responseFormatJSON, responseFormatSRT, responseFormatVTT}
+)
+
+// parseResponseFormat reads response_format, one of formats; it defaults to
+// json.
+func parseResponseFormat(raw string, formats []string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(raw))
+	if format == "" {
+		return responseFormatJSON, nil
+	}
+	if !slices.Contains(formats, format) {
+		return "", fmt.Errorf("response_format must be one of %s", strings.Join(formats, ", "))
+	}
+	return format, nil
+}
+
+func isSubtitleFormat(format string) bool {
+	return format == responseFormatSRT || format == responseFormatVTT
+}
+
+// parseTimestampGranularities reads timestamp_granularities, a list of
+// segment and word, and reports whether word timings were asked for.
+// Segments come with every verbose_json response either way.
+func parseTimestampGranularities(values []string) (words bool, err error) {
+	for _, value := range values {
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "segment", "":
+		case "word":
+			words = true
+		default:
+			return false, fmt.Errorf("timestamp_granularities must be segment or word, got %q", value)
+		}
+	}
+	return words, nil
+}
+
+// wantsSegments reports whether format needs segment timings from upstream.
+func wantsSegments(format string) bool {
+	return format == responseFormatVerboseJSON || isSubtitleFormat(format)
+}
+
+func writeTranscription(w http.ResponseWriter, format string, result transcription.Result, audioMeta *model.AudioMetadata) {
+	if isSubtitleFormat(format) {
+		cues := make([]subtitle.Cue, 0, len(result.Segments))
+		for _, seg := range result.Segments {
+			cues = append(cues, subtitle.Cue{Start: seg.Start, End: seg.End, Text: seg.Text})
+		}
+		writeSubtitles(w, format, cues, result.Text, audioMeta)
+		return
+	}
+	resp := model.TranscriptionResponse{
+		Text:          result.Text,
+		Audio:         audioMeta,
+		Preprocessing: result.Preprocessing,
+		Language:      result.Language,
+		Warnings:      result.Warnings,
+	}
+	if format != responseFormatVerboseJSON {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	segments := make([]model.TranscriptionSegment, 0, len(result.Segments))
+	for _, seg := range result.Segments {
+		segments = append(segments, model.TranscriptionSegment{
+			Start:      seg.Start.Seconds(),
+			End:        seg.End.Seconds(),
+			Text:       seg.Text,
+			Confidence: seg.Confidence,
+		})
+	}
+	writeJSON(w, http.StatusOK, model.TranscriptionVerboseResponse{TranscriptionResponse: resp, Segments: segments, Words: toModelWords(result.Words)})
+}
+
+func toModelWords(words []transcription.Word) []model.TranscriptionWord {
+	if len(words) == 0 {
+		return nil
+	}
+	out := make([]model.TranscriptionWord, len(words))
+	for i, w := range words {
+		out[i] = toModelWord(w, "")
+	}
+	return out
+}
+
+func toModelWord(w transcription.Word, speaker string) model.TranscriptionWord {
+	return model.TranscriptionWord{Start: w.Start.Seconds(), End: w.End.Seconds(), Word: w.Text, Confidence: w.Confidence, Speaker: speaker}
+}
+
+// writePipelineSubtitles captions the final transcript. When post-processing
+// rewrote the transcript, its text is re-flowed onto the raw segments'
+// timings.
+func writePipelineSubtitles(w http.ResponseWriter, format string, result pipeline.ProcessResult, audioMeta *model.AudioMetadata) {
+	cues := make([]subtitle.Cue, 0, len(result.Segments))
+	for _, seg := range result.Segments {
+		cues = append(cues, subtitle.Cue{Start: seg.Start, End: seg.End, Speaker: seg.Speaker, Text: seg.Text})
+	}
+	if result.FinalTranscript != result.RawTranscript {
+		cues = subtitle.Reflow(cues, result.FinalTranscript)
+	}
+	writeSubtitles(w, format, cues, result.FinalTranscript, audioMeta)
+}
+
+// writeSubtitles writes cues as an SRT or WebVTT file. Without segments from
+// the upstream, text becomes one cue spanning the audio.
+func writeSubtitles(w http.ResponseWriter, format string, cues []subtitle.Cue, text string, audioMeta *model.AudioMetadata) {
+	if len(cues) == 0 && strings.TrimSpace(text) != "" {
+		var end time.Duration
+		if audioMeta != nil {
+			end = time.Duration(audioMeta.DurationMS) * time.Millisecond
+		}
+		cues = []subtitle.Cue{{End: end, Text: text}}
+	}
+	body, contentType := subtitle.SRT(cues), "application/x-subrip; charset=utf-8"
+	if format == responseFormatVTT {
+		body, contentType = subtitle.VTT(cues), "text/vtt; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, body)
+}
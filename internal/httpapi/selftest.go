@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
+)
+
+const (
+	selftestTimeout    = 60 * time.Second
+	selftestFileName   = "selftest.wav"
+	selftestTranscript = "um so the the deploy is uh done and everything looks good"
+)
+
+// selftestAudio is a 1.5s 16 kHz mono WAV of a rising tone. It carries no
+// speech, so the transcript is usually empty; the point is that every
+// upstream call succeeds.
+var selftestAudio = sync.OnceValue(func() []byte {
+	const rate = 16000
+	samples := make([]float64, rate*3/2)
+	for i := range samples {
+		t := float64(i) / rate
+		samples[i] = 0.3 * math.Sin(2*math.Pi*(300+200*t)*t)
+	}
+	return audio.FromSamples([][]float64{samples}, rate).Encode()
+})
+
+// handleSelftest runs each stage against the real upstream with the
+// server-side key and reports them separately, so a failing deploy shows
+// which dependency is broken.
+func (s *server) handleSelftest(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), selftestTimeout)
+	defer cancel()
+	wav := selftestAudio()
+
+	resp := model.SelftestResponse{OK: true}
+	stage := func(name string, run func() (string, error)) {
+		started := time.Now()
+		output, err := run()
+		result := model.SelftestStage{Name: name, OK: err == nil, DurationMS: time.Since(started).Milliseconds(), Output: output}
+		if err != nil {
+			_, apiErr := mapError(err)
+			result.Error = &apiErr
+			resp.OK = false
+		}
+		resp.Stages = append(resp.Stages, result)
+	}
+
+	stage("upstream", func() (string, error) {
+		return "", s.upstream.CheckHealth(ctx)
+	})
+	stage("transcription", func() (string, error) {
+		result, err := s.transcriber.Transcribe(ctx, transcription.Input{
+			File:     bytes.NewReader(wav),
+			FileName: selftestFileName,
+			Size:     int64(len(wav)),
+		})
+		return result.Text, err
+	})
+	stage("post_processing", func() (string, error) {
+		result, err := s.postProcess.Process(ctx, postprocess.Input{Transcript: selftestTranscript})
+		return result.Transcript, err
+	})
+	stage("pipeline", func() (string, error) {
+		result, err := s.pipeline.Process(ctx, pipeline.ProcessInput{
+			File:     bytes.NewReader(wav),
+			FileName: selftestFileName,
+			FileSize: int64(len(wav)),
+		})
+		return result.PostProcessingStatus, err
+	})
+
+	reqctx.Logger(r.Context()).Info("selftest finished", "ok", resp.OK)
+	status := http.StatusOK
+	if !resp.OK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
@@ -1,6 +1,7 @@
 package httpapi
 
 import (
+	"cmp"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
@@ -10,68 +11,162 @@ import (
 	"io"
 	"log/slog"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"echoflow/internal/acronyms"
+	"echoflow/internal/audio"
+	"echoflow/internal/auth"
+	"echoflow/internal/bufpool"
 	"echoflow/internal/config"
+	"echoflow/internal/deadline"
+	"echoflow/internal/fetch"
+	"echoflow/internal/jobs"
+	"echoflow/internal/legacy"
 	"echoflow/internal/model"
 	"echoflow/internal/pipeline"
 	"echoflow/internal/postprocess"
-	"echoflow/internal/upstream/openai"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
 )
 
 type TranscriptionService interface {
-	Transcribe(ctx context.Context, file io.Reader, fileName, model string) (string, error)
+	Transcribe(ctx context.Context, in transcription.Input) (transcription.Result, error)
 }
 
 type PostProcessService interface {
 	Process(ctx context.Context, in postprocess.Input) (postprocess.Result, error)
+	ProcessStream(ctx context.Context, in postprocess.Input, onDelta func(string) error) (postprocess.Result, error)
+}
+
+type ChapterService interface {
+	Chapters(ctx context.Context, in postprocess.ChaptersInput) (postprocess.ChaptersResult, error)
 }
 
 type PipelineService interface {
 	Process(ctx context.Context, in pipeline.ProcessInput) (pipeline.ProcessResult, error)
 }
 
+type JobService interface {
+	// Submit returns jobs.ErrQueueFull when the local worker pool is saturated.
+	Submit(ctx context.Context, task jobs.Task, cleanup func(), opts ...jobs.SubmitOption) (jobs.Job, error)
+	// Enqueue hands a serialized job to a shared queue when HasQueue reports
+	// one; any replica may then run it.
+	Enqueue(ctx context.Context, payload []byte, opts ...jobs.SubmitOption) (jobs.Job, error)
+	HasQueue() bool
+	// SubmitPayload journals a serialized job, written by encode, before
+	// running it locally, when HasJournal reports a journal, so it survives a
+	// restart.
+	SubmitPayload(ctx context.Context, encode func(io.Writer) error, opts ...jobs.SubmitOption) (jobs.Job, error)
+	HasJournal() bool
+	// QueueDepth is the number of local jobs waiting for a worker.
+	QueueDepth() int
+	// EstimateJob predicts the queue wait and run time of a local job for
+	// audio of the given duration (0 if unknown).
+	EstimateJob(audio time.Duration) jobs.Estimate
+	// CheckStore reports whether the job store and queue are reachable.
+	CheckStore(ctx context.Context) error
+	// Get and Watch only find jobs submitted by key.Tenant.
+	Get(key jobs.Key) (jobs.Job, bool)
+	Watch(key jobs.Key) (jobs.Job, <-chan struct{}, bool)
+}
+
+type AudioFetcher interface {
+	Fetch(ctx context.Context, url string) (*fetch.File, error)
+}
+
 type UpstreamChecker interface {
-	CheckModels(ctx context.Context) error
+	CheckHealth(ctx context.Context) error
+}
+
+// UpstreamStatus is a background upstream health check.
+type UpstreamStatus interface {
+	// LastCheck returns the latest outcome; checked is false before the first.
+	LastCheck() (checked bool, err error)
 }
 
 type MetricsObserver interface {
 	ObserveHTTP(route, method string, status int, duration time.Duration)
 	IncPipelineFallback()
+	ObserveAudioDuration(duration time.Duration)
 }
 
 type Dependencies struct {
-	Transcription  TranscriptionService
-	PostProcess    PostProcessService
-	Pipeline       PipelineService
-	Upstream       UpstreamChecker
+	Transcription TranscriptionService
+	PostProcess   PostProcessService
+	Pipeline      PipelineService
+	Jobs          JobService
+	Upstream      UpstreamChecker
+	// Chapters enables POST /v1/chapters.
+	Chapters ChapterService
+	// Fetcher downloads audio_url; it defaults to one limited to MAX_UPLOAD_BYTES.
+	Fetcher AudioFetcher
+	// Keys enables POST /admin/upstream-key when ADMIN_TOKEN is set.
+	Keys KeyRotator
+	// Tokens resolves EchoFlow-issued tokens; nil treats every token as BYOT.
+	Tokens *auth.Registry
+	Quotas *auth.Quotas
+	// Legacy maps older clients onto the current API; nil serves every
+	// client as it is.
+	Legacy *legacy.Table
+	// Acronyms holds tenant acronym dictionaries; it defaults to an in-memory store.
+	Acronyms       *acronyms.Store
 	Metrics        MetricsObserver
 	MetricsHandler http.Handler
+	// MetricsText and Logs feed GET /admin/diagnostics; either may be nil.
+	MetricsText MetricsSnapshotter
+	Logs        LogHistory
+	// Drain lets shutdown wait for in-flight requests; it defaults to one
+	// nobody drains.
+	Drain *Drain
+	// UpstreamStatus, when set, answers /readyz's upstream check from the
+	// background prober instead of calling the upstream per probe.
+	UpstreamStatus UpstreamStatus
 }
 
 type server struct {
 	cfg          config.Config
 	logger       *slog.Logger
+	routes       chi.Routes
 	transcriber  TranscriptionService
 	postProcess  PostProcessService
 	pipeline     PipelineService
+	chapters     ChapterService
+	jobs         JobService
 	upstream     UpstreamChecker
+	upstreamLast UpstreamStatus
+	fetcher      AudioFetcher
+	keys         KeyRotator
+	tokens       *auth.Registry
+	quotas       *auth.Quotas
+	acronyms     *acronyms.Store
+	legacy       *legacy.Table
 	metrics      MetricsObserver
 	metricsRoute http.Handler
+	metricsText  MetricsSnapshotter
+	logs         LogHistory
+	startedAt    time.Time
+	inFlight     atomic.Int64
+	parsers      chan struct{}
+	drain        *Drain
+	stats        *usageStats
 }
 
-type ctxKey string
-
 const (
 	requestIDHeader  = "X-Request-Id"
-	requestIDContext = ctxKey("request_id")
 	maxJSONBodyBytes = 1 << 20
+	maxAudioParts    = 8
+	// writeDeadlineGrace leaves room to encode the response after the
+	// upstream budget has been spent.
+	writeDeadlineGrace = 5 * time.Second
 )
 
 func NewServer(cfg config.Config, logger *slog.Logger, deps Dependencies) http.Handler {
@@ -81,6 +176,18 @@ func NewServer(cfg config.Config, logger *slog.Logger, deps Dependencies) http.H
 	if deps.Transcription == nil || deps.PostProcess == nil || deps.Pipeline == nil || deps.Upstream == nil {
 		panic("httpapi: all dependencies are required")
 	}
+	if deps.Jobs == nil {
+		deps.Jobs = jobs.NewManager()
+	}
+	if deps.Fetcher == nil {
+		deps.Fetcher = fetch.New(fetch.WithMaxBytes(cfg.MaxUploadBytes))
+	}
+	if deps.Acronyms == nil {
+		deps.Acronyms, _ = acronyms.NewStore("")
+	}
+	if deps.Drain == nil {
+		deps.Drain = NewDrain()
+	}
 
 	s := &server{
 		cfg:          cfg,
@@ -88,9 +195,26 @@ func NewServer(cfg config.Config, logger *slog.Logger, deps Dependencies) http.H
 		transcriber:  deps.Transcription,
 		postProcess:  deps.PostProcess,
 		pipeline:     deps.Pipeline,
+		chapters:     deps.Chapters,
+		jobs:         deps.Jobs,
 		upstream:     deps.Upstream,
+		upstreamLast: deps.UpstreamStatus,
+		fetcher:      deps.Fetcher,
+		keys:         deps.Keys,
+		tokens:       deps.Tokens,
+		quotas:       deps.Quotas,
+		acronyms:     deps.Acronyms,
+		legacy:       deps.Legacy,
 		metrics:      deps.Metrics,
 		metricsRoute: deps.MetricsHandler,
+		metricsText:  deps.MetricsText,
+		logs:         deps.Logs,
+		startedAt:    time.Now(),
+		drain:        deps.Drain,
+		stats:        newUsageStats(),
+	}
+	if cfg.MultipartMaxParsers > 0 {
+		s.parsers = make(chan struct{}, cfg.MultipartMaxParsers)
 	}
 
 	r := chi.NewRouter()
@@ -104,7 +228,10 @@ func NewServer(cfg config.Config, logger *slog.Logger, deps Dependencies) http.H
 	r.Use(s.requestIDMiddleware)
 	r.Use(s.loggingMiddleware)
 	r.Use(s.recoverMiddleware)
+	r.Use(s.legacyMiddleware)
 	r.Use(s.authMiddleware)
+	r.Use(s.requestLoggerMiddleware)
+	s.routes = r
 
 	r.Get("/healthz", s.handleHealthz)
 	r.Get("/readyz", s.handleReadyz)
@@ -113,11 +240,38 @@ func NewServer(cfg config.Config, logger *slog.Logger, deps Dependencies) http.H
 	}
 
 	r.Route("/v1", func(r chi.Router) {
-		r.Post("/transcriptions", s.handleTranscriptions)
-		r.Post("/post-process", s.handlePostProcess)
-		r.Post("/pipeline/process", s.handlePipelineProcess)
+		r.Use(s.summaryMiddleware)
+		r.Use(s.inFlightMiddleware)
+		r.Get("/auth/whoami", s.handleWhoAmI)
+		r.With(s.requireScope(auth.ScopeTranscribe), s.shedLoad, s.consumeQuota).Post("/transcriptions", s.handleTranscriptions)
+		r.With(s.requireScope(auth.ScopeTranscribe), s.shedLoad, s.consumeQuota).Post("/translations", s.handleTranslations)
+		r.With(s.requireScope(auth.ScopePostProcess), s.shedLoad, s.consumeQuota).Post("/post-process", s.handlePostProcess)
+		if s.chapters != nil {
+			r.With(s.requireScope(auth.ScopePostProcess), s.shedLoad, s.consumeQuota).Post("/chapters", s.handleChapters)
+		}
+		r.With(s.requireScope(auth.ScopePipeline), s.shedLoad, s.consumeQuota).Post("/pipeline/process", s.handlePipelineProcess)
+		// Batches charge one request per file, once the files are counted.
+		r.With(s.requireScope(auth.ScopePipeline), s.shedLoad).Post("/pipeline/batch", s.handlePipelineBatch)
+		r.With(s.requireScope(auth.ScopeJobs), s.shedLoad, s.consumeQuota).Post("/jobs", s.handleCreateJob)
+		r.With(s.requireScope(auth.ScopeJobs)).Get("/jobs/{id}", s.handleGetJob)
+		r.With(s.requireScope(auth.ScopeJobs)).Get("/jobs/{id}/events", s.handleJobEvents)
+		r.With(s.requireScope(auth.ScopePostProcess)).Get("/acronyms", s.handleListAcronyms)
+		r.With(s.requireScope(auth.ScopeAcronymsWrite), s.shedLoad).Put("/acronyms/{acronym}", s.handlePutAcronym)
+		r.With(s.requireScope(auth.ScopeAcronymsWrite), s.shedLoad).Delete("/acronyms/{acronym}", s.handleDeleteAcronym)
 	})
 
+	if cfg.AdminToken != "" {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(s.adminMiddleware)
+			if s.keys != nil {
+				r.Post("/upstream-key", s.handleRotateUpstreamKey)
+			}
+			r.Post("/selftest", s.handleSelftest)
+			r.Get("/diagnostics", s.handleDiagnostics)
+			r.Get("/summary", s.handleSummary)
+		})
+	}
+
 	return r
 }
 
@@ -126,36 +280,142 @@ func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
-	if s.cfg.UpstreamAPIKey == "" && openai.RequestAPIKeyFromContext(r.Context()) == "" {
+	if s.drain.Draining() {
+		s.shuttingDown(w, r)
+		return
+	}
+	if details := s.overloaded(); details != nil {
+		w.Header().Set("Retry-After", "5")
+		s.writeError(w, r, http.StatusServiceUnavailable, "overloaded", "replica is over its load thresholds", details)
+		return
+	}
+	if err := s.checkStore(r.Context()); err != nil {
+		s.writeError(w, r, http.StatusServiceUnavailable, "not_ready", "job store check failed", detailsForError(err))
+		return
+	}
+	if checked, err := s.cachedUpstreamCheck(); checked {
+		if err != nil {
+			s.writeError(w, r, http.StatusServiceUnavailable, "not_ready", "upstream check failed", detailsForError(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, model.ReadyResponse{OK: true, ServiceName: "EchoFlow"})
+		return
+	}
+	if s.cfg.UpstreamAPIKey == "" && upstream.RequestAPIKeyFromContext(r.Context()) == "" {
 		writeJSON(w, http.StatusOK, model.ReadyResponse{OK: true, ServiceName: "EchoFlow"})
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
-	if err := s.upstream.CheckModels(ctx); err != nil {
+	if err := s.upstream.CheckHealth(ctx); err != nil {
 		s.writeError(w, r, http.StatusServiceUnavailable, "not_ready", "upstream check failed", detailsForError(err))
 		return
 	}
 	writeJSON(w, http.StatusOK, model.ReadyResponse{OK: true, ServiceName: "EchoFlow"})
 }
 
+// checkStore pings the job store with its own timeout, so a slow store cannot
+// eat the upstream check's budget.
+func (s *server) checkStore(ctx context.Context) error {
+	timeout := s.cfg.ReadyStoreTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return s.jobs.CheckStore(ctx)
+}
+
 func (s *server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
-	file, header, form, err := s.readMultipartAudio(w, r)
+	s.transcribeUpload(w, r, false)
+}
+
+// handleTranslations transcribes the upload into English text, through the
+// upstream's translation endpoint.
+func (s *server) handleTranslations(w http.ResponseWriter, r *http.Request) {
+	s.transcribeUpload(w, r, true)
+}
+
+func (s *server) transcribeUpload(w http.ResponseWriter, r *http.Request, translate bool) {
+	fields := transcriptionFields
+	if translate {
+		fields = translationFields
+	}
+	file, header, form, err := s.readMultipartAudio(w, r, fields)
 	if err != nil {
+		cleanupMultipartForm(form)
 		s.handleMultipartReadError(w, r, err)
 		return
 	}
 	defer cleanupMultipartForm(form)
 	defer func() { _ = file.Close() }()
 
-	text, err := s.transcriber.Transcribe(r.Context(), file, header.Filename, strings.TrimSpace(r.FormValue("model")))
+	timeout, err := s.formTimeout(r)
 	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+	r = withTimeout(r, timeout)
+	s.startProcessingDeadline(w, cmp.Or(timeout, s.transcriptionTimeouts().For(header.Size)))
+
+	preprocess, err := parsePreprocessOptions(r)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+	if err := s.checkModels(r.FormValue("model")); err != nil {
+		s.writeMappedError(w, r, err)
+		return
+	}
+	format, err := parseResponseFormat(r.FormValue("response_format"), transcriptionFormats)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+	// Translations take neither a language hint nor word timings.
+	var language string
+	var words bool
+	if !translate {
+		language = strings.TrimSpace(r.FormValue("language"))
+		if err := transcription.CheckLanguage(language); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+			return
+		}
+		words, err = parseTimestampGranularities(splitLabels(r.FormValue("timestamp_granularities")))
+		if err == nil && words && format != responseFormatVerboseJSON {
+			err = errors.New("timestamp_granularities=word requires response_format=verbose_json")
+		}
+		if err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+			return
+		}
+	}
+	audioMeta := probeUpload(file, header.Size)
+	if err := s.checkAudioDuration(audioMeta); err != nil {
 		s.writeMappedError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, model.TranscriptionResponse{Text: text})
+	result, err := s.transcriber.Transcribe(r.Context(), transcription.Input{
+		File:            file,
+		FileName:        s.uploadFileName(header.Filename, header.Header.Get("Content-Type"), audioMeta),
+		Size:            header.Size,
+		Model:           strings.TrimSpace(r.FormValue("model")),
+		Language:        language,
+		Prompt:          r.FormValue("prompt"),
+		IncludeSegments: wantsSegments(format),
+		IncludeWords:    words,
+		Translate:       translate,
+		Preprocess:      preprocess,
+	})
+	if err != nil {
+		s.writeMappedError(w, r, err)
+		return
+	}
+
+	s.setUsageHeaders(w, r, requestUsage{audio: audioUsage(audioMeta)})
+	writeTranscription(w, format, result, audioMeta)
 }
 
 func (s *server) handlePostProcess(w http.ResponseWriter, r *http.Request) {
@@ -163,92 +423,387 @@ func (s *server) handlePostProcess(w http.ResponseWriter, r *http.Request) {
 	defer func() { _ = r.Body.Close() }()
 
 	var req model.PostProcessRequest
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(&req); err != nil {
+	v := &validator{}
+	if err := v.decode(r.Body, &req); err != nil {
 		s.handleJSONDecodeError(w, r, err)
 		return
 	}
-	if err := ensureBodyFullyConsumed(decoder); err != nil {
-		s.handleJSONDecodeError(w, r, err)
+	v.required("transcript", req.Transcript)
+	timeout, err := s.requestTimeout(req.TimeoutMS)
+	v.check("timeout_ms", "out_of_range", ">= 1", err)
+	if v.failed() {
+		s.writeValidationError(w, r, v)
 		return
 	}
-	if strings.TrimSpace(req.Transcript) == "" {
-		s.writeError(w, r, http.StatusBadRequest, "invalid_request", "transcript is required", nil)
+	if err := s.checkModels(req.Model); err != nil {
+		s.writeMappedError(w, r, err)
 		return
 	}
+	r = withTimeout(r, timeout)
+	s.startProcessingDeadline(w, cmp.Or(timeout, s.cfg.PostProcessTimeout))
 
-	result, err := s.postProcess.Process(r.Context(), postprocess.Input{
+	input := postprocess.Input{
 		Transcript:         req.Transcript,
 		ContextSummary:     req.ContextSummary,
 		CustomVocabulary:   req.CustomVocabulary,
 		CustomSystemPrompt: req.CustomSystemPrompt,
 		Model:              req.Model,
+		Acronyms:           s.tenantAcronyms(r.Context(), req.ExpandAcronyms == nil || *req.ExpandAcronyms),
 		IncludeDebugPrompt: req.IncludeDebugPrompt,
-	})
+		CollapseSpellings:  s.cfg.PostProcessSpellings,
+	}
+	if req.CollapseSpellings != nil {
+		input.CollapseSpellings = *req.CollapseSpellings
+	}
+	input.SpellingsToVocabulary = req.SpellingsToVocabulary
+	if wantsEventStream(r) {
+		s.streamPostProcess(w, r, input)
+		return
+	}
+
+	result, err := s.postProcess.Process(r.Context(), input)
 	if err != nil {
 		s.writeMappedError(w, r, err)
 		return
 	}
 
+	var usage requestUsage
+	usage.addTokens(toModelTokenUsage(result.Usage))
+	s.setUsageHeaders(w, r, usage)
+	s.setDebugHeader(w, r, postProcessDebug(result.Params, cmp.Or(timeout, s.cfg.PostProcessTimeout)))
 	writeJSON(w, http.StatusOK, model.PostProcessResponse{
-		Transcript: result.Transcript,
-		Status:     "post-processing succeeded",
-		Usage:      toModelTokenUsage(result.Usage),
+		Transcript:   result.Transcript,
+		Status:       "post-processing succeeded",
+		Usage:        toModelTokenUsage(result.Usage),
+		Tier:         result.Tier,
+		SpelledWords: result.SpelledWords,
 	})
 }
 
 func (s *server) handlePipelineProcess(w http.ResponseWriter, r *http.Request) {
-	file, header, form, err := s.readMultipartAudio(w, r)
-	if err != nil {
-		s.handleMultipartReadError(w, r, err)
+	req, ok := s.readPipelineRequest(w, r)
+	if !ok {
 		return
 	}
-	defer cleanupMultipartForm(form)
-	defer func() { _ = file.Close() }()
+	defer req.close()
+	r = withTimeout(r, req.timeout)
+	s.startProcessingDeadline(w, req.budget)
 
-	includeDebug, err := parseOptionalBool(r.FormValue("include_debug"))
-	if err != nil {
-		s.writeError(w, r, http.StatusBadRequest, "invalid_request", "include_debug must be a boolean", nil)
+	if wantsEventStream(r) {
+		if req.input.EchoAudio {
+			s.writeError(w, r, http.StatusBadRequest, "invalid_request", errReturnAudioUnsupported, nil)
+			return
+		}
+		if req.format != responseFormatJSON {
+			s.writeError(w, r, http.StatusBadRequest, "invalid_request", "response_format "+req.format+" is not supported with event streaming", nil)
+			return
+		}
+		s.streamPipelineProcess(w, r, req)
+		return
+	}
+	if req.input.EchoAudio && req.format != responseFormatJSON {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", "return_audio cannot be combined with response_format "+req.format, nil)
 		return
 	}
 
-	result, err := s.pipeline.Process(r.Context(), pipeline.ProcessInput{
-		File:               file,
-		FileName:           header.Filename,
-		ContextSummary:     r.FormValue("context_summary"),
-		CustomVocabulary:   r.FormValue("custom_vocabulary"),
-		CustomSystemPrompt: r.FormValue("custom_system_prompt"),
-		TranscriptionModel: r.FormValue("transcription_model"),
-		PostProcessModel:   r.FormValue("post_process_model"),
-		IncludeDebug:       includeDebug,
-	})
+	result, err := s.pipeline.Process(r.Context(), req.input)
 	if err != nil {
 		s.writeMappedError(w, r, err)
 		return
 	}
-	if s.metrics != nil && result.PostProcessingStatus == "Post-processing failed, using raw transcript" {
-		s.metrics.IncPipelineFallback()
+	s.observePipeline(result)
+
+	resp := toPipelineResponse(result, req.audio)
+	s.setUsageHeaders(w, r, pipelineUsage(resp))
+	s.setDebugHeader(w, r, s.pipelineDebug(result, req))
+	if isSubtitleFormat(req.format) {
+		writePipelineSubtitles(w, req.format, result, req.audio)
+		return
+	}
+	if req.input.EchoAudio {
+		if err := writePipelineEchoResponse(w, resp, result.Audio); err != nil {
+			reqctx.Logger(r.Context()).Warn("audio echo response interrupted", "error", err)
+		}
+		return
+	}
+	writePipelineResponse(w, http.StatusOK, resp)
+}
+
+// pipelineRequest is a parsed /v1/pipeline/process upload. close releases the
+// multipart form and any extra audio parts once processing is done.
+type pipelineRequest struct {
+	input       pipeline.ProcessInput
+	audio       *model.AudioMetadata
+	budget      time.Duration
+	callbackURL string
+	closers     []func()
+	// timeout is the caller's timeout_ms override, 0 if none.
+	timeout time.Duration
+	// format is the response_format, json unless captions were asked for.
+	format string
+}
+
+func (p *pipelineRequest) close() {
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		p.closers[i]()
+	}
+}
+
+func (s *server) readPipelineRequest(w http.ResponseWriter, r *http.Request) (*pipelineRequest, bool) {
+	if isJSONRequest(r) {
+		return s.readPipelineURLRequest(w, r)
+	}
+	file, header, form, err := s.readMultipartAudio(w, r, pipelineFields)
+	if err != nil {
+		cleanupMultipartForm(form)
+		s.handleMultipartReadError(w, r, err)
+		return nil, false
+	}
+	req := &pipelineRequest{closers: []func(){
+		func() { cleanupMultipartForm(form) },
+		func() { _ = file.Close() },
+	}}
+	fail := func(message string) (*pipelineRequest, bool) {
+		req.close()
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", message, nil)
+		return nil, false
+	}
+
+	opts, err := s.parsePipelineOptions(r)
+	if err != nil {
+		req.close()
+		s.writeOptionsError(w, r, err)
+		return nil, false
+	}
+	req.timeout, err = s.formTimeout(r)
+	if err != nil {
+		return fail(err.Error())
+	}
+	req.format, err = parseResponseFormat(r.FormValue("response_format"), pipelineFormats)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	var parts []pipeline.AudioPart
+	largestPart := header.Size
+	if fileHeaders := form.File["file"]; len(fileHeaders) > 1 {
+		if opts.SplitChannels {
+			return fail("split_channels requires a single file")
+		}
+		if len(fileHeaders) > maxAudioParts {
+			return fail(fmt.Sprintf("at most %d audio parts are allowed", maxAudioParts))
+		}
+		var closeParts func()
+		parts, closeParts, err = s.openAudioParts(fileHeaders, opts.ChannelLabels)
+		var durationErr *audio.DurationError
+		if errors.As(err, &durationErr) {
+			req.close()
+			s.writeMappedError(w, r, err)
+			return nil, false
+		}
+		if err != nil {
+			return fail("invalid multipart form data")
+		}
+		req.closers = append(req.closers, closeParts)
+		for _, part := range parts {
+			largestPart = max(largestPart, part.Size)
+		}
+	}
+
+	req.budget = s.pipelineBudget(largestPart, req.timeout)
+	req.audio = probeUpload(file, header.Size)
+	// Parts were checked as they were opened.
+	if parts == nil {
+		if err := s.checkAudioDuration(req.audio); err != nil {
+			req.close()
+			s.writeMappedError(w, r, err)
+			return nil, false
+		}
+	}
+	req.input = opts
+	req.input.File = file
+	req.input.FileName = s.uploadFileName(header.Filename, header.Header.Get("Content-Type"), req.audio)
+	req.input.FileSize = header.Size
+	req.input.Parts = parts
+	req.input.Segments = isSubtitleFormat(req.format)
+	req.callbackURL = strings.TrimSpace(r.FormValue("callback_url"))
+	return req, true
+}
+
+// parsePipelineOptions reads the form fields shared by the pipeline endpoints;
+// the caller fills in the audio.
+func (s *server) parsePipelineOptions(r *http.Request) (pipeline.ProcessInput, error) {
+	includeDebug, err := parseOptionalBool(r.FormValue("include_debug"))
+	if err != nil {
+		return pipeline.ProcessInput{}, errors.New("include_debug must be a boolean")
+	}
+	splitChannels, err := parseOptionalBool(r.FormValue("split_channels"))
+	if err != nil {
+		return pipeline.ProcessInput{}, errors.New("split_channels must be a boolean")
+	}
+	returnAudio, err := parseOptionalBool(r.FormValue("return_audio"))
+	if err != nil {
+		return pipeline.ProcessInput{}, errors.New("return_audio must be a boolean")
+	}
+	expandAcronyms := true
+	if raw := strings.TrimSpace(r.FormValue("expand_acronyms")); raw != "" {
+		if expandAcronyms, err = strconv.ParseBool(raw); err != nil {
+			return pipeline.ProcessInput{}, errors.New("expand_acronyms must be a boolean")
+		}
+	}
+	collapseSpellings := s.cfg.PostProcessSpellings
+	if raw := strings.TrimSpace(r.FormValue("collapse_spellings")); raw != "" {
+		if collapseSpellings, err = strconv.ParseBool(raw); err != nil {
+			return pipeline.ProcessInput{}, errors.New("collapse_spellings must be a boolean")
+		}
+	}
+	spellingsToVocabulary, err := parseOptionalBool(r.FormValue("spellings_to_vocabulary"))
+	if err != nil {
+		return pipeline.ProcessInput{}, errors.New("spellings_to_vocabulary must be a boolean")
+	}
+	preprocess, err := parsePreprocessOptions(r)
+	if err != nil {
+		return pipeline.ProcessInput{}, err
+	}
+	fallbackPolicy := strings.TrimSpace(r.FormValue("fallback_policy"))
+	if err := pipeline.CheckFallbackPolicy(fallbackPolicy); err != nil {
+		return pipeline.ProcessInput{}, err
+	}
+	language := strings.TrimSpace(r.FormValue("language"))
+	if err := transcription.CheckLanguage(language); err != nil {
+		return pipeline.ProcessInput{}, err
+	}
+	words, err := parseTimestampGranularities(splitLabels(r.FormValue("timestamp_granularities")))
+	if err != nil {
+		return pipeline.ProcessInput{}, err
+	}
+	if err := s.checkModels(r.FormValue("transcription_model"), r.FormValue("post_process_model")); err != nil {
+		return pipeline.ProcessInput{}, err
+	}
+	in := pipeline.ProcessInput{
+		SplitChannels:       splitChannels,
+		ChannelLabels:       splitLabels(r.FormValue("speaker_labels")),
+		Preprocess:          preprocess,
+		ContextSummary:      r.FormValue("context_summary"),
+		CustomVocabulary:    r.FormValue("custom_vocabulary"),
+		CustomSystemPrompt:  r.FormValue("custom_system_prompt"),
+		TranscriptionModel:  r.FormValue("transcription_model"),
+		TranscriptionPrompt: r.FormValue("transcription_prompt"),
+		WordTimestamps:      words,
+		PostProcessModel:    r.FormValue("post_process_model"),
+		Language:            language,
+		Stages:              splitLabels(r.FormValue("stages")),
+		Acronyms:            s.tenantAcronyms(r.Context(), expandAcronyms),
+		EchoAudio:           returnAudio,
+		FallbackPolicy:      fallbackPolicy,
+		IncludeDebug:        includeDebug,
+	}
+	in.CollapseSpellings, in.SpellingsToVocabulary = collapseSpellings, spellingsToVocabulary
+	return in, nil
+}
+
+func (s *server) observePipeline(result pipeline.ProcessResult) {
+	observePipelineResult(s.metrics, result)
+	s.stats.observePipeline(result.PostProcessingOutcome == pipeline.OutcomeFallback)
+}
+
+func observePipelineResult(metrics MetricsObserver, result pipeline.ProcessResult) {
+	if metrics != nil && result.PostProcessingOutcome == pipeline.OutcomeFallback {
+		metrics.IncPipelineFallback()
 	}
+}
 
-	writeJSON(w, http.StatusOK, model.PipelineProcessResponse{
-		RawTranscript:        result.RawTranscript,
-		FinalTranscript:      result.FinalTranscript,
-		PostProcessingStatus: result.PostProcessingStatus,
-		PostProcessingUsage:  toModelTokenUsage(result.PostProcessingUsage),
+func toPipelineResponse(result pipeline.ProcessResult, audioMeta *model.AudioMetadata) model.PipelineProcessResponse {
+	return model.PipelineProcessResponse{
+		RawTranscript:         result.RawTranscript,
+		FinalTranscript:       result.FinalTranscript,
+		PostProcessingStatus:  result.PostProcessingStatus,
+		PostProcessingOutcome: result.PostProcessingOutcome,
+		PostProcessingUsage:   toModelTokenUsage(result.PostProcessingUsage),
+		PostProcessingTier:    result.PostProcessingTier,
+		SpelledWords:          result.SpelledWords,
+		Audio:                 audioMeta,
+		Preprocessing:         result.Preprocessing,
+		Language:              result.Language,
+		Warnings:              result.Warnings,
+		Words:                 toPipelineWords(result.Words),
+		Stages:                toModelStageResults(result.Analyses),
+		Cached:                result.Cached,
 		TimingsMS: model.PipelineTimings{
 			Transcription:  result.Timings.Transcription.Milliseconds(),
 			PostProcessing: result.Timings.PostProcessing.Milliseconds(),
+			Stages:         stageMillis(result.Timings.Stages),
+			Chunks:         chunkMillis(result.Timings.Chunks),
 			Total:          result.Timings.Total.Milliseconds(),
 		},
-	})
+	}
+}
+
+func toPipelineWords(words []pipeline.Word) []model.TranscriptionWord {
+	if len(words) == 0 {
+		return nil
+	}
+	out := make([]model.TranscriptionWord, len(words))
+	for i, w := range words {
+		out[i] = toModelWord(w.Word, w.Speaker)
+	}
+	return out
+}
+
+func toModelStageResults(analyses []pipeline.AnalysisResult) []model.PipelineStageResult {
+	if len(analyses) == 0 {
+		return nil
+	}
+	out := make([]model.PipelineStageResult, len(analyses))
+	for i, a := range analyses {
+		out[i] = model.PipelineStageResult{Stage: a.Stage, Output: a.Output, Usage: toModelTokenUsage(a.Usage), Error: a.Error}
+	}
+	return out
+}
+
+func stageMillis(stages map[string]time.Duration) map[string]int64 {
+	if len(stages) == 0 {
+		return nil
+	}
+	out := make(map[string]int64, len(stages))
+	for name, d := range stages {
+		out[name] = d.Milliseconds()
+	}
+	return out
+}
+
+func chunkMillis(chunks []pipeline.ChunkTiming) []model.PipelineChunkTiming {
+	if len(chunks) == 0 {
+		return nil
+	}
+	out := make([]model.PipelineChunkTiming, 0, len(chunks))
+	for _, c := range chunks {
+		out = append(out, model.PipelineChunkTiming{
+			Speaker:       c.Speaker,
+			Start:         c.Start.Milliseconds(),
+			End:           c.End.Milliseconds(),
+			Transcription: c.Duration.Milliseconds(),
+		})
+	}
+	return out
 }
 
-func (s *server) readMultipartAudio(w http.ResponseWriter, r *http.Request) (multipart.File, *multipart.FileHeader, *multipart.Form, error) {
+// readMultipartAudio parses the form, normalizing it to the fields the
+// endpoint reads, and opens the first file.
+func (s *server) readMultipartAudio(w http.ResponseWriter, r *http.Request, fields []string) (multipart.File, *multipart.FileHeader, *multipart.Form, error) {
 	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxUploadBytes)
-	if err := r.ParseMultipartForm(minInt64(s.cfg.MaxUploadBytes, 8<<20)); err != nil {
+	release, err := s.acquireParser(r.Context())
+	if err != nil {
 		return nil, nil, nil, err
 	}
+	err = r.ParseMultipartForm(minInt64(s.cfg.MaxUploadBytes, 8<<20))
+	release()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := s.normalizeForm(r, fields); err != nil {
+		return nil, nil, r.MultipartForm, err
+	}
 	file, header, err := r.FormFile("file")
 	if err != nil {
 		return nil, nil, r.MultipartForm, err
@@ -256,14 +811,165 @@ func (s *server) readMultipartAudio(w http.ResponseWriter, r *http.Request) (mul
 	return file, header, r.MultipartForm, nil
 }
 
+func (s *server) transcriptionTimeouts() transcription.TimeoutPolicy {
+	return transcription.TimeoutPolicy{
+		Base:  s.cfg.TranscriptionTimeout,
+		PerMB: s.cfg.TranscriptionTimeoutPerMB,
+		Max:   s.cfg.TranscriptionMaxTimeout,
+	}
+}
+
+// requestTimeout turns a timeout_ms override into the timeout for each stage
+// of the request, capped at MAX_REQUEST_TIMEOUT_MS. It returns 0 when ms is
+// nil, which keeps the configured timeouts; an explicit 0 is an error.
+func (s *server) requestTimeout(ms *int) (time.Duration, error) {
+	if ms == nil {
+		return 0, nil
+	}
+	if *ms <= 0 {
+		return 0, errors.New("timeout_ms must be a positive integer")
+	}
+	timeout := time.Duration(*ms) * time.Millisecond
+	if s.cfg.MaxRequestTimeout > 0 {
+		timeout = min(timeout, s.cfg.MaxRequestTimeout)
+	}
+	return timeout, nil
+}
+
+func (s *server) formTimeout(r *http.Request) (time.Duration, error) {
+	value := strings.TrimSpace(r.FormValue("timeout_ms"))
+	if value == "" {
+		return 0, nil
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("timeout_ms must be a positive integer")
+	}
+	return s.requestTimeout(&ms)
+}
+
+// withTimeout sets the override on the request context, so every stage of
+// the request, and of a job it submits, uses timeout instead of its
+// configured one.
+func withTimeout(r *http.Request, timeout time.Duration) *http.Request {
+	if timeout <= 0 {
+		return r
+	}
+	return r.WithContext(deadline.WithOverride(r.Context(), timeout))
+}
+
+// pipelineBudget is the time transcription and post-processing may take
+// together: each gets timeout when the caller set one.
+func (s *server) pipelineBudget(size int64, timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return 2 * timeout
+	}
+	return s.transcriptionTimeouts().For(size) + s.cfg.PostProcessTimeout
+}
+
+// startProcessingDeadline restarts the write deadline once the request body
+// has been read, so time spent uploading never eats into the processing budget.
+func (s *server) startProcessingDeadline(w http.ResponseWriter, budget time.Duration) {
+	if budget <= 0 {
+		return
+	}
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(budget + writeDeadlineGrace))
+}
+
+func (s *server) openAudioParts(headers []*multipart.FileHeader, labels []string) ([]pipeline.AudioPart, func(), error) {
+	parts := make([]pipeline.AudioPart, 0, len(headers))
+	closers := make([]io.Closer, 0, len(headers))
+	closeAll := func() {
+		for _, c := range closers {
+			_ = c.Close()
+		}
+	}
+	for i, fh := range headers {
+		f, err := fh.Open()
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		closers = append(closers, f)
+		meta := probeUpload(f, fh.Size)
+		if err := s.checkAudioDuration(meta); err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		name := s.uploadFileName(fh.Filename, fh.Header.Get("Content-Type"), meta)
+		part := pipeline.AudioPart{File: f, FileName: name, Size: fh.Size}
+		if i < len(labels) {
+			part.Label = labels[i]
+		}
+		parts = append(parts, part)
+	}
+	return parts, closeAll, nil
+}
+
+func parsePreprocessOptions(r *http.Request) (audio.PreprocessOptions, error) {
+	var opts audio.PreprocessOptions
+	var err error
+	if opts.TrimSilence, err = parseOptionalBool(r.FormValue("trim_silence")); err != nil {
+		return opts, errors.New("trim_silence must be a boolean")
+	}
+	if opts.Normalize, err = parseOptionalBool(r.FormValue("normalize")); err != nil {
+		return opts, errors.New("normalize must be a boolean")
+	}
+	if opts.Downmix, err = parseOptionalBool(r.FormValue("downmix")); err != nil {
+		return opts, errors.New("downmix must be a boolean")
+	}
+	if opts.Transcode, err = parseOptionalBool(r.FormValue("transcode")); err != nil {
+		return opts, errors.New("transcode must be a boolean")
+	}
+	if value := strings.TrimSpace(r.FormValue("resample_hz")); value != "" {
+		rate, err := strconv.Atoi(value)
+		if err != nil || !validSampleRate(rate) {
+			return opts, errSampleRate
+		}
+		opts.SampleRate = rate
+	}
+	return opts, nil
+}
+
+var errSampleRate = errors.New("resample_hz must be an integer between 8000 and 48000")
+
+func validSampleRate(rate int) bool {
+	return rate >= 8000 && rate <= 48000
+}
+
+func splitLabels(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	labels := strings.Split(value, ",")
+	for i := range labels {
+		labels[i] = strings.TrimSpace(labels[i])
+	}
+	return labels
+}
+
 func (s *server) handleMultipartReadError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errParsersBusy) {
+		s.shed(w, r, map[string]any{"max_multipart_parsers": s.cfg.MultipartMaxParsers})
+		return
+	}
 	var maxErr *http.MaxBytesError
 	if errors.As(err, &maxErr) {
 		s.writeError(w, r, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("request exceeds %d bytes", s.cfg.MaxUploadBytes), nil)
 		return
 	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		s.writeError(w, r, http.StatusRequestTimeout, "upload_timeout", "upload was not received in time", nil)
+		return
+	}
+	var fieldErr *formFieldError
+	if errors.As(err, &fieldErr) {
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", fieldErr.message, fieldErr.details)
+		return
+	}
 	if strings.Contains(strings.ToLower(err.Error()), "no such file") || strings.Contains(strings.ToLower(err.Error()), "missing") {
-		s.writeError(w, r, http.StatusBadRequest, "invalid_request", "multipart field 'file' is required", nil)
+		s.writeError(w, r, http.StatusBadRequest, "invalid_request", "multipart field 'file' (or 'audio') is required", nil)
 		return
 	}
 	s.writeError(w, r, http.StatusBadRequest, "invalid_request", "invalid multipart form data", nil)
@@ -279,13 +985,77 @@ func (s *server) handleJSONDecodeError(w http.ResponseWriter, r *http.Request, e
 }
 
 func (s *server) writeMappedError(w http.ResponseWriter, r *http.Request, err error) {
+	status, apiErr := mapError(err)
+	s.writeError(w, r, status, apiErr.Code, apiErr.Message, apiErr.Details)
+}
+
+func mapError(err error) (int, model.APIError) {
 	status := http.StatusInternalServerError
 	code := "internal_error"
 	message := "request failed"
 	details := detailsForError(err)
 
-	var upstreamErr *openai.Error
+	var upstreamErr *upstream.Error
+	var languageErr *transcription.LanguageError
+	var postErr *pipeline.PostProcessingError
+	var modelErr *upstream.ModelError
+	var durationErr *audio.DurationError
 	switch {
+	case errors.As(err, &modelErr):
+		status = http.StatusBadRequest
+		code = "invalid_model"
+		message = modelErr.Error()
+		details = map[string]any{"model": modelErr.Model}
+	case errors.As(err, &durationErr):
+		status = http.StatusRequestEntityTooLarge
+		code = "audio_too_long"
+		message = durationErr.Error()
+		details = map[string]any{"duration_ms": durationErr.Duration.Milliseconds(), "max_duration_ms": durationErr.Limit.Milliseconds()}
+	case errors.As(err, &languageErr):
+		status = http.StatusUnprocessableEntity
+		code = "language_not_allowed"
+		message = languageErr.Error()
+		details = map[string]any{"language": languageErr.Language, "detected": languageErr.Detected}
+	case errors.Is(err, upstream.ErrTranslationUnsupported):
+		status = http.StatusNotImplemented
+		code = "translation_unsupported"
+		message = err.Error()
+	case errors.Is(err, postprocess.ErrNoChapters):
+		status = http.StatusBadGateway
+		code = "chapters_failed"
+		message = "the model's reply held no usable chapters"
+	case errors.Is(err, pipeline.ErrUnknownStage):
+		status = http.StatusBadRequest
+		code = "unknown_stage"
+		message = err.Error()
+	case errors.Is(err, auth.ErrInvalidUpstreamBaseURL):
+		status = http.StatusBadRequest
+		code = "invalid_upstream_base_url"
+		message = err.Error()
+	case errors.Is(err, auth.ErrUpstreamBaseURLNotAllowed):
+		status = http.StatusForbidden
+		code = "upstream_base_url_forbidden"
+		message = err.Error()
+	case errors.Is(err, upstream.ErrServerKeyOnly):
+		status = http.StatusForbidden
+		code = "server_key_required"
+		message = err.Error()
+	case errors.Is(err, audio.ErrTranscodeUnavailable):
+		status = http.StatusBadRequest
+		code = "transcode_unavailable"
+		message = err.Error()
+	case errors.Is(err, audio.ErrUnsupportedFormat):
+		status = http.StatusUnsupportedMediaType
+		code = "unsupported_audio_format"
+		message = "audio format is not supported for this operation"
+	case errors.Is(err, audio.ErrInvalidAudio):
+		status = http.StatusBadRequest
+		code = "invalid_audio"
+		message = "audio could not be processed"
+	case errors.As(err, &postErr):
+		status = http.StatusBadGateway
+		code = "post_processing_failed"
+		message = "post-processing failed and the fallback policy is error"
 	case errors.As(err, &upstreamErr):
 		status = http.StatusBadGateway
 		code = "upstream_request_failed"
@@ -300,16 +1070,16 @@ func (s *server) writeMappedError(w http.ResponseWriter, r *http.Request, err er
 		message = "request canceled"
 	}
 
-	s.writeError(w, r, status, code, message, details)
+	return status, model.APIError{Code: code, Message: message, Details: details}
 }
 
 func (s *server) writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]any) {
-	if rid := requestIDFromContext(r.Context()); rid != "" {
+	if rid := reqctx.RequestID(r.Context()); rid != "" {
 		w.Header().Set(requestIDHeader, rid)
 	}
 	writeJSON(w, status, model.ErrorResponse{
 		Error:     model.APIError{Code: code, Message: message, Details: details},
-		RequestID: requestIDFromContext(r.Context()),
+		RequestID: reqctx.RequestID(r.Context()),
 	})
 }
 
@@ -320,7 +1090,7 @@ func (s *server) requestIDMiddleware(next http.Handler) http.Handler {
 			requestID = newRequestID()
 		}
 		w.Header().Set(requestIDHeader, requestID)
-		ctx := context.WithValue(r.Context(), requestIDContext, requestID)
+		ctx := reqctx.WithRequestID(r.Context(), requestID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -349,7 +1119,7 @@ func (s *server) loggingMiddleware(next http.Handler) http.Handler {
 		}
 
 		s.logger.Info("http_request",
-			"request_id", requestIDFromContext(r.Context()),
+			"request_id", reqctx.RequestID(r.Context()),
 			"method", r.Method,
 			"route", route,
 			"path", r.URL.Path,
@@ -364,7 +1134,7 @@ func (s *server) recoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rec := recover(); rec != nil {
-				s.logger.Error("panic recovered", "request_id", requestIDFromContext(r.Context()), "panic", rec)
+				s.logger.Error("panic recovered", "request_id", reqctx.RequestID(r.Context()), "panic", rec)
 				s.writeError(w, r, http.StatusInternalServerError, "internal_error", "internal server error", nil)
 			}
 		}()
@@ -372,8 +1142,36 @@ func (s *server) recoverMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// requestLoggerMiddleware attaches a logger carrying the request ID, tenant
+// and route, so services and upstream clients log with the same fields.
+func (s *server) requestLoggerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if rctx := chi.NewRouteContext(); s.routes.Match(rctx, r.Method, r.URL.Path) {
+			route = rctx.RoutePattern()
+		}
+		attrs := []any{"request_id", reqctx.RequestID(r.Context()), "route", route}
+		if tenant := reqctx.Tenant(r.Context()); tenant != "" {
+			attrs = append(attrs, "tenant", tenant)
+		}
+		ctx := reqctx.WithLogger(r.Context(), s.logger.With(attrs...))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Headers with which an EchoFlow token allowed to do so sends the request's
+// upstream calls to its own endpoint, with its own key.
+const (
+	upstreamBaseURLHeader = "X-Upstream-Base-Url"
+	upstreamAPIKeyHeader  = "X-Upstream-Api-Key"
+)
+
 func (s *server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
 		token, hasHeader, ok := extractBearerToken(r.Header.Get("Authorization"))
 		if hasHeader && !ok {
 			s.writeError(w, r, http.StatusUnauthorized, "unauthorized", "Authorization must be Bearer <groq_cloud_token>", nil)
@@ -383,10 +1181,37 @@ func (s *server) authMiddleware(next http.Handler) http.Handler {
 			s.writeError(w, r, http.StatusUnauthorized, "unauthorized", "missing Groq Cloud bearer token", nil)
 			return
 		}
-		if token != "" {
-			r = r.WithContext(openai.WithRequestAPIKey(r.Context(), token))
+		id := s.tokens.Resolve(token)
+		if s.cfg.IsPremiumToken(token) {
+			id.Tier = transcription.TierPremium
 		}
-		next.ServeHTTP(w, r)
+		ctx := auth.WithIdentity(r.Context(), id)
+		ctx = reqctx.WithTenant(ctx, id.Tenant)
+		if id.Tier == transcription.TierPremium {
+			ctx = reqctx.WithPriority(ctx, transcription.TierPremium)
+		}
+		if len(id.Languages.Allowed) > 0 {
+			ctx = transcription.WithLanguagePolicy(ctx, id.Languages)
+		}
+		if raw := r.Header.Get(upstreamBaseURLHeader); raw != "" && !isPublicPath(r.URL.Path) {
+			baseURL, err := id.CheckUpstreamBaseURL(raw)
+			if err != nil {
+				s.writeMappedError(w, r, err)
+				return
+			}
+			ctx = upstream.WithRequestBaseURL(ctx, baseURL)
+			ctx = upstream.WithRequestAPIKey(ctx, r.Header.Get(upstreamAPIKeyHeader))
+			ctx = reqctx.WithAPIKeySource(ctx, reqctx.KeySourceCaller)
+		} else if id.Kind == auth.KindBYOT {
+			ctx = upstream.WithRequestAPIKey(ctx, token)
+			ctx = reqctx.WithAPIKeySource(ctx, reqctx.KeySourceCaller)
+		} else {
+			ctx = reqctx.WithAPIKeySource(ctx, reqctx.KeySourceServer)
+			if s.keys != nil {
+				ctx = s.keys.PinAPIKey(ctx)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -400,10 +1225,19 @@ func isPublicPath(path string) bool {
 }
 
 func writeJSON(w http.ResponseWriter, status int, value any) {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	if err := json.NewEncoder(buf).Encode(value); err != nil {
+		status = http.StatusInternalServerError
+		buf.Reset()
+		buf.WriteString(`{"error":{"code":"internal_error","message":"failed to encode response"}}` + "\n")
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(value)
+	_, _ = w.Write(buf.Bytes())
 }
 
 func ensureBodyFullyConsumed(decoder *json.Decoder) error {
@@ -431,11 +1265,6 @@ func cleanupMultipartForm(form *multipart.Form) {
 	}
 }
 
-func requestIDFromContext(ctx context.Context) string {
-	value, _ := ctx.Value(requestIDContext).(string)
-	return value
-}
-
 func extractBearerToken(header string) (token string, hasHeader bool, ok bool) {
 	header = strings.TrimSpace(header)
 	if header == "" {
@@ -460,6 +1289,44 @@ func newRequestID() string {
 	return hex.EncodeToString(buf)
 }
 
+// uploadFileName gives an upload an extension the upstream can pick a
+// decoder from; see audio.FileName.
+func (s *server) uploadFileName(name, contentType string, meta *model.AudioMetadata) string {
+	container := ""
+	if meta != nil {
+		container = meta.Container
+	}
+	return audio.FileName(name, contentType, container, s.cfg.UploadDefaultFileName)
+}
+
+// checkAudioDuration records a probed duration and applies MAX_AUDIO_SECONDS.
+// Audio whose headers give no duration is let through.
+func (s *server) checkAudioDuration(meta *model.AudioMetadata) error {
+	if meta == nil || meta.DurationMS <= 0 {
+		return nil
+	}
+	duration := time.Duration(meta.DurationMS) * time.Millisecond
+	if s.metrics != nil {
+		s.metrics.ObserveAudioDuration(duration)
+	}
+	return audio.CheckDuration(duration, s.cfg.MaxAudioDuration)
+}
+
+// probeUpload reads container headers for the response; unknown formats are
+// still forwarded upstream, they just get no metadata.
+func probeUpload(file io.ReaderAt, size int64) *model.AudioMetadata {
+	meta, err := audio.Probe(file, size)
+	if err != nil {
+		return nil
+	}
+	return &model.AudioMetadata{
+		Container:  meta.Container,
+		DurationMS: meta.Duration.Milliseconds(),
+		SampleRate: meta.SampleRate,
+		Channels:   meta.Channels,
+	}
+}
+
 func toModelTokenUsage(u *postprocess.TokenUsage) *model.TokenUsage {
 	if u == nil {
 		return nil
@@ -476,13 +1343,54 @@ func detailsForError(err error) map[string]any {
 		return nil
 	}
 	details := map[string]any{"error": err.Error()}
-	var upstreamErr *openai.Error
+	var upstreamErr *upstream.Error
 	if errors.As(err, &upstreamErr) {
 		details["upstream_status"] = upstreamErr.StatusCode
 		if upstreamErr.Body != "" {
 			details["upstream_body"] = upstreamErr.Body
 		}
 	}
+	var deadlineErr *deadline.Error
+	if errors.As(err, &deadlineErr) {
+		details["deadline"] = deadlineDetails(deadlineErr)
+	}
+	var attemptsErr *upstream.AttemptsError
+	if errors.As(err, &attemptsErr) {
+		details["upstream_attempts"] = attemptDetails(attemptsErr.Attempts)
+	}
+	return details
+}
+
+// attemptDetails lists each try of a retried upstream call, oldest first.
+func attemptDetails(attempts []upstream.Attempt) []map[string]any {
+	out := make([]map[string]any, 0, len(attempts))
+	for _, attempt := range attempts {
+		entry := map[string]any{
+			"attempt":     attempt.Number,
+			"status":      attempt.Status,
+			"duration_ms": attempt.Duration.Milliseconds(),
+			"bytes":       attempt.Bytes,
+		}
+		if attempt.Error != "" {
+			entry["error"] = attempt.Error
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// deadlineDetails names the stage that ran out of time and whether its own
+// timeout or the caller's deadline fired.
+func deadlineDetails(err *deadline.Error) map[string]any {
+	details := map[string]any{
+		"stage":      err.Stage,
+		"source":     err.Source,
+		"budget_ms":  err.Budget.Milliseconds(),
+		"elapsed_ms": err.Elapsed.Milliseconds(),
+	}
+	if err.Caller != nil {
+		details["caller"] = deadlineDetails(err.Caller)
+	}
 	return details
 }
 
@@ -3,45 +3,93 @@ package httpapi
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"echoflow/internal/audio"
+	"echoflow/internal/auth"
 	"echoflow/internal/config"
+	"echoflow/internal/deadline"
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
 	"echoflow/internal/pipeline"
 	"echoflow/internal/postprocess"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
+	"echoflow/internal/webhook"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type stubTranscription struct {
 	text     string
+	segments []transcription.Segment
+	words    []transcription.Word
 	err      error
 	fileBody string
+	fileName string
 	model    string
+	timeout  time.Duration
+	// includeSegments records whether segments were requested.
+	includeSegments bool
+	translate       bool
 }
 
-func (s *stubTranscription) Transcribe(_ context.Context, file io.Reader, _ string, model string) (string, error) {
-	body, _ := io.ReadAll(file)
+func (s *stubTranscription) Transcribe(ctx context.Context, in transcription.Input) (transcription.Result, error) {
+	body, _ := io.ReadAll(in.File)
 	s.fileBody = string(body)
-	s.model = model
-	return s.text, s.err
+	s.fileName = in.FileName
+	s.model = in.Model
+	s.timeout = deadline.Override(ctx)
+	s.includeSegments = in.IncludeSegments
+	s.translate = in.Translate
+	res := transcription.Result{Text: s.text, Segments: s.segments}
+	if in.IncludeWords {
+		res.Words = s.words
+	}
+	return res, s.err
 }
 
 type stubPostProcess struct {
 	result postprocess.Result
 	err    error
 	input  postprocess.Input
+	ctx    context.Context
 }
 
-func (s *stubPostProcess) Process(_ context.Context, in postprocess.Input) (postprocess.Result, error) {
+func (s *stubPostProcess) Process(ctx context.Context, in postprocess.Input) (postprocess.Result, error) {
 	s.input = in
+	s.ctx = ctx
 	return s.result, s.err
 }
 
+func (s *stubPostProcess) ProcessStream(_ context.Context, in postprocess.Input, onDelta func(string) error) (postprocess.Result, error) {
+	s.input = in
+	if s.err != nil {
+		return postprocess.Result{}, s.err
+	}
+	for _, word := range strings.SplitAfter(s.result.Transcript, " ") {
+		if err := onDelta(word); err != nil {
+			return postprocess.Result{}, err
+		}
+	}
+	return s.result, nil
+}
+
 type stubPipeline struct {
 	result   pipeline.ProcessResult
 	err      error
@@ -58,7 +106,7 @@ func (s *stubPipeline) Process(_ context.Context, in pipeline.ProcessInput) (pip
 
 type stubUpstream struct{ err error }
 
-func (s stubUpstream) CheckModels(context.Context) error { return s.err }
+func (s stubUpstream) CheckHealth(context.Context) error { return s.err }
 
 func newTestHandler(t *testing.T, deps Dependencies) http.Handler {
 	t.Helper()
@@ -165,11 +213,221 @@ func TestTranscriptionsHandlerMultipart(t *testing.T) {
 	}
 }
 
+func TestTranscriptionsInfersExtensionOfBrowserUploads(t *testing.T) {
+	tr := &stubTranscription{text: "ok"}
+	h := newTestHandler(t, Dependencies{
+		Transcription: tr,
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="blob"`)
+	header.Set("Content-Type", "audio/webm;codecs=opus")
+	part, _ := mw.CreatePart(header)
+	_, _ = part.Write([]byte("audio-bytes"))
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || tr.fileName != "audio.webm" {
+		t.Fatalf("status %d, file name %q", w.Code, tr.fileName)
+	}
+}
+
+func TestTranscriptionsVerboseJSONReturnsSegments(t *testing.T) {
+	confidence := 0.82
+	tr := &stubTranscription{text: "hello there", segments: []transcription.Segment{
+		{Start: 0, End: 1500 * time.Millisecond, Text: "hello", Confidence: &confidence},
+		{Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "there"},
+	}}
+	h := newTestHandler(t, Dependencies{
+		Transcription: tr,
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	transcribe := func(format string, extra ...string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("response_format", format)
+		for i := 0; i+1 < len(extra); i += 2 {
+			_ = mw.WriteField(extra[i], extra[i+1])
+		}
+		part, _ := mw.CreateFormFile("file", "sample.wav")
+		_, _ = part.Write([]byte("audio-bytes"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := transcribe("verbose_json")
+	var resp model.TranscriptionVerboseResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || !tr.includeSegments {
+		t.Fatalf("status %d, segments requested %v, body %s", w.Code, tr.includeSegments, w.Body.String())
+	}
+	if resp.Text != "hello there" || len(resp.Segments) != 2 || resp.Segments[0].End != 1.5 || *resp.Segments[0].Confidence != 0.82 || resp.Segments[1].Confidence != nil {
+		t.Fatalf("resp = %+v", resp)
+	}
+
+	if w := transcribe("json"); w.Code != http.StatusOK || tr.includeSegments || strings.Contains(w.Body.String(), "segments") {
+		t.Fatalf("json: status %d, segments requested %v, body %s", w.Code, tr.includeSegments, w.Body.String())
+	}
+	if w := transcribe("srt-ish"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format: status %d", w.Code)
+	}
+	w = transcribe("srt")
+	if w.Code != http.StatusOK || !tr.includeSegments || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/x-subrip") ||
+		!strings.HasPrefix(w.Body.String(), "1\n00:00:00,000 --> 00:00:01,500\nhello\n\n2\n") {
+		t.Fatalf("srt: status %d, segments requested %v, body %q", w.Code, tr.includeSegments, w.Body.String())
+	}
+
+	tr.words = []transcription.Word{{Start: 250 * time.Millisecond, End: time.Second, Text: "hello"}}
+	w = transcribe("verbose_json", "timestamp_granularities[]", "segment", "timestamp_granularities[]", "word")
+	resp = model.TranscriptionVerboseResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Words) != 1 || resp.Words[0].Start != 0.25 || resp.Words[0].Word != "hello" || len(resp.Segments) != 2 {
+		t.Fatalf("word granularity: status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := transcribe("json", "timestamp_granularities", "word"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "verbose_json") {
+		t.Fatalf("words without verbose_json: status %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPipelineVTTReflowsFinalTranscript(t *testing.T) {
+	segment := func(start, end time.Duration, speaker, text string) pipeline.Segment {
+		return pipeline.Segment{Segment: transcription.Segment{Start: start, End: end, Text: text}, Speaker: speaker}
+	}
+	pipe := &stubPipeline{result: pipeline.ProcessResult{
+		RawTranscript:   "Caller: um hi there\nAgent: uh hello",
+		FinalTranscript: "Caller: Hi there.\nAgent: Hello.",
+		Segments: []pipeline.Segment{
+			segment(0, 2*time.Second, "Caller", "um hi there"),
+			segment(2*time.Second, 3*time.Second, "Agent", "uh hello"),
+		},
+	}}
+	file := formField{name: "file", value: "audio", file: true}
+	w := postPipelineForm(t, false, pipe, file, formField{name: "response_format", value: "vtt"})
+	want := "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\n<v Caller>Hi there.\n\n00:00:02.000 --> 00:00:03.000\n<v Agent>Hello.\n\n"
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/vtt; charset=utf-8" || w.Body.String() != want || !pipe.input.Segments {
+		t.Fatalf("status %d, segments requested %v, body %q", w.Code, pipe.input.Segments, w.Body.String())
+	}
+
+	if w := postPipelineForm(t, false, &stubPipeline{}, file, formField{name: "response_format", value: "verbose_json"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("verbose_json on the pipeline: status %d", w.Code)
+	}
+}
+
+func TestTranslationsTranslateTheUpload(t *testing.T) {
+	tr := &stubTranscription{text: "good morning"}
+	h := newTestHandler(t, Dependencies{
+		Transcription: tr,
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	translate := func(fields ...string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for i := 0; i+1 < len(fields); i += 2 {
+			_ = mw.WriteField(fields[i], fields[i+1])
+		}
+		part, _ := mw.CreateFormFile("file", "note.ogg")
+		_, _ = part.Write([]byte("audio-bytes"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/translations", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := translate("model", "whisper-large-v3")
+	if w.Code != http.StatusOK || !tr.translate || tr.model != "whisper-large-v3" || tr.fileBody != "audio-bytes" || !strings.Contains(w.Body.String(), "good morning") {
+		t.Fatalf("status %d, translate %v, model %q, body %s", w.Code, tr.translate, tr.model, w.Body.String())
+	}
+
+	tr.err = upstream.ErrTranslationUnsupported
+	if w := translate(); w.Code != http.StatusNotImplemented || !strings.Contains(w.Body.String(), "translation_unsupported") {
+		t.Fatalf("unsupported upstream: status %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTranscriptionsTimeoutOverrideIsCapped(t *testing.T) {
+	tr := &stubTranscription{text: "hello"}
+	h := NewServer(config.Config{MaxUploadBytes: 1 << 20, MaxRequestTimeout: time.Minute}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: tr,
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	transcribe := func(timeoutMS string) int {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("timeout_ms", timeoutMS)
+		part, _ := mw.CreateFormFile("file", "sample.wav")
+		_, _ = part.Write([]byte("audio-bytes"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+		req.Header.Set("Authorization", "Bearer gsk_caller")
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, tc := range []struct {
+		timeoutMS string
+		want      time.Duration
+	}{
+		{"1500", 1500 * time.Millisecond},
+		{"600000", time.Minute},
+	} {
+		if code := transcribe(tc.timeoutMS); code != http.StatusOK || tr.timeout != tc.want {
+			t.Fatalf("timeout_ms=%s: status %d, stage timeout %v, want %v", tc.timeoutMS, code, tr.timeout, tc.want)
+		}
+	}
+	if code := transcribe("soon"); code != http.StatusBadRequest {
+		t.Fatalf("timeout_ms=soon: status %d, want 400", code)
+	}
+}
+
+func TestPostProcessRejectsExplicitZeroTimeout(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	for body, want := range map[string]int{
+		`{"transcript":"hi"}`:                 http.StatusOK,
+		`{"transcript":"hi","timeout_ms":0}`:  http.StatusBadRequest,
+		`{"transcript":"hi","timeout_ms":-5}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer gsk_caller")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != want || (want == http.StatusBadRequest && !strings.Contains(w.Body.String(), `"timeout_ms"`)) {
+			t.Fatalf("%s: status %d %s, want %d", body, w.Code, w.Body.String(), want)
+		}
+	}
+}
+
 func TestPipelineHandlerReturnsUsageAndNoPrompt(t *testing.T) {
 	pipe := &stubPipeline{result: pipeline.ProcessResult{
 		RawTranscript:        "raw",
 		FinalTranscript:      "final",
-		PostProcessingStatus: "Post-processing succeeded",
+		PostProcessingStatus: pipeline.PostProcessingSucceeded,
 		PostProcessingUsage: &postprocess.TokenUsage{
 			PromptTokens:     100,
 			CompletionTokens: 20,
@@ -217,6 +475,111 @@ func TestPipelineHandlerReturnsUsageAndNoPrompt(t *testing.T) {
 	}
 }
 
+func TestPipelineHandlerAcceptsMultipleLabeledParts(t *testing.T) {
+	pipe := &stubPipeline{result: pipeline.ProcessResult{FinalTranscript: "final"}}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      pipe,
+		Upstream:      stubUpstream{},
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("speaker_labels", "Caller, Agent")
+	for _, name := range []string{"caller.wav", "agent.wav"} {
+		part, _ := mw.CreateFormFile("file", name)
+		_, _ = part.Write([]byte(name))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+	if len(pipe.input.Parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(pipe.input.Parts))
+	}
+	if pipe.input.Parts[0].Label != "Caller" || pipe.input.Parts[1].Label != "Agent" {
+		t.Fatalf("unexpected labels: %+v", pipe.input.Parts)
+	}
+	if pipe.input.Parts[1].FileName != "agent.wav" {
+		t.Fatalf("unexpected file name: %q", pipe.input.Parts[1].FileName)
+	}
+}
+
+func TestTranscriptionsHandlerReturnsAudioMetadata(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{text: "hello"},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+
+	wav := &audio.WAV{Format: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16, Data: make([]byte, 16000*2)}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "note.wav")
+	_, _ = part.Write(wav.Encode())
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"audio":{"container":"wav","duration_ms":1000,"sample_rate":16000,"channels":1}`) {
+		t.Fatalf("expected audio metadata in body: %s", w.Body.String())
+	}
+}
+
+func TestAudioOverMaxDurationIsRejected(t *testing.T) {
+	transcriber := &stubTranscription{text: "hello"}
+	h := NewServer(config.Config{
+		MaxUploadBytes:   1024 * 1024,
+		MaxAudioDuration: time.Second,
+		UpstreamAPIKey:   "x",
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: transcriber,
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+
+	post := func(seconds int) *httptest.ResponseRecorder {
+		wav := &audio.WAV{Format: 1, Channels: 1, SampleRate: 8000, BitsPerSample: 16, Data: make([]byte, seconds*8000*2)}
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("file", "note.wav")
+		_, _ = part.Write(wav.Encode())
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(2)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), `"code":"audio_too_long"`) ||
+		!strings.Contains(w.Body.String(), `"max_duration_ms":1000`) {
+		t.Fatalf("status = %d %s", w.Code, w.Body.String())
+	}
+	if transcriber.fileBody != "" {
+		t.Fatal("audio over the limit was sent upstream")
+	}
+	if w := post(1); w.Code != http.StatusOK {
+		t.Fatalf("audio at the limit = %d %s", w.Code, w.Body.String())
+	}
+}
+
 func TestBYOTRequiredWhenNoServerAPIKey(t *testing.T) {
 	h := NewServer(config.Config{
 		MaxUploadBytes:  1024 * 1024,
@@ -283,3 +646,730 @@ func TestReadyzSkipsUpstreamCheckWithoutAnyToken(t *testing.T) {
 		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
 	}
 }
+
+type stubUpstreamStatus struct {
+	checked bool
+	err     error
+}
+
+func (s stubUpstreamStatus) LastCheck() (bool, error) { return s.checked, s.err }
+
+func TestReadyzUsesBackgroundUpstreamStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status stubUpstreamStatus
+		live   error
+		want   int
+	}{
+		{name: "cached failure", status: stubUpstreamStatus{checked: true, err: io.EOF}, want: http.StatusServiceUnavailable},
+		{name: "cached success skips live check", status: stubUpstreamStatus{checked: true}, live: io.EOF, want: http.StatusOK},
+		{name: "no probe yet checks live", live: io.EOF, want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, Dependencies{
+				Transcription:  &stubTranscription{},
+				PostProcess:    &stubPostProcess{},
+				Pipeline:       &stubPipeline{},
+				Upstream:       stubUpstream{err: tt.live},
+				UpstreamStatus: tt.status,
+			})
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d body=%s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// hangingStore never answers a ping before the caller gives up.
+type hangingStore struct {
+	mapStore
+}
+
+func (s *hangingStore) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestReadyzFailsWhenJobStoreDoesNotAnswerInTime(t *testing.T) {
+	h := NewServer(config.Config{
+		MaxUploadBytes:    1024 * 1024,
+		ReadyStoreTimeout: 20 * time.Millisecond,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Jobs:          jobs.NewManager(jobs.WithStore(&hangingStore{})),
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "job store") {
+		t.Fatalf("readyz = %d %s, want 503 naming the job store", w.Code, w.Body.String())
+	}
+}
+
+type timeoutReader struct{}
+
+func (timeoutReader) Read([]byte) (int, error) { return 0, timeoutError{} }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestPipelineHandlerMapsSlowUploadToRequestTimeout(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", timeoutReader{})
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestTimeout {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"upload_timeout"`) {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestCreateJobRunsPipelineInBackground(t *testing.T) {
+	pipe := &stubPipeline{result: pipeline.ProcessResult{RawTranscript: "raw", FinalTranscript: "final"}}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      pipe,
+		Upstream:      stubUpstream{},
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+	var created model.JobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if created.ID == "" || w.Header().Get("Location") != "/v1/jobs/"+created.ID {
+		t.Fatalf("unexpected job response: %+v location=%q", created, w.Header().Get("Location"))
+	}
+
+	var job model.JobResponse
+	for i := 0; i < 100; i++ {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+created.ID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &job)
+		if job.Status == "succeeded" || job.Status == "failed" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != "succeeded" || job.Progress != 100 || job.Result == nil || job.Result.FinalTranscript != "final" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if w.Header().Get("Last-Modified") == "" {
+		t.Fatal("finished job has no Last-Modified")
+	}
+	req = httptest.NewRequest(http.MethodGet, "/v1/jobs/"+created.ID, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("conditional GET of a finished job: status %d", w.Code)
+	}
+	if pipe.fileBody != "audio-payload" {
+		t.Fatalf("expected spooled upload to reach the pipeline, got %q", pipe.fileBody)
+	}
+	if pipe.input.OnProgress == nil {
+		t.Fatal("expected progress callback to be wired")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+created.ID+"/events", nil))
+	if !strings.Contains(w.Body.String(), "event: progress\n") || !strings.Contains(w.Body.String(), "event: result\n") {
+		t.Fatalf("unexpected event stream: %s", w.Body.String())
+	}
+}
+
+func TestJobsAreVisibleOnlyToTheirTenant(t *testing.T) {
+	var entries []string
+	for _, tenant := range []string{"acme", "globex"} {
+		sum := sha256.Sum256([]byte("ef_" + tenant))
+		entries = append(entries, `{"name":"`+tenant+`","sha256":"`+hex.EncodeToString(sum[:])+`","tenant":"`+tenant+`","scopes":["jobs"]}`)
+	}
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(path, []byte(`{"tokens":[`+strings.Join(entries, ",")+`]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := auth.LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Tokens:        registry,
+	})
+	do := func(method, path, token string, body io.Reader, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.Close()
+	w := do(http.MethodPost, "/v1/jobs", "ef_acme", &body, mw.FormDataContentType())
+	var created model.JobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("create = %d %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/v1/jobs/" + created.ID, "/v1/jobs/" + created.ID + "/events"} {
+		if w := do(http.MethodGet, path, "ef_globex", nil, ""); w.Code != http.StatusNotFound {
+			t.Fatalf("GET %s as another tenant = %d, want 404", path, w.Code)
+		}
+	}
+	if w := do(http.MethodGet, "/v1/jobs/"+created.ID, "ef_acme", nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("GET as the owner = %d %s", w.Code, w.Body.String())
+	}
+}
+
+type queuedItem struct {
+	key     jobs.Key
+	payload []byte
+}
+
+type chanQueue struct {
+	items chan queuedItem
+}
+
+func (q *chanQueue) Enqueue(_ context.Context, key jobs.Key, payload []byte) error {
+	q.items <- queuedItem{key: key, payload: payload}
+	return nil
+}
+
+func (q *chanQueue) Dequeue(ctx context.Context) (jobs.Key, []byte, error) {
+	select {
+	case item := <-q.items:
+		return item.key, item.payload, nil
+	case <-ctx.Done():
+		return jobs.Key{}, nil, ctx.Err()
+	}
+}
+
+type mapStore struct {
+	mu      sync.Mutex
+	records map[string]jobs.Record
+}
+
+func (s *mapStore) Save(_ context.Context, rec jobs.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.ID] = rec
+	return nil
+}
+
+func (s *mapStore) Load(_ context.Context, key jobs.Key) (jobs.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[key.ID]
+	if !ok || rec.Tenant != key.Tenant {
+		return jobs.Record{}, false, nil
+	}
+	return rec, true, nil
+}
+
+func TestCreateJobEnqueuesForAnotherReplica(t *testing.T) {
+	queue := &chanQueue{items: make(chan queuedItem, 1)}
+	store := &mapStore{records: map[string]jobs.Record{}}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Jobs:          jobs.NewManager(jobs.WithStore(store), jobs.WithQueue(queue)),
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.WriteField("context_summary", "standup")
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+	var created model.JobResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Status != "queued" {
+		t.Fatalf("expected queued job, got %+v", created)
+	}
+
+	// A worker replica shares the queue and store but not the handler.
+	pipe := &stubPipeline{result: pipeline.ProcessResult{FinalTranscript: "final"}}
+	worker := jobs.NewManager(jobs.WithStore(store), jobs.WithQueue(queue), jobs.WithWorkers(1, 1))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Work(ctx, NewJobRunner(pipe, nil))
+
+	var job model.JobResponse
+	for i := 0; i < 100; i++ {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+created.ID, nil))
+		_ = json.Unmarshal(w.Body.Bytes(), &job)
+		if job.Status == "succeeded" || job.Status == "failed" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != "succeeded" || job.Result == nil || job.Result.FinalTranscript != "final" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if pipe.fileBody != "audio-payload" || pipe.input.ContextSummary != "standup" {
+		t.Fatalf("unexpected worker input: body=%q input=%+v", pipe.fileBody, pipe.input)
+	}
+}
+
+func TestCreateJobKeepsCallerKeyedJobsOffTheQueue(t *testing.T) {
+	queue := &chanQueue{items: make(chan queuedItem, 1)}
+	pipe := &stubPipeline{result: pipeline.ProcessResult{FinalTranscript: "final"}}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      pipe,
+		Upstream:      stubUpstream{},
+		Jobs:          jobs.NewManager(jobs.WithStore(&mapStore{records: map[string]jobs.Record{}}), jobs.WithQueue(queue)),
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer gsk_caller")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+	var created model.JobResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if len(queue.items) != 0 {
+		t.Fatal("caller-keyed job was pushed to the shared queue")
+	}
+
+	var job model.JobResponse
+	for i := 0; i < 100; i++ {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+created.ID, nil))
+		_ = json.Unmarshal(w.Body.Bytes(), &job)
+		if job.Status == "succeeded" || job.Status == "failed" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != "succeeded" || job.Result == nil || job.Result.FinalTranscript != "final" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+}
+
+func TestCreateJobPostsResultToCallbackURL(t *testing.T) {
+	delivered := make(chan *http.Request, 1)
+	bodies := make(chan model.JobWebhook, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job model.JobWebhook
+		_ = json.NewDecoder(r.Body).Decode(&job)
+		delivered <- r
+		bodies <- job
+	}))
+	defer receiver.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Config{MaxUploadBytes: 1024 * 1024, UpstreamAPIKey: "x", WebhookSecret: "shh", WebhookAllowPrivate: true}
+	h := NewServer(cfg, logger, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{result: pipeline.ProcessResult{FinalTranscript: "final"}},
+		Upstream:      stubUpstream{},
+		Jobs:          jobs.NewManager(jobs.WithNotifier(NewJobNotifier(webhook.New("shh", webhook.WithPrivateNetworks(true)), logger))),
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.WriteField("callback_url", receiver.URL+"/hook")
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d body=%s", w.Code, w.Body.String())
+	}
+
+	var deliveryID string
+	select {
+	case r := <-delivered:
+		if !strings.HasPrefix(r.Header.Get(webhook.SignatureHeader), "t=") {
+			t.Fatalf("missing signature header: %v", r.Header)
+		}
+		deliveryID = r.Header.Get(webhook.DeliveryHeader)
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	job := <-bodies
+	if job.Status != "succeeded" || job.Result == nil || job.Result.FinalTranscript != "final" {
+		t.Fatalf("unexpected webhook body: %+v", job)
+	}
+	if job.Event != "job.succeeded" || job.DeliveryID == "" || job.DeliveryID != deliveryID || job.DeliveryID != webhook.DeliveryID(job.ID, job.Event) {
+		t.Fatalf("delivery_id = %q event = %q header = %q", job.DeliveryID, job.Event, deliveryID)
+	}
+}
+
+func TestCreateJobRejectsCallbackURLWithoutSecret(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-payload"))
+	_ = mw.WriteField("callback_url", "https://example.com/hook")
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "WEBHOOK_SECRET") {
+		t.Fatalf("unexpected response: %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetJobNotFound(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jobs/job_missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	data, err := os.ReadFile("../../api/openapi.json")
+	if err != nil {
+		t.Fatalf("read spec: %v", err)
+	}
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Chapters:      &stubChapters{},
+	})
+	err = chi.Walk(h.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if _, ok := spec.Paths[route][strings.ToLower(method)]; !ok {
+			t.Errorf("route %s %s is missing from api/openapi.json", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+}
+
+func TestPostProcessHandlerStreamsDeltas(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{result: postprocess.Result{Transcript: "Hello there."}},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hello there"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	want := "event: delta\ndata: {\"content\":\"Hello \"}\n\n" +
+		"event: delta\ndata: {\"content\":\"there.\"}\n\n" +
+		"event: result\ndata: {\"transcript\":\"Hello there.\",\"status\":\"post-processing succeeded\"}\n\n"
+	if w.Body.String() != want {
+		t.Fatalf("unexpected stream:\n%s", w.Body.String())
+	}
+}
+
+func BenchmarkWriteJSON(b *testing.B) {
+	resp := model.PipelineProcessResponse{
+		RawTranscript:        strings.Repeat("raw words ", 500),
+		FinalTranscript:      strings.Repeat("Final words. ", 500),
+		PostProcessingStatus: pipeline.PostProcessingSucceeded,
+		TimingsMS:            model.PipelineTimings{Transcription: 300, PostProcessing: 200, Total: 500},
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		writeJSON(httptest.NewRecorder(), http.StatusOK, resp)
+	}
+}
+
+func TestRemoveStaleSpoolFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	t.Setenv("TMP", dir) // os.TempDir on Windows
+	old := filepath.Join(dir, "echoflow-job-old")
+	fresh := filepath.Join(dir, "echoflow-job-fresh")
+	other := filepath.Join(dir, "unrelated")
+	for _, path := range []string{old, fresh, other} {
+		if err := os.WriteFile(path, []byte("audio"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(old, past, past)
+	_ = os.Chtimes(other, past, past)
+
+	n, err := RemoveStaleSpoolFiles(time.Now().Add(-time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("RemoveStaleSpoolFiles = %d, %v; want 1", n, err)
+	}
+	for path, want := range map[string]bool{old: false, fresh: true, other: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists=%v, want %v", filepath.Base(path), err == nil, want)
+		}
+	}
+}
+
+func TestTranscriptionsHandlerMapsLanguageNotAllowed(t *testing.T) {
+	tr := &stubTranscription{err: &transcription.LanguageError{Language: "ru", Detected: true}}
+	h := newTestHandler(t, Dependencies{Transcription: tr, PostProcess: &stubPostProcess{}, Pipeline: &stubPipeline{}, Upstream: stubUpstream{}})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-bytes"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"code":"language_not_allowed"`) || !strings.Contains(w.Body.String(), `"language":"ru"`) {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestTranscriptionsHandlerReportsWhichDeadlineFired(t *testing.T) {
+	tr := &stubTranscription{err: &deadline.Error{Stage: "transcription", Source: deadline.SourceCaller, Budget: 1500 * time.Millisecond, Elapsed: 1500 * time.Millisecond}}
+	h := newTestHandler(t, Dependencies{Transcription: tr, PostProcess: &stubPostProcess{}, Pipeline: &stubPipeline{}, Upstream: stubUpstream{}})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-bytes"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), `"deadline":{"budget_ms":1500,"elapsed_ms":1500,"source":"caller","stage":"transcription"}`) {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestMapErrorListsUpstreamAttempts(t *testing.T) {
+	err := &upstream.AttemptsError{
+		Attempts: []upstream.Attempt{
+			{Number: 1, Duration: 30 * time.Millisecond, Bytes: 2048, Error: "connection_reset"},
+			{Number: 2, Status: http.StatusBadGateway, Duration: 12 * time.Millisecond, Bytes: 2048},
+		},
+		Err: &upstream.Error{StatusCode: http.StatusBadGateway, Body: "bad gateway"},
+	}
+	status, apiErr := mapError(err)
+	if status != http.StatusBadGateway {
+		t.Fatalf("status = %d", status)
+	}
+	attempts, _ := apiErr.Details["upstream_attempts"].([]map[string]any)
+	if len(attempts) != 2 || attempts[0]["error"] != "connection_reset" || attempts[1]["status"] != http.StatusBadGateway || attempts[1]["duration_ms"] != int64(12) {
+		t.Fatalf("details = %+v", apiErr.Details)
+	}
+}
+
+type loggingTranscription struct{}
+
+func (loggingTranscription) Transcribe(ctx context.Context, _ transcription.Input) (transcription.Result, error) {
+	reqctx.Logger(ctx).Info("upstream_request", "endpoint", "audio_transcriptions")
+	return transcription.Result{Text: "hello"}, nil
+}
+
+func TestRequestLoggerCarriesRequestFields(t *testing.T) {
+	var logs bytes.Buffer
+	cfg := config.Config{MaxUploadBytes: 1024 * 1024, UpstreamAPIKey: "x", UpstreamBaseURL: "http://example.com"}
+	h := NewServer(cfg, slog.New(slog.NewTextHandler(&logs, nil)), Dependencies{Transcription: loggingTranscription{}, PostProcess: &stubPostProcess{}, Pipeline: &stubPipeline{}, Upstream: stubUpstream{}})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "sample.wav")
+	_, _ = part.Write([]byte("audio-bytes"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transcriptions", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set(requestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "upstream_request") {
+			if !strings.Contains(line, "request_id=req-123") || !strings.Contains(line, "route=/v1/transcriptions") {
+				t.Fatalf("service log lacks request fields: %s", line)
+			}
+			return
+		}
+	}
+	t.Fatalf("no service log in %s", logs.String())
+}
+
+func TestPipelineHandlerFallbackPolicy(t *testing.T) {
+	post := func(pipe *stubPipeline, policy string) *httptest.ResponseRecorder {
+		h := newTestHandler(t, Dependencies{
+			Transcription: &stubTranscription{},
+			PostProcess:   &stubPostProcess{},
+			Pipeline:      pipe,
+			Upstream:      stubUpstream{},
+		})
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("fallback_policy", policy)
+		part, _ := mw.CreateFormFile("file", "sample.wav")
+		_, _ = part.Write([]byte("audio"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/pipeline/process", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	pipe := &stubPipeline{err: &pipeline.PostProcessingError{Err: &upstream.Error{StatusCode: http.StatusInternalServerError}}}
+	w := post(pipe, "error")
+	if pipe.input.FallbackPolicy != pipeline.FallbackError {
+		t.Fatalf("fallback policy = %q", pipe.input.FallbackPolicy)
+	}
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), `"post_processing_failed"`) {
+		t.Fatalf("failed post-processing = %d %s", w.Code, w.Body.String())
+	}
+
+	if w := post(&stubPipeline{}, "sometimes"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown policy = %d, want 400", w.Code)
+	}
+}
+
+func TestUpstreamBaseURLHeaderNeedsAllowedToken(t *testing.T) {
+	sum := sha256.Sum256([]byte("ef_acme"))
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tokens := `{"tokens":[{"name":"acme","sha256":"` + hex.EncodeToString(sum[:]) + `","upstream_base_urls":["https://*.acme.example/v1"]}]}`
+	if err := os.WriteFile(path, []byte(tokens), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := auth.LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postProcess := &stubPostProcess{result: postprocess.Result{Transcript: "hi"}}
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   postProcess,
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Tokens:        registry,
+	})
+
+	post := func(token, baseURL string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hi"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Upstream-Base-Url", baseURL)
+		req.Header.Set("X-Upstream-Api-Key", "acme-key")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("ef_acme", "https://eu.acme.example/v1"); w.Code != http.StatusOK {
+		t.Fatalf("allowed base URL: status %d: %s", w.Code, w.Body)
+	}
+	if got := upstream.RequestBaseURLFromContext(postProcess.ctx); got != "https://eu.acme.example/v1" {
+		t.Fatalf("base URL in context = %q", got)
+	}
+	if got := upstream.RequestAPIKeyFromContext(postProcess.ctx); got != "acme-key" {
+		t.Fatalf("API key in context = %q", got)
+	}
+	if w := post("ef_acme", "https://evil.example/v1"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "upstream_base_url_forbidden") {
+		t.Fatalf("other base URL: status %d: %s", w.Code, w.Body)
+	}
+	if w := post("gsk_caller", "https://eu.acme.example/v1"); w.Code != http.StatusForbidden {
+		t.Fatalf("BYOT token: status %d: %s", w.Code, w.Body)
+	}
+	if w := post("ef_acme", "eu.acme.example"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_upstream_base_url") {
+		t.Fatalf("invalid base URL: status %d: %s", w.Code, w.Body)
+	}
+}
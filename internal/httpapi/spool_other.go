@@ -0,0 +1,7 @@
+//go:build !windows
+
+package httpapi
+
+// fileInUse reports whether removing a file failed because it is open. Unix
+// systems unlink open files, so that never happens here.
+func fileInUse(error) bool { return false }
@@ -0,0 +1,16 @@
+//go:build windows
+
+package httpapi
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION, which Windows returns
+// when removing a file that another handle still has open.
+const errorSharingViolation syscall.Errno = 32
+
+func fileInUse(err error) bool {
+	return errors.Is(err, errorSharingViolation)
+}
@@ -0,0 +1,103 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/reqctx"
+)
+
+const sseWriteTimeout = 10 * time.Second
+
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func startEventStream(w http.ResponseWriter) *http.ResponseController {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return http.NewResponseController(w)
+}
+
+func writeSSE(w io.Writer, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// streamPipelineProcess runs the pipeline and reports each finished stage as
+// an SSE event, ending with "result" (or "error" once headers are sent).
+func (s *server) streamPipelineProcess(w http.ResponseWriter, r *http.Request, req *pipelineRequest) {
+	rc := startEventStream(w)
+	_ = rc.Flush()
+
+	input := req.input
+	input.OnStageComplete = func(stage pipeline.StageResult) {
+		event := "transcription_done"
+		if stage.Stage == pipeline.StagePostProcessing {
+			event = "post_processing_done"
+		}
+		_ = writeSSE(w, event, model.PipelineStageEvent{
+			Stage:      stage.Stage,
+			Transcript: stage.Transcript,
+			Status:     stage.Status,
+			Outcome:    stage.Outcome,
+			DurationMS: stage.Duration.Milliseconds(),
+		})
+		_ = rc.Flush()
+	}
+
+	result, err := s.pipeline.Process(r.Context(), input)
+	if err != nil {
+		s.writeStreamError(w, r, rc, err)
+		return
+	}
+	s.observePipeline(result)
+	_ = writeSSE(w, "result", toPipelineResponse(result, req.audio))
+	_ = rc.Flush()
+}
+
+// streamPostProcess forwards model output as "delta" events, then sends the
+// sanitized transcript as "result" (or "error").
+func (s *server) streamPostProcess(w http.ResponseWriter, r *http.Request, input postprocess.Input) {
+	rc := startEventStream(w)
+	_ = rc.Flush()
+
+	result, err := s.postProcess.ProcessStream(r.Context(), input, func(delta string) error {
+		if err := writeSSE(w, "delta", model.PostProcessDelta{Content: delta}); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		s.writeStreamError(w, r, rc, err)
+		return
+	}
+	_ = writeSSE(w, "result", model.PostProcessResponse{
+		Transcript:   result.Transcript,
+		Status:       "post-processing succeeded",
+		Usage:        toModelTokenUsage(result.Usage),
+		SpelledWords: result.SpelledWords,
+	})
+	_ = rc.Flush()
+}
+
+func (s *server) writeStreamError(w http.ResponseWriter, r *http.Request, rc *http.ResponseController, err error) {
+	status, apiErr := mapError(err)
+	requestID := reqctx.RequestID(r.Context())
+	reqctx.Logger(r.Context()).Warn("stream failed", "path", r.URL.Path, "status", status, "error", err)
+	_ = writeSSE(w, "error", model.ErrorResponse{Error: apiErr, RequestID: requestID})
+	_ = rc.Flush()
+}
@@ -0,0 +1,239 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+
+	"echoflow/internal/model"
+	"echoflow/internal/reqctx"
+)
+
+const (
+	summaryWindow     = time.Hour
+	summaryBucket     = time.Minute
+	summaryTopTenants = 10
+)
+
+// latencyBoundsMS are the upper bounds of the latency histogram buckets.
+// Percentiles are reported as the bound of the bucket they fall in, or the
+// last bound for slower requests.
+var latencyBoundsMS = []int64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 20000, 30000, 60000, 120000, 300000}
+
+// usageStats keeps per-minute counts for the last hour, for GET
+// /admin/summary. Unlike the Prometheus metrics it forgets old minutes, so a
+// status page can read recent figures without computing rates.
+type usageStats struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	buckets []statsBucket
+}
+
+type statsBucket struct {
+	start        time.Time
+	requests     int
+	serverErrors int
+	pipelines    int
+	fallbacks    int
+	latency      map[string][]int
+	tenants      map[string]*model.TenantUsage
+}
+
+func newUsageStats() *usageStats {
+	return &usageStats{now: time.Now, buckets: make([]statsBucket, summaryWindow/summaryBucket)}
+}
+
+// bucketLocked returns the current minute's bucket, clearing it when it
+// last held an older minute.
+func (u *usageStats) bucketLocked() *statsBucket {
+	start := u.now().Truncate(summaryBucket)
+	b := &u.buckets[int(start.Unix()/int64(summaryBucket/time.Second))%len(u.buckets)]
+	if !b.start.Equal(start) {
+		*b = statsBucket{start: start, latency: make(map[string][]int), tenants: make(map[string]*model.TenantUsage)}
+	}
+	return b
+}
+
+func (b *statsBucket) tenant(name string) *model.TenantUsage {
+	t, ok := b.tenants[name]
+	if !ok {
+		t = &model.TenantUsage{Tenant: name}
+		b.tenants[name] = t
+	}
+	return t
+}
+
+func (u *usageStats) observeRequest(route, tenant string, status int, duration time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	b := u.bucketLocked()
+	b.requests++
+	if status >= http.StatusInternalServerError {
+		b.serverErrors++
+	}
+	counts, ok := b.latency[route]
+	if !ok {
+		counts = make([]int, len(latencyBoundsMS)+1)
+		b.latency[route] = counts
+	}
+	ms := duration.Milliseconds()
+	i, _ := slices.BinarySearch(latencyBoundsMS, ms)
+	counts[i]++
+	b.tenant(tenant).Requests++
+}
+
+func (u *usageStats) observePipeline(fallback bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	b := u.bucketLocked()
+	b.pipelines++
+	if fallback {
+		b.fallbacks++
+	}
+}
+
+func (u *usageStats) observeUsage(tenant string, usage requestUsage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t := u.bucketLocked().tenant(tenant)
+	t.TokensUsed += usage.tokens
+	t.AudioSeconds += usage.audio.Seconds()
+}
+
+// snapshot sums the buckets that fall inside the window.
+func (u *usageStats) snapshot() model.SummaryResponse {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	out := model.SummaryResponse{GeneratedAt: now.UTC(), WindowSeconds: int(summaryWindow / time.Second)}
+	fallbacks := 0
+	latency := map[string][]int{}
+	tenants := map[string]*model.TenantUsage{}
+	for _, b := range u.buckets {
+		if b.start.IsZero() || now.Sub(b.start) >= summaryWindow {
+			continue
+		}
+		out.Requests += b.requests
+		out.ServerErrors += b.serverErrors
+		out.PipelineRuns += b.pipelines
+		fallbacks += b.fallbacks
+		for route, counts := range b.latency {
+			sum, ok := latency[route]
+			if !ok {
+				sum = make([]int, len(counts))
+				latency[route] = sum
+			}
+			for i, n := range counts {
+				sum[i] += n
+			}
+		}
+		for name, t := range b.tenants {
+			sum, ok := tenants[name]
+			if !ok {
+				sum = &model.TenantUsage{Tenant: name}
+				tenants[name] = sum
+			}
+			sum.Requests += t.Requests
+			sum.TokensUsed += t.TokensUsed
+			sum.AudioSeconds += t.AudioSeconds
+		}
+	}
+	if out.PipelineRuns > 0 {
+		out.FallbackRate = float64(fallbacks) / float64(out.PipelineRuns)
+	}
+
+	out.Latency = []model.RouteLatency{}
+	for route, counts := range latency {
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		out.Latency = append(out.Latency, model.RouteLatency{
+			Route:    route,
+			Requests: total,
+			P50MS:    percentileMS(counts, total, 0.50),
+			P95MS:    percentileMS(counts, total, 0.95),
+		})
+	}
+	slices.SortFunc(out.Latency, func(a, b model.RouteLatency) int { return cmp.Compare(a.Route, b.Route) })
+
+	out.TopTenants = []model.TenantUsage{}
+	for _, t := range tenants {
+		out.TopTenants = append(out.TopTenants, *t)
+	}
+	slices.SortFunc(out.TopTenants, func(a, b model.TenantUsage) int {
+		return cmp.Or(cmp.Compare(b.Requests, a.Requests), cmp.Compare(b.AudioSeconds, a.AudioSeconds), cmp.Compare(a.Tenant, b.Tenant))
+	})
+	if len(out.TopTenants) > summaryTopTenants {
+		out.TopTenants = out.TopTenants[:summaryTopTenants]
+	}
+	return out
+}
+
+// percentileMS returns the upper bound of the bucket holding the p-th
+// percentile.
+func percentileMS(counts []int, total int, p float64) int64 {
+	rank := int(p*float64(total)+0.5) - 1
+	rank = max(rank, 0)
+	seen := 0
+	for i, n := range counts {
+		seen += n
+		if seen > rank {
+			return latencyBoundsMS[min(i, len(latencyBoundsMS)-1)]
+		}
+	}
+	return 0
+}
+
+// summaryMiddleware records /v1 requests doing work in the summary. GETs are
+// left out, like in the in-flight count, so job polling does not drown the
+// figures.
+func (s *server) summaryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		started := time.Now()
+		ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		s.stats.observeRequest(route, reqctx.Tenant(r.Context()), status, time.Since(started))
+	})
+}
+
+func (s *server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	summary := s.stats.snapshot()
+	summary.InFlight = s.inFlight.Load()
+	summary.QueueDepth = s.jobs.QueueDepth()
+	summary.Upstream = model.UpstreamSnapshot{Status: "unchecked"}
+	if checked, err := s.cachedUpstreamCheck(); checked {
+		summary.Upstream.Status = "ok"
+		if err != nil {
+			_, apiErr := mapError(err)
+			summary.Upstream = model.UpstreamSnapshot{Status: "unhealthy", Error: &apiErr}
+		}
+	} else if s.cfg.UpstreamAPIKey != "" {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		summary.Upstream.Status = "ok"
+		if err := s.upstream.CheckHealth(ctx); err != nil {
+			_, apiErr := mapError(err)
+			summary.Upstream = model.UpstreamSnapshot{Status: "unhealthy", Error: &apiErr}
+		}
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
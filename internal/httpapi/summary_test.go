@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/model"
+)
+
+func TestUsageStatsSummarizesTheLastHour(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	stats := newUsageStats()
+
+	// Observed 61 minutes ago, so outside the window.
+	stats.now = func() time.Time { return now.Add(-61 * time.Minute) }
+	stats.observeRequest("/v1/transcriptions", "old", http.StatusOK, time.Second)
+
+	stats.now = func() time.Time { return now.Add(-30 * time.Minute) }
+	for i := range 19 {
+		stats.observeRequest("/v1/pipeline/process", "acme", http.StatusOK, time.Duration(i+1)*10*time.Millisecond)
+	}
+	stats.observeRequest("/v1/pipeline/process", "acme", http.StatusBadGateway, 4*time.Second)
+	stats.observePipeline(false)
+	stats.observePipeline(false)
+	stats.observePipeline(false)
+	stats.now = func() time.Time { return now }
+	stats.observePipeline(true)
+	stats.observeRequest("/v1/post-process", "", http.StatusOK, 5*time.Millisecond)
+	stats.observeUsage("acme", requestUsage{tokens: 120, audio: 15 * time.Second})
+
+	got := stats.snapshot()
+	if got.Requests != 21 || got.ServerErrors != 1 || got.PipelineRuns != 4 || got.FallbackRate != 0.25 {
+		t.Fatalf("totals = %+v", got)
+	}
+	wantLatency := []model.RouteLatency{
+		{Route: "/v1/pipeline/process", Requests: 20, P50MS: 100, P95MS: 250},
+		{Route: "/v1/post-process", Requests: 1, P50MS: 10, P95MS: 10},
+	}
+	if len(got.Latency) != len(wantLatency) || got.Latency[0] != wantLatency[0] || got.Latency[1] != wantLatency[1] {
+		t.Fatalf("latency = %+v, want %+v", got.Latency, wantLatency)
+	}
+	wantTenants := []model.TenantUsage{
+		{Tenant: "acme", Requests: 20, TokensUsed: 120, AudioSeconds: 15},
+		{Tenant: "", Requests: 1},
+	}
+	if len(got.TopTenants) != 2 || got.TopTenants[0] != wantTenants[0] || got.TopTenants[1] != wantTenants[1] {
+		t.Fatalf("top tenants = %+v, want %+v", got.TopTenants, wantTenants)
+	}
+}
+
+func TestSummaryEndpointReportsRecentRequests(t *testing.T) {
+	h := newAdminTestHandler(nil)
+	req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hi"}`))
+	req.Header.Set("Authorization", "Bearer gsk_caller")
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/summary", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("summary without admin token = %d, want 401", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/summary", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var got model.SummaryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("summary = %d %s", w.Code, w.Body.String())
+	}
+	if got.Requests != 1 || len(got.Latency) != 1 || got.Latency[0].Route != "/v1/post-process" || got.Upstream.Status != "ok" {
+		t.Fatalf("summary = %+v", got)
+	}
+}
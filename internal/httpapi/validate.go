@@ -0,0 +1,143 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// fieldError is one invalid field of a JSON request body.
+type fieldError struct {
+	Field      string `json:"field"`
+	Code       string `json:"code"`
+	Constraint string `json:"constraint,omitempty"`
+	Message    string `json:"message"`
+}
+
+// validator collects every invalid field of a request body, so one error
+// response lists all of them.
+type validator struct {
+	errs []fieldError
+}
+
+// decode reads a single JSON object from body into dst, a pointer to a
+// struct. Unknown fields and values of the wrong type are recorded against
+// their field rather than failing the whole body; the returned error is
+// only for bodies that are not a JSON object at all.
+func (v *validator) decode(body io.Reader, dst any) error {
+	decoder := json.NewDecoder(body)
+	var raw map[string]json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		return errors.New("JSON body must be an object")
+	}
+	if err := ensureBodyFullyConsumed(decoder); err != nil {
+		return err
+	}
+
+	target := reflect.ValueOf(dst).Elem()
+	fields := jsonFields(target.Type())
+	for _, name := range slices.Sorted(maps.Keys(raw)) {
+		index, ok := fields[name]
+		if !ok {
+			v.add(name, "unknown_field", "", fmt.Sprintf("unknown field '%s'", name))
+			continue
+		}
+		field := target.Field(index)
+		if err := json.Unmarshal(raw[name], field.Addr().Interface()); err != nil {
+			kind := jsonKind(field.Type())
+			v.add(name, "invalid_type", kind, fmt.Sprintf("%s must be %s", name, withArticle(kind)))
+		}
+	}
+	return nil
+}
+
+func (v *validator) add(field, code, constraint, message string) {
+	v.errs = append(v.errs, fieldError{Field: field, Code: code, Constraint: constraint, Message: message})
+}
+
+// required records field when value is blank, unless the field already has
+// an error.
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" && !v.has(field) {
+		v.add(field, "required", "non-empty", field+" is required")
+	}
+}
+
+// check records err, if any, against field.
+func (v *validator) check(field, code, constraint string, err error) {
+	if err != nil && !v.has(field) {
+		v.add(field, code, constraint, err.Error())
+	}
+}
+
+func (v *validator) has(field string) bool {
+	return slices.ContainsFunc(v.errs, func(e fieldError) bool { return e.Field == field })
+}
+
+func (v *validator) failed() bool { return len(v.errs) > 0 }
+
+// writeValidationError reports every collected field error. The message is
+// the first error's, so clients that only read it see what they did before.
+func (s *server) writeValidationError(w http.ResponseWriter, r *http.Request, v *validator) {
+	message := v.errs[0].Message
+	switch n := len(v.errs) - 1; {
+	case n == 1:
+		message += " (and 1 more invalid field)"
+	case n > 1:
+		message += fmt.Sprintf(" (and %d more invalid fields)", n)
+	}
+	s.writeError(w, r, http.StatusBadRequest, "invalid_request", message, map[string]any{"errors": v.errs})
+}
+
+// jsonFields maps a struct's JSON field names to their field index.
+func jsonFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !f.IsExported():
+			continue
+		case name == "":
+			name = f.Name
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array of " + jsonKind(t.Elem()) + "s"
+	default:
+		return "object"
+	}
+}
+
+func withArticle(kind string) string {
+	if strings.HasPrefix(kind, "a") || strings.HasPrefix(kind, "i") || strings.HasPrefix(kind, "o") {
+		return "an " + kind
+	}
+	return "a " + kind
+}
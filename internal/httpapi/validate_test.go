@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostProcessReportsEveryInvalidField(t *testing.T) {
+	h := newTestHandler(t, Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   &stubPostProcess{},
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+	})
+	body := `{"transcript": " ", "timeout_ms": "soon", "contxt_summary": "standup", "model": 4}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+			Details struct {
+				Errors []fieldError `json:"errors"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := []fieldError{
+		{Field: "contxt_summary", Code: "unknown_field"},
+		{Field: "model", Code: "invalid_type", Constraint: "string"},
+		{Field: "timeout_ms", Code: "invalid_type", Constraint: "integer"},
+		{Field: "transcript", Code: "required", Constraint: "non-empty"},
+	}
+	got := resp.Error.Details.Errors
+	if len(got) != len(want) {
+		t.Fatalf("errors = %+v", got)
+	}
+	for i := range want {
+		if got[i].Field != want[i].Field || got[i].Code != want[i].Code || got[i].Constraint != want[i].Constraint || got[i].Message == "" {
+			t.Fatalf("errors[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if resp.Error.Message != "unknown field 'contxt_summary' (and 3 more invalid fields)" {
+		t.Fatalf("message = %q", resp.Error.Message)
+	}
+}
+
+func TestValidatorRejectsNonObjectBodies(t *testing.T) {
+	for _, body := range []string{`null`, `[1]`, `{"transcript": "a"} {}`, `{`} {
+		var dst struct {
+			Transcript string `json:"transcript"`
+		}
+		if err := (&validator{}).decode(strings.NewReader(body), &dst); err == nil {
+			t.Errorf("decode(%s) succeeded", body)
+		}
+	}
+}
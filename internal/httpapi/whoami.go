@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"echoflow/internal/auth"
+	"echoflow/internal/model"
+)
+
+// requireScope rejects identities without scope.
+func (s *server) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, _ := auth.IdentityFromContext(r.Context())
+			if !id.HasScope(scope) {
+				s.writeError(w, r, http.StatusForbidden, "forbidden", fmt.Sprintf("token %q lacks the %q scope", id.Name, scope), nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// consumeQuota charges one request against the identity's daily quota.
+func (s *server) consumeQuota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.takeQuota(w, r, 1) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// takeQuota charges n requests against the identity's daily quota, or
+// writes 429 quota_exceeded and charges nothing when fewer are left.
+func (s *server) takeQuota(w http.ResponseWriter, r *http.Request, n int) bool {
+	id, _ := auth.IdentityFromContext(r.Context())
+	if s.quotas.TakeN(id, n) {
+		return true
+	}
+	status, _ := s.quotas.Status(id)
+	retryAfter := math.Ceil(time.Until(status.ResetsAt).Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(retryAfter), 1)))
+	details := map[string]any{"limit": status.Limit, "resets_at": status.ResetsAt}
+	if n > 1 {
+		details["remaining"], details["requested"] = status.Remaining, n
+	}
+	s.writeError(w, r, http.StatusTooManyRequests, "quota_exceeded", "daily request quota exhausted", details)
+	return false
+}
+
+func (s *server) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	id, _ := auth.IdentityFromContext(r.Context())
+	resp := model.WhoAmIResponse{
+		Kind:   id.Kind,
+		Name:   id.Name,
+		Tenant: id.Tenant,
+		Tier:   id.Tier,
+		Scopes: id.Scopes,
+	}
+	if token, _, _ := extractBearerToken(r.Header.Get("Authorization")); token != "" {
+		resp.TokenFingerprint = auth.Fingerprint(token)
+	}
+	if status, ok := s.quotas.Status(id); ok {
+		resp.Quotas.DailyRequests = &model.RequestQuota{
+			Limit:     status.Limit,
+			Used:      status.Used,
+			Remaining: status.Remaining,
+			ResetsAt:  status.ResetsAt,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"echoflow/internal/auth"
+	"echoflow/internal/config"
+	"echoflow/internal/model"
+	"echoflow/internal/postprocess"
+	"echoflow/internal/upstream"
+)
+
+type keyCapturingPostProcess struct {
+	stubPostProcess
+	apiKey string
+}
+
+func (s *keyCapturingPostProcess) Process(ctx context.Context, in postprocess.Input) (postprocess.Result, error) {
+	s.apiKey = upstream.RequestAPIKeyFromContext(ctx)
+	return s.stubPostProcess.Process(ctx, in)
+}
+
+func newAuthTestHandler(t *testing.T, postProcess PostProcessService) http.Handler {
+	t.Helper()
+	return newAuthTestHandlerWithConfig(t, config.Config{MaxUploadBytes: 1024 * 1024, UpstreamAPIKey: "x"}, postProcess)
+}
+
+func newAuthTestHandlerWithConfig(t *testing.T, cfg config.Config, postProcess PostProcessService) http.Handler {
+	t.Helper()
+	sum := sha256.Sum256([]byte("ef_acme"))
+	adminSum := sha256.Sum256([]byte("ef_acme_admin"))
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tokens := `{"tokens":[{"name":"acme-web","sha256":"` + hex.EncodeToString(sum[:]) +
+		`","tenant":"acme","scopes":["post_process"],"daily_request_quota":1},` +
+		`{"name":"acme-admin","sha256":"` + hex.EncodeToString(adminSum[:]) +
+		`","tenant":"acme","scopes":["post_process","acronyms_write"]}]}`
+	if err := os.WriteFile(path, []byte(tokens), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := auth.LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), Dependencies{
+		Transcription: &stubTranscription{},
+		PostProcess:   postProcess,
+		Pipeline:      &stubPipeline{},
+		Upstream:      stubUpstream{},
+		Tokens:        registry,
+		Quotas:        auth.NewQuotas(),
+	})
+}
+
+func whoami(t *testing.T, h http.Handler, token string) model.WhoAmIResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("whoami status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp model.WhoAmIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestWhoAmIReportsIdentityAndQuota(t *testing.T) {
+	postProcess := &keyCapturingPostProcess{}
+	h := newAuthTestHandler(t, postProcess)
+
+	resp := whoami(t, h, "ef_acme")
+	if resp.Kind != auth.KindToken || resp.Name != "acme-web" || resp.Tenant != "acme" || resp.Tier != "standard" {
+		t.Fatalf("whoami = %+v", resp)
+	}
+	if resp.TokenFingerprint == "" || strings.Contains(resp.TokenFingerprint, "ef_acme") {
+		t.Fatalf("fingerprint = %q", resp.TokenFingerprint)
+	}
+	if q := resp.Quotas.DailyRequests; q == nil || q.Limit != 1 || q.Remaining != 1 {
+		t.Fatalf("quota = %+v", q)
+	}
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/post-process", strings.NewReader(`{"transcript":"hi"}`))
+		req.Header.Set("Authorization", "Bearer ef_acme")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	if w := post(); w.Code != http.StatusOK {
+		t.Fatalf("first post-process status = %d body=%s", w.Code, w.Body.String())
+	}
+	if postProcess.apiKey != "" {
+		t.Fatalf("EchoFlow token forwarded upstream as %q", postProcess.apiKey)
+	}
+	if w := post(); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("second post-process status = %d body=%s", w.Code, w.Body.String())
+	}
+	if q := whoami(t, h, "ef_acme").Quotas.DailyRequests; q.Used != 1 || q.Remaining != 0 {
+		t.Fatalf("quota after use = %+v", q)
+	}
+}
+
+func TestScopeIsEnforcedAndBYOTHasAllScopes(t *testing.T) {
+	h := newAuthTestHandler(t, &stubPostProcess{})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/jobs/job_1", nil)
+	req.Header.Set("Authorization", "Bearer ef_acme")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+
+	resp := whoami(t, h, "gsk_caller")
+	if resp.Kind != auth.KindBYOT || len(resp.Scopes) != len(auth.AllScopes) || resp.Quotas.DailyRequests != nil {
+		t.Fatalf("byot whoami = %+v", resp)
+	}
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"time"
+
+	"echoflow/internal/model"
+)
+
+// defaultRealtimeFactor is the processing time per second of audio assumed
+// until a job with a known audio duration has finished.
+const defaultRealtimeFactor = 0.1
+
+// Estimate predicts how long a job submitted now would take to finish.
+type Estimate struct {
+	// Wait is the time until a worker is free, given the jobs queued ahead.
+	Wait time.Duration
+	// Processing is the job's own run time.
+	Processing time.Duration
+}
+
+func (e Estimate) Total() time.Duration {
+	return e.Wait + e.Processing
+}
+
+// EstimateJob predicts the wait and run time of a job for audio of the given
+// duration, or of a typical job when the duration is unknown (0). Both come
+// from moving averages of jobs this Manager has finished.
+func (m *Manager) EstimateJob(audio time.Duration) Estimate {
+	perJob, realtime := m.estimates.job()
+	processing := perJob
+	if audio > 0 {
+		processing = time.Duration(realtime * float64(audio))
+	}
+	m.mu.Lock()
+	waiting := m.waiting
+	m.mu.Unlock()
+	return Estimate{
+		Wait:       time.Duration(float64(perJob) * float64(waiting) / float64(m.workers)),
+		Processing: processing,
+	}
+}
+
+// observeJob records the run time of a successful job, and the processing
+// time per second of audio when the result reports the audio's duration.
+func (m *Manager) observeJob(elapsed time.Duration, result *model.PipelineProcessResponse) {
+	var audio time.Duration
+	if result != nil && result.Audio != nil {
+		audio = time.Duration(result.Audio.DurationMS) * time.Millisecond
+	}
+	m.estimates.observeJob(elapsed, audio)
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+func TestEstimateJobLearnsFromFinishedJobs(t *testing.T) {
+	m := NewManager(WithWorkers(2, 10))
+	now := time.Unix(0, 0)
+	m.now = func() time.Time { return now }
+
+	if got := m.EstimateJob(0); got.Wait != 0 || got.Processing != 13*time.Second {
+		t.Fatalf("idle estimate = %+v", got)
+	}
+	job, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		now = now.Add(30 * time.Second)
+		return model.PipelineProcessResponse{Audio: &model.AudioMetadata{DurationMS: 60_000}}, nil
+	}, nil)
+	waitTerminal(t, m, job.ID)
+
+	// 30s for 60s of audio pulls the realtime factor from 0.1 towards 0.5.
+	if got := m.EstimateJob(time.Minute).Processing; got != time.Duration(0.18*float64(time.Minute)) {
+		t.Fatalf("processing estimate = %v", got)
+	}
+
+	m.mu.Lock()
+	m.waiting = 4
+	m.mu.Unlock()
+	perJob, _ := m.estimates.job()
+	if got := m.EstimateJob(0).Wait; got != 2*perJob {
+		t.Fatalf("wait with 4 queued on 2 workers = %v, want %v", got, 2*perJob)
+	}
+	m.mu.Lock()
+	m.waiting = 0
+	m.mu.Unlock()
+}
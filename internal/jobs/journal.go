@@ -0,0 +1,291 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+// Journal holds the payload of every accepted job until it finishes, so work
+// interrupted by a crash or deploy can be picked up by the next start.
+type Journal interface {
+	// Spool stores the job's payload as write produces it, so a job waiting
+	// for a worker does not hold its audio in memory.
+	Spool(ctx context.Context, id string, write func(io.Writer) error) error
+	// Payload reads back what Spool stored for the job.
+	Payload(ctx context.Context, id string) ([]byte, error)
+	Append(ctx context.Context, entry JournalEntry) error
+	Remove(ctx context.Context, id string) error
+	// Entries returns every job that was journaled but never removed.
+	Entries(ctx context.Context) ([]JournalEntry, error)
+}
+
+type JournalEntry struct {
+	ID          string
+	Tenant      string
+	CreatedAt   time.Time
+	CallbackURL string
+	// Attempts counts the starts of the job, including the one interrupted.
+	Attempts int
+}
+
+// DefaultJournalAttempts is how many times a job is started before Recover
+// dead-letters it; a job that keeps crashing the process must not loop.
+const DefaultJournalAttempts = 2
+
+// WithJournal journals jobs submitted with SubmitPayload, and queued jobs
+// this replica dequeues, before they run. run executes them, both on submit
+// and when Recover resumes them. Jobs started maxAttempts times are failed
+// instead of resumed.
+func WithJournal(journal Journal, run Runner, maxAttempts int) Option {
+	return func(m *Manager) {
+		m.journal = journal
+		m.journalRun = run
+		m.journalAttempts = DefaultJournalAttempts
+		if maxAttempts > 0 {
+			m.journalAttempts = maxAttempts
+		}
+	}
+}
+
+func (m *Manager) HasJournal() bool {
+	return m.journal != nil
+}
+
+// SubmitPayload is Submit for a serialized job: encode writes the payload
+// straight into the journal, so the job survives a restart, and the journal's
+// Runner reads it back once a worker is free.
+func (m *Manager) SubmitPayload(ctx context.Context, encode func(io.Writer) error, opts ...SubmitOption) (Job, error) {
+	if m.journal == nil {
+		return Job{}, errors.New("jobs: no journal configured")
+	}
+	if !m.reserve() {
+		return Job{}, ErrQueueFull
+	}
+	j := &job{Job: m.newJob(opts), changed: make(chan struct{})}
+	entry := JournalEntry{ID: j.ID, Tenant: j.Tenant, CreatedAt: j.CreatedAt, CallbackURL: j.CallbackURL, Attempts: 1}
+	if err := m.spoolJournal(j.ID, encode); err != nil {
+		m.release()
+		m.removeJournal(j.ID)()
+		return Job{}, err
+	}
+	if err := m.appendJournal(entry); err != nil {
+		m.release()
+		m.removeJournal(j.ID)()
+		return Job{}, err
+	}
+	return m.submit(ctx, j, m.journaledTask(j.ID), m.removeJournal(j.ID)), nil
+}
+
+// Recover resumes the jobs left in the journal by the previous process, or
+// fails them once they have used up their attempts or cannot be journaled
+// again; either way their webhook fires when they finish. Call it once at
+// startup. It blocks while the local queue is full, so run it in its own
+// goroutine.
+func (m *Manager) Recover(ctx context.Context) {
+	if m.journal == nil {
+		return
+	}
+	entries, err := m.journal.Entries(ctx)
+	if err != nil {
+		m.logger.Error("job journal read failed", "error", err)
+	}
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		j := &job{
+			Job: Job{
+				ID:          entry.ID,
+				Tenant:      entry.Tenant,
+				Status:      StatusQueued,
+				CreatedAt:   entry.CreatedAt,
+				UpdatedAt:   m.now(),
+				CallbackURL: entry.CallbackURL,
+			},
+			changed: make(chan struct{}),
+		}
+		if entry.Attempts >= m.journalAttempts {
+			m.logger.Warn("job dead-lettered after restart", "job_id", entry.ID, "attempts", entry.Attempts)
+			m.failRecovered(ctx, j, fmt.Sprintf("job was interrupted %d times and will not be retried", entry.Attempts))
+			continue
+		}
+
+		entry.Attempts++
+		if m.appendJournal(entry) != nil {
+			// Running it without a recorded attempt could loop on a job
+			// that crashes the process, so fail it where callers can see.
+			m.failRecovered(ctx, j, "job was interrupted and could not be resumed")
+			continue
+		}
+		m.logger.Info("job resumed after restart", "job_id", entry.ID, "attempt", entry.Attempts)
+		m.mu.Lock()
+		m.waiting++
+		m.reportDepthLocked()
+		m.mu.Unlock()
+		m.submit(context.WithoutCancel(ctx), j, m.journaledTask(entry.ID), m.removeJournal(entry.ID))
+	}
+}
+
+// failRecovered finishes a journaled job with job_interrupted instead of
+// running it, and drops it from the journal.
+func (m *Manager) failRecovered(ctx context.Context, j *job, message string) {
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	m.mu.Unlock()
+	m.run(context.WithoutCancel(ctx), j, func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{}, &Error{APIError: model.APIError{Code: "job_interrupted", Message: message}}
+	}, m.removeJournal(j.ID))
+}
+
+// journaledTask reads the job's payload back from the journal only once a
+// worker runs it.
+func (m *Manager) journaledTask(id string) Task {
+	return func(ctx context.Context, onProgress func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		payload, err := m.journal.Payload(ctx, id)
+		if err != nil {
+			m.logger.Error("job journal payload read failed", "job_id", id, "error", err)
+			return model.PipelineProcessResponse{}, &Error{APIError: model.APIError{Code: "internal_error", Message: "job payload could not be read"}}
+		}
+		return m.journalRun(ctx, payload, onProgress)
+	}
+}
+
+func (m *Manager) spoolJournal(id string, write func(io.Writer) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.journal.Spool(ctx, id, write); err != nil {
+		m.logger.Error("job journal spool failed", "job_id", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) appendJournal(entry JournalEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.journal.Append(ctx, entry); err != nil {
+		m.logger.Error("job journal append failed", "job_id", entry.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// removeJournal returns a cleanup that drops the job's entry once it has
+// finished and its final state is saved.
+func (m *Manager) removeJournal(id string) func() {
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		defer cancel()
+		if err := m.journal.Remove(ctx, id); err != nil {
+			m.logger.Error("job journal remove failed", "job_id", id, "error", err)
+		}
+	}
+}
+
+// DirJournal keeps two files per job in a directory: the entry and the
+// spooled payload. Each is written to a temporary file, synced and renamed
+// into place, so a crash mid-write leaves either the old file or the new one.
+type DirJournal struct {
+	dir string
+}
+
+const (
+	journalExt = ".job"
+	payloadExt = ".payload"
+)
+
+func NewDirJournal(dir string) (*DirJournal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DirJournal{dir: dir}, nil
+}
+
+func (d *DirJournal) Spool(_ context.Context, id string, write func(io.Writer) error) error {
+	return d.writeFile(d.path(id, payloadExt), write)
+}
+
+func (d *DirJournal) Payload(_ context.Context, id string) ([]byte, error) {
+	return os.ReadFile(d.path(id, payloadExt))
+}
+
+func (d *DirJournal) Append(_ context.Context, entry JournalEntry) error {
+	return d.writeFile(d.path(entry.ID, journalExt), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(entry)
+	})
+}
+
+// Remove drops the entry before the payload, so a crash in between never
+// leaves an entry whose payload is gone.
+func (d *DirJournal) Remove(_ context.Context, id string) error {
+	for _, ext := range []string{journalExt, payloadExt} {
+		if err := os.Remove(d.path(id, ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DirJournal) writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Entries returns the journaled jobs oldest first. Unreadable entries are
+// reported in the error but do not hide the others.
+func (d *DirJournal) Entries(_ context.Context) ([]JournalEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(d.dir, "*"+journalExt))
+	if err != nil {
+		return nil, err
+	}
+	var entries []JournalEntry
+	var errs []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var entry JournalEntry
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b JournalEntry) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return entries, errors.Join(errs...)
+}
+
+func (d *DirJournal) path(id, ext string) string {
+	// Job IDs are generated hex strings; strip separators anyway so an entry
+	// can never land outside the directory.
+	return filepath.Join(d.dir, strings.NewReplacer("/", "_", `\`, "_").Replace(id)+ext)
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+func TestDirJournalKeepsEntriesUntilRemoved(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := NewDirJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	_ = journal.Spool(ctx, "job_b", writeString("b"))
+	_ = journal.Append(ctx, JournalEntry{ID: "job_b", CreatedAt: now.Add(time.Second), Attempts: 1})
+	_ = journal.Spool(ctx, "job_a", writeString("a"))
+	_ = journal.Append(ctx, JournalEntry{ID: "job_a", CreatedAt: now, Attempts: 1})
+	_ = journal.Append(ctx, JournalEntry{ID: "job_a", CreatedAt: now, Attempts: 2})
+
+	reopened, _ := NewDirJournal(dir)
+	entries, err := reopened.Entries(ctx)
+	if err != nil || len(entries) != 2 || entries[0].ID != "job_a" || entries[0].Attempts != 2 || entries[1].ID != "job_b" {
+		t.Fatalf("entries = %+v, %v", entries, err)
+	}
+	if payload, err := reopened.Payload(ctx, "job_b"); err != nil || string(payload) != "b" {
+		t.Fatalf("payload = %q, %v", payload, err)
+	}
+	_ = reopened.Remove(ctx, "job_a")
+	if _, err := reopened.Payload(ctx, "job_a"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("payload after remove: %v", err)
+	}
+	if err := reopened.Remove(ctx, "job_a"); err != nil {
+		t.Fatalf("second Remove: %v", err)
+	}
+	if entries, _ := reopened.Entries(ctx); len(entries) != 1 || entries[0].ID != "job_b" {
+		t.Fatalf("entries after remove = %+v", entries)
+	}
+}
+
+func TestRecoverResumesInterruptedJobsAndDeadLettersRepeatOffenders(t *testing.T) {
+	journal, _ := NewDirJournal(t.TempDir())
+	ctx := context.Background()
+	_ = journal.Spool(ctx, "job_resume", writeString("audio"))
+	_ = journal.Append(ctx, JournalEntry{ID: "job_resume", Attempts: 1, CallbackURL: "https://example.com/hook"})
+	_ = journal.Spool(ctx, "job_dead", writeString("crashes"))
+	_ = journal.Append(ctx, JournalEntry{ID: "job_dead", Attempts: 2, CallbackURL: "https://example.com/hook"})
+
+	run := func(_ context.Context, payload []byte, _ func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{FinalTranscript: string(payload)}, nil
+	}
+	notified := make(chan Job, 2)
+	m := NewManager(WithJournal(journal, run, 2), WithNotifier(func(_ context.Context, job Job) error {
+		notified <- job
+		return nil
+	}))
+	m.Recover(ctx)
+
+	resumed := waitTerminal(t, m, "job_resume")
+	if resumed.Status != StatusSucceeded || resumed.Result.FinalTranscript != "audio" {
+		t.Fatalf("resumed job = %+v", resumed)
+	}
+	dead := waitTerminal(t, m, "job_dead")
+	var jobErr *Error
+	if dead.Status != StatusFailed || !errors.As(dead.Err, &jobErr) || jobErr.Code != "job_interrupted" {
+		t.Fatalf("dead-lettered job = %+v", dead)
+	}
+	for range 2 {
+		select {
+		case <-notified:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("webhook not sent for every recovered job")
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries, _ := journal.Entries(ctx)
+		if len(entries) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("journal not emptied: %+v", entries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubmitPayloadJournalsUntilFinished(t *testing.T) {
+	journal, _ := NewDirJournal(t.TempDir())
+	release := make(chan struct{})
+	run := func(context.Context, []byte, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		<-release
+		return model.PipelineProcessResponse{}, nil
+	}
+	m := NewManager(WithJournal(journal, run, 0))
+	job, err := m.SubmitPayload(context.Background(), writeString("audio"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := journal.Entries(context.Background())
+	if len(entries) != 1 || entries[0].ID != job.ID || entries[0].Attempts != 1 {
+		t.Fatalf("entries while running = %+v", entries)
+	}
+	if payload, _ := journal.Payload(context.Background(), job.ID); string(payload) != "audio" {
+		t.Fatalf("spooled payload = %q", payload)
+	}
+	close(release)
+	waitTerminal(t, m, job.ID)
+	deadline := time.Now().Add(2 * time.Second)
+	for entries, _ := journal.Entries(context.Background()); len(entries) > 0; entries, _ = journal.Entries(context.Background()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("entry not removed after the job finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecoverFailsJobsItCannotJournalAgain(t *testing.T) {
+	dir := t.TempDir()
+	journal, _ := NewDirJournal(dir)
+	ctx := context.Background()
+	_ = journal.Spool(ctx, "job_stuck", writeString("audio"))
+	_ = journal.Append(ctx, JournalEntry{ID: "job_stuck", Attempts: 1})
+	failing := &appendFailingJournal{DirJournal: journal}
+
+	ran := false
+	run := func(context.Context, []byte, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		ran = true
+		return model.PipelineProcessResponse{}, nil
+	}
+	m := NewManager(WithJournal(failing, run, 2))
+	m.Recover(ctx)
+
+	job := waitTerminal(t, m, "job_stuck")
+	var jobErr *Error
+	if job.Status != StatusFailed || !errors.As(job.Err, &jobErr) || jobErr.Code != "job_interrupted" || ran {
+		t.Fatalf("job = %+v, ran = %v", job, ran)
+	}
+}
+
+type appendFailingJournal struct {
+	*DirJournal
+}
+
+func (appendFailingJournal) Append(context.Context, JournalEntry) error {
+	return errors.New("disk full")
+}
+
+func writeString(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
@@ -0,0 +1,356 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+func (s Status) Terminal() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Task runs the actual work for a job and reports stage progress as it goes.
+type Task func(ctx context.Context, onProgress func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error)
+
+// Job is a point-in-time snapshot of an async job.
+type Job struct {
+	ID string
+	// Tenant is the submitting token's tenant; only that tenant can read the
+	// job back.
+	Tenant    string
+	Status    Status
+	Stage     string
+	Progress  float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Result    *model.PipelineProcessResponse
+	Err       error
+	// CallbackURL, when set, is passed to the Notifier once the job finishes.
+	CallbackURL string
+}
+
+type job struct {
+	Job
+	stageStarted time.Time
+	completed    int
+	total        int
+	changed      chan struct{}
+}
+
+// StaleAfter is how long a persisted job may go without an update before it
+// is reported as interrupted; the instance running it has most likely exited.
+const StaleAfter = 15 * time.Minute
+
+const storeTimeout = 5 * time.Second
+
+type Option func(*Manager)
+
+// SubmitOption configures a single job.
+type SubmitOption func(*Job)
+
+func WithCallbackURL(url string) SubmitOption {
+	return func(j *Job) {
+		j.CallbackURL = url
+	}
+}
+
+func WithTenant(tenant string) SubmitOption {
+	return func(j *Job) {
+		j.Tenant = tenant
+	}
+}
+
+func (j Job) Key() Key {
+	return Key{Tenant: j.Tenant, ID: j.ID}
+}
+
+// Notifier is called in its own goroutine when a job with a CallbackURL
+// reaches a terminal status. ctx carries the submitting request's values.
+// With an Outbox store, an error leaves the webhook owed, and RunOutbox
+// calls the Notifier again later.
+type Notifier func(ctx context.Context, job Job) error
+
+type Manager struct {
+	mu         sync.Mutex
+	jobs       map[string]*job
+	estimates  *stageEstimates
+	store      Store
+	queue      Queue
+	notify     Notifier
+	staleAfter time.Duration
+	resultTTL  time.Duration
+
+	journal         Journal
+	journalRun      Runner
+	journalAttempts int
+
+	workers      int
+	queueSize    int
+	pending      chan pendingJob
+	waiting      int
+	poolObserver PoolObserver
+	ctx          context.Context
+	active       sync.WaitGroup
+
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// WithStore persists job snapshots to store and falls back to it for jobs
+// this instance does not know about.
+func WithStore(store Store) Option {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+func WithNotifier(notify Notifier) Option {
+	return func(m *Manager) {
+		m.notify = notify
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) {
+		if logger != nil {
+			m.logger = logger
+		}
+	}
+}
+
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		jobs:       make(map[string]*job),
+		estimates:  newStageEstimates(),
+		staleAfter: StaleAfter,
+		workers:    DefaultWorkers,
+		queueSize:  DefaultQueueSize,
+		ctx:        context.Background(),
+		logger:     slog.Default(),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+	m.startWorkers()
+	return m
+}
+
+// Submit registers a job and queues task for the worker pool, or returns
+// ErrQueueFull. ctx should already be detached from the submitting request;
+// its values (API key, request ID) are kept for upstream calls. cleanup runs
+// once the task has finished, and only if Submit succeeds.
+func (m *Manager) Submit(ctx context.Context, task Task, cleanup func(), opts ...SubmitOption) (Job, error) {
+	if !m.reserve() {
+		return Job{}, ErrQueueFull
+	}
+	j := &job{
+		Job:     m.newJob(opts),
+		changed: make(chan struct{}),
+	}
+	return m.submit(ctx, j, task, cleanup), nil
+}
+
+// submit registers j and hands it to the worker pool; the caller has already
+// reserved its queue slot.
+func (m *Manager) submit(ctx context.Context, j *job, task Task, cleanup func()) Job {
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	snapshot := m.snapshotLocked(j)
+	m.mu.Unlock()
+	m.persist(snapshot)
+
+	m.pending <- pendingJob{ctx: ctx, job: j, task: task, cleanup: cleanup, queuedAt: m.now()}
+	return snapshot
+}
+
+// Get returns the job named by key; a job of another tenant is not found.
+func (m *Manager) Get(key Key) (Job, bool) {
+	job, _, ok := m.Watch(key)
+	return job, ok
+}
+
+// Watch returns the current snapshot and a channel that is closed on the next
+// state change, for streaming progress to clients. Jobs only found in the
+// store get a nil channel; callers re-poll to see their updates.
+func (m *Manager) Watch(key Key) (Job, <-chan struct{}, bool) {
+	m.mu.Lock()
+	j, ok := m.jobs[key.ID]
+	if ok {
+		defer m.mu.Unlock()
+		if j.Tenant != key.Tenant {
+			return Job{}, nil, false
+		}
+		return m.snapshotLocked(j), j.changed, true
+	}
+	m.mu.Unlock()
+
+	job, ok := m.load(key)
+	return job, nil, ok
+}
+
+func (m *Manager) run(ctx context.Context, j *job, task Task, cleanup func()) {
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	m.update(j, func() { j.Status = StatusRunning })
+	started := m.now()
+
+	result, err := task(ctx, func(ev pipeline.ProgressEvent) {
+		m.update(j, func() { m.applyProgressLocked(j, ev) })
+	})
+
+	final := m.apply(j, func() {
+		if err != nil {
+			j.Status = StatusFailed
+			j.Err = err
+			return
+		}
+		j.Status = StatusSucceeded
+		j.Result = &result
+	})
+	var jobErr *Error
+	if err == nil {
+		m.observeJob(m.now().Sub(started), &result)
+	} else if !errors.As(err, &jobErr) {
+		// Only the code is persisted for unmapped errors; keep the detail here.
+		m.logger.Error("job failed", "job_id", j.ID, "error", err)
+	}
+	owed := m.persistFinal(final)
+	if m.notify != nil && final.CallbackURL != "" {
+		go m.deliver(ctx, final, owed)
+	}
+}
+
+func (m *Manager) applyProgressLocked(j *job, ev pipeline.ProgressEvent) {
+	now := m.now()
+	if ev.Stage != j.Stage {
+		j.Stage = ev.Stage
+		j.stageStarted = now
+	}
+	j.completed = ev.Completed
+	j.total = ev.Total
+	if ev.Total > 0 && ev.Completed >= ev.Total {
+		m.estimates.observe(ev.Stage, now.Sub(j.stageStarted))
+	}
+}
+
+func (m *Manager) update(j *job, fn func()) Job {
+	snapshot := m.apply(j, fn)
+	m.persist(snapshot)
+	return snapshot
+}
+
+// apply changes j under the lock and wakes its watchers, without saving it.
+func (m *Manager) apply(j *job, fn func()) Job {
+	m.mu.Lock()
+	fn()
+	j.UpdatedAt = m.now()
+	close(j.changed)
+	j.changed = make(chan struct{})
+	snapshot := m.snapshotLocked(j)
+	m.mu.Unlock()
+	return snapshot
+}
+
+// persist writes a snapshot to the store. Updates for a job all happen on its
+// run goroutine, so snapshots are saved in order.
+func (m *Manager) persist(job Job) {
+	if m.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.store.Save(ctx, toRecord(job)); err != nil {
+		m.logger.Error("job store save failed", "job_id", job.ID, "status", job.Status, "error", err)
+	}
+}
+
+// CheckStore pings the store and the queue, when they are Pingers. It
+// returns nil for in-memory jobs.
+func (m *Manager) CheckStore(ctx context.Context) error {
+	if p, ok := m.store.(Pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("job store: %w", err)
+		}
+	}
+	if p, ok := m.queue.(Pinger); ok && any(m.queue) != any(m.store) {
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("job queue: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) load(key Key) (Job, bool) {
+	if m.store == nil {
+		return Job{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	rec, ok, err := m.store.Load(ctx, key)
+	if err != nil {
+		m.logger.Error("job store load failed", "job_id", key.ID, "error", err)
+		return Job{}, false
+	}
+	if !ok {
+		return Job{}, false
+	}
+	job := fromRecord(rec)
+	if !job.Status.Terminal() && m.now().Sub(job.UpdatedAt) > m.staleAfter {
+		job.Status = StatusFailed
+		job.Err = &Error{APIError: model.APIError{Code: "job_interrupted", Message: "job was interrupted before it finished"}}
+	}
+	return job, true
+}
+
+func (m *Manager) snapshotLocked(j *job) Job {
+	snapshot := j.Job
+	snapshot.Progress = m.progressLocked(j, m.now())
+	return snapshot
+}
+
+func (m *Manager) newJob(opts []SubmitOption) Job {
+	now := m.now()
+	job := Job{
+		ID:        newJobID(),
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&job)
+		}
+	}
+	return job
+}
+
+func newJobID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("job-%d", time.Now().UnixNano())
+	}
+	return "job_" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,194 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+func TestManagerReportsStageProgress(t *testing.T) {
+	m := NewManager()
+	now := time.Unix(0, 0)
+	m.now = func() time.Time { return now }
+
+	step := make(chan pipeline.ProgressEvent)
+	done := make(chan struct{})
+	job, _ := m.Submit(context.Background(), func(_ context.Context, onProgress func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		for ev := range step {
+			onProgress(ev)
+			done <- struct{}{}
+		}
+		return model.PipelineProcessResponse{FinalTranscript: "final"}, nil
+	}, nil)
+
+	send := func(ev pipeline.ProgressEvent) {
+		step <- ev
+		<-done
+	}
+
+	send(pipeline.ProgressEvent{Stage: pipeline.StageTranscription, Completed: 0, Total: 4})
+	send(pipeline.ProgressEvent{Stage: pipeline.StageTranscription, Completed: 2, Total: 4})
+	got, _ := m.Get(job.Key())
+	if got.Status != StatusRunning || got.Stage != pipeline.StageTranscription {
+		t.Fatalf("unexpected job state: %+v", got)
+	}
+	if got.Progress != 0.4 {
+		t.Fatalf("expected 40%% after half the parts, got %v", got.Progress)
+	}
+
+	// Elapsed time over the post-processing estimate interpolates the stage.
+	send(pipeline.ProgressEvent{Stage: pipeline.StagePostProcessing, Completed: 0, Total: 1})
+	now = now.Add(1500 * time.Millisecond)
+	got, _ = m.Get(job.Key())
+	if got.Progress < 0.89 || got.Progress > 0.91 {
+		t.Fatalf("expected ~90%% halfway through post-processing, got %v", got.Progress)
+	}
+
+	close(step)
+	got = waitTerminal(t, m, job.ID)
+	if got.Status != StatusSucceeded || got.Progress != 1 || got.Result.FinalTranscript != "final" {
+		t.Fatalf("unexpected final state: %+v", got)
+	}
+}
+
+func TestManagerRecordsFailure(t *testing.T) {
+	m := NewManager()
+	cleaned := make(chan struct{})
+	job, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{}, errors.New("boom")
+	}, func() { close(cleaned) })
+
+	got := waitTerminal(t, m, job.ID)
+	if got.Status != StatusFailed || got.Err == nil {
+		t.Fatalf("unexpected final state: %+v", got)
+	}
+	<-cleaned
+}
+
+// waitTerminal waits for job id, submitted by tenant (none when omitted).
+func waitTerminal(t *testing.T, m *Manager, id string, tenant ...string) Job {
+	t.Helper()
+	key := Key{ID: id}
+	if len(tenant) > 0 {
+		key.Tenant = tenant[0]
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		job, changed, ok := m.Watch(key)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status.Terminal() {
+			return job
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("job %s did not finish: %+v", id, job)
+		}
+	}
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func (s *memoryStore) Save(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.records == nil {
+		s.records = make(map[string]Record)
+	}
+	s.records[rec.ID] = rec
+	return nil
+}
+
+func (s *memoryStore) Load(_ context.Context, key Key) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[key.ID]
+	if !ok || rec.Tenant != key.Tenant {
+		return Record{}, false, nil
+	}
+	return rec, true, nil
+}
+
+func TestManagerPersistsUnmappedErrorsWithoutTheirText(t *testing.T) {
+	store := &memoryStore{}
+	m := NewManager(WithStore(store))
+	job, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{}, errors.New(`Post "https://upstream.example/v1?key=secret": EOF`)
+	}, nil)
+	waitTerminal(t, m, job.ID)
+
+	rec, ok, _ := store.Load(context.Background(), job.Key())
+	if !ok || rec.Error == nil || rec.Error.Code != "internal_error" || rec.Error.Message != "job failed" {
+		t.Fatalf("persisted record = %+v ok=%v", rec, ok)
+	}
+}
+
+func TestManagerServesPersistedJobsAfterRestart(t *testing.T) {
+	store := &memoryStore{}
+	m := NewManager(WithStore(store))
+	done, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{FinalTranscript: "final"}, nil
+	}, nil)
+	failed, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{}, &Error{APIError: model.APIError{Code: "timeout", Message: "request timed out"}}
+	}, nil)
+	waitTerminal(t, m, done.ID)
+	waitTerminal(t, m, failed.ID)
+
+	restarted := NewManager(WithStore(store))
+	got, ok := restarted.Get(done.Key())
+	if !ok || got.Status != StatusSucceeded || got.Result == nil || got.Result.FinalTranscript != "final" {
+		t.Fatalf("unexpected persisted job: %+v ok=%v", got, ok)
+	}
+	got, ok = restarted.Get(failed.Key())
+	var jobErr *Error
+	if !ok || got.Status != StatusFailed || !errors.As(got.Err, &jobErr) || jobErr.Code != "timeout" {
+		t.Fatalf("unexpected persisted failure: %+v ok=%v", got, ok)
+	}
+}
+
+func TestManagerHidesJobsFromOtherTenants(t *testing.T) {
+	store := &memoryStore{}
+	m := NewManager(WithStore(store))
+	job, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{FinalTranscript: "acme only"}, nil
+	}, nil, WithTenant("acme"))
+	waitTerminal(t, m, job.ID, "acme")
+
+	restarted := NewManager(WithStore(store))
+	for _, manager := range []*Manager{m, restarted} {
+		if _, ok := manager.Get(Key{Tenant: "globex", ID: job.ID}); ok {
+			t.Fatal("job visible to another tenant")
+		}
+		if _, ok := manager.Get(Key{ID: job.ID}); ok {
+			t.Fatal("job visible without a tenant")
+		}
+		if got, ok := manager.Get(Key{Tenant: "acme", ID: job.ID}); !ok || got.Result.FinalTranscript != "acme only" {
+			t.Fatalf("owner lookup = %+v, %v", got, ok)
+		}
+	}
+}
+
+func TestManagerReportsStalePersistedJobAsInterrupted(t *testing.T) {
+	now := time.Unix(10_000, 0)
+	store := &memoryStore{}
+	_ = store.Save(context.Background(), Record{ID: "job_old", Status: StatusRunning, CreatedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Hour)})
+
+	m := NewManager(WithStore(store))
+	m.now = func() time.Time { return now }
+	got, ok := m.Get(Key{ID: "job_old"})
+	var jobErr *Error
+	if !ok || got.Status != StatusFailed || !errors.As(got.Err, &jobErr) || jobErr.Code != "job_interrupted" {
+		t.Fatalf("expected interrupted job, got %+v ok=%v", got, ok)
+	}
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// Outbox is implemented by stores that can save a finished job and record
+// that its webhook is owed in one transaction, so a crash between the two
+// cannot leave a stored result whose webhook is never sent.
+type Outbox interface {
+	// SaveWithEvent saves rec and, atomically with it, an owed webhook that
+	// ClaimEvents hands out from retryAt on.
+	SaveWithEvent(ctx context.Context, rec Record, retryAt time.Time) error
+	// ClaimEvents returns up to limit jobs whose webhook is owed and due by
+	// now, and pushes each one's retry time to now+lease so other replicas
+	// leave it alone while it is delivered.
+	ClaimEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Key, error)
+	// AckEvent drops the owed webhook of the job.
+	AckEvent(ctx context.Context, key Key) error
+}
+
+const (
+	// outboxLease covers a delivery with all its retries; an owed webhook is
+	// not handed out again before it runs out.
+	outboxLease    = 10 * time.Minute
+	outboxInterval = time.Minute
+	outboxBatch    = 100
+)
+
+// outbox returns the store as an Outbox when it is one and webhooks are sent.
+func (m *Manager) outbox() (Outbox, bool) {
+	if m.notify == nil {
+		return nil, false
+	}
+	outbox, ok := m.store.(Outbox)
+	return outbox, ok
+}
+
+// persistFinal saves a finished job, together with its owed webhook when the
+// store has an outbox. It reports whether the webhook went to the outbox.
+func (m *Manager) persistFinal(job Job) bool {
+	outbox, ok := m.outbox()
+	if !ok || job.CallbackURL == "" {
+		m.persist(job)
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := outbox.SaveWithEvent(ctx, toRecord(job), m.now().Add(outboxLease)); err != nil {
+		m.logger.Error("job store save failed", "job_id", job.ID, "status", job.Status, "error", err)
+		return false
+	}
+	return true
+}
+
+// deliver sends the webhook of a finished job and, when it was owed in the
+// outbox, acknowledges it once the notifier reports success.
+func (m *Manager) deliver(ctx context.Context, job Job, owed bool) {
+	if err := m.notify(ctx, job); err != nil || !owed {
+		return
+	}
+	outbox, _ := m.outbox()
+	storeCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := outbox.AckEvent(storeCtx, job.Key()); err != nil {
+		m.logger.Error("job outbox ack failed", "job_id", job.ID, "error", err)
+	}
+}
+
+// RunOutbox redelivers owed webhooks until ctx is cancelled: ones whose
+// delivery failed, and ones a crash or deploy interrupted. It does nothing
+// unless the store is an Outbox and a Notifier is set.
+func (m *Manager) RunOutbox(ctx context.Context) {
+	if _, ok := m.outbox(); !ok {
+		return
+	}
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+	for {
+		m.drainOutbox(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) drainOutbox(ctx context.Context) {
+	outbox, _ := m.outbox()
+	storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+	keys, err := outbox.ClaimEvents(storeCtx, m.now(), outboxLease, outboxBatch)
+	cancel()
+	if err != nil {
+		m.logger.Error("job outbox claim failed", "error", err)
+		return
+	}
+	for _, key := range keys {
+		job, ok := m.load(key)
+		if !ok || !job.Status.Terminal() || job.CallbackURL == "" {
+			// The job expired, or its record is not one that owes a webhook.
+			storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+			_ = outbox.AckEvent(storeCtx, key)
+			cancel()
+			continue
+		}
+		m.logger.Info("job webhook redelivering", "job_id", key.ID)
+		go m.deliver(context.WithoutCancel(ctx), job, true)
+	}
+}
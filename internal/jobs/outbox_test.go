@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+type memoryOutbox struct {
+	memoryStore
+	mu      sync.Mutex
+	retryAt map[Key]time.Time
+}
+
+func (o *memoryOutbox) SaveWithEvent(ctx context.Context, rec Record, retryAt time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.retryAt == nil {
+		o.retryAt = make(map[Key]time.Time)
+	}
+	o.retryAt[rec.Key()] = retryAt
+	return o.Save(ctx, rec)
+}
+
+func (o *memoryOutbox) ClaimEvents(_ context.Context, now time.Time, lease time.Duration, limit int) ([]Key, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var keys []Key
+	for key, at := range o.retryAt {
+		if !at.After(now) && len(keys) < limit {
+			keys = append(keys, key)
+			o.retryAt[key] = now.Add(lease)
+		}
+	}
+	return keys, nil
+}
+
+func (o *memoryOutbox) AckEvent(_ context.Context, key Key) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.retryAt, key)
+	return nil
+}
+
+func (o *memoryOutbox) owed() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.retryAt)
+}
+
+func TestOutboxRedeliversUntilNotifierSucceeds(t *testing.T) {
+	store := &memoryOutbox{}
+	var mu sync.Mutex
+	calls := 0
+	delivered := make(chan struct{}, 2)
+	m := NewManager(WithStore(store), WithNotifier(func(context.Context, Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		delivered <- struct{}{}
+		if calls == 1 {
+			return errors.New("receiver down")
+		}
+		return nil
+	}))
+
+	job, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{FinalTranscript: "final"}, nil
+	}, nil, WithCallbackURL("https://example.com/hook"))
+	waitTerminal(t, m, job.ID)
+	<-delivered
+	if store.owed() != 1 {
+		t.Fatalf("failed delivery should stay owed")
+	}
+
+	// A replica starting after the lease has run out picks the webhook up.
+	restarted := NewManager(WithStore(store), WithNotifier(m.notify))
+	restarted.now = func() time.Time { return time.Now().Add(outboxLease) }
+	restarted.drainOutbox(context.Background())
+	select {
+	case <-delivered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("owed webhook was not redelivered")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for store.owed() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("delivered webhook was not acknowledged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
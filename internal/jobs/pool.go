@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Defaults for the local worker pool; see WithWorkers.
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 100
+)
+
+var ErrQueueFull = errors.New("jobs: queue is full")
+
+// PoolObserver receives worker pool metrics.
+type PoolObserver interface {
+	SetJobQueueDepth(depth int)
+	ObserveJobQueueWait(wait time.Duration)
+}
+
+// WithWorkers bounds how many submitted jobs run at once and how many may
+// wait for a worker; Submit returns ErrQueueFull beyond that, and once the
+// WithContext context is done. Queued jobs from a shared Queue use the same
+// worker count.
+func WithWorkers(workers, queueSize int) Option {
+	return func(m *Manager) {
+		if workers > 0 {
+			m.workers = workers
+		}
+		if queueSize > 0 {
+			m.queueSize = queueSize
+		}
+	}
+}
+
+func WithPoolObserver(observer PoolObserver) Option {
+	return func(m *Manager) {
+		m.poolObserver = observer
+	}
+}
+
+type pendingJob struct {
+	ctx      context.Context
+	job      *job
+	task     Task
+	cleanup  func()
+	queuedAt time.Time
+}
+
+// WithContext ties the worker pool to ctx: once it is done, workers finish
+// the job in hand and stop, and Submit refuses new jobs. Wait reports when
+// the workers have stopped.
+func WithContext(ctx context.Context) Option {
+	return func(m *Manager) {
+		if ctx != nil {
+			m.ctx = ctx
+		}
+	}
+}
+
+func (m *Manager) startWorkers() {
+	// reserve keeps at most queueSize jobs in the channel, so sending to it
+	// never blocks Submit.
+	m.pending = make(chan pendingJob, m.queueSize)
+	m.active.Add(m.workers)
+	for range m.workers {
+		go func() {
+			defer m.active.Done()
+			for m.ctx.Err() == nil {
+				select {
+				case <-m.ctx.Done():
+					return
+				case p := <-m.pending:
+					m.release()
+					m.observeWait(m.now().Sub(p.queuedAt))
+					m.run(p.ctx, p.job, p.task, p.cleanup)
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until the workers started for WithContext and Work have
+// stopped, or ctx is done. Jobs still queued in memory are dropped; a
+// journal brings them back on the next start.
+func (m *Manager) Wait(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		m.active.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve claims a queue slot for a job that is about to be sent to pending;
+// workers release it as they pick the job up.
+func (m *Manager) reserve() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.waiting >= m.queueSize || m.ctx.Err() != nil {
+		return false
+	}
+	m.waiting++
+	m.reportDepthLocked()
+	return true
+}
+
+func (m *Manager) release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waiting--
+	m.reportDepthLocked()
+}
+
+// QueueDepth returns how many submitted jobs are waiting for a worker.
+func (m *Manager) QueueDepth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.waiting
+}
+
+func (m *Manager) reportDepthLocked() {
+	if m.poolObserver != nil {
+		m.poolObserver.SetJobQueueDepth(m.waiting)
+	}
+}
+
+func (m *Manager) observeWait(wait time.Duration) {
+	if m.poolObserver != nil {
+		m.poolObserver.ObserveJobQueueWait(wait)
+	}
+}
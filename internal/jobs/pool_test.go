@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+type poolRecorder struct {
+	mu    sync.Mutex
+	depth []int
+	waits int
+}
+
+func (r *poolRecorder) SetJobQueueDepth(depth int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.depth = append(r.depth, depth)
+}
+
+func (r *poolRecorder) ObserveJobQueueWait(time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waits++
+}
+
+func TestSubmitRejectsWhenQueueIsFull(t *testing.T) {
+	rec := &poolRecorder{}
+	m := NewManager(WithWorkers(1, 1), WithPoolObserver(rec))
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	task := func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		started <- struct{}{}
+		<-release
+		return model.PipelineProcessResponse{}, nil
+	}
+
+	running, err := m.Submit(context.Background(), task, nil)
+	if err != nil {
+		t.Fatalf("first Submit: %v", err)
+	}
+	<-started
+	queued, err := m.Submit(context.Background(), task, nil)
+	if err != nil {
+		t.Fatalf("second Submit: %v", err)
+	}
+	if depth := m.QueueDepth(); depth != 1 {
+		t.Fatalf("QueueDepth = %d, want 1", depth)
+	}
+	cleaned := false
+	if _, err := m.Submit(context.Background(), task, func() { cleaned = true }); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("third Submit err = %v, want ErrQueueFull", err)
+	}
+	if cleaned {
+		t.Fatal("cleanup ran for a rejected job")
+	}
+	if got, _ := m.Get(queued.Key()); got.Status != StatusQueued {
+		t.Fatalf("second job status = %s, want queued", got.Status)
+	}
+
+	close(release)
+	waitTerminal(t, m, running.ID)
+	waitTerminal(t, m, queued.ID)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.waits != 2 {
+		t.Fatalf("observed %d waits, want 2", rec.waits)
+	}
+	if len(rec.depth) == 0 || rec.depth[len(rec.depth)-1] != 0 {
+		t.Fatalf("depth = %v, want to end at 0", rec.depth)
+	}
+}
+
+func TestWorkersStopWithTheirContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(WithContext(ctx), WithWorkers(1, 2))
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	task := func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		started <- struct{}{}
+		<-release
+		return model.PipelineProcessResponse{}, nil
+	}
+	running, _ := m.Submit(context.Background(), task, nil)
+	<-started
+	queued, _ := m.Submit(context.Background(), task, nil)
+
+	cancel()
+	if _, err := m.Submit(context.Background(), task, nil); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit after cancel err = %v, want ErrQueueFull", err)
+	}
+	short, cancelWait := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelWait()
+	if err := m.Wait(short); err == nil {
+		t.Fatal("Wait returned while a job was running")
+	}
+
+	close(release)
+	if err := m.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if got, _ := m.Get(running.Key()); got.Status != StatusSucceeded {
+		t.Fatalf("running job status = %s, want succeeded", got.Status)
+	}
+	if got, _ := m.Get(queued.Key()); got.Status != StatusQueued {
+		t.Fatalf("queued job status = %s, want queued", got.Status)
+	}
+}
@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"echoflow/internal/pipeline"
+)
+
+const (
+	// transcriptionWeight is the share of overall progress attributed to the
+	// transcription stage; post-processing covers the remainder.
+	transcriptionWeight = 0.8
+	// maxStageEstimate keeps time-based interpolation from claiming a stage is
+	// finished before it reports completion.
+	maxStageEstimate  = 0.95
+	estimateSmoothing = 0.2
+)
+
+var defaultStageEstimates = map[string]time.Duration{
+	pipeline.StageTranscription:  10 * time.Second,
+	pipeline.StagePostProcessing: 3 * time.Second,
+}
+
+// stageEstimates tracks an exponentially weighted moving average of observed
+// stage durations, used to interpolate progress between stage events.
+// It also averages whole jobs, for admission estimates.
+type stageEstimates struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+	perJob    time.Duration
+	realtime  float64
+}
+
+func newStageEstimates() *stageEstimates {
+	durations := make(map[string]time.Duration, len(defaultStageEstimates))
+	var perJob time.Duration
+	for stage, d := range defaultStageEstimates {
+		durations[stage] = d
+		perJob += d
+	}
+	return &stageEstimates{durations: durations, perJob: perJob, realtime: defaultRealtimeFactor}
+}
+
+func (e *stageEstimates) observeJob(d, audio time.Duration) {
+	if d <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.perJob = time.Duration((1-estimateSmoothing)*float64(e.perJob) + estimateSmoothing*float64(d))
+	if audio > 0 {
+		e.realtime = (1-estimateSmoothing)*e.realtime + estimateSmoothing*float64(d)/float64(audio)
+	}
+}
+
+func (e *stageEstimates) job() (perJob time.Duration, realtime float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.perJob, e.realtime
+}
+
+func (e *stageEstimates) observe(stage string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prev, ok := e.durations[stage]
+	if !ok {
+		e.durations[stage] = d
+		return
+	}
+	e.durations[stage] = time.Duration((1-estimateSmoothing)*float64(prev) + estimateSmoothing*float64(d))
+}
+
+func (e *stageEstimates) get(stage string) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.durations[stage]
+}
+
+// progressLocked returns overall completion in [0, 1]. Within a stage it uses
+// the larger of reported part completion and elapsed time over the estimate.
+func (m *Manager) progressLocked(j *job, now time.Time) float64 {
+	switch {
+	case j.Status == StatusSucceeded:
+		return 1
+	case j.Status != StatusRunning || j.Stage == "":
+		return 0
+	}
+
+	stageFraction := 0.0
+	if j.total > 0 {
+		stageFraction = float64(j.completed) / float64(j.total)
+	}
+	if stageFraction < 1 {
+		if estimate := m.estimates.get(j.Stage); estimate > 0 {
+			elapsed := now.Sub(j.stageStarted)
+			stageFraction = max(stageFraction, min(maxStageEstimate, float64(elapsed)/float64(estimate)))
+		}
+	}
+
+	if j.Stage == pipeline.StageTranscription {
+		return transcriptionWeight * stageFraction
+	}
+	return transcriptionWeight + (1-transcriptionWeight)*stageFraction
+}
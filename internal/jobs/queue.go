@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+var ErrNoQueue = errors.New("jobs: no queue configured")
+
+// Queue hands serialized jobs to whichever replica dequeues them first.
+type Queue interface {
+	Enqueue(ctx context.Context, key Key, payload []byte) error
+	// Dequeue blocks until a job is available or ctx is done.
+	Dequeue(ctx context.Context) (key Key, payload []byte, err error)
+}
+
+// Runner executes a queued payload on the worker that dequeued it.
+type Runner func(ctx context.Context, payload []byte, onProgress func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error)
+
+// WithQueue lets jobs be enqueued for any replica to run. A queue needs a
+// shared Store so the submitting replica can report on the job.
+func WithQueue(queue Queue) Option {
+	return func(m *Manager) {
+		m.queue = queue
+	}
+}
+
+func (m *Manager) HasQueue() bool {
+	return m.queue != nil && m.store != nil
+}
+
+// Enqueue records a queued job and hands payload to the queue. The job is
+// tracked by whichever replica's Work loop picks it up.
+func (m *Manager) Enqueue(ctx context.Context, payload []byte, opts ...SubmitOption) (Job, error) {
+	if !m.HasQueue() {
+		return Job{}, ErrNoQueue
+	}
+	job := m.newJob(opts)
+	if err := m.store.Save(ctx, toRecord(job)); err != nil {
+		return Job{}, err
+	}
+	if err := m.queue.Enqueue(ctx, job.Key(), payload); err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// Work runs up to WithWorkers queued jobs at a time until ctx is cancelled.
+// Running jobs are detached from ctx so shutdown does not abort them midway.
+func (m *Manager) Work(ctx context.Context, run Runner) {
+	if m.queue == nil {
+		return
+	}
+	done := make(chan struct{})
+	m.active.Add(m.workers)
+	for range m.workers {
+		go func() {
+			defer m.active.Done()
+			defer func() { done <- struct{}{} }()
+			m.workLoop(ctx, run)
+		}()
+	}
+	for range m.workers {
+		<-done
+	}
+}
+
+func (m *Manager) workLoop(ctx context.Context, run Runner) {
+	for {
+		key, payload, err := m.queue.Dequeue(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			m.logger.Error("job queue dequeue failed", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		m.runQueued(context.WithoutCancel(ctx), key, payload, run)
+	}
+}
+
+func (m *Manager) runQueued(ctx context.Context, key Key, payload []byte, run Runner) {
+	id := key.ID
+	createdAt, callbackURL := m.now(), ""
+	if rec, ok, err := m.store.Load(ctx, key); err == nil && ok {
+		createdAt, callbackURL = rec.CreatedAt, rec.CallbackURL
+		m.observeWait(m.now().Sub(createdAt))
+	}
+	j := &job{
+		Job: Job{
+			ID:          id,
+			Tenant:      key.Tenant,
+			Status:      StatusQueued,
+			CreatedAt:   createdAt,
+			UpdatedAt:   m.now(),
+			CallbackURL: callbackURL,
+		},
+		changed: make(chan struct{}),
+	}
+	m.mu.Lock()
+	m.jobs[id] = j
+	m.mu.Unlock()
+
+	// The queue has handed the job over, so from here on only the journal
+	// can bring it back if this process dies.
+	var cleanup func()
+	if m.journal != nil {
+		entry := JournalEntry{ID: id, Tenant: key.Tenant, CreatedAt: createdAt, CallbackURL: callbackURL, Attempts: 1}
+		spool := func(w io.Writer) error {
+			_, err := w.Write(payload)
+			return err
+		}
+		if m.spoolJournal(id, spool) == nil && m.appendJournal(entry) == nil {
+			cleanup = m.removeJournal(id)
+		}
+	}
+	m.run(ctx, j, func(ctx context.Context, onProgress func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return run(ctx, payload, onProgress)
+	}, cleanup)
+}
@@ -0,0 +1,248 @@
+// Package redisstore implements jobs.Store and jobs.Queue on Redis so several
+// replicas can share job state and work.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix        = "echoflow:jobs:"
+	queueKey         = keyPrefix + "queue"
+	outboxKey        = keyPrefix + "outbox"
+	defaultRecordTTL = 24 * time.Hour
+	// payloadTTL drops a queued payload once its job would be reported as
+	// interrupted anyway, so no worker runs a job its submitter gave up on.
+	payloadTTL = jobs.StaleAfter
+	// dequeueBlock bounds each BRPOP so workers notice shutdown promptly.
+	dequeueBlock = 5 * time.Second
+)
+
+type Client struct {
+	rdb       *redis.Client
+	recordTTL time.Duration
+}
+
+type Option func(*Client)
+
+// WithRecordTTL sets how long job records live after their last update;
+// zero keeps them forever.
+func WithRecordTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		if ttl >= 0 {
+			c.recordTTL = ttl
+		}
+	}
+}
+
+// Open connects to the server at url (redis://[:password@]host:port/db).
+func Open(ctx context.Context, url string, opts ...Option) (*Client, error) {
+	redisOpts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	// Let context cancellation interrupt blocking commands such as BRPOP.
+	redisOpts.ContextTimeoutEnabled = true
+	rdb := redis.NewClient(redisOpts)
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		_ = rdb.Close()
+		return nil, fmt.Errorf("redis ping: %w", err)
+	}
+	c := &Client{rdb: rdb, recordTTL: defaultRecordTTL}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.rdb.Close()
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	return c.rdb.Ping(ctx).Err()
+}
+
+type record struct {
+	ID          string                         `json:"id"`
+	Tenant      string                         `json:"tenant,omitempty"`
+	Status      string                         `json:"status"`
+	Stage       string                         `json:"stage,omitempty"`
+	Progress    float64                        `json:"progress"`
+	CreatedAt   time.Time                      `json:"created_at"`
+	UpdatedAt   time.Time                      `json:"updated_at"`
+	Result      *model.PipelineProcessResponse `json:"result,omitempty"`
+	Error       *model.APIError                `json:"error,omitempty"`
+	CallbackURL string                         `json:"callback_url,omitempty"`
+}
+
+func (c *Client) Save(ctx context.Context, rec jobs.Record) error {
+	data, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	return c.rdb.Set(ctx, recordKey(rec.Key()), data, c.recordTTL).Err()
+}
+
+// SaveWithEvent implements jobs.Outbox. The owed webhook is a member of a
+// sorted set scored by its retry time, written in the same MULTI as the
+// record.
+func (c *Client) SaveWithEvent(ctx context.Context, rec jobs.Record, retryAt time.Time) error {
+	data, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, recordKey(rec.Key()), data, c.recordTTL)
+		pipe.ZAdd(ctx, outboxKey, redis.Z{Score: float64(retryAt.UnixMilli()), Member: member(rec.Key())})
+		return nil
+	})
+	return err
+}
+
+// claimScript moves due outbox members to now+lease in one step, so two
+// replicas never claim the same webhook.
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+for _, id in ipairs(ids) do
+	redis.call('ZADD', KEYS[1], ARGV[2], id)
+end
+return ids`)
+
+// ClaimEvents implements jobs.Outbox.
+func (c *Client) ClaimEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]jobs.Key, error) {
+	members, err := claimScript.Run(ctx, c.rdb, []string{outboxKey}, now.UnixMilli(), now.Add(lease).UnixMilli(), limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]jobs.Key, len(members))
+	for i, m := range members {
+		keys[i] = parseMember(m)
+	}
+	return keys, nil
+}
+
+// AckEvent implements jobs.Outbox.
+func (c *Client) AckEvent(ctx context.Context, key jobs.Key) error {
+	return c.rdb.ZRem(ctx, outboxKey, member(key)).Err()
+}
+
+func encodeRecord(rec jobs.Record) ([]byte, error) {
+	return json.Marshal(record{
+		ID:          rec.ID,
+		Tenant:      rec.Tenant,
+		Status:      string(rec.Status),
+		Stage:       rec.Stage,
+		Progress:    rec.Progress,
+		CreatedAt:   rec.CreatedAt,
+		UpdatedAt:   rec.UpdatedAt,
+		Result:      rec.Result,
+		Error:       rec.Error,
+		CallbackURL: rec.CallbackURL,
+	})
+}
+
+// Load only finds records saved under key.Tenant, since the tenant is part of
+// the Redis key.
+func (c *Client) Load(ctx context.Context, key jobs.Key) (jobs.Record, bool, error) {
+	data, err := c.rdb.Get(ctx, recordKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return jobs.Record{}, false, nil
+	}
+	if err != nil {
+		return jobs.Record{}, false, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return jobs.Record{}, false, fmt.Errorf("decode job record: %w", err)
+	}
+	return jobs.Record{
+		ID:          rec.ID,
+		Tenant:      rec.Tenant,
+		Status:      jobs.Status(rec.Status),
+		Stage:       rec.Stage,
+		Progress:    rec.Progress,
+		CreatedAt:   rec.CreatedAt,
+		UpdatedAt:   rec.UpdatedAt,
+		Result:      rec.Result,
+		Error:       rec.Error,
+		CallbackURL: rec.CallbackURL,
+	}, true, nil
+}
+
+// Enqueue stores the payload under its own key and pushes the job ID, so the
+// queue list itself stays small.
+func (c *Client) Enqueue(ctx context.Context, key jobs.Key, payload []byte) error {
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, payloadKey(key.ID), payload, payloadTTL)
+		pipe.LPush(ctx, queueKey, member(key))
+		return nil
+	})
+	return err
+}
+
+func (c *Client) Dequeue(ctx context.Context) (jobs.Key, []byte, error) {
+	for {
+		res, err := c.rdb.BRPop(ctx, dequeueBlock, queueKey).Result()
+		if errors.Is(err, redis.Nil) {
+			if ctx.Err() != nil {
+				return jobs.Key{}, nil, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return jobs.Key{}, nil, err
+		}
+		key := parseMember(res[1])
+		payload, err := c.rdb.GetDel(ctx, payloadKey(key.ID)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// The payload expired before a worker got to it.
+			_ = c.Save(ctx, jobs.Record{
+				ID:        key.ID,
+				Tenant:    key.Tenant,
+				Status:    jobs.StatusFailed,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+				Error:     &model.APIError{Code: "job_expired", Message: "job expired before a worker picked it up"},
+			})
+			continue
+		}
+		if err != nil {
+			return jobs.Key{}, nil, err
+		}
+		return key, payload, nil
+	}
+}
+
+// member encodes key for queue and outbox entries and record keys. Jobs
+// without a tenant keep the bare ID, as before tenants were stored. Job IDs
+// never contain "/", so the last one separates the tenant.
+func member(key jobs.Key) string {
+	if key.Tenant == "" {
+		return key.ID
+	}
+	return key.Tenant + "/" + key.ID
+}
+
+func parseMember(s string) jobs.Key {
+	i := strings.LastIndex(s, "/")
+	if i < 0 {
+		return jobs.Key{ID: s}
+	}
+	return jobs.Key{Tenant: s[:i], ID: s[i+1:]}
+}
+
+func recordKey(key jobs.Key) string { return keyPrefix + "record:" + member(key) }
+func payloadKey(id string) string   { return keyPrefix + "payload:" + id }
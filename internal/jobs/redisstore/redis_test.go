@@ -0,0 +1,146 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	c, err := Open(context.Background(), "redis://"+mr.Addr())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c, mr
+}
+
+func TestStoreRoundTripsRecords(t *testing.T) {
+	c, mr := newTestClient(t)
+	ctx := context.Background()
+	created := time.Unix(1_700_000_000, 0).UTC()
+
+	rec := jobs.Record{
+		ID:        "job_1",
+		Status:    jobs.StatusSucceeded,
+		Progress:  1,
+		CreatedAt: created,
+		UpdatedAt: created.Add(time.Second),
+		Result:    &model.PipelineProcessResponse{FinalTranscript: "final"},
+	}
+	if err := c.Save(ctx, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if ttl := mr.TTL(recordKey(rec.Key())); ttl != defaultRecordTTL {
+		t.Fatalf("expected record TTL %v, got %v", defaultRecordTTL, ttl)
+	}
+
+	got, ok, err := c.Load(ctx, rec.Key())
+	if err != nil || !ok {
+		t.Fatalf("Load: ok=%v err=%v", ok, err)
+	}
+	if got.Status != jobs.StatusSucceeded || !got.CreatedAt.Equal(created) || got.Result.FinalTranscript != "final" {
+		t.Fatalf("unexpected record: %+v", got)
+	}
+	if _, ok, err := c.Load(ctx, jobs.Key{ID: "missing"}); ok || err != nil {
+		t.Fatalf("expected missing record, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestLoadIsScopedByTenant(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+
+	rec := jobs.Record{ID: "job_1", Tenant: "acme", Status: jobs.StatusSucceeded, CreatedAt: now, UpdatedAt: now}
+	if err := c.Save(ctx, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, tenant := range []string{"", "globex", "acme/job_1"} {
+		if _, ok, err := c.Load(ctx, jobs.Key{Tenant: tenant, ID: "job_1"}); ok || err != nil {
+			t.Fatalf("tenant %q loaded acme's job: ok=%v err=%v", tenant, ok, err)
+		}
+	}
+	if got, ok, _ := c.Load(ctx, rec.Key()); !ok || got.Tenant != "acme" {
+		t.Fatalf("owner Load = %+v ok=%v", got, ok)
+	}
+}
+
+func TestQueueHandsPayloadToOneWorker(t *testing.T) {
+	c, mr := newTestClient(t)
+	ctx := context.Background()
+
+	if err := c.Enqueue(ctx, jobs.Key{Tenant: "acme", ID: "job_1"}, []byte("payload-1")); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := c.Enqueue(ctx, jobs.Key{Tenant: "acme", ID: "job_2"}, []byte("payload-2")); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	key, payload, err := c.Dequeue(ctx)
+	if err != nil || key != (jobs.Key{Tenant: "acme", ID: "job_1"}) || string(payload) != "payload-1" {
+		t.Fatalf("unexpected dequeue: key=%+v payload=%q err=%v", key, payload, err)
+	}
+	if mr.Exists(payloadKey("job_1")) {
+		t.Fatal("expected payload to be removed once dequeued")
+	}
+
+	// An expired payload marks the job failed and moves on to the next one.
+	mr.Del(payloadKey("job_2"))
+	if err := c.Enqueue(ctx, jobs.Key{ID: "job_3"}, []byte("payload-3")); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	key, _, err = c.Dequeue(ctx)
+	if err != nil || key.ID != "job_3" {
+		t.Fatalf("expected job_3, got %+v err=%v", key, err)
+	}
+	rec, ok, _ := c.Load(ctx, jobs.Key{Tenant: "acme", ID: "job_2"})
+	if !ok || rec.Status != jobs.StatusFailed || rec.Error.Code != "job_expired" {
+		t.Fatalf("expected expired job_2, got %+v", rec)
+	}
+}
+
+func TestDequeueReturnsWhenContextDone(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := c.Dequeue(ctx); err == nil {
+		t.Fatal("expected error once context is done")
+	}
+}
+
+func TestOutboxClaimsEachEventOncePerLease(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+
+	rec := jobs.Record{ID: "job_1", Tenant: "acme", Status: jobs.StatusFailed, CreatedAt: now, UpdatedAt: now, CallbackURL: "https://example.com/hook"}
+	if err := c.SaveWithEvent(ctx, rec, now); err != nil {
+		t.Fatalf("SaveWithEvent: %v", err)
+	}
+	if _, ok, _ := c.Load(ctx, rec.Key()); !ok {
+		t.Fatalf("record not saved")
+	}
+	ids, err := c.ClaimEvents(ctx, now, time.Minute, 10)
+	if err != nil || len(ids) != 1 || ids[0] != rec.Key() {
+		t.Fatalf("ClaimEvents = %v, %v", ids, err)
+	}
+	if ids, _ := c.ClaimEvents(ctx, now.Add(time.Second), time.Minute, 10); len(ids) != 0 {
+		t.Fatalf("claimed again within the lease: %v", ids)
+	}
+	if ids, _ := c.ClaimEvents(ctx, now.Add(2*time.Minute), time.Minute, 10); len(ids) != 1 {
+		t.Fatalf("not reclaimed after the lease: %v", ids)
+	}
+	_ = c.AckEvent(ctx, rec.Key())
+	if ids, _ := c.ClaimEvents(ctx, now.Add(time.Hour), time.Minute, 10); len(ids) != 0 {
+		t.Fatalf("acked event claimed: %v", ids)
+	}
+}
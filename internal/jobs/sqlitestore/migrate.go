@@ -0,0 +1,202 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations live in migrations/ as NNNN_name.sql and are applied in version
+// order, each in its own transaction. Never edit a migration that has
+// shipped; add a new one.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrPendingMigrations is returned by Open WithAutoMigrate(false) when the
+// database is behind the embedded migrations.
+var ErrPendingMigrations = errors.New("job database schema is out of date; run `echoflow-api migrate`")
+
+type Migration struct {
+	Version int
+	Name    string
+	sql     string
+}
+
+// legacyUpgrades brought databases created before migrations existed up to
+// date. They are replayed once on such a database, which is then recorded as
+// being at version 1. A "duplicate column" error means an upgrade was already
+// applied.
+var legacyUpgrades = []string{
+	`ALTER TABLE jobs ADD COLUMN callback_url TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS jobs_updated_at ON jobs (updated_at)`,
+	`CREATE TABLE IF NOT EXISTS job_outbox (
+	job_id   TEXT PRIMARY KEY,
+	retry_at INTEGER NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS job_outbox_retry_at ON job_outbox (retry_at)`,
+	`ALTER TABLE jobs ADD COLUMN tenant TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS jobs_tenant_id ON jobs (tenant, id)`,
+	`ALTER TABLE job_outbox ADD COLUMN tenant TEXT NOT NULL DEFAULT ''`,
+}
+
+const versionsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]Migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var all []Migration
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		prefix, label, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version number", name)
+		}
+		data, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, Migration{Version: version, Name: label, sql: string(data)})
+	}
+	slices.SortFunc(all, func(a, b Migration) int { return a.Version - b.Version })
+	for i := 1; i < len(all); i++ {
+		if all[i].Version == all[i-1].Version {
+			return nil, fmt.Errorf("two migrations have version %d", all[i].Version)
+		}
+	}
+	return all, nil
+}
+
+// Migrate opens the database at path, applies its pending migrations and
+// returns them. It is what `echoflow-api migrate` runs.
+func Migrate(ctx context.Context, path string) ([]Migration, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+	return migrate(ctx, db)
+}
+
+func migrate(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	if err := adoptLegacy(ctx, db); err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, versionsTable); err != nil {
+		return nil, err
+	}
+	todo, err := pending(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, m := range todo {
+		ok, err := apply(ctx, db, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// pending returns the migrations the database has not had yet. It does not
+// write, so it is safe to call without migrating.
+func pending(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := tableExists(ctx, db, "schema_migrations"); err != nil || !ok {
+		return all, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	done := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		done[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(all, func(m Migration) bool { return done[m.Version] }), nil
+}
+
+// apply runs one migration and records it, unless another process got there
+// first.
+func apply(ctx context.Context, db *sql.DB, m Migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM schema_migrations WHERE version = ?`, m.Version).Scan(&exists)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UnixMilli()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func adoptLegacy(ctx context.Context, db *sql.DB) error {
+	hasVersions, err := tableExists(ctx, db, "schema_migrations")
+	if err != nil || hasVersions {
+		return err
+	}
+	hasJobs, err := tableExists(ctx, db, "jobs")
+	if err != nil || !hasJobs {
+		return err
+	}
+	for _, stmt := range legacyUpgrades {
+		if _, err := db.ExecContext(ctx, stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("upgrade pre-migration schema: %w", err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, versionsTable); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (1, 'initial', ?)`, time.Now().UnixMilli())
+	return err
+}
+
+func tableExists(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,69 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"echoflow/internal/jobs"
+)
+
+func TestOpenWithoutAutoMigrateWaitsForMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	ctx := context.Background()
+	if _, err := Open(path, WithAutoMigrate(false)); !errors.Is(err, ErrPendingMigrations) {
+		t.Fatalf("Open before migrating: %v, want ErrPendingMigrations", err)
+	}
+
+	all, _ := Migrations()
+	applied, err := Migrate(ctx, path)
+	if err != nil || len(applied) != len(all) {
+		t.Fatalf("Migrate = %d applied, %v; want %d", len(applied), err, len(all))
+	}
+	if applied, err := Migrate(ctx, path); err != nil || len(applied) != 0 {
+		t.Fatalf("second Migrate = %v, %v; want nothing to do", applied, err)
+	}
+	store, err := Open(path, WithAutoMigrate(false))
+	if err != nil {
+		t.Fatalf("Open after migrating: %v", err)
+	}
+	_ = store.Close()
+}
+
+func TestMigrateAdoptsDatabaseFromBeforeMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	db, err := openDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first shipped schema, with one job in it.
+	if _, err := db.Exec(`
+CREATE TABLE jobs (
+	id         TEXT PRIMARY KEY,
+	status     TEXT NOT NULL,
+	stage      TEXT NOT NULL DEFAULT '',
+	progress   REAL NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	result     TEXT,
+	error      TEXT
+);
+INSERT INTO jobs (id, status, created_at, updated_at) VALUES ('job_old', 'succeeded', 1, 1);`); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if rec, ok, err := store.Load(context.Background(), jobs.Key{ID: "job_old"}); err != nil || !ok || rec.Status != jobs.StatusSucceeded {
+		t.Fatalf("Load job_old = %+v ok=%v err=%v", rec, ok, err)
+	}
+	var version int
+	if err := store.db.QueryRow(`SELECT max(version) FROM schema_migrations`).Scan(&version); err != nil || version == 0 {
+		t.Fatalf("schema version = %d, %v", version, err)
+	}
+}
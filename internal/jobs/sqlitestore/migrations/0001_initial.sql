@@ -0,0 +1,25 @@
+-- The schema as it stood when migrations were introduced. Databases created
+-- before then are brought to this shape by legacyUpgrades instead.
+CREATE TABLE IF NOT EXISTS jobs (
+	id           TEXT PRIMARY KEY,
+	status       TEXT NOT NULL,
+	stage        TEXT NOT NULL DEFAULT '',
+	progress     REAL NOT NULL DEFAULT 0,
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL,
+	result       TEXT,
+	error        TEXT,
+	callback_url TEXT NOT NULL DEFAULT '',
+	tenant       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS jobs_updated_at ON jobs (updated_at);
+CREATE INDEX IF NOT EXISTS jobs_tenant_id ON jobs (tenant, id);
+
+CREATE TABLE IF NOT EXISTS job_outbox (
+	job_id   TEXT PRIMARY KEY,
+	retry_at INTEGER NOT NULL,
+	tenant   TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS job_outbox_retry_at ON job_outbox (retry_at);
@@ -0,0 +1,235 @@
+// Package sqlitestore implements jobs.Store on an embedded SQLite database.
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// execer is what Save needs, from the database or a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+type Option func(*options)
+
+type options struct {
+	autoMigrate bool
+}
+
+// WithAutoMigrate controls whether Open applies pending migrations (the
+// default). Without it, Open fails with ErrPendingMigrations until Migrate
+// has been run, so schema changes can be rolled out as a separate step.
+func WithAutoMigrate(enabled bool) Option {
+	return func(o *options) {
+		o.autoMigrate = enabled
+	}
+}
+
+// Open opens (creating if needed) the database at path and brings its schema
+// up to date.
+func Open(path string, opts ...Option) (*Store, error) {
+	o := options{autoMigrate: true}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if o.autoMigrate {
+		_, err = migrate(ctx, db)
+	} else if todo, perr := pending(ctx, db); perr != nil {
+		err = perr
+	} else if len(todo) > 0 {
+		err = fmt.Errorf("%w (%d pending, next is %04d_%s)", ErrPendingMigrations, len(todo), todo[0].Version, todo[0].Name)
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate jobs schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// openDB uses WAL mode so status reads proceed while a job is being written.
+func openDB(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL", path)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite serialises writers; a single connection avoids SQLITE_BUSY churn.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Ping runs a query rather than only opening a connection, so a locked or
+// unreadable database file is reported too.
+func (s *Store) Ping(ctx context.Context) error {
+	var n int
+	return s.db.QueryRowContext(ctx, `SELECT count(*) FROM schema_migrations`).Scan(&n)
+}
+
+func (s *Store) Save(ctx context.Context, rec jobs.Record) error {
+	return save(ctx, s.db, rec)
+}
+
+// SaveWithEvent implements jobs.Outbox.
+func (s *Store) SaveWithEvent(ctx context.Context, rec jobs.Record, retryAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := save(ctx, tx, rec); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO job_outbox (job_id, tenant, retry_at) VALUES (?, ?, ?)`, rec.ID, rec.Tenant, retryAt.UnixMilli()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClaimEvents implements jobs.Outbox.
+func (s *Store) ClaimEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]jobs.Key, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	rows, err := tx.QueryContext(ctx, `SELECT tenant, job_id FROM job_outbox WHERE retry_at <= ? ORDER BY retry_at LIMIT ?`, now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	var keys []jobs.Key
+	for rows.Next() {
+		var key jobs.Key
+		if err := rows.Scan(&key.Tenant, &key.ID); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, `UPDATE job_outbox SET retry_at = ? WHERE job_id = ?`, now.Add(lease).UnixMilli(), key.ID); err != nil {
+			return nil, err
+		}
+	}
+	return keys, tx.Commit()
+}
+
+// AckEvent implements jobs.Outbox.
+func (s *Store) AckEvent(ctx context.Context, key jobs.Key) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM job_outbox WHERE tenant = ? AND job_id = ?`, key.Tenant, key.ID)
+	return err
+}
+
+func save(ctx context.Context, db execer, rec jobs.Record) error {
+	result, err := marshalNullable(rec.Result)
+	if err != nil {
+		return err
+	}
+	jobErr, err := marshalNullable(rec.Error)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+INSERT INTO jobs (id, tenant, status, stage, progress, created_at, updated_at, result, error, callback_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+	status = excluded.status,
+	stage = excluded.stage,
+	progress = excluded.progress,
+	updated_at = excluded.updated_at,
+	result = excluded.result,
+	error = excluded.error
+WHERE jobs.tenant = excluded.tenant`,
+		rec.ID, rec.Tenant, string(rec.Status), rec.Stage, rec.Progress,
+		rec.CreatedAt.UnixMilli(), rec.UpdatedAt.UnixMilli(), result, jobErr, rec.CallbackURL,
+	)
+	return err
+}
+
+// Load only matches rows saved under key.Tenant.
+func (s *Store) Load(ctx context.Context, key jobs.Key) (jobs.Record, bool, error) {
+	var (
+		rec                  jobs.Record
+		status               string
+		createdAt, updatedAt int64
+		result, jobErr       sql.NullString
+	)
+	err := s.db.QueryRowContext(ctx, `
+SELECT id, tenant, status, stage, progress, created_at, updated_at, result, error, callback_url
+FROM jobs WHERE tenant = ? AND id = ?`, key.Tenant, key.ID).Scan(
+		&rec.ID, &rec.Tenant, &status, &rec.Stage, &rec.Progress, &createdAt, &updatedAt, &result, &jobErr, &rec.CallbackURL,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return jobs.Record{}, false, nil
+	}
+	if err != nil {
+		return jobs.Record{}, false, err
+	}
+
+	rec.Status = jobs.Status(status)
+	rec.CreatedAt = time.UnixMilli(createdAt)
+	rec.UpdatedAt = time.UnixMilli(updatedAt)
+	if result.Valid {
+		rec.Result = new(model.PipelineProcessResponse)
+		if err := json.Unmarshal([]byte(result.String), rec.Result); err != nil {
+			return jobs.Record{}, false, fmt.Errorf("decode job result: %w", err)
+		}
+	}
+	if jobErr.Valid {
+		rec.Error = new(model.APIError)
+		if err := json.Unmarshal([]byte(jobErr.String), rec.Error); err != nil {
+			return jobs.Record{}, false, fmt.Errorf("decode job error: %w", err)
+		}
+	}
+	return rec, true, nil
+}
+
+// DeleteExpired implements jobs.Expirer. Only finished jobs are deleted: a
+// long job that has not reported progress for a while is still running.
+func (s *Store) DeleteExpired(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM jobs WHERE updated_at < ? AND status IN (?, ?)`,
+		cutoff.UnixMilli(), string(jobs.StatusSucceeded), string(jobs.StatusFailed))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func marshalNullable[T any](value *T) (sql.NullString, error) {
+	if value == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
@@ -0,0 +1,174 @@
+package sqlitestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"echoflow/internal/jobs"
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+func TestStoreRoundTripsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	ctx := context.Background()
+	created := time.UnixMilli(1_700_000_000_000)
+
+	rec := jobs.Record{ID: "job_1", Status: jobs.StatusRunning, Stage: pipeline.StageTranscription, Progress: 0.4, CreatedAt: created, UpdatedAt: created}
+	if err := store.Save(ctx, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	rec.Status = jobs.StatusSucceeded
+	rec.Progress = 1
+	rec.UpdatedAt = created.Add(time.Second)
+	rec.Result = &model.PipelineProcessResponse{FinalTranscript: "final", TimingsMS: model.PipelineTimings{Total: 1000}}
+	if err := store.Save(ctx, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Save(ctx, jobs.Record{ID: "job_2", Status: jobs.StatusFailed, CreatedAt: created, UpdatedAt: created,
+		Error: &model.APIError{Code: "timeout", Message: "request timed out"}}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	_ = store.Close()
+
+	// Reopen to check the data survives a restart.
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	got, ok, err := store.Load(ctx, jobs.Key{ID: "job_1"})
+	if err != nil || !ok {
+		t.Fatalf("Load job_1: ok=%v err=%v", ok, err)
+	}
+	if got.Status != jobs.StatusSucceeded || got.Progress != 1 || !got.UpdatedAt.Equal(rec.UpdatedAt) || !got.CreatedAt.Equal(created) {
+		t.Fatalf("unexpected record: %+v", got)
+	}
+	if got.Result == nil || got.Result.FinalTranscript != "final" || got.Result.TimingsMS.Total != 1000 {
+		t.Fatalf("unexpected result: %+v", got.Result)
+	}
+
+	failed, ok, err := store.Load(ctx, jobs.Key{ID: "job_2"})
+	if err != nil || !ok || failed.Error == nil || failed.Error.Code != "timeout" {
+		t.Fatalf("unexpected failed record: %+v ok=%v err=%v", failed, ok, err)
+	}
+
+	if _, ok, err := store.Load(ctx, jobs.Key{ID: "missing"}); ok || err != nil {
+		t.Fatalf("expected missing job, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestStoreDeletesExpiredRecords(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	ctx := context.Background()
+	now := time.UnixMilli(1_700_000_000_000)
+
+	for id, updated := range map[string]time.Time{"old": now.Add(-2 * time.Hour), "new": now} {
+		if err := store.Save(ctx, jobs.Record{ID: id, Status: jobs.StatusSucceeded, CreatedAt: updated, UpdatedAt: updated}); err != nil {
+			t.Fatalf("Save %s: %v", id, err)
+		}
+	}
+	stalled := now.Add(-2 * time.Hour)
+	if err := store.Save(ctx, jobs.Record{ID: "running", Status: jobs.StatusRunning, CreatedAt: stalled, UpdatedAt: stalled}); err != nil {
+		t.Fatalf("Save running: %v", err)
+	}
+	n, err := store.DeleteExpired(ctx, now.Add(-time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v; want 1", n, err)
+	}
+	if _, ok, _ := store.Load(ctx, jobs.Key{ID: "old"}); ok {
+		t.Fatal("expired record still present")
+	}
+	if _, ok, _ := store.Load(ctx, jobs.Key{ID: "new"}); !ok {
+		t.Fatal("fresh record was deleted")
+	}
+	if _, ok, _ := store.Load(ctx, jobs.Key{ID: "running"}); !ok {
+		t.Fatal("unfinished record was deleted")
+	}
+}
+
+func TestOutboxSavesRecordAndEventTogether(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	ctx := context.Background()
+	now := time.UnixMilli(1_700_000_000_000)
+
+	rec := jobs.Record{ID: "job_1", Tenant: "acme", Status: jobs.StatusSucceeded, CreatedAt: now, UpdatedAt: now, CallbackURL: "https://example.com/hook"}
+	if err := store.SaveWithEvent(ctx, rec, now.Add(time.Minute)); err != nil {
+		t.Fatalf("SaveWithEvent: %v", err)
+	}
+	if got, ok, _ := store.Load(ctx, rec.Key()); !ok || got.Status != jobs.StatusSucceeded {
+		t.Fatalf("record not saved: %+v", got)
+	}
+	if ids, err := store.ClaimEvents(ctx, now, time.Minute, 10); err != nil || len(ids) != 0 {
+		t.Fatalf("claimed before retryAt: %v %v", ids, err)
+	}
+	ids, err := store.ClaimEvents(ctx, now.Add(time.Minute), time.Hour, 10)
+	if err != nil || len(ids) != 1 || ids[0] != rec.Key() {
+		t.Fatalf("ClaimEvents = %v, %v", ids, err)
+	}
+	if ids, _ := store.ClaimEvents(ctx, now.Add(2*time.Minute), time.Hour, 10); len(ids) != 0 {
+		t.Fatalf("claimed again within the lease: %v", ids)
+	}
+	if err := store.AckEvent(ctx, rec.Key()); err != nil {
+		t.Fatalf("AckEvent: %v", err)
+	}
+	if ids, _ := store.ClaimEvents(ctx, now.Add(48*time.Hour), time.Hour, 10); len(ids) != 0 {
+		t.Fatalf("acked event claimed: %v", ids)
+	}
+}
+
+func TestStoreScopesRecordsByTenant(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	ctx := context.Background()
+	now := time.UnixMilli(1_700_000_000_000)
+
+	rec := jobs.Record{ID: "job_1", Tenant: "acme", Status: jobs.StatusSucceeded, CreatedAt: now, UpdatedAt: now}
+	if err := store.Save(ctx, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, tenant := range []string{"", "globex"} {
+		if _, ok, err := store.Load(ctx, jobs.Key{Tenant: tenant, ID: "job_1"}); ok || err != nil {
+			t.Fatalf("tenant %q loaded acme's job: ok=%v err=%v", tenant, ok, err)
+		}
+	}
+
+	// A save under another tenant must not take over the row.
+	_ = store.Save(ctx, jobs.Record{ID: "job_1", Tenant: "globex", Status: jobs.StatusFailed, CreatedAt: now, UpdatedAt: now})
+	got, ok, _ := store.Load(ctx, rec.Key())
+	if !ok || got.Tenant != "acme" || got.Status != jobs.StatusSucceeded {
+		t.Fatalf("acme's job = %+v ok=%v", got, ok)
+	}
+}
+
+func TestPingFailsOnceClosed(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := store.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	_ = store.Close()
+	if err := store.Ping(context.Background()); err == nil {
+		t.Fatal("Ping after Close succeeded")
+	}
+}
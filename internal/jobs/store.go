@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"echoflow/internal/model"
+)
+
+// Store persists job snapshots so they survive restarts and can be read by
+// any instance behind a load balancer. Live progress still comes from the
+// instance running the job; the store sees a snapshot on every state change.
+//
+// Every read is scoped by Key: a store must not return a job saved under
+// another tenant, even to a caller that knows its ID.
+type Store interface {
+	Save(ctx context.Context, rec Record) error
+	// Load returns ok=false when no job with that key exists.
+	Load(ctx context.Context, key Key) (rec Record, ok bool, err error)
+}
+
+// Pinger is implemented by stores and queues backed by a server or database,
+// so readiness can report when they become unreachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Key names a job within its tenant. Jobs submitted without a tenant, such
+// as those of BYOT callers, share the empty tenant.
+type Key struct {
+	Tenant string
+	ID     string
+}
+
+// Record is the persisted form of a Job.
+type Record struct {
+	ID        string
+	Tenant    string
+	Status    Status
+	Stage     string
+	Progress  float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Result    *model.PipelineProcessResponse
+	Error     *model.APIError
+	// CallbackURL lets the replica that runs a queued job send its webhook.
+	CallbackURL string
+}
+
+// Error is a job failure in its client-facing form. Tasks should return it so
+// the mapped error survives persistence; other errors are stored as a bare
+// internal_error, since their text may hold URLs or upstream responses.
+type Error struct {
+	model.APIError
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func (r Record) Key() Key {
+	return Key{Tenant: r.Tenant, ID: r.ID}
+}
+
+func toRecord(j Job) Record {
+	rec := Record{
+		ID:          j.ID,
+		Tenant:      j.Tenant,
+		Status:      j.Status,
+		Stage:       j.Stage,
+		Progress:    j.Progress,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+		Result:      j.Result,
+		CallbackURL: j.CallbackURL,
+	}
+	if j.Err != nil {
+		var jobErr *Error
+		if errors.As(j.Err, &jobErr) {
+			apiErr := jobErr.APIError
+			rec.Error = &apiErr
+		} else {
+			rec.Error = &model.APIError{Code: "internal_error", Message: "job failed"}
+		}
+	}
+	return rec
+}
+
+func fromRecord(rec Record) Job {
+	j := Job{
+		ID:          rec.ID,
+		Tenant:      rec.Tenant,
+		Status:      rec.Status,
+		Stage:       rec.Stage,
+		Progress:    rec.Progress,
+		CreatedAt:   rec.CreatedAt,
+		UpdatedAt:   rec.UpdatedAt,
+		Result:      rec.Result,
+		CallbackURL: rec.CallbackURL,
+	}
+	if rec.Error != nil {
+		j.Err = &Error{APIError: *rec.Error}
+	}
+	return j
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// Expirer is implemented by stores that can delete old records themselves.
+// Stores with native expiry (e.g. Redis key TTLs) need not implement it.
+type Expirer interface {
+	// DeleteExpired removes finished records last updated before cutoff.
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// WithResultTTL makes RunSweeper drop finished jobs, and persisted records,
+// once they have not changed for ttl.
+func WithResultTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.resultTTL = ttl
+	}
+}
+
+// RunSweeper deletes expired jobs periodically until ctx is cancelled. also,
+// if set, runs with the same cutoff on every sweep, e.g. to remove temp files.
+func (m *Manager) RunSweeper(ctx context.Context, also func(cutoff time.Time)) {
+	if m.resultTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(sweepInterval(m.resultTTL))
+	defer ticker.Stop()
+	for {
+		m.sweep(ctx, also)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sweepInterval(ttl time.Duration) time.Duration {
+	return min(max(ttl/4, time.Second), 10*time.Minute)
+}
+
+func (m *Manager) sweep(ctx context.Context, also func(cutoff time.Time)) {
+	cutoff := m.now().Add(-m.resultTTL)
+
+	m.mu.Lock()
+	evicted := 0
+	for id, j := range m.jobs {
+		if j.Status.Terminal() && j.UpdatedAt.Before(cutoff) {
+			delete(m.jobs, id)
+			evicted++
+		}
+	}
+	m.mu.Unlock()
+
+	deleted := 0
+	if expirer, ok := m.store.(Expirer); ok {
+		storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+		n, err := expirer.DeleteExpired(storeCtx, cutoff)
+		cancel()
+		if err != nil {
+			m.logger.Error("job store sweep failed", "error", err)
+		}
+		deleted = n
+	}
+	if also != nil {
+		also(cutoff)
+	}
+	if evicted > 0 || deleted > 0 {
+		m.logger.Info("expired jobs removed", "in_memory", evicted, "store", deleted)
+	}
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"echoflow/internal/model"
+	"echoflow/internal/pipeline"
+)
+
+func TestSweepEvictsExpiredFinishedJobs(t *testing.T) {
+	m := NewManager(WithResultTTL(time.Hour))
+	now := time.Unix(1_700_000_000, 0)
+	m.now = func() time.Time { return now }
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		return model.PipelineProcessResponse{}, nil
+	}, nil)
+	waitTerminal(t, m, done.ID)
+	running, _ := m.Submit(context.Background(), func(context.Context, func(pipeline.ProgressEvent)) (model.PipelineProcessResponse, error) {
+		close(started)
+		<-release
+		return model.PipelineProcessResponse{}, nil
+	}, nil)
+	defer close(release)
+	<-started
+
+	now = now.Add(2 * time.Hour)
+	var gotCutoff time.Time
+	m.sweep(context.Background(), func(cutoff time.Time) { gotCutoff = cutoff })
+
+	if _, ok := m.Get(done.Key()); ok {
+		t.Fatal("expired job was not evicted")
+	}
+	if _, ok := m.Get(running.Key()); !ok {
+		t.Fatal("unfinished job was evicted")
+	}
+	if !gotCutoff.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("cutoff = %v, want %v", gotCutoff, now.Add(-time.Hour))
+	}
+}
@@ -0,0 +1,303 @@
+// Package legacy maps the paths, field names and status strings of older
+// clients onto the current API and back, so the server can be upgraded
+// before every device in the field has been.
+package legacy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// maxJSONBody bounds the request bodies rewritten in memory; larger ones are
+// passed on untouched for the handler to reject.
+const maxJSONBody = 1 << 20
+
+// Client is one generation of legacy clients and its mapping table.
+type Client struct {
+	Name string `json:"name"`
+	// UserAgents picks the client's requests by User-Agent; "*" matches any
+	// run of characters. A client without patterns matches every request.
+	UserAgents []string `json:"user_agents"`
+	// Paths maps an old request path to the current one.
+	Paths map[string]string `json:"paths"`
+	// Fields maps old request field names, in the query, a JSON body or a
+	// form, to current ones.
+	Fields map[string]string `json:"fields"`
+	// ResponseFields maps current response field names to the old ones.
+	ResponseFields map[string]string `json:"response_fields"`
+	// Statuses maps current "status" values in responses to the old ones.
+	Statuses map[string]string `json:"statuses"`
+
+	agents []*regexp.Regexp
+}
+
+// Table is the set of legacy clients, tried in order.
+type Table struct {
+	clients []*Client
+}
+
+// Load reads a table of the form {"clients": [...]}.
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Clients []*Client `json:"clients"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse legacy clients %s: %w", path, err)
+	}
+	return NewTable(file.Clients)
+}
+
+func NewTable(clients []*Client) (*Table, error) {
+	for i, c := range clients {
+		if c == nil || strings.TrimSpace(c.Name) == "" {
+			return nil, fmt.Errorf("legacy client %d: name is required", i)
+		}
+		for _, pattern := range c.UserAgents {
+			if strings.TrimSpace(pattern) == "" {
+				return nil, fmt.Errorf("legacy client %q: empty user agent pattern", c.Name)
+			}
+			c.agents = append(c.agents, globPattern(pattern))
+		}
+		for old, current := range c.Paths {
+			if !strings.HasPrefix(old, "/") || !strings.HasPrefix(current, "/") {
+				return nil, fmt.Errorf("legacy client %q: paths must start with /", c.Name)
+			}
+		}
+		for _, table := range []map[string]string{c.Fields, c.ResponseFields, c.Statuses} {
+			for from, to := range table {
+				if from == "" || to == "" {
+					return nil, fmt.Errorf("legacy client %q: mappings must not be empty", c.Name)
+				}
+			}
+		}
+	}
+	return &Table{clients: clients}, nil
+}
+
+// Match returns the first client whose patterns match r's User-Agent.
+func (t *Table) Match(r *http.Request) (*Client, bool) {
+	if t == nil {
+		return nil, false
+	}
+	agent := r.UserAgent()
+	for _, c := range t.clients {
+		if len(c.agents) == 0 {
+			return c, true
+		}
+		for _, re := range c.agents {
+			if re.MatchString(agent) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func globPattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(strings.TrimSpace(pattern), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// RewriteRequest moves r onto the current path and field names. Multipart
+// bodies are renamed as they stream, so uploads are not held in memory.
+func (c *Client) RewriteRequest(r *http.Request) error {
+	if current, ok := c.Paths[r.URL.Path]; ok {
+		r.URL.Path, r.URL.RawPath = current, ""
+	}
+	if len(c.Fields) == 0 {
+		return nil
+	}
+	if r.URL.RawQuery != "" {
+		query := r.URL.Query()
+		renameValues(query, c.Fields)
+		r.URL.RawQuery = query.Encode()
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	switch {
+	case mediaType == "application/json":
+		return c.rewriteJSONBody(r)
+	case mediaType == "application/x-www-form-urlencoded":
+		return c.rewriteFormBody(r)
+	case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+		c.rewriteMultipartBody(r, params["boundary"])
+	}
+	return nil
+}
+
+func renameValues(values url.Values, names map[string]string) {
+	for old, current := range names {
+		if v, ok := values[old]; ok {
+			delete(values, old)
+			if _, exists := values[current]; !exists {
+				values[current] = v
+			}
+		}
+	}
+}
+
+func (c *Client) rewriteJSONBody(r *http.Request) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxJSONBody+1))
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if len(data) > maxJSONBody || json.Unmarshal(data, &fields) != nil || fields == nil {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+		return nil
+	}
+	for old, current := range c.Fields {
+		if v, ok := fields[old]; ok {
+			delete(fields, old)
+			if _, exists := fields[current]; !exists {
+				fields[current] = v
+			}
+		}
+	}
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	setBody(r, data)
+	return nil
+}
+
+func (c *Client) rewriteFormBody(r *http.Request) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxJSONBody+1))
+	if err != nil {
+		return err
+	}
+	values, err := url.ParseQuery(string(data))
+	if len(data) > maxJSONBody || err != nil {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+		return nil
+	}
+	renameValues(values, c.Fields)
+	setBody(r, []byte(values.Encode()))
+	return nil
+}
+
+func setBody(r *http.Request, data []byte) {
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.Header.Set("Content-Length", fmt.Sprint(len(data)))
+}
+
+// rewriteMultipartBody renames the form's parts through a pipe. A malformed
+// body is cut short with its error, which the handler's parser reports.
+func (c *Client) rewriteMultipartBody(r *http.Request, boundary string) {
+	src := r.Body
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() { _ = src.Close() }()
+		pw.CloseWithError(c.copyParts(pw, multipart.NewReader(src, boundary), boundary))
+	}()
+	r.Body = readCloser{pr, pr}
+	r.ContentLength = -1
+	r.Header.Del("Content-Length")
+}
+
+func (c *Client) copyParts(dst io.Writer, mr *multipart.Reader, boundary string) error {
+	mw := multipart.NewWriter(dst)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return mw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		header := maps.Clone(part.Header)
+		if current, ok := c.Fields[part.FormName()]; ok {
+			disposition := map[string]string{"name": current}
+			if filename := part.FileName(); filename != "" {
+				disposition["filename"] = filename
+			}
+			header.Set("Content-Disposition", mime.FormatMediaType("form-data", disposition))
+		}
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, part); err != nil {
+			return err
+		}
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// RewritesResponses reports whether JSON responses need RewriteResponse.
+func (c *Client) RewritesResponses() bool {
+	return len(c.ResponseFields) > 0 || len(c.Statuses) > 0
+}
+
+// RewriteResponse renames fields and "status" values at any depth of a JSON
+// response. Bodies that are not JSON are returned as they are.
+func (c *Client) RewriteResponse(body []byte) []byte {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if decoder.Decode(&value) != nil {
+		return body
+	}
+	out, err := json.Marshal(c.rewriteValue(value))
+	if err != nil {
+		return body
+	}
+	return append(out, '\n')
+}
+
+func (c *Client) rewriteValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, field := range v {
+			if status, ok := field.(string); ok && key == "status" {
+				if old, ok := c.Statuses[status]; ok {
+					field = old
+				}
+			}
+			if old, ok := c.ResponseFields[key]; ok {
+				key = old
+			}
+			out[key] = c.rewriteValue(field)
+		}
+		return out
+	case []any:
+		for i := range v {
+			v[i] = c.rewriteValue(v[i])
+		}
+	}
+	return value
+}
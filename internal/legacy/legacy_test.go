@@ -0,0 +1,69 @@
+package legacy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRewriteRequestRenamesFields(t *testing.T) {
+	table, err := NewTable([]*Client{{Name: "v1", Fields: map[string]string{"lang": "language", "cleanup": "post_process"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/post-process?lang=de", strings.NewReader(`{"text":"hi","cleanup":true,"lang":"de"}`))
+	req.Header.Set("Content-Type", "application/json")
+	client, ok := table.Match(req)
+	if !ok {
+		t.Fatal("client without user agents should match every request")
+	}
+	if err := client.RewriteRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.RawQuery != "language=de" {
+		t.Fatalf("query = %q", req.URL.RawQuery)
+	}
+	data, _ := io.ReadAll(req.Body)
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["language"] != "de" || body["post_process"] != true || body["lang"] != nil || body["text"] != "hi" {
+		t.Fatalf("body = %s", data)
+	}
+	if req.ContentLength != int64(len(data)) {
+		t.Fatalf("ContentLength = %d, want %d", req.ContentLength, len(data))
+	}
+}
+
+func TestRewriteResponseMapsStatusesAtAnyDepth(t *testing.T) {
+	client := &Client{
+		Name:           "v1",
+		ResponseFields: map[string]string{"final_transcript": "result_text"},
+		Statuses:       map[string]string{"succeeded": "done", "failed": "error"},
+	}
+	got := client.RewriteResponse([]byte(`{"status":"succeeded","result":{"final_transcript":"hi","duration":1.50},"items":[{"status":"failed"},{"status":"queued"}]}`))
+	want := `{"items":[{"status":"error"},{"status":"queued"}],"result":{"duration":1.50,"result_text":"hi"},"status":"done"}` + "\n"
+	if string(got) != want {
+		t.Fatalf("RewriteResponse() = %s", got)
+	}
+	if got := client.RewriteResponse([]byte("not json")); string(got) != "not json" {
+		t.Fatalf("non-JSON body changed: %q", got)
+	}
+}
+
+func TestNewTableRejectsBadMappings(t *testing.T) {
+	for _, c := range []*Client{
+		{},
+		{Name: "v1", UserAgents: []string{" "}},
+		{Name: "v1", Paths: map[string]string{"api/transcribe": "/v1/transcriptions"}},
+		{Name: "v1", Statuses: map[string]string{"succeeded": ""}},
+	} {
+		if _, err := NewTable([]*Client{c}); err == nil {
+			t.Fatalf("NewTable(%+v) succeeded", c)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package memguard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+)
+
+// Dumper writes goroutine dumps and heap profiles on demand, for debugging a
+// replica whose pprof port cannot be reached.
+type Dumper struct {
+	dir        string
+	profileDir string
+	logger     *slog.Logger
+	stderr     io.Writer
+	now        func() time.Time
+}
+
+// NewDumper writes dumps to dir. With no dir, goroutine dumps go to stderr,
+// where the runtime's own SIGQUIT dump would have gone, and heap profiles to
+// profileDir or the temp directory.
+func NewDumper(dir, profileDir string, logger *slog.Logger) *Dumper {
+	if profileDir == "" {
+		profileDir = os.TempDir()
+	}
+	return &Dumper{
+		dir:        dir,
+		profileDir: profileDir,
+		logger:     logger,
+		stderr:     os.Stderr,
+		now:        time.Now,
+	}
+}
+
+// Run dumps on every dump signal until ctx is cancelled: SIGQUIT writes the
+// stacks of all goroutines, SIGUSR1 a heap profile. Catching SIGQUIT replaces
+// the runtime's default of dumping goroutines and exiting, so the process
+// keeps serving. It is a no-op on platforms without these signals.
+func (d *Dumper) Run(ctx context.Context) {
+	if len(dumpSignals) == 0 {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	for sig := range dumpSignals {
+		signal.Notify(signals, sig)
+	}
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			dumpSignals[sig](d)
+		}
+	}
+}
+
+func (d *Dumper) goroutines() {
+	path, err := d.DumpGoroutines()
+	switch {
+	case err != nil:
+		d.logger.Error("goroutine dump failed", "error", err)
+	case path == "":
+		d.logger.Warn("goroutine dump written to stderr")
+	default:
+		d.logger.Warn("goroutine dump written", "path", path)
+	}
+}
+
+func (d *Dumper) heap() {
+	path, err := d.DumpHeap()
+	if err != nil {
+		d.logger.Error("heap profile failed", "error", err)
+		return
+	}
+	d.logger.Warn("heap profile written", "path", path)
+}
+
+// DumpGoroutines writes every goroutine's stack and returns the file it went
+// to, or "" when it went to stderr.
+func (d *Dumper) DumpGoroutines() (string, error) {
+	if d.dir == "" {
+		return "", pprof.Lookup("goroutine").WriteTo(d.stderr, 2)
+	}
+	path := filepath.Join(d.dir, fmt.Sprintf("goroutines-%s.txt", d.stamp()))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// DumpHeap writes a heap profile and returns its path.
+func (d *Dumper) DumpHeap() (string, error) {
+	dir := d.dir
+	if dir == "" {
+		dir = d.profileDir
+	}
+	path := filepath.Join(dir, fmt.Sprintf("heap-%s.pb.gz", d.stamp()))
+	return path, writeHeapProfile(path)
+}
+
+func (d *Dumper) stamp() string {
+	return d.now().UTC().Format("20060102T150405.000Z")
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package memguard
+
+import "os"
+
+// Windows cannot send SIGQUIT or SIGUSR1 to a process.
+var dumpSignals = map[os.Signal]func(*Dumper){}
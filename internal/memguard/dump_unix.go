@@ -0,0 +1,13 @@
+//go:build unix
+
+package memguard
+
+import (
+	"os"
+	"syscall"
+)
+
+var dumpSignals = map[os.Signal]func(*Dumper){
+	syscall.SIGQUIT: (*Dumper).goroutines,
+	syscall.SIGUSR1: (*Dumper).heap,
+}
@@ -0,0 +1,181 @@
+// Package memguard tunes the GC for the container memory limit and captures
+// heap profiles when the process gets close to being OOM-killed.
+package memguard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroup v1 reports a page-aligned value near MaxInt64 when no limit is set.
+const cgroupV1Unlimited = int64(1) << 62
+
+type Config struct {
+	// Limit is the container memory limit in bytes; zero means detect it
+	// from the cgroup.
+	Limit int64
+	// LimitRatio is the share of Limit handed to the runtime as GOMEMLIMIT,
+	// leaving headroom for non-heap memory.
+	LimitRatio float64
+	// GCPercent overrides GOGC when positive.
+	GCPercent int
+	// Threshold is the share of Limit at which the watchdog writes a profile.
+	Threshold  float64
+	Interval   time.Duration
+	Cooldown   time.Duration
+	ProfileDir string
+}
+
+// Apply sets the runtime soft memory limit and GC percent from cfg and returns
+// the container limit it used (0 if none is known). Explicit GOMEMLIMIT and
+// GOGC environment variables take precedence, as the runtime already honours
+// them.
+func Apply(cfg Config, logger *slog.Logger) int64 {
+	limit := cfg.Limit
+	source := "config"
+	if limit <= 0 {
+		limit = DetectLimit("/sys/fs/cgroup")
+		source = "cgroup"
+	}
+
+	if _, set := os.LookupEnv("GOMEMLIMIT"); !set && limit > 0 && cfg.LimitRatio > 0 {
+		soft := int64(float64(limit) * cfg.LimitRatio)
+		debug.SetMemoryLimit(soft)
+		logger.Info("memory limit configured", "source", source, "container_limit_bytes", limit, "gomemlimit_bytes", soft)
+	}
+	if _, set := os.LookupEnv("GOGC"); !set && cfg.GCPercent > 0 {
+		debug.SetGCPercent(cfg.GCPercent)
+		logger.Info("gc percent configured", "gogc", cfg.GCPercent)
+	}
+	return limit
+}
+
+// DetectLimit reads the cgroup v2 or v1 memory limit under root. It returns 0
+// when the limit is absent or unlimited.
+func DetectLimit(root string) int64 {
+	for _, path := range []string{
+		filepath.Join(root, "memory.max"),
+		filepath.Join(root, "memory", "memory.limit_in_bytes"),
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "max" {
+			return 0
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 || limit >= cgroupV1Unlimited {
+			return 0
+		}
+		return limit
+	}
+	return 0
+}
+
+type Watchdog struct {
+	limit       int64
+	cfg         Config
+	logger      *slog.Logger
+	rss         func() (int64, error)
+	now         func() time.Time
+	lastProfile time.Time
+}
+
+func NewWatchdog(limit int64, cfg Config, logger *slog.Logger) *Watchdog {
+	if cfg.ProfileDir == "" {
+		cfg.ProfileDir = os.TempDir()
+	}
+	return &Watchdog{
+		limit:  limit,
+		cfg:    cfg,
+		logger: logger,
+		rss:    readRSS,
+		now:    time.Now,
+	}
+}
+
+// Run checks RSS every Interval until ctx is cancelled. It is a no-op when
+// the container limit is unknown.
+func (w *Watchdog) Run(ctx context.Context) {
+	if w.limit <= 0 || w.cfg.Threshold <= 0 || w.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+func (w *Watchdog) check() {
+	rss, err := w.rss()
+	if err != nil || float64(rss) < w.cfg.Threshold*float64(w.limit) {
+		return
+	}
+	now := w.now()
+	if !w.lastProfile.IsZero() && now.Sub(w.lastProfile) < w.cfg.Cooldown {
+		return
+	}
+	w.lastProfile = now
+
+	path := filepath.Join(w.cfg.ProfileDir, fmt.Sprintf("heap-%s.pb.gz", now.UTC().Format("20060102T150405Z")))
+	if err := writeHeapProfile(path); err != nil {
+		w.logger.Error("memory watchdog: heap profile failed", "rss_bytes", rss, "limit_bytes", w.limit, "error", err)
+		return
+	}
+	w.logger.Warn("memory watchdog: RSS near container limit, heap profile written",
+		"rss_bytes", rss,
+		"limit_bytes", w.limit,
+		"profile", path,
+	)
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup("heap").WriteTo(f, 0); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readRSS returns the resident set size from /proc/self/status.
+func readRSS() (int64, error) {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "VmRSS:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "kB")), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb << 10, nil
+	}
+	return 0, fmt.Errorf("VmRSS not found")
+}
@@ -0,0 +1,95 @@
+package memguard
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectLimit(t *testing.T) {
+	cases := map[string]struct {
+		file  string
+		value string
+		want  int64
+	}{
+		"v2 limit":     {"memory.max", "536870912\n", 512 << 20},
+		"v2 unlimited": {"memory.max", "max\n", 0},
+		"v1 limit":     {"memory/memory.limit_in_bytes", "1073741824\n", 1 << 30},
+		"v1 unlimited": {"memory/memory.limit_in_bytes", "9223372036854771712\n", 0},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, tc.file)
+			_ = os.MkdirAll(filepath.Dir(path), 0o755)
+			if err := os.WriteFile(path, []byte(tc.value), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if got := DetectLimit(root); got != tc.want {
+				t.Fatalf("DetectLimit() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+	if got := DetectLimit(t.TempDir()); got != 0 {
+		t.Fatalf("expected 0 without cgroup files, got %d", got)
+	}
+}
+
+func TestWatchdogWritesProfileNearLimitWithCooldown(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWatchdog(1000, Config{Threshold: 0.8, Interval: time.Second, Cooldown: time.Minute, ProfileDir: dir}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	rss := int64(700)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	w.rss = func() (int64, error) { return rss, nil }
+	w.now = func() time.Time { return now }
+
+	w.check()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected no profile below threshold, got %d", len(entries))
+	}
+
+	rss = 900
+	w.check()
+	now = now.Add(10 * time.Second)
+	w.check()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "heap-20260102T030405Z.pb.gz" {
+		t.Fatalf("expected one profile within cooldown, got %v", entries)
+	}
+
+	now = now.Add(time.Minute)
+	w.check()
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("expected a second profile after cooldown, got %d", len(entries))
+	}
+}
+
+func TestDumperWritesGoroutinesAndHeap(t *testing.T) {
+	dir := t.TempDir()
+	d := NewDumper(dir, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	path, err := d.DumpGoroutines()
+	if err != nil || filepath.Dir(path) != dir {
+		t.Fatalf("DumpGoroutines() = %q, %v", path, err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "TestDumperWritesGoroutinesAndHeap") {
+		t.Fatalf("goroutine dump lacks the test's stack:\n%s", data)
+	}
+	if path, err := d.DumpHeap(); err != nil || filepath.Dir(path) != dir {
+		t.Fatalf("DumpHeap() = %q, %v", path, err)
+	}
+
+	var stderr strings.Builder
+	profiles := t.TempDir()
+	d = NewDumper("", profiles, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	d.stderr = &stderr
+	if path, err := d.DumpGoroutines(); err != nil || path != "" || !strings.Contains(stderr.String(), "goroutine ") {
+		t.Fatalf("DumpGoroutines() without dir = %q, %v", path, err)
+	}
+	if path, err := d.DumpHeap(); err != nil || filepath.Dir(path) != profiles {
+		t.Fatalf("DumpHeap() without dir = %q, %v", path, err)
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type APIError struct {
 	Code    string         `json:"code"`
 	Message string         `json:"message"`
@@ -26,8 +28,47 @@ type TokenUsage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+type AudioMetadata struct {
+	Container  string `json:"container"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+}
+
 type TranscriptionResponse struct {
-	Text string `json:"text"`
+	Text          string         `json:"text"`
+	Audio         *AudioMetadata `json:"audio,omitempty"`
+	Preprocessing []string       `json:"preprocessing,omitempty"`
+	Language      string         `json:"language,omitempty"`
+	Warnings      []string       `json:"warnings,omitempty"`
+}
+
+// TranscriptionVerboseResponse is the response_format=verbose_json body of
+// /v1/transcriptions and /v1/translations.
+type TranscriptionVerboseResponse struct {
+	TranscriptionResponse
+	Segments []TranscriptionSegment `json:"segments"`
+	// Words is set with timestamp_granularities=word.
+	Words []TranscriptionWord `json:"words,omitempty"`
+}
+
+// TranscriptionSegment is a span of the transcript; Start and End are
+// seconds from the beginning of the audio.
+type TranscriptionSegment struct {
+	Start      float64  `json:"start"`
+	End        float64  `json:"end"`
+	Text       string   `json:"text"`
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+// TranscriptionWord is one word of the transcript with its timing in
+// seconds. Speaker is the part label in multi-part pipeline responses.
+type TranscriptionWord struct {
+	Start      float64  `json:"start"`
+	End        float64  `json:"end"`
+	Word       string   `json:"word"`
+	Confidence *float64 `json:"confidence,omitempty"`
+	Speaker    string   `json:"speaker,omitempty"`
 }
 
 type PostProcessRequest struct {
@@ -36,26 +77,275 @@ type PostProcessRequest struct {
 	CustomVocabulary   string `json:"custom_vocabulary,omitempty"`
 	CustomSystemPrompt string `json:"custom_system_prompt,omitempty"`
 	Model              string `json:"model,omitempty"`
+	// ExpandAcronyms applies the tenant's acronym dictionary; nil means true.
+	ExpandAcronyms *bool `json:"expand_acronyms,omitempty"`
+	// CollapseSpellings collapses dictated spellings into words; nil means
+	// POSTPROCESS_COLLAPSE_SPELLINGS. SpellingsToVocabulary also adds the
+	// words to custom_vocabulary.
+	CollapseSpellings     *bool `json:"collapse_spellings,omitempty"`
+	SpellingsToVocabulary bool  `json:"spellings_to_vocabulary,omitempty"`
 	// Deprecated: accepted for backwards compatibility, ignored in responses.
 	IncludeDebugPrompt bool `json:"include_debug_prompt,omitempty"`
+	// TimeoutMS overrides the configured post-processing timeout; nil keeps
+	// it.
+	TimeoutMS *int `json:"timeout_ms,omitempty"`
 }
 
 type PostProcessResponse struct {
 	Transcript string      `json:"transcript"`
 	Status     string      `json:"status"`
 	Usage      *TokenUsage `json:"usage,omitempty"`
+	Tier       string      `json:"tier,omitempty"`
+	// SpelledWords are the words collapsed from dictated spellings.
+	SpelledWords []string `json:"spelled_words,omitempty"`
+}
+
+// ChaptersRequest is the body of POST /v1/chapters.
+type ChaptersRequest struct {
+	Segments    []TranscriptionSegment `json:"segments"`
+	Model       string                 `json:"model,omitempty"`
+	MaxChapters int                    `json:"max_chapters,omitempty"`
+	// ResponseFormat vtt answers with a WebVTT chapters track.
+	ResponseFormat string `json:"response_format,omitempty"`
+	TimeoutMS      *int   `json:"timeout_ms,omitempty"`
+}
+
+// Chapter is a titled span of the transcript in seconds.
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title"`
+}
+
+type ChaptersResponse struct {
+	Chapters []Chapter   `json:"chapters"`
+	Usage    *TokenUsage `json:"usage,omitempty"`
+}
+
+type PostProcessDelta struct {
+	Content string `json:"content"`
 }
 
 type PipelineTimings struct {
-	Transcription  int64 `json:"transcription"`
-	PostProcessing int64 `json:"post_processing"`
-	Total          int64 `json:"total"`
+	Transcription  int64            `json:"transcription"`
+	PostProcessing int64            `json:"post_processing"`
+	Stages         map[string]int64 `json:"stages,omitempty"`
+	// Chunks lists the chunks of uploads split for transcription.
+	Chunks []PipelineChunkTiming `json:"chunks,omitempty"`
+	Total  int64                 `json:"total"`
+}
+
+// PipelineChunkTiming gives a chunk's span of the recording and how long it
+// took to transcribe, all in milliseconds.
+type PipelineChunkTiming struct {
+	Speaker       string `json:"speaker,omitempty"`
+	Start         int64  `json:"start"`
+	End           int64  `json:"end"`
+	Transcription int64  `json:"transcription"`
+}
+
+// PipelineStageResult is the outcome of one stage requested in "stages".
+type PipelineStageResult struct {
+	Stage  string      `json:"stage"`
+	Output string      `json:"output,omitempty"`
+	Usage  *TokenUsage `json:"usage,omitempty"`
+	Error  string      `json:"error,omitempty"`
 }
 
 type PipelineProcessResponse struct {
-	RawTranscript        string          `json:"raw_transcript"`
-	FinalTranscript      string          `json:"final_transcript"`
-	PostProcessingStatus string          `json:"post_processing_status"`
-	PostProcessingUsage  *TokenUsage     `json:"post_processing_usage,omitempty"`
-	TimingsMS            PipelineTimings `json:"timings_ms"`
+	RawTranscript        string `json:"raw_transcript"`
+	FinalTranscript      string `json:"final_transcript"`
+	PostProcessingStatus string `json:"post_processing_status"`
+	// PostProcessingOutcome is succeeded, succeeded_after_retry or fallback.
+	PostProcessingOutcome string                `json:"post_processing_outcome"`
+	PostProcessingUsage   *TokenUsage           `json:"post_processing_usage,omitempty"`
+	PostProcessingTier    string                `json:"post_processing_tier,omitempty"`
+	SpelledWords          []string              `json:"spelled_words,omitempty"`
+	Audio                 *AudioMetadata        `json:"audio,omitempty"`
+	Preprocessing         []string              `json:"preprocessing,omitempty"`
+	Language              string                `json:"language,omitempty"`
+	Warnings              []string              `json:"warnings,omitempty"`
+	Words                 []TranscriptionWord   `json:"words,omitempty"`
+	Stages                []PipelineStageResult `json:"stages,omitempty"`
+	Cached                bool                  `json:"cached,omitempty"`
+	TimingsMS             PipelineTimings       `json:"timings_ms"`
+}
+
+// PipelineURLRequest is the JSON form of /v1/pipeline/process and /v1/jobs:
+// EchoFlow downloads the audio from AudioURL instead of taking an upload.
+type PipelineURLRequest struct {
+	AudioURL           string   `json:"audio_url"`
+	SplitChannels      bool     `json:"split_channels,omitempty"`
+	SpeakerLabels      []string `json:"speaker_labels,omitempty"`
+	ContextSummary     string   `json:"context_summary,omitempty"`
+	CustomVocabulary   string   `json:"custom_vocabulary,omitempty"`
+	CustomSystemPrompt string   `json:"custom_system_prompt,omitempty"`
+	TranscriptionModel string   `json:"transcription_model,omitempty"`
+	// TranscriptionPrompt overrides the prompt derived from CustomVocabulary.
+	TranscriptionPrompt string   `json:"transcription_prompt,omitempty"`
+	PostProcessModel    string   `json:"post_process_model,omitempty"`
+	Language            string   `json:"language,omitempty"`
+	Stages              []string `json:"stages,omitempty"`
+	// TimestampGranularities with "word" adds word timings to the response.
+	TimestampGranularities []string `json:"timestamp_granularities,omitempty"`
+	// ResponseFormat srt or vtt answers with a caption file; only used by
+	// /v1/pipeline/process.
+	ResponseFormat string `json:"response_format,omitempty"`
+	IncludeDebug   bool   `json:"include_debug,omitempty"`
+	TrimSilence    bool   `json:"trim_silence,omitempty"`
+	Normalize      bool   `json:"normalize,omitempty"`
+	Downmix        bool   `json:"downmix,omitempty"`
+	ResampleHz     int    `json:"resample_hz,omitempty"`
+	Transcode      bool   `json:"transcode,omitempty"`
+	ReturnAudio    bool   `json:"return_audio,omitempty"`
+	ExpandAcronyms *bool  `json:"expand_acronyms,omitempty"`
+	// CollapseSpellings and SpellingsToVocabulary are as in
+	// PostProcessRequest.
+	CollapseSpellings     *bool `json:"collapse_spellings,omitempty"`
+	SpellingsToVocabulary bool  `json:"spellings_to_vocabulary,omitempty"`
+	// TimeoutMS overrides each stage's configured timeout; nil keeps them.
+	TimeoutMS      *int   `json:"timeout_ms,omitempty"`
+	FallbackPolicy string `json:"fallback_policy,omitempty"`
+	// CallbackURL is only used by /v1/jobs.
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// AcronymDictionary is the calling tenant's acronym expansions.
+type AcronymDictionary struct {
+	Acronyms map[string]string `json:"acronyms"`
+}
+
+type AcronymEntry struct {
+	Acronym   string `json:"acronym"`
+	Expansion string `json:"expansion"`
+}
+
+type PipelineBatchResponse struct {
+	Results   []PipelineBatchResult `json:"results"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+}
+
+// PipelineBatchResult is the outcome for one file, in upload order. Exactly
+// one of Result and Error is set; Status is the HTTP status the file would
+// have had on /v1/pipeline/process.
+type PipelineBatchResult struct {
+	Index    int                      `json:"index"`
+	FileName string                   `json:"file_name"`
+	Status   int                      `json:"status"`
+	Result   *PipelineProcessResponse `json:"result,omitempty"`
+	Error    *APIError                `json:"error,omitempty"`
+}
+
+type PipelineStageEvent struct {
+	Stage      string `json:"stage"`
+	Transcript string `json:"transcript"`
+	Status     string `json:"status,omitempty"`
+	Outcome    string `json:"outcome,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type JobResponse struct {
+	ID        string                   `json:"id"`
+	Status    string                   `json:"status"`
+	Stage     string                   `json:"stage,omitempty"`
+	Progress  int                      `json:"progress"`
+	CreatedAt time.Time                `json:"created_at"`
+	UpdatedAt time.Time                `json:"updated_at"`
+	Result    *PipelineProcessResponse `json:"result,omitempty"`
+	Error     *APIError                `json:"error,omitempty"`
+}
+
+// JobWebhook is the callback body for a finished job: the job as GET
+// /v1/jobs/{id} returns it, plus the delivery ID receivers dedupe on.
+type JobWebhook struct {
+	DeliveryID string `json:"delivery_id"`
+	Event      string `json:"event"`
+	JobResponse
+}
+
+type RotateUpstreamKeyRequest struct {
+	APIKey string `json:"api_key"`
+}
+
+type RotateUpstreamKeyResponse struct {
+	OK bool `json:"ok"`
+	// PreviousKeyValidUntil is when requests already in flight stop using the
+	// old key.
+	PreviousKeyValidUntil time.Time `json:"previous_key_valid_until"`
+}
+
+type WhoAmIResponse struct {
+	// Kind is "token" for EchoFlow-issued tokens, "byot" for a caller's own
+	// upstream key, or "anonymous" when the server-side key is used.
+	Kind             string       `json:"kind"`
+	Name             string       `json:"name"`
+	TokenFingerprint string       `json:"token_fingerprint,omitempty"`
+	Tenant           string       `json:"tenant,omitempty"`
+	Tier             string       `json:"tier"`
+	Scopes           []string     `json:"scopes"`
+	Quotas           WhoAmIQuotas `json:"quotas"`
+}
+
+type WhoAmIQuotas struct {
+	// DailyRequests is null when the identity has no daily quota.
+	DailyRequests *RequestQuota `json:"daily_requests"`
+}
+
+type RequestQuota struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+type SelftestResponse struct {
+	OK     bool            `json:"ok"`
+	Stages []SelftestStage `json:"stages"`
+}
+
+type SelftestStage struct {
+	Name       string    `json:"name"`
+	OK         bool      `json:"ok"`
+	DurationMS int64     `json:"duration_ms"`
+	Output     string    `json:"output,omitempty"`
+	Error      *APIError `json:"error,omitempty"`
+}
+
+// SummaryResponse covers the last hour on one replica.
+type SummaryResponse struct {
+	GeneratedAt   time.Time `json:"generated_at"`
+	WindowSeconds int       `json:"window_seconds"`
+	Requests      int       `json:"requests"`
+	ServerErrors  int       `json:"server_errors"`
+	PipelineRuns  int       `json:"pipeline_runs"`
+	// FallbackRate is the share of pipeline runs that returned the raw
+	// transcript because post-processing failed.
+	FallbackRate float64          `json:"fallback_rate"`
+	Latency      []RouteLatency   `json:"latency"`
+	TopTenants   []TenantUsage    `json:"top_tenants"`
+	InFlight     int64            `json:"in_flight"`
+	QueueDepth   int              `json:"queue_depth"`
+	Upstream     UpstreamSnapshot `json:"upstream"`
+}
+
+type RouteLatency struct {
+	Route    string `json:"route"`
+	Requests int    `json:"requests"`
+	P50MS    int64  `json:"p50_ms"`
+	P95MS    int64  `json:"p95_ms"`
+}
+
+type TenantUsage struct {
+	Tenant       string  `json:"tenant"`
+	Requests     int     `json:"requests"`
+	TokensUsed   int     `json:"tokens_used"`
+	AudioSeconds float64 `json:"audio_seconds"`
+}
+
+type UpstreamSnapshot struct {
+	// Status is ok, unhealthy, or unchecked when there is no server-side
+	// upstream key to check with.
+	Status string    `json:"status"`
+	Error  *APIError `json:"error,omitempty"`
 }
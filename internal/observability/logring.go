@@ -0,0 +1,74 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// LogRing is a slog.Handler that passes records through and keeps the most
+// recent warnings and errors in memory for diagnostics bundles.
+type LogRing struct {
+	next   slog.Handler
+	format slog.Handler
+	ring   *logRing
+}
+
+type logRing struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	lines []string
+	head  int
+	full  bool
+}
+
+func NewLogRing(next slog.Handler, size int) *LogRing {
+	r := &logRing{lines: make([]string, max(size, 1))}
+	return &LogRing{next: next, format: slog.NewTextHandler(&r.buf, nil), ring: r}
+}
+
+func (h *LogRing) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.next.Enabled(ctx, level)
+}
+
+func (h *LogRing) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		h.ring.add(ctx, h.format, record)
+	}
+	if !h.next.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *LogRing) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogRing{next: h.next.WithAttrs(attrs), format: h.format.WithAttrs(attrs), ring: h.ring}
+}
+
+func (h *LogRing) WithGroup(name string) slog.Handler {
+	return &LogRing{next: h.next.WithGroup(name), format: h.format.WithGroup(name), ring: h.ring}
+}
+
+// Recent returns the retained lines, oldest first.
+func (h *LogRing) Recent() []string {
+	r := h.ring
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string(nil), r.lines[:r.head]...)
+	}
+	return append(append([]string(nil), r.lines[r.head:]...), r.lines[:r.head]...)
+}
+
+func (r *logRing) add(ctx context.Context, format slog.Handler, record slog.Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf.Reset()
+	if format.Handle(ctx, record.Clone()) != nil {
+		return
+	}
+	r.lines[r.head] = r.buf.String()
+	r.head = (r.head + 1) % len(r.lines)
+	r.full = r.full || r.head == 0
+}
@@ -0,0 +1,28 @@
+package observability
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogRingKeepsRecentWarnings(t *testing.T) {
+	var out bytes.Buffer
+	ring := NewLogRing(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelError}), 2)
+	logger := slog.New(ring).With("component", "test")
+
+	logger.Info("ignored")
+	logger.Warn("first")
+	logger.Error("second", "error", "boom")
+	logger.Warn("third")
+
+	recent := ring.Recent()
+	if len(recent) != 2 || !strings.Contains(recent[0], "msg=second") || !strings.Contains(recent[0], "component=test") ||
+		!strings.Contains(recent[1], "msg=third") {
+		t.Fatalf("recent = %q", recent)
+	}
+	if got := out.String(); strings.Contains(got, "first") || !strings.Contains(got, "second") {
+		t.Fatalf("next handler got %q, want only errors", got)
+	}
+}
@@ -1,6 +1,9 @@
+//go:build !nometrics
+
 package observability
 
 import (
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 )
 
 type Metrics struct {
@@ -18,6 +22,20 @@ type Metrics struct {
 	upstreamRequestsTotal *prometheus.CounterVec
 	upstreamDuration      *prometheus.HistogramVec
 	pipelineFallbacks     prometheus.Counter
+	upstreamProbeLatency  *prometheus.GaugeVec
+	upstreamHealthy       *prometheus.GaugeVec
+	healthProbeLatency    *prometheus.GaugeVec
+	healthProbeOK         *prometheus.GaugeVec
+	upstreamFailovers     *prometheus.CounterVec
+	upstreamRetries       *prometheus.CounterVec
+	hedgeOutcomes         *prometheus.CounterVec
+	routingDecisions      *prometheus.CounterVec
+	jobQueueDepth         prometheus.Gauge
+	jobQueueWait          prometheus.Histogram
+	upstreamQueueWait     *prometheus.HistogramVec
+	resultCache           *prometheus.CounterVec
+	transcriptionDedupe   *prometheus.CounterVec
+	audioDuration         prometheus.Histogram
 }
 
 func NewMetrics() *Metrics {
@@ -61,6 +79,95 @@ func NewMetrics() *Metrics {
 				Help: "Number of pipeline requests that fell back to raw transcript due to post-process failure.",
 			},
 		),
+		upstreamProbeLatency: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "echoflow_upstream_probe_latency_seconds",
+				Help: "Latency of the most recent probe to each configured upstream.",
+			},
+			[]string{"upstream"},
+		),
+		upstreamHealthy: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "echoflow_upstream_healthy",
+				Help: "Whether the most recent probe to each configured upstream succeeded (1) or not (0).",
+			},
+			[]string{"upstream"},
+		),
+		healthProbeLatency: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "echoflow_upstream_health_probe_latency_seconds",
+				Help: "Latency of the most recent background health probe of each provider.",
+			},
+			[]string{"provider"},
+		),
+		healthProbeOK: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "echoflow_upstream_health_probe_ok",
+				Help: "Whether the most recent background health probe of each provider succeeded (1) or not (0).",
+			},
+			[]string{"provider"},
+		),
+		upstreamFailovers: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "echoflow_upstream_failover_total",
+				Help: "Requests moved from one upstream to the next after a 5xx, timeout or transport error.",
+			},
+			[]string{"from", "to"},
+		),
+		upstreamRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "echoflow_upstream_retries_total",
+				Help: "Upstream calls retried after a 429, 5xx or transport error, by endpoint and reason.",
+			},
+			[]string{"endpoint", "reason"},
+		),
+		hedgeOutcomes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "echoflow_transcription_hedge_total",
+				Help: "Hedged transcription outcomes per provider (win, loss, error).",
+			},
+			[]string{"provider", "outcome"},
+		),
+		routingDecisions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "echoflow_routing_decisions_total",
+				Help: "Transcription requests routed by a routing rule.",
+			},
+			[]string{"rule", "provider"},
+		),
+		jobQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "echoflow_jobs_queue_depth",
+			Help: "Async jobs waiting for a local worker.",
+		}),
+		jobQueueWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "echoflow_jobs_queue_wait_seconds",
+			Help:    "Time async jobs spent queued before a worker started them.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 15, 30, 60, 120, 300},
+		}),
+		upstreamQueueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "echoflow_upstream_queue_wait_seconds",
+			Help:    "Time upstream calls waited for a MAX_CONCURRENT_* slot, by endpoint.",
+			Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"endpoint"}),
+		resultCache: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "echoflow_result_cache_requests_total",
+				Help: "Pipeline requests by result cache outcome: hit, miss, or bypass when the request cannot be cached.",
+			},
+			[]string{"result"},
+		),
+		transcriptionDedupe: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "echoflow_transcription_dedupe_total",
+				Help: "Transcription requests by deduplication outcome: unique, shared with identical concurrent requests, or bypass.",
+			},
+			[]string{"outcome"},
+		),
+		audioDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "echoflow_audio_duration_seconds",
+			Help:    "Duration of uploaded audio, from container headers, for uploads whose duration could be read.",
+			Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+		}),
 	}
 
 	registry.MustRegister(
@@ -71,6 +178,20 @@ func NewMetrics() *Metrics {
 		m.upstreamRequestsTotal,
 		m.upstreamDuration,
 		m.pipelineFallbacks,
+		m.upstreamProbeLatency,
+		m.upstreamHealthy,
+		m.healthProbeLatency,
+		m.healthProbeOK,
+		m.upstreamFailovers,
+		m.upstreamRetries,
+		m.hedgeOutcomes,
+		m.routingDecisions,
+		m.jobQueueDepth,
+		m.jobQueueWait,
+		m.upstreamQueueWait,
+		m.resultCache,
+		m.transcriptionDedupe,
+		m.audioDuration,
 	)
 
 	return m
@@ -80,6 +201,21 @@ func (m *Metrics) Handler() http.Handler {
 	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
 }
 
+// WriteText writes the current value of every metric in the Prometheus text
+// format.
+func (m *Metrics) WriteText(w io.Writer) error {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Metrics) ObserveHTTP(route, method string, status int, duration time.Duration) {
 	if m == nil {
 		return
@@ -113,3 +249,100 @@ func (m *Metrics) IncPipelineFallback() {
 	}
 	m.pipelineFallbacks.Inc()
 }
+
+func (m *Metrics) ObserveUpstreamProbe(baseURL string, latency time.Duration, healthy bool) {
+	if m == nil {
+		return
+	}
+	healthyValue := 0.0
+	if healthy {
+		healthyValue = 1
+		m.upstreamProbeLatency.WithLabelValues(baseURL).Set(latency.Seconds())
+	}
+	m.upstreamHealthy.WithLabelValues(baseURL).Set(healthyValue)
+}
+
+// ObserveHealthProbe records a background health probe of a provider, kept
+// apart from ObserveUpstreamProbe's per-base-URL series of the regional
+// selector.
+func (m *Metrics) ObserveHealthProbe(provider string, latency time.Duration, healthy bool) {
+	if m == nil {
+		return
+	}
+	healthyValue := 0.0
+	if healthy {
+		healthyValue = 1
+		m.healthProbeLatency.WithLabelValues(provider).Set(latency.Seconds())
+	}
+	m.healthProbeOK.WithLabelValues(provider).Set(healthyValue)
+}
+
+func (m *Metrics) ObserveUpstreamFailover(from, to string) {
+	if m == nil {
+		return
+	}
+	m.upstreamFailovers.WithLabelValues(from, to).Inc()
+}
+
+func (m *Metrics) ObserveUpstreamRetry(endpoint, reason string) {
+	if m == nil {
+		return
+	}
+	m.upstreamRetries.WithLabelValues(endpoint, reason).Inc()
+}
+
+func (m *Metrics) ObserveHedge(provider, outcome string) {
+	if m == nil {
+		return
+	}
+	m.hedgeOutcomes.WithLabelValues(provider, outcome).Inc()
+}
+
+func (m *Metrics) ObserveRoutingDecision(rule, provider string) {
+	if m == nil {
+		return
+	}
+	m.routingDecisions.WithLabelValues(rule, provider).Inc()
+}
+
+func (m *Metrics) SetJobQueueDepth(depth int) {
+	if m == nil {
+		return
+	}
+	m.jobQueueDepth.Set(float64(depth))
+}
+
+func (m *Metrics) ObserveJobQueueWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.jobQueueWait.Observe(wait.Seconds())
+}
+
+func (m *Metrics) ObserveUpstreamQueueWait(endpoint string, wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.upstreamQueueWait.WithLabelValues(endpoint).Observe(wait.Seconds())
+}
+
+func (m *Metrics) ObserveResultCache(result string) {
+	if m == nil {
+		return
+	}
+	m.resultCache.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) ObserveTranscriptionDedupe(outcome string) {
+	if m == nil {
+		return
+	}
+	m.transcriptionDedupe.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) ObserveAudioDuration(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.audioDuration.Observe(duration.Seconds())
+}
@@ -0,0 +1,39 @@
+//go:build nometrics
+
+package observability
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Metrics is a no-op in nometrics builds, which leave the Prometheus client
+// and its Go and process collectors out of the binary.
+type Metrics struct{}
+
+func NewMetrics() *Metrics { return &Metrics{} }
+
+// Handler returns nil, so GET /metrics is not served.
+func (m *Metrics) Handler() http.Handler { return nil }
+
+func (m *Metrics) WriteText(w io.Writer) error {
+	_, err := io.WriteString(w, "# metrics are not collected in nometrics builds\n")
+	return err
+}
+
+func (m *Metrics) ObserveHTTP(route, method string, status int, duration time.Duration)     {}
+func (m *Metrics) ObserveUpstream(endpoint string, status int, duration time.Duration)      {}
+func (m *Metrics) IncPipelineFallback()                                                     {}
+func (m *Metrics) ObserveUpstreamProbe(baseURL string, latency time.Duration, healthy bool) {}
+func (m *Metrics) ObserveHealthProbe(provider string, latency time.Duration, healthy bool)  {}
+func (m *Metrics) ObserveUpstreamFailover(from, to string)                                  {}
+func (m *Metrics) ObserveUpstreamRetry(endpoint, reason string)                             {}
+func (m *Metrics) ObserveHedge(provider, outcome string)                                    {}
+func (m *Metrics) ObserveRoutingDecision(rule, provider string)                             {}
+func (m *Metrics) SetJobQueueDepth(depth int)                                               {}
+func (m *Metrics) ObserveJobQueueWait(wait time.Duration)                                   {}
+func (m *Metrics) ObserveUpstreamQueueWait(endpoint string, wait time.Duration)             {}
+func (m *Metrics) ObserveResultCache(result string)                                         {}
+func (m *Metrics) ObserveTranscriptionDedupe(outcome string)                                {}
+func (m *Metrics) ObserveAudioDuration(duration time.Duration)                              {}
@@ -0,0 +1,32 @@
+package observability
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// recorder is the method set both builds of Metrics provide. Checking it in
+// a file without a build tag keeps the nometrics no-op from falling behind
+// the Prometheus version: a method missing from either fails that build.
+type recorder interface {
+	Handler() http.Handler
+	WriteText(w io.Writer) error
+	ObserveHTTP(route, method string, status int, duration time.Duration)
+	ObserveUpstream(endpoint string, status int, duration time.Duration)
+	IncPipelineFallback()
+	ObserveUpstreamProbe(baseURL string, latency time.Duration, healthy bool)
+	ObserveHealthProbe(provider string, latency time.Duration, healthy bool)
+	ObserveUpstreamFailover(from, to string)
+	ObserveUpstreamRetry(endpoint, reason string)
+	ObserveHedge(provider, outcome string)
+	ObserveRoutingDecision(rule, provider string)
+	SetJobQueueDepth(depth int)
+	ObserveJobQueueWait(wait time.Duration)
+	ObserveUpstreamQueueWait(endpoint string, wait time.Duration)
+	ObserveResultCache(result string)
+	ObserveTranscriptionDedupe(outcome string)
+	ObserveAudioDuration(duration time.Duration)
+}
+
+var _ recorder = (*Metrics)(nil)
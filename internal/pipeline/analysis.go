@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"echoflow/internal/postprocess"
+)
+
+// Analyzer is an optional stage that reads the raw transcript, such as
+// summarization or entity extraction. Analyzers and post-processing do not
+// depend on each other's output, so they run concurrently.
+type Analyzer interface {
+	Analyze(ctx context.Context, transcript string) (string, *postprocess.TokenUsage, error)
+}
+
+// ErrUnknownStage is returned before any work is done when ProcessInput.Stages
+// names an analyzer that is not configured.
+var ErrUnknownStage = errors.New("unknown pipeline stage")
+
+// DefaultStageConcurrency bounds how many of post-processing and the
+// requested analyzers call upstreams at once for one request.
+const DefaultStageConcurrency = 4
+
+// AnalysisResult is one requested analyzer's outcome. A failed analyzer sets
+// Error and leaves the rest of the result intact.
+type AnalysisResult struct {
+	Stage    string
+	Output   string
+	Usage    *postprocess.TokenUsage
+	Error    string
+	Duration time.Duration
+}
+
+type Option func(*Service)
+
+// WithAnalyzers makes analyzers available to ProcessInput.Stages by name.
+// concurrency bounds post-processing plus analyzers per request; values
+// below 1 use DefaultStageConcurrency.
+func WithAnalyzers(analyzers map[string]Analyzer, concurrency int) Option {
+	return func(s *Service) {
+		s.analyzers = analyzers
+		if concurrency < 1 {
+			concurrency = DefaultStageConcurrency
+		}
+		s.stageConcurrency = concurrency
+	}
+}
+
+// checkStages returns the requested stages without duplicates.
+func (s *Service) checkStages(stages []string) ([]string, error) {
+	var unique []string
+	for _, name := range stages {
+		if s.analyzers[name] == nil {
+			return nil, fmt.Errorf("%w %q", ErrUnknownStage, name)
+		}
+		if !slices.Contains(unique, name) {
+			unique = append(unique, name)
+		}
+	}
+	return unique, nil
+}
+
+type stageResults struct {
+	post         postprocess.Result
+	postErr      error
+	postDuration time.Duration
+	analyses     []AnalysisResult
+}
+
+// runStages runs post-processing and the requested analyzers on the raw
+// transcript in a bounded group. Failures are recorded per stage rather than
+// cancelling the others, matching how post-processing falls back.
+func (s *Service) runStages(ctx context.Context, post postprocess.Input, stages []string) stageResults {
+	res := stageResults{analyses: make([]AnalysisResult, len(stages))}
+
+	var g errgroup.Group
+	g.SetLimit(max(1, s.stageConcurrency))
+	g.Go(func() error {
+		started := time.Now()
+		res.post, res.postErr = s.postProcessor.Process(ctx, post)
+		res.postDuration = time.Since(started)
+		return nil
+	})
+	for i, name := range stages {
+		g.Go(func() error {
+			started := time.Now()
+			output, usage, err := s.analyzers[name].Analyze(ctx, post.Transcript)
+			res.analyses[i] = AnalysisResult{Stage: name, Output: output, Usage: usage, Duration: time.Since(started)}
+			if err != nil {
+				res.analyses[i] = AnalysisResult{Stage: name, Error: err.Error(), Duration: res.analyses[i].Duration}
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return res
+}
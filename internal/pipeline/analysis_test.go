@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/postprocess"
+)
+
+// rendezvous blocks each caller until n have arrived, so a test deadlocks
+// (and times out) unless the callers run concurrently.
+type rendezvous struct {
+	wg sync.WaitGroup
+}
+
+func newRendezvous(n int) *rendezvous {
+	r := &rendezvous{}
+	r.wg.Add(n)
+	return r
+}
+
+func (r *rendezvous) arrive(t *testing.T) {
+	r.wg.Done()
+	done := make(chan struct{})
+	go func() { r.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("stages did not run concurrently")
+	}
+}
+
+type meetingPostProcessor struct {
+	t    *testing.T
+	meet *rendezvous
+}
+
+func (m meetingPostProcessor) Process(_ context.Context, in postprocess.Input) (postprocess.Result, error) {
+	m.meet.arrive(m.t)
+	return postprocess.Result{Transcript: "Clean."}, nil
+}
+
+type meetingAnalyzer struct {
+	t      *testing.T
+	meet   *rendezvous
+	output string
+	err    error
+}
+
+func (m meetingAnalyzer) Analyze(_ context.Context, transcript string) (string, *postprocess.TokenUsage, error) {
+	m.meet.arrive(m.t)
+	if m.err != nil {
+		return "", nil, m.err
+	}
+	return m.output + ":" + transcript, &postprocess.TokenUsage{TotalTokens: 7}, nil
+}
+
+func TestProcessRunsStagesConcurrentlyWithPostProcessing(t *testing.T) {
+	meet := newRendezvous(3)
+	svc := New(&fakeTranscriber{text: "raw"}, meetingPostProcessor{t: t, meet: meet}, "whisper",
+		WithAnalyzers(map[string]Analyzer{
+			"summary":  meetingAnalyzer{t: t, meet: meet, output: "sum"},
+			"entities": meetingAnalyzer{t: t, meet: meet, err: errors.New("boom")},
+		}, 3))
+
+	result, err := svc.Process(context.Background(), ProcessInput{File: strings.NewReader("audio"), Stages: []string{"summary", "entities", "summary"}})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.FinalTranscript != "Clean." || len(result.Analyses) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if a := result.Analyses[0]; a.Stage != "summary" || a.Output != "sum:raw" || a.Usage == nil || a.Error != "" {
+		t.Fatalf("summary = %+v", a)
+	}
+	if a := result.Analyses[1]; a.Stage != "entities" || a.Output != "" || a.Error != "boom" {
+		t.Fatalf("entities = %+v", a)
+	}
+	if _, ok := result.Timings.Stages["entities"]; !ok || len(result.Timings.Stages) != 2 {
+		t.Fatalf("stage timings = %v", result.Timings.Stages)
+	}
+
+	if _, err := svc.Process(context.Background(), ProcessInput{File: strings.NewReader("audio"), Stages: []string{"chapters"}}); !errors.Is(err, ErrUnknownStage) {
+		t.Fatalf("unknown stage: err = %v", err)
+	}
+}
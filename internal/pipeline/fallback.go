@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Fallback policies decide what a pipeline run returns when post-processing
+// fails.
+const (
+	// FallbackRaw returns the raw transcript as the final one.
+	FallbackRaw = "fallback"
+	// FallbackError fails the run, for integrations that must never show
+	// un-cleaned text.
+	FallbackError = "error"
+	// FallbackRetry tries post-processing once more, then returns the raw
+	// transcript.
+	FallbackRetry = "retry_then_fallback"
+)
+
+var errUnknownFallbackPolicy = errors.New("fallback_policy must be fallback, error or retry_then_fallback")
+
+// CheckFallbackPolicy accepts the known policies and "", which means the
+// service default.
+func CheckFallbackPolicy(policy string) error {
+	switch policy {
+	case "", FallbackRaw, FallbackError, FallbackRetry:
+		return nil
+	}
+	return errUnknownFallbackPolicy
+}
+
+// WithFallbackPolicy sets the policy for requests that do not choose one;
+// without it, or with "", failed post-processing falls back to the raw
+// transcript.
+func WithFallbackPolicy(policy string) Option {
+	return func(s *Service) {
+		if policy != "" {
+			s.fallbackPolicy = policy
+		}
+	}
+}
+
+// PostProcessingError fails a run whose post-processing failed under
+// FallbackError.
+type PostProcessingError struct {
+	Err error
+}
+
+func (e *PostProcessingError) Error() string {
+	return fmt.Sprintf("post-processing failed: %v", e.Err)
+}
+
+func (e *PostProcessingError) Unwrap() error { return e.Err }
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"echoflow/internal/postprocess"
+)
+
+// flakyPostProcessor fails its first `failures` calls.
+type flakyPostProcessor struct {
+	failures int
+	calls    int
+}
+
+func (f *flakyPostProcessor) Process(context.Context, postprocess.Input) (postprocess.Result, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return postprocess.Result{}, errors.New("boom")
+	}
+	return postprocess.Result{Transcript: "clean"}, nil
+}
+
+func TestFallbackPolicies(t *testing.T) {
+	tests := []struct {
+		name          string
+		service       string
+		request       string
+		failures      int
+		wantCalls     int
+		wantOutcome   string
+		wantFinal     string
+		wantPostError bool
+	}{
+		{name: "default falls back", failures: 1, wantCalls: 1, wantOutcome: OutcomeFallback, wantFinal: "raw"},
+		{name: "error fails the run", service: FallbackError, failures: 1, wantCalls: 1, wantPostError: true},
+		{name: "retry succeeds", service: FallbackRetry, failures: 1, wantCalls: 2, wantOutcome: OutcomeRetried, wantFinal: "clean"},
+		{name: "retry then fallback", service: FallbackRetry, failures: 2, wantCalls: 2, wantOutcome: OutcomeFallback, wantFinal: "raw"},
+		{name: "request overrides service", service: FallbackRaw, request: FallbackError, failures: 1, wantCalls: 1, wantPostError: true},
+		{name: "no retry after success", service: FallbackRetry, wantCalls: 1, wantOutcome: OutcomeSucceeded, wantFinal: "clean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := &flakyPostProcessor{failures: tt.failures}
+			svc := New(&fakeTranscriber{text: "raw"}, post, "whisper", WithFallbackPolicy(tt.service))
+			res, err := svc.Process(context.Background(), ProcessInput{File: strings.NewReader("audio"), FallbackPolicy: tt.request})
+
+			var postErr *PostProcessingError
+			if tt.wantPostError != errors.As(err, &postErr) {
+				t.Fatalf("Process() error = %v, want PostProcessingError: %v", err, tt.wantPostError)
+			}
+			if post.calls != tt.wantCalls {
+				t.Fatalf("post-processor called %d times, want %d", post.calls, tt.wantCalls)
+			}
+			if !tt.wantPostError && (res.PostProcessingOutcome != tt.wantOutcome || res.FinalTranscript != tt.wantFinal) {
+				t.Fatalf("result = %q %q, want %q %q", res.PostProcessingOutcome, res.FinalTranscript, tt.wantOutcome, tt.wantFinal)
+			}
+		})
+	}
+}
+
+func TestProcessRejectsUnknownFallbackPolicy(t *testing.T) {
+	svc := New(&fakeTranscriber{text: "raw"}, &flakyPostProcessor{}, "whisper")
+	if _, err := svc.Process(context.Background(), ProcessInput{File: strings.NewReader("audio"), FallbackPolicy: "never"}); err == nil {
+		t.Fatal("expected an error for an unknown policy")
+	}
+}
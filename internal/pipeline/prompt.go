@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"strings"
+
+	"echoflow/internal/postprocess"
+)
+
+// maxVocabularyPromptBytes keeps a prompt built from custom vocabulary well
+// inside Whisper's 224-token prompt window.
+const maxVocabularyPromptBytes = 600
+
+// WithVocabularyPrompt sets whether requests without a TranscriptionPrompt
+// send their custom vocabulary to the transcription upstream as its prompt.
+// It is on by default.
+func WithVocabularyPrompt(enabled bool) Option {
+	return func(s *Service) {
+		s.vocabularyPrompt = enabled
+	}
+}
+
+// transcriptionPrompt is the request's own prompt, or else its vocabulary
+// terms, dropping whole terms past the size limit.
+func (s *Service) transcriptionPrompt(in ProcessInput) string {
+	if prompt := strings.TrimSpace(in.TranscriptionPrompt); prompt != "" || !s.vocabularyPrompt {
+		return prompt
+	}
+	var prompt strings.Builder
+	for _, term := range postprocess.VocabularyTerms(in.CustomVocabulary) {
+		if prompt.Len()+len(term)+2 > maxVocabularyPromptBytes {
+			break
+		}
+		if prompt.Len() > 0 {
+			prompt.WriteString(", ")
+		}
+		prompt.WriteString(term)
+	}
+	return prompt.String()
+}
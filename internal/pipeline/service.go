@@ -1,16 +1,24 @@
 package pipeline
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"echoflow/internal/audio"
 	"echoflow/internal/postprocess"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
 )
 
 type Transcriber interface {
-	Transcribe(ctx context.Context, file io.Reader, fileName, model string) (string, error)
+	Transcribe(ctx context.Context, in transcription.Input) (transcription.Result, error)
 }
 
 type PostProcessor interface {
@@ -21,17 +29,107 @@ type Service struct {
 	transcriber               Transcriber
 	postProcessor             PostProcessor
 	defaultTranscriptionModel string
-	defaultPostProcessModel   string
+	analyzers                 map[string]Analyzer
+	stageConcurrency          int
+	fallbackPolicy            string
+	vocabularyPrompt          bool
+}
+
+// AudioPart is one recording of a multi-part conversation, such as a single
+// speaker's channel. Parts are transcribed independently and merged by time.
+type AudioPart struct {
+	File     io.Reader
+	FileName string
+	Size     int64
+	Label    string
+}
+
+const (
+	StageTranscription  = "transcription"
+	StagePostProcessing = "post_processing"
+)
+
+// ProcessResult.PostProcessingStatus values.
+const (
+	PostProcessingSucceeded = "Post-processing succeeded"
+	PostProcessingFailed    = "Post-processing failed, using raw transcript"
+)
+
+// ProcessResult.PostProcessingOutcome values, which tell how the fallback
+// policy played out.
+const (
+	OutcomeSucceeded = "succeeded"
+	// OutcomeRetried means the first attempt failed and the retry of
+	// FallbackRetry succeeded.
+	OutcomeRetried = "succeeded_after_retry"
+	// OutcomeFallback means post-processing failed and the final transcript
+	// is the raw one.
+	OutcomeFallback = "fallback"
+)
+
+// ProgressEvent reports how far a stage has advanced. Completed == Total marks
+// the end of the stage.
+type ProgressEvent struct {
+	Stage     string
+	Completed int
+	Total     int
+}
+
+// StageResult is the outcome of a completed stage: the raw transcript after
+// transcription, the final transcript and status after post-processing.
+type StageResult struct {
+	Stage      string
+	Transcript string
+	Status     string
+	// Outcome is the PostProcessingOutcome of the post-processing stage.
+	Outcome  string
+	Duration time.Duration
 }
 
 type ProcessInput struct {
-	File               io.Reader
-	FileName           string
+	File     io.Reader
+	FileName string
+	FileSize int64
+	Parts    []AudioPart
+	// SplitChannels transcribes each channel of a multi-channel WAV file as its
+	// own part, labeled by ChannelLabels (e.g. caller/agent on call recordings).
+	SplitChannels      bool
+	ChannelLabels      []string
+	Preprocess         audio.PreprocessOptions
 	ContextSummary     string
 	CustomVocabulary   string
 	CustomSystemPrompt string
 	TranscriptionModel string
 	PostProcessModel   string
+	Language           string
+	// TranscriptionPrompt is sent to the transcription upstream as its
+	// prompt. Without it, the custom vocabulary is sent, unless disabled with
+	// WithVocabularyPrompt.
+	TranscriptionPrompt string
+	// WordTimestamps returns per-word timings in ProcessResult.Words.
+	WordTimestamps bool
+	// Segments returns segment timings in ProcessResult.Segments.
+	Segments bool
+	// Stages names analyzers, configured with WithAnalyzers, to run on the
+	// raw transcript alongside post-processing.
+	Stages []string
+	// Acronyms are expanded in the post-processed transcript.
+	Acronyms map[string]string
+	// CollapseSpellings and SpellingsToVocabulary are passed to
+	// post-processing; see postprocess.Input.
+	CollapseSpellings     bool
+	SpellingsToVocabulary bool
+	// EchoAudio returns the audio actually sent upstream, per part, in
+	// ProcessResult.Audio for debugging transcoding and trimming.
+	EchoAudio bool
+	// FallbackPolicy overrides the service's fallback policy when set.
+	FallbackPolicy string
+	// OnProgress, when set, is called as stages start and complete. It may be
+	// called from multiple goroutines, but never concurrently.
+	OnProgress func(ProgressEvent)
+	// OnStageComplete, when set, is called on the calling goroutine after each
+	// stage finishes successfully.
+	OnStageComplete func(StageResult)
 	// Deprecated: parsed for backward compatibility; debug prompts are no longer returned.
 	IncludeDebug bool
 }
@@ -39,24 +137,93 @@ type ProcessInput struct {
 type Timings struct {
 	Transcription  time.Duration
 	PostProcessing time.Duration
-	Total          time.Duration
+	// Stages holds each requested analyzer's duration by name.
+	Stages map[string]time.Duration
+	// Chunks times each chunk of uploads that were split for transcription.
+	Chunks []ChunkTiming
+	Total  time.Duration
+}
+
+// ChunkTiming is a transcribed chunk, with the part's label in multi-part
+// and split-channel runs.
+type ChunkTiming struct {
+	transcription.ChunkTiming
+	Speaker string
 }
 
 type ProcessResult struct {
 	RawTranscript        string
 	FinalTranscript      string
 	PostProcessingStatus string
-	PostProcessingUsage  *postprocess.TokenUsage
-	Timings              Timings
+	// PostProcessingOutcome is one of the Outcome values.
+	PostProcessingOutcome string
+	PostProcessingUsage   *postprocess.TokenUsage
+	// PostProcessingTier is set when two-tier post-processing chose the model.
+	PostProcessingTier string
+	// SpelledWords are the words post-processing collapsed from dictated
+	// spellings.
+	SpelledWords []string
+	// TranscriptionModel and PostProcessingParams are what the run resolved
+	// its settings to.
+	TranscriptionModel   string
+	PostProcessingParams postprocess.Params
+	Preprocessing        []string
+	Audio                []EchoedAudio
+	// Language is the detected language of the first part that reported one.
+	Language string
+	Warnings []string
+	// Words holds word timings when ProcessInput.WordTimestamps is set, in
+	// time order across parts.
+	Words []Word
+	// Segments holds the raw transcript's timed segments when
+	// ProcessInput.Segments is set, in time order across parts.
+	Segments []Segment
+	// Analyses holds the requested stages' results, in request order.
+	Analyses []AnalysisResult
+	Timings  Timings
+	// Cached is set when the result was served from a result cache instead
+	// of being computed for this request.
+	Cached bool
+}
+
+// Word is a word of the raw transcript. Speaker is the part's label in
+// multi-part and split-channel runs.
+type Word struct {
+	transcription.Word
+	Speaker string
 }
 
-func New(transcriber Transcriber, postProcessor PostProcessor, defaultTranscriptionModel, defaultPostProcessModel string) *Service {
-	return &Service{
+// Segment is a timed segment of the raw transcript, labeled like Word.
+type Segment struct {
+	transcription.Segment
+	Speaker string
+}
+
+// EchoedAudio is one file as sent to the transcription upstream.
+type EchoedAudio struct {
+	Label    string
+	FileName string
+	Data     []byte
+}
+
+// New resolves an empty transcription model to defaultTranscriptionModel. An
+// empty post-process model is left for the post-processor to resolve, since
+// two-tier post-processing picks the model itself.
+func New(transcriber Transcriber, postProcessor PostProcessor, defaultTranscriptionModel string, opts ...Option) *Service {
+	s := &Service{
 		transcriber:               transcriber,
 		postProcessor:             postProcessor,
 		defaultTranscriptionModel: strings.TrimSpace(defaultTranscriptionModel),
-		defaultPostProcessModel:   strings.TrimSpace(defaultPostProcessModel),
+		stageConcurrency:          DefaultStageConcurrency,
+		fallbackPolicy:            FallbackRaw,
+		vocabularyPrompt:          true,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
 	}
+	return s
 }
 
 func (s *Service) Process(ctx context.Context, in ProcessInput) (ProcessResult, error) {
@@ -68,46 +235,372 @@ func (s *Service) Process(ctx context.Context, in ProcessInput) (ProcessResult,
 		transcriptionModel = s.defaultTranscriptionModel
 	}
 	postProcessModel := strings.TrimSpace(in.PostProcessModel)
-	if postProcessModel == "" {
-		postProcessModel = s.defaultPostProcessModel
+	stages, err := s.checkStages(in.Stages)
+	if err != nil {
+		return ProcessResult{}, err
+	}
+	if err := CheckFallbackPolicy(in.FallbackPolicy); err != nil {
+		return ProcessResult{}, err
+	}
+	fallbackPolicy := cmp.Or(in.FallbackPolicy, s.fallbackPolicy)
+	in.TranscriptionPrompt = s.transcriptionPrompt(in)
+
+	parts := in.Parts
+	if in.SplitChannels {
+		parts, err = splitChannelParts(in)
+		if err != nil {
+			return ProcessResult{}, err
+		}
 	}
 
-	rawTranscript, err := s.transcriber.Transcribe(ctx, in.File, in.FileName, transcriptionModel)
+	progress := newProgressReporter(in.OnProgress)
+
+	var rawTranscript string
+	var preprocessing []string
+	var echoed []EchoedAudio
+	var detected partsResult
+	if len(parts) > 0 {
+		rawTranscript, preprocessing, echoed, detected, err = s.transcribeParts(ctx, parts, transcriptionModel, in, progress)
+	} else {
+		progress.report(StageTranscription, 0, 1)
+		var res transcription.Result
+		res, err = s.transcriber.Transcribe(ctx, transcription.Input{
+			File:            in.File,
+			FileName:        in.FileName,
+			Size:            in.FileSize,
+			Model:           transcriptionModel,
+			Language:        in.Language,
+			Prompt:          in.TranscriptionPrompt,
+			IncludeSegments: in.Segments,
+			IncludeWords:    in.WordTimestamps,
+			Preprocess:      in.Preprocess,
+			EchoAudio:       in.EchoAudio,
+		})
+		rawTranscript = res.Text
+		preprocessing = res.Preprocessing
+		detected = partsResult{language: res.Language, warnings: res.Warnings}
+		for _, chunk := range res.Chunks {
+			detected.chunks = append(detected.chunks, ChunkTiming{ChunkTiming: chunk})
+		}
+		for _, w := range res.Words {
+			detected.words = append(detected.words, Word{Word: w})
+		}
+		if in.Segments {
+			for _, seg := range res.Segments {
+				detected.segments = append(detected.segments, Segment{Segment: seg})
+			}
+		}
+		if in.EchoAudio && err == nil {
+			echoed = []EchoedAudio{{FileName: in.FileName, Data: res.Audio}}
+		}
+		if err == nil {
+			progress.report(StageTranscription, 1, 1)
+		}
+	}
 	transcriptionDuration := time.Since(transcriptionStarted)
 	if err != nil {
 		return ProcessResult{}, err
 	}
 	rawTranscript = strings.TrimSpace(rawTranscript)
-
-	postProcessingStarted := time.Now()
-	postResult, postErr := s.postProcessor.Process(ctx, postprocess.Input{
-		Transcript:         rawTranscript,
-		ContextSummary:     strings.TrimSpace(in.ContextSummary),
-		CustomVocabulary:   in.CustomVocabulary,
-		CustomSystemPrompt: in.CustomSystemPrompt,
-		Model:              postProcessModel,
-		IncludeDebugPrompt: in.IncludeDebug,
+	notifyStage(in.OnStageComplete, StageResult{
+		Stage:      StageTranscription,
+		Transcript: rawTranscript,
+		Duration:   transcriptionDuration,
 	})
-	postProcessingDuration := time.Since(postProcessingStarted)
+
+	progress.report(StagePostProcessing, 0, 1)
+	postInput := postprocess.Input{
+		Transcript:            rawTranscript,
+		ContextSummary:        strings.TrimSpace(in.ContextSummary),
+		CustomVocabulary:      in.CustomVocabulary,
+		CustomSystemPrompt:    in.CustomSystemPrompt,
+		Model:                 postProcessModel,
+		PreserveSpeakerLabels: len(parts) > 0,
+		Acronyms:              in.Acronyms,
+		CollapseSpellings:     in.CollapseSpellings,
+		SpellingsToVocabulary: in.SpellingsToVocabulary,
+		IncludeDebugPrompt:    in.IncludeDebug,
+	}
+	stageResults := s.runStages(ctx, postInput, stages)
+	postResult, postErr, postProcessingDuration := stageResults.post, stageResults.postErr, stageResults.postDuration
+	retried := false
+	if postErr != nil && fallbackPolicy == FallbackRetry && ctx.Err() == nil {
+		reqctx.Logger(ctx).Warn("post-processing failed, retrying", "error", postErr)
+		retryStarted := time.Now()
+		postResult, postErr = s.postProcessor.Process(ctx, postInput)
+		postProcessingDuration += time.Since(retryStarted)
+		retried = true
+	}
+	if postErr != nil && fallbackPolicy == FallbackError {
+		return ProcessResult{}, &PostProcessingError{Err: postErr}
+	}
+	progress.report(StagePostProcessing, 1, 1)
 
 	result := ProcessResult{
-		RawTranscript: rawTranscript,
+		RawTranscript:      rawTranscript,
+		TranscriptionModel: transcriptionModel,
+		Preprocessing:      preprocessing,
+		Audio:              echoed,
+		Language:           detected.language,
+		Warnings:           detected.warnings,
+		Words:              detected.words,
+		Segments:           detected.segments,
 		Timings: Timings{
 			Transcription:  transcriptionDuration,
 			PostProcessing: postProcessingDuration,
+			Chunks:         detected.chunks,
 			Total:          time.Since(started),
 		},
 	}
+	if len(stages) > 0 {
+		result.Analyses = stageResults.analyses
+		result.Timings.Stages = make(map[string]time.Duration, len(stages))
+		for _, a := range stageResults.analyses {
+			result.Timings.Stages[a.Stage] = a.Duration
+		}
+	}
 
 	if postErr != nil {
+		reqctx.Logger(ctx).Warn("post-processing failed, using raw transcript", "error", postErr)
 		result.FinalTranscript = rawTranscript
-		result.PostProcessingStatus = "Post-processing failed, using raw transcript"
-		return result, nil
+		result.PostProcessingStatus = PostProcessingFailed
+		result.PostProcessingOutcome = OutcomeFallback
+	} else {
+		result.FinalTranscript = strings.TrimSpace(postResult.Transcript)
+		result.PostProcessingStatus = PostProcessingSucceeded
+		result.PostProcessingOutcome = OutcomeSucceeded
+		if retried {
+			result.PostProcessingOutcome = OutcomeRetried
+		}
+		result.PostProcessingUsage = postResult.Usage
+		result.PostProcessingTier = postResult.Tier
+		result.SpelledWords = postResult.SpelledWords
+		result.PostProcessingParams = postResult.Params
+		result.Timings.Total = time.Since(started)
 	}
-
-	result.FinalTranscript = strings.TrimSpace(postResult.Transcript)
-	result.PostProcessingStatus = "Post-processing succeeded"
-	result.PostProcessingUsage = postResult.Usage
-	result.Timings.Total = time.Since(started)
+	notifyStage(in.OnStageComplete, StageResult{
+		Stage:      StagePostProcessing,
+		Transcript: result.FinalTranscript,
+		Status:     result.PostProcessingStatus,
+		Outcome:    result.PostProcessingOutcome,
+		Duration:   postProcessingDuration,
+	})
 	return result, nil
 }
+
+func notifyStage(fn func(StageResult), res StageResult) {
+	if fn != nil {
+		fn(res)
+	}
+}
+
+// partsResult is what transcription reports besides the transcript.
+type partsResult struct {
+	language string
+	warnings []string
+	words    []Word
+	segments []Segment
+	chunks   []ChunkTiming
+}
+
+type labeledSegment struct {
+	label string
+	part  int
+	transcription.Segment
+}
+
+func (s *Service) transcribeParts(ctx context.Context, parts []AudioPart, model string, in ProcessInput, progress *progressReporter) (string, []string, []EchoedAudio, partsResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress.report(StageTranscription, 0, len(parts))
+
+	results := make([]transcription.Result, len(parts))
+	errs := make([]error, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = s.transcriber.Transcribe(ctx, transcription.Input{
+				File:            part.File,
+				FileName:        part.FileName,
+				Size:            part.Size,
+				Model:           model,
+				Language:        in.Language,
+				Prompt:          in.TranscriptionPrompt,
+				IncludeSegments: true,
+				IncludeWords:    in.WordTimestamps,
+				Preprocess:      in.Preprocess,
+				EchoAudio:       in.EchoAudio,
+			})
+			if errs[i] != nil {
+				cancel()
+				return
+			}
+			progress.completePart(len(parts))
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return "", nil, nil, partsResult{}, fmt.Errorf("transcribe part %d (%s): %w", i+1, partLabel(parts[i], i), err)
+		}
+	}
+
+	// Each part was preprocessed on its own, but the transcriber maps its
+	// timings back onto the part's upload, so parts merge on the shared
+	// recording clock even when trim_silence cut different pauses from each.
+	var segments []labeledSegment
+	var preprocessing []string
+	var echoed []EchoedAudio
+	var detected partsResult
+	seenOps := map[string]bool{}
+	for i, res := range results {
+		if detected.language == "" {
+			detected.language = res.Language
+		}
+		for _, warning := range res.Warnings {
+			detected.warnings = append(detected.warnings, partLabel(parts[i], i)+": "+warning)
+		}
+		if in.EchoAudio {
+			echoed = append(echoed, EchoedAudio{Label: partLabel(parts[i], i), FileName: parts[i].FileName, Data: res.Audio})
+		}
+		for _, op := range res.Preprocessing {
+			if !seenOps[op] {
+				seenOps[op] = true
+				preprocessing = append(preprocessing, op)
+			}
+		}
+		label := partLabel(parts[i], i)
+		for _, chunk := range res.Chunks {
+			detected.chunks = append(detected.chunks, ChunkTiming{ChunkTiming: chunk, Speaker: label})
+		}
+		for _, w := range res.Words {
+			detected.words = append(detected.words, Word{Word: w, Speaker: label})
+		}
+		if len(res.Segments) == 0 {
+			if res.Text != "" {
+				segments = append(segments, labeledSegment{label: label, part: i, Segment: transcription.Segment{Text: res.Text}})
+			}
+			continue
+		}
+		for _, seg := range res.Segments {
+			if seg.Text != "" {
+				segments = append(segments, labeledSegment{label: label, part: i, Segment: seg})
+			}
+			if in.Segments {
+				detected.segments = append(detected.segments, Segment{Segment: seg, Speaker: label})
+			}
+		}
+	}
+	// Parts are transcribed in request order, so a stable sort keeps the
+	// earlier part first on ties, as mergeSegments does.
+	sort.SliceStable(detected.words, func(i, j int) bool { return detected.words[i].Start < detected.words[j].Start })
+	sort.SliceStable(detected.segments, func(i, j int) bool { return detected.segments[i].Start < detected.segments[j].Start })
+	return mergeSegments(segments), preprocessing, echoed, detected, nil
+}
+
+// mergeSegments interleaves segments from all parts by start time and folds
+// consecutive segments from the same speaker into a single labeled line.
+func mergeSegments(segments []labeledSegment) string {
+	sort.SliceStable(segments, func(i, j int) bool {
+		if segments[i].Start != segments[j].Start {
+			return segments[i].Start < segments[j].Start
+		}
+		return segments[i].part < segments[j].part
+	})
+
+	var lines []string
+	var current strings.Builder
+	currentPart := -1
+	for _, seg := range segments {
+		if seg.part != currentPart {
+			if current.Len() > 0 {
+				lines = append(lines, current.String())
+				current.Reset()
+			}
+			currentPart = seg.part
+			current.WriteString(seg.label)
+			current.WriteString(":")
+		}
+		current.WriteString(" ")
+		current.WriteString(seg.Text)
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func splitChannelParts(in ProcessInput) ([]AudioPart, error) {
+	data, err := io.ReadAll(in.File)
+	if err != nil {
+		return nil, err
+	}
+	wav, err := audio.DecodeWAV(data)
+	if err != nil {
+		return nil, err
+	}
+	if wav.Channels < 2 {
+		return nil, fmt.Errorf("%w: channel split requires multi-channel audio", audio.ErrInvalidAudio)
+	}
+
+	baseName := strings.TrimSuffix(in.FileName, ".wav")
+	if baseName == "" {
+		baseName = "audio"
+	}
+	channels := wav.SplitChannels()
+	parts := make([]AudioPart, len(channels))
+	for i, mono := range channels {
+		encoded := mono.Encode()
+		label := fmt.Sprintf("Channel %d", i+1)
+		if i < len(in.ChannelLabels) && strings.TrimSpace(in.ChannelLabels[i]) != "" {
+			label = strings.TrimSpace(in.ChannelLabels[i])
+		}
+		parts[i] = AudioPart{
+			File:     bytes.NewReader(encoded),
+			FileName: fmt.Sprintf("%s-ch%d.wav", baseName, i+1),
+			Size:     int64(len(encoded)),
+			Label:    label,
+		}
+	}
+	return parts, nil
+}
+
+type progressReporter struct {
+	mu             sync.Mutex
+	fn             func(ProgressEvent)
+	completedParts int
+}
+
+func newProgressReporter(fn func(ProgressEvent)) *progressReporter {
+	return &progressReporter{fn: fn}
+}
+
+func (p *progressReporter) report(stage string, completed, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emit(stage, completed, total)
+}
+
+func (p *progressReporter) completePart(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completedParts++
+	p.emit(StageTranscription, p.completedParts, total)
+}
+
+func (p *progressReporter) emit(stage string, completed, total int) {
+	if p.fn != nil {
+		p.fn(ProgressEvent{Stage: stage, Completed: completed, Total: total})
+	}
+}
+
+func partLabel(part AudioPart, index int) string {
+	if label := strings.TrimSpace(part.Label); label != "" {
+		return label
+	}
+	return fmt.Sprintf("Speaker %d", index+1)
+}
@@ -1,23 +1,48 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"echoflow/internal/audio"
 	"echoflow/internal/postprocess"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
 )
 
 type fakeTranscriber struct {
-	text string
-	err  error
+	text   string
+	err    error
+	prompt string
+}
+
+func (f *fakeTranscriber) Transcribe(_ context.Context, in transcription.Input) (transcription.Result, error) {
+	_, _ = io.ReadAll(in.File)
+	f.prompt = in.Prompt
+	return transcription.Result{Text: f.text}, f.err
 }
 
-func (f *fakeTranscriber) Transcribe(_ context.Context, file io.Reader, _ string, _ string) (string, error) {
-	_, _ = io.ReadAll(file)
-	return f.text, f.err
+type segmentTranscriber struct {
+	segments map[string][]transcription.Segment
+	words    map[string][]transcription.Word
+}
+
+func (f *segmentTranscriber) Transcribe(_ context.Context, in transcription.Input) (transcription.Result, error) {
+	if !in.IncludeSegments {
+		return transcription.Result{}, errors.New("segments not requested")
+	}
+	res := transcription.Result{Text: "ignored", Segments: f.segments[in.FileName]}
+	if in.IncludeWords {
+		res.Words = f.words[in.FileName]
+	}
+	return res, nil
 }
 
 type fakePostProcessor struct {
@@ -36,7 +61,6 @@ func TestProcessFallsBackToRawTranscriptOnPostProcessError(t *testing.T) {
 		&fakeTranscriber{text: "  raw transcript  "},
 		&fakePostProcessor{err: errors.New("boom")},
 		"whisper-large-v3",
-		"llama",
 	)
 
 	res, err := svc.Process(context.Background(), ProcessInput{
@@ -53,8 +77,8 @@ func TestProcessFallsBackToRawTranscriptOnPostProcessError(t *testing.T) {
 	if res.FinalTranscript != "raw transcript" {
 		t.Fatalf("expected fallback final transcript, got %q", res.FinalTranscript)
 	}
-	if res.PostProcessingStatus != "Post-processing failed, using raw transcript" {
-		t.Fatalf("unexpected status: %q", res.PostProcessingStatus)
+	if res.PostProcessingStatus != "Post-processing failed, using raw transcript" || res.PostProcessingOutcome != OutcomeFallback {
+		t.Fatalf("unexpected status: %q %q", res.PostProcessingStatus, res.PostProcessingOutcome)
 	}
 	if res.PostProcessingUsage != nil {
 		t.Fatalf("expected no usage on fallback, got %+v", res.PostProcessingUsage)
@@ -70,7 +94,7 @@ func TestProcessSuccessReturnsUsage(t *testing.T) {
 			TotalTokens:      120,
 		},
 	}}
-	svc := New(&fakeTranscriber{text: "raw"}, pp, "whisper", "llama")
+	svc := New(&fakeTranscriber{text: "raw"}, pp, "whisper")
 
 	res, err := svc.Process(context.Background(), ProcessInput{
 		File:         strings.NewReader("audio"),
@@ -90,3 +114,249 @@ func TestProcessSuccessReturnsUsage(t *testing.T) {
 		t.Fatal("expected IncludeDebugPrompt to still be forwarded for compatibility")
 	}
 }
+
+func TestProcessPromptsTranscriptionWithVocabulary(t *testing.T) {
+	tr := &fakeTranscriber{text: "raw"}
+	in := ProcessInput{File: strings.NewReader("audio"), CustomVocabulary: "Kubernetes\nGrafana, kubernetes"}
+	if _, err := New(tr, &fakePostProcessor{}, "whisper").Process(context.Background(), in); err != nil || tr.prompt != "Kubernetes, Grafana" {
+		t.Fatalf("vocabulary prompt = %q, err %v", tr.prompt, err)
+	}
+
+	in.File, in.TranscriptionPrompt = strings.NewReader("audio"), "Weekly sync with Dana."
+	if _, err := New(tr, &fakePostProcessor{}, "whisper").Process(context.Background(), in); err != nil || tr.prompt != "Weekly sync with Dana." {
+		t.Fatalf("explicit prompt = %q, err %v", tr.prompt, err)
+	}
+
+	in.File, in.TranscriptionPrompt = strings.NewReader("audio"), ""
+	if _, err := New(tr, &fakePostProcessor{}, "whisper", WithVocabularyPrompt(false)).Process(context.Background(), in); err != nil || tr.prompt != "" {
+		t.Fatalf("disabled prompt = %q, err %v", tr.prompt, err)
+	}
+}
+
+func TestTranscriptionPromptDropsTermsPastTheLimit(t *testing.T) {
+	terms := make([]string, 100)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("term-%02d", i)
+	}
+	prompt := New(nil, nil, "", nil).transcriptionPrompt(ProcessInput{CustomVocabulary: strings.Join(terms, "\n")})
+	if len(prompt) > maxVocabularyPromptBytes || !strings.HasSuffix(prompt, "term-65") {
+		t.Fatalf("prompt is %d bytes, ending %q", len(prompt), prompt[len(prompt)-10:])
+	}
+}
+
+func TestProcessMergesPartsChronologically(t *testing.T) {
+	tr := &segmentTranscriber{segments: map[string][]transcription.Segment{
+		"caller.wav": {
+			{Start: 0, End: 2 * time.Second, Text: "hi, I need help"},
+			{Start: 2 * time.Second, End: 3 * time.Second, Text: "with my order"},
+			{Start: 6 * time.Second, End: 7 * time.Second, Text: "thanks"},
+		},
+		"agent.wav": {
+			{Start: 4 * time.Second, End: 5 * time.Second, Text: "sure, what's the number?"},
+		},
+	}}
+	pp := &fakePostProcessor{result: postprocess.Result{Transcript: "clean"}}
+	svc := New(tr, pp, "whisper")
+
+	res, err := svc.Process(context.Background(), ProcessInput{
+		Parts: []AudioPart{
+			{File: strings.NewReader("a"), FileName: "caller.wav", Label: "Caller"},
+			{File: strings.NewReader("b"), FileName: "agent.wav"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	want := "Caller: hi, I need help with my order\nSpeaker 2: sure, what's the number?\nCaller: thanks"
+	if res.RawTranscript != want {
+		t.Fatalf("unexpected merged transcript:\n%s", res.RawTranscript)
+	}
+	if !pp.input.PreserveSpeakerLabels {
+		t.Fatal("expected speaker labels to be preserved during post-processing")
+	}
+}
+
+// upstreamByName answers each part with segments timed on the audio it was
+// sent, which is the trimmed audio when trim_silence is on.
+type upstreamByName map[string][]upstream.TranscriptionSegment
+
+func (u upstreamByName) Transcribe(_ context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	_, _ = io.ReadAll(req.File)
+	return upstream.TranscriptionResponse{Text: "ignored", Segments: u[req.FileName]}, nil
+}
+
+func TestProcessMergesTrimmedPartsOnTheirUploadTimeline(t *testing.T) {
+	tone := func(seconds float64) []float64 {
+		samples := make([]float64, int(seconds*8000))
+		for i := range samples {
+			samples[i] = 0.1
+		}
+		return samples
+	}
+	wav := func(samples ...[]float64) io.Reader {
+		return bytes.NewReader(audio.FromSamples([][]float64{slices.Concat(samples...)}, 8000).Encode())
+	}
+	// The caller's "thanks" starts at 3.5s of the upload but at 1.1s of the
+	// trimmed audio; the agent, who never pauses, answers at 2s.
+	client := upstreamByName{
+		"caller.wav": {{Start: 0, End: 0.5, Text: "hi"}, {Start: 1.1, End: 1.6, Text: "thanks"}},
+		"agent.wav":  {{Start: 2, End: 2.5, Text: "sure"}},
+	}
+	svc := New(transcription.New(client, "whisper", transcription.TimeoutPolicy{Base: time.Second}), &fakePostProcessor{}, "whisper")
+
+	res, err := svc.Process(context.Background(), ProcessInput{
+		Parts: []AudioPart{
+			{File: wav(tone(0.5), make([]float64, 24000), tone(0.5)), FileName: "caller.wav", Label: "Caller"},
+			{File: wav(tone(4)), FileName: "agent.wav", Label: "Agent"},
+		},
+		Preprocess: audio.PreprocessOptions{TrimSilence: true},
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if want := "Caller: hi\nAgent: sure\nCaller: thanks"; res.RawTranscript != want {
+		t.Fatalf("merged transcript:\n%s", res.RawTranscript)
+	}
+}
+
+func TestProcessMergesPartWordsByTime(t *testing.T) {
+	tr := &segmentTranscriber{
+		segments: map[string][]transcription.Segment{
+			"caller.wav": {{Start: 0, End: 2 * time.Second, Text: "hi there"}},
+			"agent.wav":  {{Start: time.Second, End: 2 * time.Second, Text: "hello"}},
+		},
+		words: map[string][]transcription.Word{
+			"caller.wav": {{Start: 0, End: 500 * time.Millisecond, Text: "hi"}, {Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "there"}},
+			"agent.wav":  {{Start: time.Second, End: 2 * time.Second, Text: "hello"}},
+		},
+	}
+	parts := func() []AudioPart {
+		return []AudioPart{
+			{File: strings.NewReader("a"), FileName: "caller.wav", Label: "Caller"},
+			{File: strings.NewReader("b"), FileName: "agent.wav", Label: "Agent"},
+		}
+	}
+	svc := New(tr, &fakePostProcessor{}, "whisper")
+
+	res, err := svc.Process(context.Background(), ProcessInput{Parts: parts(), WordTimestamps: true})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	var got []string
+	for _, w := range res.Words {
+		got = append(got, w.Speaker+":"+w.Text)
+	}
+	if strings.Join(got, " ") != "Caller:hi Agent:hello Caller:there" {
+		t.Fatalf("words = %v", got)
+	}
+
+	if res, err := svc.Process(context.Background(), ProcessInput{Parts: parts()}); err != nil || res.Words != nil || res.Segments != nil {
+		t.Fatalf("without WordTimestamps: words %v, segments %v, err %v", res.Words, res.Segments, err)
+	}
+
+	res, err = svc.Process(context.Background(), ProcessInput{Parts: parts(), Segments: true})
+	if err != nil || len(res.Segments) != 2 || res.Segments[0].Speaker != "Caller" || res.Segments[1].Text != "hello" || res.Segments[1].Speaker != "Agent" {
+		t.Fatalf("segments = %+v, err %v", res.Segments, err)
+	}
+}
+
+type channelTranscriber struct{}
+
+func (f *channelTranscriber) Transcribe(_ context.Context, in transcription.Input) (transcription.Result, error) {
+	data, _ := io.ReadAll(in.File)
+	wav, err := audio.DecodeWAV(data)
+	if err != nil || wav.Channels != 1 {
+		return transcription.Result{}, errors.New("expected mono wav")
+	}
+	return transcription.Result{Segments: []transcription.Segment{{Text: strings.TrimSuffix(in.FileName, ".wav")}}}, nil
+}
+
+func TestProcessSplitsStereoChannels(t *testing.T) {
+	stereo := &audio.WAV{Format: 1, Channels: 2, SampleRate: 8000, BitsPerSample: 16, Data: make([]byte, 16)}
+	tr := &channelTranscriber{}
+	svc := New(tr, &fakePostProcessor{result: postprocess.Result{Transcript: "clean"}}, "whisper")
+
+	res, err := svc.Process(context.Background(), ProcessInput{
+		File:          bytes.NewReader(stereo.Encode()),
+		FileName:      "call.wav",
+		SplitChannels: true,
+		ChannelLabels: []string{"Caller", "Agent"},
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if res.RawTranscript != "Caller: call-ch1\nAgent: call-ch2" {
+		t.Fatalf("unexpected transcript:\n%s", res.RawTranscript)
+	}
+}
+
+func TestProcessSplitRejectsMonoAudio(t *testing.T) {
+	mono := &audio.WAV{Format: 1, Channels: 1, SampleRate: 8000, BitsPerSample: 16, Data: make([]byte, 16)}
+	svc := New(&channelTranscriber{}, &fakePostProcessor{}, "whisper")
+
+	_, err := svc.Process(context.Background(), ProcessInput{
+		File:          bytes.NewReader(mono.Encode()),
+		SplitChannels: true,
+	})
+	if !errors.Is(err, audio.ErrInvalidAudio) {
+		t.Fatalf("expected ErrInvalidAudio, got %v", err)
+	}
+}
+
+func TestProcessReportsProgressPerPart(t *testing.T) {
+	tr := &segmentTranscriber{segments: map[string][]transcription.Segment{
+		"a.wav": {{Text: "one"}},
+		"b.wav": {{Text: "two"}},
+	}}
+	svc := New(tr, &fakePostProcessor{result: postprocess.Result{Transcript: "clean"}}, "whisper")
+
+	var events []ProgressEvent
+	_, err := svc.Process(context.Background(), ProcessInput{
+		Parts: []AudioPart{
+			{File: strings.NewReader("a"), FileName: "a.wav"},
+			{File: strings.NewReader("b"), FileName: "b.wav"},
+		},
+		OnProgress: func(ev ProgressEvent) { events = append(events, ev) },
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	want := []ProgressEvent{
+		{StageTranscription, 0, 2},
+		{StageTranscription, 1, 2},
+		{StageTranscription, 2, 2},
+		{StagePostProcessing, 0, 1},
+		{StagePostProcessing, 1, 1},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("event %d: got %+v want %+v", i, events[i], want[i])
+		}
+	}
+}
+
+func TestProcessReportsStageResults(t *testing.T) {
+	svc := New(&fakeTranscriber{text: " raw text "}, &fakePostProcessor{err: errors.New("boom")}, "whisper")
+
+	var stages []StageResult
+	_, err := svc.Process(context.Background(), ProcessInput{
+		File:            strings.NewReader("audio"),
+		OnStageComplete: func(res StageResult) { stages = append(stages, res) },
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(stages) != 2 {
+		t.Fatalf("unexpected stages: %+v", stages)
+	}
+	if stages[0].Stage != StageTranscription || stages[0].Transcript != "raw text" {
+		t.Fatalf("unexpected transcription stage: %+v", stages[0])
+	}
+	if stages[1].Stage != StagePostProcessing || stages[1].Transcript != "raw text" || stages[1].Status != "Post-processing failed, using raw transcript" || stages[1].Outcome != OutcomeFallback {
+		t.Fatalf("unexpected post-processing stage: %+v", stages[1])
+	}
+}
@@ -0,0 +1,162 @@
+package postprocess
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"echoflow/internal/deadline"
+	"echoflow/internal/upstream"
+)
+
+const chaptersPrompt = `Split the following timed transcript of a long recording, such as a podcast, into chapters.
+Each line starts with the second it begins at, in brackets. Start a chapter where the topic changes; prefer a few substantial chapters over many short ones. The first chapter starts at the first line.
+Give each chapter a short, specific title of at most eight words, in the transcript's language.
+Return only a JSON object of the form {"chapters": [{"start": <seconds from a line's brackets>, "title": "<title>"}]}.`
+
+// ErrNoChapters is returned when the model's reply holds no usable chapter.
+var ErrNoChapters = errors.New("chapters: model returned no usable chapters")
+
+// ChapterSegment is a timed span of the transcript to chapter.
+type ChapterSegment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+type ChaptersInput struct {
+	Segments []ChapterSegment
+	// Model overrides the configured chapters model.
+	Model string
+	// MaxChapters caps the number of chapters; 0 leaves it to the model.
+	MaxChapters int
+}
+
+// Chapter spans from its Start to the next chapter's, and the last one to
+// the end of the final segment.
+type Chapter struct {
+	Start time.Duration
+	End   time.Duration
+	Title string
+}
+
+type ChaptersResult struct {
+	Chapters []Chapter
+	Usage    *TokenUsage
+}
+
+// Chapterer splits a timed transcript into titled chapters with a JSON-mode
+// chat completion.
+type Chapterer struct {
+	client  ChatClient
+	model   string
+	timeout time.Duration
+}
+
+func NewChapterer(client ChatClient, model string, timeout time.Duration) *Chapterer {
+	return &Chapterer{client: client, model: strings.TrimSpace(model), timeout: timeout}
+}
+
+func (c *Chapterer) Chapters(ctx context.Context, in ChaptersInput) (ChaptersResult, error) {
+	if len(in.Segments) == 0 {
+		return ChaptersResult{}, nil
+	}
+	ctx, budget, cancel := deadline.Start(ctx, "chapters", c.timeout)
+	defer cancel()
+	segments := slices.SortedStableFunc(slices.Values(in.Segments), func(a, b ChapterSegment) int { return cmp.Compare(a.Start, b.Start) })
+
+	prompt := chaptersPrompt
+	if in.MaxChapters > 0 {
+		prompt += fmt.Sprintf("\nReturn at most %d chapters.", in.MaxChapters)
+	}
+	var transcript strings.Builder
+	for _, seg := range segments {
+		fmt.Fprintf(&transcript, "[%.1f] %s\n", seg.Start.Seconds(), strings.Join(strings.Fields(seg.Text), " "))
+	}
+	resp, err := c.client.ChatCompletion(ctx, upstream.ChatCompletionRequest{
+		Model:          cmp.Or(strings.TrimSpace(in.Model), c.model),
+		Temperature:    0.0,
+		ResponseFormat: &upstream.ChatResponseFormat{Type: "json_object"},
+		Messages: []upstream.ChatMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: transcript.String()},
+		},
+	})
+	if err != nil {
+		return ChaptersResult{}, budget.Explain(err)
+	}
+	chapters, err := parseChapters(resp.Content, segments, in.MaxChapters)
+	if err != nil {
+		return ChaptersResult{}, err
+	}
+	result := ChaptersResult{Chapters: chapters}
+	if resp.Usage != nil {
+		result.Usage = &TokenUsage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens, TotalTokens: resp.Usage.TotalTokens}
+	}
+	return result, nil
+}
+
+// parseChapters reads the model's reply for segments, sorted by start. Each
+// start is moved to the segment starting nearest to it, so chapters always
+// begin on a segment boundary, and the first chapter is stretched back to the
+// first segment.
+func parseChapters(content string, segments []ChapterSegment, maxChapters int) ([]Chapter, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```")
+	content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	var reply struct {
+		Chapters []struct {
+			Start float64 `json:"start"`
+			Title string  `json:"title"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal([]byte(content), &reply); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoChapters, err)
+	}
+
+	var chapters []Chapter
+	for _, ch := range reply.Chapters {
+		title := strings.Join(strings.Fields(ch.Title), " ")
+		if title == "" {
+			continue
+		}
+		start := nearestSegmentStart(segments, time.Duration(ch.Start*float64(time.Second)))
+		chapters = append(chapters, Chapter{Start: start, Title: title})
+	}
+	slices.SortStableFunc(chapters, func(a, b Chapter) int { return cmp.Compare(a.Start, b.Start) })
+	chapters = slices.CompactFunc(chapters, func(a, b Chapter) bool { return a.Start == b.Start })
+	if maxChapters > 0 && len(chapters) > maxChapters {
+		chapters = chapters[:maxChapters]
+	}
+	if len(chapters) == 0 {
+		return nil, ErrNoChapters
+	}
+
+	chapters[0].Start = segments[0].Start
+	var end time.Duration
+	for _, seg := range segments {
+		end = max(end, seg.End)
+	}
+	for i := range chapters {
+		chapters[i].End = end
+		if i+1 < len(chapters) {
+			chapters[i].End = chapters[i+1].Start
+		}
+	}
+	return chapters, nil
+}
+
+func nearestSegmentStart(segments []ChapterSegment, at time.Duration) time.Duration {
+	best := segments[0].Start
+	for _, seg := range segments[1:] {
+		if (seg.Start - at).Abs() < (best - at).Abs() {
+			best = seg.Start
+		}
+	}
+	return best
+}
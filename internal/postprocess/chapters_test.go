@@ -0,0 +1,50 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+func TestChaptersSnapToSegments(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{
+		Content: "```json\n" + `{"chapters": [{"start": 61, "title": "The  deploy"}, {"start": 3, "title": "Intro"}, {"start": 65, "title": "Duplicate"}, {"start": 130, "title": ""}]}` + "\n```",
+		Usage:   &upstream.TokenUsage{TotalTokens: 42},
+	}}
+	segments := []ChapterSegment{
+		{Start: 60 * time.Second, End: 120 * time.Second, Text: "Now the deploy."},
+		{Start: 2 * time.Second, End: 60 * time.Second, Text: "Hi all."},
+		{Start: 120 * time.Second, End: 150 * time.Second, Text: "Questions?"},
+	}
+	result, err := NewChapterer(client, "chapters-model", time.Second).Chapters(context.Background(), ChaptersInput{Segments: segments, MaxChapters: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Chapter{
+		{Start: 2 * time.Second, End: 60 * time.Second, Title: "Intro"},
+		{Start: 60 * time.Second, End: 150 * time.Second, Title: "The deploy"},
+	}
+	if len(result.Chapters) != len(want) || result.Chapters[0] != want[0] || result.Chapters[1] != want[1] {
+		t.Fatalf("chapters = %+v", result.Chapters)
+	}
+	if result.Usage == nil || result.Usage.TotalTokens != 42 {
+		t.Fatalf("usage = %+v", result.Usage)
+	}
+	if client.request.Model != "chapters-model" || client.request.ResponseFormat == nil || client.request.ResponseFormat.Type != "json_object" {
+		t.Fatalf("request = %+v", client.request)
+	}
+	prompt := client.request.Messages[0].Content.(string)
+	user := client.request.Messages[1].Content.(string)
+	if !strings.Contains(prompt, "at most 3 chapters") || !strings.HasPrefix(user, "[2.0] Hi all.\n[60.0] Now the deploy.") {
+		t.Fatalf("prompt = %q, transcript = %q", prompt, user)
+	}
+
+	client.resp.Content = "Here are your chapters!"
+	if _, err := NewChapterer(client, "m", time.Second).Chapters(context.Background(), ChaptersInput{Segments: segments}); !errors.Is(err, ErrNoChapters) {
+		t.Fatalf("err = %v, want ErrNoChapters", err)
+	}
+}
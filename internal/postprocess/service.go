@@ -3,10 +3,18 @@ package postprocess
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"echoflow/internal/upstream/openai"
+	"echoflow/internal/acronyms"
+	"echoflow/internal/deadline"
+	"echoflow/internal/upstream"
+
+	"golang.org/x/sync/errgroup"
 )
 
 const DefaultSystemPrompt = `You are a dictation post-processor. You receive raw speech-to-text output and return clean text ready to be typed into an application.
@@ -25,8 +33,12 @@ Output rules:
 
 const DefaultSystemPromptDate = "2026-02-24"
 
+const speakerLabelsPrompt = `The transcript is a conversation between several speakers. Each line starts with a speaker label followed by a colon.
+Keep every line, its speaker label, and the line order exactly as given; only clean up the text after each label.`
+
 type ChatClient interface {
-	ChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error)
+	StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error)
 }
 
 type TokenUsage struct {
@@ -41,6 +53,18 @@ type Input struct {
 	CustomVocabulary   string
 	CustomSystemPrompt string
 	Model              string
+	// PreserveSpeakerLabels is set when the transcript is a merged multi-speaker
+	// conversation whose "Label: text" lines must survive cleanup.
+	PreserveSpeakerLabels bool
+	// Acronyms are expanded in the model's output, so expansion does not
+	// depend on what the model chose to spell out.
+	Acronyms map[string]string
+	// CollapseSpellings replaces dictated spellings such as "K-A-T-R-I-N"
+	// with the word before the model sees the transcript; see
+	// CollapseSpellings. SpellingsToVocabulary also adds those words to the
+	// custom vocabulary.
+	CollapseSpellings     bool
+	SpellingsToVocabulary bool
 	// Deprecated: accepted for compatibility; prompts are no longer returned in API responses.
 	IncludeDebugPrompt bool
 }
@@ -48,32 +72,299 @@ type Input struct {
 type Result struct {
 	Transcript string
 	Usage      *TokenUsage
+	// Tier is TierSmall or TierLarge when two-tier processing chose the
+	// model, and empty otherwise.
+	Tier   string
+	Params Params
+	// SpelledWords are the words collapsed from dictated spellings.
+	SpelledWords []string
+}
+
+// Params are the settings the final chat request was built with, for
+// callers debugging what the server actually used.
+type Params struct {
+	Model string
+	// Prompt is "default@<DefaultSystemPromptDate>" or "custom".
+	Prompt string
+	// VocabularyTerms counts the distinct terms sent; VocabularyDropped the
+	// duplicates removed.
+	VocabularyTerms   int
+	VocabularyDropped int
+	LogitBiasTokens   int
+	// Context is ContextSummarized or ContextTruncated when the context
+	// summary was over MaxContextTokens, and empty otherwise.
+	Context          string
+	MaxContextTokens int
+}
+
+// How an oversized context summary was brought under the limit.
+const (
+	ContextSummarized = "summarized"
+	ContextTruncated  = "truncated"
+)
+
+// Tiers reported by two-tier processing.
+const (
+	TierSmall = "small"
+	TierLarge = "large"
+)
+
+// Tokenizer returns the token IDs model uses for text.
+type Tokenizer interface {
+	Tokenize(ctx context.Context, model, text string) ([]int, error)
+}
+
+type Option func(*Service)
+
+// WithLogitBias nudges the model toward custom vocabulary spellings by
+// adding bias to the logits of every token the terms are made of. Only
+// upstreams that accept logit_bias should be configured with it.
+func WithLogitBias(tokenizer Tokenizer, bias int) Option {
+	return func(s *Service) {
+		s.tokenizer = tokenizer
+		s.logitBias = max(-100, min(100, bias))
+	}
+}
+
+// WithOutputTag asks the model to write the transcript between <tag> and
+// </tag>, and stops generation at the closing tag. Only the text inside the
+// tags is returned, so preambles such as "Here is the cleaned text:" are
+// dropped. Output without the opening tag is used whole.
+func WithOutputTag(tag string) Option {
+	return func(s *Service) {
+		s.outputTag = strings.TrimSpace(tag)
+	}
+}
+
+// WithStopSequences adds stop sequences to every request.
+func WithStopSequences(stops []string) Option {
+	return func(s *Service) {
+		s.stops = stops
+	}
+}
+
+// WithContextSummarizer condenses a context summary estimated above
+// maxTokens with a separate call to model before the main request. Its token
+// usage is added to the Result.
+func WithContextSummarizer(model string, maxTokens int) Option {
+	return func(s *Service) {
+		s.summaryModel = strings.TrimSpace(model)
+		s.maxContextTokens = maxTokens
+	}
+}
+
+// WithEscalation tries smallModel first and only calls the default model
+// when the small model's output looks wrong: empty for real speech, much
+// shorter or longer than the transcript, more than maxDrift of its words not
+// in the transcript or vocabulary, or speaker lines lost. It applies to
+// Process when the caller did not pick a model; streams always use the
+// default model, since streamed text cannot be taken back.
+func WithEscalation(smallModel string, maxDrift float64) Option {
+	return func(s *Service) {
+		s.smallModel = strings.TrimSpace(smallModel)
+		s.maxDrift = maxDrift
+	}
 }
 
 type Service struct {
 	client       ChatClient
 	defaultModel string
+	smallModel   string
+	maxDrift     float64
 	timeout      time.Duration
+	outputTag    string
+	stops        []string
+
+	summaryModel     string
+	maxContextTokens int
+
+	tokenizer Tokenizer
+	logitBias int
+	tokenMu   sync.Mutex
+	tokens    map[tokenKey][]int
 }
 
-func New(client ChatClient, defaultModel string, timeout time.Duration) *Service {
-	return &Service{
+type tokenKey struct {
+	model string
+	text  string
+}
+
+// maxLogitBiasEntries is the OpenAI limit on logit_bias size.
+const maxLogitBiasEntries = 300
+
+const maxCachedTokenizations = 4096
+
+// maxTokenizeCalls caps the tokenizer requests one post-processing call
+// makes; texts past it go unbiased until a later request caches them.
+// tokenizeConcurrency is how many of those requests are in flight at once.
+const (
+	maxTokenizeCalls    = 32
+	tokenizeConcurrency = 4
+)
+
+func New(client ChatClient, defaultModel string, timeout time.Duration, opts ...Option) *Service {
+	s := &Service{
 		client:       client,
 		defaultModel: strings.TrimSpace(defaultModel),
 		timeout:      timeout,
 	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
 }
 
 func (s *Service) Process(ctx context.Context, in Input) (Result, error) {
+	ctx, budget, cancel := deadline.Start(ctx, "post_processing", s.timeout)
+	defer cancel()
+
+	in, spelled := collapseSpellings(in)
+	in, summaryUsage, condensed := s.condenseContext(ctx, in)
+	if s.smallModel != "" && strings.TrimSpace(in.Model) == "" {
+		result, err := s.processTiered(ctx, in, summaryUsage)
+		result.Params.Context = condensed
+		result.SpelledWords = spelled
+		return result, budget.Explain(err)
+	}
+	req := s.chatRequest(ctx, in)
+	chatResp, err := s.client.ChatCompletion(ctx, req)
+	if err != nil {
+		return Result{}, budget.Explain(err)
+	}
+	result := s.toResult(chatResp, in.Acronyms, summaryUsage)
+	result.Params = s.params(in, req, condensed)
+	result.SpelledWords = spelled
+	return result, nil
+}
+
+// collapseSpellings applies Input.CollapseSpellings and
+// Input.SpellingsToVocabulary.
+func collapseSpellings(in Input) (Input, []string) {
+	if !in.CollapseSpellings {
+		return in, nil
+	}
+	var spelled []string
+	in.Transcript, spelled = CollapseSpellings(in.Transcript)
+	if in.SpellingsToVocabulary && len(spelled) > 0 {
+		vocabulary := strings.Join(spelled, "\n")
+		if strings.TrimSpace(in.CustomVocabulary) != "" {
+			vocabulary = in.CustomVocabulary + "\n" + vocabulary
+		}
+		in.CustomVocabulary = vocabulary
+	}
+	return in, spelled
+}
+
+// processTiered runs the small model and escalates to the default model when
+// its output fails the drift checks or the call fails. Usage covers every
+// call made.
+func (s *Service) processTiered(ctx context.Context, in Input, summaryUsage *upstream.TokenUsage) (Result, error) {
+	small := in
+	small.Model = s.smallModel
+	req := s.chatRequest(ctx, small)
+	chatResp, err := s.client.ChatCompletion(ctx, req)
+	if err == nil && !needsEscalation(in, s.cleanedContent(chatResp.Content), s.maxDrift) {
+		result := s.toResult(chatResp, in.Acronyms, summaryUsage)
+		result.Tier = TierSmall
+		result.Params = s.params(in, req, "")
+		return result, nil
+	}
+	smallUsage := chatResp.Usage
+
+	req = s.chatRequest(ctx, in)
+	chatResp, err = s.client.ChatCompletion(ctx, req)
+	if err != nil {
+		return Result{}, err
+	}
+	result := s.toResult(chatResp, in.Acronyms, summaryUsage, smallUsage)
+	result.Tier = TierLarge
+	result.Params = s.params(in, req, "")
+	return result, nil
+}
+
+// needsEscalation flags small-model output that drifted from the transcript.
+// Short transcripts only get the checks that stay meaningful at their size.
+func needsEscalation(in Input, output string, maxDrift float64) bool {
+	raw := words(in.Transcript)
+	if len(raw) == 0 {
+		return false
+	}
+	out := words(output)
+	if len(out) == 0 {
+		return len(raw) >= 3
+	}
+	if len(raw) >= 5 {
+		ratio := float64(len(out)) / float64(len(raw))
+		if ratio < 0.5 || ratio > 1.5 {
+			return true
+		}
+	}
+	if in.PreserveSpeakerLabels && strings.Count(strings.TrimSpace(in.Transcript), "\n") != strings.Count(strings.TrimSpace(output), "\n") {
+		return true
+	}
+	if len(out) < 4 {
+		return false
+	}
+	known := make(map[string]bool, len(raw))
+	for _, w := range raw {
+		known[w] = true
+	}
+	for _, term := range VocabularyTerms(in.CustomVocabulary) {
+		for _, w := range words(term) {
+			known[w] = true
+		}
+	}
+	novel := 0
+	for _, w := range out {
+		if !known[w] {
+			novel++
+		}
+	}
+	return float64(novel)/float64(len(out)) > maxDrift
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// ProcessStream is Process with the model output forwarded to onDelta as it
+// is generated. Deltas are unsanitized but have acronyms expanded; the
+// returned Result is authoritative.
+func (s *Service) ProcessStream(ctx context.Context, in Input, onDelta func(string) error) (Result, error) {
+	ctx, budget, cancel := deadline.Start(ctx, "post_processing", s.timeout)
+	defer cancel()
+
+	onDelta, flush := expandingDeltas(in.Acronyms, onDelta)
+	if s.outputTag != "" {
+		onDelta = taggedDeltas(s.outputTag, onDelta)
+	}
+	in, spelled := collapseSpellings(in)
+	in, summaryUsage, condensed := s.condenseContext(ctx, in)
+	req := s.chatRequest(ctx, in)
+	chatResp, err := s.client.StreamChatCompletion(ctx, req, onDelta)
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return Result{}, budget.Explain(err)
+	}
+	result := s.toResult(chatResp, in.Acronyms, summaryUsage)
+	result.Params = s.params(in, req, condensed)
+	result.SpelledWords = spelled
+	return result, nil
+}
+
+func (s *Service) chatRequest(ctx context.Context, in Input) upstream.ChatCompletionRequest {
 	model := strings.TrimSpace(in.Model)
 	if model == "" {
 		model = s.defaultModel
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, s.timeout)
-	defer cancel()
-
-	vocabularyTerms := mergedVocabularyTerms(in.CustomVocabulary)
+	vocabularyTerms := VocabularyTerms(in.CustomVocabulary)
 	normalizedVocabulary := normalizedVocabularyText(vocabularyTerms)
 
 	vocabularyPrompt := ""
@@ -90,34 +381,268 @@ Use these spellings exactly in the output when relevant:
 	if vocabularyPrompt != "" {
 		systemPrompt += "\n\n" + vocabularyPrompt
 	}
+	if in.PreserveSpeakerLabels {
+		systemPrompt += "\n\n" + speakerLabelsPrompt
+	}
 
 	userMessage := fmt.Sprintf(`Instructions: Clean up RAW_TRANSCRIPTION and return only the cleaned transcript text without surrounding quotes. Return EMPTY if there should be no result.
 
 CONTEXT: %q
 
 RAW_TRANSCRIPTION: %q`, in.ContextSummary, in.Transcript)
+	stops := s.stops
+	if s.outputTag != "" {
+		userMessage += fmt.Sprintf("\n\nWrite the cleaned transcript (or EMPTY) between <%[1]s> and </%[1]s>, with nothing after </%[1]s>.", s.outputTag)
+		stops = append([]string{"</" + s.outputTag + ">"}, stops...)
+	}
 
-	chatResp, err := s.client.ChatCompletion(ctx, openai.ChatCompletionRequest{
+	return upstream.ChatCompletionRequest{
 		Model:       model,
 		Temperature: 0.0,
-		Messages: []openai.ChatMessage{
+		Messages: []upstream.ChatMessage{
 			{Role: "system", Content: systemPrompt},
 			{Role: "user", Content: userMessage},
 		},
-	})
+		LogitBias: s.vocabularyLogitBias(ctx, model, vocabularyTerms),
+		Stop:      stops,
+	}
+}
+
+// vocabularyLogitBias tokenizes each term both as written and with a leading
+// space, since BPE vocabularies encode a word differently mid-sentence. A
+// tokenizer failure only drops the bias; the prompt still lists the terms.
+// Requests sent to a caller's own endpoint get no bias, since the tokenizer
+// knows nothing of the model there.
+func (s *Service) vocabularyLogitBias(ctx context.Context, model string, terms []string) map[string]int {
+	if s.tokenizer == nil || s.logitBias == 0 || len(terms) == 0 || upstream.RequestBaseURLFromContext(ctx) != "" {
+		return nil
+	}
+	texts := make([]string, 0, 2*len(terms))
+	for _, term := range terms {
+		texts = append(texts, term, " "+term)
+	}
+	tokenized, err := s.tokenizeAll(ctx, model, texts)
 	if err != nil {
-		return Result{}, err
+		return nil
 	}
+	bias := make(map[string]int)
+	for _, ids := range tokenized {
+		for _, id := range ids {
+			if len(bias) == maxLogitBiasEntries {
+				return bias
+			}
+			bias[strconv.Itoa(id)] = s.logitBias
+		}
+	}
+	return bias
+}
 
-	result := Result{Transcript: sanitizePostProcessedTranscript(chatResp.Content)}
-	if chatResp.Usage != nil {
-		result.Usage = &TokenUsage{
-			PromptTokens:     chatResp.Usage.PromptTokens,
-			CompletionTokens: chatResp.Usage.CompletionTokens,
-			TotalTokens:      chatResp.Usage.TotalTokens,
+// tokenizeAll returns the token IDs of each text, from the cache where it
+// can. At most maxTokenizeCalls texts are sent to the tokenizer; the rest
+// are left nil.
+func (s *Service) tokenizeAll(ctx context.Context, model string, texts []string) ([][]int, error) {
+	out := make([][]int, len(texts))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(tokenizeConcurrency)
+	calls := 0
+	for i, text := range texts {
+		key := tokenKey{model: model, text: text}
+		s.tokenMu.Lock()
+		ids, ok := s.tokens[key]
+		s.tokenMu.Unlock()
+		if ok {
+			out[i] = ids
+			continue
+		}
+		if calls == maxTokenizeCalls {
+			continue
 		}
+		calls++
+		g.Go(func() error {
+			ids, err := s.tokenize(ctx, key)
+			out[i] = ids
+			return err
+		})
 	}
-	return result, nil
+	return out, g.Wait()
+}
+
+func (s *Service) tokenize(ctx context.Context, key tokenKey) ([]int, error) {
+	ids, err := s.tokenizer.Tokenize(ctx, key.model, key.text)
+	if err != nil {
+		return nil, err
+	}
+	s.tokenMu.Lock()
+	if s.tokens == nil || len(s.tokens) >= maxCachedTokenizations {
+		s.tokens = make(map[tokenKey][]int)
+	}
+	s.tokens[key] = ids
+	s.tokenMu.Unlock()
+	return ids, nil
+}
+
+func (s *Service) params(in Input, req upstream.ChatCompletionRequest, condensed string) Params {
+	terms := len(VocabularyTerms(in.CustomVocabulary))
+	prompt := "default@" + DefaultSystemPromptDate
+	if strings.TrimSpace(in.CustomSystemPrompt) != "" {
+		prompt = "custom"
+	}
+	return Params{
+		Model:             req.Model,
+		Prompt:            prompt,
+		VocabularyTerms:   terms,
+		VocabularyDropped: len(vocabularyFields(in.CustomVocabulary)) - terms,
+		LogitBiasTokens:   len(req.LogitBias),
+		Context:           condensed,
+		MaxContextTokens:  s.maxContextTokens,
+	}
+}
+
+// toResult extracts the transcript. extraUsage, from other calls made for
+// the same input, is added to the reported usage.
+func (s *Service) toResult(chatResp upstream.ChatCompletionResponse, dict map[string]string, extraUsage ...*upstream.TokenUsage) Result {
+	result := Result{Transcript: acronyms.Expand(s.cleanedContent(chatResp.Content), dict)}
+	for _, usage := range append([]*upstream.TokenUsage{chatResp.Usage}, extraUsage...) {
+		if usage == nil {
+			continue
+		}
+		if result.Usage == nil {
+			result.Usage = &TokenUsage{}
+		}
+		result.Usage.PromptTokens += usage.PromptTokens
+		result.Usage.CompletionTokens += usage.CompletionTokens
+		result.Usage.TotalTokens += usage.TotalTokens
+	}
+	return result
+}
+
+// cleanedContent is the transcript in a model response, before acronym
+// expansion.
+func (s *Service) cleanedContent(content string) string {
+	if s.outputTag != "" {
+		content = extractTagged(content, s.outputTag)
+	}
+	return sanitizePostProcessedTranscript(content)
+}
+
+const contextSummaryPrompt = `Condense the following background context for a dictation clean-up assistant.
+Keep every name, product, acronym, technical term and number exactly as spelled, and the topic of the conversation. Drop everything else.
+Return only the condensed context.`
+
+// estimateTokens approximates the token count of English text at four
+// characters per token, which is close enough for a threshold.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// condenseContext summarizes an oversized ContextSummary. If the summary
+// call fails, the context is cut to the limit instead so the main call
+// still fits. It reports which of the two happened, if either.
+func (s *Service) condenseContext(ctx context.Context, in Input) (Input, *upstream.TokenUsage, string) {
+	if s.maxContextTokens <= 0 || estimateTokens(in.ContextSummary) <= s.maxContextTokens {
+		return in, nil, ""
+	}
+	model := s.summaryModel
+	if model == "" {
+		model = s.defaultModel
+	}
+	resp, err := s.client.ChatCompletion(ctx, upstream.ChatCompletionRequest{
+		Model:       model,
+		Temperature: 0.0,
+		MaxTokens:   s.maxContextTokens,
+		Messages: []upstream.ChatMessage{
+			{Role: "system", Content: contextSummaryPrompt},
+			{Role: "user", Content: in.ContextSummary},
+		},
+	})
+	if summary := strings.TrimSpace(resp.Content); err == nil && summary != "" {
+		in.ContextSummary = summary
+		return in, resp.Usage, ContextSummarized
+	}
+	in.ContextSummary = truncateUTF8(in.ContextSummary, s.maxContextTokens*4)
+	return in, resp.Usage, ContextTruncated
+}
+
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// extractTagged returns the text between <tag> and </tag>. The closing tag is
+// usually missing because it is a stop sequence.
+func extractTagged(content, tag string) string {
+	_, inner, found := strings.Cut(content, "<"+tag+">")
+	if !found {
+		return content
+	}
+	inner, _, _ = strings.Cut(inner, "</"+tag+">")
+	return inner
+}
+
+// taggedDeltas forwards only the streamed text after <tag>. Text before it is
+// held back; if the tag never arrives nothing is forwarded and the final
+// Result still carries the whole output.
+func taggedDeltas(tag string, onDelta func(string) error) func(string) error {
+	open, closing := "<"+tag+">", "</"+tag+">"
+	var pending strings.Builder
+	started, done := false, false
+	return func(delta string) error {
+		if done {
+			return nil
+		}
+		if !started {
+			pending.WriteString(delta)
+			_, rest, found := strings.Cut(pending.String(), open)
+			if !found {
+				return nil
+			}
+			started, delta = true, rest
+			pending.Reset()
+		}
+		if before, _, found := strings.Cut(delta, closing); found {
+			done, delta = true, before
+		}
+		if delta == "" {
+			return nil
+		}
+		return onDelta(delta)
+	}
+}
+
+// expandingDeltas expands dict's acronyms in the streamed text. Acronyms
+// hold no spaces, so text after the last whitespace is held back until more
+// arrives, in case a term is split across deltas; flush sends what is left
+// once the stream has ended.
+func expandingDeltas(dict map[string]string, onDelta func(string) error) (func(string) error, func() error) {
+	if len(dict) == 0 {
+		return onDelta, func() error { return nil }
+	}
+	var held string
+	forward := func(delta string) error {
+		text := held + delta
+		cut := strings.LastIndexFunc(text, unicode.IsSpace)
+		if cut < 0 {
+			held = text
+			return nil
+		}
+		_, size := utf8.DecodeRuneInString(text[cut:])
+		held = text[cut+size:]
+		return onDelta(acronyms.Expand(text[:cut+size], dict))
+	}
+	flush := func() error {
+		if held == "" {
+			return nil
+		}
+		text := held
+		held = ""
+		return onDelta(acronyms.Expand(text, dict))
+	}
+	return forward, flush
 }
 
 func sanitizePostProcessedTranscript(value string) string {
@@ -134,18 +659,27 @@ func sanitizePostProcessedTranscript(value string) string {
 	return result
 }
 
-func mergedVocabularyTerms(rawVocabulary string) []string {
+// vocabularyFields splits raw custom vocabulary into its non-empty entries.
+func vocabularyFields(rawVocabulary string) []string {
 	fields := strings.FieldsFunc(rawVocabulary, func(r rune) bool {
 		return r == '\n' || r == ',' || r == ';'
 	})
+	entries := fields[:0]
+	for _, field := range fields {
+		if term := strings.TrimSpace(field); term != "" {
+			entries = append(entries, term)
+		}
+	}
+	return entries
+}
 
+// VocabularyTerms splits custom vocabulary on commas, semicolons and
+// newlines, dropping case-insensitive duplicates.
+func VocabularyTerms(rawVocabulary string) []string {
+	fields := vocabularyFields(rawVocabulary)
 	seen := make(map[string]struct{}, len(fields))
 	terms := make([]string, 0, len(fields))
-	for _, field := range fields {
-		term := strings.TrimSpace(field)
-		if term == "" {
-			continue
-		}
+	for _, term := range fields {
 		key := strings.ToLower(term)
 		if _, ok := seen[key]; ok {
 			continue
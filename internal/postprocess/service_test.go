@@ -2,26 +2,41 @@ package postprocess
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"echoflow/internal/upstream/openai"
+	"echoflow/internal/upstream"
 )
 
 type fakeChatClient struct {
-	request openai.ChatCompletionRequest
-	resp    openai.ChatCompletionResponse
+	request upstream.ChatCompletionRequest
+	resp    upstream.ChatCompletionResponse
 	err     error
 }
 
-func (f *fakeChatClient) ChatCompletion(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+func (f *fakeChatClient) ChatCompletion(_ context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
 	f.request = req
 	return f.resp, f.err
 }
 
-func TestMergedVocabularyTermsDedupesCaseInsensitively(t *testing.T) {
-	terms := mergedVocabularyTerms("Alice, bob\nALICE; Bob; Carol")
+func (f *fakeChatClient) StreamChatCompletion(_ context.Context, req upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	f.request = req
+	if f.err != nil {
+		return upstream.ChatCompletionResponse{}, f.err
+	}
+	for _, word := range strings.SplitAfter(f.resp.Content, " ") {
+		if err := onDelta(word); err != nil {
+			return upstream.ChatCompletionResponse{}, err
+		}
+	}
+	return f.resp, nil
+}
+
+func TestVocabularyTermsDedupesCaseInsensitively(t *testing.T) {
+	terms := VocabularyTerms("Alice, bob\nALICE; Bob; Carol")
 	got := strings.Join(terms, ",")
 	want := "Alice,bob,Carol"
 	if got != want {
@@ -29,6 +44,23 @@ func TestMergedVocabularyTermsDedupesCaseInsensitively(t *testing.T) {
 	}
 }
 
+func TestProcessReportsResolvedParams(t *testing.T) {
+	svc := New(&fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "ok"}}, "test-model", time.Second)
+	result, err := svc.Process(context.Background(), Input{Transcript: "x", CustomVocabulary: "Alice, alice; Project X,, "})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	want := Params{Model: "test-model", Prompt: "default@" + DefaultSystemPromptDate, VocabularyTerms: 2, VocabularyDropped: 1}
+	if result.Params != want {
+		t.Fatalf("Params = %+v, want %+v", result.Params, want)
+	}
+
+	result, _ = svc.Process(context.Background(), Input{Transcript: "x", Model: "other", CustomSystemPrompt: "Be terse."})
+	if result.Params.Model != "other" || result.Params.Prompt != "custom" {
+		t.Fatalf("Params = %+v", result.Params)
+	}
+}
+
 func TestSanitizePostProcessedTranscript(t *testing.T) {
 	cases := map[string]string{
 		"\"Hello world\"": "Hello world",
@@ -45,9 +77,9 @@ func TestSanitizePostProcessedTranscript(t *testing.T) {
 }
 
 func TestProcessBuildsPromptAndReturnsUsage(t *testing.T) {
-	client := &fakeChatClient{resp: openai.ChatCompletionResponse{
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{
 		Content: "\"Hello Alice\"",
-		Usage: &openai.TokenUsage{
+		Usage: &upstream.TokenUsage{
 			PromptTokens:     111,
 			CompletionTokens: 12,
 			TotalTokens:      123,
@@ -83,3 +115,291 @@ func TestProcessBuildsPromptAndReturnsUsage(t *testing.T) {
 		t.Fatalf("expected vocabulary in system prompt, got %q", systemContent)
 	}
 }
+
+func TestProcessStreamForwardsDeltasAndSanitizesResult(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{Content: `"Hello there world"`}}
+	svc := New(client, "llama", time.Second)
+
+	var deltas []string
+	res, err := svc.ProcessStream(context.Background(), Input{Transcript: "hello there world"}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+	if len(deltas) != 3 {
+		t.Fatalf("unexpected deltas: %q", deltas)
+	}
+	if res.Transcript != "Hello there world" {
+		t.Fatalf("unexpected transcript: %q", res.Transcript)
+	}
+	if client.request.Model != "llama" {
+		t.Fatalf("expected default model, got %q", client.request.Model)
+	}
+}
+
+type fakeTokenizer struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (f *fakeTokenizer) Tokenize(_ context.Context, model, text string) ([]int, error) {
+	f.calls.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if strings.HasPrefix(text, " ") {
+		return []int{len(text) * 100}, nil
+	}
+	return []int{len(text), len(text) + 1}, nil
+}
+
+func TestProcessAddsVocabularyLogitBias(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "ok"}}
+	tokenizer := &fakeTokenizer{}
+	svc := New(client, "model-a", time.Second, WithLogitBias(tokenizer, 7))
+
+	for range 2 {
+		if _, err := svc.Process(context.Background(), Input{Transcript: "hi", CustomVocabulary: "Kubernetes, Kubernetes"}); err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+	}
+	want := map[string]int{"10": 7, "11": 7, "1100": 7}
+	if len(client.request.LogitBias) != len(want) {
+		t.Fatalf("LogitBias = %v, want %v", client.request.LogitBias, want)
+	}
+	for id, bias := range want {
+		if client.request.LogitBias[id] != bias {
+			t.Fatalf("LogitBias = %v, want %v", client.request.LogitBias, want)
+		}
+	}
+	if tokenizer.calls.Load() != 2 {
+		t.Fatalf("tokenizer calls = %d, want 2 (cached after the first request)", tokenizer.calls.Load())
+	}
+
+	failing := New(client, "model-a", time.Second, WithLogitBias(&fakeTokenizer{err: context.DeadlineExceeded}, 7))
+	if _, err := failing.Process(context.Background(), Input{Transcript: "hi", CustomVocabulary: "Kubernetes"}); err != nil {
+		t.Fatalf("Process with failing tokenizer: %v", err)
+	}
+	if client.request.LogitBias != nil {
+		t.Fatalf("LogitBias = %v, want none when tokenization fails", client.request.LogitBias)
+	}
+}
+
+func TestProcessCapsTokenizerCallsPerRequest(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "ok"}}
+	tokenizer := &fakeTokenizer{}
+	svc := New(client, "model-a", time.Second, WithLogitBias(tokenizer, 7))
+
+	terms := make([]string, 40)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("term%d", i)
+	}
+	vocabulary := strings.Join(terms, ", ")
+	if _, err := svc.Process(context.Background(), Input{Transcript: "hi", CustomVocabulary: vocabulary}); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got := tokenizer.calls.Load(); got != maxTokenizeCalls {
+		t.Fatalf("tokenizer calls = %d, want %d", got, maxTokenizeCalls)
+	}
+	// The next request picks up where the cap left off.
+	if _, err := svc.Process(context.Background(), Input{Transcript: "hi", CustomVocabulary: vocabulary}); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got := tokenizer.calls.Load(); got != 2*maxTokenizeCalls {
+		t.Fatalf("tokenizer calls after two requests = %d, want %d", got, 2*maxTokenizeCalls)
+	}
+}
+
+func TestProcessExpandsAcronymsInOutput(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "Send the PR by EOD."}}
+	svc := New(client, "model-a", time.Second)
+
+	result, err := svc.Process(context.Background(), Input{Transcript: "send the pr by eod", Acronyms: map[string]string{"EOD": "end of day"}})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.Transcript != "Send the PR by end of day." {
+		t.Fatalf("Transcript = %q", result.Transcript)
+	}
+}
+
+func TestProcessCollapsesSpellingsIntoVocabulary(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "That's Katrin from sales."}}
+	svc := New(client, "model-a", time.Second)
+
+	result, err := svc.Process(context.Background(), Input{
+		Transcript:            "that's K-A-T-R-I-N from sales",
+		CustomVocabulary:      "Groq",
+		CollapseSpellings:     true,
+		SpellingsToVocabulary: true,
+	})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(result.SpelledWords) != 1 || result.SpelledWords[0] != "Katrin" {
+		t.Fatalf("SpelledWords = %q", result.SpelledWords)
+	}
+	user, _ := client.request.Messages[1].Content.(string)
+	if !strings.Contains(user, "that's Katrin from sales") || strings.Contains(user, "K-A-T") {
+		t.Fatalf("user message = %q", user)
+	}
+	if system, _ := client.request.Messages[0].Content.(string); !strings.Contains(system, "Groq, Katrin") {
+		t.Fatalf("system message = %q", system)
+	}
+}
+
+func TestOutputTagExtractsTranscriptAndSetsStop(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "Here is the cleaned text:\n<transcript>Hello Alice."}}
+	svc := New(client, "m", time.Second, WithOutputTag("transcript"), WithStopSequences([]string{"\n\n"}))
+
+	result, err := svc.Process(context.Background(), Input{Transcript: "hello alice"})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.Transcript != "Hello Alice." {
+		t.Fatalf("Transcript = %q", result.Transcript)
+	}
+	if got := client.request.Stop; len(got) != 2 || got[0] != "</transcript>" || got[1] != "\n\n" {
+		t.Fatalf("Stop = %q", got)
+	}
+	if user, _ := client.request.Messages[1].Content.(string); !strings.Contains(user, "between <transcript> and </transcript>") {
+		t.Fatalf("user message lacks the delimiter contract: %q", user)
+	}
+
+	client.resp.Content = "Hello Alice, no tags."
+	if result, _ := svc.Process(context.Background(), Input{Transcript: "x"}); result.Transcript != "Hello Alice, no tags." {
+		t.Fatalf("untagged Transcript = %q", result.Transcript)
+	}
+}
+
+func TestOutputTagHoldsBackStreamedPreamble(t *testing.T) {
+	client := &fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "Sure! <transcript>Hello there</transcript> trailing"}}
+	svc := New(client, "m", time.Second, WithOutputTag("transcript"))
+
+	var streamed strings.Builder
+	result, err := svc.ProcessStream(context.Background(), Input{Transcript: "x"}, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessStream: %v", err)
+	}
+	if streamed.String() != "Hello there" || result.Transcript != "Hello there" {
+		t.Fatalf("streamed = %q, result = %q", streamed.String(), result.Transcript)
+	}
+}
+
+// byteStreamClient streams its content one byte per delta, so terms are
+// split across deltas.
+type byteStreamClient struct {
+	fakeChatClient
+}
+
+func (f *byteStreamClient) StreamChatCompletion(_ context.Context, _ upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	for i := range len(f.resp.Content) {
+		if err := onDelta(f.resp.Content[i : i+1]); err != nil {
+			return upstream.ChatCompletionResponse{}, err
+		}
+	}
+	return f.resp, nil
+}
+
+func TestProcessStreamExpandsAcronymsInDeltas(t *testing.T) {
+	client := &byteStreamClient{fakeChatClient{resp: upstream.ChatCompletionResponse{Content: "Ship it by EOD, not EODs. PR EOD"}}}
+	svc := New(client, "m", time.Second)
+
+	var streamed strings.Builder
+	result, err := svc.ProcessStream(context.Background(), Input{Transcript: "x", Acronyms: map[string]string{"EOD": "end of day", "PR": "pull request"}}, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessStream: %v", err)
+	}
+	want := "Ship it by end of day, not EODs. pull request end of day"
+	if streamed.String() != want || result.Transcript != want {
+		t.Fatalf("streamed = %q, result = %q", streamed.String(), result.Transcript)
+	}
+}
+
+type scriptedChatClient struct {
+	requests  []upstream.ChatCompletionRequest
+	responses []upstream.ChatCompletionResponse
+}
+
+func (f *scriptedChatClient) ChatCompletion(_ context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	f.requests = append(f.requests, req)
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	return resp, nil
+}
+
+func (f *scriptedChatClient) StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, _ func(string) error) (upstream.ChatCompletionResponse, error) {
+	return f.ChatCompletion(ctx, req)
+}
+
+func TestProcessSummarizesOversizedContext(t *testing.T) {
+	client := &scriptedChatClient{responses: []upstream.ChatCompletionResponse{
+		{Content: "Email thread with Alice about Project X.", Usage: &upstream.TokenUsage{PromptTokens: 300, CompletionTokens: 10, TotalTokens: 310}},
+		{Content: "Hello Alice", Usage: &upstream.TokenUsage{PromptTokens: 50, CompletionTokens: 3, TotalTokens: 53}},
+	}}
+	svc := New(client, "big-model", time.Second, WithContextSummarizer("small-model", 100))
+
+	result, err := svc.Process(context.Background(), Input{Transcript: "hello alise", ContextSummary: strings.Repeat("Alice and Project X. ", 40)})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(client.requests) != 2 || client.requests[0].Model != "small-model" || client.requests[1].Model != "big-model" {
+		t.Fatalf("requests = %+v", client.requests)
+	}
+	if user, _ := client.requests[1].Messages[1].Content.(string); !strings.Contains(user, "Email thread with Alice about Project X.") {
+		t.Fatalf("main request does not carry the summary: %q", user)
+	}
+	if result.Usage == nil || result.Usage.PromptTokens != 350 || result.Usage.TotalTokens != 363 {
+		t.Fatalf("Usage = %+v", result.Usage)
+	}
+	if result.Params.Context != ContextSummarized || result.Params.MaxContextTokens != 100 || result.Params.Model != "big-model" {
+		t.Fatalf("Params = %+v", result.Params)
+	}
+
+	short := &scriptedChatClient{responses: []upstream.ChatCompletionResponse{{Content: "ok"}}}
+	if _, err := New(short, "m", time.Second, WithContextSummarizer("small-model", 100)).Process(context.Background(), Input{Transcript: "x", ContextSummary: "short"}); err != nil || len(short.requests) != 1 {
+		t.Fatalf("short context made %d requests, err %v", len(short.requests), err)
+	}
+}
+
+func TestProcessEscalatesDriftedSmallModelOutput(t *testing.T) {
+	transcript := "um so we should ship the release on friday"
+	client := &scriptedChatClient{responses: []upstream.ChatCompletionResponse{
+		{Content: "We should ship the release on Friday.", Usage: &upstream.TokenUsage{TotalTokens: 40}},
+	}}
+	svc := New(client, "big-model", time.Second, WithEscalation("small-model", 0.3))
+	result, err := svc.Process(context.Background(), Input{Transcript: transcript})
+	if err != nil || result.Tier != TierSmall || len(client.requests) != 1 || client.requests[0].Model != "small-model" {
+		t.Fatalf("faithful output: tier %q, %d requests, err %v", result.Tier, len(client.requests), err)
+	}
+
+	client = &scriptedChatClient{responses: []upstream.ChatCompletionResponse{
+		{Content: "Here is a summary of the planned launch schedule for the team.", Usage: &upstream.TokenUsage{TotalTokens: 40}},
+		{Content: "We should ship the release on Friday.", Usage: &upstream.TokenUsage{TotalTokens: 90}},
+	}}
+	svc = New(client, "big-model", time.Second, WithEscalation("small-model", 0.3))
+	result, err = svc.Process(context.Background(), Input{Transcript: transcript})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.Tier != TierLarge || result.Transcript != "We should ship the release on Friday." || client.requests[1].Model != "big-model" {
+		t.Fatalf("drifted output: tier %q, transcript %q", result.Tier, result.Transcript)
+	}
+	if result.Usage == nil || result.Usage.TotalTokens != 130 {
+		t.Fatalf("Usage = %+v", result.Usage)
+	}
+
+	client = &scriptedChatClient{responses: []upstream.ChatCompletionResponse{{Content: "ok"}}}
+	svc = New(client, "big-model", time.Second, WithEscalation("small-model", 0.3))
+	if result, _ := svc.Process(context.Background(), Input{Transcript: "ok", Model: "chosen"}); result.Tier != "" || client.requests[0].Model != "chosen" {
+		t.Fatalf("explicit model: tier %q, model %q", result.Tier, client.requests[0].Model)
+	}
+}
@@ -0,0 +1,175 @@
+package postprocess
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// spellingGap is what may sit between a spelling and the word it confirms.
+const spellingGap = " \t\n,.:;…-–—"
+
+// minUnconfirmedSpaced is the fewest letters a spaced spelling needs when no
+// spoken word confirms it; shorter runs are initials ("J. R. R. Tolkien") or
+// spoken acronyms ("the U S A").
+const minUnconfirmedSpaced = 4
+
+var spacedSeparator = regexp.MustCompile(`^\.?,?\s+$`)
+
+// CollapseSpellings replaces dictated spellings in text with the word they
+// spell, and returns the words in the order they were first spelled. A
+// spelling is three or more single letters joined by hyphens ("K-A-T-R-I-N"),
+// or capitals separated by spaces, commas or dots ("K A T", "K. A. T.").
+// When the word is also said next to its spelling, as in "Katrin, K-A-T-R-I-N"
+// or "K-A-T-R-I-N… Katrin", the spelling is dropped and the spoken word kept;
+// otherwise the letters are joined and capitalized like a name. Capitals
+// separated by spaces are common in ordinary speech, so without a spoken word
+// they only count from four letters, and never when every letter is the
+// pronoun "I" ("so I, I, I think").
+func CollapseSpellings(text string) (string, []string) {
+	var b strings.Builder
+	var spelled []string
+	seen := map[string]bool{}
+	last := 0
+	for _, span := range spellingSpans(text) {
+		start, end := span.start, span.end
+		letters := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) {
+				return r
+			}
+			return -1
+		}, text[start:end])
+		word := capitalize(strings.ToLower(letters))
+
+		// Said just before the spelling: keep the text up to the spoken
+		// word and drop the gap and the spelling.
+		before := strings.TrimRight(text[last:start], spellingGap)
+		prev := lastWord(before)
+		next, nextEnd := firstWord(text[end:])
+		if prev != "" && strings.EqualFold(prev, letters) {
+			word = capitalize(prev)
+			b.WriteString(before[:len(before)-len(prev)])
+			b.WriteString(word)
+			last = end
+		} else if next != "" && strings.EqualFold(next, letters) {
+			word = capitalize(next)
+			b.WriteString(text[last:start])
+			b.WriteString(word)
+			last = end + nextEnd
+		} else if span.spaced && (utf8.RuneCountInString(letters) < minUnconfirmedSpaced || strings.Trim(letters, "I") == "") {
+			continue
+		} else {
+			b.WriteString(text[last:start])
+			b.WriteString(word)
+			last = end
+		}
+		if key := strings.ToLower(word); !seen[key] {
+			seen[key] = true
+			spelled = append(spelled, word)
+		}
+	}
+	if spelled == nil {
+		return text, nil
+	}
+	b.WriteString(text[last:])
+	return b.String(), spelled
+}
+
+// spelling is the byte range of a run of letters in text; spaced runs are
+// separated by spaces, commas or dots rather than hyphens.
+type spelling struct {
+	start, end int
+	spaced     bool
+}
+
+// spellingSpans finds the spellings in text.
+func spellingSpans(text string) []spelling {
+	var words [][2]int
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+		end := i + size
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isWordRune(r) {
+				break
+			}
+			end += size
+		}
+		words = append(words, [2]int{i, end})
+		i = end
+	}
+
+	var spans []spelling
+	for i := 0; i < len(words); {
+		j := i
+		hyphens := false
+		for j+1 < len(words) && isLetter(text, words[j]) && isLetter(text, words[j+1]) {
+			sep := text[words[j][1]:words[j+1][0]]
+			isHyphen := sep == "-" || sep == "–"
+			isSpaced := spacedSeparator.MatchString(sep) && isUpper(text, words[j]) && isUpper(text, words[j+1])
+			if j == i {
+				hyphens = isHyphen
+			}
+			if (hyphens && !isHyphen) || (!hyphens && !isSpaced) {
+				break
+			}
+			j++
+		}
+		if j-i >= 2 {
+			spans = append(spans, spelling{start: words[i][0], end: words[j][1], spaced: !hyphens})
+			i = j + 1
+			continue
+		}
+		i++
+	}
+	return spans
+}
+
+// isLetter reports whether the word at span is a single letter.
+func isLetter(text string, span [2]int) bool {
+	r, size := utf8.DecodeRuneInString(text[span[0]:])
+	return size == span[1]-span[0] && unicode.IsLetter(r)
+}
+
+func isUpper(text string, span [2]int) bool {
+	r, _ := utf8.DecodeRuneInString(text[span[0]:])
+	return unicode.IsUpper(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
+
+// lastWord is the run of letters that text ends with.
+func lastWord(text string) string {
+	i := strings.LastIndexFunc(text, func(r rune) bool { return !unicode.IsLetter(r) })
+	if i < 0 {
+		return text
+	}
+	_, size := utf8.DecodeRuneInString(text[i:])
+	return text[i+size:]
+}
+
+// firstWord is the run of letters after any spelling gap at the start of
+// text, and the offset just past it.
+func firstWord(text string) (string, int) {
+	start := len(text) - len(strings.TrimLeft(text, spellingGap))
+	end := strings.IndexFunc(text[start:], func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(text) - start
+	}
+	return text[start : start+end], start + end
+}
+
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	if size == 0 {
+		return word
+	}
+	return string(unicode.ToUpper(r)) + word[size:]
+}
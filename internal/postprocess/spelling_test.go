@@ -0,0 +1,32 @@
+package postprocess
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCollapseSpellings(t *testing.T) {
+	tests := []struct {
+		in, want string
+		words    []string
+	}{
+		{in: "that's K-A-T-R-I-N… Katrin, from sales", want: "that's Katrin, from sales", words: []string{"Katrin"}},
+		{in: "my name is katrin, K-A-T-R-I-N, and I", want: "my name is Katrin, and I", words: []string{"Katrin"}},
+		{in: "the surname is O. B. E. R. G.", want: "the surname is Oberg.", words: []string{"Oberg"}},
+		{in: "ask S-Ø-R-E-N and then S-Ø-R-E-N again", want: "ask Søren and then Søren again", words: []string{"Søren"}},
+		{in: "plan B, then A and C; I a b c", want: "plan B, then A and C; I a b c"},
+		{in: "the X-ray and T-shirt", want: "the X-ray and T-shirt"},
+		{in: "so I, I, I think", want: "so I, I, I think"},
+		{in: "so I, I, I, I think", want: "so I, I, I, I think"},
+		{in: "J. R. R. Tolkien wrote it", want: "J. R. R. Tolkien wrote it"},
+		{in: "made in the U S A", want: "made in the U S A"},
+		{in: "Kat, K A T, from sales", want: "Kat, from sales", words: []string{"Kat"}},
+		{in: "it's K A T R I N from sales", want: "it's Katrin from sales", words: []string{"Katrin"}},
+	}
+	for _, tt := range tests {
+		got, words := CollapseSpellings(tt.in)
+		if got != tt.want || !slices.Equal(words, tt.words) {
+			t.Errorf("CollapseSpellings(%q) = %q, %q; want %q, %q", tt.in, got, words, tt.want, tt.words)
+		}
+	}
+}
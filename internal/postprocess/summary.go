@@ -0,0 +1,51 @@
+package postprocess
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"echoflow/internal/deadline"
+	"echoflow/internal/upstream"
+)
+
+const summaryPrompt = `Summarize the following speech-to-text transcript for someone who did not hear it.
+Write two to five short sentences in the transcript's language. Keep names, numbers, decisions and action items. Do not add anything that was not said.
+Return only the summary.`
+
+// Summarizer writes a short summary of a raw transcript. It is independent of
+// clean-up, so the pipeline can run it alongside Process.
+type Summarizer struct {
+	client  ChatClient
+	model   string
+	timeout time.Duration
+}
+
+func NewSummarizer(client ChatClient, model string, timeout time.Duration) *Summarizer {
+	return &Summarizer{client: client, model: strings.TrimSpace(model), timeout: timeout}
+}
+
+func (s *Summarizer) Analyze(ctx context.Context, transcript string) (string, *TokenUsage, error) {
+	if strings.TrimSpace(transcript) == "" {
+		return "", nil, nil
+	}
+	ctx, budget, cancel := deadline.Start(ctx, "summary", s.timeout)
+	defer cancel()
+
+	resp, err := s.client.ChatCompletion(ctx, upstream.ChatCompletionRequest{
+		Model:       s.model,
+		Temperature: 0.0,
+		Messages: []upstream.ChatMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: transcript},
+		},
+	})
+	if err != nil {
+		return "", nil, budget.Explain(err)
+	}
+	var usage *TokenUsage
+	if resp.Usage != nil {
+		usage = &TokenUsage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens, TotalTokens: resp.Usage.TotalTokens}
+	}
+	return strings.TrimSpace(resp.Content), usage, nil
+}
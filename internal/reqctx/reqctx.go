@@ -0,0 +1,84 @@
+// Package reqctx holds request-scoped values shared by middleware, services
+// and background jobs. Each value has a typed setter and getter over an
+// unexported key, so packages cannot collide or disagree on a value's type.
+package reqctx
+
+import (
+	"context"
+	"log/slog"
+)
+
+// KeySource says whose credentials pay for a request's upstream calls.
+type KeySource string
+
+const (
+	// KeySourceCaller is a BYOT token forwarded to the upstream.
+	KeySourceCaller KeySource = "caller"
+	// KeySourceServer is the server's own upstream key, used for anonymous
+	// callers and EchoFlow tokens.
+	KeySourceServer KeySource = "server"
+)
+
+type (
+	requestIDKey struct{}
+	tenantKey    struct{}
+	keySourceKey struct{}
+	priorityKey  struct{}
+	loggerKey    struct{}
+)
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request's ID, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the calling token's tenant, or "" when it has none.
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+func WithAPIKeySource(ctx context.Context, source KeySource) context.Context {
+	return context.WithValue(ctx, keySourceKey{}, source)
+}
+
+// APIKeySource returns where the request's upstream key comes from, or ""
+// when authentication has not run.
+func APIKeySource(ctx context.Context) KeySource {
+	source, _ := ctx.Value(keySourceKey{}).(KeySource)
+	return source
+}
+
+// WithPriority sets the request's service tier, such as "premium".
+func WithPriority(ctx context.Context, priority string) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// Priority returns the request's service tier, or "" when none was set.
+func Priority(ctx context.Context) string {
+	priority, _ := ctx.Value(priorityKey{}).(string)
+	return priority
+}
+
+// WithLogger attaches a logger that already carries the request's fields,
+// such as request_id, tenant and route.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the request's logger, or slog.Default() outside a request.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,21 @@
+package reqctx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAccessorsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	if RequestID(ctx) != "" || Tenant(ctx) != "" || APIKeySource(ctx) != "" || Priority(ctx) != "" {
+		t.Fatalf("expected zero values on an empty context")
+	}
+
+	ctx = WithRequestID(ctx, "req-1")
+	ctx = WithTenant(ctx, "acme")
+	ctx = WithAPIKeySource(ctx, KeySourceCaller)
+	ctx = WithPriority(ctx, "premium")
+	if RequestID(ctx) != "req-1" || Tenant(ctx) != "acme" || APIKeySource(ctx) != KeySourceCaller || Priority(ctx) != "premium" {
+		t.Fatalf("unexpected values: %q %q %q %q", RequestID(ctx), Tenant(ctx), APIKeySource(ctx), Priority(ctx))
+	}
+}
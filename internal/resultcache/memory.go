@@ -0,0 +1,72 @@
+package resultcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Backend that evicts the least recently used entry
+// once it holds maxEntries.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries: max(maxEntries, 1),
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !m.now().Before(entry.expiresAt) {
+		m.order.Remove(el)
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set stores value for ttl; zero keeps it until it is evicted.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = m.now().Add(ttl)
+	}
+	if el, ok := m.entries[key]; ok {
+		el.Value = entry
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(entry)
+	for m.order.Len() > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package resultcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "echoflow:result-cache:"
+
+// Redis is a Backend shared by every replica using the same server.
+type Redis struct {
+	rdb *redis.Client
+}
+
+// OpenRedis connects to the server at url (redis://[:password@]host:port/db).
+func OpenRedis(ctx context.Context, url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	rdb := redis.NewClient(opts)
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		_ = rdb.Close()
+		return nil, fmt.Errorf("redis ping: %w", err)
+	}
+	return &Redis{rdb: rdb}, nil
+}
+
+func (r *Redis) Close() error {
+	return r.rdb.Close()
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := r.rdb.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.rdb.Set(ctx, redisKeyPrefix+key, value, ttl).Err()
+}
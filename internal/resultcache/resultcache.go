@@ -0,0 +1,213 @@
+// Package resultcache serves pipeline results for audio that was already
+// processed with the same settings, without calling the upstream again.
+package resultcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/pipeline"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+// Backend stores encoded results by key.
+type Backend interface {
+	// Get returns ok=false for a missing or expired key.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type Pipeline interface {
+	Process(ctx context.Context, in pipeline.ProcessInput) (pipeline.ProcessResult, error)
+}
+
+// Lookup outcomes passed to the observer.
+const (
+	Hit    = "hit"
+	Miss   = "miss"
+	Bypass = "bypass"
+)
+
+// ObserverFunc is told the outcome of every request.
+type ObserverFunc func(result string)
+
+// Service caches the results of next. Results whose post-processing failed
+// are not stored, so a transient upstream error is not replayed.
+type Service struct {
+	next     Pipeline
+	backend  Backend
+	ttl      time.Duration
+	observer ObserverFunc
+}
+
+func New(next Pipeline, backend Backend, ttl time.Duration, observer ObserverFunc) *Service {
+	return &Service{next: next, backend: backend, ttl: ttl, observer: observer}
+}
+
+func (s *Service) Process(ctx context.Context, in pipeline.ProcessInput) (pipeline.ProcessResult, error) {
+	started := time.Now()
+	key, ok := cacheKey(ctx, in)
+	if !ok {
+		s.observe(Bypass)
+		return s.next.Process(ctx, in)
+	}
+
+	data, ok, err := s.backend.Get(ctx, key)
+	if err != nil {
+		reqctx.Logger(ctx).Warn("result cache read failed", "error", err)
+	}
+	var cached pipeline.ProcessResult
+	if ok && json.Unmarshal(data, &cached) == nil {
+		s.observe(Hit)
+		return replay(in, cached, time.Since(started)), nil
+	}
+
+	s.observe(Miss)
+	result, err := s.next.Process(ctx, in)
+	if err != nil || !cacheable(result) {
+		return result, err
+	}
+	if data, err := json.Marshal(result); err == nil {
+		if err := s.backend.Set(ctx, key, data, s.ttl); err != nil {
+			reqctx.Logger(ctx).Warn("result cache write failed", "error", err)
+		}
+	}
+	return result, nil
+}
+
+func (s *Service) observe(result string) {
+	if s.observer != nil {
+		s.observer(result)
+	}
+}
+
+func cacheable(result pipeline.ProcessResult) bool {
+	if result.PostProcessingOutcome == pipeline.OutcomeFallback {
+		return false
+	}
+	for _, a := range result.Analyses {
+		if a.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// replay reports both stages as done, as the pipeline would, and times the
+// result as this request rather than the one that computed it.
+func replay(in pipeline.ProcessInput, result pipeline.ProcessResult, elapsed time.Duration) pipeline.ProcessResult {
+	result.Cached = true
+	result.Timings = pipeline.Timings{Total: elapsed}
+	if in.OnProgress != nil {
+		in.OnProgress(pipeline.ProgressEvent{Stage: pipeline.StageTranscription, Completed: 1, Total: 1})
+		in.OnProgress(pipeline.ProgressEvent{Stage: pipeline.StagePostProcessing, Completed: 1, Total: 1})
+	}
+	if in.OnStageComplete != nil {
+		in.OnStageComplete(pipeline.StageResult{Stage: pipeline.StageTranscription, Transcript: result.RawTranscript})
+		in.OnStageComplete(pipeline.StageResult{Stage: pipeline.StagePostProcessing, Transcript: result.FinalTranscript, Status: result.PostProcessingStatus, Outcome: result.PostProcessingOutcome})
+	}
+	return result
+}
+
+// keyParams is everything besides the audio that shapes a result. The tenant
+// is part of the key so one tenant's submissions never show up, even as a
+// timing difference, in another's requests.
+type keyParams struct {
+	Tenant string
+	// APIKey is a hash of the caller's upstream key. BYOT callers all share
+	// the empty tenant, so without it any bearer could read another's
+	// cached transcript by sending the same audio.
+	APIKey              string
+	Upstream            string
+	TranscriptionModel  string
+	TranscriptionPrompt string
+	WordTimestamps      bool
+	Segments            bool
+	PostProcessModel    string
+	Language            string
+	ContextSummary      string
+	CustomVocabulary    string
+	CustomSystemPrompt  string
+	Preprocess          audio.PreprocessOptions
+	SplitChannels       bool
+	ChannelLabels       []string
+	Stages              []string
+	Acronyms            map[string]string
+	Spellings           [2]bool
+	Audio               []string
+}
+
+// cacheKey hashes the audio and the settings. Audio that cannot be rewound
+// after hashing, and requests echoing their audio for debugging, are not
+// cached.
+func cacheKey(ctx context.Context, in pipeline.ProcessInput) (string, bool) {
+	if in.EchoAudio {
+		return "", false
+	}
+	params := keyParams{
+		Tenant:              reqctx.Tenant(ctx),
+		Upstream:            upstream.RequestBaseURLFromContext(ctx),
+		TranscriptionModel:  in.TranscriptionModel,
+		TranscriptionPrompt: in.TranscriptionPrompt,
+		WordTimestamps:      in.WordTimestamps,
+		Segments:            in.Segments,
+		PostProcessModel:    in.PostProcessModel,
+		Language:            in.Language,
+		ContextSummary:      in.ContextSummary,
+		CustomVocabulary:    in.CustomVocabulary,
+		CustomSystemPrompt:  in.CustomSystemPrompt,
+		Preprocess:          in.Preprocess,
+		SplitChannels:       in.SplitChannels,
+		ChannelLabels:       in.ChannelLabels,
+		Stages:              in.Stages,
+		Acronyms:            in.Acronyms,
+		Spellings:           [2]bool{in.CollapseSpellings, in.SpellingsToVocabulary},
+	}
+	if key := upstream.RequestAPIKeyFromContext(ctx); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		params.APIKey = hex.EncodeToString(sum[:])
+	}
+	if len(in.Parts) == 0 {
+		sum, ok := hashAudio(in.File)
+		if !ok {
+			return "", false
+		}
+		params.Audio = []string{sum}
+	}
+	for _, part := range in.Parts {
+		sum, ok := hashAudio(part.File)
+		if !ok {
+			return "", false
+		}
+		params.Audio = append(params.Audio, part.Label+":"+sum)
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+func hashAudio(r io.Reader) (string, bool) {
+	seeker, ok := r.(io.ReadSeeker)
+	if !ok {
+		return "", false
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	_, copyErr := io.Copy(h, seeker)
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil || copyErr != nil {
+		return "", false
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
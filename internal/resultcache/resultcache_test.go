@@ -0,0 +1,112 @@
+package resultcache
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/pipeline"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+type countingPipeline struct {
+	calls   int
+	outcome string
+}
+
+func (p *countingPipeline) Process(_ context.Context, in pipeline.ProcessInput) (pipeline.ProcessResult, error) {
+	p.calls++
+	data, _ := io.ReadAll(in.File)
+	outcome := p.outcome
+	if outcome == "" {
+		outcome = pipeline.OutcomeSucceeded
+	}
+	return pipeline.ProcessResult{RawTranscript: string(data), FinalTranscript: "final " + string(data), PostProcessingOutcome: outcome}, nil
+}
+
+func TestIdenticalAudioAndSettingsAreServedFromCache(t *testing.T) {
+	next := &countingPipeline{}
+	var outcomes []string
+	svc := New(next, NewMemory(10), time.Hour, func(result string) { outcomes = append(outcomes, result) })
+	ctx := reqctx.WithTenant(context.Background(), "acme")
+	process := func(ctx context.Context, audio, model string) pipeline.ProcessResult {
+		t.Helper()
+		res, err := svc.Process(ctx, pipeline.ProcessInput{File: strings.NewReader(audio), PostProcessModel: model})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	first := process(ctx, "clip", "m1")
+	second := process(ctx, "clip", "m1")
+	if next.calls != 1 || first.Cached || !second.Cached || second.FinalTranscript != "final clip" {
+		t.Fatalf("calls=%d first=%+v second=%+v", next.calls, first, second)
+	}
+	process(ctx, "clip", "m2")
+	process(ctx, "other clip", "m1")
+	process(reqctx.WithTenant(context.Background(), "globex"), "clip", "m1")
+	// BYOT callers share the empty tenant but not their cached results.
+	byot := func(key string) context.Context {
+		return upstream.WithRequestAPIKey(context.Background(), key)
+	}
+	process(byot("gsk_alice"), "clip", "m1")
+	if res := process(byot("gsk_mallory"), "clip", "m1"); res.Cached {
+		t.Fatal("one BYOT key was served another's cached result")
+	}
+	if !process(byot("gsk_alice"), "clip", "m1").Cached {
+		t.Fatal("the same BYOT key missed its own cached result")
+	}
+	if next.calls != 6 {
+		t.Fatalf("different audio, settings, tenant or key hit the cache: calls=%d", next.calls)
+	}
+	if got := strings.Join(outcomes, ","); got != "miss,hit,miss,miss,miss,miss,miss,hit" {
+		t.Fatalf("outcomes = %s", got)
+	}
+}
+
+func TestFailedPostProcessingAndUnseekableAudioAreNotCached(t *testing.T) {
+	next := &countingPipeline{outcome: pipeline.OutcomeFallback}
+	svc := New(next, NewMemory(10), time.Hour, nil)
+	for range 2 {
+		_, _ = svc.Process(context.Background(), pipeline.ProcessInput{File: strings.NewReader("clip")})
+	}
+	if next.calls != 2 {
+		t.Fatalf("failed result served from cache: calls=%d", next.calls)
+	}
+
+	next.outcome = ""
+	for range 2 {
+		res, _ := svc.Process(context.Background(), pipeline.ProcessInput{File: io.LimitReader(strings.NewReader("clip"), 4)})
+		if res.RawTranscript != "clip" {
+			t.Fatalf("unseekable audio reached the pipeline as %q", res.RawTranscript)
+		}
+	}
+	if next.calls != 4 {
+		t.Fatalf("unseekable audio was cached: calls=%d", next.calls)
+	}
+}
+
+func TestMemoryEvictsLeastRecentlyUsedAndExpired(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+	_ = m.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = m.Set(ctx, "b", []byte("2"), 0)
+	_, _, _ = m.Get(ctx, "a")
+	_ = m.Set(ctx, "c", []byte("3"), 0)
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Fatal("least recently used entry kept")
+	}
+	if v, ok, _ := m.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatal("recently used entry evicted")
+	}
+	now = now.Add(time.Minute)
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Fatal("expired entry returned")
+	}
+}
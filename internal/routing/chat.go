@@ -0,0 +1,62 @@
+package routing
+
+import (
+	"context"
+
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
+)
+
+// ChatCompleter sends each chat completion to the provider chosen by the
+// rules' models conditions, or to the default provider when no rule matches
+// or the chosen provider cannot do chat.
+type ChatCompleter struct {
+	router          Router
+	providers       map[string]upstream.ChatCompleter
+	defaultProvider string
+	observer        ObserverFunc
+}
+
+func NewChatCompleter(router Router, providers map[string]upstream.ChatCompleter, defaultProvider string, observer ObserverFunc) *ChatCompleter {
+	if providers[defaultProvider] == nil {
+		panic("routing: default chat provider " + defaultProvider + " is not configured")
+	}
+	return &ChatCompleter{
+		router:          router,
+		providers:       providers,
+		defaultProvider: defaultProvider,
+		observer:        observer,
+	}
+}
+
+func (c *ChatCompleter) ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	target, req := c.route(ctx, req)
+	return target.ChatCompletion(ctx, req)
+}
+
+func (c *ChatCompleter) StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	target, req := c.route(ctx, req)
+	return target.StreamChatCompletion(ctx, req, onDelta)
+}
+
+func (c *ChatCompleter) route(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompleter, upstream.ChatCompletionRequest) {
+	decision, ok := c.router.Route(Request{Model: req.Model, Tier: transcription.TierFromContext(ctx)})
+	if !ok {
+		return c.providers[c.defaultProvider], req
+	}
+	provider := decision.Provider
+	if provider == "" {
+		provider = c.defaultProvider
+	}
+	target, ok := c.providers[provider]
+	if !ok {
+		target, provider = c.providers[c.defaultProvider], c.defaultProvider
+	}
+	if c.observer != nil {
+		c.observer(decision.Rule, provider)
+	}
+	if decision.Model != "" {
+		req.Model = decision.Model
+	}
+	return target, req
+}
@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"context"
+	"testing"
+
+	"echoflow/internal/upstream"
+)
+
+type recordingChat struct {
+	name  string
+	calls *[]string
+}
+
+func (r recordingChat) ChatCompletion(_ context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	*r.calls = append(*r.calls, r.name+":"+req.Model)
+	return upstream.ChatCompletionResponse{Content: r.name}, nil
+}
+
+func (r recordingChat) StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, _ func(string) error) (upstream.ChatCompletionResponse, error) {
+	return r.ChatCompletion(ctx, req)
+}
+
+func TestChatCompleterRoutesByModelPattern(t *testing.T) {
+	f, err := Parse([]byte(`{"rules":[
+		{"name":"openai","match":{"models":["gpt-*"]},"provider":"openai"},
+		{"name":"alias","match":{"models":["fast"]},"model":"llama-3.1-8b-instant"},
+		{"name":"deepgram","match":{"models":["nova-*"]},"provider":"deepgram"},
+		{"name":"short","match":{"max_duration_seconds":5},"provider":"openai"}
+	]}`), []string{"primary", "openai", "deepgram"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var calls []string
+	c := NewChatCompleter(f, map[string]upstream.ChatCompleter{
+		"primary": recordingChat{name: "primary", calls: &calls},
+		"openai":  recordingChat{name: "openai", calls: &calls},
+	}, "primary", nil)
+
+	for _, model := range []string{"gpt-4o-mini", "fast", "nova-2", "llama-4-scout"} {
+		if _, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: model}); err != nil {
+			t.Fatalf("ChatCompletion: %v", err)
+		}
+	}
+	want := []string{"openai:gpt-4o-mini", "primary:llama-3.1-8b-instant", "primary:nova-2", "primary:llama-4-scout"}
+	for i := range want {
+		if i >= len(calls) || calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", calls, want)
+		}
+	}
+}
+
+func TestModelChatCompleterSendsMatchingModelsToVendor(t *testing.T) {
+	var calls []string
+	c := NewModelChatCompleter([]string{"claude-*"}, recordingChat{name: "anthropic", calls: &calls}, recordingChat{name: "primary", calls: &calls})
+
+	for _, model := range []string{"claude-3-5-haiku-latest", "llama-3.1-8b-instant", ""} {
+		if _, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: model}); err != nil {
+			t.Fatalf("ChatCompletion: %v", err)
+		}
+	}
+	want := []string{"anthropic:claude-3-5-haiku-latest", "primary:llama-3.1-8b-instant", "primary:"}
+	for i := range want {
+		if i >= len(calls) || calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", calls, want)
+		}
+	}
+}
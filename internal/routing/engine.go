@@ -0,0 +1,84 @@
+package routing
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Engine holds the active rules and reloads them when the file changes.
+// A file that fails to parse is logged and the previous rules stay active.
+type Engine struct {
+	path      string
+	providers []string
+	logger    *slog.Logger
+
+	rules   atomic.Pointer[File]
+	modTime time.Time
+	size    int64
+}
+
+// NewEngine loads path once; a bad file at startup is an error.
+func NewEngine(path string, providers []string, logger *slog.Logger) (*Engine, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	e := &Engine{path: path, providers: providers, logger: logger}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Engine) Route(req Request) (Decision, bool) {
+	return e.rules.Load().Route(req)
+}
+
+func (e *Engine) reload() error {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return err
+	}
+	f, err := ReadFile(e.path, e.providers)
+	if err != nil {
+		return err
+	}
+	e.rules.Store(&f)
+	e.modTime = info.ModTime()
+	e.size = info.Size()
+	return nil
+}
+
+// Watch checks the file every interval and reloads it when its size or
+// modification time changes, until ctx is cancelled.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(e.path)
+		if err != nil {
+			e.logger.Warn("routing rules stat failed", "path", e.path, "error", err)
+			continue
+		}
+		if info.ModTime().Equal(e.modTime) && info.Size() == e.size {
+			continue
+		}
+		if err := e.reload(); err != nil {
+			e.logger.Error("routing rules reload failed; keeping previous rules", "path", e.path, "error", err)
+			// Don't retry the same broken file every tick.
+			e.modTime, e.size = info.ModTime(), info.Size()
+			continue
+		}
+		e.logger.Info("routing rules reloaded", "path", e.path, "rules", len(e.rules.Load().Rules))
+	}
+}
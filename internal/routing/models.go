@@ -0,0 +1,39 @@
+package routing
+
+import (
+	"context"
+	"path"
+
+	"echoflow/internal/upstream"
+)
+
+// ModelChatCompleter sends chat completions whose model matches one of its
+// path.Match patterns to a dedicated vendor, and everything else to the
+// fallback. It lets callers pick a chat vendor per request by model name
+// without a routing rules file.
+type ModelChatCompleter struct {
+	patterns []string
+	matched  upstream.ChatCompleter
+	fallback upstream.ChatCompleter
+}
+
+func NewModelChatCompleter(patterns []string, matched, fallback upstream.ChatCompleter) *ModelChatCompleter {
+	return &ModelChatCompleter{patterns: patterns, matched: matched, fallback: fallback}
+}
+
+func (c *ModelChatCompleter) ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	return c.target(req.Model).ChatCompletion(ctx, req)
+}
+
+func (c *ModelChatCompleter) StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	return c.target(req.Model).StreamChatCompletion(ctx, req, onDelta)
+}
+
+func (c *ModelChatCompleter) target(model string) upstream.ChatCompleter {
+	for _, pattern := range c.patterns {
+		if ok, _ := path.Match(pattern, model); ok {
+			return c.matched
+		}
+	}
+	return c.fallback
+}
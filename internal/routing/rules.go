@@ -0,0 +1,139 @@
+// Package routing picks a transcription or chat provider and model per
+// request from a rules file that can be edited while the server is running.
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// File is the on-disk rules format. Rules are evaluated in order and the
+// first match wins.
+type File struct {
+	Rules []Rule `json:"rules"`
+}
+
+type Rule struct {
+	Name     string `json:"name"`
+	Match    Match  `json:"match"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// Match conditions are ANDed; an empty condition matches everything, except
+// that only rules with a models condition match requests naming a model.
+type Match struct {
+	// Models are glob patterns such as "whisper-*" matched against the model
+	// the caller asked for.
+	Models             []string `json:"models,omitempty"`
+	Languages          []string `json:"languages,omitempty"`
+	Tiers              []string `json:"tiers,omitempty"`
+	MinDurationSeconds float64  `json:"min_duration_seconds,omitempty"`
+	MaxDurationSeconds float64  `json:"max_duration_seconds,omitempty"`
+}
+
+type Request struct {
+	// Model is the model named by the caller, if any.
+	Model    string
+	Language string
+	Tier     string
+	// Duration is zero when the audio could not be probed; duration
+	// conditions never match such requests.
+	Duration time.Duration
+}
+
+type Decision struct {
+	Rule     string
+	Provider string
+	Model    string
+}
+
+func (m Match) matches(req Request) bool {
+	if len(m.Models) > 0 || req.Model != "" {
+		if !slices.ContainsFunc(m.Models, func(pattern string) bool {
+			ok, _ := path.Match(pattern, req.Model)
+			return ok
+		}) {
+			return false
+		}
+	}
+	if len(m.Languages) > 0 && !slices.Contains(m.Languages, strings.ToLower(req.Language)) {
+		return false
+	}
+	if len(m.Tiers) > 0 && !slices.Contains(m.Tiers, req.Tier) {
+		return false
+	}
+	if m.MinDurationSeconds > 0 || m.MaxDurationSeconds > 0 {
+		if req.Duration <= 0 {
+			return false
+		}
+		seconds := req.Duration.Seconds()
+		if m.MinDurationSeconds > 0 && seconds < m.MinDurationSeconds {
+			return false
+		}
+		if m.MaxDurationSeconds > 0 && seconds > m.MaxDurationSeconds {
+			return false
+		}
+	}
+	return true
+}
+
+func (f File) Route(req Request) (Decision, bool) {
+	for _, rule := range f.Rules {
+		if rule.Match.matches(req) {
+			return Decision{Rule: rule.Name, Provider: rule.Provider, Model: rule.Model}, true
+		}
+	}
+	return Decision{}, false
+}
+
+// ReadFile parses and validates a rules file. Providers named by rules must
+// be in providers.
+func ReadFile(path string, providers []string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	return Parse(data, providers)
+}
+
+func Parse(data []byte, providers []string) (File, error) {
+	var f File
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return File{}, fmt.Errorf("parse routing rules: %w", err)
+	}
+	for i := range f.Rules {
+		rule := &f.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.Provider != "" && !slices.Contains(providers, rule.Provider) {
+			return File{}, fmt.Errorf("routing rule %q: unknown provider %q", rule.Name, rule.Provider)
+		}
+		if rule.Provider == "" && rule.Model == "" {
+			return File{}, fmt.Errorf("routing rule %q: provider or model is required", rule.Name)
+		}
+		m := rule.Match
+		if m.MinDurationSeconds < 0 || m.MaxDurationSeconds < 0 ||
+			(m.MaxDurationSeconds > 0 && m.MinDurationSeconds > m.MaxDurationSeconds) {
+			return File{}, fmt.Errorf("routing rule %q: invalid duration range", rule.Name)
+		}
+		for _, pattern := range m.Models {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return File{}, fmt.Errorf("routing rule %q: invalid model pattern %q", rule.Name, pattern)
+			}
+		}
+		for j, lang := range m.Languages {
+			rule.Match.Languages[j] = strings.ToLower(strings.TrimSpace(lang))
+		}
+	}
+	return f, nil
+}
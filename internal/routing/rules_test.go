@@ -0,0 +1,109 @@
+package routing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRules = `{
+  "rules": [
+    {"name": "german-short", "match": {"languages": ["DE"], "max_duration_seconds": 30}, "provider": "secondary", "model": "whisper-large-v3"},
+    {"name": "premium", "match": {"tiers": ["premium"]}, "model": "whisper-large-v3"},
+    {"name": "long", "match": {"min_duration_seconds": 600}, "model": "whisper-large-v3-turbo"},
+    {"name": "gpt", "match": {"models": ["gpt-*", "o?-mini"]}, "provider": "secondary"}
+  ]
+}`
+
+func TestRouteFirstMatchWins(t *testing.T) {
+	f, err := Parse([]byte(testRules), []string{"primary", "secondary"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"language and duration", Request{Language: "de", Duration: 10 * time.Second, Tier: "premium"}, "german-short"},
+		{"language too long", Request{Language: "de", Duration: time.Minute, Tier: "premium"}, "premium"},
+		{"unknown duration", Request{Language: "de"}, ""},
+		{"long audio", Request{Language: "en", Duration: time.Hour}, "long"},
+		{"no match", Request{Language: "en", Duration: time.Minute, Tier: "standard"}, ""},
+		{"model pattern", Request{Model: "gpt-4o-mini", Tier: "premium"}, "gpt"},
+		{"model single-character pattern", Request{Model: "o3-mini"}, "gpt"},
+		{"named model skips rules without models", Request{Model: "whisper-large-v3", Tier: "premium"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := f.Route(tt.req)
+			if ok != (tt.want != "") || got.Rule != tt.want {
+				t.Fatalf("route = %q, %v; want %q", got.Rule, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsUnknownProvider(t *testing.T) {
+	_, err := Parse([]byte(`{"rules":[{"match":{},"provider":"deepgram"}]}`), []string{"primary"})
+	if err == nil || !strings.Contains(err.Error(), `unknown provider "deepgram"`) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestEngineWatchReloadsAndKeepsRulesOnBadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, `{"rules":[{"name":"a","match":{},"model":"m1"}]}`)
+
+	e, err := NewEngine(path, []string{"primary"}, nil)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Watch(ctx, 5*time.Millisecond)
+
+	writeRules(t, path, `{"rules":[{"name":"b","match":{},"model":"m2"}]}`)
+	waitForRule(t, e, "b")
+
+	writeRules(t, path, `{"rules":[`)
+	time.Sleep(50 * time.Millisecond)
+	if d, _ := e.Route(Request{}); d.Rule != "b" {
+		t.Fatalf("rule after bad reload = %q, want b", d.Rule)
+	}
+}
+
+func writeRules(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the change is visible even on coarse mtime filesystems.
+	future := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForRule(t *testing.T, e *Engine, rule string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if d, _ := e.Route(Request{}); d.Rule == rule {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("rule %q was not loaded", rule)
+}
+
+func TestParseRejectsBadModelPattern(t *testing.T) {
+	_, err := Parse([]byte(`{"rules":[{"match":{"models":["gpt-["]},"provider":"primary"}]}`), []string{"primary"})
+	if err == nil || !strings.Contains(err.Error(), "invalid model pattern") {
+		t.Fatalf("err = %v", err)
+	}
+}
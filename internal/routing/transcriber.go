@@ -0,0 +1,81 @@
+package routing
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/transcription"
+)
+
+type Router interface {
+	Route(req Request) (Decision, bool)
+}
+
+type ObserverFunc func(rule, provider string)
+
+// Transcriber sends each request to the provider and model chosen by the
+// rules, or to the default provider when no rule matches. A request naming a
+// model is only routed by rules with a models condition, and keeps its model
+// unless the rule names another.
+type Transcriber struct {
+	router          Router
+	providers       map[string]transcription.Transcriber
+	defaultProvider string
+	observer        ObserverFunc
+}
+
+func NewTranscriber(router Router, providers map[string]transcription.Transcriber, defaultProvider string, observer ObserverFunc) *Transcriber {
+	if providers[defaultProvider] == nil {
+		panic("routing: default provider " + defaultProvider + " is not configured")
+	}
+	return &Transcriber{
+		router:          router,
+		providers:       providers,
+		defaultProvider: defaultProvider,
+		observer:        observer,
+	}
+}
+
+func (t *Transcriber) Transcribe(ctx context.Context, in transcription.Input) (transcription.Result, error) {
+	decision, ok := t.router.Route(Request{
+		Model:    in.Model,
+		Language: in.Language,
+		Tier:     transcription.TierFromContext(ctx),
+		Duration: probeDuration(in),
+	})
+	if !ok {
+		return t.providers[t.defaultProvider].Transcribe(ctx, in)
+	}
+
+	provider := decision.Provider
+	if provider == "" {
+		provider = t.defaultProvider
+	}
+	target, ok := t.providers[provider]
+	if !ok {
+		target, provider = t.providers[t.defaultProvider], t.defaultProvider
+	}
+	if t.observer != nil {
+		t.observer(decision.Rule, provider)
+	}
+	if decision.Model != "" {
+		in.Model = decision.Model
+	}
+	return target.Transcribe(ctx, in)
+}
+
+// probeDuration reads the container header without consuming the reader;
+// uploads are seekable files, so this is usually available.
+func probeDuration(in transcription.Input) time.Duration {
+	r, ok := in.File.(io.ReaderAt)
+	if !ok || in.Size <= 0 {
+		return 0
+	}
+	meta, err := audio.Probe(r, in.Size)
+	if err != nil {
+		return 0
+	}
+	return meta.Duration
+}
@@ -0,0 +1,116 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/transcription"
+	"echoflow/internal/upstream"
+	"echoflow/internal/upstream/assemblyai"
+	"echoflow/internal/upstream/deepgram"
+)
+
+type recordingTranscriber struct {
+	name  string
+	calls *[]string
+}
+
+func (r recordingTranscriber) Transcribe(_ context.Context, in transcription.Input) (transcription.Result, error) {
+	*r.calls = append(*r.calls, r.name+":"+in.Model)
+	return transcription.Result{Text: r.name}, nil
+}
+
+func TestTranscriberRoutesByDurationTierAndModel(t *testing.T) {
+	f, err := Parse([]byte(`{"rules":[
+		{"name":"short","match":{"max_duration_seconds":5},"provider":"secondary","model":"fast"},
+		{"name":"premium","match":{"tiers":["premium"]},"model":"accurate"},
+		{"name":"turbo","match":{"models":["*-turbo"]},"provider":"secondary"}
+	]}`), []string{"primary", "secondary"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var calls []string
+	var observed []string
+	tr := NewTranscriber(f, map[string]transcription.Transcriber{
+		"primary":   recordingTranscriber{name: "primary", calls: &calls},
+		"secondary": recordingTranscriber{name: "secondary", calls: &calls},
+	}, "primary", func(rule, provider string) { observed = append(observed, rule+"->"+provider) })
+
+	short := wavOfSeconds(1)
+	long := wavOfSeconds(10)
+	premium := reqctx.WithPriority(context.Background(), transcription.TierPremium)
+
+	for _, tc := range []struct {
+		ctx context.Context
+		in  transcription.Input
+	}{
+		{context.Background(), transcription.Input{File: bytes.NewReader(short), Size: int64(len(short))}},
+		{premium, transcription.Input{File: bytes.NewReader(long), Size: int64(len(long))}},
+		{context.Background(), transcription.Input{File: bytes.NewReader(long), Size: int64(len(long))}},
+		{context.Background(), transcription.Input{File: bytes.NewReader(short), Size: int64(len(short)), Model: "pinned"}},
+		{premium, transcription.Input{File: bytes.NewReader(long), Size: int64(len(long)), Model: "whisper-large-v3-turbo"}},
+	} {
+		if _, err := tr.Transcribe(tc.ctx, tc.in); err != nil {
+			t.Fatalf("Transcribe: %v", err)
+		}
+	}
+
+	want := []string{"secondary:fast", "primary:accurate", "primary:", "primary:pinned", "secondary:whisper-large-v3-turbo"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", calls, want)
+		}
+	}
+	if len(observed) != 3 || observed[0] != "short->secondary" || observed[1] != "premium->primary" || observed[2] != "turbo->secondary" {
+		t.Fatalf("observed = %v", observed)
+	}
+}
+
+func TestTranscriberRefusesCallerKeysForServerKeyProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("vendor called on the operator's key for %s", r.URL.Path)
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	f, err := Parse([]byte(`{"rules":[
+		{"name":"nova","match":{"models":["nova-*"]},"provider":"deepgram"},
+		{"name":"best","match":{"models":["best"]},"provider":"assemblyai"}
+	]}`), []string{"primary", "deepgram", "assemblyai"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var calls []string
+	timeouts := transcription.TimeoutPolicy{Base: time.Second}
+	tr := NewTranscriber(f, map[string]transcription.Transcriber{
+		"primary":    recordingTranscriber{name: "primary", calls: &calls},
+		"deepgram":   transcription.New(upstream.ServerKeyTranscriber(deepgram.New(srv.URL, "dg-operator", srv.Client()), nil), "nova-3", timeouts),
+		"assemblyai": transcription.New(upstream.ServerKeyTranscriber(assemblyai.New(srv.URL, "aai-operator", srv.Client()), nil), "best", timeouts),
+	}, "primary", nil)
+
+	ctx := reqctx.WithAPIKeySource(context.Background(), reqctx.KeySourceCaller)
+	wav := wavOfSeconds(1)
+	for _, model := range []string{"nova-3", "best"} {
+		_, err := tr.Transcribe(ctx, transcription.Input{File: bytes.NewReader(wav), Size: int64(len(wav)), Model: model})
+		if !errors.Is(err, upstream.ErrServerKeyOnly) {
+			t.Fatalf("Transcribe(%s) error = %v, want ErrServerKeyOnly", model, err)
+		}
+	}
+	if len(calls) != 0 {
+		t.Fatalf("calls = %v, want none", calls)
+	}
+}
+
+func wavOfSeconds(seconds int) []byte {
+	const rate = 8000
+	return audio.FromSamples([][]float64{make([]float64, seconds*rate)}, rate).Encode()
+}
@@ -0,0 +1,165 @@
+// Package subtitle renders timed transcript segments as SRT and WebVTT
+// caption files.
+package subtitle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cue is one caption. Speaker, when set, is shown as the cue's voice.
+type Cue struct {
+	Start   time.Duration
+	End     time.Duration
+	Speaker string
+	Text    string
+}
+
+// Reflow lays text over the timings of cues, for captions of a transcript
+// that was rewritten after transcription. Each cue takes a share of text's
+// words in proportion to the words it held, so cleaned-up wording stays
+// roughly in sync with the audio; cues left without words are dropped.
+//
+// Text with one "Speaker:" line per speaker turn, as the pipeline writes for
+// multi-part runs, is re-flowed turn by turn so no words cross to another
+// speaker. Otherwise labels matching a cue's speaker are dropped and the
+// whole text is spread over all cues.
+func Reflow(cues []Cue, text string) []Cue {
+	turns, lines := speakerTurns(cues), labeledLines(text)
+	if len(turns) == 0 || len(turns) != len(lines) {
+		return reflow(cues, stripSpeakerLabels(text, cues))
+	}
+	for i, turn := range turns {
+		if lines[i].speaker != turn[0].Speaker {
+			return reflow(cues, stripSpeakerLabels(text, cues))
+		}
+	}
+	var out []Cue
+	for i, turn := range turns {
+		out = append(out, reflow(turn, lines[i].text)...)
+	}
+	return out
+}
+
+func reflow(cues []Cue, text string) []Cue {
+	words := strings.Fields(text)
+	if len(cues) == 0 || len(words) == 0 {
+		return cues
+	}
+	counts := make([]int, len(cues))
+	total := 0
+	for i, c := range cues {
+		counts[i] = max(len(strings.Fields(c.Text)), 1)
+		total += counts[i]
+	}
+
+	out := make([]Cue, 0, len(cues))
+	seen, next := 0, 0
+	for i, c := range cues {
+		seen += counts[i]
+		end := len(words) * seen / total
+		if end <= next {
+			continue
+		}
+		c.Text = strings.Join(words[next:end], " ")
+		out = append(out, c)
+		next = end
+	}
+	return out
+}
+
+// speakerTurns groups consecutive cues by speaker. It returns nil when any
+// cue has no speaker.
+func speakerTurns(cues []Cue) [][]Cue {
+	var turns [][]Cue
+	for i, c := range cues {
+		if c.Speaker == "" {
+			return nil
+		}
+		if i == 0 || c.Speaker != cues[i-1].Speaker {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], c)
+	}
+	return turns
+}
+
+type labeledLine struct {
+	speaker, text string
+}
+
+func labeledLines(text string) []labeledLine {
+	var lines []labeledLine
+	for line := range strings.Lines(text) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		speaker, rest, _ := strings.Cut(strings.TrimSpace(line), ":")
+		lines = append(lines, labeledLine{speaker: speaker, text: rest})
+	}
+	return lines
+}
+
+func stripSpeakerLabels(text string, cues []Cue) string {
+	speakers := map[string]bool{}
+	for _, c := range cues {
+		if c.Speaker != "" {
+			speakers[c.Speaker] = true
+		}
+	}
+	if len(speakers) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		label, rest, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && speakers[label] {
+			lines[i] = rest
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SRT renders cues as a SubRip file.
+func SRT(cues []Cue) string {
+	var b strings.Builder
+	for i, c := range cues {
+		text := cueText(c.Text)
+		if c.Speaker != "" {
+			text = c.Speaker + ": " + text
+		}
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.Start, ','), timestamp(c.End, ','), text)
+	}
+	return b.String()
+}
+
+// VTT renders cues as a WebVTT file, with speakers as voice spans.
+func VTT(cues []Cue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		text := vttEscaper.Replace(cueText(c.Text))
+		if c.Speaker != "" {
+			text = "<v " + vttEscaper.Replace(cueText(c.Speaker)) + ">" + text
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", timestamp(c.Start, '.'), timestamp(c.End, '.'), text)
+	}
+	return b.String()
+}
+
+// vttEscaper escapes the characters WebVTT reads as markup, in cue text and
+// in a voice span's annotation alike.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// cueText folds text onto one line, since a blank line would end the cue,
+// and breaks up any "-->", which would read as a timing line.
+func cueText(text string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(text), " "), "-->", "->")
+}
+
+func timestamp(d time.Duration, sep byte) string {
+	d = max(d, 0)
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,53 @@
+package subtitle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSRTAndVTT(t *testing.T) {
+	cues := []Cue{
+		{Start: 0, End: 2500 * time.Millisecond, Text: "Hello\nthere."},
+		{Start: time.Hour + 61*time.Second + 5*time.Millisecond, End: time.Hour + 62*time.Second, Speaker: "Agent", Text: "Hi --> you"},
+	}
+	wantSRT := "1\n00:00:00,000 --> 00:00:02,500\nHello there.\n\n" +
+		"2\n01:01:01,005 --> 01:01:02,000\nAgent: Hi -> you\n\n"
+	if got := SRT(cues); got != wantSRT {
+		t.Fatalf("SRT() = %q", got)
+	}
+	wantVTT := "WEBVTT\n\n00:00:00.000 --> 00:00:02.500\nHello there.\n\n" +
+		"01:01:01.005 --> 01:01:02.000\n<v Agent>Hi -&gt; you\n\n"
+	if got := VTT(cues); got != wantVTT {
+		t.Fatalf("VTT() = %q", got)
+	}
+}
+
+func TestVTTEscapesMarkup(t *testing.T) {
+	cues := []Cue{{End: time.Second, Speaker: "R&D <lead>", Text: "if a < b && b > c"}}
+	want := "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\n<v R&amp;D &lt;lead&gt;>if a &lt; b &amp;&amp; b &gt; c\n\n"
+	if got := VTT(cues); got != want {
+		t.Fatalf("VTT() = %q", got)
+	}
+}
+
+func TestReflowSpreadsCleanedTextOverCues(t *testing.T) {
+	cues := []Cue{
+		{Start: 0, End: time.Second, Speaker: "Caller", Text: "um so like hi there"},
+		{Start: time.Second, End: 2 * time.Second, Speaker: "Agent", Text: "uh hello"},
+		{Start: 2 * time.Second, End: 3 * time.Second, Speaker: "Agent", Text: "how can I help you"},
+	}
+	got := Reflow(cues, "Caller: So, hi there.\nAgent: Hello, how can I help?")
+	want := []string{"So, hi there.", "Hello,", "how can I help?"}
+	if len(got) != len(want) {
+		t.Fatalf("Reflow() = %+v", got)
+	}
+	for i := range want {
+		if got[i].Text != want[i] || got[i].Start != cues[i].Start || got[i].Speaker != cues[i].Speaker {
+			t.Fatalf("cue %d = %+v, want text %q", i, got[i], want[i])
+		}
+	}
+
+	if got := Reflow(cues[:2], "Hi."); len(got) != 1 || got[0].Text != "Hi." || got[0].End != cues[1].End {
+		t.Fatalf("Reflow() with fewer words than cues = %+v", got)
+	}
+}
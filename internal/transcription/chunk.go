@@ -0,0 +1,273 @@
+package transcription
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/sync/errgroup"
+
+	"echoflow/internal/audio"
+)
+
+// ChunkPolicy says when Chunked splits audio. A zero limit is not checked.
+type ChunkPolicy struct {
+	// MaxBytes is the largest upload sent upstream in one piece.
+	MaxBytes int64
+	// MaxDuration is the longest audio sent upstream in one piece.
+	MaxDuration time.Duration
+	// Overlap is the audio each chunk repeats from the end of the one
+	// before it.
+	Overlap time.Duration
+	// Parallelism bounds the chunks of one upload transcribed at once;
+	// below 1 they go one at a time.
+	Parallelism int
+}
+
+// ChunkTiming is one chunk of a split upload: the span of the recording it
+// covers and how long transcribing it took.
+type ChunkTiming struct {
+	Start    time.Duration
+	End      time.Duration
+	Duration time.Duration
+}
+
+// Chunked splits audio over the policy's limits into overlapping chunks,
+// transcribes them with next, and stitches the transcripts back together,
+// so recordings longer than an upstream accepts still go through. Only WAV
+// can be split; other formats are converted with the transcoder, and are
+// passed to next whole when there is none.
+type Chunked struct {
+	next       Transcriber
+	policy     ChunkPolicy
+	transcoder Transcoder
+}
+
+func NewChunked(next Transcriber, policy ChunkPolicy, transcoder Transcoder) *Chunked {
+	return &Chunked{next: next, policy: policy, transcoder: transcoder}
+}
+
+func (c *Chunked) Transcribe(ctx context.Context, in Input) (Result, error) {
+	if !c.overLimit(in) {
+		return c.next.Transcribe(ctx, in)
+	}
+	data, err := io.ReadAll(in.File)
+	if err != nil {
+		return Result{}, err
+	}
+	var applied []string
+	wav, err := audio.DecodeWAV(data)
+	if errors.Is(err, audio.ErrUnsupportedFormat) && c.transcoder != nil {
+		if data, err = c.transcoder.Transcode(ctx, data, "wav"); err != nil {
+			return Result{}, err
+		}
+		applied = append(applied, "transcode")
+		wav, err = audio.DecodeWAV(data)
+	}
+	if err != nil {
+		in.File = bytes.NewReader(data)
+		return c.next.Transcribe(ctx, in)
+	}
+	// Preprocessing runs on the whole recording, so trimmed silence does
+	// not shift one chunk's timings against the next.
+	var timeline audio.Timeline
+	if in.Preprocess.Enabled() {
+		processed, err := audio.Preprocess(wav.Encode(), in.Preprocess)
+		if err != nil {
+			return Result{}, err
+		}
+		if wav, err = audio.DecodeWAV(processed.Audio); err != nil {
+			return Result{}, err
+		}
+		applied = append(applied, processed.Applied...)
+		timeline = processed.Timeline
+	}
+
+	length := c.chunkLength(wav)
+	chunks := wav.Split(length, min(c.policy.Overlap, length/4))
+	results, timings, err := c.transcribeChunks(ctx, in, chunks)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := mergeChunks(chunks, results)
+	if len(chunks) > 1 {
+		applied = append(applied, fmt.Sprintf("chunk:%d", len(chunks)))
+		result.Chunks = timings
+	}
+	result.Preprocessing = applied
+	if !in.IncludeSegments {
+		result.Segments = nil
+	}
+	result.remap(timeline)
+	if in.EchoAudio {
+		result.Audio = wav.Encode()
+	}
+	return result, nil
+}
+
+// transcribeChunks sends up to Parallelism chunks upstream at once. The
+// first failure cancels the chunks still running.
+func (c *Chunked) transcribeChunks(ctx context.Context, in Input, chunks []audio.WAVChunk) ([]Result, []ChunkTiming, error) {
+	results := make([]Result, len(chunks))
+	timings := make([]ChunkTiming, len(chunks))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(c.policy.Parallelism, 1))
+	for i, chunk := range chunks {
+		g.Go(func() error {
+			// Encoded here, so only the chunks in flight are held in memory.
+			data := chunk.Encode()
+			chunkIn := in
+			chunkIn.File = bytes.NewReader(data)
+			chunkIn.FileName = audio.WithExt(cmp.Or(in.FileName, audio.DefaultFileName), ".wav")
+			chunkIn.Size = int64(len(data))
+			chunkIn.Preprocess = audio.PreprocessOptions{}
+			chunkIn.EchoAudio = false
+			// Segment timings tell where one chunk's overlap ends.
+			chunkIn.IncludeSegments = true
+			started := time.Now()
+			res, err := c.next.Transcribe(ctx, chunkIn)
+			if err != nil {
+				if len(chunks) > 1 {
+					err = fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+				}
+				return err
+			}
+			results[i] = res
+			timings[i] = ChunkTiming{Start: chunk.Start, End: chunk.End, Duration: time.Since(started)}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	return results, timings, nil
+}
+
+// overLimit reports whether in may be over the policy's limits. Duration is
+// only known for uploads that can be probed in place.
+func (c *Chunked) overLimit(in Input) bool {
+	if c.policy.MaxBytes > 0 && in.Size > c.policy.MaxBytes {
+		return true
+	}
+	if c.policy.MaxDuration <= 0 {
+		return false
+	}
+	r, ok := in.File.(io.ReaderAt)
+	if !ok {
+		return false
+	}
+	meta, err := audio.Probe(r, in.Size)
+	return err == nil && meta.Duration > c.policy.MaxDuration
+}
+
+// chunkLength is the longest chunk of wav within both limits.
+func (c *Chunked) chunkLength(wav *audio.WAV) time.Duration {
+	length := wav.Duration()
+	if c.policy.MaxDuration > 0 {
+		length = min(length, c.policy.MaxDuration)
+	}
+	if bytesPerSecond := int64(wav.SampleRate * wav.Channels * wav.BitsPerSample / 8); c.policy.MaxBytes > 0 && bytesPerSecond > 0 {
+		length = min(length, time.Duration((c.policy.MaxBytes-44)*int64(time.Second)/bytesPerSecond))
+	}
+	return max(length, time.Second)
+}
+
+// mergeChunks stitches the results of overlapping chunks. Each overlap is
+// cut in the middle: segments and words centred before the cut come from
+// the earlier chunk, the rest from the later one. When a chunk has text but
+// no segments, the texts are joined with the words they share dropped.
+func mergeChunks(chunks []audio.WAVChunk, results []Result) Result {
+	var merged Result
+	var texts []string
+	timed := true
+	for i, res := range results {
+		from, to := time.Duration(0), time.Duration(1<<63-1)
+		if i > 0 {
+			from = (chunks[i].Start + chunks[i-1].End) / 2
+		}
+		if i+1 < len(chunks) {
+			to = (chunks[i+1].Start + chunks[i].End) / 2
+		}
+		offset := chunks[i].Start
+		inside := func(start, end time.Duration) bool {
+			mid := offset + (start+end)/2
+			return mid >= from && mid < to
+		}
+
+		var kept []string
+		for _, seg := range res.Segments {
+			if inside(seg.Start, seg.End) {
+				seg.Start += offset
+				seg.End += offset
+				merged.Segments = append(merged.Segments, seg)
+				kept = append(kept, seg.Text)
+			}
+		}
+		for _, w := range res.Words {
+			if inside(w.Start, w.End) {
+				w.Start += offset
+				w.End += offset
+				merged.Words = append(merged.Words, w)
+			}
+		}
+		if len(res.Segments) == 0 && res.Text != "" {
+			timed = false
+		}
+		texts = append(texts, strings.Join(kept, " "))
+		if merged.Language == "" {
+			merged.Language = res.Language
+		}
+		for _, warning := range res.Warnings {
+			if !slices.Contains(merged.Warnings, warning) {
+				merged.Warnings = append(merged.Warnings, warning)
+			}
+		}
+	}
+	if !timed {
+		texts = texts[:0]
+		for _, res := range results {
+			texts = append(texts, res.Text)
+		}
+		merged.Text = joinOverlapping(texts)
+		return merged
+	}
+	merged.Text = strings.Join(strings.Fields(strings.Join(texts, " ")), " ")
+	return merged
+}
+
+// maxOverlapWords bounds the words joinOverlapping looks for in both texts.
+const maxOverlapWords = 40
+
+// joinOverlapping joins texts whose ends repeat the start of the next, as
+// transcripts of overlapping chunks do, dropping the longest run of words
+// shared at each seam. Words are compared without case and punctuation.
+func joinOverlapping(texts []string) string {
+	var words []string
+	for _, text := range texts {
+		next := strings.Fields(text)
+		shared := 0
+		for n := min(len(words), len(next), maxOverlapWords); n > 0; n-- {
+			if slices.EqualFunc(words[len(words)-n:], next[:n], sameWord) {
+				shared = n
+				break
+			}
+		}
+		words = append(words, next[shared:]...)
+	}
+	return strings.Join(words, " ")
+}
+
+func sameWord(a, b string) bool {
+	trim := func(s string) string {
+		return strings.TrimFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	}
+	return strings.EqualFold(trim(a), trim(b))
+}
@@ -0,0 +1,148 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/audio"
+)
+
+// spokenClient transcribes 8 kHz WAV chunks of a recording in which word n
+// is spoken during second n.
+type spokenClient struct {
+	mu     sync.Mutex
+	starts []time.Duration
+}
+
+func (c *spokenClient) Transcribe(_ context.Context, in Input) (Result, error) {
+	data, _ := io.ReadAll(in.File)
+	wav, err := audio.DecodeWAV(data)
+	if err != nil {
+		return Result{}, err
+	}
+	// The chunk's samples hold its start second.
+	first := int(wav.Samples()[0][0]*100 + 0.5)
+	c.mu.Lock()
+	c.starts = append(c.starts, time.Duration(first)*time.Second)
+	c.mu.Unlock()
+
+	var res Result
+	var words []string
+	for s := 0; s < int(wav.Duration()/time.Second); s++ {
+		word := fmt.Sprintf("w%d", first+s)
+		words = append(words, word)
+		res.Segments = append(res.Segments, Segment{Start: time.Duration(s) * time.Second, End: time.Duration(s+1) * time.Second, Text: word})
+	}
+	res.Text = strings.Join(words, " ")
+	if !in.IncludeSegments {
+		res.Segments = nil
+	}
+	return res, nil
+}
+
+func spokenWAV(seconds int) []byte {
+	samples := make([]float64, 0, seconds*8000)
+	for s := range seconds {
+		for range 8000 {
+			samples = append(samples, float64(s)/100)
+		}
+	}
+	return audio.FromSamples([][]float64{samples}, 8000).Encode()
+}
+
+func TestChunkedStitchesOverlappingChunks(t *testing.T) {
+	client := &spokenClient{}
+	chunked := NewChunked(client, ChunkPolicy{MaxDuration: 10 * time.Second, Overlap: 2 * time.Second}, nil)
+	data := spokenWAV(25)
+
+	res, err := chunked.Transcribe(context.Background(), Input{File: bytes.NewReader(data), FileName: "long.wav", Size: int64(len(data)), IncludeSegments: true})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	var want []string
+	for s := range 25 {
+		want = append(want, fmt.Sprintf("w%d", s))
+	}
+	if res.Text != strings.Join(want, " ") {
+		t.Fatalf("Text = %q", res.Text)
+	}
+	if len(res.Segments) != 25 || res.Segments[24].Start != 24*time.Second {
+		t.Fatalf("Segments = %+v", res.Segments)
+	}
+	if len(client.starts) != 3 || len(res.Preprocessing) != 1 || res.Preprocessing[0] != "chunk:3" {
+		t.Fatalf("chunks = %v, Preprocessing = %q", client.starts, res.Preprocessing)
+	}
+
+	client.starts = nil
+	short := spokenWAV(5)
+	if _, err := chunked.Transcribe(context.Background(), Input{File: bytes.NewReader(short), Size: int64(len(short))}); err != nil || len(client.starts) != 1 {
+		t.Fatalf("short upload: err=%v calls=%d", err, len(client.starts))
+	}
+}
+
+// gatedClient holds each call until two are in flight, and records the
+// most it saw at once.
+type gatedClient struct {
+	spokenClient
+	active, peak int
+	both         chan struct{}
+	bothOnce     sync.Once
+}
+
+func (c *gatedClient) Transcribe(ctx context.Context, in Input) (Result, error) {
+	c.mu.Lock()
+	c.active++
+	c.peak = max(c.peak, c.active)
+	if c.active == 2 {
+		c.bothOnce.Do(func() { close(c.both) })
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.both:
+	case <-time.After(2 * time.Second):
+	}
+	defer func() {
+		c.mu.Lock()
+		c.active--
+		c.mu.Unlock()
+	}()
+	return c.spokenClient.Transcribe(ctx, in)
+}
+
+func TestChunkedTranscribesChunksInParallel(t *testing.T) {
+	client := &gatedClient{both: make(chan struct{})}
+	chunked := NewChunked(client, ChunkPolicy{MaxDuration: 10 * time.Second, Overlap: 2 * time.Second, Parallelism: 2}, nil)
+	data := spokenWAV(25)
+
+	res, err := chunked.Transcribe(context.Background(), Input{File: bytes.NewReader(data), Size: int64(len(data))})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if client.peak != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", client.peak)
+	}
+	if !strings.HasPrefix(res.Text, "w0 w1 ") || !strings.HasSuffix(res.Text, " w23 w24") {
+		t.Fatalf("Text = %q", res.Text)
+	}
+	if len(res.Chunks) != 3 || res.Chunks[0].Start != 0 || res.Chunks[2].End != 25*time.Second {
+		t.Fatalf("Chunks = %+v", res.Chunks)
+	}
+	for i, c := range res.Chunks {
+		if c.End <= c.Start || c.Duration <= 0 || (i > 0 && c.Start >= res.Chunks[i-1].End) {
+			t.Fatalf("chunk %d = %+v", i, c)
+		}
+	}
+}
+
+func TestJoinOverlappingDropsSharedWords(t *testing.T) {
+	got := joinOverlapping([]string{"so the plan is to ship", "Ship it on Friday, then", "then rest."})
+	if got != "so the plan is to ship it on Friday, then rest." {
+		t.Fatalf("joinOverlapping() = %q", got)
+	}
+}
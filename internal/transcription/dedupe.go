@@ -0,0 +1,183 @@
+package transcription
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+// Deduplication outcomes reported to the observer, once per request. Every
+// request served by a call that had duplicates counts as shared.
+const (
+	DedupeUnique = "unique"
+	DedupeShared = "shared"
+	DedupeBypass = "bypass"
+)
+
+type DedupeObserverFunc func(outcome string)
+
+// Deduplicated collapses identical concurrent requests, same audio and same
+// parameters from the same caller, into one call to next and hands its
+// result to all of them. Clients that retry while their first attempt is
+// still running then cost one upstream call, not several.
+type Deduplicated struct {
+	next     Transcriber
+	observer DedupeObserverFunc
+
+	mu    sync.Mutex
+	calls map[string]*dedupeCall
+}
+
+type dedupeCall struct {
+	done   chan struct{}
+	result Result
+	err    error
+	// abandoned is set when the leading request's context ended first, so
+	// its error says nothing about the duplicates' own requests.
+	abandoned bool
+	dups      int
+}
+
+func NewDeduplicated(next Transcriber, observer DedupeObserverFunc) *Deduplicated {
+	return &Deduplicated{next: next, observer: observer, calls: make(map[string]*dedupeCall)}
+}
+
+func (d *Deduplicated) Transcribe(ctx context.Context, in Input) (Result, error) {
+	key, ok := dedupeKey(ctx, in)
+	if !ok {
+		d.observe(DedupeBypass)
+		return d.next.Transcribe(ctx, in)
+	}
+
+	d.mu.Lock()
+	if c, ok := d.calls[key]; ok {
+		c.dups++
+		d.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		}
+		if c.abandoned {
+			d.observe(DedupeUnique)
+			return d.next.Transcribe(ctx, in)
+		}
+		d.observe(DedupeShared)
+		return cloneResult(c.result), c.err
+	}
+	c := &dedupeCall{done: make(chan struct{})}
+	d.calls[key] = c
+	d.mu.Unlock()
+
+	// The leader calls next with its own context, so its upload stays open
+	// for as long as the call reads it.
+	c.result, c.err = d.next.Transcribe(ctx, in)
+	c.abandoned = ctx.Err() != nil
+	d.mu.Lock()
+	delete(d.calls, key)
+	shared := c.dups > 0
+	d.mu.Unlock()
+	close(c.done)
+
+	if !shared {
+		d.observe(DedupeUnique)
+		return c.result, c.err
+	}
+	d.observe(DedupeShared)
+	return cloneResult(c.result), c.err
+}
+
+// cloneResult gives each request its own slices, since callers may edit
+// them.
+func cloneResult(r Result) Result {
+	r.Segments = slices.Clone(r.Segments)
+	r.Preprocessing = slices.Clone(r.Preprocessing)
+	r.Warnings = slices.Clone(r.Warnings)
+	r.Words = slices.Clone(r.Words)
+	r.Chunks = slices.Clone(r.Chunks)
+	return r
+}
+
+func (d *Deduplicated) observe(outcome string) {
+	if d.observer != nil {
+		d.observer(outcome)
+	}
+}
+
+// dedupeParams is everything besides the audio that can change the result
+// or who pays for it. The caller's upstream key is hashed in, so BYOT callers
+// sharing the empty tenant never ride on each other's keys.
+type dedupeParams struct {
+	Tenant          string
+	APIKey          string
+	BaseURL         string
+	Tier            string
+	Policy          *LanguagePolicy
+	FileName        string
+	Model           string
+	Language        string
+	Prompt          string
+	IncludeSegments bool
+	IncludeWords    bool
+	Translate       bool
+	Preprocess      audio.PreprocessOptions
+	Audio           string
+}
+
+// dedupeKey hashes the audio and the parameters. Audio that cannot be
+// rewound after hashing, and requests echoing their audio, are not
+// deduplicated.
+func dedupeKey(ctx context.Context, in Input) (string, bool) {
+	if in.EchoAudio {
+		return "", false
+	}
+	seeker, ok := in.File.(io.ReadSeeker)
+	if !ok {
+		return "", false
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	_, copyErr := io.Copy(h, seeker)
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil || copyErr != nil {
+		return "", false
+	}
+
+	params := dedupeParams{
+		Tenant:          reqctx.Tenant(ctx),
+		Tier:            TierFromContext(ctx),
+		FileName:        in.FileName,
+		Model:           in.Model,
+		Language:        in.Language,
+		Prompt:          in.Prompt,
+		IncludeSegments: in.IncludeSegments,
+		IncludeWords:    in.IncludeWords,
+		Translate:       in.Translate,
+		Preprocess:      in.Preprocess,
+		Audio:           hex.EncodeToString(h.Sum(nil)),
+	}
+	if key := upstream.RequestAPIKeyFromContext(ctx); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		params.APIKey = hex.EncodeToString(sum[:])
+	}
+	params.BaseURL = upstream.RequestBaseURLFromContext(ctx)
+	if policy, ok := LanguagePolicyFromContext(ctx); ok {
+		params.Policy = &policy
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
@@ -0,0 +1,150 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"echoflow/internal/reqctx"
+)
+
+type gatedTranscriber struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedTranscriber) Transcribe(ctx context.Context, in Input) (Result, error) {
+	g.calls.Add(1)
+	g.started <- struct{}{}
+	select {
+	case <-g.release:
+		return Result{Text: "hello", Warnings: []string{"w"}, Words: []Word{{Text: "hello"}}, Chunks: []ChunkTiming{{End: time.Second}}}, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// waitForDuplicates blocks until n requests are waiting on an in-flight call.
+func waitForDuplicates(t *testing.T, d *Deduplicated, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		d.mu.Lock()
+		dups := 0
+		for _, c := range d.calls {
+			dups += c.dups
+		}
+		d.mu.Unlock()
+		if dups == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d duplicates waiting, want %d", dups, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDeduplicatedSharesOneCallBetweenIdenticalRequests(t *testing.T) {
+	next := &gatedTranscriber{started: make(chan struct{}, 3), release: make(chan struct{})}
+	var mu sync.Mutex
+	var outcomes []string
+	d := NewDeduplicated(next, func(outcome string) {
+		mu.Lock()
+		defer mu.Unlock()
+		outcomes = append(outcomes, outcome)
+	})
+	ctx := reqctx.WithTenant(context.Background(), "acme")
+	in := func() Input { return Input{File: strings.NewReader("clip"), FileName: "a.wav"} }
+
+	type reply struct {
+		result Result
+		err    error
+	}
+	replies := make(chan reply, 3)
+	transcribe := func(ctx context.Context) {
+		result, err := d.Transcribe(ctx, in())
+		replies <- reply{result, err}
+	}
+	go transcribe(ctx)
+	<-next.started
+	go transcribe(ctx)
+	waitForDuplicates(t, d, 1)
+	// Another tenant's identical upload gets its own call.
+	go transcribe(reqctx.WithTenant(context.Background(), "globex"))
+	<-next.started
+	close(next.release)
+
+	for range 3 {
+		r := <-replies
+		if r.err != nil || r.result.Text != "hello" {
+			t.Fatalf("reply = %+v, %v", r.result, r.err)
+		}
+		r.result.Warnings[0] = "edited"
+		r.result.Words[0].Text = "edited"
+		r.result.Chunks[0].End = 0
+	}
+	if got := next.calls.Load(); got != 2 {
+		t.Fatalf("upstream calls = %d, want 2", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	shared := 0
+	for _, outcome := range outcomes {
+		if outcome == DedupeShared {
+			shared++
+		}
+	}
+	if shared != 2 {
+		t.Fatalf("outcomes = %v, want 2 shared", outcomes)
+	}
+}
+
+func TestDeduplicatedRetriesWhenLeaderGivesUp(t *testing.T) {
+	next := &gatedTranscriber{started: make(chan struct{}, 2), release: make(chan struct{})}
+	d := NewDeduplicated(next, nil)
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	in := func() Input { return Input{File: strings.NewReader("clip")} }
+
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := d.Transcribe(leaderCtx, in())
+		leaderErr <- err
+	}()
+	<-next.started
+	followerResult := make(chan Result, 1)
+	go func() {
+		result, _ := d.Transcribe(context.Background(), in())
+		followerResult <- result
+	}()
+	waitForDuplicates(t, d, 1)
+
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("leader error = %v", err)
+	}
+	<-next.started
+	close(next.release)
+	if result := <-followerResult; result.Text != "hello" {
+		t.Fatalf("follower result = %+v", result)
+	}
+}
+
+func TestDeduplicatedBypassesUnseekableAudio(t *testing.T) {
+	next := &gatedTranscriber{started: make(chan struct{}, 1), release: make(chan struct{})}
+	close(next.release)
+	var outcome string
+	d := NewDeduplicated(next, func(o string) { outcome = o })
+	if _, err := d.Transcribe(context.Background(), Input{File: io.MultiReader(strings.NewReader("clip"))}); err != nil {
+		t.Fatal(err)
+	}
+	if outcome != DedupeBypass {
+		t.Fatalf("outcome = %q, want bypass", outcome)
+	}
+}
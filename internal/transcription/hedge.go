@@ -0,0 +1,213 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+type Transcriber interface {
+	Transcribe(ctx context.Context, in Input) (Result, error)
+}
+
+// Provider is a named transcription backend taking part in a hedged request.
+type Provider struct {
+	Name        string
+	Transcriber Transcriber
+	// KeepModel forwards the caller's model to this provider. Leave it unset
+	// for providers whose model names differ, so they use their own default.
+	KeepModel bool
+}
+
+// Hedge outcomes reported to the observer, once per provider per request.
+const (
+	HedgeWin   = "win"
+	HedgeLoss  = "loss"
+	HedgeError = "error"
+)
+
+type HedgeObserverFunc func(provider, outcome string)
+
+// Hedged races the primary and secondary providers for premium-tier requests
+// and returns the first successful result, cancelling the other.
+// Other requests, and requests paid with the caller's own key, only go to
+// the primary: the secondary runs on the operator's key.
+type Hedged struct {
+	primary   Provider
+	secondary Provider
+	observer  HedgeObserverFunc
+}
+
+func NewHedged(primary, secondary Provider, observer HedgeObserverFunc) *Hedged {
+	primary.KeepModel = true
+	return &Hedged{primary: primary, secondary: secondary, observer: observer}
+}
+
+type hedgeResult struct {
+	provider string
+	result   Result
+	err      error
+}
+
+func (h *Hedged) Transcribe(ctx context.Context, in Input) (Result, error) {
+	if TierFromContext(ctx) != TierPremium || !upstream.ServerPays(ctx) {
+		return h.primary.Transcriber.Transcribe(ctx, in)
+	}
+
+	// Both providers need their own reader over the same audio.
+	data, err := io.ReadAll(in.File)
+	if err != nil {
+		return Result{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	providers := []Provider{h.primary, h.secondary}
+	results := make(chan hedgeResult, len(providers))
+	for _, p := range providers {
+		pin := in
+		pin.File = bytes.NewReader(data)
+		if !p.KeepModel {
+			pin.Model = ""
+		}
+		go func() {
+			res, err := p.Transcriber.Transcribe(ctx, pin)
+			results <- hedgeResult{provider: p.Name, result: res, err: err}
+		}()
+	}
+
+	// Prefer the primary's error when both fail: it is the one callers expect.
+	var firstErr error
+	failed := make(map[string]bool, len(providers))
+	for range providers {
+		res := <-results
+		if res.err != nil {
+			h.observe(res.provider, HedgeError)
+			failed[res.provider] = true
+			if firstErr == nil || res.provider == h.primary.Name {
+				firstErr = res.err
+			}
+			continue
+		}
+		cancel()
+		h.observe(res.provider, HedgeWin)
+		for _, p := range providers {
+			if p.Name != res.provider && !failed[p.Name] {
+				h.observe(p.Name, HedgeLoss)
+			}
+		}
+		return res.result, nil
+	}
+	return Result{}, firstErr
+}
+
+func (h *Hedged) observe(provider, outcome string) {
+	if h.observer != nil {
+		h.observer(provider, outcome)
+	}
+}
+
+// Labels DelayedHedge reports to its observer in place of provider names.
+const (
+	HedgeFirst  = "first"
+	HedgeSecond = "hedge"
+)
+
+// DelayedHedge sends a second, identical request to the same client when the
+// first has not answered within delay, and returns whichever succeeds first,
+// cancelling the other. Only the slow tail pays for a second request.
+type DelayedHedge struct {
+	client   Client
+	delay    time.Duration
+	maxBytes int64
+	observer HedgeObserverFunc
+}
+
+// NewDelayedHedge hedges requests whose audio is at most maxBytes (0 for any
+// size); larger uploads take long enough that the delay says nothing about a
+// stuck request. The observer sees the same outcomes as for Hedged, labelled
+// HedgeFirst and HedgeSecond, and only for requests that were hedged.
+func NewDelayedHedge(client Client, delay time.Duration, maxBytes int64, observer HedgeObserverFunc) *DelayedHedge {
+	return &DelayedHedge{client: client, delay: delay, maxBytes: maxBytes, observer: observer}
+}
+
+type delayedResult struct {
+	label string
+	resp  upstream.TranscriptionResponse
+	err   error
+}
+
+func (h *DelayedHedge) Transcribe(ctx context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	// Both requests need their own reader over the same audio.
+	src := io.Reader(req.File)
+	if h.maxBytes > 0 {
+		src = io.LimitReader(src, h.maxBytes+1)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	if h.maxBytes > 0 && int64(len(data)) > h.maxBytes {
+		req.File = io.MultiReader(bytes.NewReader(data), req.File)
+		return h.client.Transcribe(ctx, req)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan delayedResult, 2)
+	start := func(label string) {
+		attempt := req
+		attempt.File = bytes.NewReader(data)
+		go func() {
+			resp, err := h.client.Transcribe(ctx, attempt)
+			results <- delayedResult{label: label, resp: resp, err: err}
+		}()
+	}
+	start(HedgeFirst)
+
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+	select {
+	case res := <-results:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return upstream.TranscriptionResponse{}, ctx.Err()
+	case <-timer.C:
+	}
+	start(HedgeSecond)
+
+	// Prefer the first request's error when both fail; the hedge only
+	// repeats it.
+	var firstErr error
+	failed := make(map[string]bool, 2)
+	for range 2 {
+		res := <-results
+		if res.err != nil {
+			h.observe(res.label, HedgeError)
+			failed[res.label] = true
+			if firstErr == nil || res.label == HedgeFirst {
+				firstErr = res.err
+			}
+			continue
+		}
+		cancel()
+		h.observe(res.label, HedgeWin)
+		for _, label := range []string{HedgeFirst, HedgeSecond} {
+			if label != res.label && !failed[label] {
+				h.observe(label, HedgeLoss)
+			}
+		}
+		return res.resp, nil
+	}
+	return upstream.TranscriptionResponse{}, firstErr
+}
+
+func (h *DelayedHedge) observe(label, outcome string) {
+	if h.observer != nil {
+		h.observer(label, outcome)
+	}
+}
@@ -0,0 +1,208 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+type fakeTranscriber struct {
+	delay time.Duration
+	text  string
+	err   error
+
+	mu       sync.Mutex
+	body     string
+	model    string
+	canceled bool
+}
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, in Input) (Result, error) {
+	body, _ := io.ReadAll(in.File)
+	f.mu.Lock()
+	f.body, f.model = string(body), in.Model
+	f.mu.Unlock()
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		f.mu.Lock()
+		f.canceled = true
+		f.mu.Unlock()
+		return Result{}, ctx.Err()
+	}
+	return Result{Text: f.text}, f.err
+}
+
+type outcomeRecorder struct {
+	mu       sync.Mutex
+	outcomes map[string]string
+}
+
+func (r *outcomeRecorder) observe(provider, outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcomes[provider] = outcome
+}
+
+func TestHedgedReturnsFastestAndCancelsOther(t *testing.T) {
+	groq := &fakeTranscriber{delay: time.Second, text: "slow"}
+	other := &fakeTranscriber{delay: 0, text: "fast"}
+	rec := &outcomeRecorder{outcomes: map[string]string{}}
+	h := NewHedged(Provider{Name: "groq", Transcriber: groq}, Provider{Name: "other", Transcriber: other}, rec.observe)
+
+	res, err := h.Transcribe(reqctx.WithPriority(context.Background(), TierPremium), Input{File: strings.NewReader("audio"), Model: "whisper-large-v3"})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if res.Text != "fast" {
+		t.Fatalf("expected fastest result, got %q", res.Text)
+	}
+	if rec.outcomes["other"] != HedgeWin || rec.outcomes["groq"] != HedgeLoss {
+		t.Fatalf("unexpected outcomes: %v", rec.outcomes)
+	}
+
+	other.mu.Lock()
+	if other.body != "audio" || other.model != "" {
+		t.Fatalf("secondary should get the audio and its own default model, got body=%q model=%q", other.body, other.model)
+	}
+	other.mu.Unlock()
+	deadline := time.Now().Add(time.Second)
+	for {
+		groq.mu.Lock()
+		canceled, body, model := groq.canceled, groq.body, groq.model
+		groq.mu.Unlock()
+		if canceled {
+			if body != "audio" || model != "whisper-large-v3" {
+				t.Fatalf("unexpected primary input: body=%q model=%q", body, model)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected slower provider to be cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHedgedFallsBackWhenOneProviderFails(t *testing.T) {
+	groq := &fakeTranscriber{err: errors.New("upstream down")}
+	other := &fakeTranscriber{delay: 10 * time.Millisecond, text: "ok"}
+	rec := &outcomeRecorder{outcomes: map[string]string{}}
+	h := NewHedged(Provider{Name: "groq", Transcriber: groq}, Provider{Name: "other", Transcriber: other}, rec.observe)
+
+	res, err := h.Transcribe(reqctx.WithPriority(context.Background(), TierPremium), Input{File: strings.NewReader("audio")})
+	if err != nil || res.Text != "ok" {
+		t.Fatalf("expected secondary result, got %+v err=%v", res, err)
+	}
+	if rec.outcomes["groq"] != HedgeError || rec.outcomes["other"] != HedgeWin {
+		t.Fatalf("unexpected outcomes: %v", rec.outcomes)
+	}
+}
+
+func TestHedgedOnlyUsesPrimaryWithoutHedging(t *testing.T) {
+	groq := &fakeTranscriber{text: "primary"}
+	other := &fakeTranscriber{text: "secondary"}
+	h := NewHedged(Provider{Name: "groq", Transcriber: groq}, Provider{Name: "other", Transcriber: other}, nil)
+
+	res, err := h.Transcribe(context.Background(), Input{File: strings.NewReader("audio")})
+	if err != nil || res.Text != "primary" {
+		t.Fatalf("unexpected result: %+v err=%v", res, err)
+	}
+	if other.body != "" {
+		t.Fatal("secondary should not be called without hedging")
+	}
+}
+
+func TestHedgedKeepsCallerKeyedRequestsOnPrimary(t *testing.T) {
+	groq := &fakeTranscriber{text: "primary"}
+	other := &fakeTranscriber{text: "secondary"}
+	h := NewHedged(Provider{Name: "groq", Transcriber: groq}, Provider{Name: "other", Transcriber: other}, nil)
+
+	ctx := reqctx.WithAPIKeySource(reqctx.WithPriority(context.Background(), TierPremium), reqctx.KeySourceCaller)
+	res, err := h.Transcribe(ctx, Input{File: strings.NewReader("audio")})
+	if err != nil || res.Text != "primary" {
+		t.Fatalf("unexpected result: %+v err=%v", res, err)
+	}
+	if other.body != "" {
+		t.Fatal("secondary should not be called for a caller's key")
+	}
+}
+
+// slowOnceClient answers its first call after slow and later calls at once.
+type slowOnceClient struct {
+	slow time.Duration
+
+	mu     sync.Mutex
+	calls  int
+	bodies []string
+}
+
+func (c *slowOnceClient) Transcribe(ctx context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	body, _ := io.ReadAll(req.File)
+	c.mu.Lock()
+	c.calls++
+	call := c.calls
+	c.bodies = append(c.bodies, string(body))
+	c.mu.Unlock()
+	if call == 1 {
+		select {
+		case <-time.After(c.slow):
+		case <-ctx.Done():
+			return upstream.TranscriptionResponse{}, ctx.Err()
+		}
+	}
+	return upstream.TranscriptionResponse{Text: fmt.Sprintf("call %d", call)}, nil
+}
+
+func TestDelayedHedgeSendsIdenticalRequestAfterDelay(t *testing.T) {
+	client := &slowOnceClient{slow: time.Second}
+	outcomes := map[string]string{}
+	var mu sync.Mutex
+	h := NewDelayedHedge(client, 20*time.Millisecond, 0, func(label, outcome string) {
+		mu.Lock()
+		outcomes[label] = outcome
+		mu.Unlock()
+	})
+
+	resp, err := h.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio"), Model: "m"})
+	if err != nil || resp.Text != "call 2" {
+		t.Fatalf("Transcribe = %+v, %v", resp, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if outcomes[HedgeSecond] != HedgeWin || outcomes[HedgeFirst] != HedgeLoss {
+		t.Fatalf("outcomes = %v", outcomes)
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.bodies) != 2 || client.bodies[0] != "audio" || client.bodies[1] != "audio" {
+		t.Fatalf("bodies = %q", client.bodies)
+	}
+}
+
+func TestDelayedHedgeSkipsFastAndLargeRequests(t *testing.T) {
+	client := &slowOnceClient{slow: 50 * time.Millisecond}
+	h := NewDelayedHedge(client, time.Second, 4, nil)
+
+	resp, err := h.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("a")})
+	if err != nil || resp.Text != "call 1" {
+		t.Fatalf("fast request = %+v, %v", resp, err)
+	}
+	h = NewDelayedHedge(client, time.Millisecond, 4, nil)
+	if _, err := h.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("long audio")}); err != nil {
+		t.Fatal(err)
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.calls != 2 || client.bodies[1] != "long audio" {
+		t.Fatalf("calls = %d bodies = %q", client.calls, client.bodies)
+	}
+}
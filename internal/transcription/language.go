@@ -0,0 +1,122 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrLanguageNotAllowed is wrapped by LanguageError.
+var ErrLanguageNotAllowed = errors.New("language not allowed")
+
+// ErrInvalidLanguage is returned by CheckLanguage.
+var ErrInvalidLanguage = errors.New("language must be an ISO 639-1 code, a locale such as en-US, or a Whisper language name")
+
+// CheckLanguage accepts an empty language and anything NormalizeLanguage
+// turns into a two or three letter code.
+func CheckLanguage(language string) error {
+	if strings.TrimSpace(language) == "" {
+		return nil
+	}
+	code := NormalizeLanguage(language)
+	if len(code) < 2 || len(code) > 3 || strings.ContainsFunc(code, func(r rune) bool { return r < 'a' || r > 'z' }) {
+		return ErrInvalidLanguage
+	}
+	return nil
+}
+
+// LanguagePolicy restricts which languages a tenant may transcribe. Allowed
+// holds ISO 639-1 codes; an empty list allows everything.
+type LanguagePolicy struct {
+	Allowed []string
+	// Reject fails requests outside the list, including audio whose language
+	// could not be detected. Otherwise they succeed with a warning.
+	Reject bool
+}
+
+// LanguageError reports a requested or detected language outside the
+// tenant's allowlist. Language is empty when detection gave no answer.
+type LanguageError struct {
+	Language string
+	Detected bool
+}
+
+func (e *LanguageError) Error() string {
+	switch {
+	case e.Language == "":
+		return "the spoken language could not be detected to check it against the tenant's language allowlist"
+	case e.Detected:
+		return fmt.Sprintf("detected language %q is not in the tenant's language allowlist", e.Language)
+	default:
+		return fmt.Sprintf("language %q is not in the tenant's language allowlist", e.Language)
+	}
+}
+
+func (e *LanguageError) Unwrap() error { return ErrLanguageNotAllowed }
+
+// check returns a warning, or an error under Reject, when language is not
+// allowed.
+func (p LanguagePolicy) check(language string, detected bool) (string, error) {
+	code := NormalizeLanguage(language)
+	if code != "" && slices.Contains(p.Allowed, code) {
+		return "", nil
+	}
+	err := &LanguageError{Language: code, Detected: detected}
+	if p.Reject {
+		return "", err
+	}
+	return err.Error(), nil
+}
+
+type languagePolicyContextKey struct{}
+
+func WithLanguagePolicy(ctx context.Context, policy LanguagePolicy) context.Context {
+	return context.WithValue(ctx, languagePolicyContextKey{}, policy)
+}
+
+// LanguagePolicyFromContext reports whether ctx carries a policy with a
+// non-empty allowlist.
+func LanguagePolicyFromContext(ctx context.Context) (LanguagePolicy, bool) {
+	policy, ok := ctx.Value(languagePolicyContextKey{}).(LanguagePolicy)
+	return policy, ok && len(policy.Allowed) > 0
+}
+
+// NormalizeLanguage maps a language code, locale ("en-US") or the English
+// name Whisper reports ("english") to its ISO 639-1 code. Unknown values are
+// returned lower-cased.
+func NormalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if code, ok := languageCodes[language]; ok {
+		return code
+	}
+	if base, _, ok := strings.Cut(strings.ReplaceAll(language, "_", "-"), "-"); ok {
+		language = base
+	}
+	return language
+}
+
+// languageCodes maps Whisper's language names to ISO 639-1 codes.
+var languageCodes = map[string]string{
+	"english": "en", "chinese": "zh", "german": "de", "spanish": "es", "russian": "ru",
+	"korean": "ko", "french": "fr", "japanese": "ja", "portuguese": "pt", "turkish": "tr",
+	"polish": "pl", "catalan": "ca", "dutch": "nl", "arabic": "ar", "swedish": "sv",
+	"italian": "it", "indonesian": "id", "hindi": "hi", "finnish": "fi", "vietnamese": "vi",
+	"hebrew": "he", "ukrainian": "uk", "greek": "el", "malay": "ms", "czech": "cs",
+	"romanian": "ro", "danish": "da", "hungarian": "hu", "tamil": "ta", "norwegian": "no",
+	"thai": "th", "urdu": "ur", "croatian": "hr", "bulgarian": "bg", "lithuanian": "lt",
+	"latin": "la", "maori": "mi", "malayalam": "ml", "welsh": "cy", "slovak": "sk",
+	"telugu": "te", "persian": "fa", "latvian": "lv", "bengali": "bn", "serbian": "sr",
+	"azerbaijani": "az", "slovenian": "sl", "kannada": "kn", "estonian": "et", "macedonian": "mk",
+	"breton": "br", "basque": "eu", "icelandic": "is", "armenian": "hy", "nepali": "ne",
+	"mongolian": "mn", "bosnian": "bs", "kazakh": "kk", "albanian": "sq", "swahili": "sw",
+	"galician": "gl", "marathi": "mr", "punjabi": "pa", "sinhala": "si", "khmer": "km",
+	"shona": "sn", "yoruba": "yo", "somali": "so", "afrikaans": "af", "occitan": "oc",
+	"georgian": "ka", "belarusian": "be", "tajik": "tg", "sindhi": "sd", "gujarati": "gu",
+	"amharic": "am", "yiddish": "yi", "lao": "lo", "uzbek": "uz", "faroese": "fo",
+	"haitian creole": "ht", "pashto": "ps", "turkmen": "tk", "nynorsk": "nn", "maltese": "mt",
+	"sanskrit": "sa", "luxembourgish": "lb", "myanmar": "my", "tibetan": "bo", "tagalog": "tl",
+	"malagasy": "mg", "assamese": "as", "tatar": "tt", "hawaiian": "haw", "lingala": "ln",
+	"hausa": "ha", "bashkir": "ba", "javanese": "jw", "sundanese": "su", "cantonese": "yue",
+}
@@ -0,0 +1,91 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"echoflow/internal/upstream"
+)
+
+type languageClient struct {
+	language string
+	calls    int
+	format   string
+	sent     string
+}
+
+func (c *languageClient) Transcribe(_ context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	c.calls++
+	c.format = req.ResponseFormat
+	c.sent = req.Language
+	return upstream.TranscriptionResponse{Text: "hola", Language: c.language}, nil
+}
+
+func TestLanguagePolicy(t *testing.T) {
+	reject := WithLanguagePolicy(context.Background(), LanguagePolicy{Allowed: []string{"en"}, Reject: true})
+	warn := WithLanguagePolicy(context.Background(), LanguagePolicy{Allowed: []string{"en"}})
+
+	client := &languageClient{language: "spanish"}
+	svc := New(client, "whisper", TimeoutPolicy{})
+	_, err := svc.Transcribe(reject, Input{File: strings.NewReader("x")})
+	var langErr *LanguageError
+	if !errors.As(err, &langErr) || langErr.Language != "es" || !langErr.Detected {
+		t.Fatalf("detected spanish under reject: err = %v", err)
+	}
+	if client.format != "verbose_json" {
+		t.Fatalf("response format = %q, want verbose_json for language detection", client.format)
+	}
+
+	res, err := svc.Transcribe(warn, Input{File: strings.NewReader("x")})
+	if err != nil || res.Language != "es" || len(res.Warnings) != 1 {
+		t.Fatalf("warn policy: res = %+v err = %v", res, err)
+	}
+
+	client.calls = 0
+	if _, err := svc.Transcribe(reject, Input{File: strings.NewReader("x"), Language: "es"}); !errors.Is(err, ErrLanguageNotAllowed) || client.calls != 0 {
+		t.Fatalf("requested es: err = %v, upstream calls = %d", err, client.calls)
+	}
+
+	client.language = ""
+	if _, err := svc.Transcribe(reject, Input{File: strings.NewReader("x")}); !errors.Is(err, ErrLanguageNotAllowed) {
+		t.Fatalf("undetected language under reject: err = %v", err)
+	}
+
+	client.language = "English"
+	if res, err := svc.Transcribe(reject, Input{File: strings.NewReader("x")}); err != nil || len(res.Warnings) != 0 {
+		t.Fatalf("allowed language: res = %+v err = %v", res, err)
+	}
+	// The default language must neither be sent upstream nor pass as the
+	// detected one.
+	client.language = ""
+	withDefault := New(client, "whisper", TimeoutPolicy{}, WithDefaultLanguage("en"))
+	if _, err := withDefault.Transcribe(reject, Input{File: strings.NewReader("x")}); !errors.Is(err, ErrLanguageNotAllowed) || client.sent != "" {
+		t.Fatalf("default language under policy: sent %q, err = %v", client.sent, err)
+	}
+}
+
+func TestLanguagePolicyChecksTheSpokenLanguageOfTranslations(t *testing.T) {
+	reject := WithLanguagePolicy(context.Background(), LanguagePolicy{Allowed: []string{"en"}, Reject: true})
+	client := &languageClient{language: "spanish"}
+	svc := New(client, "whisper", TimeoutPolicy{})
+	_, err := svc.Transcribe(reject, Input{File: strings.NewReader("x"), Translate: true})
+	var langErr *LanguageError
+	if !errors.As(err, &langErr) || langErr.Language != "es" || client.format != "verbose_json" {
+		t.Fatalf("translated spanish under reject: err = %v, format = %q", err, client.format)
+	}
+
+	client.language = "english"
+	if res, err := svc.Transcribe(reject, Input{File: strings.NewReader("x"), Translate: true}); err != nil || res.Language != "en" {
+		t.Fatalf("translated english: res = %+v err = %v", res, err)
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	for in, want := range map[string]string{"English": "en", "en-US": "en", "pt_BR": "pt", "haitian creole": "ht", "de": "de", "": ""} {
+		if got := NormalizeLanguage(in); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
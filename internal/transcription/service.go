@@ -1,45 +1,309 @@
 package transcription
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"io"
+	"math"
 	"strings"
 	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/deadline"
+	"echoflow/internal/upstream"
 )
 
 type Client interface {
-	Transcribe(ctx context.Context, file io.Reader, fileName, model string) (string, error)
+	Transcribe(ctx context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error)
+}
+
+type TimeoutPolicy struct {
+	Base  time.Duration
+	PerMB time.Duration
+	Max   time.Duration
+}
+
+// For scales the base timeout by the upload size so short clips fail fast
+// while long recordings get a proportionally larger budget.
+func (p TimeoutPolicy) For(sizeBytes int64) time.Duration {
+	timeout := p.Base
+	if p.PerMB > 0 && sizeBytes > 0 {
+		timeout += time.Duration(float64(p.PerMB) * float64(sizeBytes) / (1 << 20))
+	}
+	if p.Max > 0 && timeout > p.Max {
+		timeout = p.Max
+	}
+	return timeout
+}
+
+type Input struct {
+	File     io.Reader
+	FileName string
+	Size     int64
+	Model    string
+	// Language is the spoken language, if the caller knows it. It routes the
+	// request and is sent upstream as a hint.
+	Language string
+	// Prompt is passed to the upstream as Whisper's initial prompt.
+	Prompt          string
+	IncludeSegments bool
+	// IncludeWords asks the upstream for per-word timings.
+	IncludeWords bool
+	// Translate returns an English translation instead of a transcript.
+	Translate  bool
+	Preprocess audio.PreprocessOptions
+	// EchoAudio returns the bytes sent upstream, after preprocessing, in
+	// Result.Audio.
+	EchoAudio bool
+}
+
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+	// Confidence is between 0 and 1, when the upstream gives one or a
+	// Whisper log probability to derive it from.
+	Confidence *float64
+}
+
+// Word is one transcribed word and its timing.
+type Word struct {
+	Start      time.Duration
+	End        time.Duration
+	Text       string
+	Confidence *float64
+}
+
+type Result struct {
+	Text          string
+	Segments      []Segment
+	Words         []Word
+	Preprocessing []string
+	Audio         []byte
+	// Language is the detected ISO 639-1 code, when the upstream reports one.
+	Language string
+	Warnings []string
+	// Chunks is set when the upload was split into chunks, in order.
+	Chunks []ChunkTiming
 }
 
 type Service struct {
-	client       Client
-	defaultModel string
-	timeout      time.Duration
+	client          Client
+	defaultModel    string
+	defaultLanguage string
+	timeouts        TimeoutPolicy
+	transcoder      Transcoder
+}
+
+// Transcoder converts audio to 16 kHz mono; see audio.Transcoder.
+type Transcoder interface {
+	Transcode(ctx context.Context, data []byte, format string) ([]byte, error)
+}
+
+type Option func(*Service)
+
+// WithDefaultLanguage sends language upstream for requests that do not give
+// one. It is only a hint to the upstream; routing and tenant allowlists
+// still see the request's own language.
+func WithDefaultLanguage(language string) Option {
+	return func(s *Service) {
+		s.defaultLanguage = NormalizeLanguage(language)
+	}
+}
+
+// WithTranscoder converts uploads with an extension upstreams cannot decode,
+// non-WAV uploads that ask for WAV preprocessing, and uploads that ask for
+// it with Preprocess.Transcode. Without a transcoder those requests fail
+// with audio.ErrTranscodeUnavailable, and other uploads are sent as they are.
+func WithTranscoder(t Transcoder) Option {
+	return func(s *Service) {
+		s.transcoder = t
+	}
 }
 
-func New(client Client, defaultModel string, timeout time.Duration) *Service {
-	return &Service{
+func New(client Client, defaultModel string, timeouts TimeoutPolicy, opts ...Option) *Service {
+	s := &Service{
 		client:       client,
 		defaultModel: strings.TrimSpace(defaultModel),
-		timeout:      timeout,
+		timeouts:     timeouts,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
 	}
+	return s
 }
 
-func (s *Service) Transcribe(ctx context.Context, file io.Reader, fileName, model string) (string, error) {
-	selectedModel := strings.TrimSpace(model)
+func (s *Service) Transcribe(ctx context.Context, in Input) (Result, error) {
+	selectedModel := strings.TrimSpace(in.Model)
 	if selectedModel == "" {
 		selectedModel = s.defaultModel
 	}
+	fileName := in.FileName
 	if fileName == "" {
 		fileName = "audio.wav"
 	}
+	policy, restricted := LanguagePolicyFromContext(ctx)
+	var warnings []string
+	if restricted && in.Language != "" {
+		warning, err := policy.check(in.Language, false)
+		if err != nil {
+			return Result{}, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	file := in.File
+	var applied []string
+	var sent []byte
+	var timeline audio.Timeline
+	transcode := in.Preprocess.Transcode || (s.transcoder != nil && audio.NeedsTranscode(fileName))
+	if in.Preprocess.Enabled() || transcode {
+		data, err := io.ReadAll(in.File)
+		if err != nil {
+			return Result{}, err
+		}
+		if transcode || !audio.IsWAV(data) && s.transcoder != nil {
+			if s.transcoder == nil {
+				return Result{}, audio.ErrTranscodeUnavailable
+			}
+			// The WAV operations need WAV; otherwise FLAC is smaller.
+			format := "flac"
+			if in.Preprocess.Enabled() {
+				format = "wav"
+			}
+			if data, err = s.transcoder.Transcode(ctx, data, format); err != nil {
+				return Result{}, err
+			}
+			fileName = audio.WithExt(fileName, "."+format)
+			applied = append(applied, "transcode")
+		}
+		if in.Preprocess.Enabled() {
+			processed, err := audio.Preprocess(data, in.Preprocess)
+			if err != nil {
+				return Result{}, err
+			}
+			data = processed.Audio
+			applied = append(applied, processed.Applied...)
+			timeline = processed.Timeline
+		}
+		file = bytes.NewReader(data)
+		sent = data
+	} else if in.EchoAudio {
+		data, err := io.ReadAll(in.File)
+		if err != nil {
+			return Result{}, err
+		}
+		file = bytes.NewReader(data)
+		sent = data
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	ctx, budget, cancel := deadline.Start(ctx, "transcription", s.timeouts.For(in.Size))
 	defer cancel()
 
-	text, err := s.client.Transcribe(ctx, file, fileName, selectedModel)
+	req := upstream.TranscriptionRequest{
+		File:     file,
+		FileName: fileName,
+		Model:    selectedModel,
+		Language: cmp.Or(NormalizeLanguage(in.Language), s.defaultLanguage),
+		Prompt:   strings.TrimSpace(in.Prompt),
+	}
+	// Under a policy the upstream has to detect the language itself: the
+	// default would make it transcribe any speech as the default and report
+	// that back, which the policy would then allow.
+	if restricted {
+		req.Language = NormalizeLanguage(in.Language)
+	}
+	// verbose_json also carries the detected language the policy needs.
+	if in.IncludeSegments || in.IncludeWords || restricted {
+		req.ResponseFormat = "verbose_json"
+	}
+	req.WordTimestamps = in.IncludeWords
+	req.Translate = in.Translate
+
+	resp, err := s.client.Transcribe(ctx, req)
 	if err != nil {
-		return "", err
+		return Result{}, budget.Explain(err)
+	}
+
+	// Upstreams told the language often leave it out of the response; the
+	// audio was still transcribed as that language.
+	result := Result{Text: strings.TrimSpace(resp.Text), Preprocessing: applied, Language: cmp.Or(NormalizeLanguage(resp.Language), req.Language)}
+	if restricted {
+		// Only a language the caller asked for, already checked above, stands
+		// in for one the upstream left out. For a translation this is still
+		// the spoken language, so the policy holds whatever comes out.
+		warning, err := policy.check(cmp.Or(NormalizeLanguage(resp.Language), req.Language), true)
+		if err != nil {
+			return Result{}, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	if in.Translate {
+		// A translation is always English, whatever was spoken.
+		result.Language = "en"
+	}
+	result.Warnings = warnings
+	if in.EchoAudio {
+		result.Audio = sent
+	}
+	if in.IncludeWords {
+		for _, w := range resp.Words {
+			result.Words = append(result.Words, Word{
+				Start:      secondsToDuration(w.Start),
+				End:        secondsToDuration(w.End),
+				Text:       strings.TrimSpace(w.Word),
+				Confidence: w.Confidence,
+			})
+		}
 	}
-	return strings.TrimSpace(text), nil
+	if in.IncludeSegments {
+		for _, seg := range resp.Segments {
+			result.Segments = append(result.Segments, Segment{
+				Start:      secondsToDuration(seg.Start),
+				End:        secondsToDuration(seg.End),
+				Text:       strings.TrimSpace(seg.Text),
+				Confidence: segmentConfidence(seg),
+			})
+		}
+	}
+	result.remap(timeline)
+	return result, nil
+}
+
+// remap moves timings from trimmed audio onto the timeline of the upload,
+// so they point at the right place in the caller's recording.
+func (r *Result) remap(timeline audio.Timeline) {
+	if timeline == nil {
+		return
+	}
+	for i := range r.Segments {
+		r.Segments[i].Start, r.Segments[i].End = timeline.Original(r.Segments[i].Start), timeline.Original(r.Segments[i].End)
+	}
+	for i := range r.Words {
+		r.Words[i].Start, r.Words[i].End = timeline.Original(r.Words[i].Start), timeline.Original(r.Words[i].End)
+	}
+	for i := range r.Chunks {
+		r.Chunks[i].Start, r.Chunks[i].End = timeline.Original(r.Chunks[i].Start), timeline.Original(r.Chunks[i].End)
+	}
+}
+
+// segmentConfidence is the vendor's confidence, or else the mean token
+// probability exp(avg_logprob) Whisper's log probability stands for.
+func segmentConfidence(seg upstream.TranscriptionSegment) *float64 {
+	if seg.Confidence != nil || seg.AvgLogprob == nil {
+		return seg.Confidence
+	}
+	confidence := math.Exp(min(*seg.AvgLogprob, 0))
+	return &confidence
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
 }
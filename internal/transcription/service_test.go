@@ -0,0 +1,203 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/audio"
+	"echoflow/internal/upstream"
+)
+
+func TestTimeoutPolicyScalesWithSizeAndCaps(t *testing.T) {
+	p := TimeoutPolicy{Base: 10 * time.Second, PerMB: 2 * time.Second, Max: 30 * time.Second}
+
+	cases := map[int64]time.Duration{
+		0:         10 * time.Second,
+		512 << 10: 11 * time.Second,
+		5 << 20:   20 * time.Second,
+		50 << 20:  30 * time.Second,
+	}
+	for size, want := range cases {
+		if got := p.For(size); got != want {
+			t.Fatalf("For(%d): got %v want %v", size, got, want)
+		}
+	}
+}
+
+type recordingClient struct {
+	body     []byte
+	fileName string
+	language string
+}
+
+func (c *recordingClient) Transcribe(_ context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	c.body, _ = io.ReadAll(req.File)
+	c.fileName = req.FileName
+	c.language = req.Language
+	return upstream.TranscriptionResponse{Text: "ok"}, nil
+}
+
+type segmentClient struct {
+	segments []upstream.TranscriptionSegment
+}
+
+func (c segmentClient) Transcribe(_ context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	if req.ResponseFormat != "verbose_json" {
+		return upstream.TranscriptionResponse{Text: "ok"}, nil
+	}
+	return upstream.TranscriptionResponse{Text: "ok", Segments: c.segments}, nil
+}
+
+func TestTranscribeDerivesSegmentConfidence(t *testing.T) {
+	vendor, logprob := 0.9, math.Log(0.5)
+	svc := New(segmentClient{segments: []upstream.TranscriptionSegment{
+		{Start: 0, End: 1.5, Text: " hello ", Confidence: &vendor},
+		{Start: 1.5, End: 2, Text: "there", AvgLogprob: &logprob},
+		{Start: 2, End: 3, Text: "friend"},
+	}}, "whisper", TimeoutPolicy{Base: time.Second})
+
+	res, err := svc.Transcribe(context.Background(), Input{File: bytes.NewReader(nil), IncludeSegments: true})
+	if err != nil || len(res.Segments) != 3 {
+		t.Fatalf("segments %+v, err %v", res.Segments, err)
+	}
+	if seg := res.Segments[0]; seg.Text != "hello" || seg.End != 1500*time.Millisecond || *seg.Confidence != 0.9 {
+		t.Fatalf("vendor confidence segment = %+v", seg)
+	}
+	if c := res.Segments[1].Confidence; c == nil || math.Abs(*c-0.5) > 1e-9 || res.Segments[2].Confidence != nil {
+		t.Fatalf("derived confidences = %v, %v", c, res.Segments[2].Confidence)
+	}
+}
+
+func TestTranscribeMapsTrimmedTimingsToUpload(t *testing.T) {
+	// Two 0.5s tones split by 3s of silence; trimming leaves the second one
+	// at 1.1s.
+	tone := make([]float64, 4000)
+	for i := range tone {
+		tone[i] = 0.1
+	}
+	samples := slices.Concat(tone, make([]float64, 24000), tone)
+	svc := New(segmentClient{segments: []upstream.TranscriptionSegment{{Start: 1.1, End: 1.6, Text: "second"}}}, "whisper", TimeoutPolicy{Base: time.Second})
+
+	res, err := svc.Transcribe(context.Background(), Input{
+		File:            bytes.NewReader(audio.FromSamples([][]float64{samples}, 8000).Encode()),
+		Preprocess:      audio.PreprocessOptions{TrimSilence: true},
+		IncludeSegments: true,
+	})
+	if err != nil || len(res.Segments) != 1 {
+		t.Fatalf("segments %+v, err %v", res.Segments, err)
+	}
+	if seg := res.Segments[0]; seg.Start != 3500*time.Millisecond || seg.End != 4*time.Second {
+		t.Fatalf("segment = %+v, want 3.5s to 4s", seg)
+	}
+}
+
+func TestTranscribeSendsLanguageHint(t *testing.T) {
+	client := &recordingClient{}
+	svc := New(client, "whisper", TimeoutPolicy{Base: time.Second}, WithDefaultLanguage("EN"))
+
+	res, err := svc.Transcribe(context.Background(), Input{File: bytes.NewReader(nil), Language: "German"})
+	if err != nil || client.language != "de" || res.Language != "de" {
+		t.Fatalf("explicit language: sent %q, result %q, err %v", client.language, res.Language, err)
+	}
+	if _, err := svc.Transcribe(context.Background(), Input{File: bytes.NewReader(nil)}); err != nil || client.language != "en" {
+		t.Fatalf("default language: sent %q, err %v", client.language, err)
+	}
+	if _, err := New(client, "whisper", TimeoutPolicy{}).Transcribe(context.Background(), Input{File: bytes.NewReader(nil)}); err != nil || client.language != "" {
+		t.Fatalf("no language: sent %q, err %v", client.language, err)
+	}
+}
+
+func TestCheckLanguage(t *testing.T) {
+	for _, ok := range []string{"", "en", "pt-BR", "german", "haw"} {
+		if err := CheckLanguage(ok); err != nil {
+			t.Errorf("CheckLanguage(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"e", "klingon", "en1"} {
+		if err := CheckLanguage(bad); err == nil {
+			t.Errorf("CheckLanguage(%q) accepted", bad)
+		}
+	}
+}
+
+func TestTranscribeEchoesAudioSentUpstream(t *testing.T) {
+	client := &recordingClient{}
+	svc := New(client, "whisper", TimeoutPolicy{Base: time.Second})
+	stereo := audio.FromSamples([][]float64{{0.2, 0.4}, {0.4, 0.2}}, 8000).Encode()
+
+	res, err := svc.Transcribe(context.Background(), Input{File: bytes.NewReader(stereo), EchoAudio: true})
+	if err != nil || !bytes.Equal(res.Audio, stereo) || !bytes.Equal(client.body, stereo) {
+		t.Fatalf("unprocessed echo: err=%v echoed %d bytes, sent %d", err, len(res.Audio), len(client.body))
+	}
+
+	res, err = svc.Transcribe(context.Background(), Input{
+		File:       bytes.NewReader(stereo),
+		Preprocess: audio.PreprocessOptions{Downmix: true},
+		EchoAudio:  true,
+	})
+	if err != nil || !bytes.Equal(res.Audio, client.body) || bytes.Equal(res.Audio, stereo) {
+		t.Fatalf("preprocessed echo should match the downmixed upload: err=%v", err)
+	}
+
+	res, _ = svc.Transcribe(context.Background(), Input{File: bytes.NewReader(stereo)})
+	if res.Audio != nil {
+		t.Fatal("audio echoed without EchoAudio")
+	}
+}
+
+type fakeTranscoder struct {
+	format string
+}
+
+func (t *fakeTranscoder) Transcode(_ context.Context, data []byte, format string) ([]byte, error) {
+	t.format = format
+	if format == "wav" {
+		return audio.FromSamples([][]float64{{0.2, 0.4}}, 16000).Encode(), nil
+	}
+	return []byte("fLaC"), nil
+}
+
+func TestTranscribeTranscodesUndecodableUploads(t *testing.T) {
+	client := &recordingClient{}
+	transcoder := &fakeTranscoder{}
+	svc := New(client, "whisper", TimeoutPolicy{Base: time.Second}, WithTranscoder(transcoder))
+
+	res, err := svc.Transcribe(context.Background(), Input{File: strings.NewReader("#!AMR\n"), FileName: "call.amr"})
+	if err != nil || client.fileName != "call.flac" || string(client.body) != "fLaC" {
+		t.Fatalf("AMR upload: err=%v sent %q as %q", err, client.body, client.fileName)
+	}
+	if len(res.Preprocessing) != 1 || res.Preprocessing[0] != "transcode" {
+		t.Fatalf("Preprocessing = %q", res.Preprocessing)
+	}
+
+	// WAV preprocessing of a non-WAV upload transcodes to WAV first.
+	res, err = svc.Transcribe(context.Background(), Input{
+		File:       strings.NewReader("ID3"),
+		FileName:   "memo.mp3",
+		Preprocess: audio.PreprocessOptions{Normalize: true},
+	})
+	if err != nil || transcoder.format != "wav" || client.fileName != "memo.wav" || !audio.IsWAV(client.body) {
+		t.Fatalf("preprocessed MP3: err=%v format=%q name=%q", err, transcoder.format, client.fileName)
+	}
+	if len(res.Preprocessing) != 2 || res.Preprocessing[0] != "transcode" || res.Preprocessing[1] != "normalize" {
+		t.Fatalf("Preprocessing = %q", res.Preprocessing)
+	}
+
+	// Decodable uploads pass through.
+	if _, err := svc.Transcribe(context.Background(), Input{File: strings.NewReader("ID3"), FileName: "memo.mp3"}); err != nil || client.fileName != "memo.mp3" {
+		t.Fatalf("MP3 upload: err=%v name=%q", err, client.fileName)
+	}
+
+	plain := New(client, "whisper", TimeoutPolicy{Base: time.Second})
+	_, err = plain.Transcribe(context.Background(), Input{File: strings.NewReader("ID3"), FileName: "memo.mp3", Preprocess: audio.PreprocessOptions{Transcode: true}})
+	if !errors.Is(err, audio.ErrTranscodeUnavailable) {
+		t.Fatalf("Transcode without a transcoder: err=%v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package transcription
+
+import (
+	"context"
+
+	"echoflow/internal/reqctx"
+)
+
+// Service tiers. Requests without a tier are standard.
+const (
+	TierStandard = "standard"
+	TierPremium  = "premium"
+)
+
+// TierFromContext returns the request's tier, set with reqctx.WithPriority.
+func TierFromContext(ctx context.Context) string {
+	if tier := reqctx.Priority(ctx); tier != "" {
+		return tier
+	}
+	return TierStandard
+}
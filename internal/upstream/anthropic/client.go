@@ -0,0 +1,344 @@
+// Package anthropic completes chats with Anthropic's Messages API.
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+const (
+	DefaultBaseURL = "https://api.anthropic.com/v1"
+	// APIVersion is the anthropic-version header the request and response
+	// shapes below follow.
+	APIVersion = "2023-06-01"
+	// DefaultMaxTokens is sent when a request does not set MaxTokens, which
+	// the Messages API requires.
+	DefaultMaxTokens = 4096
+)
+
+type ObserverFunc func(endpoint string, status int, duration time.Duration)
+
+type Option func(*Client)
+
+// Client implements upstream.ChatCompleter and upstream.HealthChecker. It
+// always uses its own key: callers' BYOT tokens belong to the primary vendor.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	maxTokens  int
+	httpClient *http.Client
+	observer   ObserverFunc
+}
+
+func WithObserver(observer ObserverFunc) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
+}
+
+// WithMaxTokens sets the output limit for requests that do not set one.
+func WithMaxTokens(maxTokens int) Option {
+	return func(c *Client) {
+		if maxTokens > 0 {
+			c.maxTokens = maxTokens
+		}
+	}
+}
+
+func New(baseURL, apiKey string, httpClient *http.Client, opts ...Option) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultBaseURL
+	}
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     strings.TrimSpace(apiKey),
+		maxTokens:  DefaultMaxTokens,
+		httpClient: httpClient,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+type messagesRequest struct {
+	Model         string                 `json:"model"`
+	MaxTokens     int                    `json:"max_tokens"`
+	System        string                 `json:"system,omitempty"`
+	Messages      []upstream.ChatMessage `json:"messages"`
+	Temperature   float64                `json:"temperature"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Stream        bool                   `json:"stream,omitempty"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (c *Client) ChatCompletion(ctx context.Context, reqPayload upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe("anthropic_messages", statusCode, time.Since(started)) }()
+
+	req, err := c.newMessagesRequest(ctx, reqPayload, false)
+	if err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return upstream.ChatCompletionResponse{}, &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+	}
+	return parseMessage(body)
+}
+
+// StreamChatCompletion reads the Messages API event stream and calls onDelta
+// for each text delta.
+func (c *Client) StreamChatCompletion(ctx context.Context, reqPayload upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe("anthropic_messages", statusCode, time.Since(started)) }()
+
+	req, err := c.newMessagesRequest(ctx, reqPayload, true)
+	if err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+		return upstream.ChatCompletionResponse{}, &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+	}
+	return readMessageStream(resp.Body, onDelta)
+}
+
+// CheckHealth lists the models visible to the key, which fails fast on a
+// revoked or mistyped key.
+func (c *Client) CheckHealth(ctx context.Context) error {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe("anthropic_models", statusCode, time.Since(started)) }()
+
+	if c.apiKey == "" {
+		return upstream.ErrMissingAPIKey
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+	}
+	return nil
+}
+
+// newMessagesRequest moves system messages into the top-level system prompt,
+// which is where the Messages API expects them. Logit bias and JSON mode
+// have no Messages API equivalent and are dropped; prompts asking for JSON
+// still get it.
+func (c *Client) newMessagesRequest(ctx context.Context, reqPayload upstream.ChatCompletionRequest, stream bool) (*http.Request, error) {
+	if c.apiKey == "" {
+		return nil, upstream.ErrMissingAPIKey
+	}
+	payload := messagesRequest{
+		Model:         reqPayload.Model,
+		MaxTokens:     reqPayload.MaxTokens,
+		Temperature:   reqPayload.Temperature,
+		StopSequences: reqPayload.Stop,
+		Stream:        stream,
+	}
+	if payload.MaxTokens <= 0 {
+		payload.MaxTokens = c.maxTokens
+	}
+	var system []string
+	for _, msg := range reqPayload.Messages {
+		if msg.Role != "system" {
+			payload.Messages = append(payload.Messages, msg)
+			continue
+		}
+		text, ok := msg.Content.(string)
+		if !ok {
+			return nil, fmt.Errorf("anthropic: system message content must be text")
+		}
+		system = append(system, text)
+	}
+	payload.System = strings.Join(system, "\n\n")
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", APIVersion)
+}
+
+func parseMessage(data []byte) (upstream.ChatCompletionResponse, error) {
+	var parsed struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage *usage `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return upstream.ChatCompletionResponse{}, fmt.Errorf("invalid anthropic response: %w", err)
+	}
+	var content strings.Builder
+	for _, block := range parsed.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return upstream.ChatCompletionResponse{}, fmt.Errorf("missing content text")
+	}
+	resp := upstream.ChatCompletionResponse{Content: content.String()}
+	if parsed.Usage != nil {
+		resp.Usage = tokenUsage(parsed.Usage.InputTokens, parsed.Usage.OutputTokens)
+	}
+	return resp, nil
+}
+
+// readMessageStream follows the Messages API events: message_start carries
+// the input tokens, content_block_delta the text and message_delta the
+// output tokens so far.
+func readMessageStream(body io.Reader, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	var content strings.Builder
+	var inputTokens, outputTokens int
+	var sawUsage bool
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage *usage `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage *usage `json:"usage"`
+			Error *struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return upstream.ChatCompletionResponse{}, fmt.Errorf("invalid anthropic event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message.Usage != nil {
+				inputTokens, outputTokens, sawUsage = event.Message.Usage.InputTokens, event.Message.Usage.OutputTokens, true
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				outputTokens, sawUsage = event.Usage.OutputTokens, true
+			}
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			content.WriteString(event.Delta.Text)
+			if onDelta != nil {
+				if err := onDelta(event.Delta.Text); err != nil {
+					return upstream.ChatCompletionResponse{}, err
+				}
+			}
+		case "error":
+			if event.Error != nil {
+				return upstream.ChatCompletionResponse{}, fmt.Errorf("anthropic stream error: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return upstream.ChatCompletionResponse{}, fmt.Errorf("anthropic stream error")
+		case "message_stop":
+			return streamResult(content.String(), inputTokens, outputTokens, sawUsage)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	return streamResult(content.String(), inputTokens, outputTokens, sawUsage)
+}
+
+func streamResult(content string, inputTokens, outputTokens int, sawUsage bool) (upstream.ChatCompletionResponse, error) {
+	if content == "" {
+		return upstream.ChatCompletionResponse{}, fmt.Errorf("missing streamed content")
+	}
+	resp := upstream.ChatCompletionResponse{Content: content}
+	if sawUsage {
+		resp.Usage = tokenUsage(inputTokens, outputTokens)
+	}
+	return resp, nil
+}
+
+func tokenUsage(inputTokens, outputTokens int) *upstream.TokenUsage {
+	return &upstream.TokenUsage{PromptTokens: inputTokens, CompletionTokens: outputTokens, TotalTokens: inputTokens + outputTokens}
+}
+
+func (c *Client) observe(endpoint string, status int, duration time.Duration) {
+	if c.observer != nil {
+		c.observer(endpoint, status, duration)
+	}
+}
+
+func truncateBody(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= 4096 {
+		return s
+	}
+	return s[:4096] + "..."
+}
@@ -0,0 +1,124 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"echoflow/internal/upstream"
+)
+
+func TestChatCompletionMovesSystemPromptAndParsesUsage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "ant-key" || r.Header.Get("anthropic-version") != APIVersion {
+			t.Errorf("unexpected request %s key=%q version=%q", r.URL.Path, r.Header.Get("x-api-key"), r.Header.Get("anthropic-version"))
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("caller token forwarded: %q", r.Header.Get("Authorization"))
+		}
+		var body messagesRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if body.Model != "claude-3-5-haiku-latest" || body.System != "clean it up" || body.MaxTokens != DefaultMaxTokens || len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+			t.Errorf("unexpected body %+v", body)
+		}
+		if len(body.StopSequences) != 1 || body.StopSequences[0] != "</out>" {
+			t.Errorf("stop_sequences = %v", body.StopSequences)
+		}
+		_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"Hello there."}],"usage":{"input_tokens":12,"output_tokens":3}}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL+"/v1", "ant-key", ts.Client())
+	resp, err := c.ChatCompletion(upstream.WithRequestAPIKey(context.Background(), "caller-groq-key"), upstream.ChatCompletionRequest{
+		Model: "claude-3-5-haiku-latest",
+		Messages: []upstream.ChatMessage{
+			{Role: "system", Content: "clean it up"},
+			{Role: "user", Content: "hello there"},
+		},
+		Stop: []string{"</out>"},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if resp.Content != "Hello there." || resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+		t.Fatalf("resp = %+v usage=%+v", resp, resp.Usage)
+	}
+}
+
+func TestStreamChatCompletionForwardsTextDeltas(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body messagesRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream {
+			t.Errorf("expected stream request")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(strings.Join([]string{
+			`event: message_start`,
+			`data: {"type":"message_start","message":{"usage":{"input_tokens":10,"output_tokens":1}}}`,
+			``,
+			`event: content_block_delta`,
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			``,
+			`event: ping`,
+			`data: {"type":"ping"}`,
+			``,
+			`event: content_block_delta`,
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there."}}`,
+			``,
+			`event: message_delta`,
+			`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+			``,
+			`event: message_stop`,
+			`data: {"type":"message_stop"}`,
+			``,
+		}, "\n")))
+	}))
+	defer ts.Close()
+
+	var deltas []string
+	c := New(ts.URL, "ant-key", ts.Client())
+	resp, err := c.StreamChatCompletion(context.Background(), upstream.ChatCompletionRequest{
+		Model:    "claude-3-5-haiku-latest",
+		Messages: []upstream.ChatMessage{{Role: "user", Content: "hello there"}},
+	}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChatCompletion: %v", err)
+	}
+	if resp.Content != "Hello there." || len(deltas) != 2 || resp.Usage == nil || resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 4 {
+		t.Fatalf("resp = %+v usage=%+v deltas=%q", resp, resp.Usage, deltas)
+	}
+}
+
+func TestChatCompletionReturnsUpstreamError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	defer ts.Close()
+
+	_, err := New(ts.URL, "ant-key", ts.Client()).ChatCompletion(context.Background(), upstream.ChatCompletionRequest{
+		Model:    "claude-3-5-haiku-latest",
+		Messages: []upstream.ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusTooManyRequests || !strings.Contains(upstreamErr.Body, "rate_limit_error") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestChatCompletionRequiresKey(t *testing.T) {
+	_, err := New("", "", nil).ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "claude-3-5-haiku-latest"})
+	if !errors.Is(err, upstream.ErrMissingAPIKey) {
+		t.Fatalf("err = %v", err)
+	}
+}
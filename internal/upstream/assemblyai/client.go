@@ -0,0 +1,245 @@
+// Package assemblyai transcribes audio with AssemblyAI's asynchronous v2 API.
+package assemblyai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+const (
+	DefaultBaseURL      = "https://api.assemblyai.com/v2"
+	DefaultPollInterval = time.Second
+)
+
+type ObserverFunc func(endpoint string, status int, duration time.Duration)
+
+type Option func(*Client)
+
+// Client implements upstream.Transcriber and upstream.HealthChecker. AssemblyAI
+// transcribes asynchronously; Transcribe uploads the audio, submits a
+// transcript and polls it until it finishes or ctx ends. Like the other
+// secondary vendors it always uses its own key.
+type Client struct {
+	baseURL      string
+	apiKey       string
+	httpClient   *http.Client
+	pollInterval time.Duration
+	observer     ObserverFunc
+}
+
+func WithObserver(observer ObserverFunc) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
+}
+
+// WithPollInterval sets how often a submitted transcript is checked.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		if interval > 0 {
+			c.pollInterval = interval
+		}
+	}
+}
+
+func New(baseURL, apiKey string, httpClient *http.Client, opts ...Option) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultBaseURL
+	}
+	c := &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		apiKey:       strings.TrimSpace(apiKey),
+		httpClient:   httpClient,
+		pollInterval: DefaultPollInterval,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+type transcript struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	Text         string `json:"text"`
+	LanguageCode string `json:"language_code"`
+	Error        string `json:"error"`
+	// Words have millisecond timestamps.
+	Words []struct {
+		Text       string   `json:"text"`
+		Start      int64    `json:"start"`
+		End        int64    `json:"end"`
+		Confidence *float64 `json:"confidence"`
+	} `json:"words"`
+}
+
+// Transcribe blocks until the transcript completes. A verbose_json response
+// format fetches the transcript's sentences as segments, and enables
+// language detection unless the request names the language. When ctx ends first the submitted transcript is
+// left to finish on AssemblyAI's side.
+func (c *Client) Transcribe(ctx context.Context, reqPayload upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	if c.apiKey == "" {
+		return upstream.TranscriptionResponse{}, upstream.ErrMissingAPIKey
+	}
+	if reqPayload.Translate {
+		return upstream.TranscriptionResponse{}, upstream.ErrTranslationUnsupported
+	}
+
+	var uploaded struct {
+		UploadURL string `json:"upload_url"`
+	}
+	if err := c.do(ctx, "assemblyai_upload", http.MethodPost, "/upload", reqPayload.File, "application/octet-stream", &uploaded); err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	if uploaded.UploadURL == "" {
+		return upstream.TranscriptionResponse{}, fmt.Errorf("missing upload_url in assemblyai response")
+	}
+
+	submit := map[string]any{"audio_url": uploaded.UploadURL}
+	if reqPayload.Model != "" {
+		submit["speech_model"] = reqPayload.Model
+	}
+	verbose := reqPayload.ResponseFormat == "verbose_json"
+	if reqPayload.Language != "" {
+		submit["language_code"] = reqPayload.Language
+	} else if verbose {
+		submit["language_detection"] = true
+	}
+	body, err := json.Marshal(submit)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	var current transcript
+	if err := c.do(ctx, "assemblyai_transcript", http.MethodPost, "/transcript", bytes.NewReader(body), "application/json", &current); err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	if current.ID == "" {
+		return upstream.TranscriptionResponse{}, fmt.Errorf("missing id in assemblyai response")
+	}
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for current.Status != "completed" {
+		if current.Status == "error" {
+			return upstream.TranscriptionResponse{}, fmt.Errorf("assemblyai transcript %s failed: %s", current.ID, current.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return upstream.TranscriptionResponse{}, ctx.Err()
+		case <-ticker.C:
+		}
+		if err := c.do(ctx, "assemblyai_poll", http.MethodGet, "/transcript/"+url.PathEscape(current.ID), nil, "", &current); err != nil {
+			return upstream.TranscriptionResponse{}, err
+		}
+	}
+
+	resp := upstream.TranscriptionResponse{Text: current.Text, Language: current.LanguageCode}
+	if reqPayload.WordTimestamps {
+		for _, w := range current.Words {
+			resp.Words = append(resp.Words, upstream.TranscriptionWord{
+				Start:      float64(w.Start) / 1000,
+				End:        float64(w.End) / 1000,
+				Word:       w.Text,
+				Confidence: w.Confidence,
+			})
+		}
+	}
+	if !verbose {
+		return resp, nil
+	}
+	var sentences struct {
+		Sentences []struct {
+			Start      int64    `json:"start"`
+			End        int64    `json:"end"`
+			Text       string   `json:"text"`
+			Confidence *float64 `json:"confidence"`
+		} `json:"sentences"`
+	}
+	if err := c.do(ctx, "assemblyai_sentences", http.MethodGet, "/transcript/"+url.PathEscape(current.ID)+"/sentences", nil, "", &sentences); err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	for _, s := range sentences.Sentences {
+		// AssemblyAI timestamps are milliseconds.
+		resp.Segments = append(resp.Segments, upstream.TranscriptionSegment{
+			Start:      float64(s.Start) / 1000,
+			End:        float64(s.End) / 1000,
+			Text:       s.Text,
+			Confidence: s.Confidence,
+		})
+	}
+	return resp, nil
+}
+
+// CheckHealth lists at most one transcript, which fails fast on a revoked or
+// mistyped key.
+func (c *Client) CheckHealth(ctx context.Context) error {
+	if c.apiKey == "" {
+		return upstream.ErrMissingAPIKey
+	}
+	return c.do(ctx, "assemblyai_list", http.MethodGet, "/transcript?limit=1", nil, "", nil)
+}
+
+func (c *Client) do(ctx context.Context, endpoint, method, path string, body io.Reader, contentType string, out any) error {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe(endpoint, statusCode, time.Since(started)) }()
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.apiKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid assemblyai response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) observe(endpoint string, status int, duration time.Duration) {
+	if c.observer != nil {
+		c.observer(endpoint, status, duration)
+	}
+}
+
+func truncateBody(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= 4096 {
+		return s
+	}
+	return s[:4096] + "..."
+}
@@ -0,0 +1,123 @@
+package assemblyai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+func TestTranscribeUploadsSubmitsAndPolls(t *testing.T) {
+	var polls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "aai-key" {
+			t.Errorf("%s %s auth=%q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /v2/upload":
+			if body, _ := io.ReadAll(r.Body); string(body) != "wav-bytes" {
+				t.Errorf("upload body = %q", body)
+			}
+			_, _ = w.Write([]byte(`{"upload_url":"https://cdn.example/abc"}`))
+		case "POST /v2/transcript":
+			var submit map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&submit)
+			if submit["audio_url"] != "https://cdn.example/abc" || submit["speech_model"] != "best" || submit["language_detection"] != true {
+				t.Errorf("submit = %v", submit)
+			}
+			_, _ = w.Write([]byte(`{"id":"t1","status":"queued"}`))
+		case "GET /v2/transcript/t1":
+			if polls.Add(1) < 2 {
+				_, _ = w.Write([]byte(`{"id":"t1","status":"processing"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"t1","status":"completed","text":"Hello there. General.","language_code":"en"}`))
+		case "GET /v2/transcript/t1/sentences":
+			_, _ = w.Write([]byte(`{"sentences":[{"start":500,"end":1250,"text":"Hello there."},{"start":2000,"end":2500,"text":"General."}]}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	var observed []string
+	c := New(ts.URL+"/v2", "aai-key", ts.Client(), WithPollInterval(time.Millisecond), WithObserver(func(endpoint string, _ int, _ time.Duration) {
+		observed = append(observed, endpoint)
+	}))
+	resp, err := c.Transcribe(upstream.WithRequestAPIKey(context.Background(), "caller-groq-key"), upstream.TranscriptionRequest{
+		File:           strings.NewReader("wav-bytes"),
+		FileName:       "call.wav",
+		Model:          "best",
+		ResponseFormat: "verbose_json",
+	})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if resp.Text != "Hello there. General." || resp.Language != "en" || len(resp.Segments) != 2 || resp.Segments[0].End != 1.25 || resp.Segments[1].Text != "General." {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if want := "assemblyai_upload,assemblyai_transcript,assemblyai_poll,assemblyai_poll,assemblyai_sentences"; strings.Join(observed, ",") != want {
+		t.Fatalf("observed = %v", observed)
+	}
+}
+
+func TestTranscribeReportsFailedTranscript(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload":
+			_, _ = w.Write([]byte(`{"upload_url":"https://cdn.example/abc"}`))
+		case "/transcript":
+			_, _ = w.Write([]byte(`{"id":"t2","status":"error","error":"audio too short"}`))
+		}
+	}))
+	defer ts.Close()
+
+	_, err := New(ts.URL, "aai-key", ts.Client()).Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("x")})
+	if err == nil || !strings.Contains(err.Error(), "audio too short") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestTranscribeStopsPollingWhenContextEnds(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload":
+			_, _ = w.Write([]byte(`{"upload_url":"https://cdn.example/abc"}`))
+		default:
+			_, _ = w.Write([]byte(`{"id":"t3","status":"processing"}`))
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := New(ts.URL, "aai-key", ts.Client(), WithPollInterval(5*time.Millisecond)).Transcribe(ctx, upstream.TranscriptionRequest{File: strings.NewReader("x")})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestUpstreamErrorsAndMissingKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"Authentication error"}`, http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	err := New(ts.URL, "bad", ts.Client()).CheckHealth(context.Background())
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("CheckHealth = %v", err)
+	}
+	if _, err := New(ts.URL, "", ts.Client()).Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("x")}); !errors.Is(err, upstream.ErrMissingAPIKey) {
+		t.Fatalf("Transcribe without key = %v", err)
+	}
+}
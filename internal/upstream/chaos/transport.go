@@ -0,0 +1,113 @@
+// Package chaos injects upstream faults for exercising retry and fallback
+// behaviour outside production.
+package chaos
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FaultHeader is set on responses that were altered or synthesized, so logs
+// and tests can tell injected failures from real ones.
+const FaultHeader = "X-Chaos-Fault"
+
+type Config struct {
+	Latency           time.Duration
+	LatencyRate       float64
+	RateLimitRate     float64
+	TruncateRate      float64
+	MalformedJSONRate float64
+}
+
+type Transport struct {
+	base http.RoundTripper
+	cfg  Config
+}
+
+func NewTransport(base http.RoundTripper, cfg Config) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, cfg: cfg}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.hit(t.cfg.LatencyRate) && t.cfg.Latency > 0 {
+		timer := time.NewTimer(t.cfg.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	if t.hit(t.cfg.RateLimitRate) {
+		closeBody(req)
+		return synthesize(req, http.StatusTooManyRequests, "rate_limit", `{"error":{"message":"chaos: injected rate limit","type":"rate_limit_error"}}`), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+
+	switch {
+	case t.hit(t.cfg.TruncateRate):
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body[:len(body)/2]), errReader{io.ErrUnexpectedEOF}))
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		resp.Header.Set(FaultHeader, "truncate")
+	case t.hit(t.cfg.MalformedJSONRate):
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(strings.NewReader(`{"text": "chaos`))
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		resp.Header.Set("Content-Type", "application/json")
+		resp.Header.Set(FaultHeader, "malformed_json")
+	}
+	return resp, nil
+}
+
+func (t *Transport) hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+func synthesize(req *http.Request, status int, fault, body string) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Retry-After", "1")
+	header.Set(FaultHeader, fault)
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
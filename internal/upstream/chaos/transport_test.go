@@ -0,0 +1,67 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+	"echoflow/internal/upstream/openai"
+)
+
+func newChaosClient(t *testing.T, cfg Config) *openai.Client {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"cleaned transcript"}}]}`)
+	}))
+	t.Cleanup(ts.Close)
+	return openai.New(ts.URL, "k", &http.Client{Transport: NewTransport(ts.Client().Transport, cfg)})
+}
+
+func chat(c *openai.Client, ctx context.Context) error {
+	_, err := c.ChatCompletion(ctx, upstream.ChatCompletionRequest{Model: "m"})
+	return err
+}
+
+func TestTransportInjectsRateLimit(t *testing.T) {
+	err := chat(newChaosClient(t, Config{RateLimitRate: 1}), context.Background())
+	var upErr *upstream.Error
+	if !errors.As(err, &upErr) || upErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected injected 429, got %v", err)
+	}
+}
+
+func TestTransportTruncatesBody(t *testing.T) {
+	err := chat(newChaosClient(t, Config{TruncateRate: 1}), context.Background())
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestTransportCorruptsJSON(t *testing.T) {
+	err := chat(newChaosClient(t, Config{MalformedJSONRate: 1}), context.Background())
+	if err == nil || !strings.Contains(err.Error(), "invalid chat completion response") {
+		t.Fatalf("expected JSON decode error, got %v", err)
+	}
+}
+
+func TestTransportInjectsLatencyAndHonorsDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := chat(newChaosClient(t, Config{Latency: time.Second, LatencyRate: 1}), ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestTransportPassesThroughWhenRatesAreZero(t *testing.T) {
+	if err := chat(newChaosClient(t, Config{}), context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
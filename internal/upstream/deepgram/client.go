@@ -0,0 +1,204 @@
+// Package deepgram transcribes audio with Deepgram's pre-recorded /listen API.
+package deepgram
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+const DefaultBaseURL = "https://api.deepgram.com/v1"
+
+type ObserverFunc func(endpoint string, status int, duration time.Duration)
+
+type Option func(*Client)
+
+// Client implements upstream.Transcriber and upstream.HealthChecker. It
+// always uses its own key: callers' BYOT tokens belong to the primary vendor.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	observer   ObserverFunc
+}
+
+func WithObserver(observer ObserverFunc) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
+}
+
+func New(baseURL, apiKey string, httpClient *http.Client, opts ...Option) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultBaseURL
+	}
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     strings.TrimSpace(apiKey),
+		httpClient: httpClient,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+// Transcribe sends the audio as the raw request body. A verbose_json
+// response format asks for utterances, which become segments, and for
+// language detection unless the request names the language.
+func (c *Client) Transcribe(ctx context.Context, reqPayload upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe("deepgram_listen", statusCode, time.Since(started)) }()
+
+	if c.apiKey == "" {
+		return upstream.TranscriptionResponse{}, upstream.ErrMissingAPIKey
+	}
+	if reqPayload.Translate {
+		return upstream.TranscriptionResponse{}, upstream.ErrTranslationUnsupported
+	}
+	query := url.Values{"smart_format": {"true"}, "punctuate": {"true"}}
+	if reqPayload.Model != "" {
+		query.Set("model", reqPayload.Model)
+	}
+	if reqPayload.ResponseFormat == "verbose_json" {
+		query.Set("utterances", "true")
+	}
+	if reqPayload.Language != "" {
+		query.Set("language", reqPayload.Language)
+	} else if reqPayload.ResponseFormat == "verbose_json" {
+		query.Set("detect_language", "true")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/listen?"+query.Encode(), reqPayload.File)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	req.Header.Set("Authorization", "Token "+c.apiKey)
+	req.Header.Set("Content-Type", contentType(reqPayload.FileName))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return upstream.TranscriptionResponse{}, &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+	}
+	return parseListen(body, reqPayload.WordTimestamps)
+}
+
+// CheckHealth lists the projects visible to the key, which fails fast on a
+// revoked or mistyped key.
+func (c *Client) CheckHealth(ctx context.Context) error {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe("deepgram_projects", statusCode, time.Since(started)) }()
+
+	if c.apiKey == "" {
+		return upstream.ErrMissingAPIKey
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/projects", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+c.apiKey)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+	}
+	return nil
+}
+
+// parseListen reads a /listen response. Deepgram always returns word
+// timings; they are kept only when words is set.
+func parseListen(data []byte, words bool) (upstream.TranscriptionResponse, error) {
+	var parsed struct {
+		Results struct {
+			Channels []struct {
+				DetectedLanguage string `json:"detected_language"`
+				Alternatives     []struct {
+					Transcript string `json:"transcript"`
+					Words      []struct {
+						Word           string   `json:"word"`
+						PunctuatedWord string   `json:"punctuated_word"`
+						Start          float64  `json:"start"`
+						End            float64  `json:"end"`
+						Confidence     *float64 `json:"confidence"`
+					} `json:"words"`
+				} `json:"alternatives"`
+			} `json:"channels"`
+			Utterances []struct {
+				Start      float64  `json:"start"`
+				End        float64  `json:"end"`
+				Transcript string   `json:"transcript"`
+				Confidence *float64 `json:"confidence"`
+			} `json:"utterances"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return upstream.TranscriptionResponse{}, fmt.Errorf("invalid deepgram response: %w", err)
+	}
+	if len(parsed.Results.Channels) == 0 || len(parsed.Results.Channels[0].Alternatives) == 0 {
+		return upstream.TranscriptionResponse{}, fmt.Errorf("missing results.channels[0].alternatives")
+	}
+
+	channel := parsed.Results.Channels[0]
+	resp := upstream.TranscriptionResponse{Text: channel.Alternatives[0].Transcript, Language: channel.DetectedLanguage}
+	for _, u := range parsed.Results.Utterances {
+		resp.Segments = append(resp.Segments, upstream.TranscriptionSegment{Start: u.Start, End: u.End, Text: u.Transcript, Confidence: u.Confidence})
+	}
+	if words {
+		for _, w := range channel.Alternatives[0].Words {
+			resp.Words = append(resp.Words, upstream.TranscriptionWord{Start: w.Start, End: w.End, Word: cmp.Or(w.PunctuatedWord, w.Word), Confidence: w.Confidence})
+		}
+	}
+	return resp, nil
+}
+
+func contentType(fileName string) string {
+	if ct := mime.TypeByExtension(strings.ToLower(path.Ext(fileName))); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func (c *Client) observe(endpoint string, status int, duration time.Duration) {
+	if c.observer != nil {
+		c.observer(endpoint, status, duration)
+	}
+}
+
+func truncateBody(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= 4096 {
+		return s
+	}
+	return s[:4096] + "..."
+}
@@ -0,0 +1,71 @@
+package deepgram
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+func TestTranscribeSendsRawAudioAndParsesUtterances(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/listen" || r.Header.Get("Authorization") != "Token dg-key" {
+			t.Errorf("unexpected request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if q := r.URL.Query(); q.Get("model") != "nova-2" || q.Get("utterances") != "true" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "audio/") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if body, _ := io.ReadAll(r.Body); string(body) != "wav-bytes" {
+			t.Errorf("body = %q", body)
+		}
+		_, _ = w.Write([]byte(`{"results":{"channels":[{"alternatives":[{"transcript":"hello there general"}]}],
+			"utterances":[{"start":0.5,"end":1.25,"transcript":"hello there","confidence":0.93},{"start":2,"end":2.5,"transcript":"general"}]}}`))
+	}))
+	defer ts.Close()
+
+	var observed string
+	c := New(ts.URL+"/v1", "dg-key", ts.Client(), WithObserver(func(endpoint string, status int, _ time.Duration) {
+		observed = endpoint
+	}))
+	resp, err := c.Transcribe(upstream.WithRequestAPIKey(context.Background(), "caller-groq-key"), upstream.TranscriptionRequest{
+		File:           strings.NewReader("wav-bytes"),
+		FileName:       "call.wav",
+		Model:          "nova-2",
+		ResponseFormat: "verbose_json",
+	})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if resp.Text != "hello there general" || len(resp.Segments) != 2 || resp.Segments[0].End != 1.25 || resp.Segments[1].Text != "general" ||
+		resp.Segments[0].Confidence == nil || *resp.Segments[0].Confidence != 0.93 || resp.Segments[1].Confidence != nil {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if observed != "deepgram_listen" {
+		t.Fatalf("observed endpoint %q", observed)
+	}
+}
+
+func TestTranscribeReturnsUpstreamError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"err_code":"INVALID_AUTH"}`, http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	_, err := New(ts.URL, "bad", ts.Client()).Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("x")})
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("err = %v", err)
+	}
+	if err := New(ts.URL, "", ts.Client()).CheckHealth(context.Background()); !errors.Is(err, upstream.ErrMissingAPIKey) {
+		t.Fatalf("CheckHealth without key = %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+// Package failover tries an ordered list of equivalent upstreams, moving on
+// to the next one when an upstream is down rather than when it rejects the
+// request.
+package failover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"time"
+
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+// Upstream is one entry in the failover order. Either capability may be nil,
+// in which case the upstream is skipped for that kind of request.
+type Upstream struct {
+	Name          string
+	Transcriber   upstream.Transcriber
+	ChatCompleter upstream.ChatCompleter
+	// ServerKey marks an upstream that calls with the operator's own key. It
+	// is skipped for requests paid with the caller's key.
+	ServerKey bool
+}
+
+// ObserverFunc is called each time a request moves from one upstream to the
+// next.
+type ObserverFunc func(from, to string)
+
+type Option func(*Client)
+
+func WithObserver(observer ObserverFunc) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
+}
+
+// WithAttemptTimeout bounds every attempt except the last, so a hung
+// upstream leaves time for the next one. Zero leaves attempts bounded only by
+// the request context.
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.attemptTimeout = timeout
+	}
+}
+
+// Client implements upstream.Transcriber and upstream.ChatCompleter over the
+// configured upstreams.
+type Client struct {
+	transcribers []Upstream
+	completers   []Upstream
+
+	observer       ObserverFunc
+	attemptTimeout time.Duration
+}
+
+func New(upstreams []Upstream, opts ...Option) *Client {
+	c := &Client{}
+	for _, u := range upstreams {
+		if u.Transcriber != nil {
+			c.transcribers = append(c.transcribers, u)
+		}
+		if u.ChatCompleter != nil {
+			c.completers = append(c.completers, u)
+		}
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+func (c *Client) Transcribe(ctx context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	if len(c.transcribers) == 1 {
+		return c.transcribers[0].Transcriber.Transcribe(ctx, req)
+	}
+	// Every attempt needs the full audio, so it is buffered once up front.
+	data, err := io.ReadAll(req.File)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	return attempt(ctx, c, c.transcribers, func(ctx context.Context, u Upstream) (upstream.TranscriptionResponse, bool, error) {
+		areq := req
+		areq.File = bytes.NewReader(data)
+		resp, err := u.Transcriber.Transcribe(ctx, areq)
+		return resp, true, err
+	})
+}
+
+func (c *Client) ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	return attempt(ctx, c, c.completers, func(ctx context.Context, u Upstream) (upstream.ChatCompletionResponse, bool, error) {
+		resp, err := u.ChatCompleter.ChatCompletion(ctx, req)
+		return resp, true, err
+	})
+}
+
+// StreamChatCompletion only fails over before the first delta; once content
+// has reached the caller the stream cannot be restarted elsewhere.
+func (c *Client) StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	return attempt(ctx, c, c.completers, func(ctx context.Context, u Upstream) (upstream.ChatCompletionResponse, bool, error) {
+		streamed := false
+		resp, err := u.ChatCompleter.StreamChatCompletion(ctx, req, func(delta string) error {
+			streamed = true
+			return onDelta(delta)
+		})
+		return resp, !streamed, err
+	})
+}
+
+// attempt calls try on each upstream in order until one succeeds or fails
+// with an error that another upstream would not fix. try reports whether
+// its failure may still be retried elsewhere.
+func attempt[T any](ctx context.Context, c *Client, upstreams []Upstream, try func(context.Context, Upstream) (T, bool, error)) (T, error) {
+	var zero T
+	if len(upstreams) == 0 {
+		return zero, errors.New("failover: no upstream configured")
+	}
+	// A caller's own endpoint has no failover: their audio goes nowhere else.
+	if upstream.RequestBaseURLFromContext(ctx) != "" {
+		upstreams = upstreams[:1]
+	}
+	if !upstream.ServerPays(ctx) {
+		upstreams = slices.DeleteFunc(slices.Clone(upstreams), func(u Upstream) bool { return u.ServerKey })
+		if len(upstreams) == 0 {
+			return zero, upstream.ErrServerKeyOnly
+		}
+	}
+	for i := 0; ; i++ {
+		last := i == len(upstreams)-1
+		actx, cancel := ctx, func() {}
+		if c.attemptTimeout > 0 && !last {
+			actx, cancel = context.WithTimeout(ctx, c.attemptTimeout)
+		}
+		resp, canRetry, err := try(actx, upstreams[i])
+		cancel()
+		if err == nil || last || !canRetry || ctx.Err() != nil || !unavailable(err) {
+			return resp, err
+		}
+		reqctx.Logger(ctx).Warn("upstream failover", "from", upstreams[i].Name, "to", upstreams[i+1].Name, "error", err)
+		if c.observer != nil {
+			c.observer(upstreams[i].Name, upstreams[i+1].Name)
+		}
+	}
+}
+
+// unavailable reports whether err means the upstream is unavailable: a 5xx
+// response, a timeout or a transport failure. Client errors such as 4xx or a
+// missing API key would fail the same way everywhere.
+func unavailable(err error) bool {
+	var upstreamErr *upstream.Error
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,132 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+type fakeUpstream struct {
+	err   error
+	delay time.Duration
+	text  string
+	calls int
+	body  string
+}
+
+func (f *fakeUpstream) Transcribe(ctx context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	f.calls++
+	body, _ := io.ReadAll(req.File)
+	f.body = string(body)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return upstream.TranscriptionResponse{}, ctx.Err()
+	}
+	if f.err != nil {
+		return upstream.TranscriptionResponse{}, f.err
+	}
+	return upstream.TranscriptionResponse{Text: f.text}, nil
+}
+
+func (f *fakeUpstream) ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	f.calls++
+	return upstream.ChatCompletionResponse{Content: f.text}, f.err
+}
+
+func (f *fakeUpstream) StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	f.calls++
+	if f.text != "" {
+		if err := onDelta(f.text); err != nil {
+			return upstream.ChatCompletionResponse{}, err
+		}
+	}
+	return upstream.ChatCompletionResponse{Content: f.text}, f.err
+}
+
+type hops []string
+
+func (h *hops) observe(from, to string) { *h = append(*h, from+">"+to) }
+
+func TestTranscribeFailsOverOn5xxWithFullAudio(t *testing.T) {
+	primary := &fakeUpstream{err: &upstream.Error{StatusCode: 503}}
+	secondary := &fakeUpstream{text: "hello"}
+	var h hops
+	c := New([]Upstream{{Name: "groq", Transcriber: primary}, {Name: "backup", Transcriber: secondary}}, WithObserver(h.observe))
+
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio")})
+	if err != nil || resp.Text != "hello" {
+		t.Fatalf("Transcribe = %q, %v", resp.Text, err)
+	}
+	if primary.body != "audio" || secondary.body != "audio" {
+		t.Fatalf("bodies = %q %q", primary.body, secondary.body)
+	}
+	if len(h) != 1 || h[0] != "groq>backup" {
+		t.Fatalf("failovers = %v", h)
+	}
+}
+
+func TestClientErrorsDoNotFailOver(t *testing.T) {
+	primary := &fakeUpstream{err: &upstream.Error{StatusCode: 400}}
+	secondary := &fakeUpstream{text: "hello"}
+	c := New([]Upstream{{Name: "a", ChatCompleter: primary}, {Name: "b", ChatCompleter: secondary}})
+
+	_, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{})
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != 400 || secondary.calls != 0 {
+		t.Fatalf("err = %v, secondary calls = %d", err, secondary.calls)
+	}
+}
+
+func TestCallerKeyedRequestsSkipServerKeyedUpstreams(t *testing.T) {
+	primary := &fakeUpstream{err: &upstream.Error{StatusCode: 503}}
+	backup := &fakeUpstream{text: "hello"}
+	c := New([]Upstream{{Name: "a", ChatCompleter: primary}, {Name: "b", ChatCompleter: backup, ServerKey: true}})
+
+	ctx := reqctx.WithAPIKeySource(context.Background(), reqctx.KeySourceCaller)
+	_, err := c.ChatCompletion(ctx, upstream.ChatCompletionRequest{})
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || backup.calls != 0 {
+		t.Fatalf("err = %v, backup calls = %d", err, backup.calls)
+	}
+
+	ctx = reqctx.WithAPIKeySource(context.Background(), reqctx.KeySourceServer)
+	resp, err := c.ChatCompletion(ctx, upstream.ChatCompletionRequest{})
+	if err != nil || resp.Content != "hello" {
+		t.Fatalf("ChatCompletion = %q, %v", resp.Content, err)
+	}
+}
+
+func TestAttemptTimeoutMovesToNextUpstream(t *testing.T) {
+	primary := &fakeUpstream{delay: time.Second}
+	secondary := &fakeUpstream{text: "fast"}
+	c := New([]Upstream{{Name: "a", Transcriber: primary}, {Name: "b", Transcriber: secondary}}, WithAttemptTimeout(20*time.Millisecond))
+
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio")})
+	if err != nil || resp.Text != "fast" {
+		t.Fatalf("Transcribe = %q, %v", resp.Text, err)
+	}
+}
+
+func TestStreamDoesNotFailOverAfterFirstDelta(t *testing.T) {
+	primary := &fakeUpstream{text: "partial", err: &upstream.Error{StatusCode: 502}}
+	secondary := &fakeUpstream{text: "full"}
+	c := New([]Upstream{{Name: "a", ChatCompleter: primary}, {Name: "b", ChatCompleter: secondary}})
+
+	_, err := c.StreamChatCompletion(context.Background(), upstream.ChatCompletionRequest{}, func(string) error { return nil })
+	if err == nil || secondary.calls != 0 {
+		t.Fatalf("err = %v, secondary calls = %d", err, secondary.calls)
+	}
+
+	primary.text = ""
+	resp, err := c.StreamChatCompletion(context.Background(), upstream.ChatCompletionRequest{}, func(string) error { return nil })
+	if err != nil || resp.Content != "full" {
+		t.Fatalf("stream before first delta = %q, %v", resp.Content, err)
+	}
+}
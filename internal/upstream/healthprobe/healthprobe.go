@@ -0,0 +1,165 @@
+// Package healthprobe checks the upstream in the background and caches the
+// outcome, so readiness probes answer without calling the upstream
+// themselves.
+package healthprobe
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+type ChatClient interface {
+	ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error)
+}
+
+type ObserverFunc func(name string, latency time.Duration, healthy bool)
+
+type Config struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// UseKey lists models with the server-side key. Without it the prober
+	// only pings, which shows the upstream is reachable.
+	UseKey bool
+	// ChatModel, when set, also sends a one-token chat completion to that
+	// model on every probe.
+	ChatModel string
+}
+
+type Option func(*Prober)
+
+func WithObserver(observer ObserverFunc) Option {
+	return func(p *Prober) {
+		p.observer = observer
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Prober) {
+		if logger != nil {
+			p.logger = logger
+		}
+	}
+}
+
+// WithChat sets the client for Config.ChatModel probes.
+func WithChat(chat ChatClient) Option {
+	return func(p *Prober) {
+		p.chat = chat
+	}
+}
+
+type Prober struct {
+	name     string
+	checker  upstream.HealthChecker
+	chat     ChatClient
+	cfg      Config
+	observer ObserverFunc
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	checked bool
+	err     error
+}
+
+var errNoProbe = errors.New("healthprobe: upstream has no ping and no key to check with")
+
+// New probes checker, reported under name.
+func New(name string, checker upstream.HealthChecker, cfg Config, opts ...Option) *Prober {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	p := &Prober{name: name, checker: checker, cfg: cfg, logger: slog.Default()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+	return p
+}
+
+// Run probes immediately and then every Interval until ctx is cancelled. It
+// is a no-op when Interval is not positive.
+func (p *Prober) Run(ctx context.Context) {
+	if p.cfg.Interval <= 0 {
+		return
+	}
+	p.probe(ctx)
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.probe(ctx)
+		}
+	}
+}
+
+// LastCheck returns the outcome of the latest probe; checked is false until
+// the first one has finished.
+func (p *Prober) LastCheck() (checked bool, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.checked, p.err
+}
+
+func (p *Prober) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := p.check(ctx)
+	latency := time.Since(started)
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		// Shutting down; keep the last real outcome.
+		return
+	}
+
+	p.mu.Lock()
+	wasFailing := p.checked && p.err != nil
+	p.checked, p.err = true, err
+	p.mu.Unlock()
+
+	switch {
+	case err != nil && !wasFailing:
+		p.logger.Warn("upstream health probe failed", "upstream", p.name, "error", err)
+	case err == nil && wasFailing:
+		p.logger.Info("upstream health probe recovered", "upstream", p.name)
+	}
+	if p.observer != nil {
+		p.observer(p.name, latency, err == nil)
+	}
+}
+
+func (p *Prober) check(ctx context.Context) error {
+	if p.cfg.UseKey {
+		if err := p.checker.CheckHealth(ctx); err != nil {
+			return err
+		}
+	} else {
+		// Without a server-side key to list models with, a ping is all
+		// that can be checked.
+		pinger, ok := p.checker.(upstream.Pinger)
+		if !ok {
+			return errNoProbe
+		}
+		if err := pinger.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	if p.cfg.ChatModel == "" || p.chat == nil {
+		return nil
+	}
+	_, err := p.chat.ChatCompletion(ctx, upstream.ChatCompletionRequest{
+		Model:     p.cfg.ChatModel,
+		Messages:  []upstream.ChatMessage{{Role: "user", Content: "ping"}},
+		MaxTokens: 1,
+	})
+	return err
+}
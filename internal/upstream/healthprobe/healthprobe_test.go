@@ -0,0 +1,86 @@
+package healthprobe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+type fakeUpstream struct {
+	checkErr error
+	pingErr  error
+	checks   int
+	pings    int
+}
+
+func (f *fakeUpstream) CheckHealth(context.Context) error {
+	f.checks++
+	return f.checkErr
+}
+
+func (f *fakeUpstream) Ping(context.Context) error {
+	f.pings++
+	return f.pingErr
+}
+
+type fakeChat struct {
+	err      error
+	requests []upstream.ChatCompletionRequest
+}
+
+func (c *fakeChat) ChatCompletion(_ context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	c.requests = append(c.requests, req)
+	return upstream.ChatCompletionResponse{}, c.err
+}
+
+func newTestProber(up upstream.HealthChecker, cfg Config, opts ...Option) *Prober {
+	return New("openai", up, cfg, append(opts, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))...)
+}
+
+func TestProbeCachesTheLatestOutcome(t *testing.T) {
+	up := &fakeUpstream{}
+	var observed []bool
+	p := newTestProber(up, Config{UseKey: true}, WithObserver(func(name string, _ time.Duration, healthy bool) {
+		if name != "openai" {
+			t.Errorf("observed name = %q", name)
+		}
+		observed = append(observed, healthy)
+	}))
+
+	if checked, _ := p.LastCheck(); checked {
+		t.Fatal("checked before the first probe")
+	}
+	p.probe(context.Background())
+	if checked, err := p.LastCheck(); !checked || err != nil {
+		t.Fatalf("after healthy probe: %v %v", checked, err)
+	}
+	up.checkErr = &upstream.Error{StatusCode: 401}
+	p.probe(context.Background())
+	if _, err := p.LastCheck(); err == nil {
+		t.Fatal("failed probe not cached")
+	}
+	if up.checks != 2 || up.pings != 0 || len(observed) != 2 || !observed[0] || observed[1] {
+		t.Fatalf("checks %d, pings %d, observed %v", up.checks, up.pings, observed)
+	}
+}
+
+func TestProbePingsWithoutKeyAndSendsChatProbe(t *testing.T) {
+	up := &fakeUpstream{}
+	p := newTestProber(up, Config{}, WithChat(&fakeChat{}))
+	p.probe(context.Background())
+	if _, err := p.LastCheck(); err != nil || up.pings != 1 || up.checks != 0 {
+		t.Fatalf("keyless probe: err %v, pings %d, checks %d", err, up.pings, up.checks)
+	}
+
+	chat := &fakeChat{err: errors.New("model overloaded")}
+	p = newTestProber(&fakeUpstream{}, Config{UseKey: true, ChatModel: "tiny"}, WithChat(chat))
+	p.probe(context.Background())
+	if _, err := p.LastCheck(); err == nil || len(chat.requests) != 1 || chat.requests[0].Model != "tiny" || chat.requests[0].MaxTokens != 1 {
+		t.Fatalf("chat probe: err %v, requests %+v", err, chat.requests)
+	}
+}
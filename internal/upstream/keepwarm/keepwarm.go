@@ -0,0 +1,109 @@
+// Package keepwarm keeps upstream connections alive between requests so the
+// first dictation after an idle period does not pay for a fresh TLS and HTTP/2
+// handshake.
+package keepwarm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+type ChatClient interface {
+	ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error)
+}
+
+type Config struct {
+	// Interval between pings. It should be shorter than the transport's idle
+	// connection timeout.
+	Interval time.Duration
+	Timeout  time.Duration
+	// WarmupModel, when set, also sends a one-token chat completion to that
+	// model every WarmupInterval to keep it resident on the provider side.
+	WarmupModel    string
+	WarmupInterval time.Duration
+}
+
+type Keeper struct {
+	pinger     upstream.Pinger
+	chat       ChatClient
+	cfg        Config
+	logger     *slog.Logger
+	now        func() time.Time
+	lastWarmup time.Time
+	failing    bool
+}
+
+func New(pinger upstream.Pinger, chat ChatClient, cfg Config, logger *slog.Logger) *Keeper {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.WarmupInterval <= 0 {
+		cfg.WarmupInterval = cfg.Interval
+	}
+	return &Keeper{
+		pinger: pinger,
+		chat:   chat,
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Run pings immediately and then every Interval until ctx is cancelled. It is
+// a no-op when Interval is not positive.
+func (k *Keeper) Run(ctx context.Context) {
+	if k.cfg.Interval <= 0 {
+		return
+	}
+	k.tick(ctx)
+	ticker := time.NewTicker(k.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			k.tick(ctx)
+		}
+	}
+}
+
+func (k *Keeper) tick(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, k.cfg.Timeout)
+	defer cancel()
+
+	err := k.pinger.Ping(ctx)
+	switch {
+	case err != nil && !k.failing:
+		k.logger.Warn("keep-warm ping failed", "error", err)
+	case err == nil && k.failing:
+		k.logger.Info("keep-warm ping recovered")
+	}
+	k.failing = err != nil
+	if err != nil {
+		return
+	}
+
+	if k.cfg.WarmupModel == "" || k.chat == nil {
+		return
+	}
+	now := k.now()
+	if !k.lastWarmup.IsZero() && now.Sub(k.lastWarmup) < k.cfg.WarmupInterval {
+		return
+	}
+	k.lastWarmup = now
+	_, err = k.chat.ChatCompletion(ctx, upstream.ChatCompletionRequest{
+		Model:     k.cfg.WarmupModel,
+		Messages:  []upstream.ChatMessage{{Role: "user", Content: "ping"}},
+		MaxTokens: 1,
+	})
+	if err != nil {
+		k.logger.Debug("keep-warm model warm-up failed", "model", k.cfg.WarmupModel, "error", err)
+	}
+}
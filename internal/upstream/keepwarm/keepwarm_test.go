@@ -0,0 +1,86 @@
+package keepwarm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+type fakePinger struct {
+	calls int
+	err   error
+}
+
+func (p *fakePinger) Ping(context.Context) error {
+	p.calls++
+	return p.err
+}
+
+type fakeChat struct {
+	requests []upstream.ChatCompletionRequest
+}
+
+func (c *fakeChat) ChatCompletion(_ context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	c.requests = append(c.requests, req)
+	return upstream.ChatCompletionResponse{}, nil
+}
+
+func newTestKeeper(p upstream.Pinger, c ChatClient, cfg Config) (*Keeper, *time.Time) {
+	k := New(p, c, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Unix(0, 0)
+	k.now = func() time.Time { return now }
+	return k, &now
+}
+
+func TestTickWarmsModelAtWarmupInterval(t *testing.T) {
+	pinger := &fakePinger{}
+	chat := &fakeChat{}
+	k, now := newTestKeeper(pinger, chat, Config{Interval: 30 * time.Second, WarmupModel: "llama", WarmupInterval: time.Minute})
+
+	k.tick(context.Background())
+	*now = now.Add(30 * time.Second)
+	k.tick(context.Background())
+	*now = now.Add(30 * time.Second)
+	k.tick(context.Background())
+
+	if pinger.calls != 3 {
+		t.Fatalf("expected 3 pings, got %d", pinger.calls)
+	}
+	if len(chat.requests) != 2 {
+		t.Fatalf("expected 2 warm-ups, got %d", len(chat.requests))
+	}
+	if req := chat.requests[0]; req.Model != "llama" || req.MaxTokens != 1 {
+		t.Fatalf("unexpected warm-up request: %+v", req)
+	}
+}
+
+func TestTickSkipsWarmupWhenPingFails(t *testing.T) {
+	pinger := &fakePinger{err: errors.New("dial tcp: connection refused")}
+	chat := &fakeChat{}
+	k, _ := newTestKeeper(pinger, chat, Config{Interval: time.Second, WarmupModel: "llama"})
+
+	k.tick(context.Background())
+
+	if len(chat.requests) != 0 {
+		t.Fatalf("expected no warm-up after failed ping, got %d", len(chat.requests))
+	}
+	if !k.failing {
+		t.Fatal("expected keeper to record the failure")
+	}
+}
+
+func TestRunDisabledWithoutInterval(t *testing.T) {
+	pinger := &fakePinger{}
+	k, _ := newTestKeeper(pinger, nil, Config{})
+
+	k.Run(context.Background())
+
+	if pinger.calls != 0 {
+		t.Fatalf("expected no pings, got %d", pinger.calls)
+	}
+}
@@ -0,0 +1,101 @@
+// Package limit caps how many upstream calls run at once. Callers over the
+// cap wait in line until a call finishes or their deadline passes, instead of
+// opening another connection and tripping the provider's rate limits.
+package limit
+
+import (
+	"context"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+// ObserverFunc is told how long each call waited for a slot.
+type ObserverFunc func(endpoint string, wait time.Duration)
+
+// Limiter is a counting semaphore for one endpoint.
+type Limiter struct {
+	endpoint string
+	slots    chan struct{}
+	observer ObserverFunc
+}
+
+// New allows n concurrent calls; n <= 0 returns nil, which never limits.
+func New(endpoint string, n int, observer ObserverFunc) *Limiter {
+	if n <= 0 {
+		return nil
+	}
+	return &Limiter{endpoint: endpoint, slots: make(chan struct{}, n), observer: observer}
+}
+
+// Acquire waits for a slot and returns the func that frees it, or ctx's
+// error if ctx ends first.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	started := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if l.observer != nil {
+		l.observer(l.endpoint, time.Since(started))
+	}
+	return func() { <-l.slots }, nil
+}
+
+// Transcriber limits calls to next; a nil l returns next unchanged.
+func Transcriber(next upstream.Transcriber, l *Limiter) upstream.Transcriber {
+	if l == nil {
+		return next
+	}
+	return transcriber{next: next, limiter: l}
+}
+
+// ChatCompleter limits calls to next; a nil l returns next unchanged. A
+// stream holds its slot until it ends.
+func ChatCompleter(next upstream.ChatCompleter, l *Limiter) upstream.ChatCompleter {
+	if l == nil {
+		return next
+	}
+	return completer{next: next, limiter: l}
+}
+
+type transcriber struct {
+	next    upstream.Transcriber
+	limiter *Limiter
+}
+
+func (t transcriber) Transcribe(ctx context.Context, req upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	release, err := t.limiter.Acquire(ctx)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	defer release()
+	return t.next.Transcribe(ctx, req)
+}
+
+type completer struct {
+	next    upstream.ChatCompleter
+	limiter *Limiter
+}
+
+func (c completer) ChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
+	release, err := c.limiter.Acquire(ctx)
+	if err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	defer release()
+	return c.next.ChatCompletion(ctx, req)
+}
+
+func (c completer) StreamChatCompletion(ctx context.Context, req upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	release, err := c.limiter.Acquire(ctx)
+	if err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	defer release()
+	return c.next.StreamChatCompletion(ctx, req, onDelta)
+}
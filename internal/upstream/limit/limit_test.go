@@ -0,0 +1,77 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+type slowTranscriber struct {
+	running, peak atomic.Int32
+}
+
+func (s *slowTranscriber) Transcribe(ctx context.Context, _ upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	n := s.running.Add(1)
+	defer s.running.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return upstream.TranscriptionResponse{Text: "ok"}, nil
+}
+
+func TestTranscriberCapsConcurrentCalls(t *testing.T) {
+	next := &slowTranscriber{}
+	var waits atomic.Int32
+	limited := Transcriber(next, New("audio_transcriptions", 2, func(endpoint string, _ time.Duration) {
+		if endpoint == "audio_transcriptions" {
+			waits.Add(1)
+		}
+	}))
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Go(func() {
+			if _, err := limited.Transcribe(context.Background(), upstream.TranscriptionRequest{}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if peak := next.peak.Load(); peak != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", peak)
+	}
+	if waits.Load() != 6 {
+		t.Fatalf("observed %d waits, want 6", waits.Load())
+	}
+}
+
+func TestAcquireGivesUpWhenContextEnds(t *testing.T) {
+	l := New("chat_completions", 1, nil)
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestZeroLimitLeavesClientUnwrapped(t *testing.T) {
+	next := &slowTranscriber{}
+	if got := Transcriber(next, New("audio_transcriptions", 0, nil)); got != upstream.Transcriber(next) {
+		t.Fatalf("Transcriber wrapped an unlimited client")
+	}
+}
@@ -0,0 +1,55 @@
+package upstream
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// MaxModelLength bounds model names accepted from clients.
+const MaxModelLength = 128
+
+// modelPattern admits names like whisper-large-v3, gpt-4o-mini-2024-07-18,
+// meta-llama/llama-4-scout:free and nova-2@latest. Anything else, including
+// whitespace and control characters, never reaches a header, path or query.
+var modelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:@+/-]*$`)
+
+// ModelError reports a client-supplied model name that is malformed or
+// outside the configured allowlist.
+type ModelError struct {
+	Model  string
+	Reason string
+}
+
+func (e *ModelError) Error() string {
+	return fmt.Sprintf("model %q %s", e.Model, e.Reason)
+}
+
+// CheckModel validates a model name before it is sent upstream. An empty
+// model, meaning the configured default, is always accepted. allowed holds
+// path.Match patterns; an empty list allows any well-formed name.
+func CheckModel(model string, allowed []string) error {
+	if model == "" {
+		return nil
+	}
+	shown := model
+	if len(shown) > MaxModelLength {
+		shown = shown[:MaxModelLength] + "..."
+	}
+	switch {
+	case len(model) > MaxModelLength:
+		return &ModelError{Model: shown, Reason: fmt.Sprintf("is longer than %d characters", MaxModelLength)}
+	case !modelPattern.MatchString(model) || strings.Contains(model, ".."):
+		return &ModelError{Model: shown, Reason: "may only contain letters, digits and . _ : @ + / -"}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, pattern := range allowed {
+		if ok, _ := path.Match(pattern, model); ok {
+			return nil
+		}
+	}
+	return &ModelError{Model: model, Reason: "is not in the model allowlist"}
+}
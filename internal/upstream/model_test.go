@@ -0,0 +1,32 @@
+package upstream
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckModel(t *testing.T) {
+	tests := []struct {
+		model   string
+		allowed []string
+		ok      bool
+	}{
+		{model: "", allowed: []string{"whisper-*"}, ok: true},
+		{model: "whisper-large-v3", ok: true},
+		{model: "meta-llama/llama-4-scout-17b-16e-instruct", ok: true},
+		{model: "gpt-4o-mini\r\nX-Injected: 1"},
+		{model: "../../admin"},
+		{model: "whisper large"},
+		{model: strings.Repeat("a", MaxModelLength+1)},
+		{model: "whisper-large-v3", allowed: []string{"whisper-*", "gpt-4o*"}, ok: true},
+		{model: "whsiper-large-v3", allowed: []string{"whisper-*"}},
+	}
+	for _, tt := range tests {
+		err := CheckModel(tt.model, tt.allowed)
+		var modelErr *ModelError
+		if tt.ok != (err == nil) || (err != nil && !errors.As(err, &modelErr)) {
+			t.Errorf("CheckModel(%q, %v) = %v", tt.model, tt.allowed, err)
+		}
+	}
+}
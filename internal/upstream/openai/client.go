@@ -1,16 +1,24 @@
 package openai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
+
+	"echoflow/internal/bufpool"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
 )
 
 type ObserverFunc func(endpoint string, status int, duration time.Duration)
@@ -19,49 +27,69 @@ type Option func(*Client)
 
 type Client struct {
 	baseURL    string
-	apiKey     string
 	httpClient *http.Client
 	observer   ObserverFunc
+	selector   BaseURLSelector
+	// retry is zero, meaning no retries, unless WithRetries is set.
+	retry         RetryPolicy
+	retryObserver RetryObserverFunc
+	// ignoreRequestKey makes the client always use apiKey, for upstreams
+	// where the caller's BYOT token is not valid.
+	ignoreRequestKey bool
+	tokenizeURL      string
+	tokenizeKey      string
+	// azureAPIVersion switches to Azure OpenAI conventions when set.
+	azureAPIVersion string
+
+	keyMu         sync.RWMutex
+	apiKey        string
+	previousKey   string
+	previousUntil time.Time
 }
 
-var ErrMissingAPIKey = errors.New("missing upstream API key")
-
-type apiKeyContextKey struct{}
-
-type Error struct {
-	StatusCode int
-	Body       string
+// BaseURLSelector picks the upstream base URL for each request, e.g. the
+// fastest healthy region.
+type BaseURLSelector interface {
+	BaseURL() string
 }
 
-func (e *Error) Error() string {
-	return fmt.Sprintf("upstream request failed with status %d", e.StatusCode)
+// chatRequest is the wire form of a chat completion request.
+type chatRequest struct {
+	upstream.ChatCompletionRequest
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
 }
 
-type TokenUsage struct {
-	PromptTokens     int
-	CompletionTokens int
-	TotalTokens      int
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
-type ChatMessage struct {
-	Role    string `json:"role"`
-	Content any    `json:"content"`
+func WithObserver(observer ObserverFunc) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
 }
 
-type ChatCompletionRequest struct {
-	Model       string        `json:"model"`
-	Temperature float64       `json:"temperature"`
-	Messages    []ChatMessage `json:"messages"`
+func WithBaseURLSelector(selector BaseURLSelector) Option {
+	return func(c *Client) {
+		c.selector = selector
+	}
 }
 
-type ChatCompletionResponse struct {
-	Content string
-	Usage   *TokenUsage
+// WithoutRequestAPIKey ignores per-request keys set by WithRequestAPIKey.
+func WithoutRequestAPIKey() Option {
+	return func(c *Client) {
+		c.ignoreRequestKey = true
+	}
 }
 
-func WithObserver(observer ObserverFunc) Option {
+// WithAzure speaks Azure OpenAI: keys go in the api-key header, the model
+// name is used as the deployment name in /openai/deployments/{name}/... URLs,
+// and every request carries apiVersion. The base URL is the resource
+// endpoint, e.g. https://my-resource.openai.azure.com.
+func WithAzure(apiVersion string) Option {
 	return func(c *Client) {
-		c.observer = observer
+		c.azureAPIVersion = strings.TrimSpace(apiVersion)
 	}
 }
 
@@ -82,122 +110,119 @@ func New(baseURL, apiKey string, httpClient *http.Client, opts ...Option) *Clien
 	return c
 }
 
-func WithRequestAPIKey(ctx context.Context, apiKey string) context.Context {
-	apiKey = strings.TrimSpace(apiKey)
-	if apiKey == "" {
-		return ctx
-	}
-	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
-}
-
-func RequestAPIKeyFromContext(ctx context.Context) string {
-	if ctx == nil {
-		return ""
-	}
-	value, _ := ctx.Value(apiKeyContextKey{}).(string)
-	return strings.TrimSpace(value)
-}
-
-func (c *Client) Transcribe(ctx context.Context, file io.Reader, fileName, model string) (string, error) {
+func (c *Client) Transcribe(ctx context.Context, reqPayload upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
 	started := time.Now()
 	statusCode := 0
-	defer func() { c.observe("audio_transcriptions", statusCode, time.Since(started)) }()
-
-	var body bytes.Buffer
-	writer := multipart.NewWriter(&body)
-
-	if err := writer.WriteField("model", model); err != nil {
-		return "", err
-	}
-	part, err := writer.CreateFormFile("file", fileName)
-	if err != nil {
-		return "", err
-	}
-	if _, err := io.Copy(part, file); err != nil {
-		return "", err
-	}
-	if err := writer.Close(); err != nil {
-		return "", err
+	operation, path := "audio_transcriptions", "/audio/transcriptions"
+	if reqPayload.Translate {
+		operation, path = "audio_translations", "/audio/translations"
 	}
+	defer func() { c.observe(ctx, operation, statusCode, time.Since(started)) }()
 
-	url := c.baseURL + "/audio/transcriptions"
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body.Bytes()))
+	body, contentType, err := transcriptionBody(reqPayload)
 	if err != nil {
-		return "", err
-	}
-	if err := c.setAuthorizationHeader(ctx, req); err != nil {
-		return "", err
+		return upstream.TranscriptionResponse{}, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	shared := newSharedBody(body)
+	defer shared.release()
 
-	resp, err := c.httpClient.Do(req)
+	resp, tried, err := c.do(ctx, operation, func() (*http.Request, error) {
+		return c.newPostRequest(ctx, c.endpoint(ctx, path, reqPayload.Model), shared, contentType)
+	})
 	if err != nil {
-		return "", err
+		return upstream.TranscriptionResponse{}, tried.wrap(err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 	statusCode = resp.StatusCode
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := readBody(resp.Body)
 	if err != nil {
-		return "", err
+		return upstream.TranscriptionResponse{}, err
 	}
+	defer bufpool.Put(respBody)
 
 	if resp.StatusCode != http.StatusOK {
-		return "", &Error{StatusCode: resp.StatusCode, Body: truncateBody(string(respBody))}
+		return upstream.TranscriptionResponse{}, tried.wrap(&upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(respBody.String())})
 	}
 
-	return parseTranscript(respBody)
+	return parseTranscript(respBody.Bytes())
 }
 
-func (c *Client) ChatCompletion(ctx context.Context, reqPayload ChatCompletionRequest) (ChatCompletionResponse, error) {
+func (c *Client) ChatCompletion(ctx context.Context, reqPayload upstream.ChatCompletionRequest) (upstream.ChatCompletionResponse, error) {
 	started := time.Now()
 	statusCode := 0
-	defer func() { c.observe("chat_completions", statusCode, time.Since(started)) }()
+	defer func() { c.observe(ctx, "chat_completions", statusCode, time.Since(started)) }()
 
-	payload, err := json.Marshal(reqPayload)
+	resp, tried, err := c.sendChatRequest(ctx, chatRequest{ChatCompletionRequest: reqPayload}, "")
 	if err != nil {
-		return ChatCompletionResponse{}, err
+		return upstream.ChatCompletionResponse{}, tried.wrap(err)
 	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
 
-	url := c.baseURL + "/chat/completions"
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	respBody, err := readBody(resp.Body)
 	if err != nil {
-		return ChatCompletionResponse{}, err
+		return upstream.ChatCompletionResponse{}, err
 	}
-	if err := c.setAuthorizationHeader(ctx, req); err != nil {
-		return ChatCompletionResponse{}, err
+	defer bufpool.Put(respBody)
+
+	if resp.StatusCode != http.StatusOK {
+		return upstream.ChatCompletionResponse{}, tried.wrap(&upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(respBody.String())})
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	return parseChatCompletion(respBody.Bytes())
+}
+
+// StreamChatCompletion requests a streamed completion and calls onDelta for
+// each content fragment as it arrives. The returned response holds the full
+// content and, when the upstream reports it, token usage. An error from
+// onDelta aborts the stream and is returned as is.
+func (c *Client) StreamChatCompletion(ctx context.Context, reqPayload upstream.ChatCompletionRequest, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe(ctx, "chat_completions", statusCode, time.Since(started)) }()
+
+	resp, tried, err := c.sendChatRequest(ctx, chatRequest{
+		ChatCompletionRequest: reqPayload,
+		Stream:                true,
+		StreamOptions:         &streamOptions{IncludeUsage: true},
+	}, "text/event-stream")
 	if err != nil {
-		return ChatCompletionResponse{}, err
+		return upstream.ChatCompletionResponse{}, tried.wrap(err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 	statusCode = resp.StatusCode
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return ChatCompletionResponse{}, err
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return ChatCompletionResponse{}, &Error{StatusCode: resp.StatusCode, Body: truncateBody(string(respBody))}
+		body, _ := io.ReadAll(resp.Body)
+		return upstream.ChatCompletionResponse{}, tried.wrap(&upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))})
 	}
 
-	return parseChatCompletion(respBody)
+	return readChatCompletionStream(resp.Body, onDelta)
 }
 
-func (c *Client) CheckModels(ctx context.Context) error {
+// CheckHealth lists models with the current key.
+func (c *Client) CheckHealth(ctx context.Context) error {
+	apiKey, err := c.resolveAPIKey(ctx)
+	if err != nil {
+		return err
+	}
+	return c.checkModels(ctx, apiKey)
+}
+
+// Ping issues a lightweight request so the transport keeps a warm TLS/HTTP2
+// connection to the upstream. Any HTTP response counts as success, so it also
+// works without a server-side API key.
+func (c *Client) Ping(ctx context.Context) error {
 	started := time.Now()
 	statusCode := 0
-	defer func() { c.observe("models", statusCode, time.Since(started)) }()
+	defer func() { c.observe(ctx, "ping", statusCode, time.Since(started)) }()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.endpoint(ctx, "/models", ""), nil)
 	if err != nil {
 		return err
 	}
-	if err := c.setAuthorizationHeader(ctx, req); err != nil {
+	if err := c.setAuthorizationHeader(ctx, req); err != nil && !errors.Is(err, upstream.ErrMissingAPIKey) {
 		return err
 	}
 
@@ -205,50 +230,212 @@ func (c *Client) CheckModels(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	// Draining the body lets the connection return to the idle pool.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
 	statusCode = resp.StatusCode
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return &Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+// sendChatRequest encodes payload once and sends it, with retries.
+func (c *Client) sendChatRequest(ctx context.Context, payload chatRequest, accept string) (*http.Response, attempts, error) {
+	body := bufpool.Get()
+	if err := json.NewEncoder(body).Encode(payload); err != nil {
+		bufpool.Put(body)
+		return nil, nil, err
+	}
+	shared := newSharedBody(body)
+
+	resp, tried, err := c.do(ctx, "chat_completions", func() (*http.Request, error) {
+		req, err := c.newPostRequest(ctx, c.endpoint(ctx, "/chat/completions", payload.Model), shared, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, nil
+	})
+	if err != nil {
+		shared.release()
+		return nil, tried, err
+	}
+	shared.releaseOnClose(resp)
+	return resp, tried, nil
+}
+
+// transcriptionBody assembles the multipart upload in a pooled buffer.
+func transcriptionBody(reqPayload upstream.TranscriptionRequest) (*bytes.Buffer, string, error) {
+	body := bufpool.Get()
+	writer := multipart.NewWriter(body)
+	err := func() error {
+		if err := writer.WriteField("model", reqPayload.Model); err != nil {
+			return err
+		}
+		// /audio/translations takes neither a language nor timestamp
+		// granularities.
+		if reqPayload.Language != "" && !reqPayload.Translate {
+			if err := writer.WriteField("language", reqPayload.Language); err != nil {
+				return err
+			}
+		}
+		if reqPayload.Prompt != "" {
+			if err := writer.WriteField("prompt", reqPayload.Prompt); err != nil {
+				return err
+			}
+		}
+		if reqPayload.ResponseFormat != "" {
+			if err := writer.WriteField("response_format", reqPayload.ResponseFormat); err != nil {
+				return err
+			}
+		}
+		if reqPayload.WordTimestamps && !reqPayload.Translate {
+			if err := writeTimestampGranularities(writer); err != nil {
+				return err
+			}
+		}
+		part, err := writer.CreateFormFile("file", reqPayload.FileName)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, reqPayload.File); err != nil {
+			return err
+		}
+		return writer.Close()
+	}()
+	if err != nil {
+		bufpool.Put(body)
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+// writeTimestampGranularities asks for word timings. Naming a granularity
+// drops the default segment one, so both are sent.
+func writeTimestampGranularities(writer *multipart.Writer) error {
+	for _, granularity := range []string{"segment", "word"} {
+		if err := writer.WriteField("timestamp_granularities[]", granularity); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (c *Client) observe(endpoint string, status int, duration time.Duration) {
+// newPostRequest builds an authorized POST with its own reader over body.
+// GetBody is left nil so the buffer is never re-read after release.
+func (c *Client) newPostRequest(ctx context.Context, url string, body *sharedBody, contentType string) (*http.Request, error) {
+	reader := body.open()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	req.ContentLength = int64(body.buf.Len())
+	if err := c.setAuthorizationHeader(ctx, req); err != nil {
+		_ = req.Body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
+
+func readBody(r io.Reader) (*bytes.Buffer, error) {
+	buf := bufpool.Get()
+	if _, err := buf.ReadFrom(r); err != nil {
+		bufpool.Put(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// endpoint returns the URL for path. deployment is the model a call is
+// scoped to; only Azure puts it in the URL. A base URL from
+// upstream.WithRequestBaseURL wins, except on clients that ignore
+// per-request keys.
+func (c *Client) endpoint(ctx context.Context, path, deployment string) string {
+	base := c.baseURL
+	if requestBase := upstream.RequestBaseURLFromContext(ctx); requestBase != "" && !c.ignoreRequestKey {
+		base = requestBase
+	} else if c.selector != nil {
+		if selected := c.selector.BaseURL(); selected != "" {
+			base = strings.TrimRight(selected, "/")
+		}
+	}
+	if c.azureAPIVersion == "" {
+		return base + path
+	}
+	if deployment != "" {
+		path = "/deployments/" + url.PathEscape(deployment) + path
+	}
+	return base + "/openai" + path + "?api-version=" + url.QueryEscape(c.azureAPIVersion)
+}
+
+// observe reports a finished call to the observer and logs it with the
+// request's logger, so upstream calls share the request's fields. Failed
+// calls (no response, or an error status) log at warn level.
+func (c *Client) observe(ctx context.Context, endpoint string, status int, duration time.Duration) {
 	if c.observer != nil {
 		c.observer(endpoint, status, duration)
 	}
+	level := slog.LevelDebug
+	if status == 0 || status >= 400 {
+		level = slog.LevelWarn
+	}
+	reqctx.Logger(ctx).Log(ctx, level, "upstream_request", "endpoint", endpoint, "status", status, "duration_ms", duration.Milliseconds())
 }
 
 func (c *Client) setAuthorizationHeader(ctx context.Context, req *http.Request) error {
-	apiKey := RequestAPIKeyFromContext(ctx)
-	if apiKey == "" {
-		apiKey = c.apiKey
+	apiKey, err := c.resolveAPIKey(ctx)
+	if err != nil {
+		return err
 	}
-	if apiKey == "" {
-		return ErrMissingAPIKey
+	if apiKey != "" {
+		c.setKey(req, apiKey)
 	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
 	return nil
 }
 
-func parseTranscript(data []byte) (string, error) {
+func (c *Client) setKey(req *http.Request, apiKey string) {
+	if c.azureAPIVersion != "" {
+		req.Header.Set("api-key", apiKey)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+}
+
+func (c *Client) resolveAPIKey(ctx context.Context) (string, error) {
+	if requestKey := upstream.RequestAPIKeyFromContext(ctx); requestKey != "" && !c.ignoreRequestKey {
+		return requestKey, nil
+	}
+	// A caller's own endpoint gets their key or none, never the server's.
+	if upstream.RequestBaseURLFromContext(ctx) != "" && !c.ignoreRequestKey {
+		return "", nil
+	}
+	if apiKey := c.serverKey(ctx); apiKey != "" {
+		return apiKey, nil
+	}
+	return "", upstream.ErrMissingAPIKey
+}
+
+func parseTranscript(data []byte) (upstream.TranscriptionResponse, error) {
 	var parsed struct {
-		Text string `json:"text"`
+		Text     string                          `json:"text"`
+		Segments []upstream.TranscriptionSegment `json:"segments"`
+		Words    []upstream.TranscriptionWord    `json:"words"`
+		Language string                          `json:"language"`
 	}
 	if err := json.Unmarshal(data, &parsed); err == nil && parsed.Text != "" {
-		return parsed.Text, nil
+		return upstream.TranscriptionResponse{Text: parsed.Text, Segments: parsed.Segments, Words: parsed.Words, Language: parsed.Language}, nil
 	}
 
 	plainText := strings.TrimSpace(joinLines(string(data)))
 	if plainText == "" {
-		return "", fmt.Errorf("invalid transcription response")
+		return upstream.TranscriptionResponse{}, fmt.Errorf("invalid transcription response")
 	}
-	return plainText, nil
+	return upstream.TranscriptionResponse{Text: plainText}, nil
 }
 
-func parseChatCompletion(data []byte) (ChatCompletionResponse, error) {
+func parseChatCompletion(data []byte) (upstream.ChatCompletionResponse, error) {
 	var parsed struct {
 		Choices []struct {
 			Message struct {
@@ -262,19 +449,19 @@ func parseChatCompletion(data []byte) (ChatCompletionResponse, error) {
 		} `json:"usage,omitempty"`
 	}
 	if err := json.Unmarshal(data, &parsed); err != nil {
-		return ChatCompletionResponse{}, fmt.Errorf("invalid chat completion response: %w", err)
+		return upstream.ChatCompletionResponse{}, fmt.Errorf("invalid chat completion response: %w", err)
 	}
 	if len(parsed.Choices) == 0 {
-		return ChatCompletionResponse{}, fmt.Errorf("missing choices")
+		return upstream.ChatCompletionResponse{}, fmt.Errorf("missing choices")
 	}
 	content := parsed.Choices[0].Message.Content
 	if content == "" {
-		return ChatCompletionResponse{}, fmt.Errorf("missing choices[0].message.content")
+		return upstream.ChatCompletionResponse{}, fmt.Errorf("missing choices[0].message.content")
 	}
 
-	resp := ChatCompletionResponse{Content: content}
+	resp := upstream.ChatCompletionResponse{Content: content}
 	if parsed.Usage != nil {
-		resp.Usage = &TokenUsage{
+		resp.Usage = &upstream.TokenUsage{
 			PromptTokens:     parsed.Usage.PromptTokens,
 			CompletionTokens: parsed.Usage.CompletionTokens,
 			TotalTokens:      parsed.Usage.TotalTokens,
@@ -283,6 +470,64 @@ func parseChatCompletion(data []byte) (ChatCompletionResponse, error) {
 	return resp, nil
 }
 
+func readChatCompletionStream(body io.Reader, onDelta func(string) error) (upstream.ChatCompletionResponse, error) {
+	var content strings.Builder
+	var usage *upstream.TokenUsage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+				TotalTokens      int `json:"total_tokens"`
+			} `json:"usage,omitempty"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return upstream.ChatCompletionResponse{}, fmt.Errorf("invalid chat completion chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = &upstream.TokenUsage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		if onDelta != nil {
+			if err := onDelta(delta); err != nil {
+				return upstream.ChatCompletionResponse{}, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return upstream.ChatCompletionResponse{}, err
+	}
+	if content.Len() == 0 {
+		return upstream.ChatCompletionResponse{}, fmt.Errorf("missing streamed content")
+	}
+	return upstream.ChatCompletionResponse{Content: content.String(), Usage: usage}, nil
+}
+
 func joinLines(s string) string {
 	parts := strings.FieldsFunc(s, func(r rune) bool {
 		return r == '\n' || r == '\r'
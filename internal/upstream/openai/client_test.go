@@ -2,12 +2,19 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
 )
 
 func TestTranscribeParsesJSONResponse(t *testing.T) {
@@ -31,12 +38,38 @@ func TestTranscribeParsesJSONResponse(t *testing.T) {
 	defer ts.Close()
 
 	c := New(ts.URL, "test-key", ts.Client())
-	text, err := c.Transcribe(context.Background(), strings.NewReader("audio"), "sample.wav", "whisper-large-v3")
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "sample.wav", Model: "whisper-large-v3"})
 	if err != nil {
 		t.Fatalf("Transcribe() error = %v", err)
 	}
-	if text != "hello" {
-		t.Fatalf("unexpected text: %q", text)
+	if resp.Text != "hello" {
+		t.Fatalf("unexpected text: %q", resp.Text)
+	}
+}
+
+func TestTranslateUsesTranslationsEndpoint(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/translations" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm: %v", err)
+		}
+		_ = r.MultipartForm.RemoveAll()
+		if _, ok := r.MultipartForm.Value["language"]; ok || r.FormValue("prompt") != "Dana" {
+			t.Fatalf("unexpected fields: %v", r.MultipartForm.Value)
+		}
+		_, _ = io.WriteString(w, `{"text":"good morning"}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "test-key", ts.Client())
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{
+		File: strings.NewReader("audio"), FileName: "sample.wav", Model: "whisper-large-v3",
+		Language: "de", Prompt: "Dana", Translate: true,
+	})
+	if err != nil || resp.Text != "good morning" {
+		t.Fatalf("Transcribe() = %+v, %v", resp, err)
 	}
 }
 
@@ -47,12 +80,50 @@ func TestTranscribeParsesPlainTextResponse(t *testing.T) {
 	defer ts.Close()
 
 	c := New(ts.URL, "test-key", ts.Client())
-	text, err := c.Transcribe(context.Background(), strings.NewReader("audio"), "sample.wav", "whisper-large-v3")
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "sample.wav", Model: "whisper-large-v3"})
 	if err != nil {
 		t.Fatalf("Transcribe() error = %v", err)
 	}
-	if text != "hello world" {
-		t.Fatalf("unexpected text: %q", text)
+	if resp.Text != "hello world" {
+		t.Fatalf("unexpected text: %q", resp.Text)
+	}
+}
+
+func TestTranscribeRequestsAndParsesSegments(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm: %v", err)
+		}
+		_ = r.MultipartForm.RemoveAll()
+		if r.FormValue("response_format") != "verbose_json" || r.FormValue("language") != "de" || r.FormValue("prompt") != "Kubernetes, Grafana" {
+			t.Fatalf("unexpected response_format %q, language %q, prompt %q", r.FormValue("response_format"), r.FormValue("language"), r.FormValue("prompt"))
+		}
+		if got := strings.Join(r.MultipartForm.Value["timestamp_granularities[]"], ","); got != "segment,word" {
+			t.Fatalf("timestamp_granularities[] = %q", got)
+		}
+		_, _ = io.WriteString(w, `{"text":"hi there","segments":[{"start":0,"end":1.5,"text":"hi"},{"start":1.5,"end":2,"text":"there"}],
+			"words":[{"word":"hi","start":0,"end":0.4},{"word":"there","start":1.5,"end":1.9}]}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "test-key", ts.Client())
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{
+		File:           strings.NewReader("audio"),
+		FileName:       "sample.wav",
+		Model:          "whisper-large-v3",
+		Language:       "de",
+		Prompt:         "Kubernetes, Grafana",
+		ResponseFormat: "verbose_json",
+		WordTimestamps: true,
+	})
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if len(resp.Segments) != 2 || resp.Segments[1].Start != 1.5 || resp.Segments[1].Text != "there" {
+		t.Fatalf("unexpected segments: %+v", resp.Segments)
+	}
+	if len(resp.Words) != 2 || resp.Words[1].Word != "there" || resp.Words[1].End != 1.9 {
+		t.Fatalf("unexpected words: %+v", resp.Words)
 	}
 }
 
@@ -67,10 +138,10 @@ func TestChatCompletionParsesContentAndUsage(t *testing.T) {
 	defer ts.Close()
 
 	c := New(ts.URL, "test-key", ts.Client())
-	resp, err := c.ChatCompletion(context.Background(), ChatCompletionRequest{
+	resp, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{
 		Model:       "m",
 		Temperature: 0,
-		Messages:    []ChatMessage{{Role: "user", Content: "hi"}},
+		Messages:    []upstream.ChatMessage{{Role: "user", Content: "hi"}},
 	})
 	if err != nil {
 		t.Fatalf("ChatCompletion() error = %v", err)
@@ -90,13 +161,13 @@ func TestTranscribeReturnsUpstreamError(t *testing.T) {
 	defer ts.Close()
 
 	c := New(ts.URL, "test-key", ts.Client())
-	_, err := c.Transcribe(context.Background(), strings.NewReader("audio"), "sample.wav", "whisper-large-v3")
+	_, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "sample.wav", Model: "whisper-large-v3"})
 	if err == nil {
 		t.Fatal("expected error")
 	}
-	upErr, ok := err.(*Error)
+	upErr, ok := err.(*upstream.Error)
 	if !ok {
-		t.Fatalf("expected *Error, got %T", err)
+		t.Fatalf("expected *upstream.Error, got %T", err)
 	}
 	if upErr.StatusCode != http.StatusTooManyRequests {
 		t.Fatalf("unexpected status code: %d", upErr.StatusCode)
@@ -113,20 +184,308 @@ func TestTranscribeUsesRequestScopedAPIKeyOverride(t *testing.T) {
 	defer ts.Close()
 
 	c := New(ts.URL, "server-key", ts.Client())
-	ctx := WithRequestAPIKey(context.Background(), "byot-key")
-	text, err := c.Transcribe(ctx, strings.NewReader("audio"), "sample.wav", "whisper-large-v3")
+	ctx := upstream.WithRequestAPIKey(context.Background(), "byot-key")
+	resp, err := c.Transcribe(ctx, upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "sample.wav", Model: "whisper-large-v3"})
 	if err != nil {
 		t.Fatalf("Transcribe() error = %v", err)
 	}
-	if text != "hello" {
-		t.Fatalf("unexpected text: %q", text)
+	if resp.Text != "hello" {
+		t.Fatalf("unexpected text: %q", resp.Text)
+	}
+}
+
+func TestRequestBaseURLNeverGetsServerKey(t *testing.T) {
+	var auths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.URL.Path+" "+r.Header.Get("Authorization"))
+		_, _ = io.WriteString(w, `{"text":"hello"}`)
+	}))
+	defer ts.Close()
+
+	c := New("http://configured.invalid/v1", "server-key", ts.Client())
+	ctx := upstream.WithRequestBaseURL(context.Background(), ts.URL+"/acme/v1/")
+	if _, err := c.Transcribe(ctx, upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "a.wav"}); err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	ctx = upstream.WithRequestAPIKey(ctx, "acme-key")
+	if _, err := c.Transcribe(ctx, upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "a.wav"}); err != nil {
+		t.Fatalf("Transcribe() with key error = %v", err)
+	}
+	want := []string{"/acme/v1/audio/transcriptions ", "/acme/v1/audio/transcriptions Bearer acme-key"}
+	if !slices.Equal(auths, want) {
+		t.Fatalf("requests = %q, want %q", auths, want)
 	}
 }
 
 func TestTranscribeReturnsMissingAPIKeyError(t *testing.T) {
 	c := New("http://example.com", "", http.DefaultClient)
-	_, err := c.Transcribe(context.Background(), strings.NewReader("audio"), "sample.wav", "whisper-large-v3")
-	if !errors.Is(err, ErrMissingAPIKey) {
-		t.Fatalf("expected ErrMissingAPIKey, got %v", err)
+	_, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "sample.wav", Model: "whisper-large-v3"})
+	if !errors.Is(err, upstream.ErrMissingAPIKey) {
+		t.Fatalf("expected upstream.ErrMissingAPIKey, got %v", err)
+	}
+}
+
+func TestStreamChatCompletionForwardsDeltasAndUsage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"stream":true`) || !strings.Contains(string(body), `"include_usage":true`) {
+			t.Fatalf("expected streaming request, got %s", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n"+
+			"data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n"+
+			": keep-alive\n\n"+
+			"data: {\"choices\":[{\"delta\":{\"content\":\" world\"}}]}\n\n"+
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2,\"total_tokens\":11}}\n\n"+
+			"data: [DONE]\n\n")
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "test-key", ts.Client())
+	var deltas []string
+	resp, err := c.StreamChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m"}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChatCompletion() error = %v", err)
+	}
+	if strings.Join(deltas, "|") != "Hello| world" || resp.Content != "Hello world" {
+		t.Fatalf("unexpected stream: deltas=%q content=%q", deltas, resp.Content)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 11 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestStreamChatCompletionStopsOnCallbackError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"b\"}}]}\n\n")
+	}))
+	defer ts.Close()
+
+	stop := errors.New("client gone")
+	calls := 0
+	_, err := New(ts.URL, "k", ts.Client()).StreamChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m"}, func(string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected callback error after one delta, got err=%v calls=%d", err, calls)
+	}
+}
+
+func BenchmarkTranscribe(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = io.WriteString(w, `{"text":"hello world"}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "k", ts.Client())
+	audio := strings.Repeat("a", 256<<10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader(audio), FileName: "a.wav", Model: "m"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChatCompletion(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"cleaned"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "k", ts.Client())
+	transcript := strings.Repeat("word ", 2000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m", Messages: []upstream.ChatMessage{{Role: "user", Content: transcript}}}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPingReusesConnectionWithoutAPIKey(t *testing.T) {
+	var conns atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/models" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	c := New(ts.URL, "", ts.Client())
+	for range 3 {
+		if err := c.Ping(context.Background()); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+	}
+	if got := conns.Load(); got != 1 {
+		t.Fatalf("expected a single reused connection, got %d", got)
+	}
+}
+
+type staticSelector string
+
+func (s staticSelector) BaseURL() string { return string(s) }
+
+func TestChatCompletionUsesSelectedBaseURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eu/v1/chat/completions" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer ts.Close()
+
+	c := New("http://primary.invalid", "key", ts.Client(), WithBaseURLSelector(staticSelector(ts.URL+"/eu/v1/")))
+	resp, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m"})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Fatalf("unexpected content: %q", resp.Content)
+	}
+}
+
+func TestWithoutRequestAPIKeyUsesConfiguredKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer server-key" {
+			t.Fatalf("unexpected auth header: %q", got)
+		}
+		_, _ = io.WriteString(w, `{"text":"ok"}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "server-key", ts.Client(), WithoutRequestAPIKey())
+	ctx := upstream.WithRequestAPIKey(context.Background(), "caller-token")
+	if _, err := c.Transcribe(ctx, upstream.TranscriptionRequest{File: strings.NewReader("a"), FileName: "a.wav", Model: "m"}); err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+}
+
+func TestRotateAPIKeyValidatesAndKeepsPinnedKeyDuringGrace(t *testing.T) {
+	var lastAuth atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		lastAuth.Store(auth)
+		if auth == "Bearer bad-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, `{"data":[]}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "old-key", ts.Client())
+	inFlight := c.PinAPIKey(context.Background())
+
+	var upstreamErr *upstream.Error
+	if err := c.RotateAPIKey(context.Background(), "bad-key", time.Minute); !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("RotateAPIKey(bad) = %v, want 401", err)
+	}
+	if err := c.CheckHealth(context.Background()); err != nil || lastAuth.Load() != "Bearer old-key" {
+		t.Fatalf("rejected key was swapped in: err=%v auth=%v", err, lastAuth.Load())
+	}
+
+	if err := c.RotateAPIKey(context.Background(), "new-key", time.Minute); err != nil {
+		t.Fatalf("RotateAPIKey: %v", err)
+	}
+	_ = c.CheckHealth(context.Background())
+	if lastAuth.Load() != "Bearer new-key" {
+		t.Fatalf("new requests auth = %v, want new-key", lastAuth.Load())
+	}
+	_ = c.CheckHealth(inFlight)
+	if lastAuth.Load() != "Bearer old-key" {
+		t.Fatalf("in-flight request auth = %v, want old-key", lastAuth.Load())
+	}
+
+	c.keyMu.Lock()
+	c.previousUntil = time.Now().Add(-time.Second)
+	c.keyMu.Unlock()
+	_ = c.CheckHealth(inFlight)
+	if lastAuth.Load() != "Bearer new-key" {
+		t.Fatalf("auth after grace = %v, want new-key", lastAuth.Load())
+	}
+}
+
+func TestTokenizeParsesTokens(t *testing.T) {
+	var auth atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["prompt"] != " Kubernetes" || body["model"] != "m" || body["add_special_tokens"] != false {
+			t.Errorf("unexpected body: %v", body)
+		}
+		_, _ = io.WriteString(w, `{"tokens":[42,7],"count":2}`)
+	}))
+	defer ts.Close()
+
+	c := New("http://primary.invalid", "key", ts.Client(), WithTokenizeURL(ts.URL+"/tokenize", "tok-key"))
+	ctx := upstream.WithRequestAPIKey(context.Background(), "caller-key")
+	ids, err := c.Tokenize(ctx, "m", " Kubernetes")
+	if err != nil || len(ids) != 2 || ids[0] != 42 {
+		t.Fatalf("Tokenize() = %v, %v", ids, err)
+	}
+	if auth.Load() != "Bearer tok-key" {
+		t.Fatalf("Authorization = %v, want the tokenizer's own key", auth.Load())
+	}
+	if _, err := New("http://primary.invalid", "key", ts.Client(), WithTokenizeURL(ts.URL+"/tokenize", "")).Tokenize(ctx, "m", " Kubernetes"); err != nil || auth.Load() != "" {
+		t.Fatalf("without a tokenizer key: Authorization = %v, err %v", auth.Load(), err)
+	}
+	if _, err := New(ts.URL, "key", ts.Client()).Tokenize(context.Background(), "m", "x"); err == nil {
+		t.Fatal("Tokenize() without a tokenize URL succeeded")
+	}
+}
+
+func TestAzureUsesDeploymentURLsAndAPIKeyHeader(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		if r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("auth headers: api-key=%q Authorization=%q", r.Header.Get("api-key"), r.Header.Get("Authorization"))
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/audio/transcriptions"):
+			_, _ = io.WriteString(w, `{"text":"hi"}`)
+		case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+			_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+		default:
+			_, _ = io.WriteString(w, `{"data":[]}`)
+		}
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "azure-key", ts.Client(), WithAzure("2024-10-21"))
+	if _, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("a"), FileName: "a.wav", Model: "whisper-prod"}); err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if _, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "gpt-4o-mini"}); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if err := c.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("CheckHealth() error = %v", err)
+	}
+	want := []string{
+		"POST /openai/deployments/whisper-prod/audio/transcriptions?api-version=2024-10-21",
+		"POST /openai/deployments/gpt-4o-mini/chat/completions?api-version=2024-10-21",
+		"GET /openai/models?api-version=2024-10-21",
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("requests:\n%s\nwant:\n%s", strings.Join(paths, "\n"), strings.Join(want, "\n"))
 	}
 }
@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+type pinnedKeyContextKey struct{}
+
+// serverKey returns the server-side key for a request. A key pinned by
+// PinAPIKey is honoured while it is current or within the grace period after
+// a rotation, so a multi-call request does not switch keys midway.
+func (c *Client) serverKey(ctx context.Context) string {
+	c.keyMu.RLock()
+	defer c.keyMu.RUnlock()
+	pinned, _ := ctx.Value(pinnedKeyContextKey{}).(string)
+	if pinned != "" && pinned == c.previousKey && time.Now().Before(c.previousUntil) {
+		return pinned
+	}
+	return c.apiKey
+}
+
+// PinAPIKey records the current server-side key in ctx for the rest of the
+// request.
+func (c *Client) PinAPIKey(ctx context.Context) context.Context {
+	c.keyMu.RLock()
+	defer c.keyMu.RUnlock()
+	return context.WithValue(ctx, pinnedKeyContextKey{}, c.apiKey)
+}
+
+// RotateAPIKey validates key against /models and makes it the server-side
+// key. Requests pinned to the old key keep using it for grace.
+func (c *Client) RotateAPIKey(ctx context.Context, key string, grace time.Duration) error {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return upstream.ErrMissingAPIKey
+	}
+	if err := c.checkModels(ctx, key); err != nil {
+		return err
+	}
+
+	c.keyMu.Lock()
+	defer c.keyMu.Unlock()
+	if key == c.apiKey {
+		return nil
+	}
+	c.previousKey, c.previousUntil = c.apiKey, time.Now().Add(grace)
+	c.apiKey = key
+	return nil
+}
+
+func (c *Client) checkModels(ctx context.Context, key string) error {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe(ctx, "models", statusCode, time.Since(started)) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(ctx, "/models", ""), nil)
+	if err != nil {
+		return err
+	}
+	c.setKey(req, key)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+	}
+	return nil
+}
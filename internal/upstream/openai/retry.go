@@ -0,0 +1,247 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"echoflow/internal/bufpool"
+	"echoflow/internal/reqctx"
+	"echoflow/internal/upstream"
+)
+
+// RetryPolicy retries rate limits (429), 5xx responses and transport errors
+// with jittered exponential backoff. A Retry-After header replaces the
+// backoff for that attempt.
+type RetryPolicy struct {
+	// MaxAttempts counts the first try; values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles after each.
+	BaseDelay time.Duration
+	// MaxDelay caps any single wait. A Retry-After asking for longer ends the
+	// retries, since waiting less would only hit the limit again.
+	MaxDelay time.Duration
+}
+
+// RetryObserverFunc is told about each retry and why: the status code, or
+// "network" for a transport error.
+type RetryObserverFunc func(endpoint, reason string)
+
+// WithRetries retries failed calls per policy. Retries never outlast the
+// request's deadline: a wait that would pass it returns the last failure
+// instead. Streams are only retried before the first byte of the response.
+func WithRetries(policy RetryPolicy, observer RetryObserverFunc) Option {
+	return func(c *Client) {
+		c.retry = policy
+		c.retryObserver = observer
+	}
+}
+
+// attempts records the tries of one call. Each is logged at debug level as it
+// finishes.
+type attempts []upstream.Attempt
+
+// wrap attaches the attempts to err when the call was retried; a call made
+// once returns its error unchanged.
+func (a attempts) wrap(err error) error {
+	if err == nil || len(a) < 2 {
+		return err
+	}
+	return &upstream.AttemptsError{Attempts: a, Err: err}
+}
+
+func (a *attempts) record(ctx context.Context, endpoint string, req *http.Request, resp *http.Response, err error, duration time.Duration) {
+	attempt := upstream.Attempt{Number: len(*a) + 1, Duration: duration, Bytes: max(req.ContentLength, 0)}
+	if resp != nil {
+		attempt.Status = resp.StatusCode
+	}
+	if err != nil {
+		attempt.Error = errorCategory(err)
+	}
+	*a = append(*a, attempt)
+	logger := reqctx.Logger(ctx)
+	if err != nil {
+		logger = logger.With("error", err)
+	}
+	logger.Debug("upstream_attempt",
+		"endpoint", endpoint,
+		"attempt", attempt.Number,
+		"status", attempt.Status,
+		"duration_ms", duration.Milliseconds(),
+		"bytes", attempt.Bytes,
+	)
+}
+
+// errorCategory names what went wrong with a transport error without its
+// text, which carries the upstream URL and is returned to callers.
+func errorCategory(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection_reset"
+	case errors.As(err, &certErr):
+		return "tls"
+	}
+	return "network"
+}
+
+// do sends the request newReq builds, building a fresh one for each attempt
+// so the body and the selected base URL are current. It returns the last
+// attempt's response or error as is, plus every attempt made; callers pass
+// their final error through attempts.wrap.
+func (c *Client) do(ctx context.Context, endpoint string, newReq func() (*http.Request, error)) (*http.Response, attempts, error) {
+	var tried attempts
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, tried, err
+		}
+		started := time.Now()
+		resp, err := c.httpClient.Do(req)
+		tried.record(ctx, endpoint, req, resp, err, time.Since(started))
+		if attempt >= c.retry.MaxAttempts || ctx.Err() != nil {
+			return resp, tried, err
+		}
+		reason, retry := retryReason(resp, err)
+		if !retry {
+			return resp, tried, err
+		}
+		delay, ok := c.retryDelay(attempt, resp)
+		if deadline, has := ctx.Deadline(); !ok || (has && time.Until(deadline) <= delay) {
+			return resp, tried, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+		if c.retryObserver != nil {
+			c.retryObserver(endpoint, reason)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, tried, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func retryReason(resp *http.Response, err error) (string, bool) {
+	if err != nil {
+		return "network", !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return strconv.Itoa(resp.StatusCode), true
+	}
+	return "", false
+}
+
+// retryDelay returns the wait before the next attempt, and false when a
+// Retry-After asks for longer than MaxDelay.
+func (c *Client) retryDelay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return after, after <= c.retry.MaxDelay
+		}
+	}
+	backoff := c.retry.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > c.retry.MaxDelay {
+		backoff = c.retry.MaxDelay
+	}
+	// Equal jitter: at least half the backoff, so bursts of retries spread
+	// out without ever retrying immediately.
+	half := backoff / 2
+	return half + rand.N(half+1), true
+}
+
+// parseRetryAfter reads delay-seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sharedBody lets every attempt send the same pooled buffer. The buffer goes
+// back to the pool once the caller releases it and the transport has closed
+// every attempt's reader, which may happen after Do returns. The transport
+// can close a reader while it is still writing from it, so the caller holds
+// its reference until the response body is done.
+type sharedBody struct {
+	buf  *bytes.Buffer
+	refs atomic.Int32
+}
+
+func newSharedBody(buf *bytes.Buffer) *sharedBody {
+	b := &sharedBody{buf: buf}
+	b.refs.Store(1)
+	return b
+}
+
+// open returns a reader for one attempt; the transport closes it.
+func (b *sharedBody) open() io.ReadCloser {
+	b.refs.Add(1)
+	return &sharedReader{Reader: bytes.NewReader(b.buf.Bytes()), body: b}
+}
+
+func (b *sharedBody) release() {
+	if b.refs.Add(-1) == 0 {
+		bufpool.Put(b.buf)
+	}
+}
+
+// releaseOnClose passes the caller's reference to resp's body, for callers
+// that return the response before reading it.
+func (b *sharedBody) releaseOnClose(resp *http.Response) {
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: b.release}
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releasingBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+type sharedReader struct {
+	*bytes.Reader
+	body *sharedBody
+	once sync.Once
+}
+
+func (r *sharedReader) Close() error {
+	r.once.Do(r.body.release)
+	return nil
+}
@@ -0,0 +1,181 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+func TestTranscribeRetriesRateLimitWithSameBody(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("FormFile: %v", err)
+		} else if data, _ := io.ReadAll(file); string(data) != "audio" {
+			t.Errorf("attempt %d sent %q", calls.Load()+1, data)
+		}
+		_ = r.MultipartForm.RemoveAll()
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = io.WriteString(w, `{"text":"hello"}`)
+	}))
+	defer ts.Close()
+
+	var retries []string
+	c := New(ts.URL, "test-key", ts.Client(), WithRetries(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}, func(endpoint, reason string) {
+		retries = append(retries, endpoint+":"+reason)
+	}))
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("audio"), FileName: "sample.wav", Model: "whisper-large-v3"})
+	if err != nil || resp.Text != "hello" {
+		t.Fatalf("Transcribe = %+v, %v", resp, err)
+	}
+	if calls.Load() != 2 || len(retries) != 1 || retries[0] != "audio_transcriptions:429" {
+		t.Fatalf("calls = %d retries = %v", calls.Load(), retries)
+	}
+}
+
+func TestChatCompletionStopsRetryingAtMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = io.WriteString(w, `{"error":"bad gateway"}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "test-key", ts.Client(), WithRetries(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}, nil))
+	_, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m"})
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusBadGateway || !strings.Contains(upstreamErr.Body, "bad gateway") {
+		t.Fatalf("err = %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("calls = %d, want 3", calls.Load())
+	}
+	var attemptsErr *upstream.AttemptsError
+	if !errors.As(err, &attemptsErr) || len(attemptsErr.Attempts) != 3 {
+		t.Fatalf("err = %v, want the three attempts attached", err)
+	}
+	for i, attempt := range attemptsErr.Attempts {
+		if attempt.Number != i+1 || attempt.Status != http.StatusBadGateway || attempt.Bytes == 0 {
+			t.Fatalf("attempt %d = %+v", i, attempt)
+		}
+	}
+}
+
+func TestChatCompletionDoesNotRetryClientErrorsOrLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusBadRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "test-key", ts.Client(), WithRetries(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}, nil))
+	if _, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	status = http.StatusTooManyRequests
+	if _, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want one per request", calls.Load())
+	}
+}
+
+func TestChatCompletionRetryRespectsDeadline(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "test-key", ts.Client(), WithRetries(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := c.ChatCompletion(ctx, upstream.ChatCompletionRequest{Model: "m"})
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("err = %v calls = %d", err, calls.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"3", 3 * time.Second, true},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{"soon", 0, false},
+		{"", 0, false},
+	} {
+		got, ok := parseRetryAfter(tc.value, now)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("parseRetryAfter(%q) = %v, %v", tc.value, got, ok)
+		}
+	}
+}
+
+func TestSharedBodyOutlivesTheTransportsClose(t *testing.T) {
+	buf := bytes.NewBufferString("payload")
+	shared := newSharedBody(buf)
+	reader := shared.open()
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader("ok"))}
+	shared.releaseOnClose(resp)
+
+	// The transport may close the request body before it stops writing it.
+	_ = reader.Close()
+	if buf.String() != "payload" {
+		t.Fatalf("buffer released while the response was open")
+	}
+	_ = resp.Body.Close()
+	_ = resp.Body.Close()
+	if buf.Len() != 0 {
+		t.Fatalf("buffer not released after the response was closed: %q", buf.String())
+	}
+}
+
+func TestAttemptsNameTransportErrorsWithoutTheURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	ts.Close()
+
+	c := New(ts.URL, "test-key", ts.Client(), WithRetries(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, nil))
+	_, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "m"})
+	var attemptsErr *upstream.AttemptsError
+	if !errors.As(err, &attemptsErr) || len(attemptsErr.Attempts) != 2 {
+		t.Fatalf("err = %v", err)
+	}
+	for _, attempt := range attemptsErr.Attempts {
+		if attempt.Error != "connection_refused" {
+			t.Fatalf("attempt = %+v", attempt)
+		}
+	}
+	if got := errorCategory(&url.Error{Op: "Post", URL: ts.URL, Err: context.DeadlineExceeded}); got != "timeout" {
+		t.Fatalf("errorCategory(deadline) = %q", got)
+	}
+}
@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"echoflow/internal/upstream"
+)
+
+var errNoTokenizeURL = errors.New("tokenize URL is not configured")
+
+// WithTokenizeURL sets the tokenizer endpoint used by Tokenize. The OpenAI
+// API has none; vLLM and llama.cpp servers expose one at /tokenize. apiKey,
+// if set, is sent as its bearer token; neither the client's key nor a
+// caller's ever is, since the endpoint is configured separately.
+func WithTokenizeURL(url, apiKey string) Option {
+	return func(c *Client) {
+		c.tokenizeURL = strings.TrimSpace(url)
+		c.tokenizeKey = strings.TrimSpace(apiKey)
+	}
+}
+
+// Tokenize returns the token IDs model uses for text, without special tokens.
+// The body carries the text as both "prompt" (vLLM) and "content" (llama.cpp).
+func (c *Client) Tokenize(ctx context.Context, model, text string) ([]int, error) {
+	if c.tokenizeURL == "" {
+		return nil, errNoTokenizeURL
+	}
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe(ctx, "tokenize", statusCode, time.Since(started)) }()
+
+	body, err := json.Marshal(map[string]any{
+		"model":              model,
+		"prompt":             text,
+		"content":            text,
+		"add_special_tokens": false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenizeURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.tokenizeKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.tokenizeKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(data))}
+	}
+	var parsed struct {
+		Tokens []int `json:"tokens"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid tokenize response: %w", err)
+	}
+	return parsed.Tokens, nil
+}
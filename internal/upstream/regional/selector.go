@@ -0,0 +1,208 @@
+// Package regional probes several equivalent upstream base URLs and routes
+// new requests to the fastest healthy one.
+package regional
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	latencySmoothing = 0.3
+	// switchMargin is how much faster another upstream must be before
+	// traffic moves off a healthy current choice, to avoid flapping.
+	switchMargin = 0.8
+)
+
+type ObserverFunc func(baseURL string, latency time.Duration, healthy bool)
+
+type Config struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+type Option func(*Selector)
+
+func WithObserver(observer ObserverFunc) Option {
+	return func(s *Selector) {
+		s.observer = observer
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Selector) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
+type upstream struct {
+	baseURL string
+	latency time.Duration
+	healthy bool
+	probed  bool
+}
+
+type Selector struct {
+	client   *http.Client
+	cfg      Config
+	observer ObserverFunc
+	logger   *slog.Logger
+
+	mu        sync.RWMutex
+	upstreams []upstream
+	current   int
+}
+
+// NewSelector routes to baseURLs[0] until the first probe completes.
+func NewSelector(baseURLs []string, client *http.Client, cfg Config, opts ...Option) *Selector {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	s := &Selector{
+		client: client,
+		cfg:    cfg,
+		logger: slog.Default(),
+	}
+	for _, u := range baseURLs {
+		s.upstreams = append(s.upstreams, upstream{baseURL: strings.TrimRight(u, "/"), healthy: true})
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Selector) BaseURL() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.upstreams) == 0 {
+		return ""
+	}
+	return s.upstreams[s.current].baseURL
+}
+
+// Run probes every upstream immediately and then every Interval until ctx is
+// cancelled.
+func (s *Selector) Run(ctx context.Context) {
+	if s.cfg.Interval <= 0 || len(s.upstreams) < 2 {
+		return
+	}
+	s.probeAll(ctx)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.probeAll(ctx)
+		}
+	}
+}
+
+type probeResult struct {
+	latency time.Duration
+	healthy bool
+}
+
+func (s *Selector) probeAll(ctx context.Context) {
+	s.mu.RLock()
+	urls := make([]string, len(s.upstreams))
+	for i, u := range s.upstreams {
+		urls[i] = u.baseURL
+	}
+	s.mu.RUnlock()
+
+	results := make([]probeResult, len(urls))
+	var wg sync.WaitGroup
+	for i, baseURL := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.probe(ctx, baseURL)
+		}()
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	for i, res := range results {
+		u := &s.upstreams[i]
+		u.healthy = res.healthy
+		if res.healthy {
+			if u.probed {
+				u.latency = time.Duration((1-latencySmoothing)*float64(u.latency) + latencySmoothing*float64(res.latency))
+			} else {
+				u.latency = res.latency
+			}
+			u.probed = true
+		}
+	}
+	prev := s.current
+	s.current = s.pickLocked()
+	next := s.upstreams[s.current]
+	s.mu.Unlock()
+
+	for i, res := range results {
+		if s.observer != nil {
+			s.observer(urls[i], res.latency, res.healthy)
+		}
+	}
+	if next.baseURL != urls[prev] {
+		s.logger.Info("upstream selection changed", "from", urls[prev], "to", next.baseURL, "latency_ms", next.latency.Milliseconds())
+	}
+}
+
+// pickLocked keeps the current upstream unless it is unhealthy or another
+// healthy one is clearly faster. With nothing healthy it falls back to the
+// first configured URL.
+func (s *Selector) pickLocked() int {
+	best := -1
+	for i, u := range s.upstreams {
+		if !u.healthy || !u.probed {
+			continue
+		}
+		if best < 0 || u.latency < s.upstreams[best].latency {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	cur := s.upstreams[s.current]
+	if cur.healthy && cur.probed && float64(s.upstreams[best].latency) > switchMargin*float64(cur.latency) {
+		return s.current
+	}
+	return best
+}
+
+// probe times a HEAD request. Any response below 500 counts as healthy, so
+// probes need no API key.
+func (s *Selector) probe(ctx context.Context, baseURL string) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL+"/models", nil)
+	if err != nil {
+		return probeResult{}
+	}
+	started := time.Now()
+	resp, err := s.client.Do(req)
+	latency := time.Since(started)
+	if err != nil {
+		s.logger.Debug("upstream probe failed", "upstream", baseURL, "error", err)
+		return probeResult{latency: latency}
+	}
+	_ = resp.Body.Close()
+	return probeResult{latency: latency, healthy: resp.StatusCode < http.StatusInternalServerError}
+}
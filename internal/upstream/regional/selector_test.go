@@ -0,0 +1,67 @@
+package regional
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newUpstream(t *testing.T, delay time.Duration, status int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbeSelectsFastestHealthyUpstream(t *testing.T) {
+	slow := newUpstream(t, 60*time.Millisecond, http.StatusUnauthorized)
+	fast := newUpstream(t, 0, http.StatusUnauthorized)
+	broken := newUpstream(t, 0, http.StatusBadGateway)
+
+	var mu sync.Mutex
+	observed := map[string]bool{}
+	s := NewSelector([]string{slow.URL, broken.URL, fast.URL}, nil, Config{Interval: time.Minute}, WithObserver(func(baseURL string, _ time.Duration, healthy bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed[baseURL] = healthy
+	}))
+	if got := s.BaseURL(); got != slow.URL {
+		t.Fatalf("expected primary before probing, got %s", got)
+	}
+
+	s.probeAll(context.Background())
+
+	if got := s.BaseURL(); got != fast.URL {
+		t.Fatalf("expected fastest upstream %s, got %s", fast.URL, got)
+	}
+	if observed[broken.URL] || !observed[fast.URL] || !observed[slow.URL] {
+		t.Fatalf("unexpected health observations: %v", observed)
+	}
+}
+
+func TestPickKeepsCurrentUnlessClearlyFaster(t *testing.T) {
+	s := NewSelector([]string{"http://a", "http://b"}, nil, Config{})
+	s.upstreams[0] = upstream{baseURL: "http://a", latency: 100 * time.Millisecond, healthy: true, probed: true}
+	s.upstreams[1] = upstream{baseURL: "http://b", latency: 90 * time.Millisecond, healthy: true, probed: true}
+
+	if got := s.pickLocked(); got != 0 {
+		t.Fatalf("expected to stay on current upstream, got %d", got)
+	}
+
+	s.upstreams[1].latency = 50 * time.Millisecond
+	if got := s.pickLocked(); got != 1 {
+		t.Fatalf("expected switch to clearly faster upstream, got %d", got)
+	}
+
+	s.upstreams[0].healthy = false
+	s.upstreams[1].healthy = false
+	if got := s.pickLocked(); got != 0 {
+		t.Fatalf("expected fallback to primary when nothing is healthy, got %d", got)
+	}
+}
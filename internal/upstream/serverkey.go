@@ -0,0 +1,76 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+
+	"echoflow/internal/reqctx"
+)
+
+// ErrServerKeyOnly is returned when a request paid with the caller's own key
+// reaches an upstream that only has the server's key.
+var ErrServerKeyOnly = errors.New("this upstream is only available to requests on the server's key")
+
+// ServerPays reports whether the server's own key pays for ctx's upstream
+// calls. Requests without a key source are the server's background calls.
+func ServerPays(ctx context.Context) bool {
+	return reqctx.APIKeySource(ctx) != reqctx.KeySourceCaller
+}
+
+// ServerKeyTranscriber sends calls to server, a client holding the operator's
+// key, only when the server pays for the request. Calls on a caller's key go
+// to caller, which forwards that key, or fail with ErrServerKeyOnly when
+// caller is nil. An unvalidated BYOT bearer can then never spend the
+// operator's key.
+func ServerKeyTranscriber(server, caller Transcriber) Transcriber {
+	return serverKeyTranscriber{server: server, caller: caller}
+}
+
+type serverKeyTranscriber struct {
+	server, caller Transcriber
+}
+
+func (t serverKeyTranscriber) Transcribe(ctx context.Context, req TranscriptionRequest) (TranscriptionResponse, error) {
+	if ServerPays(ctx) {
+		return t.server.Transcribe(ctx, req)
+	}
+	if t.caller == nil {
+		return TranscriptionResponse{}, ErrServerKeyOnly
+	}
+	return t.caller.Transcribe(ctx, req)
+}
+
+// ServerKeyChatCompleter is ServerKeyTranscriber for chat completions.
+func ServerKeyChatCompleter(server, caller ChatCompleter) ChatCompleter {
+	return serverKeyChat{server: server, caller: caller}
+}
+
+type serverKeyChat struct {
+	server, caller ChatCompleter
+}
+
+func (c serverKeyChat) pick(ctx context.Context) (ChatCompleter, error) {
+	if ServerPays(ctx) {
+		return c.server, nil
+	}
+	if c.caller == nil {
+		return nil, ErrServerKeyOnly
+	}
+	return c.caller, nil
+}
+
+func (c serverKeyChat) ChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	next, err := c.pick(ctx)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	return next.ChatCompletion(ctx, req)
+}
+
+func (c serverKeyChat) StreamChatCompletion(ctx context.Context, req ChatCompletionRequest, onDelta func(string) error) (ChatCompletionResponse, error) {
+	next, err := c.pick(ctx)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	return next.StreamChatCompletion(ctx, req, onDelta)
+}
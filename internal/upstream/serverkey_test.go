@@ -0,0 +1,37 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"echoflow/internal/reqctx"
+)
+
+type namedTranscriber string
+
+func (n namedTranscriber) Transcribe(context.Context, TranscriptionRequest) (TranscriptionResponse, error) {
+	return TranscriptionResponse{Text: string(n)}, nil
+}
+
+func TestServerKeyTranscriber(t *testing.T) {
+	server := reqctx.WithAPIKeySource(context.Background(), reqctx.KeySourceServer)
+	caller := reqctx.WithAPIKeySource(context.Background(), reqctx.KeySourceCaller)
+	tests := []struct {
+		ctx    context.Context
+		caller Transcriber
+		want   string
+		err    error
+	}{
+		{ctx: server, caller: namedTranscriber("caller"), want: "server"},
+		{ctx: context.Background(), caller: namedTranscriber("caller"), want: "server"},
+		{ctx: caller, caller: namedTranscriber("caller"), want: "caller"},
+		{ctx: caller, err: ErrServerKeyOnly},
+	}
+	for i, tt := range tests {
+		res, err := ServerKeyTranscriber(namedTranscriber("server"), tt.caller).Transcribe(tt.ctx, TranscriptionRequest{})
+		if !errors.Is(err, tt.err) || res.Text != tt.want {
+			t.Errorf("case %d: got %q, %v; want %q, %v", i, res.Text, err, tt.want, tt.err)
+		}
+	}
+}
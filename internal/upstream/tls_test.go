@@ -0,0 +1,111 @@
+package upstream
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a PEM certificate and key signed by parent (self-signed
+// when parent is nil) and returns them with their file paths.
+func writeCert(t *testing.T, dir, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	_ = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	_ = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return cert, key, certPath, keyPath
+}
+
+func TestTLSConfigPresentsClientCertificateToMutualTLSUpstream(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPath, _ := writeCert(t, dir, "ca", true, nil, nil)
+	_, _, serverCertPath, serverKeyPath := writeCert(t, dir, "server", false, ca, caKey)
+	_, _, clientCertPath, clientKeyPath := writeCert(t, dir, "client", false, ca, caKey)
+
+	serverCert, err := tls.LoadX509KeyPair(serverCertPath, serverKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := x509.NewCertPool()
+	clients.AddCert(ca)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientCAs: clients, ClientAuth: tls.RequireAndVerifyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	cfg, err := TLSConfig(clientCertPath, clientKeyPath, caPath)
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("mTLS request: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	// Without the client certificate the handshake is refused.
+	cfg, _ = TLSConfig("", "", caPath)
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	if resp, err := client.Get(ts.URL); err == nil {
+		_ = resp.Body.Close()
+		t.Fatalf("expected handshake failure without a client certificate")
+	}
+}
+
+func TestTLSConfigRejectsIncompleteSettings(t *testing.T) {
+	if cfg, err := TLSConfig("", "", ""); cfg != nil || err != nil {
+		t.Fatalf("expected no config, got %v %v", cfg, err)
+	}
+	if _, err := TLSConfig("client.crt", "", ""); err == nil {
+		t.Fatalf("expected error for a certificate without a key")
+	}
+	path := filepath.Join(t.TempDir(), "empty.pem")
+	_ = os.WriteFile(path, []byte("not pem"), 0o600)
+	if _, err := TLSConfig("", "", path); err == nil {
+		t.Fatalf("expected error for a CA bundle without certificates")
+	}
+}
@@ -0,0 +1,204 @@
+// Package upstream defines the vendor-neutral speech and LLM interfaces the
+// services depend on. Each vendor lives in its own subpackage; the openai
+// package speaks the OpenAI-compatible API used by OpenAI and Groq.
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Transcriber interface {
+	Transcribe(ctx context.Context, req TranscriptionRequest) (TranscriptionResponse, error)
+}
+
+type ChatCompleter interface {
+	ChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error)
+	// StreamChatCompletion calls onDelta for each content fragment as it
+	// arrives and returns the full content. An error from onDelta aborts the
+	// stream and is returned as is.
+	StreamChatCompletion(ctx context.Context, req ChatCompletionRequest, onDelta func(string) error) (ChatCompletionResponse, error)
+}
+
+type HealthChecker interface {
+	// CheckHealth verifies the vendor is reachable and accepts our credentials.
+	CheckHealth(ctx context.Context) error
+}
+
+// Pinger reaches the vendor without credentials, showing it is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Provider is one configured vendor. Capabilities it does not offer are nil.
+type Provider struct {
+	Name          string
+	Transcriber   Transcriber
+	ChatCompleter ChatCompleter
+	HealthChecker HealthChecker
+}
+
+var ErrMissingAPIKey = errors.New("missing upstream API key")
+
+// ErrTranslationUnsupported is returned for a TranscriptionRequest with
+// Translate set by vendors that cannot translate audio.
+var ErrTranslationUnsupported = errors.New("upstream does not support audio translation")
+
+// Error is a non-success HTTP response from a vendor.
+type Error struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("upstream request failed with status %d", e.StatusCode)
+}
+
+// Attempt is one try of an upstream call that was retried.
+type Attempt struct {
+	Number   int
+	Status   int // 0 when no response arrived
+	Duration time.Duration
+	Bytes    int64 // request body size
+	Error    string
+}
+
+// AttemptsError wraps the final error of a retried upstream call with every
+// attempt made, so callers can report what happened before giving up.
+type AttemptsError struct {
+	Attempts []Attempt
+	Err      error
+}
+
+func (e *AttemptsError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, len(e.Attempts))
+}
+
+func (e *AttemptsError) Unwrap() error { return e.Err }
+
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+type TranscriptionRequest struct {
+	File     io.Reader
+	FileName string
+	Model    string
+	// Language is an ISO 639-1 hint for the spoken language; empty lets the
+	// vendor detect it.
+	Language string
+	// Prompt is Whisper's initial prompt: text in the style of the audio,
+	// with spellings of names and terms it may contain.
+	Prompt         string
+	ResponseFormat string
+	// WordTimestamps asks for per-word timings along with verbose_json
+	// segments.
+	WordTimestamps bool
+	// Translate asks for an English translation of the audio instead of a
+	// transcript. Language, if set, is the spoken language.
+	Translate bool
+}
+
+type TranscriptionSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+	// Confidence is between 0 and 1, when the vendor reports one.
+	Confidence *float64 `json:"confidence,omitempty"`
+	// AvgLogprob is Whisper's mean token log probability for the segment.
+	AvgLogprob *float64 `json:"avg_logprob,omitempty"`
+}
+
+// TranscriptionWord is one word and its timing, in seconds.
+type TranscriptionWord struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Word  string  `json:"word"`
+	// Confidence is between 0 and 1, when the vendor reports one.
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+type TranscriptionResponse struct {
+	Text     string
+	Segments []TranscriptionSegment
+	// Words is only filled when the request set WordTimestamps.
+	Words []TranscriptionWord
+	// Language is the detected language as the vendor reports it, when known.
+	Language string
+}
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type ChatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Temperature float64       `json:"temperature"`
+	Messages    []ChatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	// LogitBias maps token IDs, as decimal strings, to a bias from -100 to 100.
+	LogitBias map[string]int `json:"logit_bias,omitempty"`
+	// Stop ends generation at the first of these strings, which is not
+	// included in the output.
+	Stop []string `json:"stop,omitempty"`
+	// ResponseFormat {"type": "json_object"} asks for a reply that is a
+	// single JSON object.
+	ResponseFormat *ChatResponseFormat `json:"response_format,omitempty"`
+}
+
+type ChatResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type ChatCompletionResponse struct {
+	Content string
+	Usage   *TokenUsage
+}
+
+type apiKeyContextKey struct{}
+
+// WithRequestAPIKey attaches a caller-supplied (BYOT) vendor key to ctx.
+func WithRequestAPIKey(ctx context.Context, apiKey string) context.Context {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+}
+
+func RequestAPIKeyFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	value, _ := ctx.Value(apiKeyContextKey{}).(string)
+	return strings.TrimSpace(value)
+}
+
+type baseURLContextKey struct{}
+
+// WithRequestBaseURL sends the request's upstream calls to baseURL, a
+// caller's own endpoint, instead of the configured upstream. Clients that
+// honor it never send the server-side key there; only a key from
+// WithRequestAPIKey, if any.
+func WithRequestBaseURL(ctx context.Context, baseURL string) context.Context {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, baseURLContextKey{}, baseURL)
+}
+
+func RequestBaseURLFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	value, _ := ctx.Value(baseURLContextKey{}).(string)
+	return value
+}
@@ -0,0 +1,325 @@
+// Package whisper transcribes audio with self-hosted Whisper servers, so
+// EchoFlow can run without any cloud speech vendor.
+package whisper
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"echoflow/internal/bufpool"
+	"echoflow/internal/upstream"
+)
+
+// Server APIs.
+const (
+	// APIOpenAI is the OpenAI-compatible /v1/audio/transcriptions endpoint
+	// served by faster-whisper-server, speaches and LocalAI.
+	APIOpenAI = "openai"
+	// APIWhisperCpp is the /inference endpoint of whisper.cpp's server.
+	APIWhisperCpp = "whispercpp"
+	// APIASR is the /asr endpoint of whisper-asr-webservice, which runs
+	// faster-whisper or openai-whisper.
+	APIASR = "asr"
+)
+
+var APIs = []string{APIOpenAI, APIWhisperCpp, APIASR}
+
+// Server is one Whisper server and the model it serves. Each server runs a
+// single model, so the request's model picks the server.
+type Server struct {
+	Model string
+	URL   string
+}
+
+type ObserverFunc func(endpoint string, status int, duration time.Duration)
+
+type Option func(*Client)
+
+func WithObserver(observer ObserverFunc) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
+}
+
+// WithAPIKey sends key as a bearer token, for servers behind an
+// authenticating proxy.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = strings.TrimSpace(key)
+	}
+}
+
+// Client implements upstream.Transcriber and upstream.HealthChecker. It
+// never forwards callers' BYOT tokens.
+type Client struct {
+	api        string
+	servers    []Server
+	apiKey     string
+	httpClient *http.Client
+	observer   ObserverFunc
+}
+
+func New(api string, servers []Server, httpClient *http.Client, opts ...Option) (*Client, error) {
+	if !slices.Contains(APIs, api) {
+		return nil, fmt.Errorf("unknown whisper server API %q", api)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no whisper servers configured")
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Client{api: api, httpClient: httpClient}
+	for _, s := range servers {
+		c.servers = append(c.servers, Server{Model: s.Model, URL: strings.TrimRight(s.URL, "/")})
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c, nil
+}
+
+// DefaultModel is the model of the first server.
+func (c *Client) DefaultModel() string {
+	return c.servers[0].Model
+}
+
+func (c *Client) Transcribe(ctx context.Context, reqPayload upstream.TranscriptionRequest) (upstream.TranscriptionResponse, error) {
+	started := time.Now()
+	statusCode := 0
+	defer func() { c.observe("whisper_"+c.api, statusCode, time.Since(started)) }()
+
+	model := reqPayload.Model
+	if model == "" {
+		model = c.DefaultModel()
+	}
+	idx := slices.IndexFunc(c.servers, func(s Server) bool { return s.Model == model })
+	if idx < 0 {
+		return upstream.TranscriptionResponse{}, &upstream.Error{StatusCode: http.StatusNotFound, Body: fmt.Sprintf("no whisper server serves model %q", model)}
+	}
+
+	body, contentType, err := c.requestBody(reqPayload, model)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	// The transport can close the request body while still writing from it,
+	// so the buffer is only returned once the response has been read.
+	defer bufpool.Put(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(c.servers[idx].URL, reqPayload), io.NopCloser(bytes.NewReader(body.Bytes())))
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", contentType)
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	statusCode = resp.StatusCode
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return upstream.TranscriptionResponse{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return upstream.TranscriptionResponse{}, &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(data))}
+	}
+	return parseTranscript(data)
+}
+
+// CheckHealth requires every server to answer below 500. None of the APIs
+// has a standard health route, so the server root is requested.
+func (c *Client) CheckHealth(ctx context.Context) error {
+	for _, s := range c.servers {
+		if err := c.checkServer(ctx, s.URL); err != nil {
+			return fmt.Errorf("whisper server for %s: %w", s.Model, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) checkServer(ctx context.Context, base string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/", nil)
+	if err != nil {
+		return err
+	}
+	c.setAuth(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 500 {
+		return &upstream.Error{StatusCode: resp.StatusCode, Body: truncateBody(string(body))}
+	}
+	return nil
+}
+
+func (c *Client) endpoint(base string, reqPayload upstream.TranscriptionRequest) string {
+	switch c.api {
+	case APIWhisperCpp:
+		return base + "/inference"
+	case APIASR:
+		// segments and language are always part of the JSON output.
+		query := url.Values{"task": {"transcribe"}, "output": {"json"}, "encode": {"true"}}
+		if reqPayload.Translate {
+			query.Set("task", "translate")
+		}
+		if reqPayload.Language != "" {
+			query.Set("language", reqPayload.Language)
+		}
+		if reqPayload.Prompt != "" {
+			query.Set("initial_prompt", reqPayload.Prompt)
+		}
+		if reqPayload.WordTimestamps {
+			query.Set("word_timestamps", "true")
+		}
+		return base + "/asr?" + query.Encode()
+	default:
+		if reqPayload.Translate {
+			return base + "/v1/audio/translations"
+		}
+		return base + "/v1/audio/transcriptions"
+	}
+}
+
+func (c *Client) requestBody(reqPayload upstream.TranscriptionRequest, model string) (*bytes.Buffer, string, error) {
+	body := bufpool.Get()
+	writer := multipart.NewWriter(body)
+	err := func() error {
+		fileField := "file"
+		language := reqPayload.Language
+		if reqPayload.Translate && c.api == APIOpenAI {
+			language = ""
+		}
+		if c.api != APIASR {
+			for _, field := range [][2]string{{"language", language}, {"prompt", reqPayload.Prompt}} {
+				if field[1] == "" {
+					continue
+				}
+				if err := writer.WriteField(field[0], field[1]); err != nil {
+					return err
+				}
+			}
+		}
+		switch c.api {
+		case APIOpenAI:
+			if err := writer.WriteField("model", model); err != nil {
+				return err
+			}
+			if reqPayload.ResponseFormat != "" {
+				if err := writer.WriteField("response_format", reqPayload.ResponseFormat); err != nil {
+					return err
+				}
+			}
+			if reqPayload.WordTimestamps && !reqPayload.Translate {
+				for _, granularity := range []string{"segment", "word"} {
+					if err := writer.WriteField("timestamp_granularities[]", granularity); err != nil {
+						return err
+					}
+				}
+			}
+		case APIWhisperCpp:
+			format := "json"
+			if reqPayload.ResponseFormat == "verbose_json" {
+				format = "verbose_json"
+			}
+			if err := writer.WriteField("response_format", format); err != nil {
+				return err
+			}
+			if reqPayload.Translate {
+				if err := writer.WriteField("translate", "true"); err != nil {
+					return err
+				}
+			}
+		case APIASR:
+			fileField = "audio_file"
+		}
+		part, err := writer.CreateFormFile(fileField, reqPayload.FileName)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, reqPayload.File); err != nil {
+			return err
+		}
+		return writer.Close()
+	}()
+	if err != nil {
+		bufpool.Put(body)
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+// parseTranscript reads the {"text", "segments", "language"} shape all three
+// APIs share. Word timings are top-level "words" from OpenAI-compatible
+// servers, and nested in each segment, with a "probability", from
+// whisper-asr-webservice.
+func parseTranscript(data []byte) (upstream.TranscriptionResponse, error) {
+	type word struct {
+		upstream.TranscriptionWord
+		Probability *float64 `json:"probability"`
+	}
+	var parsed struct {
+		Text     string `json:"text"`
+		Segments []struct {
+			upstream.TranscriptionSegment
+			Words []word `json:"words"`
+		} `json:"segments"`
+		Words    []upstream.TranscriptionWord `json:"words"`
+		Language string                       `json:"language"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return upstream.TranscriptionResponse{}, fmt.Errorf("invalid whisper server response: %w", err)
+	}
+	resp := upstream.TranscriptionResponse{Text: strings.TrimSpace(parsed.Text), Words: parsed.Words, Language: parsed.Language}
+	for _, seg := range parsed.Segments {
+		seg.Text = strings.TrimSpace(seg.Text)
+		resp.Segments = append(resp.Segments, seg.TranscriptionSegment)
+		if len(parsed.Words) > 0 {
+			continue
+		}
+		for _, w := range seg.Words {
+			w.Word = strings.TrimSpace(w.Word)
+			w.Confidence = cmp.Or(w.Confidence, w.Probability)
+			resp.Words = append(resp.Words, w.TranscriptionWord)
+		}
+	}
+	return resp, nil
+}
+
+func (c *Client) setAuth(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+func (c *Client) observe(endpoint string, status int, duration time.Duration) {
+	if c.observer != nil {
+		c.observer(endpoint, status, duration)
+	}
+}
+
+func truncateBody(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= 4096 {
+		return s
+	}
+	return s[:4096] + "..."
+}
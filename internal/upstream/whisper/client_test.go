@@ -0,0 +1,115 @@
+package whisper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"echoflow/internal/upstream"
+)
+
+func TestTranscribeSpeaksEachServerAPI(t *testing.T) {
+	tests := []struct {
+		api, path, fileField, format string
+	}{
+		{APIOpenAI, "/v1/audio/transcriptions", "file", "verbose_json"},
+		{APIWhisperCpp, "/inference", "file", "verbose_json"},
+		{APIASR, "/asr", "audio_file", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.api, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path || r.Header.Get("Authorization") != "" {
+					t.Errorf("request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+				}
+				file, _, err := r.FormFile(tt.fileField)
+				if err != nil {
+					t.Errorf("missing %s: %v", tt.fileField, err)
+				} else if data, _ := io.ReadAll(file); string(data) != "wav-bytes" {
+					t.Errorf("file = %q", data)
+				}
+				if got := r.FormValue("response_format"); got != tt.format {
+					t.Errorf("response_format = %q, want %q", got, tt.format)
+				}
+				_, _ = io.WriteString(w, `{"text":" hello there ","language":"en","segments":[{"start":0,"end":1.5,"text":" hello there"}]}`)
+			}))
+			defer ts.Close()
+
+			c, err := New(tt.api, []Server{{Model: "large-v3", URL: ts.URL + "/"}}, ts.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Transcribe(upstream.WithRequestAPIKey(context.Background(), "caller-token"), upstream.TranscriptionRequest{
+				File:           strings.NewReader("wav-bytes"),
+				FileName:       "a.wav",
+				ResponseFormat: "verbose_json",
+			})
+			if err != nil {
+				t.Fatalf("Transcribe: %v", err)
+			}
+			if resp.Text != "hello there" || resp.Language != "en" || len(resp.Segments) != 1 || resp.Segments[0].Text != "hello there" {
+				t.Fatalf("resp = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestASRWordTimestampsComeFromSegments(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("word_timestamps") != "true" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		_, _ = io.WriteString(w, `{"text":" hello there","segments":[{"start":0,"end":1.5,"text":" hello there","avg_logprob":-0.2,
+			"words":[{"start":0,"end":0.6,"word":" hello","probability":0.98},{"start":0.7,"end":1.5,"word":" there","probability":0.9}]}]}`)
+	}))
+	defer ts.Close()
+
+	c, err := New(APIASR, []Server{{Model: "large-v3", URL: ts.URL}}, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("wav"), FileName: "a.wav", WordTimestamps: true})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if len(resp.Words) != 2 || resp.Words[1].Word != "there" || resp.Words[1].Start != 0.7 || *resp.Words[0].Confidence != 0.98 {
+		t.Fatalf("words = %+v", resp.Words)
+	}
+	if len(resp.Segments) != 1 || resp.Segments[0].AvgLogprob == nil {
+		t.Fatalf("segments = %+v", resp.Segments)
+	}
+}
+
+func TestTranscribePicksServerByModel(t *testing.T) {
+	serve := func(text string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"text":"`+text+`"}`)
+		}))
+	}
+	small, large := serve("small"), serve("large")
+	defer small.Close()
+	defer large.Close()
+
+	c, err := New(APIWhisperCpp, []Server{{Model: "small", URL: small.URL}, {Model: "large-v3", URL: large.URL}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("x"), Model: "large-v3"}); resp.Text != "large" {
+		t.Fatalf("large-v3 went to %q", resp.Text)
+	}
+	if resp, _ := c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("x")}); resp.Text != "small" {
+		t.Fatalf("default model went to %q", resp.Text)
+	}
+	_, err = c.Transcribe(context.Background(), upstream.TranscriptionRequest{File: strings.NewReader("x"), Model: "tiny"})
+	var upstreamErr *upstream.Error
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown model err = %v", err)
+	}
+	if err := c.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("CheckHealth: %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Package webhook delivers signed JSON callbacks with retries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"echoflow/internal/fetch"
+)
+
+const (
+	SignatureHeader = "X-EchoFlow-Signature"
+	// DeliveryHeader carries the delivery ID, which stays the same across
+	// every redelivery of one event. Receivers dedupe on it.
+	DeliveryHeader = "X-EchoFlow-Delivery"
+	// AttemptHeader numbers the attempts of one delivery from 1.
+	AttemptHeader = "X-EchoFlow-Delivery-Attempt"
+
+	// DefaultTolerance is how old a signature timestamp Verify accepts.
+	DefaultTolerance = 5 * time.Minute
+
+	defaultMaxAttempts = 5
+	defaultBaseDelay   = time.Second
+	maxDelay           = time.Minute
+)
+
+// Sender POSTs payloads to callback URLs. Every request carries
+// X-EchoFlow-Signature: t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">,
+// signed afresh for each attempt, and the X-EchoFlow-Delivery ID.
+type Sender struct {
+	secret       []byte
+	client       *http.Client
+	timeout      time.Duration
+	allowPrivate bool
+	maxAttempts  int
+	baseDelay    time.Duration
+	now          func() time.Time
+}
+
+type Option func(*Sender)
+
+// WithHTTPClient replaces the client that refuses non-public addresses.
+// Only meant for tests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sender) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// WithTimeout bounds each delivery attempt.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Sender) {
+		if d > 0 {
+			s.timeout = d
+		}
+	}
+}
+
+// WithPrivateNetworks allows delivering to non-public addresses. Only meant
+// for local development and tests.
+func WithPrivateNetworks(allow bool) Option {
+	return func(s *Sender) {
+		s.allowPrivate = allow
+	}
+}
+
+func WithMaxAttempts(attempts int) Option {
+	return func(s *Sender) {
+		if attempts > 0 {
+			s.maxAttempts = attempts
+		}
+	}
+}
+
+// WithBaseDelay sets the wait before the first retry; it doubles after each
+// failed attempt, up to a minute.
+func WithBaseDelay(delay time.Duration) Option {
+	return func(s *Sender) {
+		if delay > 0 {
+			s.baseDelay = delay
+		}
+	}
+}
+
+func New(secret string, opts ...Option) *Sender {
+	s := &Sender{
+		secret:      []byte(secret),
+		timeout:     10 * time.Second,
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	if s.client == nil {
+		s.client = s.newClient()
+	}
+	return s
+}
+
+// newClient dials only public addresses, unless allowed otherwise, and
+// neither follows redirects nor uses a proxy: a redirect could point a
+// callback at an internal host, and a proxy would dial on our behalf.
+func (s *Sender) newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if s.allowPrivate {
+				return nil
+			}
+			return fetch.PublicOnly(network, address, c)
+		},
+	}
+	return &http.Client{
+		Timeout: s.timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: s.timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// StatusError is returned when the receiver answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook receiver returned status %d", e.StatusCode)
+}
+
+// Deliver POSTs body to url until the receiver acknowledges it with a 2xx
+// response, answers with a non-retryable status (4xx other than 408 and 429),
+// or attempts run out. A Retry-After on a retryable response replaces the
+// backoff, up to a minute. Every attempt carries the same deliveryID.
+func (s *Sender) Deliver(ctx context.Context, url, deliveryID string, body []byte) error {
+	delay := s.baseDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = s.send(ctx, url, deliveryID, attempt, body)
+		if err == nil || !retryable(err) || attempt >= s.maxAttempts {
+			return err
+		}
+		wait := delay
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			wait = min(statusErr.RetryAfter, maxDelay)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+func (s *Sender) send(ctx context.Context, url, deliveryID string, attempt int, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "EchoFlow-Webhook/1")
+	req.Header.Set(SignatureHeader, Sign(s.secret, s.now(), body))
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			statusErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return statusErr
+	}
+	return nil
+}
+
+// Rejected reports whether err is the receiver refusing the delivery, with a
+// status that no later attempt will change.
+func Rejected(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && !retryable(err)
+}
+
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return !errors.Is(err, context.Canceled)
+	}
+	code := statusErr.StatusCode
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// Sign returns the signature header value for body sent at ts.
+func Sign(secret []byte, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeliveryID derives the ID of one event's delivery from what identifies the
+// event, such as a job ID and its final status, so a job that is run again
+// after a restart redelivers under the same ID.
+func DeliveryID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return "dlv_" + hex.EncodeToString(sum[:12])
+}
+
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrStaleSignature   = errors.New("webhook: signature timestamp outside tolerance")
+)
+
+// Verify checks a signature header against body and rejects timestamps more
+// than tolerance away from now, so a captured request cannot be replayed
+// later. It is what receivers written in Go should run before trusting a
+// callback.
+func Verify(secret []byte, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, field := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	want := Sign(secret, time.Unix(unix, 0), body)
+	if !hmac.Equal([]byte(want), []byte("t="+ts+",v1="+sig)) {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleSignature
+	}
+	return nil
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL and, unless
+// allowPrivate is set, that its host is not a loopback or private address.
+// Names that resolve to one are refused when the Sender dials them.
+func ValidateURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("callback_url is not a valid URL")
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("callback_url must be an absolute http or https URL")
+	}
+	if allowPrivate {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errPrivateURL
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !fetch.IsPublic(addr) {
+		return errPrivateURL
+	}
+	return nil
+}
+
+var errPrivateURL = errors.New("callback_url must not point at a loopback or private address")
@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"echoflow/internal/fetch"
+)
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	var gotSig, gotBody string
+	var deliveries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries = append(deliveries, r.Header.Get(DeliveryHeader)+"#"+r.Header.Get(AttemptHeader))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		gotSig, gotBody = r.Header.Get(SignatureHeader), string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	now := time.Unix(1700000000, 0)
+	s := New("secret", WithBaseDelay(time.Millisecond), WithPrivateNetworks(true))
+	s.now = func() time.Time { return now }
+
+	if err := s.Deliver(context.Background(), srv.URL, "dlv_1", []byte(`{"id":"job_1"}`)); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("calls = %d, want 3", calls.Load())
+	}
+	if strings.Join(deliveries, " ") != "dlv_1#1 dlv_1#2 dlv_1#3" {
+		t.Fatalf("delivery headers = %v", deliveries)
+	}
+	if gotBody != `{"id":"job_1"}` {
+		t.Fatalf("body = %q", gotBody)
+	}
+	// HMAC-SHA256("secret", `1700000000.{"id":"job_1"}`), pinned so receivers
+	// can check their verification code against it.
+	if want := "t=1700000000,v1=287e6e830f67761f411bf6f58d64f314188daeda980343d77443a9e6f4b74738"; gotSig != want {
+		t.Fatalf("signature = %q, want %q", gotSig, want)
+	}
+}
+
+func TestDeliverStopsOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	err := New("secret", WithBaseDelay(time.Millisecond), WithPrivateNetworks(true)).Deliver(context.Background(), srv.URL, "dlv_1", []byte(`{}`))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusGone {
+		t.Fatalf("err = %v, want status 410", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestDeliverHonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	started := time.Now()
+	if err := New("secret", WithBaseDelay(time.Millisecond), WithPrivateNetworks(true)).Deliver(context.Background(), srv.URL, "dlv_1", []byte(`{}`)); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Fatalf("retried after %v, want the 1s Retry-After", elapsed)
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	err := New("secret", WithMaxAttempts(1)).Deliver(context.Background(), srv.URL, "dlv_1", []byte(`{}`))
+	if !errors.Is(err, fetch.ErrBlockedAddress) || calls.Load() != 0 {
+		t.Fatalf("err = %v, calls = %d", err, calls.Load())
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			t.Error("followed the redirect")
+		}
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	err := New("secret", WithPrivateNetworks(true)).Deliver(context.Background(), srv.URL, "dlv_1", []byte(`{}`))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("err = %v, want status 307", err)
+	}
+}
+
+func TestVerifyChecksSignatureAndAge(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"delivery_id":"dlv_1"}`)
+	sent := time.Unix(1700000000, 0)
+	header := Sign(secret, sent, body)
+
+	if err := Verify(secret, header, body, sent.Add(time.Minute), DefaultTolerance); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := Verify(secret, header, []byte(`{"delivery_id":"dlv_2"}`), sent, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("tampered body: %v", err)
+	}
+	if err := Verify([]byte("other"), header, body, sent, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("wrong secret: %v", err)
+	}
+	if err := Verify(secret, header, body, sent.Add(time.Hour), DefaultTolerance); !errors.Is(err, ErrStaleSignature) {
+		t.Fatalf("replayed an hour later: %v", err)
+	}
+	if err := Verify(secret, "garbage", body, sent, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("malformed header: %v", err)
+	}
+}
+
+func TestDeliveryIDIsStablePerEvent(t *testing.T) {
+	a := DeliveryID("job_1", "job.succeeded")
+	if a != DeliveryID("job_1", "job.succeeded") || a == DeliveryID("job_1", "job.failed") || !strings.HasPrefix(a, "dlv_") {
+		t.Fatalf("DeliveryID = %q", a)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://example.com/hook":                 true,
+		"http://93.184.216.34/cb":                  true,
+		"http://localhost:8080/cb":                 false,
+		"http://api.localhost/cb":                  false,
+		"http://127.0.0.1:8080/cb":                 false,
+		"http://[::1]/cb":                          false,
+		"http://10.0.0.5/cb":                       false,
+		"http://169.254.169.254/latest/meta-data/": false,
+		"ftp://example.com":                        false,
+		"/relative":                                false,
+		"https://":                                 false,
+	} {
+		if err := ValidateURL(raw, false); (err == nil) != ok {
+			t.Errorf("ValidateURL(%q) = %v, want ok=%v", raw, err, ok)
+		}
+	}
+}
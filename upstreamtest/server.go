@@ -0,0 +1,350 @@
+// Package upstreamtest provides a fake OpenAI-compatible upstream for tests.
+// It serves /audio/transcriptions, /chat/completions (including streaming) and
+// /models, with configurable latency and injected failures.
+package upstreamtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTranscript = "hello from the fake upstream"
+	DefaultCompletion = "Hello from the fake upstream."
+)
+
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Request records a call the server received.
+type Request struct {
+	Method string
+	Path   string
+	Model  string
+	APIKey string
+	Stream bool
+}
+
+type Option func(*Server)
+
+// WithLatency delays every response by base plus a uniform random jitter.
+func WithLatency(base, jitter time.Duration) Option {
+	return func(s *Server) {
+		s.latency = base
+		s.jitter = jitter
+	}
+}
+
+// WithErrorRate fails the given fraction of requests with status.
+func WithErrorRate(rate float64, status int) Option {
+	return func(s *Server) {
+		s.errorRate = rate
+		s.errorStatus = status
+	}
+}
+
+func WithTranscript(text string, segments ...Segment) Option {
+	return func(s *Server) {
+		s.transcript = text
+		s.segments = segments
+	}
+}
+
+func WithCompletion(text string, usage *Usage) Option {
+	return func(s *Server) {
+		s.completion = text
+		s.usage = usage
+	}
+}
+
+// WithStreamChunkDelay sets the pause between streamed chat completion chunks.
+func WithStreamChunkDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.chunkDelay = d
+	}
+}
+
+// WithAPIKey makes the server reject requests without this bearer token.
+func WithAPIKey(key string) Option {
+	return func(s *Server) {
+		s.apiKey = key
+	}
+}
+
+func WithModels(models ...string) Option {
+	return func(s *Server) {
+		s.models = models
+	}
+}
+
+// WithSeed makes latency jitter and error injection deterministic.
+func WithSeed(seed uint64) Option {
+	return func(s *Server) {
+		s.rng = rand.New(rand.NewPCG(seed, seed))
+	}
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	rng         *rand.Rand
+	latency     time.Duration
+	jitter      time.Duration
+	errorRate   float64
+	errorStatus int
+	transcript  string
+	segments    []Segment
+	completion  string
+	usage       *Usage
+	chunkDelay  time.Duration
+	apiKey      string
+	models      []string
+	requests    []Request
+}
+
+// NewServer starts a fake upstream. Call Close when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		rng:         rand.New(rand.NewPCG(1, 1)),
+		errorStatus: http.StatusInternalServerError,
+		transcript:  DefaultTranscript,
+		completion:  DefaultCompletion,
+		models:      []string{"whisper-large-v3", "llama-3.3-70b-versatile"},
+	}
+	s.Configure(opts...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /audio/transcriptions", s.handleTranscriptions)
+	mux.HandleFunc("POST /chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("GET /models", s.handleModels)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Configure applies options to a running server, e.g. to start failing
+// requests midway through a test.
+func (s *Server) Configure(opts ...Option) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+}
+
+// Requests returns the requests received so far, in arrival order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Count returns how many requests were made to path.
+func (s *Server) Count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, req := range s.requests {
+		if req.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// begin records the request, applies latency and decides whether to inject a
+// failure. It returns false when the response has already been written.
+func (s *Server) begin(w http.ResponseWriter, r *http.Request, rec Request) bool {
+	rec.Method = r.Method
+	rec.Path = r.URL.Path
+	rec.APIKey = strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, rec)
+	delay := s.latency
+	if s.jitter > 0 {
+		delay += time.Duration(s.rng.Int64N(int64(s.jitter)))
+	}
+	fail := s.errorRate > 0 && s.rng.Float64() < s.errorRate
+	status := s.errorStatus
+	wantKey := s.apiKey
+	s.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return false
+		}
+	}
+	if wantKey != "" && rec.APIKey != wantKey {
+		writeError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid API Key")
+		return false
+	}
+	if fail {
+		writeError(w, status, "injected_failure", fmt.Sprintf("upstreamtest: injected %d", status))
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	if !s.begin(w, r, Request{Model: r.FormValue("model")}) {
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "file is required")
+		return
+	}
+	_, _ = io.Copy(io.Discard, file)
+	_ = file.Close()
+
+	s.mu.Lock()
+	text, segments := s.transcript, append([]Segment(nil), s.segments...)
+	s.mu.Unlock()
+
+	switch r.FormValue("response_format") {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, text)
+	case "verbose_json":
+		if len(segments) == 0 {
+			segments = []Segment{{Start: 0, End: 1, Text: text}}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"text": text, "segments": segments})
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"text": text})
+	}
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if !s.begin(w, r, Request{Model: req.Model, Stream: req.Stream}) {
+		return
+	}
+
+	s.mu.Lock()
+	content, usage, chunkDelay := s.completion, s.usage, s.chunkDelay
+	s.mu.Unlock()
+
+	if !req.Stream {
+		resp := map[string]any{
+			"object":  "chat.completion",
+			"model":   req.Model,
+			"choices": []map[string]any{{"index": 0, "message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"}},
+		}
+		if usage != nil {
+			resp["usage"] = usage
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+	for _, chunk := range streamChunks(content) {
+		payload, _ := json.Marshal(map[string]any{
+			"object":  "chat.completion.chunk",
+			"model":   req.Model,
+			"choices": []map[string]any{{"index": 0, "delta": map[string]string{"content": chunk}}},
+		})
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+			return
+		}
+		_ = rc.Flush()
+		if chunkDelay > 0 {
+			select {
+			case <-time.After(chunkDelay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+	final := map[string]any{
+		"object":  "chat.completion.chunk",
+		"model":   req.Model,
+		"choices": []map[string]any{{"index": 0, "delta": map[string]string{}, "finish_reason": "stop"}},
+	}
+	if usage != nil {
+		final["usage"] = usage
+	}
+	payload, _ := json.Marshal(final)
+	_, _ = fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", payload)
+	_ = rc.Flush()
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(w, r, Request{}) {
+		return
+	}
+	s.mu.Lock()
+	data := make([]map[string]string, 0, len(s.models))
+	for _, id := range s.models {
+		data = append(data, map[string]string{"id": id, "object": "model"})
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+// streamChunks splits content into word-sized deltas that concatenate back to
+// the original string.
+func streamChunks(content string) []string {
+	var chunks []string
+	for len(content) > 0 {
+		i := strings.IndexByte(content[1:], ' ')
+		if i < 0 {
+			chunks = append(chunks, content)
+			break
+		}
+		chunks = append(chunks, content[:i+1])
+		content = content[i+1:]
+	}
+	return chunks
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]string{"message": message, "type": "api_error", "code": code},
+	})
+}
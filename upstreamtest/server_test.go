@@ -0,0 +1,121 @@
+package upstreamtest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echoflow/internal/upstream"
+	"echoflow/internal/upstream/openai"
+)
+
+func TestServerServesTranscriptionsAndCompletions(t *testing.T) {
+	srv := NewServer(
+		WithTranscript("hi there", Segment{Start: 0, End: 1, Text: "hi"}, Segment{Start: 1, End: 2, Text: "there"}),
+		WithCompletion("Hi there.", &Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}),
+		WithAPIKey("test-key"),
+	)
+	defer srv.Close()
+	c := openai.New(srv.URL, "test-key", srv.Client())
+
+	tr, err := c.Transcribe(context.Background(), upstream.TranscriptionRequest{
+		File: strings.NewReader("audio"), FileName: "a.wav", Model: "whisper", ResponseFormat: "verbose_json",
+	})
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if tr.Text != "hi there" || len(tr.Segments) != 2 {
+		t.Fatalf("unexpected transcription: %+v", tr)
+	}
+
+	chat, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "llama"})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if chat.Content != "Hi there." || chat.Usage == nil || chat.Usage.TotalTokens != 7 {
+		t.Fatalf("unexpected completion: %+v", chat)
+	}
+
+	if err := c.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("CheckHealth() error = %v", err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 3 || reqs[0].Model != "whisper" || reqs[0].APIKey != "test-key" {
+		t.Fatalf("unexpected recorded requests: %+v", reqs)
+	}
+}
+
+func TestServerInjectsErrors(t *testing.T) {
+	srv := NewServer(WithErrorRate(1, http.StatusTooManyRequests))
+	defer srv.Close()
+	c := openai.New(srv.URL, "k", srv.Client())
+
+	_, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "llama"})
+	var upErr *upstream.Error
+	if !errors.As(err, &upErr) || upErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected injected 429, got %v", err)
+	}
+
+	srv.Configure(WithErrorRate(0, 0))
+	if _, err := c.ChatCompletion(context.Background(), upstream.ChatCompletionRequest{Model: "llama"}); err != nil {
+		t.Fatalf("expected recovery after reconfigure, got %v", err)
+	}
+}
+
+func TestServerInjectsLatency(t *testing.T) {
+	srv := NewServer(WithLatency(200*time.Millisecond, 0))
+	defer srv.Close()
+	c := openai.New(srv.URL, "k", srv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.CheckHealth(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestServerStreamsCompletionChunks(t *testing.T) {
+	srv := NewServer(WithCompletion("one two three", nil))
+	defer srv.Close()
+
+	resp, err := srv.Client().Post(srv.URL+"/chat/completions", "application/json", strings.NewReader(`{"model":"llama","stream":true}`))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	if len(events) != 5 || events[len(events)-1] != "[DONE]" || !strings.Contains(events[1], `"content":" two"`) {
+		t.Fatalf("unexpected stream: %q", events)
+	}
+}
+
+func TestServerIsSafeForConcurrentUse(t *testing.T) {
+	srv := NewServer(WithLatency(time.Millisecond, time.Millisecond), WithErrorRate(0.5, http.StatusBadGateway), WithSeed(7))
+	defer srv.Close()
+	c := openai.New(srv.URL, "k", srv.Client())
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.CheckHealth(context.Background())
+		}()
+	}
+	wg.Wait()
+	if got := srv.Count("/models"); got != 20 {
+		t.Fatalf("expected 20 recorded requests, got %d", got)
+	}
+}